@@ -0,0 +1,227 @@
+// Package determinism reports workflow code that behaves differently on replay.
+//
+// A workflow function is re-executed from the top every time it resumes, and
+// only step results are memoized. Code outside steps must therefore make the
+// same decisions on every run. The analyzer inspects every function or
+// closure with a *engine.Context parameter and flags:
+//
+//   - time.Now, time.Since and time.Until
+//   - functions from math/rand, math/rand/v2 and crypto/rand
+//   - ranging over a map, whose iteration order is randomized
+//   - go statements, which the workflow does not wait for (use ctx.Go)
+//   - step IDs built from unstable data: clock readings, random values
+//     (including UUIDs), or counters advanced while ranging over a map,
+//     directly or through variables holding them. The step gets a new ID
+//     on resume, so it runs again and repeats its side effects.
+//
+// Bodies passed to the engine's step functions (Step, AutoStep, LocalStep,
+// Map, StepWithContext, StepWithInfo, TxStep and StepStream) are exempt:
+// their results are recorded and replayed. A finding can be silenced with a
+// "//workflowvet:ignore" comment on the same or the preceding line.
+package determinism
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// EnginePath is the import path of the engine package
+const EnginePath = "github.com/yourusername/durable-execution-engine/engine"
+
+const ignoreDirective = "//workflowvet:ignore"
+
+// Analyzer flags non-deterministic code in workflow functions
+var Analyzer = &analysis.Analyzer{
+	Name:     "determinism",
+	Doc:      "report non-deterministic code in durable workflow functions",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+// randPackages are the packages whose functions are random by design
+var randPackages = map[string]bool{
+	"math/rand":    true,
+	"math/rand/v2": true,
+	"crypto/rand":  true,
+}
+
+// clockFuncs are the time functions that read the wall clock
+var clockFuncs = map[string]bool{
+	"Now":   true,
+	"Since": true,
+	"Until": true,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	// The engine implements replay itself and is free to read the clock
+	if pass.Pkg.Path() == EnginePath {
+		return nil, nil
+	}
+
+	ignored := ignoredLines(pass)
+	report := func(pos token.Pos, format string, args ...interface{}) {
+		p := pass.Fset.Position(pos)
+		if ignored[p.Filename][p.Line] || ignored[p.Filename][p.Line-1] {
+			return
+		}
+		pass.Reportf(pos, format, args...)
+	}
+
+	ins := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	ins.Preorder([]ast.Node{(*ast.FuncDecl)(nil), (*ast.FuncLit)(nil)}, func(n ast.Node) {
+		var typ *ast.FuncType
+		var body *ast.BlockStmt
+		switch fn := n.(type) {
+		case *ast.FuncDecl:
+			typ, body = fn.Type, fn.Body
+		case *ast.FuncLit:
+			typ, body = fn.Type, fn.Body
+		}
+		if body == nil || !takesContext(pass, typ) {
+			return
+		}
+		checkWorkflowBody(pass, body, report)
+	})
+
+	return nil, nil
+}
+
+// checkWorkflowBody reports non-deterministic constructs in one workflow function
+func checkWorkflowBody(pass *analysis.Pass, body *ast.BlockStmt, report func(token.Pos, string, ...interface{})) {
+	vars := unstableVars(pass, body)
+	stepIDs := make(map[ast.Expr]bool)
+	ast.Inspect(body, func(n ast.Node) bool {
+		if expr, ok := n.(ast.Expr); ok && stepIDs[expr] {
+			// Already checked with the step ID rules
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			// Nested workflow functions are checked on their own
+			return !takesContext(pass, n.Type)
+
+		case *ast.CallExpr:
+			if id := stepIDArg(pass, n); id != nil {
+				checkStepID(pass, id, vars, report)
+				stepIDs[id] = true
+			}
+			if isStepCall(pass, n) {
+				// Only the arguments before the body (ctx, id) run on replay
+				for _, arg := range n.Args {
+					if _, ok := arg.(*ast.FuncLit); !ok && !stepIDs[arg] {
+						ast.Inspect(arg, func(m ast.Node) bool {
+							if call, ok := m.(*ast.CallExpr); ok {
+								checkCall(pass, call, report)
+							}
+							return true
+						})
+					}
+				}
+				return false
+			}
+			checkCall(pass, n, report)
+
+		case *ast.RangeStmt:
+			if t := pass.TypesInfo.TypeOf(n.X); t != nil {
+				if _, ok := t.Underlying().(*types.Map); ok {
+					report(n.For, "map iteration order is random; sort the keys before ranging in workflow code")
+				}
+			}
+
+		case *ast.GoStmt:
+			report(n.Go, "goroutine started in workflow code; use ctx.Go so the workflow waits for it")
+		}
+		return true
+	})
+}
+
+// checkCall reports clock and random-number calls
+func checkCall(pass *analysis.Pass, call *ast.CallExpr, report func(token.Pos, string, ...interface{})) {
+	fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+	if !ok || fn.Pkg() == nil {
+		return
+	}
+
+	path := fn.Pkg().Path()
+	switch {
+	case path == "time" && clockFuncs[fn.Name()] && isPackageFunc(fn):
+		report(call.Pos(), "time.%s is not replay-safe; read the clock inside engine.Step", fn.Name())
+	case randPackages[path]:
+		report(call.Pos(), "%s.%s is not replay-safe; generate random values inside engine.Step", path, fn.Name())
+	}
+}
+
+// stepFuncs are the engine functions whose body argument runs as a recorded step
+var stepFuncs = map[string]bool{
+	"Step":            true,
+	"AutoStep":        true,
+	"LocalStep":       true,
+	"Map":             true,
+	"StepWithContext": true,
+	"StepWithInfo":    true,
+	"TxStep":          true,
+	"StepStream":      true,
+}
+
+// isStepCall reports whether call is one of the engine's stepFuncs
+func isStepCall(pass *analysis.Pass, call *ast.CallExpr) bool {
+	fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != EnginePath || !isPackageFunc(fn) {
+		return false
+	}
+	return stepFuncs[fn.Name()]
+}
+
+// takesContext reports whether a function has a *engine.Context parameter
+func takesContext(pass *analysis.Pass, typ *ast.FuncType) bool {
+	if typ.Params == nil {
+		return false
+	}
+	for _, field := range typ.Params.List {
+		ptr, ok := pass.TypesInfo.TypeOf(field.Type).(*types.Pointer)
+		if !ok {
+			continue
+		}
+		named, ok := ptr.Elem().(*types.Named)
+		if !ok {
+			continue
+		}
+		obj := named.Obj()
+		if obj.Name() == "Context" && obj.Pkg() != nil && obj.Pkg().Path() == EnginePath {
+			return true
+		}
+	}
+	return false
+}
+
+// isPackageFunc reports whether fn is a package-level function rather than a method
+func isPackageFunc(fn *types.Func) bool {
+	return fn.Type().(*types.Signature).Recv() == nil
+}
+
+// ignoredLines indexes the lines carrying an ignore directive, by file
+func ignoredLines(pass *analysis.Pass) map[string]map[int]bool {
+	lines := make(map[string]map[int]bool)
+	for _, file := range pass.Files {
+		for _, group := range file.Comments {
+			for _, c := range group.List {
+				if !strings.HasPrefix(c.Text, ignoreDirective) {
+					continue
+				}
+				p := pass.Fset.Position(c.Slash)
+				if lines[p.Filename] == nil {
+					lines[p.Filename] = make(map[int]bool)
+				}
+				lines[p.Filename][p.Line] = true
+			}
+		}
+	}
+	return lines
+}
@@ -0,0 +1,13 @@
+package determinism_test
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+
+	"github.com/yourusername/durable-execution-engine/analysis/determinism"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), determinism.Analyzer, "workflows")
+}
@@ -0,0 +1,206 @@
+package determinism
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// stepIDParams are the names of engine parameters that become step IDs
+var stepIDParams = map[string]bool{
+	"id":     true,
+	"stepID": true,
+	"prefix": true,
+}
+
+// orderDependent is the instability of variables changed while ranging over a map
+const orderDependent = "map iteration order"
+
+// idRandomFuncs are functions outside randPackages that return random values
+var idRandomFuncs = map[string]bool{
+	"github.com/google/uuid.New":       true,
+	"github.com/google/uuid.NewString": true,
+	"github.com/google/uuid.NewRandom": true,
+	"github.com/google/uuid.NewUUID":   true,
+	"github.com/google/uuid.NewV7":     true,
+}
+
+// stepIDArg returns the argument of an engine call that becomes a step ID, if any
+func stepIDArg(pass *analysis.Pass, call *ast.CallExpr) ast.Expr {
+	fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != EnginePath {
+		return nil
+	}
+	params := fn.Type().(*types.Signature).Params()
+	for i := 0; i < params.Len() && i < len(call.Args); i++ {
+		p := params.At(i)
+		if stepIDParams[p.Name()] && types.Identical(p.Type(), types.Typ[types.String]) {
+			return call.Args[i]
+		}
+	}
+	return nil
+}
+
+// unstableSource describes a call whose result differs between runs, or
+// returns "" for other calls
+func unstableSource(pass *analysis.Pass, call *ast.CallExpr) string {
+	fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+	if !ok || fn.Pkg() == nil || !isPackageFunc(fn) {
+		return ""
+	}
+	path := fn.Pkg().Path()
+	switch {
+	case path == "time" && clockFuncs[fn.Name()]:
+		return "time." + fn.Name()
+	case randPackages[path], idRandomFuncs[path+"."+fn.Name()]:
+		return path + "." + fn.Name()
+	}
+	return ""
+}
+
+// unstableVars finds the variables of a workflow function that hold
+// unstable data: results of clock and random calls, values computed from
+// them, and variables changed while ranging over a map, whose value at a
+// given iteration depends on the random iteration order. Each variable
+// maps to the source of its instability.
+func unstableVars(pass *analysis.Pass, body *ast.BlockStmt) map[types.Object]string {
+	vars := make(map[types.Object]string)
+
+	// source returns what makes an expression unstable, if anything
+	source := func(expr ast.Expr) string {
+		found := ""
+		ast.Inspect(expr, func(n ast.Node) bool {
+			if found != "" {
+				return false
+			}
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				return false
+			case *ast.CallExpr:
+				found = unstableSource(pass, n)
+			case *ast.Ident:
+				found = vars[pass.TypesInfo.Uses[n]]
+			}
+			return found == ""
+		})
+		return found
+	}
+	taint := func(lhs ast.Expr, src string) {
+		if id, ok := lhs.(*ast.Ident); ok && src != "" {
+			if obj := pass.TypesInfo.ObjectOf(id); obj != nil && vars[obj] == "" {
+				vars[obj] = src
+			}
+		}
+	}
+
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for i, lhs := range n.Lhs {
+				if len(n.Rhs) == len(n.Lhs) {
+					taint(lhs, source(n.Rhs[i]))
+				} else if len(n.Rhs) == 1 {
+					taint(lhs, source(n.Rhs[0]))
+				}
+			}
+
+		case *ast.ValueSpec:
+			for i, name := range n.Names {
+				if i < len(n.Values) {
+					taint(name, source(n.Values[i]))
+				} else if len(n.Values) == 1 {
+					taint(name, source(n.Values[0]))
+				}
+			}
+
+		case *ast.RangeStmt:
+			if t := pass.TypesInfo.TypeOf(n.X); t != nil {
+				if _, ok := t.Underlying().(*types.Map); ok {
+					markOrderDependent(pass, n, vars)
+				}
+			}
+
+		case *ast.CallExpr:
+			// Sorting what was collected from a map fixes its order
+			if isSortCall(pass, n) && len(n.Args) > 0 {
+				if id, ok := n.Args[0].(*ast.Ident); ok {
+					if obj := pass.TypesInfo.Uses[id]; vars[obj] == orderDependent {
+						delete(vars, obj)
+					}
+				}
+			}
+		}
+		return true
+	})
+	return vars
+}
+
+// isSortCall reports whether call sorts its first argument in place
+func isSortCall(pass *analysis.Pass, call *ast.CallExpr) bool {
+	fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+	if !ok || fn.Pkg() == nil || !isPackageFunc(fn) {
+		return false
+	}
+	switch fn.Pkg().Path() {
+	case "sort":
+		return true
+	case "slices":
+		return strings.HasPrefix(fn.Name(), "Sort")
+	}
+	return false
+}
+
+// markOrderDependent records the variables declared outside a range over a
+// map that its body changes
+func markOrderDependent(pass *analysis.Pass, loop *ast.RangeStmt, vars map[types.Object]string) {
+	mark := func(expr ast.Expr) {
+		id, ok := expr.(*ast.Ident)
+		if !ok {
+			return
+		}
+		obj := pass.TypesInfo.Uses[id]
+		if obj == nil || (obj.Pos() >= loop.Pos() && obj.Pos() < loop.End()) {
+			return
+		}
+		if vars[obj] == "" {
+			vars[obj] = orderDependent
+		}
+	}
+	ast.Inspect(loop.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				mark(lhs)
+			}
+		case *ast.IncDecStmt:
+			mark(n.X)
+		}
+		return true
+	})
+}
+
+// checkStepID reports step IDs built from unstable data: on resume the
+// step gets a different ID, so its recorded result isn't found and the step
+// runs again, repeating its side effects
+func checkStepID(pass *analysis.Pass, id ast.Expr, vars map[types.Object]string, report func(token.Pos, string, ...interface{})) {
+	ast.Inspect(id, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.CallExpr:
+			if src := unstableSource(pass, n); src != "" {
+				report(n.Pos(), "step ID built from %s is not replay-safe: the step gets a new ID on resume and runs again", src)
+				return false
+			}
+		case *ast.Ident:
+			if src := vars[pass.TypesInfo.Uses[n]]; src != "" {
+				report(n.Pos(), "step ID built from %s, which depends on %s: the step gets a new ID on resume and runs again", n.Name, src)
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,9 @@
+package uuid
+
+type UUID [16]byte
+
+func (u UUID) String() string { return "" }
+
+func New() UUID { return UUID{} }
+
+func NewString() string { return "" }
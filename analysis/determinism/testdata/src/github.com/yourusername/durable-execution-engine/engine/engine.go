@@ -0,0 +1,46 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"time"
+)
+
+type Context struct{}
+
+func (ctx *Context) Go(fn func() error) {}
+
+func (ctx *Context) Wait() error { return nil }
+
+func (ctx *Context) Sleep(id string, d time.Duration) error { return nil }
+
+func (ctx *Context) Logf(format string, args ...interface{}) {}
+
+func Step[T any](ctx *Context, id string, fn func() (T, error)) (T, error) { return fn() }
+
+func AutoStep[T any](ctx *Context, fn func() (T, error)) (T, error) { return fn() }
+
+func LocalStep[T any](ctx *Context, id string, fn func() (T, error)) (T, error) { return fn() }
+
+func Map[In, Out any](ctx *Context, prefix string, items []In, fn func(i int, item In) (Out, error)) ([]Out, error) {
+	return nil, nil
+}
+
+type StepInfo struct{ Attempt int }
+
+func StepWithContext[T any](ctx *Context, id string, fn func(c context.Context) (T, error)) (T, error) {
+	return fn(context.Background())
+}
+
+func StepWithInfo[T any](ctx *Context, id string, fn func(info StepInfo) (T, error)) (T, error) {
+	return fn(StepInfo{})
+}
+
+func TxStep[T any](ctx *Context, id string, db *sql.DB, fn func(tx *sql.Tx) (T, error)) (T, error) {
+	return fn(nil)
+}
+
+func StepStream(ctx *Context, id string, fn func(w io.Writer) error) (io.ReadCloser, error) {
+	return nil, fn(nil)
+}
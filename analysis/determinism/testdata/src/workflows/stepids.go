@@ -0,0 +1,47 @@
+package workflows
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/durable-execution-engine/engine"
+)
+
+func Reserve(ctx *engine.Context, stock map[string]int) error {
+	batch := time.Now().Format("20060102")                                         // want `time.Now is not replay-safe`
+	engine.LocalStep(ctx, "reserve-"+batch, func() (int, error) { return 0, nil }) // want `step ID built from batch, which depends on time.Now`
+
+	engine.Step(ctx, "charge-"+uuid.NewString(), func() (int, error) { return 0, nil })                                 // want `step ID built from github.com/google/uuid.NewString is not replay-safe`
+	ctx.Sleep("wait-"+strconv.Itoa(rand.Intn(3)), time.Second)                                                          // want `step ID built from math/rand.Intn is not replay-safe`
+	engine.Map(ctx, fmt.Sprintf("ship-%d", time.Now().Unix()), []int{1}, func(i, n int) (int, error) { return n, nil }) // want `step ID built from time.Now is not replay-safe`
+
+	i := 0
+	var skus []string
+	for sku := range stock { // want `map iteration order is random`
+		// Keys are stable IDs; a counter depends on the iteration order
+		engine.Step(ctx, "hold-"+sku, func() (int, error) { return 0, nil })
+		engine.Step(ctx, fmt.Sprintf("line-%d", i), func() (int, error) { return 0, nil }) // want `step ID built from i, which depends on map iteration order`
+		i++
+		skus = append(skus, sku)
+	}
+	engine.Step(ctx, "first-"+skus[0], func() (int, error) { return 0, nil }) // want `step ID built from skus, which depends on map iteration order`
+
+	var sorted []string
+	for sku := range stock { //workflowvet:ignore keys are sorted below
+		sorted = append(sorted, sku)
+	}
+	sort.Strings(sorted)
+	for n, sku := range sorted {
+		engine.Step(ctx, fmt.Sprintf("pack-%d-%s", n, sku), func() (int, error) { return 0, nil })
+	}
+
+	// Values computed inside steps are replayed, so IDs built from them are stable
+	id, _ := engine.Step(ctx, "new-id", func() (string, error) { return uuid.New().String(), nil })
+	engine.Step(ctx, "label-"+id, func() (int, error) { return 0, nil })
+	ctx.Logf("reserved at %v", batch)
+	return nil
+}
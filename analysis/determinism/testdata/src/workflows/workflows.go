@@ -0,0 +1,93 @@
+package workflows
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/yourusername/durable-execution-engine/engine"
+)
+
+func Checkout(ctx *engine.Context) error {
+	started := time.Now()  // want `time.Now is not replay-safe`
+	if rand.Intn(2) == 0 { // want `math/rand.Intn is not replay-safe`
+		return nil
+	}
+
+	prices := map[string]int{"a": 1, "b": 2}
+	for sku := range prices { // want `map iteration order is random`
+		fmt.Println(sku)
+	}
+
+	go func() {}() // want `goroutine started in workflow code`
+
+	ctx.Go(func() error {
+		_ = time.Since(started) // want `time.Since is not replay-safe`
+		return nil
+	})
+
+	// Step bodies are memoized, so anything goes inside them
+	_, err := engine.Step(ctx, "stamp", func() (time.Time, error) {
+		go func() {}()
+		return time.Now().Add(time.Duration(rand.Intn(10))), nil
+	})
+	if err != nil {
+		return err
+	}
+	_, err = engine.AutoStep(ctx, func() (int, error) { return rand.Int(), nil })
+	if err != nil {
+		return err
+	}
+
+	// So are the bodies of the other step functions
+	engine.LocalStep(ctx, "jitter", func() (int, error) { return rand.Intn(100), nil })
+	engine.Map(ctx, "price", []string{"a", "b"}, func(i int, sku string) (int64, error) {
+		return time.Now().UnixNano(), nil
+	})
+	engine.StepWithContext(ctx, "fetch", func(c context.Context) (time.Time, error) { return time.Now(), nil })
+	engine.StepWithInfo(ctx, "attempt", func(info engine.StepInfo) (int, error) {
+		for range prices {
+		}
+		return info.Attempt, nil
+	})
+	engine.TxStep(ctx, "insert", nil, func(tx *sql.Tx) (int64, error) { return time.Now().Unix(), nil })
+	engine.StepStream(ctx, "export", func(w io.Writer) error {
+		_, err := fmt.Fprint(w, rand.Int())
+		return err
+	})
+
+	// Step IDs are evaluated on every replay
+	engine.Step(ctx, fmt.Sprint(time.Now().Unix()), func() (int, error) { return 0, nil }) // want `time.Now is not replay-safe`
+
+	keys := make([]string, 0, len(prices))
+	for k := range prices { //workflowvet:ignore keys are sorted below
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	//workflowvet:ignore deadline is only logged
+	fmt.Println(time.Now())
+
+	// Durations and methods on time values are deterministic
+	_ = started.Add(time.Hour)
+	return ctx.Wait()
+}
+
+// Helpers without a workflow context are not checked
+func helper() time.Time {
+	return time.Now()
+}
+
+var register = func(name string, fn func(*engine.Context) error) {}
+
+func init() {
+	register("inline", func(ctx *engine.Context) error {
+		for range map[int]bool{} { // want `map iteration order is random`
+		}
+		return nil
+	})
+}
@@ -0,0 +1,386 @@
+// Package client starts, signals and inspects workflows hosted by a remote
+// engine over HTTP. It mirrors the Engine API without linking the storage
+// layer, so application services can drive a central worker cluster that
+// serves the API with the server package.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors returned for the matching engine errors on the server
+var (
+	ErrWorkflowNotFound     = errors.New("workflow not found")
+	ErrWorkflowNotCompleted = errors.New("workflow not completed")
+	ErrInvalidRequest       = errors.New("invalid request")
+	ErrUnavailable          = errors.New("engine unavailable")
+	ErrUnauthorized         = errors.New("unauthorized")
+)
+
+// Client talks to one engine API server. It is safe for concurrent use.
+type Client struct {
+	baseURL string
+	http    *http.Client
+	token   string
+}
+
+// DialOption configures a Client
+type DialOption func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests, e.g. for TLS or timeouts
+func WithHTTPClient(c *http.Client) DialOption {
+	return func(cl *Client) {
+		if c != nil {
+			cl.http = c
+		}
+	}
+}
+
+// WithBearerToken sends token with every request, for servers using
+// server.BearerToken
+func WithBearerToken(token string) DialOption {
+	return func(cl *Client) {
+		cl.token = token
+	}
+}
+
+// Dial connects to the engine API at addr ("host:port" or a base URL) and
+// checks that it answers
+func Dial(addr string, opts ...DialOption) (*Client, error) {
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	c := &Client{
+		baseURL: strings.TrimRight(addr, "/"),
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if err := c.do(http.MethodGet, "/v1/health", nil, nil); err != nil {
+		return nil, fmt.Errorf("failed to dial %s: %w", addr, err)
+	}
+	return c, nil
+}
+
+// Close releases idle connections
+func (c *Client) Close() error {
+	c.http.CloseIdleConnections()
+	return nil
+}
+
+// WorkflowOption configures a single workflow start
+type WorkflowOption func(*StartRequest)
+
+// WithQueue places the workflow on a named queue
+func WithQueue(queue string) WorkflowOption {
+	return func(r *StartRequest) {
+		r.Queue = queue
+	}
+}
+
+// WithPriority sets the workflow's priority; higher priorities are claimed first
+func WithPriority(priority int) WorkflowOption {
+	return func(r *StartRequest) {
+		r.Priority = priority
+	}
+}
+
+// WithConcurrencyKey serializes workflows sharing key; others stay queued while one runs
+func WithConcurrencyKey(key string) WorkflowOption {
+	return func(r *StartRequest) {
+		r.ConcurrencyKey = key
+	}
+}
+
+// WithWorkflowTimeout bounds the whole run, measured from when it is enqueued
+func WithWorkflowTimeout(d time.Duration) WorkflowOption {
+	return func(r *StartRequest) {
+		r.TimeoutMs = d.Milliseconds()
+	}
+}
+
+// WithOnComplete enqueues the named workflow when this one completes
+func WithOnComplete(workflowName string) WorkflowOption {
+	return func(r *StartRequest) {
+		r.OnComplete = append(r.OnComplete, workflowName)
+	}
+}
+
+// WithParams sets the parameters of a templated workflow
+func WithParams(params map[string]string) WorkflowOption {
+	return func(r *StartRequest) {
+		r.Params = params
+	}
+}
+
+// WithHeader attaches metadata such as a correlation ID, readable in the
+// workflow with ctx.Header
+func WithHeader(name, value string) WorkflowOption {
+	return func(r *StartRequest) {
+		if r.Headers == nil {
+			r.Headers = make(map[string]string)
+		}
+		r.Headers[name] = value
+	}
+}
+
+// Enqueue durably records a run of a registered workflow for the cluster's
+// workers. The input is sent as JSON. Enqueueing an existing ID is a no-op.
+func (c *Client) Enqueue(workflowID, workflowName string, input interface{}, opts ...WorkflowOption) error {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return fmt.Errorf("failed to marshal workflow input: %w", err)
+	}
+	req := &StartRequest{WorkflowID: workflowID, Name: workflowName, Input: data}
+	for _, opt := range opts {
+		opt(req)
+	}
+	if err := c.do(http.MethodPost, "/v1/workflows", req, nil); err != nil {
+		return fmt.Errorf("failed to enqueue workflow: %w", err)
+	}
+	return nil
+}
+
+// Signal delivers a named signal to a workflow
+func (c *Client) Signal(workflowID, name string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal signal payload: %w", err)
+	}
+	path := "/v1/workflows/" + url.PathEscape(workflowID) + "/signals/" + url.PathEscape(name)
+	if err := c.do(http.MethodPost, path, json.RawMessage(data), nil); err != nil {
+		return fmt.Errorf("failed to send signal: %w", err)
+	}
+	return nil
+}
+
+// CancelWorkflow marks a queued or running workflow as canceled
+func (c *Client) CancelWorkflow(workflowID string) error {
+	if err := c.do(http.MethodPost, "/v1/workflows/"+url.PathEscape(workflowID)+"/cancel", nil, nil); err != nil {
+		return fmt.Errorf("failed to cancel workflow: %w", err)
+	}
+	return nil
+}
+
+// GetWorkflow returns a workflow run, or ErrWorkflowNotFound
+func (c *Client) GetWorkflow(workflowID string) (*WorkflowInfo, error) {
+	var info WorkflowInfo
+	if err := c.do(http.MethodGet, "/v1/workflows/"+url.PathEscape(workflowID), nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// GetWorkflowStatus returns the status of a workflow run
+func (c *Client) GetWorkflowStatus(workflowID string) (string, error) {
+	info, err := c.GetWorkflow(workflowID)
+	if err != nil {
+		return "", err
+	}
+	return info.Status, nil
+}
+
+// ListWorkflows returns the runs matching filter, newest first
+func (c *Client) ListWorkflows(filter WorkflowFilter) ([]WorkflowInfo, error) {
+	q := url.Values{}
+	for _, n := range filter.Names {
+		q.Add("name", n)
+	}
+	for _, p := range filter.ParentIDs {
+		q.Add("parent_id", p)
+	}
+	if filter.Status != "" {
+		q.Set("status", filter.Status)
+	}
+	if filter.Limit > 0 {
+		q.Set("limit", strconv.Itoa(filter.Limit))
+	}
+
+	var runs []WorkflowInfo
+	if err := c.do(http.MethodGet, "/v1/workflows?"+q.Encode(), nil, &runs); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// GetHistory returns every recorded step of a workflow in sequence order
+func (c *Client) GetHistory(workflowID string) ([]StepRecord, error) {
+	var steps []StepRecord
+	if err := c.do(http.MethodGet, "/v1/workflows/"+url.PathEscape(workflowID)+"/history", nil, &steps); err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
+// GetLogs returns the lines captured from a run, oldest first
+func (c *Client) GetLogs(workflowID string) ([]LogLine, error) {
+	var lines []LogLine
+	if err := c.do(http.MethodGet, "/v1/workflows/"+url.PathEscape(workflowID)+"/logs", nil, &lines); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// Result decodes the output of a completed workflow into out. It returns
+// ErrWorkflowNotCompleted while the workflow is still queued or running.
+func (c *Client) Result(workflowID string, out interface{}) error {
+	info, err := c.GetWorkflow(workflowID)
+	if err != nil {
+		return err
+	}
+	if info.Status != "completed" {
+		return fmt.Errorf("%w: %s is %s", ErrWorkflowNotCompleted, workflowID, info.Status)
+	}
+	if len(info.Output) == 0 || out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(info.Output, out); err != nil {
+		return fmt.Errorf("failed to unmarshal workflow output: %w", err)
+	}
+	return nil
+}
+
+// EventsFromNow watches only events recorded after the watch starts
+const EventsFromNow int64 = -1
+
+// EventStream receives events pushed by the server
+type EventStream struct {
+	body io.ReadCloser
+	dec  *json.Decoder
+}
+
+// Recv blocks until the next event arrives. It returns io.EOF once the
+// stream ends: a watched workflow finished or the server shut down.
+func (s *EventStream) Recv() (*Event, error) {
+	var ev Event
+	if err := s.dec.Decode(&ev); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	return &ev, nil
+}
+
+// Close stops the stream
+func (s *EventStream) Close() error {
+	return s.body.Close()
+}
+
+// WatchWorkflow streams status changes of a workflow and its steps after
+// event ID after (0 for its whole history, or EventsFromNow). The stream
+// ends after the workflow completes, fails or is canceled.
+func (c *Client) WatchWorkflow(ctx context.Context, workflowID string, after int64) (*EventStream, error) {
+	return c.watch(ctx, "/v1/workflows/"+url.PathEscape(workflowID)+"/events", after)
+}
+
+// WatchAll streams status changes of every workflow after event ID after
+// (or EventsFromNow) until ctx is done. Resume a broken stream by passing
+// the ID of the last event received.
+func (c *Client) WatchAll(ctx context.Context, after int64) (*EventStream, error) {
+	return c.watch(ctx, "/v1/events", after)
+}
+
+// watch opens an event stream; it is not bound by the client's request timeout
+func (c *Client) watch(ctx context.Context, path string, after int64) (*EventStream, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		c.baseURL+path+"?after="+strconv.FormatInt(after, 10), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	c.authorize(req)
+
+	streaming := *c.http
+	streaming.Timeout = 0
+	resp, err := streaming.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, decodeError(resp)
+	}
+	return &EventStream{body: resp.Body, dec: json.NewDecoder(resp.Body)}, nil
+}
+
+// do sends one request and decodes a JSON response into out, if given
+func (c *Client) do(method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	c.authorize(req)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return decodeError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// authorize adds the client's bearer token, if it has one
+func (c *Client) authorize(req *http.Request) {
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+}
+
+// decodeError maps an error response to the matching sentinel error
+func decodeError(resp *http.Response) error {
+	var e ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil || e.Message == "" {
+		e.Message = resp.Status
+	}
+
+	var sentinel error
+	switch e.Code {
+	case CodeNotFound:
+		sentinel = ErrWorkflowNotFound
+	case CodeNotCompleted:
+		sentinel = ErrWorkflowNotCompleted
+	case CodeInvalid:
+		sentinel = ErrInvalidRequest
+	case CodeUnavailable:
+		sentinel = ErrUnavailable
+	case CodeUnauthorized:
+		sentinel = ErrUnauthorized
+	default:
+		return fmt.Errorf("server error: %s", e.Message)
+	}
+	return fmt.Errorf("%w: %s", sentinel, strings.TrimPrefix(e.Message, sentinel.Error()+": "))
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WorkflowInfo describes one workflow run
+type WorkflowInfo struct {
+	WorkflowID string          `json:"workflow_id"`
+	Name       string          `json:"name,omitempty"` // empty for workflows started with Execute
+	Status     string          `json:"status"`
+	Queue      string          `json:"queue"`
+	ParentID   string          `json:"parent_id,omitempty"`
+	Output     json.RawMessage `json:"output,omitempty"` // JSON result, set once completed
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+// WorkflowFilter selects runs for ListWorkflows; empty fields match every run
+type WorkflowFilter struct {
+	Names     []string
+	ParentIDs []string
+	Status    string
+	Limit     int // newest runs first; applies per name when Names is set
+}
+
+// StepRecord is one persisted step of a workflow run
+type StepRecord struct {
+	StepID      string          `json:"step_id"`
+	StepKey     string          `json:"step_key"`
+	SequenceNum int64           `json:"sequence_num"`
+	Kind        string          `json:"kind"`
+	Status      string          `json:"status"` // in_progress, completed, failed or canceled
+	Output      json.RawMessage `json:"output,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	WorkerID    string          `json:"worker_id,omitempty"`
+	Hostname    string          `json:"hostname,omitempty"`
+	PID         int             `json:"pid,omitempty"`
+	StartedAt   time.Time       `json:"started_at"`
+	CompletedAt time.Time       `json:"completed_at"` // zero while in progress
+	Attempts    []StepAttempt   `json:"attempts,omitempty"`
+}
+
+// Duration returns how long the step ran, or zero if it has not finished
+func (r *StepRecord) Duration() time.Duration {
+	if r.CompletedAt.IsZero() {
+		return 0
+	}
+	return r.CompletedAt.Sub(r.StartedAt)
+}
+
+// StepAttempt is one execution of a step's function
+type StepAttempt struct {
+	Attempt     int       `json:"attempt"`
+	Status      string    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	WorkerID    string    `json:"worker_id,omitempty"`
+	Hostname    string    `json:"hostname,omitempty"`
+	PID         int       `json:"pid,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// LogLine is one line a run printed, captured by an engine with log capture on
+type LogLine struct {
+	Seq     int64     `json:"seq"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// Event is one status change of a workflow or one of its steps, or a line
+// logged by the workflow
+type Event struct {
+	ID         int64     `json:"id"` // pass as after to resume watching
+	WorkflowID string    `json:"workflow_id"`
+	StepID     string    `json:"step_id,omitempty"` // empty for workflow status changes and logs
+	StepKey    string    `json:"step_key,omitempty"`
+	Status     string    `json:"status"` // "log" for lines logged by the workflow
+	Error      string    `json:"error,omitempty"`
+	Message    string    `json:"message,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// StartRequest is the body of POST /v1/workflows
+type StartRequest struct {
+	WorkflowID     string            `json:"workflow_id"`
+	Name           string            `json:"name"`
+	Input          json.RawMessage   `json:"input,omitempty"`
+	Queue          string            `json:"queue,omitempty"`
+	Priority       int               `json:"priority,omitempty"`
+	ConcurrencyKey string            `json:"concurrency_key,omitempty"`
+	TimeoutMs      int64             `json:"timeout_ms,omitempty"`
+	OnComplete     []string          `json:"on_complete,omitempty"`
+	Params         map[string]string `json:"params,omitempty"`
+	Headers        map[string]string `json:"headers,omitempty"`
+}
+
+// ErrorResponse is the body of every non-2xx response
+type ErrorResponse struct {
+	Code    string `json:"code"` // one of the Code* constants
+	Message string `json:"message"`
+}
+
+// Error codes carried by ErrorResponse
+const (
+	CodeNotFound     = "not_found"
+	CodeNotCompleted = "not_completed"
+	CodeInvalid      = "invalid"
+	CodeUnavailable  = "unavailable"
+	CodeUnauthorized = "unauthorized"
+	CodeInternal     = "internal"
+)
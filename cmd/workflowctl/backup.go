@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/yourusername/durable-execution-engine/engine"
+)
+
+// backupDatabase writes a snapshot of the database to a .tar.zst file
+func backupDatabase(eng *engine.Engine, args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	out := fs.String("out", "", "snapshot file to write (default workflows-<timestamp>.tar.zst)")
+	fs.Parse(args)
+	if *out == "" {
+		*out = fmt.Sprintf("workflows-%s.tar.zst", time.Now().UTC().Format("20060102T150405Z"))
+	}
+
+	f, err := os.OpenFile(*out, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", *out, err)
+	}
+	manifest, err := eng.Backup(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(*out)
+		return err
+	}
+
+	fmt.Printf("wrote %s (%d bytes uncompressed)\n", *out, manifest.SizeBytes)
+	printManifest(manifest)
+	return nil
+}
+
+// restoreDatabase replaces the database at dbPath with a snapshot. It runs
+// before any engine opens the database.
+func restoreDatabase(dbPath string, args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	force := fs.Bool("force", false, "replace an existing database")
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	if _, err := os.Stat(dbPath); err == nil && !*force {
+		return fmt.Errorf("%s already exists; stop all workers and pass -force to replace it", dbPath)
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer f.Close()
+
+	manifest, err := engine.RestoreBackup(f, dbPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("restored %s from snapshot taken %s\n", dbPath, manifest.CreatedAt.Local().Format(time.RFC3339))
+	printManifest(manifest)
+	return nil
+}
+
+// printManifest lists the row count of each table in a snapshot
+func printManifest(manifest *engine.BackupManifest) {
+	tables := make([]string, 0, len(manifest.Tables))
+	for table := range manifest.Tables {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		fmt.Printf("  %-20s %d rows\n", table, manifest.Tables[table])
+	}
+}
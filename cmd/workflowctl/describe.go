@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/yourusername/durable-execution-engine/engine"
+)
+
+// describeWorkflow prints a run's steps as an indented tree
+func describeWorkflow(eng *engine.Engine, workflowID string) error {
+	tree, err := eng.DescribeWorkflow(workflowID)
+	if err != nil {
+		return err
+	}
+	fmt.Println(nodeLine(tree, true, time.Now()))
+	printTree(os.Stdout, tree.Children, "", time.Now())
+	return nil
+}
+
+// printTree writes nodes below a parent, drawing branches with prefix
+func printTree(w io.Writer, nodes []*engine.DescribeNode, prefix string, now time.Time) {
+	for i, n := range nodes {
+		branch, indent := "├─ ", "│  "
+		if i == len(nodes)-1 {
+			branch, indent = "└─ ", "   "
+		}
+		fmt.Fprintf(w, "%s%s%s\n", prefix, branch, nodeLine(n, false, now))
+		printTree(w, n.Children, prefix+indent, now)
+	}
+}
+
+// nodeLine formats one node: its name, status, timing and error
+func nodeLine(n *engine.DescribeNode, root bool, now time.Time) string {
+	name := n.Name
+	switch n.Kind {
+	case engine.NodeWorkflow:
+		if !root {
+			name = "workflow " + n.Name
+		}
+	case engine.NodeMap:
+		name = "map " + n.Name
+	case engine.NodeParallel:
+		name = "parallel (" + n.Name + ")"
+	}
+
+	timing := ""
+	switch {
+	case n.Duration() > 0:
+		timing = "  " + n.Duration().Round(time.Millisecond).String()
+	case n.CompletedAt.IsZero() && !n.StartedAt.IsZero() && n.Status != "queued":
+		timing = "  running for " + now.Sub(n.StartedAt).Round(time.Second).String()
+	}
+
+	line := fmt.Sprintf("%s  %s%s", name, n.Status, timing)
+	if n.Error != "" {
+		line += ": " + n.Error
+	}
+	return line
+}
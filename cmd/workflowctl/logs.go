@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/yourusername/durable-execution-engine/engine"
+)
+
+// showLogs prints the lines captured from a run, oldest first
+func showLogs(eng *engine.Engine, workflowID string) error {
+	lines, err := eng.GetWorkflowLogs(workflowID)
+	if err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		fmt.Println("no captured lines (is log capture on for the engines running it?)")
+		return nil
+	}
+	for _, line := range lines {
+		fmt.Printf("%s  %s\n", line.Time.Local().Format("2006-01-02 15:04:05.000"), line.Message)
+	}
+	return nil
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/yourusername/durable-execution-engine/dashboard"
+	"github.com/yourusername/durable-execution-engine/engine"
+	"github.com/yourusername/durable-execution-engine/server"
+)
+
+// workflowctl is the operator CLI for inspecting a workflow database
+func main() {
+	dbPath := flag.String("db", "./workflows.db", "path or DSN of the workflow database")
+	replica := flag.String("replica", "", "path or DSN of a read replica to serve listings, history and stats from")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+	// openapi describes the API without a database
+	if flag.Arg(0) == "openapi" {
+		if err := writeOpenAPI(flag.Args()[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// restore replaces the database file, so it must run before it is opened
+	if flag.Arg(0) == "restore" {
+		if err := restoreDatabase(*dbPath, flag.Args()[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var opts []engine.EngineOption
+	if *replica != "" {
+		opts = append(opts, engine.WithReadReplica(*replica))
+	}
+	eng, err := engine.NewEngine(*dbPath, opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open engine: %v\n", err)
+		os.Exit(1)
+	}
+	defer eng.Close()
+
+	switch flag.Arg(0) {
+	case "workers":
+		err = listWorkers(eng)
+	case "stats":
+		err = showStats(eng)
+	case "top":
+		err = runTop(eng, flag.Args()[1:])
+	case "pending":
+		if flag.NArg() < 2 {
+			usage()
+			os.Exit(2)
+		}
+		err = showPending(eng, flag.Arg(1))
+	case "describe":
+		if flag.NArg() < 2 {
+			usage()
+			os.Exit(2)
+		}
+		err = describeWorkflow(eng, flag.Arg(1))
+	case "tail":
+		err = tailWorkflow(eng, flag.Args()[1:])
+	case "logs":
+		if flag.NArg() < 2 {
+			usage()
+			os.Exit(2)
+		}
+		err = showLogs(eng, flag.Arg(1))
+	case "errors":
+		err = searchErrors(eng, flag.Args()[1:])
+	case "diff":
+		if flag.NArg() < 3 {
+			usage()
+			os.Exit(2)
+		}
+		err = diffRuns(eng, flag.Arg(1), flag.Arg(2))
+	case "replay":
+		err = replayRun(eng, flag.Args()[1:])
+	case "export":
+		err = exportHistory(eng, flag.Args()[1:])
+	case "backup":
+		err = backupDatabase(eng, flag.Args()[1:])
+	case "maintain":
+		err = maintain(eng)
+	case "serve":
+		err = serve(eng, flag.Args()[1:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: workflowctl [-db path] [-replica path] <command>")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Commands:")
+	fmt.Fprintln(os.Stderr, "  workers    list registered workers and their health")
+	fmt.Fprintln(os.Stderr, "  stats      show workflow counts by status, database size and step queue latency")
+	fmt.Fprintln(os.Stderr, "  top [-interval 2s] [-window 1m]")
+	fmt.Fprintln(os.Stderr, "             live view of running workflows, step throughput, failures and queue backlog")
+	fmt.Fprintln(os.Stderr, "  pending <workflow-id>")
+	fmt.Fprintln(os.Stderr, "             show in-progress steps and what they are waiting on")
+	fmt.Fprintln(os.Stderr, "  describe <workflow-id>")
+	fmt.Fprintln(os.Stderr, "             show a run's steps as a tree: maps, parallel groups, retries and child runs")
+	fmt.Fprintln(os.Stderr, "  tail [-new] <workflow-id>")
+	fmt.Fprintln(os.Stderr, "             follow a workflow's step events and logged lines until it finishes")
+	fmt.Fprintln(os.Stderr, "  logs <workflow-id>")
+	fmt.Fprintln(os.Stderr, "             print the lines a run printed, captured by engines with log capture on")
+	fmt.Fprintln(os.Stderr, "  errors [-since 24h] <text>")
+	fmt.Fprintln(os.Stderr, "             find failed steps whose error contains text")
+	fmt.Fprintln(os.Stderr, "  diff <run1> <run2>")
+	fmt.Fprintln(os.Stderr, "             compare two runs' steps, outputs and timings")
+	fmt.Fprintln(os.Stderr, "  replay [-interactive] <workflow-id>")
+	fmt.Fprintln(os.Stderr, "             step through a run's recorded history, showing each step's output, error and attempts")
+	fmt.Fprintln(os.Stderr, "  export [-out file] <workflow-id>")
+	fmt.Fprintln(os.Stderr, "             write a run's history as Temporal event history JSON")
+	fmt.Fprintln(os.Stderr, "  maintain   checkpoint and truncate the WAL and reclaim free pages")
+	fmt.Fprintln(os.Stderr, "  backup [-out snapshot.tar.zst]")
+	fmt.Fprintln(os.Stderr, "             write a consistent snapshot of the database, schedules included")
+	fmt.Fprintln(os.Stderr, "  restore [-force] <snapshot.tar.zst>")
+	fmt.Fprintln(os.Stderr, "             replace the database with a verified snapshot (stop all workers first)")
+	fmt.Fprintln(os.Stderr, "  serve [-token token] [addr]")
+	fmt.Fprintln(os.Stderr, "             serve the dashboard GraphQL API on /graphql and the workflow API on /v1 (default 127.0.0.1:8080);")
+	fmt.Fprintln(os.Stderr, "             with -token or $WORKFLOWCTL_API_TOKEN, requests must send it as a bearer token")
+	fmt.Fprintln(os.Stderr, "  openapi [-out openapi.json]")
+	fmt.Fprintln(os.Stderr, "             write the OpenAPI document of the workflow API, for generating SDKs")
+}
+
+// writeOpenAPI prints the OpenAPI document of the /v1 API, or writes it to -out
+func writeOpenAPI(args []string) error {
+	fs := flag.NewFlagSet("openapi", flag.ExitOnError)
+	out := fs.String("out", "", "file to write instead of stdout")
+	fs.Parse(args)
+
+	data, err := json.MarshalIndent(server.OpenAPI(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode OpenAPI document: %w", err)
+	}
+	data = append(data, '\n')
+	if *out == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write OpenAPI document: %w", err)
+	}
+	fmt.Printf("Wrote the OpenAPI document to %s\n", *out)
+	return nil
+}
+
+// listWorkers prints the worker fleet as a table
+func listWorkers(eng *engine.Engine) error {
+	workers, err := eng.ListWorkers()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "WORKER\tHOST\tPID\tVERSION\tQUEUES\tLABELS\tCAPACITY\tHEALTH\tLAST HEARTBEAT")
+	for _, w := range workers {
+		health := "dead"
+		if w.Alive(now) {
+			health = "alive"
+		} else if w.Status == "stopped" {
+			health = "stopped"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\t%d\t%s\t%s ago\n",
+			w.WorkerID, w.Hostname, w.PID, w.Version, strings.Join(w.Queues, ","),
+			strings.Join(w.Labels, ","), w.Capacity, health, now.Sub(w.LastHeartbeat).Round(time.Second))
+	}
+	return tw.Flush()
+}
+
+// showStats prints the engine summary
+func showStats(eng *engine.Engine) error {
+	stats, err := eng.Stats()
+	if err != nil {
+		return err
+	}
+
+	statuses := make([]string, 0, len(stats.ByStatus))
+	for status := range stats.ByStatus {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATUS\tWORKFLOWS")
+	for _, status := range statuses {
+		fmt.Fprintf(tw, "%s\t%d\n", status, stats.ByStatus[status])
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	if stats.OldestRunningID != "" {
+		fmt.Printf("Oldest running: %s (since %s)\n", stats.OldestRunningID,
+			stats.OldestRunningSince.Format(time.RFC3339))
+	}
+	fmt.Printf("Database size:  %d bytes\n", stats.DBSizeBytes)
+
+	latencies, err := eng.StepQueueLatency(time.Now().Add(-time.Hour))
+	if err != nil || len(latencies) == 0 {
+		return err
+	}
+	fmt.Println()
+	tw = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "QUEUE\tSTEPS (1H)\tSCHEDULE-TO-START P50\tP90\tP99\tMAX")
+	for _, l := range latencies {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\n", l.Queue, l.Steps,
+			l.P50.Round(time.Millisecond), l.P90.Round(time.Millisecond), l.P99.Round(time.Millisecond), l.Max.Round(time.Millisecond))
+	}
+	return tw.Flush()
+}
+
+// maintain runs storage maintenance once and reports what it did
+func maintain(eng *engine.Engine) error {
+	stats, err := eng.Maintain()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("WAL frames checkpointed: %d/%d\n", stats.CheckpointedFrames, stats.WALFrames)
+	fmt.Printf("Pages freed:             %d\n", stats.FreedPages)
+	if stats.Busy {
+		fmt.Println("Checkpoint incomplete: other connections are active; the WAL was not truncated")
+	}
+	return nil
+}
+
+// showPending prints the in-progress steps of a workflow
+func showPending(eng *engine.Engine, workflowID string) error {
+	steps, err := eng.GetPendingSteps(workflowID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STEP\tKIND\tOWNER\tRUNNING FOR\tWAITING ON")
+	for _, p := range steps {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			p.StepID, p.Kind, p.Owner, now.Sub(p.StartedAt).Round(time.Second), p.WaitingOn)
+	}
+	return tw.Flush()
+}
+
+// searchErrors prints failed steps whose error matches the query
+func searchErrors(eng *engine.Engine, args []string) error {
+	fs := flag.NewFlagSet("errors", flag.ExitOnError)
+	since := fs.Duration("since", 24*time.Hour, "only show failures newer than this")
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	matches, err := eng.SearchErrors(strings.Join(fs.Args(), " "), time.Now().Add(-*since))
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FAILED AT\tWORKFLOW\tSTEP\tERROR")
+	for _, m := range matches {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n",
+			m.FailedAt.Local().Format(time.RFC3339), m.WorkflowID, m.StepID, m.Error)
+	}
+	return tw.Flush()
+}
+
+// diffRuns prints a step-by-step comparison of two workflow runs
+func diffRuns(eng *engine.Engine, run1, run2 string) error {
+	diff, err := eng.DiffWorkflows(run1, run2)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "STEP\tCHANGE\t%s\t%s\tDETAILS\n", run1, run2)
+	for _, s := range diff.Steps {
+		change := string(s.Change)
+		if s.Reordered {
+			change += " (reordered)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			s.StepID, change, stepTiming(s.First), stepTiming(s.Second), strings.Join(s.Differences, "; "))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if diff.Identical() {
+		fmt.Println("\nruns are identical")
+	}
+	return nil
+}
+
+// stepTiming summarizes a step's status and duration for the diff table
+func stepTiming(r *engine.StepRecord) string {
+	if r == nil {
+		return "-"
+	}
+	if r.CompletedAt.IsZero() {
+		return r.Status
+	}
+	return fmt.Sprintf("%s %s", r.Status, r.Duration().Round(time.Millisecond))
+}
+
+// serve exposes the dashboard GraphQL API and the workflow API until the
+// process is stopped. It listens on localhost unless given another address.
+func serve(eng *engine.Engine, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	token := fs.String("token", os.Getenv("WORKFLOWCTL_API_TOKEN"), "bearer token requests must send")
+	fs.Parse(args)
+	addr := "127.0.0.1:8080"
+	if fs.NArg() > 0 {
+		addr = fs.Arg(0)
+	}
+
+	handler, err := dashboard.NewHandler(eng)
+	if err != nil {
+		return err
+	}
+	var api http.Handler
+	if *token != "" {
+		handler = server.Authenticate(handler, server.BearerToken(*token))
+		api = server.NewHandler(eng, server.WithAuth(server.BearerToken(*token)))
+	} else {
+		api = server.NewHandler(eng)
+		fmt.Println("Serving without authentication; pass -token to require one")
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/graphql", handler)
+	mux.Handle("/v1/", api)
+
+	fmt.Printf("Serving GraphQL on http://%s/graphql and the workflow API on http://%s/v1/\n", addr, addr)
+	return http.ListenAndServe(addr, mux)
+}
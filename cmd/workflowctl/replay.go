@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/yourusername/durable-execution-engine/engine"
+)
+
+// replayRun steps through a run's recorded history in the order it
+// happened, pausing before each step with -interactive
+func replayRun(eng *engine.Engine, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	interactive := fs.Bool("interactive", false, "pause before each step")
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+	workflowID := fs.Arg(0)
+	// Flags may also follow the workflow ID
+	fs.Parse(fs.Args()[1:])
+
+	run, err := eng.GetWorkflow(workflowID)
+	if err != nil {
+		return err
+	}
+	history, err := eng.GetHistory(workflowID)
+	if err != nil {
+		return err
+	}
+	input, err := eng.GetWorkflowInput(workflowID)
+	if err != nil {
+		return err
+	}
+
+	name := run.Name
+	if name == "" {
+		name = "(unregistered)"
+	}
+	fmt.Printf("workflow %s  %s  %s  %d steps\n", run.WorkflowID, name, run.Status, len(history))
+	if input != nil {
+		fmt.Printf("input:\n%s\n", formatValue(input))
+	}
+
+	c := &replayConsole{in: bufio.NewScanner(os.Stdin), out: os.Stdout, pause: *interactive}
+	for i := range history {
+		step := engine.ReplayStep{
+			Index:       i + 1,
+			StepID:      history[i].StepID,
+			SequenceNum: history[i].SequenceNum,
+			Kind:        history[i].Kind,
+			Recorded:    &history[i],
+		}
+		if !c.breakpoint(step, len(history)) {
+			return nil
+		}
+		c.show(step)
+	}
+
+	fmt.Printf("\nworkflow %s\n", run.Status)
+	if run.Output != nil {
+		fmt.Printf("output:\n%s\n", formatValue(run.Output))
+	}
+	return nil
+}
+
+// replayConsole pauses a replay before each step and reads commands
+type replayConsole struct {
+	in    *bufio.Scanner
+	out   io.Writer
+	pause bool
+}
+
+// breakpoint announces the next step and, while pausing, waits for a
+// command; it reports false if the user quit
+func (c *replayConsole) breakpoint(step engine.ReplayStep, total int) bool {
+	fmt.Fprintf(c.out, "\n[%d/%d] %s  (%s, sequence %d)\n", step.Index, total, step.StepID, step.Kind, step.SequenceNum)
+	for c.pause {
+		fmt.Fprint(c.out, "(n)ext, (c)ontinue, (a)ttempts, (q)uit> ")
+		if !c.in.Scan() {
+			return false
+		}
+		switch strings.TrimSpace(c.in.Text()) {
+		case "", "n", "next":
+			return true
+		case "c", "continue":
+			c.pause = false
+		case "a", "attempts":
+			printAttempts(c.out, step.Recorded)
+		case "q", "quit":
+			return false
+		default:
+			fmt.Fprintln(c.out, "unknown command")
+		}
+	}
+	return true
+}
+
+// show prints what a step returned when it ran
+func (c *replayConsole) show(step engine.ReplayStep) {
+	r := step.Recorded
+	timing := ""
+	if r.Duration() > 0 {
+		timing = " in " + r.Duration().Round(time.Millisecond).String()
+	}
+	attempts := ""
+	if len(r.Attempts) > 1 {
+		attempts = fmt.Sprintf(" after %d attempts", len(r.Attempts))
+	}
+	fmt.Fprintf(c.out, "  %s%s%s on %s at %s\n", r.Status, timing, attempts, r.Executor(), r.StartedAt.Local().Format(time.RFC3339))
+	if r.Error != "" {
+		fmt.Fprintf(c.out, "  error: %s\n", r.Error)
+	}
+	if r.Output != nil {
+		fmt.Fprintf(c.out, "  output:\n%s\n", indent(formatValue(r.Output), "    "))
+	}
+}
+
+// printAttempts lists each execution of a step
+func printAttempts(w io.Writer, r *engine.StepRecord) {
+	if len(r.Attempts) == 0 {
+		fmt.Fprintln(w, "  no attempts recorded")
+		return
+	}
+	for _, a := range r.Attempts {
+		line := fmt.Sprintf("  attempt %d  %s  %s on %s", a.Attempt, a.Status, a.Duration().Round(time.Millisecond), a.Executor())
+		if a.Error != "" {
+			line += ": " + a.Error
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// formatValue pretty-prints a JSON value, or describes other encodings
+func formatValue(data []byte) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "  "); err != nil {
+		return fmt.Sprintf("<%d bytes, not JSON>", len(data))
+	}
+	return buf.String()
+}
+
+// indent prefixes every line of s
+func indent(s, prefix string) string {
+	return prefix + strings.ReplaceAll(s, "\n", "\n"+prefix)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/yourusername/durable-execution-engine/engine"
+)
+
+// tailWorkflow prints a workflow's step events and logged lines as they are
+// recorded, until the workflow finishes or the command is interrupted
+func tailWorkflow(eng *engine.Engine, args []string) error {
+	fs := flag.NewFlagSet("tail", flag.ExitOnError)
+	onlyNew := fs.Bool("new", false, "skip events recorded before tail started")
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+	workflowID := fs.Arg(0)
+
+	if _, err := eng.GetWorkflowStatus(workflowID); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	after := int64(0)
+	if *onlyNew {
+		after = engine.EventsFromNow
+	}
+	events, err := eng.WatchEvents(ctx, workflowID, after)
+	if err != nil {
+		return err
+	}
+
+	started := make(map[string]time.Time)
+	for ev := range events {
+		printEvent(os.Stdout, ev, started)
+	}
+	return nil
+}
+
+// printEvent writes one event line; started tracks step start times so
+// completions can show how long the attempt took
+func printEvent(w io.Writer, ev engine.WorkflowEvent, started map[string]time.Time) {
+	at := ev.Time.Local().Format("15:04:05.000")
+
+	switch {
+	case ev.Status == engine.EventLog:
+		fmt.Fprintf(w, "%s  log       %s\n", at, ev.Message)
+	case ev.StepID == "":
+		fmt.Fprintf(w, "%s  workflow  %s\n", at, ev.Status)
+	case ev.Status == engine.EventAttemptFailed:
+		fmt.Fprintf(w, "%s  step      %s %s failed: %s\n", at, ev.StepID, ev.Message, ev.Error)
+	case ev.Status == "in_progress":
+		started[ev.StepKey] = ev.Time
+		fmt.Fprintf(w, "%s  step      %s started\n", at, ev.StepID)
+	default:
+		took := ""
+		if start, ok := started[ev.StepKey]; ok {
+			took = fmt.Sprintf(" (%s)", ev.Time.Sub(start).Round(time.Millisecond))
+		}
+		if ev.Error != "" {
+			fmt.Fprintf(w, "%s  step      %s %s%s: %s\n", at, ev.StepID, ev.Status, took, ev.Error)
+			return
+		}
+		fmt.Fprintf(w, "%s  step      %s %s%s\n", at, ev.StepID, ev.Status, took)
+	}
+}
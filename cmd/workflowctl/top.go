@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/yourusername/durable-execution-engine/engine"
+)
+
+// topRunningRows caps the running workflows table
+const topRunningRows = 15
+
+// topSample is the step activity of one refresh interval
+type topSample struct {
+	at                time.Time
+	completed, failed int
+}
+
+// topState carries throughput samples between refreshes
+type topState struct {
+	window  time.Duration
+	started time.Time
+	lastID  int64
+	samples []topSample
+}
+
+// runTop redraws a live view of the engine until interrupted
+func runTop(eng *engine.Engine, args []string) error {
+	fs := flag.NewFlagSet("top", flag.ExitOnError)
+	interval := fs.Duration("interval", 2*time.Second, "time between refreshes")
+	window := fs.Duration("window", time.Minute, "period step throughput and failure rate are averaged over")
+	fs.Parse(args)
+
+	start, err := eng.StepActivity(engine.EventsFromNow)
+	if err != nil {
+		return err
+	}
+	state := &topState{window: *window, started: time.Now(), lastID: start.LastEventID}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+
+	// Draw on the alternate screen so the shell is restored on exit
+	fmt.Print("\033[?1049h\033[?25l")
+	defer fmt.Print("\033[?25h\033[?1049l")
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		var buf bytes.Buffer
+		if err := state.render(&buf, eng, *interval); err != nil {
+			return err
+		}
+		fmt.Print("\033[H\033[2J")
+		os.Stdout.Write(buf.Bytes())
+
+		select {
+		case <-ticker.C:
+		case <-interrupt:
+			return nil
+		}
+	}
+}
+
+// render samples the engine once and writes one screen
+func (s *topState) render(w io.Writer, eng *engine.Engine, interval time.Duration) error {
+	now := time.Now()
+
+	activity, err := eng.StepActivity(s.lastID)
+	if err != nil {
+		return err
+	}
+	s.lastID = activity.LastEventID
+	s.samples = append(s.samples, topSample{at: now, completed: activity.Completed, failed: activity.Failed})
+	for len(s.samples) > 0 && now.Sub(s.samples[0].at) > s.window {
+		s.samples = s.samples[1:]
+	}
+
+	stats, err := eng.Stats()
+	if err != nil {
+		return err
+	}
+	queues, err := eng.QueueStats()
+	if err != nil {
+		return err
+	}
+	running, err := eng.ListWorkflows(engine.WorkflowFilter{Status: "running", Limit: topRunningRows})
+	if err != nil {
+		return err
+	}
+	workers, err := eng.ListWorkers()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "workflowctl top - %s (every %s, Ctrl-C to quit)\n\n", now.Format("15:04:05"), interval)
+
+	statuses := make([]string, 0, len(stats.ByStatus))
+	for status := range stats.ByStatus {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	fmt.Fprint(w, "Workflows:")
+	for _, status := range statuses {
+		fmt.Fprintf(w, "  %s %d", status, stats.ByStatus[status])
+	}
+	fmt.Fprintln(w)
+
+	alive := 0
+	for _, wk := range workers {
+		if wk.Alive(now) {
+			alive++
+		}
+	}
+	fmt.Fprintf(w, "Workers:    %d alive, %d registered\n", alive, len(workers))
+
+	completed, failed := 0, 0
+	for _, sample := range s.samples {
+		completed += sample.completed
+		failed += sample.failed
+	}
+	span := now.Sub(s.started)
+	if span > s.window {
+		span = s.window
+	}
+	failureRate := 0.0
+	if completed+failed > 0 {
+		failureRate = 100 * float64(failed) / float64(completed+failed)
+	}
+	throughput := 0.0
+	if span > 0 {
+		throughput = float64(completed+failed) / span.Seconds()
+	}
+	fmt.Fprintf(w, "Steps:      %.1f/s, %d completed, %d failed (%.1f%% failure rate) over the last %s\n\n",
+		throughput, completed, failed, failureRate, span.Round(time.Second))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "QUEUE\tQUEUED\tRUNNING\tOLDEST QUEUED")
+	for _, q := range queues {
+		oldest := "-"
+		if !q.OldestQueuedAt.IsZero() {
+			oldest = now.Sub(q.OldestQueuedAt).Round(time.Second).String()
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\n", q.Queue, q.Queued, q.Running, oldest)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\nRunning workflows (newest %d of %d)\n", len(running), stats.ByStatus["running"])
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "WORKFLOW\tNAME\tQUEUE\tAGE")
+	for _, run := range running {
+		name := run.Name
+		if name == "" {
+			name = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", run.WorkflowID, name, run.Queue, now.Sub(run.CreatedAt).Round(time.Second))
+	}
+	return tw.Flush()
+}
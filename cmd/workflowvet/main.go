@@ -0,0 +1,17 @@
+// workflowvet reports non-deterministic code in durable workflow functions.
+//
+// Run it as a vet tool, or directly on package patterns:
+//
+//	go vet -vettool=$(which workflowvet) ./...
+//	workflowvet ./...
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/singlechecker"
+
+	"github.com/yourusername/durable-execution-engine/analysis/determinism"
+)
+
+func main() {
+	singlechecker.Main(determinism.Analyzer)
+}
@@ -0,0 +1,322 @@
+// Package notify posts workflow outcomes to Slack, email and PagerDuty. A
+// Notifier is an engine.WorkflowHook configured from a Config, which can be
+// loaded from a JSON file:
+//
+//	n, err := notify.New(cfg)
+//	eng, err := engine.NewEngine(path, engine.WithWorkflowHook(n))
+//
+// Importing the package also registers it as the "notify" engine plugin,
+// configured with the same JSON and notified in the background instead of
+// before Execute returns:
+//
+//	eng, err := engine.NewEngine(path, engine.WithPlugin("notify", cfgJSON))
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/yourusername/durable-execution-engine/engine"
+)
+
+// DefaultPagerDutyURL is the PagerDuty Events API v2 endpoint
+const DefaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+
+// defaultTimeout bounds each delivery, since hooks delay the workflow's caller
+const defaultTimeout = 5 * time.Second
+
+// Config selects which runs are reported and where
+type Config struct {
+	On           []string      `json:"on"`            // statuses to report; default ["failed"]
+	Workflows    []string      `json:"workflows"`     // registered names to report; empty reports every run
+	DashboardURL string        `json:"dashboard_url"` // prefix of a link to the run, e.g. https://ops/runs/
+	Timeout      time.Duration `json:"timeout"`       // per delivery; default 5s
+
+	Slack     *SlackConfig     `json:"slack"`
+	Email     *EmailConfig     `json:"email"`
+	PagerDuty *PagerDutyConfig `json:"pagerduty"`
+}
+
+// SlackConfig posts to a Slack incoming webhook
+type SlackConfig struct {
+	WebhookURL string `json:"webhook_url"`
+}
+
+// EmailConfig sends mail through an SMTP server. Username and Password,
+// if set, are used for PLAIN authentication.
+type EmailConfig struct {
+	Addr     string   `json:"addr"` // host:port
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+}
+
+// PagerDutyConfig triggers PagerDuty incidents, one per run
+type PagerDutyConfig struct {
+	RoutingKey string `json:"routing_key"`
+	Severity   string `json:"severity"` // critical, error, warning or info; default error
+	URL        string `json:"url"`      // default DefaultPagerDutyURL
+}
+
+// LoadConfig reads a Config from a JSON file. Timeout is written as a
+// duration string, e.g. "10s".
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notify config: %w", err)
+	}
+	return parseConfig(data)
+}
+
+// parseConfig parses a JSON Config
+func parseConfig(data []byte) (*Config, error) {
+	var raw struct {
+		Config
+		Timeout string `json:"timeout"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse notify config: %w", err)
+	}
+	cfg := raw.Config
+	if raw.Timeout != "" {
+		var err error
+		if cfg.Timeout, err = time.ParseDuration(raw.Timeout); err != nil {
+			return nil, fmt.Errorf("failed to parse notify timeout: %w", err)
+		}
+	}
+	return &cfg, nil
+}
+
+// Notifier reports finished runs to the configured targets
+type Notifier struct {
+	cfg       Config
+	on        map[string]bool
+	workflows map[string]bool
+	http      *http.Client
+}
+
+// sendMail is replaced in tests
+var sendMail = smtp.SendMail
+
+// New validates a Config and creates a Notifier
+func New(cfg Config) (*Notifier, error) {
+	if cfg.Slack == nil && cfg.Email == nil && cfg.PagerDuty == nil {
+		return nil, fmt.Errorf("notify config has no slack, email or pagerduty target")
+	}
+	if cfg.Slack != nil && cfg.Slack.WebhookURL == "" {
+		return nil, fmt.Errorf("slack target needs webhook_url")
+	}
+	if cfg.Email != nil && (cfg.Email.Addr == "" || cfg.Email.From == "" || len(cfg.Email.To) == 0) {
+		return nil, fmt.Errorf("email target needs addr, from and to")
+	}
+	if cfg.PagerDuty != nil && cfg.PagerDuty.RoutingKey == "" {
+		return nil, fmt.Errorf("pagerduty target needs routing_key")
+	}
+	if len(cfg.On) == 0 {
+		cfg.On = []string{"failed"}
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+
+	n := &Notifier{
+		cfg:       cfg,
+		on:        make(map[string]bool),
+		workflows: make(map[string]bool),
+		http:      &http.Client{Timeout: cfg.Timeout},
+	}
+	for _, status := range cfg.On {
+		n.on[status] = true
+	}
+	for _, name := range cfg.Workflows {
+		n.workflows[name] = true
+	}
+	return n, nil
+}
+
+func init() {
+	engine.RegisterPlugin("notify", func(config json.RawMessage) (engine.Plugin, error) {
+		cfg, err := parseConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		return New(*cfg)
+	})
+}
+
+// Name identifies the notifier as an engine plugin
+func (n *Notifier) Name() string {
+	return "notify"
+}
+
+// HandleEvent notifies of runs that finished, as an engine plugin
+func (n *Notifier) HandleEvent(ev *engine.EngineEvent) {
+	var status string
+	switch ev.Type {
+	case engine.EventWorkflowCompleted, engine.EventWorkflowFailed, engine.EventWorkflowCanceled:
+		status = strings.TrimPrefix(string(ev.Type), "workflow.")
+	default:
+		return
+	}
+	n.WorkflowFinished(&engine.FinishedWorkflow{
+		WorkflowID: ev.WorkflowID,
+		Name:       ev.Name,
+		Status:     status,
+		Err:        ev.Err,
+		CreatedAt:  ev.Time.Add(-ev.Duration),
+		FinishedAt: ev.Time,
+	})
+}
+
+// WorkflowFinished sends a notification to every target if the run matches
+// the config. Delivery failures are logged, not retried.
+func (n *Notifier) WorkflowFinished(run *engine.FinishedWorkflow) {
+	if !n.on[run.Status] || (len(n.workflows) > 0 && !n.workflows[run.Name]) {
+		return
+	}
+
+	if n.cfg.Slack != nil {
+		if err := n.postSlack(run); err != nil {
+			fmt.Printf("[NOTIFY] slack notification for %s failed: %v\n", run.WorkflowID, err)
+		}
+	}
+	if n.cfg.Email != nil {
+		if err := n.sendEmail(run); err != nil {
+			fmt.Printf("[NOTIFY] email notification for %s failed: %v\n", run.WorkflowID, err)
+		}
+	}
+	if n.cfg.PagerDuty != nil {
+		if err := n.triggerPagerDuty(run); err != nil {
+			fmt.Printf("[NOTIFY] pagerduty notification for %s failed: %v\n", run.WorkflowID, err)
+		}
+	}
+}
+
+// summary is the one-line description of a run used by every target
+func (n *Notifier) summary(run *engine.FinishedWorkflow) string {
+	name := run.WorkflowID
+	if run.Name != "" {
+		name = fmt.Sprintf("%s (%s)", run.WorkflowID, run.Name)
+	}
+	line := fmt.Sprintf("Workflow %s %s", name, run.Status)
+	if run.Err != nil {
+		line += ": " + run.Err.Error()
+	}
+	return line
+}
+
+// link returns the dashboard URL of a run, if configured
+func (n *Notifier) link(run *engine.FinishedWorkflow) string {
+	if n.cfg.DashboardURL == "" {
+		return ""
+	}
+	return n.cfg.DashboardURL + run.WorkflowID
+}
+
+// postSlack posts the run to the Slack webhook
+func (n *Notifier) postSlack(run *engine.FinishedWorkflow) error {
+	text := n.summary(run)
+	if link := n.link(run); link != "" {
+		text += "\n" + link
+	}
+	return n.postJSON(n.cfg.Slack.WebhookURL, map[string]string{"text": text})
+}
+
+// sendEmail mails the run to the configured recipients
+func (n *Notifier) sendEmail(run *engine.FinishedWorkflow) error {
+	cfg := n.cfg.Email
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(&body, "To: %s\r\n", strings.Join(cfg.To, ", "))
+	fmt.Fprintf(&body, "Subject: %s\r\n", n.summary(run))
+	fmt.Fprintf(&body, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&body, "Workflow: %s\r\n", run.WorkflowID)
+	if run.Name != "" {
+		fmt.Fprintf(&body, "Name: %s\r\n", run.Name)
+	}
+	fmt.Fprintf(&body, "Status: %s\r\n", run.Status)
+	if run.Err != nil {
+		fmt.Fprintf(&body, "Error: %s\r\n", run.Err)
+	}
+	if !run.CreatedAt.IsZero() {
+		fmt.Fprintf(&body, "Started: %s\r\n", run.CreatedAt.Format(time.RFC3339))
+	}
+	fmt.Fprintf(&body, "Finished: %s\r\n", run.FinishedAt.Format(time.RFC3339))
+	if link := n.link(run); link != "" {
+		fmt.Fprintf(&body, "\r\n%s\r\n", link)
+	}
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		host := cfg.Addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, host)
+	}
+	if err := sendMail(cfg.Addr, auth, cfg.From, cfg.To, []byte(body.String())); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	return nil
+}
+
+// triggerPagerDuty opens an incident for the run, deduplicated by workflow ID
+func (n *Notifier) triggerPagerDuty(run *engine.FinishedWorkflow) error {
+	cfg := n.cfg.PagerDuty
+	url := cfg.URL
+	if url == "" {
+		url = DefaultPagerDutyURL
+	}
+	severity := cfg.Severity
+	if severity == "" {
+		severity = "error"
+	}
+
+	details := map[string]string{"workflow_id": run.WorkflowID, "status": run.Status}
+	if run.Name != "" {
+		details["name"] = run.Name
+	}
+	if run.Err != nil {
+		details["error"] = run.Err.Error()
+	}
+	event := map[string]interface{}{
+		"routing_key":  cfg.RoutingKey,
+		"event_action": "trigger",
+		"dedup_key":    "workflow/" + run.WorkflowID,
+		"payload": map[string]interface{}{
+			"summary":        n.summary(run),
+			"source":         run.WorkflowID,
+			"severity":       severity,
+			"timestamp":      run.FinishedAt.UTC().Format(time.RFC3339),
+			"custom_details": details,
+		},
+	}
+	if link := n.link(run); link != "" {
+		event["links"] = []map[string]string{{"href": link, "text": "Workflow run"}}
+	}
+	return n.postJSON(url, event)
+}
+
+// postJSON posts a JSON body and checks for a 2xx response
+func (n *Notifier) postJSON(url string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+	resp, err := n.http.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to post notification: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("notification rejected: %s", resp.Status)
+	}
+	return nil
+}
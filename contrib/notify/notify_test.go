@@ -0,0 +1,136 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yourusername/durable-execution-engine/engine"
+)
+
+func TestNotifierReportsFailedRuns(t *testing.T) {
+	dbPath := "./test_notify.db"
+	defer os.Remove(dbPath)
+
+	var mu sync.Mutex
+	var slack []map[string]string
+	var pagerduty []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/slack":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			slack = append(slack, body)
+		case "/pagerduty":
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			pagerduty = append(pagerduty, body)
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	defer srv.Close()
+
+	var mails []string
+	sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		mails = append(mails, string(msg))
+		return nil
+	}
+	defer func() { sendMail = smtp.SendMail }()
+
+	n, err := New(Config{
+		DashboardURL: "https://ops.example.com/runs/",
+		Slack:        &SlackConfig{WebhookURL: srv.URL + "/slack"},
+		Email:        &EmailConfig{Addr: "localhost:25", From: "engine@example.com", To: []string{"oncall@example.com"}},
+		PagerDuty:    &PagerDutyConfig{RoutingKey: "key", URL: srv.URL + "/pagerduty"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create notifier: %v", err)
+	}
+
+	eng, err := engine.NewEngine(dbPath, engine.WithWorkflowHook(n))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	eng.Execute("notify-ok", func(ctx *engine.Context) error { return nil })
+	eng.Execute("notify-broken", func(ctx *engine.Context) error { return errors.New("disk full") })
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(slack) != 1 || !strings.Contains(slack[0]["text"], "notify-broken failed") ||
+		!strings.Contains(slack[0]["text"], "https://ops.example.com/runs/notify-broken") {
+		t.Errorf("expected one slack message for the failed run, got %v", slack)
+	}
+	if len(mails) != 1 || !strings.Contains(mails[0], "To: oncall@example.com") || !strings.Contains(mails[0], "disk full") {
+		t.Errorf("expected one mail for the failed run, got %v", mails)
+	}
+	if len(pagerduty) != 1 || pagerduty[0]["dedup_key"] != "workflow/notify-broken" || pagerduty[0]["routing_key"] != "key" {
+		t.Errorf("expected one pagerduty event for the failed run, got %v", pagerduty)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := "./test_notify.json"
+	defer os.Remove(path)
+	os.WriteFile(path, []byte(`{
+		"on": ["failed", "canceled"],
+		"workflows": ["billing"],
+		"timeout": "2s",
+		"slack": {"webhook_url": "https://hooks.slack.com/services/x"}
+	}`), 0o600)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if len(cfg.On) != 2 || cfg.Workflows[0] != "billing" || cfg.Timeout.Seconds() != 2 || cfg.Slack == nil {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	if _, err := New(Config{}); err == nil {
+		t.Error("expected a config without targets to be rejected")
+	}
+	if _, err := New(Config{PagerDuty: &PagerDutyConfig{}}); err == nil {
+		t.Error("expected a pagerduty target without routing key to be rejected")
+	}
+}
+
+func TestNotifyPlugin(t *testing.T) {
+	dbPath := "./test_notify_plugin.db"
+	defer os.Remove(dbPath)
+
+	var mu sync.Mutex
+	var texts []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		texts = append(texts, body["text"])
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	cfg := `{"on": ["failed"], "timeout": "2s", "slack": {"webhook_url": "` + srv.URL + `"}}`
+	eng, err := engine.NewEngine(dbPath, engine.WithPlugin("notify", json.RawMessage(cfg)))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	eng.Execute("plugin-ok", func(ctx *engine.Context) error { return nil })
+	eng.Execute("plugin-broken", func(ctx *engine.Context) error { return errors.New("disk full") })
+	// Closing delivers the queued events
+	eng.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(texts) != 1 || !strings.Contains(texts[0], "plugin-broken failed: disk full") {
+		t.Errorf("expected one slack message for the failed run, got %v", texts)
+	}
+}
@@ -0,0 +1,81 @@
+package steps
+
+import (
+	"fmt"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/yourusername/durable-execution-engine/engine"
+)
+
+// SMTPConfig is an SMTP server to send mail through. Username and Password,
+// if set, are used for PLAIN authentication.
+type SMTPConfig struct {
+	Addr     string `json:"addr"` // host:port
+	From     string `json:"from"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Email is a plain-text or HTML message
+type Email struct {
+	To      []string
+	Cc      []string
+	Subject string
+	Body    string
+	HTML    bool // send Body as text/html
+}
+
+// EmailReceipt is the recorded output of SendEmail
+type EmailReceipt struct {
+	MessageID string    `json:"message_id"`
+	SentAt    time.Time `json:"sent_at"`
+}
+
+// sendMail is replaced in tests
+var sendMail = smtp.SendMail
+
+// SendEmail sends msg as a durable step. Its Message-ID is derived from the
+// step's idempotency key, so mail clients and servers that deduplicate by
+// Message-ID show a retried attempt's copy once.
+func SendEmail(ctx *engine.Context, id string, cfg SMTPConfig, msg Email, opts ...engine.StepOption) (EmailReceipt, error) {
+	return engine.StepWithInfo(ctx, id, func(info engine.StepInfo) (EmailReceipt, error) {
+		if cfg.Addr == "" || cfg.From == "" || len(msg.To) == 0 {
+			return EmailReceipt{}, fmt.Errorf("email needs an SMTP addr, from and to")
+		}
+
+		host := cfg.Addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		messageID := fmt.Sprintf("<%s@%s>", info.IdempotencyKey(), host)
+
+		contentType := "text/plain"
+		if msg.HTML {
+			contentType = "text/html"
+		}
+		var body strings.Builder
+		fmt.Fprintf(&body, "From: %s\r\n", cfg.From)
+		fmt.Fprintf(&body, "To: %s\r\n", strings.Join(msg.To, ", "))
+		if len(msg.Cc) > 0 {
+			fmt.Fprintf(&body, "Cc: %s\r\n", strings.Join(msg.Cc, ", "))
+		}
+		fmt.Fprintf(&body, "Subject: %s\r\n", msg.Subject)
+		fmt.Fprintf(&body, "Message-ID: %s\r\n", messageID)
+		fmt.Fprintf(&body, "%s: %s\r\n", engine.TraceParentHeader, info.TraceParent())
+		fmt.Fprintf(&body, "MIME-Version: 1.0\r\n")
+		fmt.Fprintf(&body, "Content-Type: %s; charset=utf-8\r\n\r\n", contentType)
+		body.WriteString(msg.Body)
+
+		var auth smtp.Auth
+		if cfg.Username != "" {
+			auth = smtp.PlainAuth("", cfg.Username, cfg.Password, host)
+		}
+		recipients := append(append([]string(nil), msg.To...), msg.Cc...)
+		if err := sendMail(cfg.Addr, auth, cfg.From, recipients, []byte(body.String())); err != nil {
+			return EmailReceipt{}, fmt.Errorf("failed to send mail: %w", err)
+		}
+		return EmailReceipt{MessageID: messageID, SentAt: time.Now().UTC()}, nil
+	}, stepOptions("email", opts)...)
+}
@@ -0,0 +1,295 @@
+package steps
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GCSEndpoint is the S3-compatible XML API of Google Cloud Storage
+const GCSEndpoint = "https://storage.googleapis.com"
+
+// unsignedPayload marks request bodies that are not part of the signature
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3Config is an S3-compatible object store account
+type S3Config struct {
+	Endpoint        string        `json:"endpoint"` // default https://s3.<region>.amazonaws.com
+	Region          string        `json:"region"`
+	AccessKeyID     string        `json:"access_key_id"`
+	SecretAccessKey string        `json:"secret_access_key"`
+	SessionToken    string        `json:"session_token"`
+	Timeout         time.Duration `json:"timeout"` // per request; default 10s
+}
+
+// S3Store is an ObjectStore speaking the S3 REST API with path-style
+// addressing, signed with AWS Signature Version 4
+type S3Store struct {
+	cfg    S3Config
+	client *http.Client
+}
+
+// NewS3Store creates an S3Store
+func NewS3Store(cfg S3Config) *S3Store {
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+	cfg.Endpoint = strings.TrimSuffix(cfg.Endpoint, "/")
+	return &S3Store{cfg: cfg, client: httpClient(cfg.Timeout)}
+}
+
+// NewGCSStore creates an S3Store for Google Cloud Storage, authenticated
+// with an HMAC key of a service account
+func NewGCSStore(accessKeyID, secret string) *S3Store {
+	return NewS3Store(S3Config{Endpoint: GCSEndpoint, Region: "auto", AccessKeyID: accessKeyID, SecretAccessKey: secret})
+}
+
+// CreateUpload starts a multipart upload
+func (s *S3Store) CreateUpload(ctx context.Context, bucket, key string) (string, error) {
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	resp, err := s.do(ctx, http.MethodPost, bucket, key, url.Values{"uploads": {""}}, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode upload: %w", err)
+	}
+	return result.UploadID, nil
+}
+
+// UploadPart uploads part number of an upload
+func (s *S3Store) UploadPart(ctx context.Context, bucket, key, uploadID string, number int, data []byte) (string, error) {
+	query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+	resp, err := s.do(ctx, http.MethodPut, bucket, key, query, nil, data)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Header.Get("ETag"), nil
+}
+
+// CompleteUpload assembles the uploaded parts into the object
+func (s *S3Store) CompleteUpload(ctx context.Context, bucket, key, uploadID string, parts []Part) (string, error) {
+	type xmlPart struct {
+		PartNumber int
+		ETag       string
+	}
+	body := struct {
+		XMLName xml.Name  `xml:"CompleteMultipartUpload"`
+		Parts   []xmlPart `xml:"Part"`
+	}{}
+	for _, p := range parts {
+		body.Parts = append(body.Parts, xmlPart{PartNumber: p.Number, ETag: p.ETag})
+	}
+	data, err := xml.Marshal(body)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal parts: %w", err)
+	}
+	resp, err := s.do(ctx, http.MethodPost, bucket, key, url.Values{"uploadId": {uploadID}}, nil, data)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// S3 may report a failure in the body of a 200 response
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+	if err := s3Error(raw); err != nil {
+		return "", err
+	}
+	var result struct {
+		ETag string `xml:"ETag"`
+	}
+	if err := xml.Unmarshal(raw, &result); err != nil {
+		return "", fmt.Errorf("failed to decode completed upload: %w", err)
+	}
+	return result.ETag, nil
+}
+
+// Stat returns the size and ETag of an object
+func (s *S3Store) Stat(ctx context.Context, bucket, key string) (ObjectInfo, error) {
+	resp, err := s.do(ctx, http.MethodHead, bucket, key, nil, nil, nil)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	resp.Body.Close()
+	return ObjectInfo{Size: resp.ContentLength, ETag: resp.Header.Get("ETag")}, nil
+}
+
+// ReadRange reads part of an object
+func (s *S3Store) ReadRange(ctx context.Context, bucket, key, etag string, offset, length int64) (io.ReadCloser, error) {
+	header := http.Header{"Range": {fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)}}
+	if etag != "" {
+		header.Set("If-Match", etag)
+	}
+	resp, err := s.do(ctx, http.MethodGet, bucket, key, nil, header, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// do sends a signed request and returns the response if it is a 2xx
+func (s *S3Store) do(ctx context.Context, method, bucket, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	u, err := url.Parse(s.cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint %q: %w", s.cfg.Endpoint, err)
+	}
+	u.RawPath = u.Path + "/" + s3Escape(bucket, false) + "/" + s3Escape(key, false)
+	u.Path = u.Path + "/" + bucket + "/" + key
+	u.RawQuery = canonicalQuery(query)
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if s.cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.cfg.SessionToken)
+	}
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+	s.sign(req, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if err := s3Error(raw); err != nil {
+			return nil, fmt.Errorf("%s %s/%s: %w", method, bucket, key, err)
+		}
+		return nil, fmt.Errorf("%s %s/%s: status %d", method, bucket, key, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// s3Error returns the error in an S3 error document, if raw is one
+func s3Error(raw []byte) error {
+	var doc struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(raw, &doc) != nil || doc.XMLName.Local != "Error" {
+		return nil
+	}
+	if doc.Code == "NoSuchUpload" {
+		return fmt.Errorf("%s: %w", doc.Message, ErrUploadNotFound)
+	}
+	return fmt.Errorf("%s: %s", doc.Code, doc.Message)
+}
+
+// sign adds a Signature Version 4 Authorization header to req, covering the
+// host, Range and X-Amz-* headers. The payload hash is taken from the
+// X-Amz-Content-Sha256 header.
+func (s *S3Store) sign(req *http.Request, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		name := strings.ToLower(k)
+		if name == "range" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		req.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretAccessKey), date)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery encodes query sorted by key, escaped the way SigV4 expects
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			pairs = append(pairs, s3Escape(k, true)+"="+s3Escape(v, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// s3Escape percent-encodes everything but unreserved characters, and '/'
+// unless slash is set
+func s3Escape(s string, slash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !slash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,79 @@
+package steps
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/yourusername/durable-execution-engine/engine"
+)
+
+// DefaultSlackURL is the Slack chat.postMessage endpoint
+const DefaultSlackURL = "https://slack.com/api/chat.postMessage"
+
+// SlackConfig posts either with a bot token through chat.postMessage, which
+// returns the message's timestamp, or to an incoming webhook
+type SlackConfig struct {
+	Token      string        `json:"token"`       // bot token, used unless WebhookURL is set
+	WebhookURL string        `json:"webhook_url"` // posts to the webhook's channel
+	APIURL     string        `json:"api_url"`     // default DefaultSlackURL
+	Timeout    time.Duration `json:"timeout"`     // per attempt; default 10s
+}
+
+// SlackMessage is a message to post. Channel is ignored by webhooks.
+type SlackMessage struct {
+	Channel  string
+	Text     string
+	ThreadTS string // reply in this thread
+}
+
+// SlackReceipt is the recorded output of PostSlack. Channel and TS are only
+// set for messages posted with a token, and identify the message for replies
+// and updates.
+type SlackReceipt struct {
+	Channel string `json:"channel,omitempty"`
+	TS      string `json:"ts,omitempty"`
+}
+
+// PostSlack posts msg to Slack as a durable step
+func PostSlack(ctx *engine.Context, id string, cfg SlackConfig, msg SlackMessage, opts ...engine.StepOption) (SlackReceipt, error) {
+	return engine.StepWithInfo(ctx, id, func(info engine.StepInfo) (SlackReceipt, error) {
+		client := httpClient(cfg.Timeout)
+		body := map[string]string{"text": msg.Text}
+		if msg.ThreadTS != "" {
+			body["thread_ts"] = msg.ThreadTS
+		}
+
+		if cfg.WebhookURL != "" {
+			// Webhooks answer with plain "ok"
+			if err := postJSON(ctx, info, client, cfg.WebhookURL, nil, body, nil); err != nil {
+				return SlackReceipt{}, fmt.Errorf("failed to post slack message: %w", err)
+			}
+			return SlackReceipt{}, nil
+		}
+
+		if cfg.Token == "" || msg.Channel == "" {
+			return SlackReceipt{}, fmt.Errorf("slack message needs a webhook URL, or a token and channel")
+		}
+		url := cfg.APIURL
+		if url == "" {
+			url = DefaultSlackURL
+		}
+		body["channel"] = msg.Channel
+		header := http.Header{"Authorization": {"Bearer " + cfg.Token}}
+
+		var resp struct {
+			OK      bool   `json:"ok"`
+			Error   string `json:"error"`
+			Channel string `json:"channel"`
+			TS      string `json:"ts"`
+		}
+		if err := postJSON(ctx, info, client, url, header, body, &resp); err != nil {
+			return SlackReceipt{}, fmt.Errorf("failed to post slack message: %w", err)
+		}
+		if !resp.OK {
+			return SlackReceipt{}, fmt.Errorf("slack rejected message: %s", resp.Error)
+		}
+		return SlackReceipt{Channel: resp.Channel, TS: resp.TS}, nil
+	}, stepOptions("slack", opts)...)
+}
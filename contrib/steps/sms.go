@@ -0,0 +1,68 @@
+package steps
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/yourusername/durable-execution-engine/engine"
+)
+
+// DefaultTwilioURL is the Twilio REST API base URL
+const DefaultTwilioURL = "https://api.twilio.com"
+
+// TwilioConfig is a Twilio account to send SMS from
+type TwilioConfig struct {
+	AccountSID string        `json:"account_sid"`
+	AuthToken  string        `json:"auth_token"`
+	From       string        `json:"from"`     // sender number or messaging service SID
+	BaseURL    string        `json:"base_url"` // default DefaultTwilioURL
+	Timeout    time.Duration `json:"timeout"`  // per attempt; default 10s
+}
+
+// SMS is a text message
+type SMS struct {
+	To   string
+	Body string
+}
+
+// SMSReceipt is the recorded output of SendSMS
+type SMSReceipt struct {
+	SID    string `json:"sid"`
+	Status string `json:"status"` // as first reported by Twilio, e.g. queued
+}
+
+// SendSMS sends msg through Twilio as a durable step
+func SendSMS(ctx *engine.Context, id string, cfg TwilioConfig, msg SMS, opts ...engine.StepOption) (SMSReceipt, error) {
+	return engine.StepWithInfo(ctx, id, func(info engine.StepInfo) (SMSReceipt, error) {
+		if cfg.AccountSID == "" || cfg.AuthToken == "" || cfg.From == "" || msg.To == "" {
+			return SMSReceipt{}, fmt.Errorf("sms needs a Twilio account SID, auth token, from and to")
+		}
+		base := cfg.BaseURL
+		if base == "" {
+			base = DefaultTwilioURL
+		}
+
+		form := url.Values{"To": {msg.To}, "Body": {msg.Body}}
+		if strings.HasPrefix(cfg.From, "MG") {
+			form.Set("MessagingServiceSid", cfg.From)
+		} else {
+			form.Set("From", cfg.From)
+		}
+		endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", strings.TrimSuffix(base, "/"), url.PathEscape(cfg.AccountSID))
+		req, err := http.NewRequestWithContext(ctx.Context(), http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+		if err != nil {
+			return SMSReceipt{}, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.SetBasicAuth(cfg.AccountSID, cfg.AuthToken)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		var receipt SMSReceipt
+		if err := do(httpClient(cfg.Timeout), info, req, &receipt); err != nil {
+			return SMSReceipt{}, fmt.Errorf("failed to send sms: %w", err)
+		}
+		return receipt, nil
+	}, stepOptions("sms", opts)...)
+}
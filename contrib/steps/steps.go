@@ -0,0 +1,105 @@
+// Package steps provides ready-made durable steps for the integrations most
+// workflows need: SMTP email, Twilio SMS, Slack messages and S3 or GCS file
+// transfers. Each is one engine step that retries with DefaultRetry, sends
+// the step's idempotency key with the request and records the provider's
+// receipt as its output, so a resumed workflow gets the receipt back instead
+// of sending again:
+//
+//	receipt, err := steps.SendEmail(ctx, "welcome-email", smtpCfg, steps.Email{
+//		To: []string{user.Email}, Subject: "Welcome", Body: text,
+//	})
+//
+// A completed step never sends again. An attempt that fails after the
+// provider accepted the message may be retried, and is only deduplicated
+// where the provider honours the idempotency key, so keep DefaultRetry's
+// attempts low for messages that must not be duplicated.
+package steps
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/yourusername/durable-execution-engine/engine"
+)
+
+// DefaultRetry is the retry policy of every step in this package. Options
+// passed to a step are applied after it, so WithRetry overrides it.
+var DefaultRetry = engine.RetryPolicy{
+	MaxAttempts:     4,
+	InitialInterval: time.Second,
+	MaxInterval:     30 * time.Second,
+	Multiplier:      2,
+	Jitter:          0.2,
+}
+
+// IdempotencyKeyHeader carries the step's idempotency key on HTTP requests
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// defaultTimeout bounds each HTTP attempt
+const defaultTimeout = 10 * time.Second
+
+// stepOptions puts the package defaults before the caller's options
+func stepOptions(tag string, opts []engine.StepOption) []engine.StepOption {
+	return append([]engine.StepOption{
+		engine.WithRetry(DefaultRetry),
+		engine.WithTags("external-api", tag),
+	}, opts...)
+}
+
+// postJSON posts body with the step's trace and idempotency headers and
+// decodes a 2xx JSON response into out
+func postJSON(ctx *engine.Context, info engine.StepInfo, client *http.Client, url string, header http.Header, body, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx.Context(), http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return do(client, info, req, out)
+}
+
+// do sends req with the step's trace and idempotency headers and decodes a
+// 2xx JSON response into out
+func do(client *http.Client, info engine.StepInfo, req *http.Request, out interface{}) error {
+	info.InjectTraceHeaders(req.Header)
+	req.Header.Set(IdempotencyKeyHeader, info.IdempotencyKey())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("request rejected: %s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// httpClient returns a client with the given timeout, or the default one
+func httpClient(timeout time.Duration) *http.Client {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &http.Client{Timeout: timeout}
+}
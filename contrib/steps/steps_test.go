@@ -0,0 +1,129 @@
+package steps
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yourusername/durable-execution-engine/engine"
+)
+
+func TestStepsSendOnceAndRecordReceipts(t *testing.T) {
+	dbPath := "./test_steps.db"
+	defer os.Remove(dbPath)
+
+	var mu sync.Mutex
+	var smsForms []string
+	var idempotencyKeys []string
+	slackCalls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		idempotencyKeys = append(idempotencyKeys, r.Header.Get(IdempotencyKeyHeader))
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/Accounts/AC1/Messages.json"):
+			if user, pass, _ := r.BasicAuth(); user != "AC1" || pass != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			r.ParseForm()
+			smsForms = append(smsForms, r.PostForm.Encode())
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"sid": "SM123", "status": "queued"}`))
+		case r.URL.Path == "/slack":
+			slackCalls++
+			if slackCalls == 1 {
+				// The first attempt fails and is retried
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			if r.Header.Get("Authorization") != "Bearer xoxb" || body["channel"] != "#onboarding" {
+				w.Write([]byte(`{"ok": false, "error": "not_authed"}`))
+				return
+			}
+			w.Write([]byte(`{"ok": true, "channel": "C1", "ts": "1700000000.000100"}`))
+		}
+	}))
+	defer srv.Close()
+
+	var mails []string
+	sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		mails = append(mails, string(msg))
+		return nil
+	}
+	defer func() { sendMail = smtp.SendMail }()
+
+	eng, err := engine.NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	fast := engine.WithRetry(engine.RetryPolicy{MaxAttempts: 3, InitialInterval: time.Millisecond})
+	var email EmailReceipt
+	var sms SMSReceipt
+	var slack SlackReceipt
+	crash := true
+	onboard := func(ctx *engine.Context) error {
+		var err error
+		if email, err = SendEmail(ctx, "welcome-email", SMTPConfig{Addr: "mail.example.com:587", From: "hr@example.com"},
+			Email{To: []string{"ada@example.com"}, Subject: "Welcome", Body: "Hi Ada"}); err != nil {
+			return err
+		}
+		if sms, err = SendSMS(ctx, "welcome-sms", TwilioConfig{AccountSID: "AC1", AuthToken: "secret", From: "+15550000", BaseURL: srv.URL},
+			SMS{To: "+15551234", Body: "Welcome!"}); err != nil {
+			return err
+		}
+		slack, err = PostSlack(ctx, "announce", SlackConfig{Token: "xoxb", APIURL: srv.URL + "/slack"},
+			SlackMessage{Channel: "#onboarding", Text: "Ada joined"}, fast)
+		if err == nil && crash {
+			return errors.New("worker crashed")
+		}
+		return err
+	}
+
+	if err := eng.Execute("onboard-ada", onboard); err == nil {
+		t.Fatal("expected the first execution to crash")
+	}
+	if len(mails) != 1 || !strings.Contains(mails[0], "Subject: Welcome") || !strings.Contains(mails[0], "Message-ID: "+email.MessageID) {
+		t.Errorf("unexpected mail: %v", mails)
+	}
+	if sms.SID != "SM123" || len(smsForms) != 1 || !strings.Contains(smsForms[0], "From=%2B15550000") {
+		t.Errorf("unexpected sms %+v, forms %v", sms, smsForms)
+	}
+	if slack.TS != "1700000000.000100" || slackCalls != 2 {
+		t.Errorf("expected slack to be retried once, got %+v after %d calls", slack, slackCalls)
+	}
+	if idempotencyKeys[1] != idempotencyKeys[2] || idempotencyKeys[1] == "" {
+		// Slack is the second and third request; both attempts share the step's key
+		t.Errorf("expected retried attempts to share an idempotency key, got %v", idempotencyKeys)
+	}
+
+	// A resumed run gets the recorded receipts without sending again
+	email, sms, slack = EmailReceipt{}, SMSReceipt{}, SlackReceipt{}
+	crash = false
+	if err := eng.Execute("onboard-ada", onboard); err != nil {
+		t.Fatalf("resumed run failed: %v", err)
+	}
+	if sms.SID != "SM123" || slack.TS == "" || email.MessageID == "" {
+		t.Errorf("expected recorded receipts, got %+v %+v %+v", email, sms, slack)
+	}
+	history, _ := eng.GetHistory("onboard-ada")
+	for _, step := range history {
+		if step.Status != "completed" {
+			t.Errorf("expected %s to be completed, got %s", step.StepID, step.Status)
+		}
+	}
+	if len(mails) != 1 || len(smsForms) != 1 || slackCalls != 2 {
+		t.Errorf("expected nothing to be sent again, got %d mails, %d sms, %d slack calls", len(mails), len(smsForms), slackCalls)
+	}
+}
@@ -0,0 +1,247 @@
+package steps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/yourusername/durable-execution-engine/engine"
+)
+
+// DefaultPartSize is the part size of transfers that don't set one. S3
+// requires every part but the last to be at least 5 MiB.
+const DefaultPartSize = 8 << 20
+
+// ErrUploadNotFound is returned by an ObjectStore when a multipart upload
+// has expired or been aborted; UploadFile then starts a new one
+var ErrUploadNotFound = errors.New("multipart upload not found")
+
+// ObjectStore is a bucket store with multipart uploads and ranged reads,
+// such as S3 or GCS (see S3Store)
+type ObjectStore interface {
+	CreateUpload(ctx context.Context, bucket, key string) (uploadID string, err error)
+	UploadPart(ctx context.Context, bucket, key, uploadID string, number int, data []byte) (etag string, err error)
+	CompleteUpload(ctx context.Context, bucket, key, uploadID string, parts []Part) (etag string, err error)
+	Stat(ctx context.Context, bucket, key string) (ObjectInfo, error)
+	// ReadRange reads length bytes from offset, failing if the object's
+	// ETag is no longer etag
+	ReadRange(ctx context.Context, bucket, key, etag string, offset, length int64) (io.ReadCloser, error)
+}
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Size int64
+	ETag string
+}
+
+// Part is an uploaded part of a multipart upload
+type Part struct {
+	Number int    `json:"number"`
+	ETag   string `json:"etag"`
+	Size   int64  `json:"size"`
+}
+
+// Upload copies a local file to Bucket/Key
+type Upload struct {
+	Path     string
+	Bucket   string
+	Key      string
+	PartSize int // default DefaultPartSize
+}
+
+// UploadReceipt is the recorded output of UploadFile
+type UploadReceipt struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+	Size   int64  `json:"size"`
+	Parts  int    `json:"parts"`
+	ETag   string `json:"etag"`
+}
+
+// uploadProgress is the checkpoint of an upload
+type uploadProgress struct {
+	UploadID string `json:"upload_id"`
+	Parts    []Part `json:"parts"`
+}
+
+// UploadFile uploads a file in parts as a durable step. Each uploaded part
+// is checkpointed, so a retry or a resumed run continues after the last part
+// that was stored instead of uploading the whole file again.
+func UploadFile(ctx *engine.Context, id string, store ObjectStore, u Upload, opts ...engine.StepOption) (UploadReceipt, error) {
+	return engine.Step(ctx, id, func() (UploadReceipt, error) {
+		partSize := u.PartSize
+		if partSize <= 0 {
+			partSize = DefaultPartSize
+		}
+		c := ctx.Context()
+
+		var progress uploadProgress
+		if _, err := ctx.LastCheckpoint(id, &progress); err != nil {
+			return UploadReceipt{}, err
+		}
+
+		f, err := os.Open(u.Path)
+		if err != nil {
+			return UploadReceipt{}, fmt.Errorf("failed to open %s: %w", u.Path, err)
+		}
+		defer f.Close()
+
+		for {
+			if progress.UploadID == "" {
+				if progress.UploadID, err = store.CreateUpload(c, u.Bucket, u.Key); err != nil {
+					return UploadReceipt{}, fmt.Errorf("failed to start upload: %w", err)
+				}
+				progress.Parts = nil
+				if err := ctx.Checkpoint(id, progress); err != nil {
+					return UploadReceipt{}, err
+				}
+			}
+			err = uploadParts(ctx, id, store, u, f, partSize, &progress)
+			if !errors.Is(err, ErrUploadNotFound) {
+				break
+			}
+			// The store dropped the upload; start over
+			ctx.Logf("upload %s expired, restarting", progress.UploadID)
+			progress.UploadID = ""
+		}
+		if err != nil {
+			return UploadReceipt{}, err
+		}
+
+		etag, err := store.CompleteUpload(c, u.Bucket, u.Key, progress.UploadID, progress.Parts)
+		if err != nil {
+			return UploadReceipt{}, fmt.Errorf("failed to complete upload: %w", err)
+		}
+		receipt := UploadReceipt{Bucket: u.Bucket, Key: u.Key, Parts: len(progress.Parts), ETag: etag}
+		for _, p := range progress.Parts {
+			receipt.Size += p.Size
+		}
+		return receipt, nil
+	}, stepOptions("storage", opts)...)
+}
+
+// uploadParts uploads the parts of f after the checkpointed ones
+func uploadParts(ctx *engine.Context, id string, store ObjectStore, u Upload, f *os.File, partSize int, progress *uploadProgress) error {
+	var offset int64
+	for _, p := range progress.Parts {
+		offset += p.Size
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek %s: %w", u.Path, err)
+	}
+
+	buf := make([]byte, partSize)
+	for {
+		n, err := io.ReadFull(f, buf)
+		if err == io.EOF && len(progress.Parts) > 0 {
+			return nil
+		}
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return fmt.Errorf("failed to read %s: %w", u.Path, err)
+		}
+
+		number := len(progress.Parts) + 1
+		etag, upErr := store.UploadPart(ctx.Context(), u.Bucket, u.Key, progress.UploadID, number, buf[:n])
+		if upErr != nil {
+			return fmt.Errorf("failed to upload part %d: %w", number, upErr)
+		}
+		progress.Parts = append(progress.Parts, Part{Number: number, ETag: etag, Size: int64(n)})
+		if err := ctx.Checkpoint(id, *progress); err != nil {
+			return err
+		}
+		if n < partSize {
+			return nil
+		}
+	}
+}
+
+// Download copies Bucket/Key to a local file
+type Download struct {
+	Bucket   string
+	Key      string
+	Path     string
+	PartSize int // bytes read per request; default DefaultPartSize
+}
+
+// DownloadReceipt is the recorded output of DownloadFile
+type DownloadReceipt struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	ETag string `json:"etag"`
+}
+
+// downloadProgress is the checkpoint of a download
+type downloadProgress struct {
+	ETag   string `json:"etag"`
+	Offset int64  `json:"offset"`
+}
+
+// DownloadFile downloads an object in ranges as a durable step. The file is
+// written to Path+".partial" and renamed once complete; the offset reached
+// is checkpointed after each range, so an interrupted download resumes
+// there unless the object changed in the meantime.
+func DownloadFile(ctx *engine.Context, id string, store ObjectStore, d Download, opts ...engine.StepOption) (DownloadReceipt, error) {
+	return engine.Step(ctx, id, func() (DownloadReceipt, error) {
+		partSize := int64(d.PartSize)
+		if partSize <= 0 {
+			partSize = DefaultPartSize
+		}
+		c := ctx.Context()
+
+		info, err := store.Stat(c, d.Bucket, d.Key)
+		if err != nil {
+			return DownloadReceipt{}, fmt.Errorf("failed to stat %s/%s: %w", d.Bucket, d.Key, err)
+		}
+		var progress downloadProgress
+		if _, err := ctx.LastCheckpoint(id, &progress); err != nil {
+			return DownloadReceipt{}, err
+		}
+		if progress.ETag != info.ETag {
+			progress = downloadProgress{ETag: info.ETag}
+		}
+
+		partial := d.Path + ".partial"
+		f, err := os.OpenFile(partial, os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return DownloadReceipt{}, fmt.Errorf("failed to open %s: %w", partial, err)
+		}
+		defer f.Close()
+		// Drop anything written after the last checkpoint
+		if err := f.Truncate(progress.Offset); err != nil {
+			return DownloadReceipt{}, fmt.Errorf("failed to truncate %s: %w", partial, err)
+		}
+
+		for progress.Offset < info.Size {
+			length := min(partSize, info.Size-progress.Offset)
+			r, err := store.ReadRange(c, d.Bucket, d.Key, info.ETag, progress.Offset, length)
+			if err != nil {
+				return DownloadReceipt{}, fmt.Errorf("failed to read %s/%s at %d: %w", d.Bucket, d.Key, progress.Offset, err)
+			}
+			n, err := io.Copy(io.NewOffsetWriter(f, progress.Offset), io.LimitReader(r, length))
+			r.Close()
+			if err == nil && n != length {
+				err = io.ErrUnexpectedEOF
+			}
+			if err != nil {
+				return DownloadReceipt{}, fmt.Errorf("failed to download %s/%s at %d: %w", d.Bucket, d.Key, progress.Offset, err)
+			}
+			if err := f.Sync(); err != nil {
+				return DownloadReceipt{}, fmt.Errorf("failed to sync %s: %w", partial, err)
+			}
+			progress.Offset += n
+			if err := ctx.Checkpoint(id, progress); err != nil {
+				return DownloadReceipt{}, err
+			}
+		}
+
+		if err := f.Close(); err != nil {
+			return DownloadReceipt{}, fmt.Errorf("failed to close %s: %w", partial, err)
+		}
+		if err := os.Rename(partial, d.Path); err != nil {
+			return DownloadReceipt{}, fmt.Errorf("failed to rename %s: %w", partial, err)
+		}
+		return DownloadReceipt{Path: d.Path, Size: info.Size, ETag: info.ETag}, nil
+	}, stepOptions("storage", opts)...)
+}
@@ -0,0 +1,22 @@
+// Package dashboard serves workflow history over GraphQL for the dashboard
+package dashboard
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+
+	"github.com/yourusername/durable-execution-engine/engine"
+)
+
+// NewHandler returns an http.Handler answering GraphQL queries posted as
+// JSON ({"query": ..., "variables": ...}) against the engine's database
+func NewHandler(eng *engine.Engine) (http.Handler, error) {
+	s, err := graphql.ParseSchema(schema, &queryResolver{eng: eng}, graphql.UseFieldResolvers())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse dashboard schema: %w", err)
+	}
+	return &relay.Handler{Schema: s}, nil
+}
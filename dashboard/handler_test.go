@@ -0,0 +1,251 @@
+package dashboard
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/yourusername/durable-execution-engine/engine"
+)
+
+func TestDashboardQueryBatchesNestedFields(t *testing.T) {
+	dbPath := "./test_dashboard.db"
+	defer os.Remove(dbPath)
+
+	eng, err := engine.NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	failures := make(map[string]int)
+	eng.Register("import", func(ctx *engine.Context) error {
+		_, err := engine.Step(ctx, "fetch", func() (int, error) {
+			if failures[ctx.WorkflowID] == 0 {
+				failures[ctx.WorkflowID]++
+				return 0, errors.New("timeout")
+			}
+			return 42, nil
+		}, engine.WithRetry(engine.RetryPolicy{MaxAttempts: 2, InitialInterval: time.Millisecond}))
+		if err != nil {
+			return err
+		}
+		_, err = ctx.StartChildDetached("notify", "notify", nil)
+		return err
+	})
+	eng.Register("notify", func(ctx *engine.Context) error { return nil })
+
+	for i := 0; i < 5; i++ {
+		if err := eng.Enqueue(fmt.Sprintf("import-%d", i), "import", nil); err != nil {
+			t.Fatalf("failed to enqueue: %v", err)
+		}
+	}
+	if err := eng.StartWorker(engine.WorkerConfig{Capacity: 1, PollInterval: 10 * time.Millisecond}); err != nil {
+		t.Fatalf("failed to start worker: %v", err)
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		runs, _ := eng.ListWorkflows(engine.WorkflowFilter{Status: "completed"})
+		if len(runs) == 10 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("workflows never completed, %d done", len(runs))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	handler, err := NewHandler(eng)
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+
+	stepQueries := func() int64 {
+		for _, op := range eng.StorageMetrics() {
+			if op.Op == "ListStepsForWorkflows" {
+				return op.Count
+			}
+		}
+		return 0
+	}
+	before := stepQueries()
+
+	query := `{
+		workflows {
+			name
+			runs(first: 3) {
+				id status
+				steps { id status attempts { number status error } }
+				children { id name status steps { id } }
+			}
+		}
+	}`
+	body, _ := json.Marshal(map[string]string{"query": query})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body)))
+
+	var resp struct {
+		Data struct {
+			Workflows []struct {
+				Name string
+				Runs []struct {
+					ID    string
+					Steps []struct {
+						ID       string
+						Attempts []struct {
+							Number int
+							Status string
+							Error  *string
+						}
+					}
+					Children []struct {
+						ID   string
+						Name string
+					}
+				}
+			}
+		}
+		Errors []interface{}
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("bad response %s: %v", rec.Body.String(), err)
+	}
+	if len(resp.Errors) > 0 {
+		t.Fatalf("query failed: %v", resp.Errors)
+	}
+
+	wfs := resp.Data.Workflows
+	if len(wfs) != 2 || wfs[0].Name != "import" || len(wfs[0].Runs) != 3 || len(wfs[1].Runs) != 3 {
+		t.Fatalf("unexpected workflows: %s", rec.Body.String())
+	}
+	run := wfs[0].Runs[0]
+	if run.ID != "import-4" {
+		t.Errorf("expected newest run first, got %s", run.ID)
+	}
+	if len(run.Steps) != 2 || len(run.Steps[0].Attempts) != 2 || run.Steps[0].Attempts[0].Status != "failed" {
+		t.Errorf("unexpected steps: %+v", run.Steps)
+	}
+	if len(run.Children) != 1 || run.Children[0].ID != "import-4/notify" {
+		t.Errorf("unexpected children: %+v", run.Children)
+	}
+
+	// One query for the steps of every listed run and one for their children's
+	if n := stepQueries() - before; n != 2 {
+		t.Errorf("expected 2 batched step queries, got %d", n)
+	}
+}
+
+func TestDashboardRunLookup(t *testing.T) {
+	dbPath := "./test_dashboard_run.db"
+	defer os.Remove(dbPath)
+
+	eng, err := engine.NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	eng.Execute("adhoc-1", func(ctx *engine.Context) error {
+		_, err := engine.Step(ctx, "only", func() (string, error) { return "done", nil })
+		return err
+	})
+
+	handler, err := NewHandler(eng)
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"query":     `query($id: ID!) { run(id: $id) { id status steps { id output durationMs } } missing: run(id: "nope") { id } }`,
+		"variables": map[string]string{"id": "adhoc-1"},
+	})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body)))
+
+	var resp struct {
+		Data struct {
+			Run struct {
+				Status string
+				Steps  []struct {
+					Output     string
+					DurationMs *float64
+				}
+			}
+			Missing *struct{ ID string }
+		}
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("bad response %s: %v", rec.Body.String(), err)
+	}
+	if resp.Data.Run.Status != "completed" || len(resp.Data.Run.Steps) != 1 ||
+		resp.Data.Run.Steps[0].Output != `"done"` || resp.Data.Run.Steps[0].DurationMs == nil {
+		t.Errorf("unexpected run: %s", rec.Body.String())
+	}
+	if resp.Data.Missing != nil {
+		t.Errorf("expected null for a missing run, got %+v", resp.Data.Missing)
+	}
+}
+
+func TestDashboardTagStats(t *testing.T) {
+	dbPath := "./test_dashboard_tags.db"
+	defer os.Remove(dbPath)
+
+	eng, err := engine.NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	for i, fail := range []bool{false, true} {
+		eng.Execute(fmt.Sprintf("pay-%d", i), func(ctx *engine.Context) error {
+			_, err := engine.Step(ctx, "charge", func() (bool, error) {
+				if fail {
+					return false, errors.New("declined")
+				}
+				return true, nil
+			}, engine.WithTags("payments"))
+			return err
+		})
+	}
+
+	handler, err := NewHandler(eng)
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+
+	body, _ := json.Marshal(map[string]string{
+		"query": `{ tagStats { tag completed failed failureRate } run(id: "pay-0") { steps { tags } } }`,
+	})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body)))
+
+	var resp struct {
+		Data struct {
+			TagStats []struct {
+				Tag         string
+				Completed   int
+				Failed      int
+				FailureRate float64
+			}
+			Run struct {
+				Steps []struct{ Tags []string }
+			}
+		}
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("bad response %s: %v", rec.Body.String(), err)
+	}
+	stats := resp.Data.TagStats
+	if len(stats) != 1 || stats[0].Tag != "payments" || stats[0].Completed != 1 || stats[0].Failed != 1 || stats[0].FailureRate != 0.5 {
+		t.Errorf("unexpected tag stats: %s", rec.Body.String())
+	}
+	if steps := resp.Data.Run.Steps; len(steps) != 1 || len(steps[0].Tags) != 1 || steps[0].Tags[0] != "payments" {
+		t.Errorf("unexpected step tags: %s", rec.Body.String())
+	}
+}
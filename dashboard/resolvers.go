@@ -0,0 +1,439 @@
+package dashboard
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/graph-gophers/graphql-go"
+
+	"github.com/yourusername/durable-execution-engine/engine"
+)
+
+// Nested fields are resolved in batches: every run returned by one list
+// shares a runBatch, so the first run asked for its steps loads the steps of
+// all of them in one query, and likewise for children and for the runs of
+// each workflow name. A dashboard page costs a query per level, not per row.
+
+// queryResolver resolves the Query type
+type queryResolver struct {
+	eng *engine.Engine
+}
+
+// Workflows lists every workflow name
+func (q *queryResolver) Workflows() ([]*workflowResolver, error) {
+	names, err := q.eng.ListWorkflowNames()
+	if err != nil {
+		return nil, err
+	}
+	return newWorkflows(q.eng, names), nil
+}
+
+// Workflow returns one workflow name, or null if no run was started under it
+func (q *queryResolver) Workflow(args struct{ Name string }) (*workflowResolver, error) {
+	names, err := q.eng.ListWorkflowNames()
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range names {
+		if n == args.Name {
+			return newWorkflows(q.eng, []string{n})[0], nil
+		}
+	}
+	return nil, nil
+}
+
+// Runs lists runs of any workflow
+func (q *queryResolver) Runs(args runsArgs) ([]*runResolver, error) {
+	runs, err := q.eng.ListWorkflows(args.filter())
+	if err != nil {
+		return nil, err
+	}
+	return newRuns(q.eng, runs), nil
+}
+
+// Run returns one run, or null if it doesn't exist
+func (q *queryResolver) Run(args struct{ ID graphql.ID }) (*runResolver, error) {
+	run, err := q.eng.GetWorkflow(string(args.ID))
+	if errors.Is(err, engine.ErrWorkflowNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newRuns(q.eng, []engine.WorkflowInfo{*run})[0], nil
+}
+
+// TagStats returns step outcomes per tag
+func (q *queryResolver) TagStats(args struct{ Since *graphql.Time }) ([]*tagStatsResolver, error) {
+	var since time.Time
+	if args.Since != nil {
+		since = args.Since.Time
+	}
+	stats, err := q.eng.TagStats(since)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*tagStatsResolver, len(stats))
+	for i := range stats {
+		out[i] = &tagStatsResolver{st: &stats[i]}
+	}
+	return out, nil
+}
+
+// runsArgs are the arguments of a runs field
+type runsArgs struct {
+	Status *string
+	First  int32
+}
+
+// filter converts the arguments to a workflow filter
+func (a runsArgs) filter() engine.WorkflowFilter {
+	var f engine.WorkflowFilter
+	if a.Status != nil {
+		f.Status = *a.Status
+	}
+	f.Limit = int(a.First)
+	return f
+}
+
+// workflowResolver resolves the Workflow type
+type workflowResolver struct {
+	name  string
+	batch *workflowBatch
+}
+
+// workflowBatch loads the runs of sibling workflow names together
+type workflowBatch struct {
+	eng   *engine.Engine
+	names []string
+
+	mu   sync.Mutex
+	runs map[runsArgsKey]*runsByName
+}
+
+// runsArgsKey identifies the arguments runs were loaded with
+type runsArgsKey struct {
+	status string
+	limit  int
+}
+
+// runsByName is one batched load of runs, grouped by workflow name
+type runsByName struct {
+	once   sync.Once
+	byName map[string][]*runResolver
+	err    error
+}
+
+// newWorkflows creates resolvers for sibling workflow names
+func newWorkflows(eng *engine.Engine, names []string) []*workflowResolver {
+	batch := &workflowBatch{eng: eng, names: names, runs: make(map[runsArgsKey]*runsByName)}
+	out := make([]*workflowResolver, len(names))
+	for i, n := range names {
+		out[i] = &workflowResolver{name: n, batch: batch}
+	}
+	return out
+}
+
+// Name returns the workflow name
+func (w *workflowResolver) Name() string { return w.name }
+
+// Runs returns the workflow's runs, loading those of every sibling name at once
+func (w *workflowResolver) Runs(args runsArgs) ([]*runResolver, error) {
+	filter := args.filter()
+	key := runsArgsKey{status: filter.Status, limit: filter.Limit}
+
+	b := w.batch
+	b.mu.Lock()
+	load, ok := b.runs[key]
+	if !ok {
+		load = &runsByName{}
+		b.runs[key] = load
+	}
+	b.mu.Unlock()
+
+	load.once.Do(func() {
+		filter.Names = b.names
+		runs, err := b.eng.ListWorkflows(filter)
+		if err != nil {
+			load.err = err
+			return
+		}
+		load.byName = make(map[string][]*runResolver)
+		for _, r := range newRuns(b.eng, runs) {
+			load.byName[r.info.Name] = append(load.byName[r.info.Name], r)
+		}
+	})
+	if load.err != nil {
+		return nil, load.err
+	}
+	return load.byName[w.name], nil
+}
+
+// runResolver resolves the Run type
+type runResolver struct {
+	info  engine.WorkflowInfo
+	batch *runBatch
+}
+
+// runBatch loads the steps and children of sibling runs together
+type runBatch struct {
+	eng *engine.Engine
+	ids []string
+
+	stepsOnce sync.Once
+	steps     map[string][]engine.StepRecord
+	stepsErr  error
+
+	childrenOnce sync.Once
+	children     map[string][]*runResolver
+	childrenErr  error
+}
+
+// newRuns creates resolvers for sibling runs
+func newRuns(eng *engine.Engine, runs []engine.WorkflowInfo) []*runResolver {
+	batch := &runBatch{eng: eng, ids: make([]string, len(runs))}
+	out := make([]*runResolver, len(runs))
+	for i, r := range runs {
+		batch.ids[i] = r.WorkflowID
+		out[i] = &runResolver{info: r, batch: batch}
+	}
+	return out
+}
+
+// ID returns the workflow ID
+func (r *runResolver) ID() graphql.ID { return graphql.ID(r.info.WorkflowID) }
+
+// Name returns the registered name
+func (r *runResolver) Name() string { return r.info.Name }
+
+// Status returns the run's status
+func (r *runResolver) Status() string { return r.info.Status }
+
+// Queue returns the queue the run was enqueued on
+func (r *runResolver) Queue() string { return r.info.Queue }
+
+// ParentID returns the run that started this one, if any
+func (r *runResolver) ParentID() *graphql.ID {
+	if r.info.ParentID == "" {
+		return nil
+	}
+	id := graphql.ID(r.info.ParentID)
+	return &id
+}
+
+// Output returns the encoded result of a completed run
+func (r *runResolver) Output() *string { return optionalBytes(r.info.Output) }
+
+// CreatedAt returns when the run was created
+func (r *runResolver) CreatedAt() graphql.Time { return graphql.Time{Time: r.info.CreatedAt} }
+
+// UpdatedAt returns when the run last changed status
+func (r *runResolver) UpdatedAt() graphql.Time { return graphql.Time{Time: r.info.UpdatedAt} }
+
+// Steps returns the run's steps, loading those of every sibling run at once
+func (r *runResolver) Steps() ([]*stepResolver, error) {
+	b := r.batch
+	b.stepsOnce.Do(func() {
+		b.steps, b.stepsErr = b.eng.GetHistories(b.ids)
+	})
+	if b.stepsErr != nil {
+		return nil, fmt.Errorf("failed to load steps: %w", b.stepsErr)
+	}
+
+	records := b.steps[r.info.WorkflowID]
+	out := make([]*stepResolver, len(records))
+	for i := range records {
+		out[i] = &stepResolver{rec: &records[i]}
+	}
+	return out, nil
+}
+
+// Children returns the runs this one started, loading those of every sibling run at once
+func (r *runResolver) Children() ([]*runResolver, error) {
+	b := r.batch
+	b.childrenOnce.Do(func() {
+		runs, err := b.eng.ListWorkflows(engine.WorkflowFilter{ParentIDs: b.ids})
+		if err != nil {
+			b.childrenErr = err
+			return
+		}
+		b.children = make(map[string][]*runResolver)
+		for _, c := range newRuns(b.eng, runs) {
+			b.children[c.info.ParentID] = append(b.children[c.info.ParentID], c)
+		}
+	})
+	if b.childrenErr != nil {
+		return nil, fmt.Errorf("failed to load children: %w", b.childrenErr)
+	}
+	return b.children[r.info.WorkflowID], nil
+}
+
+// stepResolver resolves the Step type
+type stepResolver struct {
+	rec *engine.StepRecord
+}
+
+// ID returns the step ID
+func (s *stepResolver) ID() string { return s.rec.StepID }
+
+// Key returns the step key
+func (s *stepResolver) Key() string { return s.rec.StepKey }
+
+// Sequence returns the step's sequence number
+func (s *stepResolver) Sequence() int32 { return int32(s.rec.SequenceNum) }
+
+// Kind returns the step kind
+func (s *stepResolver) Kind() string { return s.rec.Kind }
+
+// Tags returns the step's tags
+func (s *stepResolver) Tags() []string {
+	if s.rec.Tags == nil {
+		return []string{}
+	}
+	return s.rec.Tags
+}
+
+// Status returns the step's status
+func (s *stepResolver) Status() string { return s.rec.Status }
+
+// Output returns the encoded result of a completed step
+func (s *stepResolver) Output() *string { return optionalBytes(s.rec.Output) }
+
+// Error returns the step's error, if it failed
+func (s *stepResolver) Error() *string { return optionalString(s.rec.Error) }
+
+// WorkerID returns the worker that ran the step
+func (s *stepResolver) WorkerID() *string { return optionalString(s.rec.WorkerID) }
+
+// Hostname returns the host that ran the step
+func (s *stepResolver) Hostname() *string { return optionalString(s.rec.Hostname) }
+
+// Pid returns the process that ran the step
+func (s *stepResolver) Pid() *int32 { return optionalInt(s.rec.PID) }
+
+// StartedAt returns when the step started
+func (s *stepResolver) StartedAt() graphql.Time { return graphql.Time{Time: s.rec.StartedAt} }
+
+// CompletedAt returns when the step finished, if it has
+func (s *stepResolver) CompletedAt() *graphql.Time { return optionalTime(s.rec.CompletedAt) }
+
+// DurationMs returns how long the step ran, if it has finished
+func (s *stepResolver) DurationMs() *float64 {
+	return optionalDuration(s.rec.CompletedAt, s.rec.Duration())
+}
+
+// Attempts returns every attempt of the step
+func (s *stepResolver) Attempts() []*attemptResolver {
+	out := make([]*attemptResolver, len(s.rec.Attempts))
+	for i := range s.rec.Attempts {
+		out[i] = &attemptResolver{a: &s.rec.Attempts[i]}
+	}
+	return out
+}
+
+// attemptResolver resolves the Attempt type
+type attemptResolver struct {
+	a *engine.StepAttempt
+}
+
+// Number returns the attempt number, starting at 1
+func (a *attemptResolver) Number() int32 { return int32(a.a.Attempt) }
+
+// Status returns the attempt's outcome
+func (a *attemptResolver) Status() string { return a.a.Status }
+
+// Error returns the attempt's error, if it failed
+func (a *attemptResolver) Error() *string { return optionalString(a.a.Error) }
+
+// WorkerID returns the worker that made the attempt
+func (a *attemptResolver) WorkerID() *string { return optionalString(a.a.WorkerID) }
+
+// Hostname returns the host that made the attempt
+func (a *attemptResolver) Hostname() *string { return optionalString(a.a.Hostname) }
+
+// Pid returns the process that made the attempt
+func (a *attemptResolver) Pid() *int32 { return optionalInt(a.a.PID) }
+
+// StartedAt returns when the attempt started
+func (a *attemptResolver) StartedAt() graphql.Time { return graphql.Time{Time: a.a.StartedAt} }
+
+// CompletedAt returns when the attempt finished, if it has
+func (a *attemptResolver) CompletedAt() *graphql.Time { return optionalTime(a.a.CompletedAt) }
+
+// DurationMs returns how long the attempt ran, if it has finished
+func (a *attemptResolver) DurationMs() *float64 {
+	return optionalDuration(a.a.CompletedAt, a.a.Duration())
+}
+
+// tagStatsResolver resolves the TagStats type
+type tagStatsResolver struct {
+	st *engine.TagStats
+}
+
+// Tag returns the tag
+func (t *tagStatsResolver) Tag() string { return t.st.Tag }
+
+// Completed returns how many tagged steps completed
+func (t *tagStatsResolver) Completed() int32 { return int32(t.st.Completed) }
+
+// Failed returns how many tagged steps failed
+func (t *tagStatsResolver) Failed() int32 { return int32(t.st.Failed) }
+
+// Canceled returns how many tagged steps were canceled
+func (t *tagStatsResolver) Canceled() int32 { return int32(t.st.Canceled) }
+
+// InProgress returns how many tagged steps are running
+func (t *tagStatsResolver) InProgress() int32 { return int32(t.st.InProgress) }
+
+// FailureRate returns the fraction of finished tagged steps that failed
+func (t *tagStatsResolver) FailureRate() float64 { return t.st.FailureRate() }
+
+// MeanDurationMs returns the average duration of finished tagged steps
+func (t *tagStatsResolver) MeanDurationMs() float64 {
+	return float64(t.st.MeanDuration()) / float64(time.Millisecond)
+}
+
+// optionalString maps an empty string to null
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// optionalInt maps zero to null
+func optionalInt(n int) *int32 {
+	if n == 0 {
+		return nil
+	}
+	v := int32(n)
+	return &v
+}
+
+// optionalBytes maps nil to null and anything else to a string
+func optionalBytes(b []byte) *string {
+	if b == nil {
+		return nil
+	}
+	s := string(b)
+	return &s
+}
+
+// optionalTime maps the zero time to null
+func optionalTime(t time.Time) *graphql.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &graphql.Time{Time: t}
+}
+
+// optionalDuration returns d in milliseconds once completedAt is set
+func optionalDuration(completedAt time.Time, d time.Duration) *float64 {
+	if completedAt.IsZero() {
+		return nil
+	}
+	ms := float64(d) / float64(time.Millisecond)
+	return &ms
+}
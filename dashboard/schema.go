@@ -0,0 +1,87 @@
+package dashboard
+
+// schema is the GraphQL schema served by NewHandler. It mirrors the way the
+// dashboard drills down: workflow names, their runs, each run's steps and
+// every attempt of a step.
+const schema = `
+schema {
+	query: Query
+}
+
+scalar Time
+
+type Query {
+	# Names workflows have been started under, each with its runs
+	workflows: [Workflow!]!
+	workflow(name: String!): Workflow
+	# Runs of any workflow, newest first
+	runs(status: String, first: Int = 50): [Run!]!
+	run(id: ID!): Run
+	# Step outcomes per tag across every workflow, for steps started since the given time
+	tagStats(since: Time): [TagStats!]!
+}
+
+type Workflow {
+	name: String!
+	# Newest runs first
+	runs(status: String, first: Int = 20): [Run!]!
+}
+
+type Run {
+	id: ID!
+	# Empty for workflows started with Execute rather than by name
+	name: String!
+	status: String!
+	queue: String!
+	parentId: ID
+	# Encoded result of a completed run
+	output: String
+	createdAt: Time!
+	updatedAt: Time!
+	steps: [Step!]!
+	# Runs started by this one
+	children: [Run!]!
+}
+
+type Step {
+	id: String!
+	key: String!
+	sequence: Int!
+	kind: String!
+	tags: [String!]!
+	status: String!
+	output: String
+	error: String
+	workerId: String
+	# Host and process that executed the step
+	hostname: String
+	pid: Int
+	startedAt: Time!
+	completedAt: Time
+	durationMs: Float
+	attempts: [Attempt!]!
+}
+
+type TagStats {
+	tag: String!
+	completed: Int!
+	failed: Int!
+	canceled: Int!
+	inProgress: Int!
+	# Fraction of finished steps that failed
+	failureRate: Float!
+	meanDurationMs: Float!
+}
+
+type Attempt {
+	number: Int!
+	status: String!
+	error: String
+	workerId: String
+	hostname: String
+	pid: Int
+	startedAt: Time!
+	completedAt: Time
+	durationMs: Float
+}
+`
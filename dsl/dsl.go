@@ -0,0 +1,204 @@
+// Package dsl runs declaratively defined workflows on the durable engine.
+//
+// A definition lists steps in order; each step calls a named activity
+// registered in Go, with arguments that may reference the workflow input and
+// earlier step results:
+//
+//	name: publish-report
+//	steps:
+//	  - id: fetch
+//	    activity: http-get
+//	    args: {url: "${input.source}"}
+//	    retry: {max_attempts: 3, initial_interval: 1s}
+//	  - parallel:
+//	      - id: pdf
+//	        activity: render-pdf
+//	        args: {data: "${steps.fetch}"}
+//	      - id: csv
+//	        activity: render-csv
+//	        args: {data: "${steps.fetch.rows}"}
+//	  - id: notify
+//	    activity: email
+//	    args: {to: "${input.owner}", subject: "Report ${input.source} ready"}
+//
+// A definition can also be a template: "params" declares start-time
+// parameters with defaults, referenced as ${params.name} and supplied with
+// engine.WithParams when the run is enqueued.
+//
+// Definitions are YAML or JSON. Each step runs as an engine step keyed by its
+// ID, so a definition can be edited and re-registered without a redeploy;
+// in-flight runs replay the steps whose IDs they have already completed.
+package dsl
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Definition is a declarative workflow
+type Definition struct {
+	Name   string            `yaml:"name"`
+	Params map[string]string `yaml:"params"` // template parameters and their defaults
+	Steps  []Node            `yaml:"steps"`
+}
+
+// Node is either a single step or a group of steps run in parallel
+type Node struct {
+	Step     `yaml:",inline"`
+	Parallel []Step `yaml:"parallel"`
+}
+
+// Step calls one registered activity
+type Step struct {
+	ID       string                 `yaml:"id"`
+	Activity string                 `yaml:"activity"`
+	Args     map[string]interface{} `yaml:"args"`
+	Retry    *Retry                 `yaml:"retry"`
+	Timeout  time.Duration          `yaml:"timeout"`
+}
+
+// Retry mirrors engine.RetryPolicy; durations are strings such as "500ms"
+type Retry struct {
+	MaxAttempts     int           `yaml:"max_attempts"`
+	InitialInterval time.Duration `yaml:"initial_interval"`
+	MaxInterval     time.Duration `yaml:"max_interval"`
+	Multiplier      float64       `yaml:"multiplier"`
+	Jitter          float64       `yaml:"jitter"`
+}
+
+// Activity is a Go function a definition can call by name. Its result must be
+// JSON-serializable; later steps see it in its decoded JSON form.
+type Activity func(args map[string]interface{}) (interface{}, error)
+
+// Activities is a set of named activities
+type Activities struct {
+	mu sync.RWMutex
+	m  map[string]Activity
+}
+
+// NewActivities creates an empty activity set
+func NewActivities() *Activities {
+	return &Activities{m: make(map[string]Activity)}
+}
+
+// Register makes fn callable from definitions under name
+func (a *Activities) Register(name string, fn Activity) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.m[name] = fn
+}
+
+// lookup returns the activity registered under name
+func (a *Activities) lookup(name string) (Activity, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	fn, ok := a.m[name]
+	return fn, ok
+}
+
+// Parse decodes a YAML or JSON definition
+func Parse(data []byte) (*Definition, error) {
+	var def Definition
+	if err := yaml.Unmarshal(data, &def); err != nil {
+		return nil, fmt.Errorf("failed to parse workflow definition: %w", err)
+	}
+	return &def, nil
+}
+
+// LoadFile reads and decodes a definition file
+func LoadFile(path string) (*Definition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workflow definition: %w", err)
+	}
+	return Parse(data)
+}
+
+// refPattern matches ${input...}, ${params...} and ${steps...} references in string arguments
+var refPattern = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// Validate checks that the definition is well formed, that every activity is
+// registered, and that references only point at the input or earlier steps
+func (d *Definition) Validate(acts *Activities) error {
+	if d.Name == "" {
+		return fmt.Errorf("workflow definition has no name")
+	}
+	if len(d.Steps) == 0 {
+		return fmt.Errorf("workflow %s has no steps", d.Name)
+	}
+
+	done := make(map[string]bool)
+	for i, node := range d.Steps {
+		group := node.Parallel
+		if len(group) == 0 {
+			group = []Step{node.Step}
+		} else if node.ID != "" || node.Activity != "" {
+			return fmt.Errorf("workflow %s: node %d mixes a step with a parallel group", d.Name, i)
+		}
+
+		for _, s := range group {
+			if s.ID == "" {
+				return fmt.Errorf("workflow %s: node %d has a step without an id", d.Name, i)
+			}
+			if done[s.ID] {
+				return fmt.Errorf("workflow %s: duplicate step id %s", d.Name, s.ID)
+			}
+			if _, ok := acts.lookup(s.Activity); !ok {
+				return fmt.Errorf("workflow %s: step %s uses unknown activity %q", d.Name, s.ID, s.Activity)
+			}
+			for _, ref := range references(s.Args) {
+				if err := checkReference(ref, done); err != nil {
+					return fmt.Errorf("workflow %s: step %s: %w", d.Name, s.ID, err)
+				}
+			}
+		}
+
+		// Steps in a parallel group cannot see each other's results
+		for _, s := range group {
+			done[s.ID] = true
+		}
+	}
+	return nil
+}
+
+// checkReference validates a single ${...} expression
+func checkReference(ref string, done map[string]bool) error {
+	parts := strings.Split(ref, ".")
+	switch parts[0] {
+	case "input", "params":
+		return nil
+	case "steps":
+		if len(parts) < 2 || !done[parts[1]] {
+			return fmt.Errorf("reference ${%s} does not name an earlier step", ref)
+		}
+		return nil
+	default:
+		return fmt.Errorf("reference ${%s} must start with input, params or steps", ref)
+	}
+}
+
+// references collects every ${...} expression in an argument tree
+func references(v interface{}) []string {
+	var refs []string
+	switch v := v.(type) {
+	case string:
+		for _, m := range refPattern.FindAllStringSubmatch(v, -1) {
+			refs = append(refs, m[1])
+		}
+	case map[string]interface{}:
+		for _, child := range v {
+			refs = append(refs, references(child)...)
+		}
+	case []interface{}:
+		for _, child := range v {
+			refs = append(refs, references(child)...)
+		}
+	}
+	return refs
+}
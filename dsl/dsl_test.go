@@ -0,0 +1,206 @@
+package dsl
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yourusername/durable-execution-engine/engine"
+)
+
+const reportYAML = `
+name: publish-report
+steps:
+  - id: fetch
+    activity: fetch
+    args: {source: "${input.source}"}
+    retry: {max_attempts: 3, initial_interval: 1ms}
+  - parallel:
+      - id: pdf
+        activity: render
+        args: {format: pdf, rows: "${steps.fetch.rows}"}
+      - id: csv
+        activity: render
+        args: {format: csv, rows: "${steps.fetch.rows}"}
+  - id: notify
+    activity: notify
+    args:
+      to: "${input.owner}"
+      subject: "Report ${input.source} ready: ${steps.pdf}"
+`
+
+func TestDefinitionRuns(t *testing.T) {
+	dbPath := "./test_dsl.db"
+	defer os.Remove(dbPath)
+
+	eng, err := engine.NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	var fetchCalls int32
+	notified := make(chan map[string]interface{}, 1)
+
+	acts := NewActivities()
+	acts.Register("fetch", func(args map[string]interface{}) (interface{}, error) {
+		if atomic.AddInt32(&fetchCalls, 1) == 1 {
+			return nil, errors.New("connection reset by peer")
+		}
+		return map[string]interface{}{"rows": []int{1, 2, 3}, "source": args["source"]}, nil
+	})
+	acts.Register("render", func(args map[string]interface{}) (interface{}, error) {
+		rows := args["rows"].([]interface{})
+		return args["format"].(string) + ":" + strings.Repeat("#", len(rows)), nil
+	})
+	acts.Register("notify", func(args map[string]interface{}) (interface{}, error) {
+		notified <- args
+		return true, nil
+	})
+
+	def, err := Parse([]byte(reportYAML))
+	if err != nil {
+		t.Fatalf("failed to parse definition: %v", err)
+	}
+	if err := Register(eng, def, acts); err != nil {
+		t.Fatalf("failed to register definition: %v", err)
+	}
+
+	input := map[string]string{"source": "sales", "owner": "ops@example.com"}
+	if err := eng.Enqueue("report-1", "publish-report", input); err != nil {
+		t.Fatalf("failed to enqueue: %v", err)
+	}
+	if err := eng.StartWorker(engine.WorkerConfig{PollInterval: 10 * time.Millisecond}); err != nil {
+		t.Fatalf("failed to start worker: %v", err)
+	}
+
+	select {
+	case args := <-notified:
+		if args["to"] != "ops@example.com" {
+			t.Errorf("expected input reference to resolve, got %v", args["to"])
+		}
+		if args["subject"] != "Report sales ready: pdf:###" {
+			t.Errorf("expected embedded references to resolve, got %v", args["subject"])
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("workflow did not reach notify")
+	}
+
+	if n := atomic.LoadInt32(&fetchCalls); n != 2 {
+		t.Errorf("expected fetch to be retried once, got %d calls", n)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		status, _ := eng.GetWorkflowStatus("report-1")
+		if status == "completed" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected workflow to complete, got %s", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDefinitionValidation(t *testing.T) {
+	acts := NewActivities()
+	acts.Register("noop", func(args map[string]interface{}) (interface{}, error) { return nil, nil })
+
+	cases := map[string]string{
+		"unknown activity": `
+name: bad
+steps:
+  - {id: a, activity: missing}`,
+		"forward reference": `
+name: bad
+steps:
+  - {id: a, activity: noop, args: {x: "${steps.b}"}}
+  - {id: b, activity: noop}`,
+		"sibling reference": `
+name: bad
+steps:
+  - parallel:
+      - {id: a, activity: noop}
+      - {id: b, activity: noop, args: {x: "${steps.a}"}}`,
+		"duplicate id": `
+name: bad
+steps:
+  - {id: a, activity: noop}
+  - {id: a, activity: noop}`,
+		"bad root": `
+name: bad
+steps:
+  - {id: a, activity: noop, args: {x: "${env.HOME}"}}`,
+	}
+	for name, src := range cases {
+		def, err := Parse([]byte(src))
+		if err != nil {
+			t.Fatalf("%s: failed to parse: %v", name, err)
+		}
+		if err := def.Validate(acts); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+
+	// JSON definitions are accepted too
+	def, err := Parse([]byte(`{"name": "ok", "steps": [{"id": "a", "activity": "noop", "args": {"x": "${input}"}}]}`))
+	if err != nil {
+		t.Fatalf("failed to parse JSON definition: %v", err)
+	}
+	if err := def.Validate(acts); err != nil {
+		t.Errorf("expected valid JSON definition, got %v", err)
+	}
+}
+
+func TestTemplateDefinition(t *testing.T) {
+	dbPath := "./test_dsl_template.db"
+	defer os.Remove(dbPath)
+
+	eng, err := engine.NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	posted := make(chan string, 2)
+	acts := NewActivities()
+	acts.Register("post", func(args map[string]interface{}) (interface{}, error) {
+		posted <- args["channel"].(string) + " " + args["text"].(string)
+		return nil, nil
+	})
+
+	def, err := Parse([]byte(`
+name: notify-team
+params: {channel: "#alerts"}
+steps:
+  - id: post
+    activity: post
+    args: {channel: "${params.channel}", text: "paging ${params.team}"}
+`))
+	if err != nil {
+		t.Fatalf("failed to parse definition: %v", err)
+	}
+	if err := Register(eng, def, acts); err != nil {
+		t.Fatalf("failed to register definition: %v", err)
+	}
+
+	if err := eng.Enqueue("page-1", "notify-team", nil, engine.WithParams(map[string]string{"team": "search"})); err != nil {
+		t.Fatalf("failed to enqueue: %v", err)
+	}
+	if err := eng.StartWorker(engine.WorkerConfig{PollInterval: 10 * time.Millisecond}); err != nil {
+		t.Fatalf("failed to start worker: %v", err)
+	}
+
+	select {
+	case got := <-posted:
+		if got != "#alerts paging search" {
+			t.Errorf("expected params to be substituted, got %q", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("template run did not post")
+	}
+}
@@ -0,0 +1,226 @@
+package dsl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/yourusername/durable-execution-engine/engine"
+)
+
+// Register validates def and registers it with the engine under def.Name, so
+// it can be started with Enqueue like any Go workflow. Registering a changed
+// definition under the same name replaces the previous one.
+func Register(eng *engine.Engine, def *Definition, acts *Activities) error {
+	if err := def.Validate(acts); err != nil {
+		return err
+	}
+	eng.Register(def.Name, def.Workflow(acts))
+	return nil
+}
+
+// Workflow returns a workflow function that executes the definition.
+// The definition should have been validated against acts.
+func (d *Definition) Workflow(acts *Activities) engine.WorkflowFunc {
+	return func(ctx *engine.Context) error {
+		var input interface{}
+		if err := ctx.Input(&input); err != nil {
+			return err
+		}
+		params := make(map[string]interface{}, len(d.Params))
+		for k, v := range d.Params {
+			params[k] = v
+		}
+		for k, v := range ctx.Params() {
+			params[k] = v
+		}
+		scope := &scope{input: input, params: params, steps: make(map[string]interface{})}
+
+		for _, node := range d.Steps {
+			if len(node.Parallel) == 0 {
+				if err := runStep(ctx, node.Step, acts, scope); err != nil {
+					return err
+				}
+				continue
+			}
+
+			for _, s := range node.Parallel {
+				s := s
+				ctx.Go(func() error {
+					return runStep(ctx, s, acts, scope)
+				})
+			}
+			if err := ctx.Wait(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// scope holds the values references resolve against
+type scope struct {
+	input  interface{}
+	params map[string]interface{}
+
+	mu    sync.Mutex
+	steps map[string]interface{}
+}
+
+// runStep resolves a step's arguments and runs its activity as an engine step
+func runStep(ctx *engine.Context, s Step, acts *Activities, sc *scope) error {
+	activity, ok := acts.lookup(s.Activity)
+	if !ok {
+		return fmt.Errorf("step %s uses unknown activity %q", s.ID, s.Activity)
+	}
+
+	resolved, err := sc.resolve(s.Args)
+	if err != nil {
+		return fmt.Errorf("step %s: %w", s.ID, err)
+	}
+	args, _ := resolved.(map[string]interface{})
+	if args == nil {
+		args = make(map[string]interface{})
+	}
+
+	var opts []engine.StepOption
+	if s.Retry != nil {
+		opts = append(opts, engine.WithRetry(engine.RetryPolicy{
+			MaxAttempts:     s.Retry.MaxAttempts,
+			InitialInterval: s.Retry.InitialInterval,
+			MaxInterval:     s.Retry.MaxInterval,
+			Multiplier:      s.Retry.Multiplier,
+			Jitter:          s.Retry.Jitter,
+		}))
+	}
+	if s.Timeout > 0 {
+		opts = append(opts, engine.WithStepTimeout(s.Timeout))
+	}
+
+	result, err := engine.Step(ctx, s.ID, func() (interface{}, error) {
+		out, err := activity(args)
+		if err != nil {
+			return nil, err
+		}
+		// Normalize to decoded JSON so a fresh run sees what a replay would
+		return normalize(out)
+	}, opts...)
+	if err != nil {
+		return err
+	}
+
+	sc.mu.Lock()
+	sc.steps[s.ID] = result
+	sc.mu.Unlock()
+	return nil
+}
+
+// resolve substitutes ${...} references throughout an argument tree. A string
+// that is exactly one reference becomes the referenced value; references
+// embedded in longer strings are formatted into the text.
+func (sc *scope) resolve(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		if m := refPattern.FindStringSubmatch(v); m != nil && m[0] == v {
+			return sc.lookup(m[1])
+		}
+		var firstErr error
+		out := refPattern.ReplaceAllStringFunc(v, func(expr string) string {
+			val, err := sc.lookup(refPattern.FindStringSubmatch(expr)[1])
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return expr
+			}
+			return formatValue(val)
+		})
+		return out, firstErr
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, child := range v {
+			r, err := sc.resolve(child)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = r
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, child := range v {
+			r, err := sc.resolve(child)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = r
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
+
+// lookup evaluates a reference such as input.user.email, params.team or steps.fetch.rows
+func (sc *scope) lookup(ref string) (interface{}, error) {
+	parts := strings.Split(ref, ".")
+
+	var cur interface{}
+	switch parts[0] {
+	case "input":
+		cur, parts = sc.input, parts[1:]
+	case "params":
+		cur, parts = sc.params, parts[1:]
+	case "steps":
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("reference ${%s} does not name a step", ref)
+		}
+		sc.mu.Lock()
+		val, ok := sc.steps[parts[1]]
+		sc.mu.Unlock()
+		if !ok {
+			return nil, fmt.Errorf("reference ${%s}: step %s has not run", ref, parts[1])
+		}
+		cur, parts = val, parts[2:]
+	default:
+		return nil, fmt.Errorf("reference ${%s} must start with input, params or steps", ref)
+	}
+
+	for _, field := range parts {
+		obj, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("reference ${%s}: %s is not a field of an object", ref, field)
+		}
+		if cur, ok = obj[field]; !ok {
+			return nil, fmt.Errorf("reference ${%s}: no field %s", ref, field)
+		}
+	}
+	return cur, nil
+}
+
+// formatValue renders a referenced value inside a larger string
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(v)
+		return string(data)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// normalize round-trips a value through JSON
+func normalize(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("activity result is not JSON-serializable: %w", err)
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
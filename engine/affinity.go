@@ -0,0 +1,152 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrMissingCapabilities is returned by a step that requires worker labels
+// the running engine wasn't started with
+var ErrMissingCapabilities = errors.New("worker lacks required capabilities")
+
+// WithRequires restricts a step to workers registered with every one of
+// labels, such as "has-gpu" or "region=eu" (see WorkerConfig.Labels).
+//
+// When the running worker lacks one, no further steps start and a workflow
+// enqueued by name is handed back to its queue, where only a worker with all
+// the labels can claim it, whatever shard it is in. That worker replays the
+// completed steps and runs the rest of the workflow. Workflows started with
+// Execute can't move between processes and fail with ErrMissingCapabilities.
+func WithRequires(labels ...string) StepOption {
+	return func(o *stepOptions) {
+		o.requires = append(o.requires, labels...)
+	}
+}
+
+// missingLabels returns the required labels this engine's worker lacks
+func (e *Engine) missingLabels(required []string) []string {
+	e.workerMu.Lock()
+	var labels []string
+	if e.worker != nil {
+		labels = e.worker.Labels
+	}
+	e.workerMu.Unlock()
+
+	var missing []string
+	for _, l := range required {
+		found := false
+		for _, have := range labels {
+			if l == have {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, l)
+		}
+	}
+	return missing
+}
+
+// checkAffinity fails a step this worker can't run. Once one step has
+// failed, every later step does too, so the workflow stops where it is
+// and can be handed off.
+func (ctx *Context) checkAffinity(id string, required []string) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if len(required) > 0 {
+		if missing := ctx.engine.missingLabels(required); len(missing) > 0 {
+			ctx.requires = mergeLabels(ctx.requires, required)
+			return fmt.Errorf("%w: step %s needs %s", ErrMissingCapabilities, id, strings.Join(missing, ", "))
+		}
+	}
+	if len(ctx.requires) > 0 {
+		return fmt.Errorf("%w: waiting for a worker with %s", ErrMissingCapabilities, strings.Join(ctx.requires, ", "))
+	}
+	return nil
+}
+
+// handoffLabels returns the labels a worker needs to continue this run, if
+// a step couldn't run here
+func (ctx *Context) handoffLabels() []string {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	return ctx.requires
+}
+
+// mergeLabels returns the sorted union of two label sets
+func mergeLabels(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var out []string
+	for _, l := range append(append([]string(nil), a...), b...) {
+		if !seen[l] {
+			seen[l] = true
+			out = append(out, l)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// handOff requeues a workflow for a worker with the given labels. It
+// reports false for workflows without a registered name, which only the
+// code that started them can run.
+func (e *Engine) handOff(workflowID string, version int64, labels []string) (bool, error) {
+	name, err := e.storage.GetWorkflowName(workflowID)
+	if err != nil || name == "" {
+		return false, err
+	}
+	if err := e.storage.HandOffWorkflow(workflowID, version, labels); err != nil {
+		return false, fmt.Errorf("failed to hand off workflow: %w", err)
+	}
+	fmt.Printf("[AFFINITY] %s needs %s; requeued for a capable worker\n", workflowID, strings.Join(labels, ", "))
+	return true, nil
+}
+
+// GetWorkflowName returns the registered name a workflow was started under, if any
+func (s *Storage) GetWorkflowName(workflowID string) (string, error) {
+	var name string
+	err := s.db.QueryRow(
+		"SELECT COALESCE(workflow_name, '') FROM workflows WHERE workflow_id = ?",
+		workflowID,
+	).Scan(&name)
+	if err != nil {
+		return "", fmt.Errorf("failed to get workflow name: %w", err)
+	}
+	return name, nil
+}
+
+// HandOffWorkflow returns a running workflow to its queue, restricted to
+// workers with labels, provided its status is still at version
+func (s *Storage) HandOffWorkflow(workflowID string, version int64, labels []string) error {
+	requires, err := json.Marshal(labels)
+	if err != nil {
+		return err
+	}
+
+	var affected int64
+	err = s.retryOnBusy(func() error {
+		res, err := s.db.Exec(
+			`UPDATE workflows SET status = 'queued', claimed_by = NULL, requires = ?,
+			   version = version + 1, updated_at = ?
+			 WHERE workflow_id = ? AND status = 'running' AND version = ?`,
+			string(requires), dbNow(), workflowID, version,
+		)
+		if err != nil {
+			return err
+		}
+		affected, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w: %s", ErrStatusConflict, workflowID)
+	}
+	return nil
+}
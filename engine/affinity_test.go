@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStepAffinityHandsOffToCapableWorker(t *testing.T) {
+	dbPath := "./test_affinity.db"
+	defer os.Remove(dbPath)
+
+	cpu, err := NewEngine(dbPath, WithWorkerID("cpu-1"))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer cpu.Close()
+	gpu, err := NewEngine(dbPath, WithWorkerID("gpu-1"))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer gpu.Close()
+
+	var prepared, rendered int32
+	render := func(ctx *Context) error {
+		if _, err := Step(ctx, "prepare", func() (int, error) {
+			atomic.AddInt32(&prepared, 1)
+			return 1, nil
+		}); err != nil {
+			return err
+		}
+		_, err := Step(ctx, "render", func() (string, error) {
+			atomic.AddInt32(&rendered, 1)
+			return "frame.png", nil
+		}, WithRequires("has-gpu"))
+		return err
+	}
+	cpu.Register("render", render)
+	gpu.Register("render", render)
+
+	// The CPU worker runs what it can, then hands the run off
+	if err := cpu.StartWorker(WorkerConfig{PollInterval: 10 * time.Millisecond}); err != nil {
+		t.Fatalf("failed to start worker: %v", err)
+	}
+	if err := cpu.Enqueue("render-1", "render", nil); err != nil {
+		t.Fatalf("failed to enqueue: %v", err)
+	}
+	waitForStatus(t, cpu, "render-1", "queued", func() bool { return atomic.LoadInt32(&prepared) == 1 })
+
+	// It stays queued until a worker with the label appears
+	time.Sleep(50 * time.Millisecond)
+	if status, _ := cpu.GetWorkflowStatus("render-1"); status != "queued" || rendered != 0 {
+		t.Fatalf("expected run to wait for a GPU worker, got %s with %d renders", status, rendered)
+	}
+
+	if err := gpu.StartWorker(WorkerConfig{Labels: []string{"has-gpu", "region=eu"}, PollInterval: 10 * time.Millisecond}); err != nil {
+		t.Fatalf("failed to start worker: %v", err)
+	}
+	waitForStatus(t, gpu, "render-1", "completed", func() bool { return true })
+
+	if prepared != 1 || rendered != 1 {
+		t.Errorf("expected each step to run once, got prepare=%d render=%d", prepared, rendered)
+	}
+	steps, _ := gpu.storage.ListSteps("render-1")
+	if len(steps) != 2 || steps[0].WorkerID != "cpu-1" || steps[1].WorkerID != "gpu-1" {
+		t.Errorf("unexpected step placement: %+v", steps)
+	}
+
+	workers, _ := gpu.ListWorkers()
+	for _, w := range workers {
+		if w.WorkerID == "gpu-1" && len(w.Labels) != 2 {
+			t.Errorf("expected registered labels, got %v", w.Labels)
+		}
+	}
+}
+
+func TestStepAffinityFailsUnnamedWorkflows(t *testing.T) {
+	dbPath := "./test_affinity_unnamed.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	err = eng.Execute("adhoc-1", func(ctx *Context) error {
+		_, err := Step(ctx, "train", func() (int, error) { return 1, nil }, WithRequires("has-gpu"))
+		return err
+	})
+	if !errors.Is(err, ErrMissingCapabilities) {
+		t.Fatalf("expected ErrMissingCapabilities, got %v", err)
+	}
+	if status, _ := eng.GetWorkflowStatus("adhoc-1"); status != "failed" {
+		t.Errorf("expected failed, got %s", status)
+	}
+}
+
+// waitForStatus polls until a workflow reaches status and cond holds
+func waitForStatus(t *testing.T, e *Engine, workflowID, status string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if s, _ := e.GetWorkflowStatus(workflowID); s == status && cond() {
+			return
+		}
+		if time.Now().After(deadline) {
+			s, _ := e.GetWorkflowStatus(workflowID)
+			t.Fatalf("workflow %s never reached %s (status %s)", workflowID, status, s)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,123 @@
+package engine
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// StepAttempt is one execution of a step's function. A step retried twice
+// before succeeding has three attempts; attempts continue numbering across
+// resumes of the workflow.
+type StepAttempt struct {
+	Attempt     int
+	Status      string // in_progress, completed or failed
+	Error       string
+	WorkerID    string
+	Hostname    string // host and process the attempt ran in
+	PID         int
+	StartedAt   time.Time
+	CompletedAt time.Time // zero while in progress
+}
+
+// Duration returns how long the attempt ran, or zero if it has not finished
+func (a *StepAttempt) Duration() time.Duration {
+	if a.CompletedAt.IsZero() {
+		return 0
+	}
+	return a.CompletedAt.Sub(a.StartedAt)
+}
+
+// Executor returns the worker, host and process that made the attempt
+func (a *StepAttempt) Executor() Executor {
+	return Executor{WorkerID: a.WorkerID, Hostname: a.Hostname, PID: a.PID}
+}
+
+// StartStepAttempt records the start of the next attempt of a step and returns its number
+func (s *Storage) StartStepAttempt(workflowID, stepKey string, exec Executor) (int, error) {
+	var attempt int
+	err := s.retryOnBusy(func() error {
+		return s.db.QueryRow(
+			`INSERT INTO step_attempts (workflow_id, step_key, attempt, worker_id, hostname, pid, status, started_at)
+			 SELECT ?, ?, COALESCE(MAX(attempt), 0) + 1, ?, ?, ?, 'in_progress', ?
+			 FROM step_attempts WHERE workflow_id = ? AND step_key = ?
+			 RETURNING attempt`,
+			workflowID, stepKey, exec.WorkerID, exec.Hostname, exec.PID, dbNow(), workflowID, stepKey,
+		).Scan(&attempt)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to record step attempt: %w", err)
+	}
+	return attempt, nil
+}
+
+// FinishStepAttempt records the outcome of an attempt; a nil error means it
+// succeeded and ErrWorkflowCanceled that its workflow was canceled
+func (s *Storage) FinishStepAttempt(workflowID, stepKey string, attempt int, attemptErr error) error {
+	status, errMsg := "completed", sql.NullString{}
+	if attemptErr != nil {
+		status, errMsg = "failed", sql.NullString{String: attemptErr.Error(), Valid: true}
+		if errors.Is(attemptErr, ErrWorkflowCanceled) {
+			status = "canceled"
+		}
+	}
+	return s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			`UPDATE step_attempts SET status = ?, error = ?, completed_at = ?
+			 WHERE workflow_id = ? AND step_key = ? AND attempt = ?`,
+			status, errMsg, dbNow(), workflowID, stepKey, attempt,
+		)
+		return err
+	})
+}
+
+// ListStepAttempts loads every attempt of a workflow's steps, keyed by step key
+func (s *Storage) ListStepAttempts(workflowID string) (map[string][]StepAttempt, error) {
+	attempts, err := s.listStepAttempts([]string{workflowID})
+	if err != nil {
+		return nil, err
+	}
+	return attempts[workflowID], nil
+}
+
+// listStepAttempts loads every attempt of several workflows' steps, keyed by
+// workflow ID and step key
+func (s *Storage) listStepAttempts(workflowIDs []string) (map[string]map[string][]StepAttempt, error) {
+	args := make([]interface{}, len(workflowIDs))
+	for i, id := range workflowIDs {
+		args[i] = id
+	}
+
+	rows, err := s.db.Query(
+		fmt.Sprintf(
+			`SELECT workflow_id, step_key, attempt, status, error, worker_id, COALESCE(hostname, ''), COALESCE(pid, 0), started_at, completed_at
+			 FROM step_attempts WHERE workflow_id IN (%s) ORDER BY workflow_id, step_key, attempt`,
+			placeholders(len(workflowIDs)),
+		),
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list step attempts: %w", err)
+	}
+	defer rows.Close()
+
+	attempts := make(map[string]map[string][]StepAttempt)
+	for rows.Next() {
+		var workflowID, stepKey string
+		var a StepAttempt
+		var errMsg, workerID sql.NullString
+		var completedAt sql.NullTime
+		if err := rows.Scan(&workflowID, &stepKey, &a.Attempt, &a.Status, &errMsg, &workerID, &a.Hostname, &a.PID, &a.StartedAt, &completedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan step attempt: %w", err)
+		}
+		a.Error = errMsg.String
+		a.WorkerID = workerID.String
+		a.CompletedAt = completedAt.Time
+		if attempts[workflowID] == nil {
+			attempts[workflowID] = make(map[string][]StepAttempt)
+		}
+		attempts[workflowID][stepKey] = append(attempts[workflowID][stepKey], a)
+	}
+	return attempts, rows.Err()
+}
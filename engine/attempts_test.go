@@ -0,0 +1,66 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestHistoryRecordsEveryAttempt(t *testing.T) {
+	dbPath := "./test_attempts.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath, WithWorkerID("worker-a"))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	calls := 0
+	workflow := func(ctx *Context) error {
+		_, err := Step(ctx, "flaky", func() (string, error) {
+			calls++
+			if calls < 3 {
+				return "", fmt.Errorf("timeout #%d", calls)
+			}
+			return "ok", nil
+		}, WithRetry(RetryPolicy{MaxAttempts: 2, InitialInterval: time.Millisecond}))
+		return err
+	}
+
+	// Two attempts fail and exhaust the policy; the resume succeeds on the third
+	if err := eng.Execute("attempts-1", workflow); err == nil {
+		t.Fatal("expected first run to fail")
+	}
+	if err := eng.Execute("attempts-1", workflow); err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+
+	steps, err := eng.GetHistory("attempts-1")
+	if err != nil {
+		t.Fatalf("failed to get history: %v", err)
+	}
+	if len(steps) != 1 {
+		t.Fatalf("expected 1 step, got %d", len(steps))
+	}
+
+	attempts := steps[0].Attempts
+	if len(attempts) != 3 {
+		t.Fatalf("expected 3 attempts, got %+v", attempts)
+	}
+	for i, a := range attempts {
+		if a.Attempt != i+1 || a.WorkerID != "worker-a" || a.CompletedAt.IsZero() {
+			t.Errorf("unexpected attempt %d: %+v", i+1, a)
+		}
+	}
+	if attempts[0].Status != "failed" || attempts[0].Error != "timeout #1" || attempts[1].Error != "timeout #2" {
+		t.Errorf("expected failed attempts with errors, got %+v", attempts[:2])
+	}
+	if attempts[2].Status != "completed" || attempts[2].Error != "" {
+		t.Errorf("expected final attempt to succeed, got %+v", attempts[2])
+	}
+	if steps[0].Status != "completed" {
+		t.Errorf("expected step completed, got %s", steps[0].Status)
+	}
+}
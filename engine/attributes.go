@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SetSearchAttribute tags the run with a name and value that ListWorkflows
+// can filter on (WorkflowFilter.SearchAttributes). Setting a name again
+// replaces its value, so replays may set it again safely.
+func (ctx *Context) SetSearchAttribute(name, value string) error {
+	return ctx.storage.SetSearchAttribute(ctx.WorkflowID, name, value)
+}
+
+// GetSearchAttributes returns the search attributes of a run
+func (e *Engine) GetSearchAttributes(workflowID string) (map[string]string, error) {
+	if _, err := e.storage.GetWorkflowStatus(workflowID); err != nil {
+		return nil, err
+	}
+	return e.storage.GetSearchAttributes(workflowID)
+}
+
+// SetSearchAttribute stores one search attribute of a run
+func (s *Storage) SetSearchAttribute(workflowID, name, value string) error {
+	return s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			`INSERT INTO workflow_attributes (workflow_id, name, value) VALUES (?, ?, ?)
+			 ON CONFLICT(workflow_id, name) DO UPDATE SET value = excluded.value`,
+			workflowID, name, value,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to set search attribute %s: %w", name, err)
+		}
+		return nil
+	})
+}
+
+// GetSearchAttributes loads the search attributes of a run
+func (s *Storage) GetSearchAttributes(workflowID string) (map[string]string, error) {
+	rows, err := s.db.Query("SELECT name, value FROM workflow_attributes WHERE workflow_id = ?", workflowID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load search attributes: %w", err)
+	}
+	defer rows.Close()
+
+	attrs := make(map[string]string)
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan search attribute: %w", err)
+		}
+		attrs[name] = value
+	}
+	return attrs, rows.Err()
+}
+
+// searchAttributeConditions returns SQL conditions matching runs that carry
+// every attribute, with their arguments in a stable order
+func searchAttributeConditions(attrs map[string]string) ([]string, []interface{}) {
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var where []string
+	var args []interface{}
+	for _, name := range names {
+		where = append(where, `EXISTS (SELECT 1 FROM workflow_attributes a
+			WHERE a.workflow_id = workflows.workflow_id AND a.name = ? AND a.value = ?)`)
+		args = append(args, name, attrs[name])
+	}
+	return where, args
+}
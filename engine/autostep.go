@@ -0,0 +1,315 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// AutoStepSite is where an AutoStep call is in the workflow's code, as
+// recorded with the run the first time the call ran
+type AutoStepSite struct {
+	StepID   string
+	Package  string // import path of the calling package
+	File     string // base name of the source file
+	Line     int
+	Function string // e.g. github.com/acme/orders.Checkout.func1
+	CallHash string // hash of the call expression, if WithCallHash was given
+}
+
+// AutoStep is a bonus feature that automatically generates step IDs from the
+// call location: the calling package's import path, file and line, e.g.
+// "github.com/acme/orders/checkout.go:42". With WithCallHash the line is
+// replaced by a hash of the call expression. Each call site is recorded
+// with the run (see ListAutoSteps), so runs started before a change to the
+// code can be migrated with RenameSteps.
+func AutoStep[T any](ctx *Context, fn func() (T, error), opts ...StepOption) (T, error) {
+	// Get caller location (skip 1 frame to get the actual caller)
+	site, file := callerSite(2)
+	if ctx.newStepOptions(opts).callHash {
+		site = withCallHash(site, file)
+	}
+	id, err := ctx.autoStepID(site)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return Step(ctx, id, fn, opts...)
+}
+
+// WithCallHash keys an AutoStep by a hash of its call expression instead of
+// its line, so edits elsewhere in the file keep the step's ID; editing the
+// call itself, including the step's function, changes it. Identical calls
+// in a file are told apart by their order. Without the source file at run
+// time the line is used.
+func WithCallHash() StepOption {
+	return func(o *stepOptions) {
+		o.callHash = true
+	}
+}
+
+// ListAutoSteps returns the call sites of a run's AutoStep calls, by step ID
+func (e *Engine) ListAutoSteps(workflowID string) ([]AutoStepSite, error) {
+	return e.reads.ListAutoSteps(workflowID)
+}
+
+// RenameSteps migrates a run's history to new step IDs, e.g. after the
+// AutoStep calls it recorded moved (see ListAutoSteps): the steps, their
+// attempts, checkpoints and events keep their sequence numbers and take the
+// new IDs, so a resumed run replays them under the IDs the code now uses.
+// Idempotency keys of renamed steps change with their step keys.
+func (e *Engine) RenameSteps(workflowID string, renames map[string]string) error {
+	if err := e.storage.RenameSteps(workflowID, renames); err != nil {
+		return err
+	}
+	fmt.Printf("[AUTOSTEP] renamed %d steps of %s\n", len(renames), workflowID)
+	return nil
+}
+
+// autoStepID returns the step ID of an AutoStep call site and records the
+// site with the run. A run started before IDs carried the package path
+// keeps the file:line IDs it recorded.
+func (ctx *Context) autoStepID(site AutoStepSite) (string, error) {
+	legacy := fmt.Sprintf("%s:%d", site.File, site.Line)
+	ctx.mu.Lock()
+	_, isNew := ctx.stepIDToSeq[site.StepID]
+	_, isLegacy := ctx.stepIDToSeq[legacy]
+	recorded := ctx.autoSteps[site.StepID]
+	ctx.mu.Unlock()
+	if isLegacy && !isNew {
+		return legacy, nil
+	}
+	if recorded || ctx.sim != nil {
+		return site.StepID, nil
+	}
+
+	if err := ctx.storage.RecordAutoStep(ctx.WorkflowID, site); err != nil {
+		return "", err
+	}
+	ctx.mu.Lock()
+	if ctx.autoSteps == nil {
+		ctx.autoSteps = make(map[string]bool)
+	}
+	ctx.autoSteps[site.StepID] = true
+	ctx.mu.Unlock()
+	return site.StepID, nil
+}
+
+// callerSite describes the call skip frames up, with its line-based step
+// ID, and returns the path of its source file
+func callerSite(skip int) (AutoStepSite, string) {
+	pc, file, line, ok := runtime.Caller(skip)
+	if !ok {
+		return AutoStepSite{StepID: "unknown:0", File: "unknown"}, ""
+	}
+	site := AutoStepSite{File: filepath.Base(file), Line: line}
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		site.Function = fn.Name()
+		site.Package = funcPackage(site.Function)
+	}
+	site.StepID = fmt.Sprintf("%s:%d", path.Join(site.Package, site.File), line)
+	return site, file
+}
+
+// funcPackage returns the import path of a qualified function name
+func funcPackage(name string) string {
+	slash := strings.LastIndex(name, "/")
+	if dot := strings.Index(name[slash+1:], "."); dot >= 0 {
+		return name[:slash+1+dot]
+	}
+	return name
+}
+
+// withCallHash keys a call site by the hash of its AutoStep call expression
+// in file, e.g. "github.com/acme/orders/checkout.go@1f3a9c0e2b7d"; the site
+// keeps its line-based ID if the source can't be read
+func withCallHash(site AutoStepSite, file string) AutoStepSite {
+	calls, err := autoStepCalls(file)
+	if err != nil {
+		printAutoStepWarning(file, err)
+		return site
+	}
+	for _, call := range calls {
+		if site.Line >= call.start && site.Line <= call.end {
+			site.CallHash = call.hash
+			site.StepID = fmt.Sprintf("%s@%s", path.Join(site.Package, site.File), call.hash)
+			break
+		}
+	}
+	return site
+}
+
+// autoStepCall is an AutoStep call expression in a source file
+type autoStepCall struct {
+	start, end int // lines the expression spans
+	hash       string
+}
+
+// autoStepCallCache holds the AutoStep calls of each source file read
+var autoStepCallCache sync.Map
+
+// autoStepWarned remembers source files reported unreadable
+var autoStepWarned sync.Map
+
+// autoStepCalls parses a source file for its AutoStep calls, innermost
+// first, hashing each call expression; the nth repeat of an expression
+// gets "-n" appended to its hash
+func autoStepCalls(file string) ([]autoStepCall, error) {
+	if cached, ok := autoStepCallCache.Load(file); ok {
+		return cached.([]autoStepCall), nil
+	}
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, src, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var calls []autoStepCall
+	seen := make(map[string]int)
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || !isAutoStepFunc(call.Fun) {
+			return true
+		}
+		var buf bytes.Buffer
+		if err := printer.Fprint(&buf, token.NewFileSet(), call); err != nil {
+			return true
+		}
+		sum := sha256.Sum256(buf.Bytes())
+		hash := hex.EncodeToString(sum[:6])
+		if seen[hash]++; seen[hash] > 1 {
+			hash = fmt.Sprintf("%s-%d", hash, seen[hash])
+		}
+		calls = append(calls, autoStepCall{
+			start: fset.Position(call.Pos()).Line,
+			end:   fset.Position(call.End()).Line,
+			hash:  hash,
+		})
+		return true
+	})
+	// Calls nested in another call's function are matched first
+	for i, j := 0, len(calls)-1; i < j; i, j = i+1, j-1 {
+		calls[i], calls[j] = calls[j], calls[i]
+	}
+	autoStepCallCache.Store(file, calls)
+	return calls, nil
+}
+
+// isAutoStepFunc reports whether a called expression is AutoStep, possibly
+// package-qualified or instantiated
+func isAutoStepFunc(fun ast.Expr) bool {
+	switch f := fun.(type) {
+	case *ast.IndexExpr:
+		return isAutoStepFunc(f.X)
+	case *ast.IndexListExpr:
+		return isAutoStepFunc(f.X)
+	case *ast.SelectorExpr:
+		return f.Sel.Name == "AutoStep"
+	case *ast.Ident:
+		return f.Name == "AutoStep"
+	}
+	return false
+}
+
+// printAutoStepWarning reports once per file that call hashes fall back to lines
+func printAutoStepWarning(file string, err error) {
+	if _, warned := autoStepWarned.LoadOrStore(file, true); !warned {
+		fmt.Printf("[AUTOSTEP] can't hash calls in %s, using line numbers: %v\n", file, err)
+	}
+}
+
+// RecordAutoStep records the call site of a run's AutoStep step
+func (s *Storage) RecordAutoStep(workflowID string, site AutoStepSite) error {
+	return s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			`INSERT OR IGNORE INTO auto_steps (workflow_id, step_id, package, file, line, function, call_hash)
+			 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			workflowID, site.StepID, site.Package, site.File, site.Line, site.Function, site.CallHash,
+		)
+		return err
+	})
+}
+
+// ListAutoSteps loads the AutoStep call sites recorded with a run
+func (s *Storage) ListAutoSteps(workflowID string) ([]AutoStepSite, error) {
+	rows, err := s.db.Query(
+		`SELECT step_id, package, file, line, function, call_hash FROM auto_steps
+		 WHERE workflow_id = ? ORDER BY step_id`,
+		workflowID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list auto steps: %w", err)
+	}
+	defer rows.Close()
+
+	var sites []AutoStepSite
+	for rows.Next() {
+		var site AutoStepSite
+		var hash sql.NullString
+		if err := rows.Scan(&site.StepID, &site.Package, &site.File, &site.Line, &site.Function, &hash); err != nil {
+			return nil, fmt.Errorf("failed to scan auto step: %w", err)
+		}
+		site.CallHash = hash.String
+		sites = append(sites, site)
+	}
+	return sites, rows.Err()
+}
+
+// RenameSteps gives a run's steps new IDs in one transaction, keeping their
+// sequence numbers
+func (s *Storage) RenameSteps(workflowID string, renames map[string]string) error {
+	err := s.WithTx(func(tx StorageTx) error {
+		for from, to := range renames {
+			var seq int64
+			err := tx.db.QueryRow(
+				`SELECT sequence_num FROM steps WHERE workflow_id = ? AND step_id = ?
+				 UNION SELECT sequence_num FROM step_sequences WHERE workflow_id = ? AND step_id = ?`,
+				workflowID, from, workflowID, from,
+			).Scan(&seq)
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("step %s not found in %s", from, workflowID)
+			}
+			if err != nil {
+				return err
+			}
+			fromKey, toKey := generateStepKey(from, seq), generateStepKey(to, seq)
+
+			for _, stmt := range []struct {
+				query string
+				args  []interface{}
+			}{
+				{"UPDATE steps SET step_id = ?, step_key = ? WHERE workflow_id = ? AND step_key = ?", []interface{}{to, toKey, workflowID, fromKey}},
+				{"UPDATE step_attempts SET step_key = ? WHERE workflow_id = ? AND step_key = ?", []interface{}{toKey, workflowID, fromKey}},
+				{"UPDATE step_checkpoints SET step_key = ? WHERE workflow_id = ? AND step_key = ?", []interface{}{toKey, workflowID, fromKey}},
+				{"UPDATE workflow_events SET step_id = ?, step_key = ? WHERE workflow_id = ? AND step_key = ?", []interface{}{to, toKey, workflowID, fromKey}},
+				{"UPDATE step_sequences SET step_id = ? WHERE workflow_id = ? AND step_id = ?", []interface{}{to, workflowID, from}},
+				{"UPDATE auto_steps SET step_id = ? WHERE workflow_id = ? AND step_id = ?", []interface{}{to, workflowID, from}},
+			} {
+				if _, err := tx.db.Exec(stmt.query, stmt.args...); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to rename steps: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAutoStepIDs(t *testing.T) {
+	dbPath := "./test_autostep.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	runs := 0
+	workflow := func(ctx *Context) error {
+		runs++
+		if _, err := AutoStep(ctx, func() (int, error) { return 1, nil }); err != nil {
+			return err
+		}
+		for i := 0; i < 2; i++ {
+			if _, err := AutoStep(ctx, func() (int, error) { return 2, nil }, WithCallHash()); err != nil {
+				return err
+			}
+		}
+		_, err := AutoStep(ctx, func() (int, error) { return 2, nil }, WithCallHash())
+		return err
+	}
+	if err := eng.Execute("auto-1", workflow); err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+
+	sites, err := eng.ListAutoSteps("auto-1")
+	if err != nil {
+		t.Fatalf("failed to list auto steps: %v", err)
+	}
+	if len(sites) != 3 {
+		t.Fatalf("expected three call sites, got %+v", sites)
+	}
+	const pkg = "github.com/yourusername/durable-execution-engine/engine"
+	var byLine, hashed []AutoStepSite
+	for _, site := range sites {
+		if site.Package != pkg || site.File != "autostep_test.go" || !strings.HasPrefix(site.Function, pkg+".TestAutoStepIDs") {
+			t.Errorf("expected the call site in this test, got %+v", site)
+		}
+		if site.CallHash == "" {
+			byLine = append(byLine, site)
+		} else {
+			hashed = append(hashed, site)
+		}
+	}
+	if len(byLine) != 1 || !strings.HasPrefix(byLine[0].StepID, pkg+"/autostep_test.go:") {
+		t.Errorf("expected a line-based ID with the package path, got %+v", byLine)
+	}
+	// Identical calls get distinct hashes; the loop's repeats reuse their step
+	if len(hashed) != 2 || hashed[0].StepID == hashed[1].StepID || !strings.Contains(hashed[0].StepID, "/autostep_test.go@") {
+		t.Errorf("expected two distinct hashed IDs, got %+v", hashed)
+	}
+
+	// A run recorded with the file:line IDs of earlier versions keeps them
+	ctx, err := newContext(eng, "auto-1")
+	if err != nil {
+		t.Fatalf("failed to create context: %v", err)
+	}
+	legacy := byLine[0].File + ":" + strconv.Itoa(byLine[0].Line)
+	ctx.stepIDToSeq[legacy] = 9
+	if id, err := ctx.autoStepID(byLine[0]); err != nil || id != byLine[0].StepID {
+		t.Errorf("expected the recorded new ID to win, got %q, %v", id, err)
+	}
+	delete(ctx.stepIDToSeq, byLine[0].StepID)
+	if id, _ := ctx.autoStepID(byLine[0]); id != legacy {
+		t.Errorf("expected the legacy ID %q, got %q", legacy, id)
+	}
+
+	// Renamed steps replay under their new IDs
+	if err := eng.RenameSteps("auto-1", map[string]string{byLine[0].StepID: "load"}); err != nil {
+		t.Fatalf("failed to rename: %v", err)
+	}
+	history, err := eng.GetHistory("auto-1")
+	if err != nil {
+		t.Fatalf("failed to load history: %v", err)
+	}
+	if history[0].StepID != "load" || history[0].StepKey != "load:1" || len(history[0].Attempts) != 1 {
+		t.Errorf("expected the first step renamed with its attempt, got %+v", history[0])
+	}
+	ctx, err = newContext(eng, "auto-1")
+	if err != nil {
+		t.Fatalf("failed to create context: %v", err)
+	}
+	if seq, _ := ctx.stepSequence("load"); seq != 1 {
+		t.Errorf("expected load to keep sequence 1, got %d", seq)
+	}
+}
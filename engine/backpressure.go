@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrBackpressure is returned when too many workflows are pending to admit another
+var ErrBackpressure = errors.New("too many pending workflows")
+
+// admissionPollInterval is how often a blocked admission re-checks the backlog
+const admissionPollInterval = 50 * time.Millisecond
+
+// WithMaxPendingWorkflows sets the high-water mark of queued plus running
+// workflows above which new work is rejected with ErrBackpressure
+func WithMaxPendingWorkflows(n int) EngineOption {
+	return func(e *Engine) {
+		e.maxPending = n
+	}
+}
+
+// WithAdmissionTimeout makes Execute/Enqueue block up to d for the backlog to
+// drain below the high-water mark before giving up with ErrBackpressure
+func WithAdmissionTimeout(d time.Duration) EngineOption {
+	return func(e *Engine) {
+		e.admissionTimeout = d
+	}
+}
+
+// admit checks the pending backlog against the configured high-water mark
+func (e *Engine) admit() error {
+	if e.maxPending <= 0 {
+		return nil
+	}
+
+	deadline := time.Now().Add(e.admissionTimeout)
+	for {
+		pending, err := e.storage.CountWorkflowsByStatus("queued", "running")
+		if err != nil {
+			return fmt.Errorf("failed to count pending workflows: %w", err)
+		}
+		if pending < e.maxPending {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("%w: %d pending (limit %d)", ErrBackpressure, pending, e.maxPending)
+		}
+
+		select {
+		case <-e.stop:
+			return ErrBackpressure
+		case <-time.After(admissionPollInterval):
+		}
+	}
+}
+
+// CountWorkflowsByStatus counts workflows in any of the given statuses
+func (s *Storage) CountWorkflowsByStatus(statuses ...string) (int, error) {
+	args := make([]interface{}, len(statuses))
+	for i, status := range statuses {
+		args[i] = status
+	}
+
+	var count int
+	err := s.db.QueryRow(
+		fmt.Sprintf("SELECT COUNT(*) FROM workflows WHERE status IN (%s)", placeholders(len(statuses))),
+		args...,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count workflows: %w", err)
+	}
+
+	return count, nil
+}
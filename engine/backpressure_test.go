@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestBackpressureRejectsNewWork(t *testing.T) {
+	dbPath := "./test_backpressure.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath, WithMaxPendingWorkflows(2))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	for _, id := range []string{"wf-1", "wf-2"} {
+		if err := eng.Enqueue(id, "report", nil); err != nil {
+			t.Fatalf("enqueue %s: %v", id, err)
+		}
+	}
+
+	if err := eng.Enqueue("wf-3", "report", nil); !errors.Is(err, ErrBackpressure) {
+		t.Fatalf("expected ErrBackpressure from Enqueue, got %v", err)
+	}
+	if err := eng.Execute("wf-4", func(ctx *Context) error { return nil }); !errors.Is(err, ErrBackpressure) {
+		t.Fatalf("expected ErrBackpressure from Execute, got %v", err)
+	}
+
+	// Existing workflows are always admitted so they can drain
+	if err := eng.Execute("wf-1", func(ctx *Context) error { return nil }); err != nil {
+		t.Fatalf("expected resume of existing workflow to be admitted, got %v", err)
+	}
+	if err := eng.Enqueue("wf-5", "report", nil); err != nil {
+		t.Fatalf("expected admission after backlog drained, got %v", err)
+	}
+}
+
+func TestWorkerRunsEnqueuedWorkflow(t *testing.T) {
+	dbPath := "./test_dispatch.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	type Input struct {
+		Email string
+	}
+	got := make(chan string, 1)
+	eng.Register("welcome", func(ctx *Context) error {
+		var in Input
+		if err := ctx.Input(&in); err != nil {
+			return err
+		}
+		got <- in.Email
+		return nil
+	})
+
+	if err := eng.Enqueue("welcome-1", "welcome", Input{Email: "a@example.com"}); err != nil {
+		t.Fatalf("failed to enqueue: %v", err)
+	}
+	if err := eng.StartWorker(WorkerConfig{Capacity: 2, PollInterval: 10 * time.Millisecond}); err != nil {
+		t.Fatalf("failed to start worker: %v", err)
+	}
+
+	select {
+	case email := <-got:
+		if email != "a@example.com" {
+			t.Errorf("expected input email, got %q", email)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("enqueued workflow was never dispatched")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if status, _ := eng.GetWorkflowStatus("welcome-1"); status == "completed" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("expected enqueued workflow to complete")
+}
@@ -0,0 +1,258 @@
+package engine
+
+import (
+	"archive/tar"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ErrInvalidBackup is returned when restoring an archive that isn't a
+// complete snapshot written by Backup
+var ErrInvalidBackup = errors.New("invalid backup archive")
+
+// Entries of a backup archive
+const (
+	backupManifestName = "manifest.json"
+	backupDatabaseName = "workflows.db"
+)
+
+// BackupManifest describes a snapshot
+type BackupManifest struct {
+	CreatedAt time.Time        `json:"created_at"`
+	SizeBytes int64            `json:"size_bytes"`
+	Tables    map[string]int64 `json:"tables"` // rows per table, checked on restore
+}
+
+// Backup writes a consistent snapshot of the whole database (workflows,
+// steps, schedules, timers, signals, ...) to w as a zstd-compressed tar
+// archive. Workers may keep running: the snapshot is taken in one read
+// transaction and sees every write committed before it started.
+func (e *Engine) Backup(w io.Writer) (*BackupManifest, error) {
+	dir, err := os.MkdirTemp("", "workflow-backup-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	snapshot := filepath.Join(dir, backupDatabaseName)
+	if err := e.storage.Snapshot(snapshot); err != nil {
+		return nil, err
+	}
+	manifest, err := inspectSnapshot(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	manifest.CreatedAt = time.Now().UTC()
+
+	zw, err := zstd.NewWriter(w)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create compressor: %w", err)
+	}
+	tw := tar.NewWriter(zw)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal backup manifest: %w", err)
+	}
+	if err := writeTarEntry(tw, backupManifestName, int64(len(data)), manifest.CreatedAt, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer f.Close()
+	if err := writeTarEntry(tw, backupDatabaseName, manifest.SizeBytes, manifest.CreatedAt, f); err != nil {
+		return nil, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish backup archive: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish backup archive: %w", err)
+	}
+	return manifest, nil
+}
+
+// RestoreBackup replaces the database at dbPath with a snapshot written by
+// Backup. No engine may have dbPath open. The snapshot is verified before it
+// replaces anything, so a truncated or corrupt archive leaves dbPath as it was.
+func RestoreBackup(r io.Reader, dbPath string) (*BackupManifest, error) {
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	defer zr.Close()
+
+	staged := dbPath + ".restore"
+	defer os.Remove(staged)
+
+	var manifest *BackupManifest
+	restored := false
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+		}
+
+		switch hdr.Name {
+		case backupManifestName:
+			manifest = &BackupManifest{}
+			if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+				return nil, fmt.Errorf("%w: bad manifest: %v", ErrInvalidBackup, err)
+			}
+		case backupDatabaseName:
+			if err := copyToFile(staged, tr); err != nil {
+				return nil, err
+			}
+			restored = true
+		}
+	}
+	if manifest == nil || !restored {
+		return nil, fmt.Errorf("%w: missing %s or %s", ErrInvalidBackup, backupManifestName, backupDatabaseName)
+	}
+
+	got, err := inspectSnapshot(staged)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	for table, rows := range manifest.Tables {
+		if got.Tables[table] != rows {
+			return nil, fmt.Errorf("%w: table %s has %d rows, manifest says %d",
+				ErrInvalidBackup, table, got.Tables[table], rows)
+		}
+	}
+
+	// Move the replaced database and its WAL aside before renaming, so stale
+	// WAL frames are never applied to the snapshot, and delete them only once
+	// the snapshot is in place; a failed rename puts them back
+	var aside []string
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		if err := os.Rename(dbPath+suffix, dbPath+".replaced"+suffix); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			restoreAside(dbPath, aside)
+			return nil, fmt.Errorf("failed to move %s aside: %w", dbPath+suffix, err)
+		}
+		aside = append(aside, suffix)
+	}
+	if err := os.Rename(staged, dbPath); err != nil {
+		restoreAside(dbPath, aside)
+		return nil, fmt.Errorf("failed to replace database: %w", err)
+	}
+	for _, suffix := range aside {
+		os.Remove(dbPath + ".replaced" + suffix)
+	}
+	return manifest, nil
+}
+
+// restoreAside moves files RestoreBackup moved aside back into place
+func restoreAside(dbPath string, suffixes []string) {
+	for _, suffix := range suffixes {
+		if err := os.Rename(dbPath+".replaced"+suffix, dbPath+suffix); err != nil {
+			fmt.Printf("[BACKUP] failed to put back %s: %v\n", dbPath+suffix, err)
+		}
+	}
+}
+
+// Snapshot writes a consistent copy of the database to path
+func (s *Storage) Snapshot(path string) error {
+	if _, err := s.db.Exec("VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("failed to snapshot database: %w", err)
+	}
+	return nil
+}
+
+// inspectSnapshot checks a database file's integrity and counts its rows
+func inspectSnapshot(path string) (*BackupManifest, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat snapshot: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer db.Close()
+
+	var check string
+	if err := db.QueryRow("PRAGMA integrity_check").Scan(&check); err != nil {
+		return nil, fmt.Errorf("failed to check snapshot: %w", err)
+	}
+	if check != "ok" {
+		return nil, fmt.Errorf("snapshot failed integrity check: %s", check)
+	}
+
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshot tables: %w", err)
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan table name: %w", err)
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	manifest := &BackupManifest{SizeBytes: info.Size(), Tables: make(map[string]int64, len(tables))}
+	for _, table := range tables {
+		var n int64
+		if err := db.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM "%s"`, table)).Scan(&n); err != nil {
+			return nil, fmt.Errorf("failed to count rows of %s: %w", table, err)
+		}
+		manifest.Tables[table] = n
+	}
+	return manifest, nil
+}
+
+// writeTarEntry adds one regular file to a tar archive
+func writeTarEntry(tw *tar.Writer, name string, size int64, modTime time.Time, r io.Reader) error {
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: size, ModTime: modTime}); err != nil {
+		return fmt.Errorf("failed to write %s header: %w", name, err)
+	}
+	if _, err := io.Copy(tw, r); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// copyToFile writes r to a new file at path
+func copyToFile(path string, r io.Reader) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync %s: %w", path, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	dbPath := "./test_backup.db"
+	restorePath := "./test_backup_restored.db"
+	defer os.Remove(dbPath)
+	defer os.Remove(restorePath)
+
+	engine, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	engine.Execute("before-backup", func(ctx *Context) error {
+		_, err := Step(ctx, "work", func() (string, error) { return "done", nil })
+		return err
+	})
+	if err := engine.CreateSchedule(Schedule{ID: "nightly", Cron: "0 2 * * *", WorkflowName: "report"}); err != nil {
+		t.Fatalf("failed to create schedule: %v", err)
+	}
+
+	var archive bytes.Buffer
+	manifest, err := engine.Backup(&archive)
+	if err != nil {
+		t.Fatalf("failed to back up: %v", err)
+	}
+	if manifest.Tables["workflows"] != 1 || manifest.Tables["schedules"] != 1 || manifest.Tables["steps"] != 1 {
+		t.Errorf("unexpected manifest counts: %v", manifest.Tables)
+	}
+
+	engine.Execute("after-backup", func(ctx *Context) error { return nil })
+	engine.Close()
+
+	// A truncated archive must not replace anything
+	if _, err := RestoreBackup(bytes.NewReader(archive.Bytes()[:archive.Len()/2]), restorePath); !errors.Is(err, ErrInvalidBackup) {
+		t.Errorf("expected ErrInvalidBackup for a truncated archive, got %v", err)
+	}
+	if _, err := os.Stat(restorePath); !os.IsNotExist(err) {
+		t.Errorf("expected no database after a failed restore, got %v", err)
+	}
+
+	// Restoring replaces a database along with its stale WAL
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		if err := os.WriteFile(restorePath+suffix, []byte("stale"), 0o644); err != nil {
+			t.Fatalf("failed to write stale file: %v", err)
+		}
+	}
+	if _, err := RestoreBackup(bytes.NewReader(archive.Bytes()), restorePath); err != nil {
+		t.Fatalf("failed to restore: %v", err)
+	}
+	defer os.Remove(restorePath + "-wal")
+	defer os.Remove(restorePath + "-shm")
+	for _, path := range []string{restorePath + "-wal", restorePath + ".replaced", restorePath + ".replaced-wal", restorePath + ".replaced-shm"} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be gone after the restore, got %v", path, err)
+		}
+	}
+
+	restored, err := NewEngine(restorePath)
+	if err != nil {
+		t.Fatalf("failed to open restored database: %v", err)
+	}
+	defer restored.Close()
+
+	if status, err := restored.GetWorkflowStatus("before-backup"); err != nil || status != "completed" {
+		t.Errorf("expected before-backup completed, got %q: %v", status, err)
+	}
+	if _, err := restored.GetWorkflowStatus("after-backup"); !errors.Is(err, ErrWorkflowNotFound) {
+		t.Errorf("expected after-backup missing from the snapshot, got %v", err)
+	}
+	schedules, err := restored.ListSchedules()
+	if err != nil || len(schedules) != 1 || schedules[0].ID != "nightly" {
+		t.Errorf("expected the nightly schedule, got %+v: %v", schedules, err)
+	}
+}
@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+// Step outputs are stored once per distinct content in the blobs table and
+// referenced from steps.output_blob, so thousands of identical results
+// ("ACCESS-GRANTED", "true", ...) share a single row. Reference counts are
+// maintained by triggers, so any path that updates or deletes steps keeps
+// them correct and unreferenced blobs are removed immediately.
+//
+// Rows written before deduplication keep their inline steps.output; readers
+// use COALESCE over both columns. Streamed outputs keep their data in
+// blob_chunks instead (see stream.go).
+
+// stepOutputColumn selects a step's output from either storage form; queries
+// using it must LEFT JOIN blobs b ON b.id = s.output_blob
+const stepOutputColumn = "COALESCE(b.data, s.output)"
+
+// initBlobStore creates the triggers that maintain blob reference counts
+func (s *Storage) initBlobStore() error {
+	schema := `
+	CREATE TRIGGER IF NOT EXISTS steps_blob_insert AFTER INSERT ON steps
+	WHEN new.output_blob IS NOT NULL BEGIN
+		UPDATE blobs SET refcount = refcount + 1 WHERE id = new.output_blob;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS steps_blob_update AFTER UPDATE OF output_blob ON steps
+	WHEN new.output_blob IS NOT old.output_blob BEGIN
+		UPDATE blobs SET refcount = refcount + 1 WHERE id = new.output_blob;
+		UPDATE blobs SET refcount = refcount - 1 WHERE id = old.output_blob;
+		DELETE FROM blobs WHERE id = old.output_blob AND refcount <= 0;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS steps_blob_delete AFTER DELETE ON steps
+	WHEN old.output_blob IS NOT NULL BEGIN
+		UPDATE blobs SET refcount = refcount - 1 WHERE id = old.output_blob;
+		DELETE FROM blobs WHERE id = old.output_blob AND refcount <= 0;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS blobs_chunks_delete AFTER DELETE ON blobs BEGIN
+		DELETE FROM blob_chunks WHERE blob_id = old.id;
+	END;
+	`
+	if _, err := s.db.Exec(schema); err != nil {
+		return fmt.Errorf("failed to create blob triggers: %w", err)
+	}
+	return nil
+}
+
+// putBlob stores data unless identical content already exists and returns its ID.
+// The caller must reference the blob from a step in the same transaction.
+func putBlob(tx *instrumentedTx, data []byte) (int64, error) {
+	hash := sha256.Sum256(data)
+
+	if _, err := tx.Exec(
+		"INSERT INTO blobs (hash, data, refcount) VALUES (?, ?, 0) ON CONFLICT(hash) DO NOTHING",
+		hash[:], data,
+	); err != nil {
+		return 0, fmt.Errorf("failed to store blob: %w", err)
+	}
+
+	var id int64
+	if err := tx.QueryRow("SELECT id FROM blobs WHERE hash = ?", hash[:]).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to look up blob: %w", err)
+	}
+	return id, nil
+}
+
+// BlobStats reports how much step output storage deduplication is saving
+type BlobStats struct {
+	Blobs      int   // distinct outputs stored
+	References int   // steps pointing at a blob
+	Bytes      int64 // bytes stored in blobs
+	SavedBytes int64 // bytes that would be stored without deduplication, minus Bytes
+}
+
+// BlobStats summarizes the deduplicated step output store
+func (e *Engine) BlobStats() (*BlobStats, error) {
+	return e.storage.BlobStats()
+}
+
+// BlobStats aggregates the blobs table
+func (s *Storage) BlobStats() (*BlobStats, error) {
+	var st BlobStats
+	err := s.db.QueryRow(
+		`SELECT COUNT(*), COALESCE(SUM(refcount), 0), COALESCE(SUM(COALESCE(LENGTH(data), size)), 0),
+		        COALESCE(SUM((refcount - 1) * COALESCE(LENGTH(data), size)), 0)
+		 FROM blobs WHERE refcount > 0`,
+	).Scan(&st.Blobs, &st.References, &st.Bytes, &st.SavedBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob stats: %w", err)
+	}
+	return &st, nil
+}
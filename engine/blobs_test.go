@@ -0,0 +1,80 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestStepOutputDeduplication(t *testing.T) {
+	dbPath := "./test_blobs.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	for i := 0; i < 20; i++ {
+		err := eng.Execute(fmt.Sprintf("access-%d", i), func(ctx *Context) error {
+			_, err := Step(ctx, "grant", func() (string, error) { return "ACCESS-GRANTED", nil })
+			if err != nil {
+				return err
+			}
+			_, err = Step(ctx, "audit", func() (int, error) { return i, nil })
+			return err
+		})
+		if err != nil {
+			t.Fatalf("workflow %d failed: %v", i, err)
+		}
+	}
+
+	stats, err := eng.BlobStats()
+	if err != nil {
+		t.Fatalf("failed to get blob stats: %v", err)
+	}
+	// One shared "ACCESS-GRANTED" blob plus one per distinct audit value
+	if stats.Blobs != 21 || stats.References != 40 {
+		t.Errorf("expected 21 blobs and 40 references, got %+v", stats)
+	}
+	if stats.SavedBytes != 19*int64(len(`"ACCESS-GRANTED"`)) {
+		t.Errorf("unexpected saved bytes %d", stats.SavedBytes)
+	}
+
+	// Outputs read back through the blob reference, including on replay
+	history, err := eng.GetHistory("access-7")
+	if err != nil || len(history) != 2 || string(history[0].Output) != `"ACCESS-GRANTED"` || string(history[1].Output) != "7" {
+		t.Errorf("unexpected history %+v (%v)", history, err)
+	}
+	var replayed string
+	eng.storage.UpdateWorkflowStatus("access-7", "running")
+	eng.Execute("access-7", func(ctx *Context) error {
+		replayed, err = Step(ctx, "grant", func() (string, error) { return "recomputed", nil })
+		return err
+	})
+	if replayed != "ACCESS-GRANTED" {
+		t.Errorf("expected replay from blob, got %q", replayed)
+	}
+
+	// Deleting steps releases references; the last reference removes the blob
+	if _, err := eng.storage.db.Exec("DELETE FROM steps WHERE step_id = 'audit' AND workflow_id != 'access-0'"); err != nil {
+		t.Fatalf("failed to delete steps: %v", err)
+	}
+	if _, err := eng.storage.db.Exec("DELETE FROM steps WHERE step_id = 'grant' AND workflow_id != 'access-0'"); err != nil {
+		t.Fatalf("failed to delete steps: %v", err)
+	}
+	stats, _ = eng.BlobStats()
+	if stats.Blobs != 2 || stats.References != 2 {
+		t.Errorf("expected 2 blobs with 1 reference each, got %+v", stats)
+	}
+
+	// Outputs stored inline before deduplication are still readable
+	eng.storage.CreateWorkflow("legacy-1", 0)
+	eng.storage.db.Exec(`INSERT INTO steps (workflow_id, step_id, sequence_num, step_key, status, output)
+		VALUES ('legacy-1', 'grant', 1, 'grant:1', 'completed', '"OLD"')`)
+	history, err = eng.GetHistory("legacy-1")
+	if err != nil || len(history) != 1 || string(history[0].Output) != `"OLD"` {
+		t.Errorf("expected inline output, got %+v (%v)", history, err)
+	}
+}
@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// ErrDatabaseBusy is returned when a write still finds the database locked
+// after the busy retry policy gave up
+var ErrDatabaseBusy = errors.New("database busy")
+
+// BusyRetryPolicy controls how writes that find SQLite locked are retried.
+// Waits double from InitialBackoff up to MaxBackoff, each randomized
+// between half and all of its length so concurrent writers spread out.
+// Retrying stops after MaxAttempts tries or once MaxElapsed has passed,
+// whichever comes first, and when the caller's context is done.
+type BusyRetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	MaxElapsed     time.Duration // zero: no limit besides MaxAttempts
+}
+
+// DefaultBusyRetryPolicy rides out the lock contention of large parallel
+// fan-outs: up to 20 tries over at most 10 seconds
+var DefaultBusyRetryPolicy = BusyRetryPolicy{
+	MaxAttempts:    20,
+	InitialBackoff: 5 * time.Millisecond,
+	MaxBackoff:     500 * time.Millisecond,
+	MaxElapsed:     10 * time.Second,
+}
+
+// WithBusyRetry replaces DefaultBusyRetryPolicy for the engine's writes
+func WithBusyRetry(policy BusyRetryPolicy) EngineOption {
+	return func(e *Engine) {
+		e.storage.busyRetry = policy
+	}
+}
+
+// WithContext returns a view of the storage whose busy retries stop once
+// ctx is done, or when the next try would come after its deadline
+func (s *Storage) WithContext(ctx context.Context) *Storage {
+	return s.withContextFunc(func() context.Context { return ctx })
+}
+
+// withContextFunc is WithContext for a context that changes over time
+func (s *Storage) withContextFunc(ctx func() context.Context) *Storage {
+	view := *s
+	view.ctx = ctx
+	return &view
+}
+
+// retryOnBusy retries a database operation while SQLite is busy, per the
+// storage's BusyRetryPolicy and context
+func (s *Storage) retryOnBusy(fn func() error) error {
+	// The enclosing WithTx retries the whole transaction
+	if s.db.tx != nil {
+		return fn()
+	}
+	ctx := context.Background()
+	if s.ctx != nil {
+		ctx = s.ctx()
+	}
+	return s.retryOnBusyContext(ctx, fn)
+}
+
+// retryOnBusyContext is retryOnBusy for a given caller context
+func (s *Storage) retryOnBusyContext(ctx context.Context, fn func() error) error {
+	p := s.busyRetry
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 1
+	}
+	start := time.Now()
+	backoff := p.InitialBackoff
+	if backoff <= 0 {
+		backoff = time.Millisecond
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !isSQLiteBusy(err) {
+			return err
+		}
+		if attempt >= p.MaxAttempts {
+			return s.busyExhausted(attempt, start, err)
+		}
+
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		if p.MaxElapsed > 0 && time.Since(start)+wait > p.MaxElapsed {
+			return s.busyExhausted(attempt, start, err)
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return fmt.Errorf("%w: %w", context.DeadlineExceeded, err)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w: %w", ctx.Err(), err)
+		case <-timer.C:
+		}
+		if backoff *= 2; p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+}
+
+// busyExhausted counts an operation that gave up retrying and builds its error
+func (s *Storage) busyExhausted(attempts int, start time.Time, err error) error {
+	s.db.metrics.observeBusyExhausted()
+	return fmt.Errorf("%w after %d attempts in %v: %w", ErrDatabaseBusy, attempts, time.Since(start).Round(time.Millisecond), err)
+}
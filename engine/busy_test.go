@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+var errLocked = errors.New("database is locked (5) (SQLITE_BUSY)")
+
+func TestRetryOnBusyPolicy(t *testing.T) {
+	dbPath := "./test_busy.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath, WithBusyRetry(BusyRetryPolicy{MaxAttempts: 4, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+	s := eng.storage
+
+	calls := 0
+	err = s.retryOnBusy(func() error {
+		calls++
+		if calls < 4 {
+			return errLocked
+		}
+		return nil
+	})
+	if err != nil || calls != 4 {
+		t.Errorf("expected success on the 4th try, got %v after %d", err, calls)
+	}
+
+	calls = 0
+	err = s.retryOnBusy(func() error { calls++; return errLocked })
+	if !errors.Is(err, ErrDatabaseBusy) || calls != 4 {
+		t.Errorf("expected ErrDatabaseBusy after 4 tries, got %v after %d", err, calls)
+	}
+	var exhausted int64
+	for _, op := range eng.StorageMetrics() {
+		exhausted += op.BusyExhausted
+	}
+	if exhausted != 1 {
+		t.Errorf("expected one exhausted operation, got %d", exhausted)
+	}
+
+	// Errors other than busy aren't retried
+	calls = 0
+	boom := errors.New("constraint failed")
+	if err := s.retryOnBusy(func() error { calls++; return boom }); err != boom || calls != 1 {
+		t.Errorf("expected the error without retries, got %v after %d", err, calls)
+	}
+}
+
+func TestRetryOnBusyRespectsContext(t *testing.T) {
+	dbPath := "./test_busy_ctx.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath, WithBusyRetry(BusyRetryPolicy{MaxAttempts: 100, InitialBackoff: 50 * time.Millisecond}))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	// A deadline sooner than the next try stops retrying right away
+	c, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = eng.storage.WithContext(c).retryOnBusy(func() error { return errLocked })
+	if !errors.Is(err, context.DeadlineExceeded) || !isSQLiteBusy(err) || time.Since(start) > 20*time.Millisecond {
+		t.Errorf("expected to stop at the deadline, got %v after %v", err, time.Since(start))
+	}
+
+	// Canceling the caller interrupts the wait
+	c, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	calls := 0
+	err = eng.storage.WithContext(c).retryOnBusy(func() error { calls++; return errLocked })
+	if !errors.Is(err, context.Canceled) || calls > 2 {
+		t.Errorf("expected cancellation to stop retries, got %v after %d tries", err, calls)
+	}
+}
+
+func TestStorageMetricsCountBusyStatements(t *testing.T) {
+	m := &storageMetrics{ops: make(map[string]*StorageOpStats)}
+	m.observe("UPDATE steps SET status = ?", nil, time.Now(), errLocked)
+	m.observe("UPDATE steps SET status = ?", nil, time.Now(), nil)
+
+	ops := m.snapshot()
+	if len(ops) != 1 || ops[0].Busy != 1 || ops[0].Errors != 1 || ops[0].Count != 2 {
+		t.Errorf("expected one busy statement of two, got %+v", ops)
+	}
+}
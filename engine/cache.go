@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// WithGlobalCacheKey memoizes the step's result across workflows under key,
+// e.g. "exchange-rates:2024-05-01". The first workflow to run the step
+// computes it while others with the same key wait; afterwards every workflow
+// reuses the stored result until it expires (see WithGlobalCacheTTL).
+// The result is still recorded in each workflow's own history, so replays
+// are unaffected by cache expiry or invalidation.
+func WithGlobalCacheKey(key string) StepOption {
+	return func(o *stepOptions) {
+		o.cacheKey = key
+	}
+}
+
+// WithGlobalCacheTTL bounds how long a globally cached result is reused; zero never expires
+func WithGlobalCacheTTL(ttl time.Duration) StepOption {
+	return func(o *stepOptions) {
+		o.cacheTTL = ttl
+	}
+}
+
+// InvalidateCacheKey drops a globally cached step result so the next step using key recomputes it
+func (e *Engine) InvalidateCacheKey(key string) error {
+	return e.storage.DeleteCachedStep(key)
+}
+
+// claimGlobalCache returns the cached result for the step's key, or holds the
+// key until release is called so that only this workflow computes it
+func (ctx *Context) claimGlobalCache(stepID string, so *stepOptions) ([]byte, func(), error) {
+	output, found, err := ctx.storage.GetCachedStep(so.cacheKey, time.Now())
+	if err != nil || found {
+		return output, func() {}, err
+	}
+
+	lock, err := ctx.acquireSlot("cache:"+so.cacheKey, ctx.WorkflowID+"/"+stepID, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Another workflow may have filled the entry while we waited
+	output, found, err = ctx.storage.GetCachedStep(so.cacheKey, time.Now())
+	if err != nil || found {
+		lock.Release()
+		return output, func() {}, err
+	}
+	return nil, func() { lock.Release() }, nil
+}
+
+// GetCachedStep returns an unexpired globally cached step result
+func (s *Storage) GetCachedStep(key string, now time.Time) ([]byte, bool, error) {
+	var output []byte
+	err := s.db.QueryRow(
+		"SELECT output FROM step_cache WHERE cache_key = ? AND (expires_at_ms = 0 OR expires_at_ms > ?)",
+		key, now.UnixMilli(),
+	).Scan(&output)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get cached step: %w", err)
+	}
+	return output, true, nil
+}
+
+// PutCachedStep stores a step result under a global cache key, replacing any previous entry
+func (s *Storage) PutCachedStep(key string, output []byte, ttl time.Duration) error {
+	now := time.Now()
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = now.Add(ttl).UnixMilli()
+	}
+
+	return s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			`INSERT INTO step_cache (cache_key, output, created_at_ms, expires_at_ms) VALUES (?, ?, ?, ?)
+			 ON CONFLICT(cache_key) DO UPDATE SET
+			   output = excluded.output, created_at_ms = excluded.created_at_ms, expires_at_ms = excluded.expires_at_ms`,
+			key, output, now.UnixMilli(), expiresAt,
+		)
+		return err
+	})
+}
+
+// DeleteCachedStep removes a global cache entry
+func (s *Storage) DeleteCachedStep(key string) error {
+	return s.retryOnBusy(func() error {
+		_, err := s.db.Exec("DELETE FROM step_cache WHERE cache_key = ?", key)
+		return err
+	})
+}
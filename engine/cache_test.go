@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGlobalStepCache(t *testing.T) {
+	dbPath := "./test_cache.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	var calls int32
+	fetchRates := func(ctx *Context, opts ...StepOption) (float64, error) {
+		opts = append(opts, WithGlobalCacheKey("exchange-rates:2024-05-01"))
+		return Step(ctx, "fetch-rates", func() (float64, error) {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(50 * time.Millisecond)
+			return 1.08, nil
+		}, opts...)
+	}
+
+	// Fan-out: concurrent workflows compute the shared step once
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := eng.Execute(fmt.Sprintf("batch-%d", i), func(ctx *Context) error {
+				rate, err := fetchRates(ctx)
+				if err == nil && rate != 1.08 {
+					err = fmt.Errorf("unexpected rate %v", rate)
+				}
+				return err
+			})
+			if err != nil {
+				t.Errorf("batch-%d failed: %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("expected one computation across workflows, got %d", n)
+	}
+	history, err := eng.GetHistory("batch-3")
+	if err != nil || len(history) != 1 || history[0].Status != "completed" || string(history[0].Output) != "1.08" {
+		t.Errorf("expected cached result in each workflow's history, got %+v (%v)", history, err)
+	}
+
+	// Invalidation forces a recompute
+	if err := eng.InvalidateCacheKey("exchange-rates:2024-05-01"); err != nil {
+		t.Fatalf("failed to invalidate: %v", err)
+	}
+	eng.Execute("batch-late", func(ctx *Context) error {
+		_, err := fetchRates(ctx, WithGlobalCacheTTL(time.Millisecond))
+		return err
+	})
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("expected recompute after invalidation, got %d calls", n)
+	}
+
+	// Expired entries are recomputed
+	time.Sleep(5 * time.Millisecond)
+	eng.Execute("batch-expired", func(ctx *Context) error {
+		_, err := fetchRates(ctx)
+		return err
+	})
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("expected recompute after expiry, got %d calls", n)
+	}
+}
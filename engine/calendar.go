@@ -0,0 +1,194 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ScheduleSpec computes the fire times of a schedule
+type ScheduleSpec interface {
+	// Next returns the first fire time strictly after t, or the zero time if none
+	Next(t time.Time) time.Time
+}
+
+// zonedSpec evaluates a spec in a fixed time zone
+type zonedSpec struct {
+	spec ScheduleSpec
+	loc  *time.Location
+}
+
+func (z zonedSpec) Next(t time.Time) time.Time {
+	return z.spec.Next(t.In(z.loc))
+}
+
+// ParseScheduleSpec builds the spec for a schedule from its cron or calendar
+// expression, evaluated in the named IANA time zone (UTC when empty)
+func ParseScheduleSpec(cron, calendar, timezone string) (ScheduleSpec, error) {
+	loc := time.UTC
+	if timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("invalid schedule timezone %q: %w", timezone, err)
+		}
+	}
+
+	var spec ScheduleSpec
+	switch {
+	case cron != "" && calendar != "":
+		return nil, fmt.Errorf("schedule must set either a cron or a calendar expression, not both")
+	case calendar != "":
+		c, err := ParseCalendar(calendar)
+		if err != nil {
+			return nil, err
+		}
+		spec = c
+	case cron != "":
+		c, err := ParseCron(cron)
+		if err != nil {
+			return nil, err
+		}
+		spec = c
+	default:
+		return nil, fmt.Errorf("schedule requires a cron or calendar expression")
+	}
+
+	return zonedSpec{spec: spec, loc: loc}, nil
+}
+
+// CalendarSpec fires once a month on a calendar-relative day, e.g.
+// "last business day of month at 18:00" or "first monday of month at 09:30"
+type CalendarSpec struct {
+	expr    string
+	ordinal int // 1-4, or -1 for last
+	unit    string
+	weekday time.Weekday
+	hour    int
+	minute  int
+}
+
+var calendarOrdinals = map[string]int{
+	"first": 1, "second": 2, "third": 3, "fourth": 4, "last": -1,
+}
+
+var calendarWeekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday,
+	"wednesday": time.Wednesday, "thursday": time.Thursday, "friday": time.Friday,
+	"saturday": time.Saturday,
+}
+
+// ParseCalendar parses "<first|second|third|fourth|last> <day|business day|weekday name> of month at HH:MM"
+func ParseCalendar(expr string) (*CalendarSpec, error) {
+	fields := strings.Fields(strings.ToLower(expr))
+	invalid := fmt.Errorf("invalid calendar expression %q: expected \"<first|last|...> <day|business day|monday...> of month at HH:MM\"", expr)
+	if len(fields) < 6 {
+		return nil, invalid
+	}
+
+	spec := &CalendarSpec{expr: expr}
+	ordinal, ok := calendarOrdinals[fields[0]]
+	if !ok {
+		return nil, invalid
+	}
+	spec.ordinal = ordinal
+
+	rest := fields[1:]
+	switch {
+	case rest[0] == "business" && len(rest) > 1 && rest[1] == "day":
+		spec.unit = "business day"
+		rest = rest[2:]
+	case rest[0] == "day":
+		spec.unit = "day"
+		rest = rest[1:]
+	default:
+		wd, ok := calendarWeekdays[rest[0]]
+		if !ok {
+			return nil, invalid
+		}
+		spec.unit = "weekday"
+		spec.weekday = wd
+		rest = rest[1:]
+	}
+
+	if len(rest) != 4 || rest[0] != "of" || rest[1] != "month" || rest[2] != "at" {
+		return nil, invalid
+	}
+	clock := strings.SplitN(rest[3], ":", 2)
+	if len(clock) != 2 {
+		return nil, invalid
+	}
+	var err error
+	if spec.hour, err = strconv.Atoi(clock[0]); err != nil || spec.hour < 0 || spec.hour > 23 {
+		return nil, invalid
+	}
+	if spec.minute, err = strconv.Atoi(clock[1]); err != nil || spec.minute < 0 || spec.minute > 59 {
+		return nil, invalid
+	}
+
+	return spec, nil
+}
+
+// String returns the original expression
+func (c *CalendarSpec) String() string {
+	return c.expr
+}
+
+// matchesUnit reports whether day d counts towards the ordinal
+func (c *CalendarSpec) matchesUnit(d time.Time) bool {
+	switch c.unit {
+	case "business day":
+		return d.Weekday() != time.Saturday && d.Weekday() != time.Sunday
+	case "weekday":
+		return d.Weekday() == c.weekday
+	default:
+		return true
+	}
+}
+
+// dayInMonth returns the matching day of the given month (0 if none)
+func (c *CalendarSpec) dayInMonth(year int, month time.Month) int {
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+
+	if c.ordinal < 0 {
+		for day := last; day >= 1; day-- {
+			if c.matchesUnit(time.Date(year, month, day, 0, 0, 0, 0, time.UTC)) {
+				return day
+			}
+		}
+		return 0
+	}
+
+	seen := 0
+	for day := 1; day <= last; day++ {
+		if c.matchesUnit(time.Date(year, month, day, 0, 0, 0, 0, time.UTC)) {
+			seen++
+			if seen == c.ordinal {
+				return day
+			}
+		}
+	}
+	return 0
+}
+
+// Next returns the first fire time strictly after t, in t's location
+func (c *CalendarSpec) Next(t time.Time) time.Time {
+	loc := t.Location()
+	year, month := t.Year(), t.Month()
+
+	for i := 0; i < 60; i++ {
+		if day := c.dayInMonth(year, month); day > 0 {
+			fire := wallToLocal(time.Date(year, month, day, c.hour, c.minute, 0, 0, time.UTC), loc)
+			if fire.After(t) {
+				return fire
+			}
+		}
+		month++
+		if month > time.December {
+			month = time.January
+			year++
+		}
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,176 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// ErrWorkflowCanceled is returned when a workflow was canceled before or while running
+var ErrWorkflowCanceled = errors.New("workflow canceled")
+
+// cancelPollInterval is how often a run checks whether another process
+// canceled or deleted it
+const cancelPollInterval = 500 * time.Millisecond
+
+// CancelWorkflow marks a queued or running workflow as canceled. A workflow
+// running in this engine starts no further steps and its retries are
+// abandoned; running step bodies see ctx.Done(). A run executing in another
+// process notices before its next step starts, or within cancelPollInterval.
+// Completed steps are kept.
+func (e *Engine) CancelWorkflow(workflowID string) error {
+	canceled, err := e.storage.CancelWorkflow(workflowID)
+	if err != nil {
+		return fmt.Errorf("failed to cancel workflow: %w", err)
+	}
+	if !canceled {
+		return nil
+	}
+
+	e.stopRunning(workflowID)
+	fmt.Printf("[CANCEL] workflow %s canceled\n", workflowID)
+	return nil
+}
+
+// stopRunning cancels the run of workflowID executing in this engine, if any
+func (e *Engine) stopRunning(workflowID string) {
+	e.runningMu.Lock()
+	ctx := e.running[workflowID]
+	e.runningMu.Unlock()
+	if ctx != nil {
+		ctx.stop()
+	}
+}
+
+// stop cancels the run: no further steps start and running bodies see ctx.Done()
+func (ctx *Context) stop() {
+	atomic.StoreInt32(&ctx.canceled, 1)
+	ctx.cancelRun()
+}
+
+// checkCanceled stops the run and returns ErrWorkflowCanceled if its row was
+// canceled or deleted, e.g. by another process that can't reach it directly.
+// A run that already knows it was canceled is left to run its cleanups.
+func (ctx *Context) checkCanceled(s *Storage) error {
+	if ctx.Canceled() {
+		return nil
+	}
+	status, err := s.GetWorkflowStatus(ctx.WorkflowID)
+	if errors.Is(err, ErrWorkflowNotFound) || status == "canceled" {
+		ctx.stop()
+		return ErrWorkflowCanceled
+	}
+	return err
+}
+
+// watchCancellation polls the run's status until it ends, so a cancellation
+// or deletion by another process also reaches step bodies already running
+func (e *Engine) watchCancellation(ctx *Context) {
+	ticker := time.NewTicker(cancelPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.runCtx.Done():
+			return
+		case <-e.stop:
+			return
+		case <-ticker.C:
+		}
+		if err := ctx.checkCanceled(ctx.storage); err != nil {
+			if !errors.Is(err, ErrWorkflowCanceled) {
+				fmt.Printf("[CANCEL] failed to check workflow %s: %v\n", ctx.WorkflowID, err)
+				continue
+			}
+			fmt.Printf("[CANCEL] workflow %s was canceled elsewhere\n", ctx.WorkflowID)
+			return
+		}
+	}
+}
+
+// Canceled reports whether the workflow has been canceled
+func (ctx *Context) Canceled() bool {
+	return atomic.LoadInt32(&ctx.canceled) == 1
+}
+
+// Context returns a context.Context that is canceled when the workflow is,
+// for step bodies to pass to the calls they make
+func (ctx *Context) Context() context.Context {
+	return ctx.cancellationContext()
+}
+
+// Done returns a channel that is closed when the workflow is canceled
+func (ctx *Context) Done() <-chan struct{} {
+	return ctx.cancellationContext().Done()
+}
+
+// stoppedStepError reports an error a step stopped with after its workflow
+// was canceled or passed its deadline as such, so it isn't retried and a
+// cancellation isn't recorded as a failure
+func (ctx *Context) stoppedStepError(err error) error {
+	if err == nil || errors.Is(err, ErrWorkflowCanceled) || errors.Is(err, ErrWorkflowTimeout) {
+		return err
+	}
+	if doneErr := ctx.doneErr(); doneErr != nil {
+		return fmt.Errorf("%w (step stopped with: %v)", doneErr, err)
+	}
+	return err
+}
+
+// failStep records a step's error, as canceled if the workflow was canceled
+func (ctx *Context) failStep(stepKey string, err error) {
+	if errors.Is(err, ErrWorkflowCanceled) {
+		ctx.storage.SaveStepCanceled(ctx.WorkflowID, stepKey)
+		return
+	}
+	ctx.storage.SaveStepError(ctx.WorkflowID, stepKey, err.Error())
+}
+
+// trackRunning registers a context so CancelWorkflow can reach it
+func (e *Engine) trackRunning(ctx *Context) func() {
+	e.runningMu.Lock()
+	if e.running == nil {
+		e.running = make(map[string]*Context)
+	}
+	e.running[ctx.WorkflowID] = ctx
+	e.runningMu.Unlock()
+
+	return func() {
+		e.runningMu.Lock()
+		delete(e.running, ctx.WorkflowID)
+		e.runningMu.Unlock()
+	}
+}
+
+// CancelWorkflow flips a queued, running or interrupted workflow to canceled.
+// It reports false if the workflow was already terminal.
+func (s *Storage) CancelWorkflow(workflowID string) (bool, error) {
+	var affected int64
+	err := s.retryOnBusy(func() error {
+		res, err := s.db.Exec(
+			`UPDATE workflows SET status = 'canceled', version = version + 1, updated_at = ?
+			 WHERE workflow_id = ? AND status IN ('queued', 'running', 'interrupted')`,
+			dbNow(), workflowID,
+		)
+		if err != nil {
+			return err
+		}
+		affected, err = res.RowsAffected()
+		return err
+	})
+	return affected > 0, err
+}
+
+// SaveStepCanceled records that a step stopped because its workflow was canceled
+func (s *Storage) SaveStepCanceled(workflowID, stepKey string) error {
+	return s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			`UPDATE steps
+			 SET status = 'canceled', error = ?, completed_at = ?
+			 WHERE workflow_id = ? AND step_key = ?`,
+			ErrWorkflowCanceled.Error(), dbNow(), workflowID, stepKey,
+		)
+		return err
+	})
+}
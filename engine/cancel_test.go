@@ -0,0 +1,201 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCancelStopsInFlightStep(t *testing.T) {
+	dbPath := "./test_cancel.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	started := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- eng.Execute("cancel-1", func(ctx *Context) error {
+			_, err := Step(ctx, "export", func() (int, error) {
+				close(started)
+				<-ctx.Done()
+				return 0, ctx.Context().Err()
+			}, WithRetry(RetryPolicy{MaxAttempts: 5, InitialInterval: time.Millisecond}))
+			return err
+		})
+	}()
+
+	<-started
+	if err := eng.CancelWorkflow("cancel-1"); err != nil {
+		t.Fatalf("cancel failed: %v", err)
+	}
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrWorkflowCanceled) {
+			t.Errorf("expected ErrWorkflowCanceled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("step body never saw the cancellation")
+	}
+
+	steps, _ := eng.storage.ListSteps("cancel-1")
+	if len(steps) != 1 || steps[0].Status != "canceled" {
+		t.Fatalf("expected one canceled step, got %+v", steps)
+	}
+	if len(steps[0].Attempts) != 1 || steps[0].Attempts[0].Status != "canceled" {
+		t.Errorf("expected a single canceled attempt, got %+v", steps[0].Attempts)
+	}
+}
+
+func TestCancelAbandonsPendingRetries(t *testing.T) {
+	dbPath := "./test_cancel_retry.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	var attempts int32
+	done := make(chan error, 1)
+	go func() {
+		done <- eng.Execute("cancel-2", func(ctx *Context) error {
+			_, err := Step(ctx, "flaky", func() (int, error) {
+				atomic.AddInt32(&attempts, 1)
+				return 0, errors.New("unavailable")
+			}, WithRetry(RetryPolicy{MaxAttempts: 10, InitialInterval: time.Hour}))
+			return err
+		})
+	}()
+
+	// Cancel while the step sleeps before its second attempt
+	for atomic.LoadInt32(&attempts) == 0 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	eng.CancelWorkflow("cancel-2")
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrWorkflowCanceled) {
+			t.Errorf("expected ErrWorkflowCanceled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("retry backoff ignored the cancellation")
+	}
+	if attempts != 1 {
+		t.Errorf("expected no further attempts, got %d", attempts)
+	}
+
+	steps, _ := eng.storage.ListSteps("cancel-2")
+	if len(steps) != 1 || steps[0].Status != "canceled" {
+		t.Fatalf("expected step canceled, got %+v", steps)
+	}
+	if a := steps[0].Attempts; len(a) != 1 || a[0].Status != "failed" {
+		t.Errorf("expected the failed attempt to stay failed, got %+v", a)
+	}
+}
+
+func TestCancelFromAnotherProcess(t *testing.T) {
+	dbPath := "./test_cancel_remote.db"
+	defer os.Remove(dbPath)
+
+	worker, err := NewEngine(dbPath, WithWorkerID("worker"))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer worker.Close()
+	admin, err := NewEngine(dbPath, WithWorkerID("admin"))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer admin.Close()
+
+	run := func(workflowID string) (chan struct{}, chan error) {
+		started := make(chan struct{})
+		done := make(chan error, 1)
+		go func() {
+			done <- worker.Execute(workflowID, func(ctx *Context) error {
+				_, err := Step(ctx, "export", func() (int, error) {
+					close(started)
+					<-ctx.Done()
+					return 0, ctx.Context().Err()
+				})
+				return err
+			})
+		}()
+		return started, done
+	}
+
+	// The admin engine can't reach the run directly; the worker notices the status
+	started, done := run("remote-cancel")
+	<-started
+	if err := admin.CancelWorkflow("remote-cancel"); err != nil {
+		t.Fatalf("cancel failed: %v", err)
+	}
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrWorkflowCanceled) {
+			t.Errorf("expected ErrWorkflowCanceled, got %v", err)
+		}
+	case <-time.After(5 * cancelPollInterval):
+		t.Fatal("running step never saw the cancellation from the other engine")
+	}
+	if status, _ := admin.GetWorkflowStatus("remote-cancel"); status != "canceled" {
+		t.Errorf("expected canceled, got %s", status)
+	}
+
+	started, done = run("remote-delete")
+	<-started
+	if err := admin.DeleteWorkflow("remote-delete", DeleteOptions{}); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrWorkflowCanceled) {
+			t.Errorf("expected ErrWorkflowCanceled, got %v", err)
+		}
+	case <-time.After(5 * cancelPollInterval):
+		t.Fatal("running step never saw the deletion from the other engine")
+	}
+}
+
+func TestCanceledRunStartsNoFurtherSteps(t *testing.T) {
+	dbPath := "./test_cancel_step.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	var second bool
+	err = eng.Execute("cancel-between", func(ctx *Context) error {
+		if _, err := Step(ctx, "first", func() (int, error) {
+			// Cancel through storage only, as another process would
+			_, err := eng.storage.CancelWorkflow("cancel-between")
+			return 1, err
+		}); err != nil {
+			return err
+		}
+		_, err := Step(ctx, "second", func() (int, error) {
+			second = true
+			return 2, nil
+		})
+		return err
+	})
+	if !errors.Is(err, ErrWorkflowCanceled) {
+		t.Fatalf("expected ErrWorkflowCanceled, got %v", err)
+	}
+	if second {
+		t.Error("a step started after the workflow was canceled")
+	}
+}
@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// WithOnComplete durably chains a registered workflow to start when this one
+// completes. The continuation receives the same input and runs as
+// "<workflowID>-then-<workflowName>". It may be given more than once.
+func WithOnComplete(workflowName string) WorkflowOption {
+	return func(o *workflowOptions) {
+		o.onComplete = append(o.onComplete, workflowName)
+	}
+}
+
+// ContinuationWorkflowID returns the deterministic ID of a chained workflow
+func ContinuationWorkflowID(workflowID, next string) string {
+	return fmt.Sprintf("%s-then-%s", workflowID, next)
+}
+
+// continuation is a chained workflow not yet started
+type continuation struct {
+	position int
+	name     string
+}
+
+// startContinuations enqueues every continuation of a completed workflow that
+// has not been started yet, writing through s, and returns the IDs started.
+// A run completes in the same transaction (see completeWorkflow), so its
+// continuations can't be lost to a crash; enqueueing is idempotent on the
+// deterministic ID for runs found completed with some still pending.
+func (e *Engine) startContinuations(s *Storage, workflowID string) ([]string, error) {
+	pending, err := s.PendingContinuations(workflowID)
+	if err != nil {
+		return nil, err
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	input, err := s.GetWorkflowInput(workflowID)
+	if err != nil {
+		return nil, err
+	}
+
+	var started []string
+	for _, c := range pending {
+		nextID := ContinuationWorkflowID(workflowID, c.name)
+		if err := e.enqueueContinuation(s, nextID, c.name, input); err != nil {
+			return nil, fmt.Errorf("failed to start continuation %s: %w", nextID, err)
+		}
+		if err := s.MarkContinuationStarted(workflowID, c.position, nextID); err != nil {
+			return nil, fmt.Errorf("failed to record continuation %s: %w", nextID, err)
+		}
+		started = append(started, nextID)
+	}
+
+	return started, nil
+}
+
+// logContinuations reports the continuations started for a workflow
+func logContinuations(workflowID string, started []string) {
+	for _, id := range started {
+		fmt.Printf("[CHAIN] %s completed, started %s\n", workflowID, id)
+	}
+}
+
+// enqueueContinuation queues a continuation unless it already exists.
+// Unlike Enqueue it doesn't wait for admission (see WithMaxPendingWorkflows): the
+// continuation is part of completing an admitted run, and the transaction
+// completing it can't wait for the queue to drain.
+func (e *Engine) enqueueContinuation(s *Storage, workflowID, workflowName string, input []byte) error {
+	if _, err := s.GetWorkflowStatus(workflowID); err == nil {
+		return nil
+	} else if !errors.Is(err, ErrWorkflowNotFound) {
+		return fmt.Errorf("failed to check workflow: %w", err)
+	}
+	if err := e.validateInput(workflowName, input); err != nil {
+		return err
+	}
+	shard := ShardForWorkflow(workflowID, e.shardCount)
+	if err := s.CreateQueuedWorkflow(workflowID, shard, workflowName, DefaultQueue, 0, "", input); err != nil {
+		return fmt.Errorf("failed to enqueue workflow: %w", err)
+	}
+	return nil
+}
+
+// AddContinuations records the workflows to start when workflowID completes
+func (s *Storage) AddContinuations(workflowID string, names []string) error {
+	return s.retryOnBusy(func() error {
+		for i, name := range names {
+			if _, err := s.db.Exec(
+				`INSERT OR IGNORE INTO continuations (workflow_id, position, next_workflow_name)
+				 VALUES (?, ?, ?)`,
+				workflowID, i, name,
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// PendingContinuations returns continuations of workflowID not yet started
+func (s *Storage) PendingContinuations(workflowID string) ([]continuation, error) {
+	rows, err := s.db.Query(
+		`SELECT position, next_workflow_name FROM continuations
+		 WHERE workflow_id = ? AND started_workflow_id IS NULL ORDER BY position`,
+		workflowID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load continuations: %w", err)
+	}
+	defer rows.Close()
+
+	var pending []continuation
+	for rows.Next() {
+		var c continuation
+		if err := rows.Scan(&c.position, &c.name); err != nil {
+			return nil, fmt.Errorf("failed to scan continuation: %w", err)
+		}
+		pending = append(pending, c)
+	}
+
+	return pending, rows.Err()
+}
+
+// MarkContinuationStarted records the workflow started for a continuation
+func (s *Storage) MarkContinuationStarted(workflowID string, position int, startedID string) error {
+	return s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			`UPDATE continuations SET started_workflow_id = ?
+			 WHERE workflow_id = ? AND position = ? AND started_workflow_id IS NULL`,
+			startedID, workflowID, position,
+		)
+		return err
+	})
+}
+
+// GetContinuations returns the names and started IDs of a workflow's continuations
+func (s *Storage) GetContinuations(workflowID string) (map[string]string, error) {
+	rows, err := s.db.Query(
+		"SELECT next_workflow_name, started_workflow_id FROM continuations WHERE workflow_id = ?",
+		workflowID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load continuations: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string]string)
+	for rows.Next() {
+		var name string
+		var started sql.NullString
+		if err := rows.Scan(&name, &started); err != nil {
+			return nil, fmt.Errorf("failed to scan continuation: %w", err)
+		}
+		result[name] = started.String
+	}
+
+	return result, rows.Err()
+}
@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWorkflowChaining(t *testing.T) {
+	dbPath := "./test_chain.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	type Order struct {
+		ID string
+	}
+	invoiced := make(chan string, 1)
+	eng.Register("generate-invoice", func(ctx *Context) error {
+		var order Order
+		if err := ctx.Input(&order); err != nil {
+			return err
+		}
+		invoiced <- order.ID
+		return nil
+	})
+	eng.Register("fulfil-order", func(ctx *Context) error {
+		_, err := Step(ctx, "ship", func() (string, error) { return "SHIPPED", nil })
+		return err
+	})
+
+	if err := eng.Enqueue("order-7", "fulfil-order", Order{ID: "7"}, WithOnComplete("generate-invoice")); err != nil {
+		t.Fatalf("failed to enqueue: %v", err)
+	}
+	if err := eng.StartWorker(WorkerConfig{Capacity: 2, PollInterval: 10 * time.Millisecond}); err != nil {
+		t.Fatalf("failed to start worker: %v", err)
+	}
+
+	select {
+	case id := <-invoiced:
+		if id != "7" {
+			t.Errorf("expected continuation to receive the order input, got %q", id)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("continuation never ran")
+	}
+
+	started, err := eng.storage.GetContinuations("order-7")
+	if err != nil {
+		t.Fatalf("failed to load continuations: %v", err)
+	}
+	if started["generate-invoice"] != ContinuationWorkflowID("order-7", "generate-invoice") {
+		t.Errorf("expected continuation to be recorded as started, got %v", started)
+	}
+}
+
+func TestStartChildDetachedOnce(t *testing.T) {
+	dbPath := "./test_child.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	runs := 0
+	parent := func(ctx *Context) error {
+		runs++
+		if _, err := ctx.StartChildDetached("welcome-email", "send-email", map[string]string{"to": "a@example.com"}); err != nil {
+			return err
+		}
+		// Simulate a crash right after starting the child on the first run
+		if runs == 1 {
+			return os.ErrDeadlineExceeded
+		}
+		return nil
+	}
+
+	eng.Execute("onboard-1", parent)
+	if err := eng.Execute("onboard-1", parent); err != nil {
+		t.Fatalf("resumed parent failed: %v", err)
+	}
+
+	children, err := eng.ListChildren("onboard-1")
+	if err != nil {
+		t.Fatalf("failed to list children: %v", err)
+	}
+	if len(children) != 1 || children[0] != "onboard-1/welcome-email" {
+		t.Fatalf("expected exactly one child, got %v", children)
+	}
+	if status, _ := eng.GetWorkflowStatus(children[0]); status != "queued" {
+		t.Errorf("expected detached child to be queued, got %s", status)
+	}
+}
+
+func TestEnqueueRecordsOptionsWithTheRow(t *testing.T) {
+	dbPath := "./test_enqueue_atomic.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	// Recording the continuation fails, so the run must not be enqueued without it
+	eng.storage.db.Exec("DROP TABLE continuations")
+	if err := eng.Enqueue("order-1", "place-order", nil, WithOnComplete("ship-order")); err == nil {
+		t.Fatal("expected enqueue to fail")
+	}
+	if _, err := eng.storage.GetWorkflowStatus("order-1"); !errors.Is(err, ErrWorkflowNotFound) {
+		t.Errorf("expected no workflow row, got %v", err)
+	}
+}
+
+func TestStartChildDetachedLeavesCallerOptions(t *testing.T) {
+	dbPath := "./test_child_opts.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	// Spare capacity the child's options must not be written into
+	opts := make([]WorkflowOption, 1, 4)
+	opts[0] = WithQueue("emails")
+	err = eng.Execute("onboard-2", func(ctx *Context) error {
+		_, err := ctx.StartChildDetached("welcome-email", "send-email", nil, opts...)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("parent failed: %v", err)
+	}
+	if spare := opts[:cap(opts)]; spare[1] != nil || spare[2] != nil {
+		t.Error("StartChildDetached appended into the caller's options")
+	}
+}
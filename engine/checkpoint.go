@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Checkpoint durably records partial progress of a running step, so a retry
+// or a resume after a crash can continue from it with LastCheckpoint instead
+// of starting over. Each call replaces the previous checkpoint; it is
+// discarded once the step completes.
+func (ctx *Context) Checkpoint(stepID string, state interface{}) error {
+	data, err := ctx.engine.codec.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint for %s: %w", stepID, err)
+	}
+	seqNum, err := ctx.stepSequence(stepID)
+	if err != nil {
+		return err
+	}
+	stepKey := generateStepKey(stepID, seqNum)
+	if err := ctx.storage.SaveCheckpoint(ctx.WorkflowID, stepKey, data); err != nil {
+		return fmt.Errorf("failed to save checkpoint for %s: %w", stepID, err)
+	}
+	return nil
+}
+
+// LastCheckpoint decodes the step's most recent checkpoint into state and
+// reports whether there was one
+func (ctx *Context) LastCheckpoint(stepID string, state interface{}) (bool, error) {
+	seqNum, err := ctx.stepSequence(stepID)
+	if err != nil {
+		return false, err
+	}
+	stepKey := generateStepKey(stepID, seqNum)
+	data, found, err := ctx.storage.LoadCheckpoint(ctx.WorkflowID, stepKey)
+	if err != nil || !found {
+		return false, err
+	}
+	if err := ctx.engine.codec.Unmarshal(data, state); err != nil {
+		return false, fmt.Errorf("failed to unmarshal checkpoint for %s: %w", stepID, err)
+	}
+	ctx.printf("[CHECKPOINT] %s resuming from checkpoint\n", stepID)
+	return true, nil
+}
+
+// initCheckpoints drops a step's checkpoint once the step completes
+func (s *Storage) initCheckpoints() error {
+	_, err := s.db.Exec(`
+	CREATE TRIGGER IF NOT EXISTS steps_checkpoint_complete AFTER UPDATE OF status ON steps
+	WHEN new.status = 'completed' BEGIN
+		DELETE FROM step_checkpoints WHERE workflow_id = new.workflow_id AND step_key = new.step_key;
+	END;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create checkpoint trigger: %w", err)
+	}
+	return nil
+}
+
+// SaveCheckpoint replaces the checkpoint of a step
+func (s *Storage) SaveCheckpoint(workflowID, stepKey string, state []byte) error {
+	return s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			`INSERT INTO step_checkpoints (workflow_id, step_key, state, updated_at)
+			 VALUES (?, ?, ?, ?)
+			 ON CONFLICT(workflow_id, step_key) DO UPDATE SET
+			   state = excluded.state, updated_at = excluded.updated_at`,
+			workflowID, stepKey, state, dbNow(),
+		)
+		return err
+	})
+}
+
+// LoadCheckpoint returns the checkpoint of a step, if it has one
+func (s *Storage) LoadCheckpoint(workflowID, stepKey string) ([]byte, bool, error) {
+	var state []byte
+	err := s.db.QueryRow(
+		"SELECT state FROM step_checkpoints WHERE workflow_id = ? AND step_key = ?",
+		workflowID, stepKey,
+	).Scan(&state)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+	return state, true, nil
+}
@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+type exportProgress struct {
+	Record int
+}
+
+func TestCheckpointResumesRetriedStep(t *testing.T) {
+	dbPath := "./test_checkpoint.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	var starts []int
+	err = eng.Execute("export-1", func(ctx *Context) error {
+		_, err := Step(ctx, "export", func() (int, error) {
+			var p exportProgress
+			if _, err := ctx.LastCheckpoint("export", &p); err != nil {
+				return 0, err
+			}
+			starts = append(starts, p.Record)
+
+			for r := p.Record; r < 1000; r += 100 {
+				if err := ctx.Checkpoint("export", exportProgress{Record: r}); err != nil {
+					return 0, err
+				}
+				if r == 800 && len(starts) == 1 {
+					return 0, errors.New("connection reset")
+				}
+			}
+			return 1000, nil
+		}, WithRetry(RetryPolicy{MaxAttempts: 2, InitialInterval: time.Millisecond}))
+		return err
+	})
+	if err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+
+	if len(starts) != 2 || starts[0] != 0 || starts[1] != 800 {
+		t.Errorf("expected the retry to resume from record 800, got starts %v", starts)
+	}
+
+	// Completing the step discards its checkpoint
+	if _, found, _ := eng.storage.LoadCheckpoint("export-1", "export:1"); found {
+		t.Error("expected checkpoint to be removed once the step completed")
+	}
+}
+
+func TestCheckpointSurvivesResume(t *testing.T) {
+	dbPath := "./test_checkpoint_resume.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	var resumedFrom int
+	crashed := false
+	workflow := func(ctx *Context) error {
+		_, err := Step(ctx, "export", func() (int, error) {
+			var p exportProgress
+			found, err := ctx.LastCheckpoint("export", &p)
+			if err != nil {
+				return 0, err
+			}
+			if found {
+				resumedFrom = p.Record
+			}
+			if !crashed {
+				crashed = true
+				ctx.Checkpoint("export", exportProgress{Record: 500})
+				return 0, errors.New("process killed")
+			}
+			return p.Record, nil
+		})
+		return err
+	}
+
+	if err := eng.Execute("export-2", workflow); err == nil {
+		t.Fatal("expected first run to fail")
+	}
+	eng.storage.db.Exec("UPDATE workflows SET status = 'running' WHERE workflow_id = 'export-2'")
+	if err := eng.Execute("export-2", workflow); err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+	if resumedFrom != 500 {
+		t.Errorf("expected resume from record 500, got %d", resumedFrom)
+	}
+}
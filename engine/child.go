@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"fmt"
+)
+
+// withParent links a started workflow to the workflow that started it
+func withParent(parentID string) WorkflowOption {
+	return func(o *workflowOptions) {
+		o.parentID = parentID
+	}
+}
+
+// ChildWorkflowID returns the ID a child started by parentID under childID runs as
+func ChildWorkflowID(parentID, childID string) string {
+	return fmt.Sprintf("%s/%s", parentID, childID)
+}
+
+// StartChildDetached durably starts a registered workflow as a fire-and-forget
+// child and returns its workflow ID without waiting for it. The start is
+// recorded as a step, so the child is started exactly once even if the parent
+// crashes and replays. Children are started by registered name rather than by
+// closure so that any worker can run (and resume) them. Children inherit the
+// parent's headers unless opts set them.
+func (ctx *Context) StartChildDetached(childID, workflowName string, input interface{}, opts ...WorkflowOption) (string, error) {
+	workflowID := ChildWorkflowID(ctx.WorkflowID, childID)
+
+	return Step(ctx, "start-child:"+childID, func() (string, error) {
+		// Cap opts so the append can't write into the caller's backing array
+		childOpts := append(opts[:len(opts):len(opts)], withParent(ctx.WorkflowID), withHeaders(ctx.headers))
+		if err := ctx.engine.Enqueue(workflowID, workflowName, input, childOpts...); err != nil {
+			return "", fmt.Errorf("failed to start child %s: %w", workflowID, err)
+		}
+		return workflowID, nil
+	}, withStepKind(StepKindChildStart))
+}
+
+// ListChildren returns the IDs of workflows started by parentID
+func (e *Engine) ListChildren(parentID string) ([]string, error) {
+	return e.storage.ListChildWorkflows(parentID)
+}
+
+// SetWorkflowParent records the parent of a workflow
+func (s *Storage) SetWorkflowParent(workflowID, parentID string) error {
+	return s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			"UPDATE workflows SET parent_id = ? WHERE workflow_id = ?",
+			parentID, workflowID,
+		)
+		return err
+	})
+}
+
+// ListChildWorkflows returns the workflows whose parent is parentID, oldest first
+func (s *Storage) ListChildWorkflows(parentID string) ([]string, error) {
+	rows, err := s.db.Query(
+		"SELECT workflow_id FROM workflows WHERE parent_id = ? ORDER BY created_at, workflow_id",
+		parentID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list child workflows: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan child workflow: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
@@ -0,0 +1,261 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by Step when the circuit for its step ID is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// CircuitBreakerConfig controls per-step-ID circuit breaking.
+// A circuit opens when at least MinRequests attempts were made within Window
+// and the failure ratio reached FailureRatio. After OpenDuration it half-opens
+// and lets HalfOpenProbes attempts through; a successful probe closes it.
+// Attempts abandoned because the run was canceled or timed out, or the step
+// timed out, say nothing about the dependency and are not counted.
+type CircuitBreakerConfig struct {
+	FailureRatio   float64
+	MinRequests    int
+	Window         time.Duration
+	OpenDuration   time.Duration
+	HalfOpenProbes int
+	// ProbeTimeout is how long a half-open probe may run before it counts as
+	// failed and the circuit re-opens; defaults to OpenDuration
+	ProbeTimeout time.Duration
+	// DelayWhenOpen makes steps wait for the half-open probe window instead of failing fast
+	DelayWhenOpen bool
+}
+
+// WithCircuitBreaker enables engine-wide circuit breaking keyed by step ID
+func WithCircuitBreaker(cfg CircuitBreakerConfig) EngineOption {
+	return func(e *Engine) {
+		if cfg.FailureRatio <= 0 {
+			cfg.FailureRatio = 0.5
+		}
+		if cfg.MinRequests <= 0 {
+			cfg.MinRequests = 10
+		}
+		if cfg.Window <= 0 {
+			cfg.Window = time.Minute
+		}
+		if cfg.OpenDuration <= 0 {
+			cfg.OpenDuration = 30 * time.Second
+		}
+		if cfg.HalfOpenProbes <= 0 {
+			cfg.HalfOpenProbes = 1
+		}
+		if cfg.ProbeTimeout <= 0 {
+			cfg.ProbeTimeout = cfg.OpenDuration
+		}
+		e.breakers = &circuitBreakers{cfg: cfg, circuits: make(map[string]*circuit)}
+	}
+}
+
+// CircuitState is the state of a single step circuit
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half_open"
+)
+
+type outcome struct {
+	at     time.Time
+	failed bool
+}
+
+type circuit struct {
+	state    CircuitState
+	openedAt time.Time
+	probes   int
+	probedAt time.Time // when the latest half-open probe was let through
+	outcomes []outcome
+}
+
+type circuitBreakers struct {
+	cfg       CircuitBreakerConfig
+	mu        sync.Mutex
+	circuits  map[string]*circuit
+	lastSweep time.Time
+}
+
+// allow decides whether an attempt of stepID may run now.
+// It returns how long to wait before asking again when the circuit is open.
+func (b *circuitBreakers) allow(stepID string, now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuits[stepID]
+	if c == nil {
+		return true, 0
+	}
+
+	switch c.state {
+	case CircuitOpen:
+		if remaining := c.openedAt.Add(b.cfg.OpenDuration).Sub(now); remaining > 0 {
+			return false, remaining
+		}
+		c.state = CircuitHalfOpen
+		c.probes = 0
+		fallthrough
+	case CircuitHalfOpen:
+		if c.probes >= b.cfg.HalfOpenProbes {
+			if now.Sub(c.probedAt) < b.cfg.ProbeTimeout {
+				return false, c.probedAt.Add(b.cfg.ProbeTimeout).Sub(now)
+			}
+			// The probes never reported back; treat them as failed
+			c.state = CircuitOpen
+			c.openedAt = now
+			fmt.Printf("[CIRCUIT] %s probe timed out, circuit re-opened\n", stepID)
+			return false, b.cfg.OpenDuration
+		}
+		c.probes++
+		c.probedAt = now
+		return true, 0
+	}
+	return true, 0
+}
+
+// record feeds an attempt result into the circuit for stepID
+func (b *circuitBreakers) record(stepID string, failed bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.sweep(now)
+	c := b.circuits[stepID]
+	if c == nil {
+		c = &circuit{state: CircuitClosed}
+		b.circuits[stepID] = c
+	}
+
+	if c.state == CircuitHalfOpen {
+		if failed {
+			c.state = CircuitOpen
+			c.openedAt = now
+			fmt.Printf("[CIRCUIT] %s probe failed, circuit re-opened\n", stepID)
+		} else {
+			c.state = CircuitClosed
+			c.outcomes = nil
+			fmt.Printf("[CIRCUIT] %s probe succeeded, circuit closed\n", stepID)
+		}
+		return
+	}
+
+	// Drop outcomes that fell out of the window
+	cutoff := now.Add(-b.cfg.Window)
+	kept := c.outcomes[:0]
+	for _, o := range c.outcomes {
+		if o.at.After(cutoff) {
+			kept = append(kept, o)
+		}
+	}
+	c.outcomes = append(kept, outcome{at: now, failed: failed})
+
+	if c.state != CircuitClosed || len(c.outcomes) < b.cfg.MinRequests {
+		return
+	}
+
+	failures := 0
+	for _, o := range c.outcomes {
+		if o.failed {
+			failures++
+		}
+	}
+	if float64(failures)/float64(len(c.outcomes)) >= b.cfg.FailureRatio {
+		fmt.Printf("[CIRCUIT] %s opened after %d/%d failures\n", stepID, failures, len(c.outcomes))
+		c.state = CircuitOpen
+		c.openedAt = now
+		c.outcomes = nil
+	}
+}
+
+// release returns a half-open probe whose attempt was abandoned, so another
+// attempt can probe in its place
+func (b *circuitBreakers) release(stepID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c := b.circuits[stepID]; c != nil && c.state == CircuitHalfOpen && c.probes > 0 {
+		c.probes--
+	}
+}
+
+// sweep drops closed circuits with no outcome left in the window, at most
+// once per window, so step IDs that stopped running don't accumulate
+func (b *circuitBreakers) sweep(now time.Time) {
+	if now.Sub(b.lastSweep) < b.cfg.Window {
+		return
+	}
+	b.lastSweep = now
+	cutoff := now.Add(-b.cfg.Window)
+	for stepID, c := range b.circuits {
+		if c.state != CircuitClosed {
+			continue
+		}
+		if n := len(c.outcomes); n == 0 || !c.outcomes[n-1].at.After(cutoff) {
+			delete(b.circuits, stepID)
+		}
+	}
+}
+
+// state returns the current state of the circuit for stepID
+func (b *circuitBreakers) state(stepID string) CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c := b.circuits[stepID]; c != nil {
+		return c.state
+	}
+	return CircuitClosed
+}
+
+// CircuitState reports the breaker state for a step ID (closed if breaking is disabled)
+func (e *Engine) CircuitState(stepID string) CircuitState {
+	if e.breakers == nil {
+		return CircuitClosed
+	}
+	return e.breakers.state(stepID)
+}
+
+// awaitCircuit blocks or fails fast according to the breaker for stepID.
+// A wait ends early when the run is canceled or times out, or stop is stopped.
+func (e *Engine) awaitCircuit(ctx *Context, stop *stepStop, stepID string) error {
+	if e.breakers == nil {
+		return nil
+	}
+
+	for {
+		ok, wait := e.breakers.allow(stepID, time.Now())
+		if ok {
+			return nil
+		}
+		if !e.breakers.cfg.DelayWhenOpen {
+			return fmt.Errorf("%w for step %s (retry in %v)", ErrCircuitOpen, stepID, wait.Round(time.Millisecond))
+		}
+
+		select {
+		case <-e.stop:
+			return fmt.Errorf("%w for step %s", ErrCircuitOpen, stepID)
+		case <-ctx.Done():
+			return ctx.doneErr()
+		case <-stop.done():
+			return stop.stopped()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// recordCircuit reports a step attempt outcome to the breaker. Abandoned
+// attempts are not outcomes: their probe, if they were one, is released.
+func (e *Engine) recordCircuit(stepID string, err error, abandoned bool) {
+	if e.breakers == nil {
+		return
+	}
+	if abandoned {
+		e.breakers.release(stepID)
+		return
+	}
+	e.breakers.record(stepID, err != nil, time.Now())
+}
@@ -0,0 +1,156 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	dbPath := "./test_circuit.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath, WithCircuitBreaker(CircuitBreakerConfig{
+		FailureRatio: 0.9,
+		MinRequests:  3,
+		Window:       time.Minute,
+		OpenDuration: 50 * time.Millisecond,
+	}))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	calls := 0
+	healthy := false
+	charge := func(workflowID string) error {
+		return eng.Execute(workflowID, func(ctx *Context) error {
+			_, err := Step(ctx, "process-payment", func() (string, error) {
+				calls++
+				if !healthy {
+					return "", errors.New("payment provider down")
+				}
+				return "CHARGED", nil
+			})
+			return err
+		})
+	}
+
+	for i := 0; i < 3; i++ {
+		charge(fmt.Sprintf("order-%d", i))
+	}
+	if eng.CircuitState("process-payment") != CircuitOpen {
+		t.Fatalf("expected circuit to open, got %s", eng.CircuitState("process-payment"))
+	}
+
+	// While open, attempts fail fast without calling the provider
+	if err := charge("order-open"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected no calls while open, got %d", calls)
+	}
+
+	// After the open duration a successful probe closes the circuit
+	healthy = true
+	time.Sleep(60 * time.Millisecond)
+	if err := charge("order-probe"); err != nil {
+		t.Fatalf("expected probe to succeed, got %v", err)
+	}
+	if eng.CircuitState("process-payment") != CircuitClosed {
+		t.Errorf("expected circuit to close, got %s", eng.CircuitState("process-payment"))
+	}
+}
+
+func TestCircuitBreakerIgnoresAbandonedAttempts(t *testing.T) {
+	dbPath := "./test_circuit_abandoned.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath, WithCircuitBreaker(CircuitBreakerConfig{
+		FailureRatio:  0.5,
+		MinRequests:   2,
+		OpenDuration:  time.Hour,
+		DelayWhenOpen: true,
+	}))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	// Step timeouts stop the attempt; they don't count as failures
+	for i := 0; i < 2; i++ {
+		err := eng.Execute(fmt.Sprintf("slow-%d", i), func(ctx *Context) error {
+			_, err := Step(ctx, "report", func() (int, error) {
+				time.Sleep(100 * time.Millisecond)
+				return 1, nil
+			}, WithStepTimeout(20*time.Millisecond))
+			return err
+		})
+		if !errors.Is(err, ErrStepTimeout) {
+			t.Fatalf("expected ErrStepTimeout, got %v", err)
+		}
+	}
+	if state := eng.CircuitState("report"); state != CircuitClosed {
+		t.Fatalf("expected timed out attempts to leave the circuit closed, got %s", state)
+	}
+
+	// A run waiting for an open circuit stops waiting when it times out
+	eng.breakers.record("report", true, time.Now())
+	eng.breakers.record("report", true, time.Now())
+	started := time.Now()
+	err = eng.Execute("waiting-1", func(ctx *Context) error {
+		_, err := Step(ctx, "report", func() (int, error) { return 1, nil })
+		return err
+	}, WithWorkflowTimeout(50*time.Millisecond))
+	if !errors.Is(err, ErrWorkflowTimeout) {
+		t.Fatalf("expected ErrWorkflowTimeout, got %v", err)
+	}
+	if waited := time.Since(started); waited > time.Second {
+		t.Errorf("expected the wait to end with the run, waited %v", waited)
+	}
+}
+
+func TestCircuitProbeTimeoutAndEviction(t *testing.T) {
+	b := &circuitBreakers{
+		cfg: CircuitBreakerConfig{
+			FailureRatio: 0.5, MinRequests: 1, Window: time.Minute,
+			OpenDuration: time.Second, HalfOpenProbes: 1, ProbeTimeout: 2 * time.Second,
+		},
+		circuits: make(map[string]*circuit),
+	}
+	now := time.Now()
+	b.record("a", true, now)
+
+	if ok, _ := b.allow("a", now.Add(time.Second)); !ok {
+		t.Fatal("expected a probe once the circuit half-opens")
+	}
+	if ok, _ := b.allow("a", now.Add(2*time.Second)); ok {
+		t.Fatal("expected no second probe while the first runs")
+	}
+
+	// A probe that never reports back re-opens the circuit
+	if ok, _ := b.allow("a", now.Add(3*time.Second+time.Millisecond)); ok || b.state("a") != CircuitOpen {
+		t.Fatalf("expected the lost probe to re-open the circuit, got %s", b.state("a"))
+	}
+
+	// An abandoned probe is released for the next attempt
+	if ok, _ := b.allow("a", now.Add(5*time.Second)); !ok {
+		t.Fatal("expected a probe after re-opening")
+	}
+	b.release("a")
+	if ok, _ := b.allow("a", now.Add(5*time.Second)); !ok {
+		t.Fatal("expected the released probe to be taken again")
+	}
+
+	// Closed circuits with no recent outcomes are evicted
+	b.record("b", false, now)
+	b.record("c", false, now.Add(2*time.Minute))
+	if _, ok := b.circuits["b"]; ok {
+		t.Error("expected the idle circuit to be evicted")
+	}
+	if _, ok := b.circuits["a"]; !ok {
+		t.Error("expected the half-open circuit to be kept")
+	}
+}
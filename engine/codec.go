@@ -0,0 +1,338 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Codec encodes the data the engine persists on behalf of workflows: step
+// results, start inputs, workflow outputs and signal payloads. Schema
+// validation, readable history diffs and the DSL require a JSON codec.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// FieldNaming selects how untagged struct fields are named in encoded JSON.
+// Fields with a json tag always keep their tag name.
+type FieldNaming int
+
+const (
+	FieldNamesAsDeclared FieldNaming = iota // Go field name, as encoding/json does
+	FieldNamesSnakeCase                     // CreatedAt -> created_at
+	FieldNamesCamelCase                     // CreatedAt -> createdAt
+)
+
+// JSONCodecOptions configures the JSON codec. The zero value behaves exactly
+// like encoding/json.
+type JSONCodecOptions struct {
+	DisableHTMLEscape     bool        // write <, > and & as-is instead of escaping them
+	FieldNaming           FieldNaming // naming of untagged struct fields
+	DisallowUnknownFields bool        // fail decoding when stored data has fields the Go type lacks
+	TimeFormat            string      // layout for time.Time values; empty means RFC 3339
+}
+
+// jsonCodec is the default Codec
+type jsonCodec struct {
+	opts JSONCodecOptions
+}
+
+// NewJSONCodec creates a JSON codec with the given options
+func NewJSONCodec(opts JSONCodecOptions) Codec {
+	return &jsonCodec{opts: opts}
+}
+
+// WithCodec sets the codec used to persist step results, inputs, outputs and
+// signal payloads. Changing the codec of a database with in-flight workflows
+// must keep previously written data decodable.
+func WithCodec(c Codec) EngineOption {
+	return func(e *Engine) {
+		if c != nil {
+			e.codec = c
+		}
+	}
+}
+
+// rewrites reports whether values need a type-guided pass beyond encoding/json
+func (c *jsonCodec) rewrites() bool {
+	return c.opts.FieldNaming != FieldNamesAsDeclared || c.opts.TimeFormat != ""
+}
+
+// Marshal encodes v
+func (c *jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	if c.rewrites() {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		tree, err := decodeTree(data)
+		if err != nil {
+			return nil, err
+		}
+		rw := &jsonRewriter{opts: c.opts, encode: true}
+		if v, err = rw.rewrite(tree, reflect.TypeOf(v)); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(!c.opts.DisableHTMLEscape)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// Unmarshal decodes data into v
+func (c *jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	if c.rewrites() {
+		tree, err := decodeTree(data)
+		if err != nil {
+			return err
+		}
+		rw := &jsonRewriter{opts: c.opts}
+		if tree, err = rw.rewrite(tree, reflect.TypeOf(v)); err != nil {
+			return err
+		}
+		if data, err = json.Marshal(tree); err != nil {
+			return err
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if c.opts.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	return dec.Decode(v)
+}
+
+// decodeTree parses JSON into generic values, keeping numbers exact
+func decodeTree(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var tree interface{}
+	if err := dec.Decode(&tree); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	marshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// jsonRewriter converts between encoding/json's representation of a Go type
+// and the configured one, walking the generic tree alongside the type
+type jsonRewriter struct {
+	opts   JSONCodecOptions
+	encode bool // true: encoding/json form -> configured form; false: the reverse
+}
+
+// rewrite converts one value of type t
+func (rw *jsonRewriter) rewrite(v interface{}, t reflect.Type) (interface{}, error) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || v == nil {
+		return v, nil
+	}
+
+	if t == timeType {
+		return rw.rewriteTime(v)
+	}
+	// Types with custom JSON encoding are left to their own methods
+	if t.Implements(marshalerType) || reflect.PointerTo(t).Implements(unmarshalerType) {
+		return v, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return v, nil
+		}
+		fields := structFields(t)
+		out := make(map[string]interface{}, len(obj))
+		for key, val := range obj {
+			f := rw.matchField(fields, key)
+			if f == nil {
+				out[key] = val
+				continue
+			}
+			converted, err := rw.rewrite(val, f.typ)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", f.goName, err)
+			}
+			if rw.encode {
+				out[rw.configuredName(f)] = converted
+			} else {
+				out[f.jsonName] = converted
+			}
+		}
+		return out, nil
+
+	case reflect.Map:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return v, nil
+		}
+		out := make(map[string]interface{}, len(obj))
+		for key, val := range obj {
+			converted, err := rw.rewrite(val, t.Elem())
+			if err != nil {
+				return nil, err
+			}
+			out[key] = converted
+		}
+		return out, nil
+
+	case reflect.Slice, reflect.Array:
+		arr, ok := v.([]interface{})
+		if !ok {
+			return v, nil
+		}
+		out := make([]interface{}, len(arr))
+		for i, val := range arr {
+			converted, err := rw.rewrite(val, t.Elem())
+			if err != nil {
+				return nil, err
+			}
+			out[i] = converted
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+// rewriteTime converts between RFC 3339 and the configured time layout
+func (rw *jsonRewriter) rewriteTime(v interface{}) (interface{}, error) {
+	s, ok := v.(string)
+	if !ok || rw.opts.TimeFormat == "" {
+		return v, nil
+	}
+	if rw.encode {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, err
+		}
+		return t.Format(rw.opts.TimeFormat), nil
+	}
+	t, err := time.Parse(rw.opts.TimeFormat, s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse time %q: %w", s, err)
+	}
+	return t.Format(time.RFC3339Nano), nil
+}
+
+// codecField is an encodable struct field
+type codecField struct {
+	goName   string
+	jsonName string // name encoding/json uses
+	tagged   bool
+	typ      reflect.Type
+}
+
+// matchField finds the field an encoded key belongs to
+func (rw *jsonRewriter) matchField(fields []codecField, key string) *codecField {
+	for i := range fields {
+		f := &fields[i]
+		if rw.encode && f.jsonName == key {
+			return f
+		}
+		if !rw.encode && rw.configuredName(f) == key {
+			return f
+		}
+	}
+	return nil
+}
+
+// configuredName is the field's name under the configured naming
+func (rw *jsonRewriter) configuredName(f *codecField) string {
+	if f.tagged {
+		return f.jsonName
+	}
+	switch rw.opts.FieldNaming {
+	case FieldNamesSnakeCase:
+		return snakeCase(f.goName)
+	case FieldNamesCamelCase:
+		return camelCase(f.goName)
+	default:
+		return f.goName
+	}
+}
+
+// structFields lists the fields encoding/json would encode, flattening embedded structs
+func structFields(t reflect.Type) []codecField {
+	var fields []codecField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		ft := sf.Type
+		if sf.Anonymous && name == "" {
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				fields = append(fields, structFields(ft)...)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		f := codecField{goName: sf.Name, jsonName: sf.Name, typ: sf.Type}
+		if name != "" {
+			f.jsonName, f.tagged = name, true
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// snakeCase converts a Go identifier such as HTTPStatusCode to http_status_code
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// Start a new word at a lower->upper edge or at the last capital of an acronym
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// camelCase converts a Go identifier such as HTTPStatusCode to httpStatusCode
+func camelCase(name string) string {
+	runes := []rune(name)
+	for i := range runes {
+		if !unicode.IsUpper(runes[i]) {
+			break
+		}
+		// Keep the capital that starts the next word, as in HTTPStatus -> httpStatus
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type codecAudit struct {
+	ActorID string
+}
+
+type codecEvent struct {
+	codecAudit
+	HTTPStatus int
+	CreatedAt  time.Time
+	Note       string
+	Tags       map[string]string
+	Items      []codecItem
+	Legacy     string `json:"legacy_name"`
+}
+
+type codecItem struct {
+	SKUCode string
+	When    *time.Time
+}
+
+func TestJSONCodecDefaultMatchesEncodingJSON(t *testing.T) {
+	v := codecEvent{HTTPStatus: 200, Note: "<b>&</b>", CreatedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	want, _ := json.Marshal(v)
+	got, err := NewJSONCodec(JSONCodecOptions{}).Marshal(v)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	got, _ = NewJSONCodec(JSONCodecOptions{DisableHTMLEscape: true}).Marshal(v)
+	if !strings.Contains(string(got), `"<b>&</b>"`) {
+		t.Errorf("expected unescaped HTML, got %s", got)
+	}
+}
+
+func TestJSONCodecNamingAndTimeFormat(t *testing.T) {
+	when := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)
+	in := codecEvent{
+		codecAudit: codecAudit{ActorID: "u1"},
+		HTTPStatus: 404,
+		CreatedAt:  time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+		Tags:       map[string]string{"KeepCase": "yes"},
+		Items:      []codecItem{{SKUCode: "A1", When: &when}},
+		Legacy:     "tagged",
+	}
+
+	codec := NewJSONCodec(JSONCodecOptions{FieldNaming: FieldNamesSnakeCase, TimeFormat: "2006-01-02 15:04"})
+	data, err := codec.Marshal(in)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	for _, want := range []string{
+		`"actor_id":"u1"`, `"http_status":404`, `"created_at":"2024-05-01 12:30"`,
+		`"KeepCase":"yes"`, `"sku_code":"A1"`, `"when":"2024-05-02 00:00"`, `"legacy_name":"tagged"`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %s in %s", want, data)
+		}
+	}
+
+	var out codecEvent
+	if err := codec.Unmarshal(data, &out); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if out.ActorID != "u1" || out.HTTPStatus != 404 || !out.CreatedAt.Equal(in.CreatedAt) ||
+		out.Tags["KeepCase"] != "yes" || out.Items[0].SKUCode != "A1" || !out.Items[0].When.Equal(when) || out.Legacy != "tagged" {
+		t.Errorf("round trip mismatch: %+v", out)
+	}
+
+	camel, _ := NewJSONCodec(JSONCodecOptions{FieldNaming: FieldNamesCamelCase}).Marshal(in)
+	if !strings.Contains(string(camel), `"httpStatus":404`) || !strings.Contains(string(camel), `"skuCode":"A1"`) {
+		t.Errorf("expected camelCase fields, got %s", camel)
+	}
+}
+
+func TestStrictCodecRejectsDroppedFields(t *testing.T) {
+	dbPath := "./test_codec.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath, WithCodec(NewJSONCodec(JSONCodecOptions{DisallowUnknownFields: true})))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	type accountV1 struct {
+		ID    string
+		Email string
+	}
+	type accountV2 struct {
+		ID string
+	}
+
+	eng.Execute("acct-1", func(ctx *Context) error {
+		if _, err := Step(ctx, "load", func() (accountV1, error) { return accountV1{"a1", "a@example.com"}, nil }); err != nil {
+			return err
+		}
+		return errors.New("stop before completion")
+	})
+
+	// A deploy removed Email; replaying the stored result must not silently drop it
+	err = eng.Execute("acct-1", func(ctx *Context) error {
+		_, err := Step(ctx, "load", func() (accountV2, error) { return accountV2{"a1"}, nil })
+		return err
+	})
+	if err == nil || !strings.Contains(err.Error(), "unknown field") {
+		t.Errorf("expected unknown field error on replay, got %v", err)
+	}
+}
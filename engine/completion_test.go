@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCompletionWaitsForUnawaitedSteps(t *testing.T) {
+	dbPath := "./test_completion.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	// The workflow returns without calling ctx.Wait
+	err = eng.Execute("barrier-1", func(ctx *Context) error {
+		ctx.Go(func() error {
+			_, err := Step(ctx, "slow", func() (string, error) {
+				time.Sleep(100 * time.Millisecond)
+				return "done", nil
+			})
+			return err
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+
+	steps, err := eng.GetHistory("barrier-1")
+	if err != nil {
+		t.Fatalf("failed to get history: %v", err)
+	}
+	if len(steps) != 1 || steps[0].Status != "completed" {
+		t.Fatalf("expected the slow step to be persisted before completion, got %+v", steps)
+	}
+
+	// A failure in a goroutine the workflow didn't wait for still fails the run
+	err = eng.Execute("barrier-2", func(ctx *Context) error {
+		ctx.Go(func() error {
+			_, err := Step(ctx, "broken", func() (int, error) { return 0, errors.New("boom") })
+			return err
+		})
+		return nil
+	})
+	if err == nil {
+		t.Fatal("expected unawaited step failure to fail the workflow")
+	}
+	if status, _ := eng.storage.GetWorkflowStatus("barrier-2"); status != "failed" {
+		t.Errorf("expected failed, got %s", status)
+	}
+}
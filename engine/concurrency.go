@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+)
+
+// concurrencyKeyPoll is how often Execute checks whether a busy concurrency key is free
+const concurrencyKeyPoll = 100 * time.Millisecond
+
+// concurrencyKeyTouch is how often a run waiting for its concurrency key
+// records that it is still alive, so the orphan scanner leaves it alone
+const concurrencyKeyTouch = 10 * time.Second
+
+// WithConcurrencyKey serializes workflows sharing key, e.g. a customer or
+// employee ID: at most one of them is running at a time. Enqueued workflows
+// stay queued while another run holds the key; Execute waits for it.
+func WithConcurrencyKey(key string) WorkflowOption {
+	return func(o *workflowOptions) {
+		o.concurrencyKey = key
+	}
+}
+
+// concurrencyKeyFree is a condition on a workflows row that holds unless
+// another running workflow has the row's concurrency key
+const concurrencyKeyFree = `(workflows.concurrency_key IS NULL OR NOT EXISTS (
+	SELECT 1 FROM workflows held WHERE held.concurrency_key = workflows.concurrency_key
+	  AND held.status = 'running' AND held.workflow_id != workflows.workflow_id))`
+
+// awaitConcurrencyKey blocks until the workflow holds key, or the engine
+// closes. The row is already 'running', so while it waits it is claimed by
+// this engine and touched every concurrencyKeyTouch, like a run making progress.
+func (e *Engine) awaitConcurrencyKey(workflowID, key string) error {
+	var touched time.Time
+	for {
+		acquired, err := e.storage.AcquireConcurrencyKey(workflowID, key)
+		if err != nil {
+			return err
+		}
+		if acquired {
+			return nil
+		}
+		if touched.IsZero() {
+			fmt.Printf("[CONCURRENCY] %s waiting for key %s\n", workflowID, key)
+		}
+		if time.Since(touched) >= concurrencyKeyTouch {
+			if err := e.storage.TouchWaitingWorkflow(workflowID, e.workerID); err != nil {
+				return err
+			}
+			touched = time.Now()
+		}
+
+		select {
+		case <-e.stop:
+			return fmt.Errorf("engine closed while %s waited for concurrency key %s", workflowID, key)
+		case <-time.After(concurrencyKeyPoll):
+		}
+	}
+}
+
+// AcquireConcurrencyKey assigns key to a workflow unless another running
+// workflow holds it, reporting whether it did
+func (s *Storage) AcquireConcurrencyKey(workflowID, key string) (bool, error) {
+	var affected int64
+	err := s.retryOnBusy(func() error {
+		res, err := s.db.Exec(
+			`UPDATE workflows SET concurrency_key = ?
+			 WHERE workflow_id = ? AND NOT EXISTS (
+			   SELECT 1 FROM workflows held WHERE held.concurrency_key = ?
+			     AND held.status = 'running' AND held.workflow_id != ?)`,
+			key, workflowID, key, workflowID,
+		)
+		if err != nil {
+			return err
+		}
+		affected, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire concurrency key: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// TouchWaitingWorkflow records workerID as the owner of a running workflow
+// that hasn't started executing yet, and that it is alive now
+func (s *Storage) TouchWaitingWorkflow(workflowID, workerID string) error {
+	err := s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			`UPDATE workflows SET claimed_by = ?, updated_at = ?
+			 WHERE workflow_id = ? AND status = 'running'`,
+			workerID, dbNow(), workflowID,
+		)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to touch waiting workflow: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package engine
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// keyTracker records the most runs seen at once per concurrency key
+type keyTracker struct {
+	mu      sync.Mutex
+	running map[string]int
+	max     map[string]int
+}
+
+func (k *keyTracker) run(key string) {
+	k.mu.Lock()
+	k.running[key]++
+	k.max[key] = max(k.max[key], k.running[key])
+	k.mu.Unlock()
+
+	time.Sleep(30 * time.Millisecond)
+
+	k.mu.Lock()
+	k.running[key]--
+	k.mu.Unlock()
+}
+
+func TestConcurrencyKeySerializesQueuedWorkflows(t *testing.T) {
+	dbPath := "./test_concurrency_key.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	tracker := &keyTracker{running: make(map[string]int), max: make(map[string]int)}
+	eng.Register("onboard", func(ctx *Context) error {
+		var employee string
+		if err := ctx.Input(&employee); err != nil {
+			return err
+		}
+		_, err := Step(ctx, "provision", func() (bool, error) {
+			tracker.run(employee)
+			return true, nil
+		})
+		return err
+	})
+
+	ids := []string{"onboard-1", "onboard-2", "onboard-3", "onboard-4"}
+	employees := []string{"employee-7", "employee-7", "employee-7", "employee-8"}
+	for i, id := range ids {
+		if err := eng.Enqueue(id, "onboard", employees[i], WithConcurrencyKey(employees[i])); err != nil {
+			t.Fatalf("failed to enqueue %s: %v", id, err)
+		}
+	}
+	if err := eng.StartWorker(WorkerConfig{Capacity: 4, PollInterval: 5 * time.Millisecond}); err != nil {
+		t.Fatalf("failed to start worker: %v", err)
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for _, id := range ids {
+		for {
+			if status, _ := eng.GetWorkflowStatus(id); status == "completed" {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %s to complete", id)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	if tracker.max["employee-7"] != 1 {
+		t.Errorf("expected one employee-7 run at a time, saw %d", tracker.max["employee-7"])
+	}
+}
+
+func TestConcurrencyKeyExecuteWaits(t *testing.T) {
+	dbPath := "./test_concurrency_key_execute.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	tracker := &keyTracker{running: make(map[string]int), max: make(map[string]int)}
+	var wg sync.WaitGroup
+	errs := make(chan error, 3)
+	for _, id := range []string{"sync-1", "sync-2", "sync-3"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			errs <- eng.Execute(id, func(ctx *Context) error {
+				_, err := Step(ctx, "sync", func() (bool, error) {
+					tracker.run("customer-42")
+					return true, nil
+				})
+				return err
+			}, WithConcurrencyKey("customer-42"))
+		}(id)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("workflow failed: %v", err)
+		}
+	}
+	if tracker.max["customer-42"] != 1 {
+		t.Errorf("expected one customer-42 run at a time, saw %d", tracker.max["customer-42"])
+	}
+}
+
+func TestConcurrencyKeyWaitIsNotOrphaned(t *testing.T) {
+	dbPath := "./test_concurrency_key_orphan.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath, WithWorkerID("waiter"))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+	scanner, err := NewEngine(dbPath, WithWorkerID("scanner"))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer scanner.Close()
+	eng.storage.RegisterWorker(WorkerInfo{WorkerID: "waiter", HeartbeatInterval: time.Minute})
+
+	release := make(chan struct{})
+	holding := make(chan struct{})
+	done := make(chan error, 2)
+	go func() {
+		done <- eng.Execute("holder", func(ctx *Context) error {
+			close(holding)
+			<-release
+			return nil
+		}, WithConcurrencyKey("customer-7"))
+	}()
+	<-holding
+	go func() {
+		done <- eng.Execute("waiter-1", func(ctx *Context) error { return nil }, WithConcurrencyKey("customer-7"))
+	}()
+
+	// Wait until the second run is blocked on the key, then let its row look old
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		var owner string
+		scanner.storage.db.QueryRow("SELECT COALESCE(claimed_by, '') FROM workflows WHERE workflow_id = 'waiter-1'").Scan(&owner)
+		if owner == "waiter" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("waiting run was never claimed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	scanner.storage.db.Exec("UPDATE workflows SET updated_at = ? WHERE workflow_id = 'waiter-1'",
+		time.Now().Add(-2*time.Hour).UTC().Format("2006-01-02 15:04:05"))
+
+	orphans, err := scanner.ScanOrphans(time.Hour)
+	if err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+	if len(orphans) != 0 {
+		t.Errorf("expected the waiting run to be left alone, got %+v", orphans)
+	}
+
+	close(release)
+	for range 2 {
+		if err := <-done; err != nil {
+			t.Fatalf("workflow failed: %v", err)
+		}
+	}
+}
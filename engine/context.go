@@ -1,74 +1,188 @@
 package engine
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"sync"
-	"sync/atomic"
+	"time"
 
 	"golang.org/x/sync/errgroup"
 )
 
 // Context represents the execution context for a workflow
 type Context struct {
-	WorkflowID     string
-	sequenceNum    int64
-	storage        *Storage
-	completedSteps map[string][]byte
-	stepIDToSeq    map[string]int64 // Maps step ID to its sequence number
-	mu             sync.Mutex
-	eg             *errgroup.Group
+	WorkflowID       string
+	sequenceNum      int64
+	prefetchedSeq    int64 // every step row up to this sequence number was loaded with the run
+	fencingToken     int64 // issued when this execution claimed the run; see SaveStep
+	engine           *Engine
+	storage          *Storage
+	completedSteps   map[string][]byte
+	outputTypes      map[string]string // result type fingerprints of completed steps, by step key
+	stepIDToSeq      map[string]int64  // Maps step ID to its sequence number
+	autoSteps        map[string]bool   // AutoStep IDs whose call site is recorded
+	input            []byte            // JSON-encoded start input, if enqueued with one
+	params           map[string]string
+	headers          map[string]string // start headers, e.g. correlation IDs
+	retryBudget      *retryBudgetState
+	limits           *runLimitState // nil unless the run has RunLimits
+	stepDefaults     []StepOption   // engine, workflow and run defaults, applied before each step's options
+	canceled         int32          // set atomically by Engine.CancelWorkflow
+	nonCancellable   int32          // positive while cancellation scopes run cleanup steps
+	scopeSeq         int            // cancellation scopes created so far, for stable step IDs
+	queuedAt         time.Time      // when a dispatched run entered its queue, until its first step
+	locks            []*Lock
+	lockAcquisitions map[string]int    // AcquireLock and AcquireSemaphore calls per name, for stable lock holders
+	signalWaits      map[string]int    // WaitForSignal calls per signal name, for stable step IDs
+	signalSends      map[string]int    // SignalWorkflow calls per target and signal name, for stable step IDs
+	updateWaits      map[string]int    // HandleUpdate calls per update name, for stable step IDs
+	timerStarts      map[string]int    // StartTimer calls per timer name, for stable step IDs
+	timerCancels     map[string]int    // CancelTimer calls per timer name, for stable step IDs
+	logSeq           int64             // Logf calls so far, for stable log keys
+	logs             runLog            // lines captured with WithLogCapture
+	stateSets        map[string]int    // Set calls per key, for stable step IDs
+	state            map[string][]byte // encoded value last Set per key
+	counters         map[string]int64  // current value per counter name
+	counterAdds      map[string]int    // Add calls per counter name, for stable step IDs
+	counterMu        sync.Mutex        // serializes Adds, so each sees the previous total
+	attempts         map[string]int    // attempts per step key by this execution, until reported
+	attemptNums      map[string]int    // number of each step's current attempt, counted across resumes
+	sim              *simulation       // non-nil during Engine.Simulate
+	output           []byte            // encoded result, written together with the completed status
+	inflight         int               // steps started by this run and not yet persisted
+	localSteps       []localStepRecord // LocalStep results waiting for the next durable step
+	stepsDone        *sync.Cond        // signaled when inflight drops
+	goStarted        int               // functions started with Go
+	goFailures       []parallelFailure
+	groups           []*Group  // started with ctx.Group, waited for before the run completes
+	groupSeq         int       // groups created so far, for stable step IDs
+	requires         []string  // labels a worker needs to continue this run
+	deadline         time.Time // zero unless started WithWorkflowTimeout
+	runCtx           context.Context
+	cancelRun        context.CancelFunc // called by Engine.CancelWorkflow and when the run ends
+	mu               sync.Mutex
+	eg               *errgroup.Group
 }
 
 // newContext creates a new workflow context
-func newContext(workflowID string, storage *Storage) (*Context, error) {
-	// Load completed steps from database
-	completedSteps, err := storage.LoadCompletedSteps(workflowID)
+func newContext(e *Engine, workflowID string) (*Context, error) {
+	storage := e.storage
+
+	// Load completed steps, the step ID mapping and the sequence to resume
+	// from in one pass, so resuming a long run doesn't query step by step
+	replay, err := storage.LoadReplayState(workflowID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load step history: %w", err)
+	}
+
+	// Load the start input for enqueued workflows
+	input, err := storage.GetWorkflowInput(workflowID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load workflow input: %w", err)
+	}
+
+	// Load start-time template parameters
+	params, err := storage.GetWorkflowParams(workflowID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load completed steps: %w", err)
+		return nil, fmt.Errorf("failed to load workflow params: %w", err)
 	}
 
-	// Load step ID to sequence number mapping
-	stepIDToSeq, err := storage.LoadStepIDMapping(workflowID)
+	headers, err := storage.GetWorkflowHeaders(workflowID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load step ID mapping: %w", err)
+		return nil, fmt.Errorf("failed to load workflow headers: %w", err)
 	}
 
-	// Get the maximum sequence number to resume from
-	maxSeq, err := storage.GetMaxSequenceNum(workflowID)
+	deadline, err := storage.GetWorkflowDeadline(workflowID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get max sequence: %w", err)
+		return nil, err
 	}
 
 	eg := &errgroup.Group{}
 
-	return &Context{
+	ctx := &Context{
 		WorkflowID:     workflowID,
-		sequenceNum:    maxSeq,
+		sequenceNum:    replay.maxSeq,
+		prefetchedSeq:  replay.maxSeq,
+		engine:         e,
 		storage:        storage,
-		completedSteps: completedSteps,
-		stepIDToSeq:    stepIDToSeq,
+		completedSteps: replay.completed,
+		outputTypes:    replay.outputTypes,
+		stepIDToSeq:    replay.stepIDToSeq,
+		input:          input,
+		params:         params,
+		headers:        headers,
+		deadline:       deadline,
 		eg:             eg,
-	}, nil
+	}
+	if deadline.IsZero() {
+		ctx.runCtx, ctx.cancelRun = context.WithCancel(context.Background())
+	} else {
+		ctx.runCtx, ctx.cancelRun = context.WithDeadline(context.Background(), deadline)
+	}
+	// Writes stop retrying a busy database once the run is canceled or expires
+	ctx.storage = storage.withContextFunc(ctx.cancellationContext)
+	ctx.stepsDone = sync.NewCond(&ctx.mu)
+	return ctx, nil
+}
+
+// beginStep registers a step this run is executing; the returned func must
+// be called once its outcome is persisted
+func (ctx *Context) beginStep() func() {
+	ctx.mu.Lock()
+	ctx.inflight++
+	ctx.mu.Unlock()
+
+	return func() {
+		ctx.mu.Lock()
+		ctx.inflight--
+		ctx.stepsDone.Broadcast()
+		ctx.mu.Unlock()
+	}
+}
+
+// awaitSteps blocks until every step started by this run has been persisted,
+// including steps in goroutines the workflow did not wait for
+func (ctx *Context) awaitSteps() error {
+	err := ctx.Wait()
+	if groupErr := ctx.waitGroups(); err == nil {
+		err = groupErr
+	}
+
+	ctx.mu.Lock()
+	for ctx.inflight > 0 {
+		ctx.stepsDone.Wait()
+	}
+	ctx.mu.Unlock()
+	if flushErr := ctx.flushLocalSteps(); err == nil {
+		err = flushErr
+	}
+	return err
 }
 
 // Step is the core primitive - executes a function with memoization
 // Generic type T for any return type
 // id: user-provided step identifier (e.g., "create-user", "send-email")
 // fn: the function to execute (only runs if not already completed)
-func Step[T any](ctx *Context, id string, fn func() (T, error)) (T, error) {
+// opts: per-step options such as WithRetry
+// Errors are returned as *StepError.
+func Step[T any](ctx *Context, id string, fn func() (T, error), opts ...StepOption) (T, error) {
+	result, err := runStep(ctx, id, fn, opts...)
+	if err != nil {
+		return result, &StepError{StepID: id, Err: err}
+	}
+	return result, nil
+}
+
+// runStep executes or replays a step
+func runStep[T any](ctx *Context, id string, fn func() (T, error), opts ...StepOption) (T, error) {
 	var zero T
+	so := ctx.newStepOptions(opts)
 
 	// 1. Check if we've seen this step ID before, reuse sequence if so
-	ctx.mu.Lock()
-	seqNum, exists := ctx.stepIDToSeq[id]
-	if !exists {
-		// Generate new sequence number
-		seqNum = atomic.AddInt64(&ctx.sequenceNum, 1)
-		ctx.stepIDToSeq[id] = seqNum
+	seqNum, err := ctx.stepSequence(id)
+	if err != nil {
+		return zero, err
 	}
-	ctx.mu.Unlock()
-
 	stepKey := generateStepKey(id, seqNum)
 
 	// 2. Check in-memory cache first
@@ -77,24 +191,38 @@ func Step[T any](ctx *Context, id string, fn func() (T, error)) (T, error) {
 	ctx.mu.Unlock()
 
 	if ok {
+		if cached == nil {
+			var err error
+			if cached, err = ctx.loadOffloaded(stepKey); err != nil {
+				return zero, err
+			}
+		}
 		var result T
-		if err := json.Unmarshal(cached, &result); err != nil {
-			return zero, fmt.Errorf("failed to unmarshal cached result: %w", err)
+		if err := ctx.decodeResult(id, stepKey, cached, &result, "cached"); err != nil {
+			return zero, err
 		}
-		fmt.Printf("[SKIPPED] %s (already completed)\n", id)
+		ctx.printf("[SKIPPED] %s (already completed)\n", id)
 		return result, nil
 	}
 
 	// 3. Check database
-	output, found, err := ctx.storage.GetStep(ctx.WorkflowID, stepKey)
+	output, found, err := ctx.storedStep(stepKey, seqNum)
 	if err != nil {
 		return zero, fmt.Errorf("failed to check step in database: %w", err)
 	}
 
 	if found {
+		if output == nil {
+			if output, err = ctx.loadOffloaded(stepKey); err != nil {
+				return zero, err
+			}
+		}
+		if err := ctx.loadOutputType(stepKey); err != nil {
+			return zero, err
+		}
 		var result T
-		if err := json.Unmarshal(output, &result); err != nil {
-			return zero, fmt.Errorf("failed to unmarshal database result: %w", err)
+		if err := ctx.decodeResult(id, stepKey, output, &result, "database"); err != nil {
+			return zero, err
 		}
 
 		// Cache in memory
@@ -102,54 +230,199 @@ func Step[T any](ctx *Context, id string, fn func() (T, error)) (T, error) {
 		ctx.completedSteps[stepKey] = output
 		ctx.mu.Unlock()
 
-		fmt.Printf("[SKIPPED] %s (already completed)\n", id)
+		ctx.printf("[SKIPPED] %s (already completed)\n", id)
 		return result, nil
 	}
 
-	// 4. Mark as in-progress (zombie protection)
-	if err := ctx.storage.MarkStepInProgress(ctx.WorkflowID, stepKey, id, seqNum); err != nil {
-		return zero, fmt.Errorf("failed to mark step in progress: %w", err)
+	// Canceled or expired workflows don't start new steps
+	if err := ctx.doneErr(); err != nil {
+		return zero, err
 	}
 
-	// 5. Execute the function
-	result, err := fn()
+	// Runaway runs fail before adding to their history
+	if err := ctx.startStepWithinLimits(id, seqNum); err != nil {
+		return zero, err
+	}
+
+	// Dry runs record the step and return its stub result instead of executing
+	if ctx.sim != nil {
+		return simulateStep[T](ctx, id, stepKey, seqNum, so.kind)
+	}
+
+	// Steps needing labels this worker lacks wait for one that has them
+	if err := ctx.checkAffinity(id, so.requires); err != nil {
+		return zero, err
+	}
+	// A promoted region fences this one before the step can run twice
+	if err := ctx.engine.checkFence(); err != nil {
+		return zero, err
+	}
+
+	// 4. Mark as in-progress (zombie protection), after the local steps before it
+	if err := ctx.markStepInProgress(stepKey, id, seqNum, so.kind, so.group, so.tags); err != nil {
+		return zero, err
+	}
+	defer ctx.beginStep()()
+	started := time.Now()
+
+	// Reuse a result computed by any workflow sharing the global cache key
+	if so.cacheKey != "" {
+		cached, release, err := ctx.claimGlobalCache(id, so)
+		if err != nil {
+			ctx.failStep(stepKey, err)
+			return zero, fmt.Errorf("failed to claim cache key %s: %w", so.cacheKey, err)
+		}
+		defer release()
+
+		if cached != nil {
+			var result T
+			if err := ctx.engine.codec.Unmarshal(cached, &result); err != nil {
+				return zero, fmt.Errorf("failed to unmarshal cached result: %w", err)
+			}
+			if err := ctx.recordStep(stepKey, cached, ctx.saveOutputType(stepKey, fingerprintOf[T]())); err != nil {
+				return zero, err
+			}
+			ctx.interceptStep(id, stepKey, so.kind, started, cached, nil)
+			ctx.printf("[CACHED] %s (global key %s)\n", id, so.cacheKey)
+			return result, nil
+		}
+	}
+
+	// Hold any resource pool slots for the duration of execution
+	if len(so.pools) > 0 {
+		release, err := ctx.acquirePools(id, so)
+		if err != nil {
+			ctx.failStep(stepKey, err)
+			return zero, err
+		}
+		defer release()
+	}
+
+	// 5. Execute the function, retrying per the step's policy
+	var result T
+	if so.timeout > 0 || !ctx.deadline.IsZero() {
+		result, err = executeWithTimeout(ctx, id, so.timeout, func(stop *stepStop) (T, error) {
+			return executeWithRetry(ctx, stop, id, stepKey, so.retry, fn)
+		})
+	} else {
+		result, err = executeWithRetry(ctx, nil, id, stepKey, so.retry, fn)
+	}
 	if err != nil {
 		// Save error to database
-		ctx.storage.SaveStepError(ctx.WorkflowID, stepKey, err.Error())
+		ctx.failStep(stepKey, err)
+		ctx.interceptStep(id, stepKey, so.kind, started, nil, err)
 		return zero, err
 	}
 
 	// 6. Serialize and save
-	output, err = json.Marshal(result)
+	output, err = ctx.engine.codec.Marshal(result)
 	if err != nil {
 		return zero, fmt.Errorf("failed to marshal result: %w", err)
 	}
+	if err := ctx.engine.validateStepOutput(id, output); err != nil {
+		ctx.failStep(stepKey, err)
+		ctx.interceptStep(id, stepKey, so.kind, started, nil, err)
+		return zero, err
+	}
 
-	if err := ctx.storage.SaveStep(ctx.WorkflowID, stepKey, output); err != nil {
-		return zero, fmt.Errorf("failed to save step: %w", err)
+	offload, err := ctx.checkOutputSize(id, so, output)
+	if err == nil {
+		err = ctx.addHistoryBytes(id, output)
+	}
+	if err != nil {
+		ctx.failStep(stepKey, err)
+		ctx.interceptStep(id, stepKey, so.kind, started, nil, err)
+		return zero, err
+	}
+	if offload {
+		// Oversized results are not copied into the global cache either
+		if err := ctx.offloadStep(stepKey, output); err != nil {
+			return zero, err
+		}
+		ctx.interceptStep(id, stepKey, so.kind, started, output, nil)
+		return result, nil
+	}
+
+	// The result is published to the global cache as the step completes
+	publish := []func(tx StorageTx) error{ctx.saveOutputType(stepKey, fingerprintOf[T]())}
+	if so.cacheKey != "" {
+		publish = append(publish, func(tx StorageTx) error {
+			if err := tx.PutCachedStep(so.cacheKey, output, so.cacheTTL); err != nil {
+				return fmt.Errorf("failed to cache step result: %w", err)
+			}
+			return nil
+		})
+	}
+	if err := ctx.recordStep(stepKey, output, publish...); err != nil {
+		return zero, err
+	}
+	ctx.interceptStep(id, stepKey, so.kind, started, output, nil)
+
+	return result, nil
+}
+
+// recordStep persists a completed step's output, in one transaction with
+// any further writes, and caches it in memory
+func (ctx *Context) recordStep(stepKey string, output []byte, also ...func(tx StorageTx) error) error {
+	err := ctx.storage.WithTx(func(tx StorageTx) error {
+		for _, write := range also {
+			if err := write(tx); err != nil {
+				return err
+			}
+		}
+		if err := tx.SaveStep(ctx.WorkflowID, stepKey, ctx.fencingToken, output); err != nil {
+			return fmt.Errorf("failed to save step: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	// Cache in memory
 	ctx.mu.Lock()
 	ctx.completedSteps[stepKey] = output
 	ctx.mu.Unlock()
-
-	return result, nil
+	return nil
 }
 
 // Go runs a function concurrently (like errgroup)
 func (ctx *Context) Go(fn func() error) {
-	ctx.eg.Go(fn)
+	ctx.mu.Lock()
+	index := ctx.goStarted
+	ctx.goStarted++
+	ctx.mu.Unlock()
+
+	ctx.eg.Go(func() error {
+		err := callWorkflow(fn)
+		if err != nil {
+			ctx.mu.Lock()
+			ctx.goFailures = append(ctx.goFailures, parallelFailure{index, err})
+			ctx.mu.Unlock()
+		}
+		return err
+	})
 }
 
-// Wait waits for all concurrent operations to complete
+// Wait waits for all concurrent operations to complete. If one failed its
+// error is returned; if several failed, a *ParallelError lists every failure
+// with the ID of the step that caused it. Each failure is reported by one Wait.
 func (ctx *Context) Wait() error {
-	return ctx.eg.Wait()
+	ctx.eg.Wait()
+
+	ctx.mu.Lock()
+	failures := ctx.goFailures
+	ctx.goFailures = nil
+	ctx.mu.Unlock()
+	return collectParallelErrors(failures)
 }
 
-// AutoStep is a bonus feature that automatically generates step IDs from the call location
-func AutoStep[T any](ctx *Context, fn func() (T, error)) (T, error) {
-	// Get caller location (skip 1 frame to get the actual caller)
-	autoID := getCallerLocation(2)
-	return Step(ctx, autoID, fn)
+// storedStep returns the output of a step completed by an earlier execution
+// that isn't in the in-memory cache. Rows up to prefetchedSeq were loaded with
+// the run, so replayed steps that missed the cache (failed or interrupted
+// ones) need no query; only steps beyond the loaded history are looked up.
+func (ctx *Context) storedStep(stepKey string, seqNum int64) ([]byte, bool, error) {
+	if seqNum <= ctx.prefetchedSeq {
+		return nil, false, nil
+	}
+	return ctx.storage.GetStep(ctx.WorkflowID, stepKey)
 }
@@ -0,0 +1,184 @@
+package engine
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Counter is a named running total of a workflow run, such as the number
+// of emails it sent
+type Counter struct {
+	ctx  *Context
+	name string
+}
+
+// Counter returns the counter called name
+func (ctx *Context) Counter(name string) *Counter {
+	return &Counter{ctx: ctx, name: name}
+}
+
+// Add adds delta to the counter and returns the new total. Each call is
+// recorded like a step, and the total is written to the run's counters (see
+// Engine.GetCounters) in the same transaction, so a resumed run replays the
+// recorded totals instead of counting again. Call Add from workflow code
+// after the step it counts, not inside a step function, whose retries would
+// add again.
+func (c *Counter) Add(delta int64) (int64, error) {
+	ctx := c.ctx
+	ctx.counterMu.Lock()
+	defer ctx.counterMu.Unlock()
+
+	ctx.mu.Lock()
+	if ctx.counterAdds == nil {
+		ctx.counterAdds = make(map[string]int)
+	}
+	ctx.counterAdds[c.name]++
+	id := fmt.Sprintf("counter:%s:%d", c.name, ctx.counterAdds[c.name])
+	ctx.mu.Unlock()
+
+	value, err := ctx.recordCounter(id, c.name, delta)
+	if err != nil {
+		return 0, &StepError{StepID: id, Err: err}
+	}
+	return value, nil
+}
+
+// Value returns the counter's current total
+func (c *Counter) Value() int64 {
+	c.ctx.mu.Lock()
+	defer c.ctx.mu.Unlock()
+	return c.ctx.counters[c.name]
+}
+
+// recordCounter records the total after adding delta as a completed step,
+// or returns the total recorded by an earlier execution of the same step
+func (ctx *Context) recordCounter(id, name string, delta int64) (int64, error) {
+	seqNum, err := ctx.stepSequence(id)
+	if err != nil {
+		return 0, err
+	}
+	stepKey := generateStepKey(id, seqNum)
+
+	ctx.mu.Lock()
+	output, found := ctx.completedSteps[stepKey]
+	ctx.mu.Unlock()
+	if !found {
+		var err error
+		if output, found, err = ctx.storedStep(stepKey, seqNum); err != nil {
+			return 0, fmt.Errorf("failed to check step in database: %w", err)
+		}
+	}
+
+	var value int64
+	if found {
+		if err := ctx.engine.codec.Unmarshal(output, &value); err != nil {
+			return 0, fmt.Errorf("failed to unmarshal counter %s: %w", name, err)
+		}
+	} else {
+		if err := ctx.doneErr(); err != nil {
+			return 0, err
+		}
+		ctx.mu.Lock()
+		value = ctx.counters[name] + delta
+		ctx.mu.Unlock()
+
+		data, err := ctx.engine.codec.Marshal(value)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal counter %s: %w", name, err)
+		}
+		// Dry runs keep counters in memory only
+		if ctx.sim == nil {
+			if err := ctx.markStepInProgress(stepKey, id, seqNum, StepKindCounter, "", nil); err != nil {
+				return 0, err
+			}
+			if err := ctx.storage.SaveCounterStep(ctx.WorkflowID, stepKey, ctx.fencingToken, data, name, value); err != nil {
+				return 0, fmt.Errorf("failed to save counter %s: %w", name, err)
+			}
+		}
+		output = data
+	}
+
+	ctx.mu.Lock()
+	ctx.completedSteps[stepKey] = output
+	if ctx.counters == nil {
+		ctx.counters = make(map[string]int64)
+	}
+	ctx.counters[name] = value
+	ctx.mu.Unlock()
+	return value, nil
+}
+
+// SaveCounterStep completes a counter step and stores the counter's new
+// total in the same transaction
+func (s *Storage) SaveCounterStep(workflowID, stepKey string, token int64, output []byte, name string, value int64) error {
+	return s.retryOnBusy(func() error {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if err := checkFencingToken(tx, workflowID, token); err != nil {
+			return err
+		}
+		blobID, err := putBlob(tx, output)
+		if err != nil {
+			return err
+		}
+		now := dbNow()
+		if _, err := tx.Exec(
+			`UPDATE steps SET status = 'completed', output = NULL, output_blob = ?, completed_at = ?
+			 WHERE workflow_id = ? AND step_key = ?`,
+			blobID, now, workflowID, stepKey,
+		); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO workflow_counters (workflow_id, name, value, updated_at) VALUES (?, ?, ?, ?)
+			 ON CONFLICT(workflow_id, name) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
+			workflowID, name, value, now,
+		); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+// GetCounters returns the current total of each counter of a run
+func (e *Engine) GetCounters(workflowID string) (map[string]int64, error) {
+	return e.reads.GetCounters(workflowID)
+}
+
+// CounterTotal returns the sum of a counter across all runs
+func (e *Engine) CounterTotal(name string) (int64, error) {
+	return e.reads.CounterTotal(name)
+}
+
+// GetCounters loads the counters of a run
+func (s *Storage) GetCounters(workflowID string) (map[string]int64, error) {
+	rows, err := s.db.Query("SELECT name, value FROM workflow_counters WHERE workflow_id = ?", workflowID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load counters: %w", err)
+	}
+	defer rows.Close()
+
+	counters := make(map[string]int64)
+	for rows.Next() {
+		var name string
+		var value int64
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan counter: %w", err)
+		}
+		counters[name] = value
+	}
+	return counters, rows.Err()
+}
+
+// CounterTotal sums a counter over every run
+func (s *Storage) CounterTotal(name string) (int64, error) {
+	var total sql.NullInt64
+	if err := s.db.QueryRow("SELECT SUM(value) FROM workflow_counters WHERE name = ?", name).Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to sum counter %s: %w", name, err)
+	}
+	return total.Int64, nil
+}
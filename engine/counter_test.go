@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestCounterCountsOnceAcrossResumes(t *testing.T) {
+	dbPath := "./test_counter.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	sent := 0
+	crash := true
+	var final int64
+	campaign := func(ctx *Context) error {
+		for i := 0; i < 3; i++ {
+			if _, err := Step(ctx, fmt.Sprintf("email-%d", i), func() (bool, error) {
+				sent++
+				return true, nil
+			}); err != nil {
+				return err
+			}
+			total, err := ctx.Counter("emails-sent").Add(1)
+			if err != nil {
+				return err
+			}
+			if total != int64(i+1) {
+				return fmt.Errorf("expected total %d, got %d", i+1, total)
+			}
+			if i == 1 && crash {
+				return errors.New("worker crashed")
+			}
+		}
+		final = ctx.Counter("emails-sent").Value()
+		return nil
+	}
+
+	if err := eng.Execute("campaign-1", campaign); err == nil {
+		t.Fatal("expected the first execution to crash")
+	}
+	counters, err := eng.GetCounters("campaign-1")
+	if err != nil || counters["emails-sent"] != 2 {
+		t.Fatalf("expected 2 emails counted before the crash, got %v (%v)", counters, err)
+	}
+
+	crash = false
+	if err := eng.storage.UpdateWorkflowStatus("campaign-1", "running"); err != nil {
+		t.Fatalf("failed to reset status: %v", err)
+	}
+	if err := eng.Execute("campaign-1", campaign); err != nil {
+		t.Fatalf("resumed run failed: %v", err)
+	}
+	counters, _ = eng.GetCounters("campaign-1")
+	if sent != 3 || final != 3 || counters["emails-sent"] != 3 {
+		t.Errorf("expected 3 emails sent and counted, got %d sent, value %d, stored %v", sent, final, counters)
+	}
+
+	if err := eng.Execute("campaign-2", campaign); err != nil {
+		t.Fatalf("second campaign failed: %v", err)
+	}
+	if total, err := eng.CounterTotal("emails-sent"); err != nil || total != 6 {
+		t.Errorf("expected 6 emails across runs, got %d (%v)", total, err)
+	}
+}
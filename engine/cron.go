@@ -0,0 +1,187 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSpec is a parsed five-field cron expression: minute hour day-of-month month day-of-week
+type CronSpec struct {
+	expr   string
+	minute bitset
+	hour   bitset
+	dom    bitset
+	month  bitset
+	dow    bitset
+	anyDom bool
+	anyDow bool
+}
+
+type bitset uint64
+
+func (b bitset) has(v int) bool { return b&(1<<uint(v)) != 0 }
+
+// cronAliases are the supported @-shorthands
+var cronAliases = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron parses a standard five-field cron expression or an @-alias
+func ParseCron(expr string) (*CronSpec, error) {
+	normalized := strings.TrimSpace(expr)
+	if alias, ok := cronAliases[normalized]; ok {
+		normalized = alias
+	}
+
+	fields := strings.Fields(normalized)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields, got %d", expr, len(fields))
+	}
+
+	spec := &CronSpec{expr: expr}
+	var err error
+	if spec.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("invalid minute in %q: %w", expr, err)
+	}
+	if spec.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("invalid hour in %q: %w", expr, err)
+	}
+	if spec.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("invalid day-of-month in %q: %w", expr, err)
+	}
+	if spec.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("invalid month in %q: %w", expr, err)
+	}
+	if spec.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("invalid day-of-week in %q: %w", expr, err)
+	}
+	// 7 is an alias for Sunday
+	if spec.dow.has(7) {
+		spec.dow |= 1
+	}
+	spec.anyDom = fields[2] == "*" || fields[2] == "?"
+	spec.anyDow = fields[4] == "*" || fields[4] == "?"
+
+	return spec, nil
+}
+
+// parseCronField parses a comma-separated list of values, ranges and steps
+func parseCronField(field string, min, max int) (bitset, error) {
+	var bits bitset
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("bad step in %q", part)
+			}
+			step = n
+			part = part[:i]
+		}
+
+		lo, hi := min, max
+		switch {
+		case part == "*" || part == "?":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("bad range %q", part)
+			}
+			if hi, err = strconv.Atoi(bounds[1]); err != nil {
+				return 0, fmt.Errorf("bad range %q", part)
+			}
+		default:
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("bad value %q", part)
+			}
+			lo, hi = n, n
+			if step > 1 {
+				hi = max
+			}
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("value out of range [%d-%d] in %q", min, max, field)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// String returns the original expression
+func (c *CronSpec) String() string {
+	return c.expr
+}
+
+// dayMatches applies cron's rule: when both day fields are restricted, either may match
+func (c *CronSpec) dayMatches(t time.Time) bool {
+	domOK := c.dom.has(t.Day())
+	dowOK := c.dow.has(int(t.Weekday()))
+	if c.anyDom || c.anyDow {
+		return domOK && dowOK
+	}
+	return domOK || dowOK
+}
+
+// Next returns the first matching time strictly after t, in t's location.
+// Matching is done on wall-clock time, so across a DST fall-back the
+// repeated hour fires once, and a wall time skipped by spring-forward fires
+// at the first instant after the gap. It returns the zero time if nothing
+// matches within five years.
+func (c *CronSpec) Next(t time.Time) time.Time {
+	loc := t.Location()
+
+	// Walk a naive (UTC) copy of the wall clock so DST never shifts the search
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC).Add(time.Minute)
+	limit := wall.AddDate(5, 0, 0)
+
+	for wall.Before(limit) {
+		if !c.month.has(int(wall.Month())) {
+			wall = time.Date(wall.Year(), wall.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !c.dayMatches(wall) {
+			wall = time.Date(wall.Year(), wall.Month(), wall.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !c.hour.has(wall.Hour()) {
+			wall = time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour()+1, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !c.minute.has(wall.Minute()) {
+			wall = wall.Add(time.Minute)
+			continue
+		}
+
+		if fire := wallToLocal(wall, loc); fire.After(t) {
+			return fire
+		}
+		wall = wall.Add(time.Minute)
+	}
+
+	return time.Time{}
+}
+
+// wallToLocal interprets a naive wall-clock time in loc. Ambiguous times
+// resolve to their first occurrence; times inside a DST gap are moved forward
+// by the size of the gap.
+func wallToLocal(wall time.Time, loc *time.Location) time.Time {
+	local := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), 0, 0, loc)
+	got := time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), 0, 0, time.UTC)
+	if gap := wall.Sub(got); gap > 0 {
+		local = local.Add(gap)
+	}
+	return local
+}
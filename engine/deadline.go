@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrWorkflowTimeout is returned by steps once the workflow's deadline has passed
+var ErrWorkflowTimeout = errors.New("workflow deadline exceeded")
+
+// WithWorkflowTimeout fails the workflow with ErrWorkflowTimeout once d has
+// passed since it was first started or enqueued. The deadline is durable, so
+// resumes and requeues keep the original one. No step starts or retries after
+// it, step timeouts are capped by it, and it is the deadline of ctx.Context().
+func WithWorkflowTimeout(d time.Duration) WorkflowOption {
+	return func(o *workflowOptions) {
+		o.timeout = d
+	}
+}
+
+// StepWithContext is Step for bodies that take a context. Each attempt's
+// context is canceled with the workflow and carries the earlier of the step's
+// WithStepTimeout deadline and the workflow deadline, so a step can't outlive
+// its workflow.
+func StepWithContext[T any](ctx *Context, id string, fn func(c context.Context) (T, error), opts ...StepOption) (T, error) {
+	so := ctx.newStepOptions(opts)
+	return Step(ctx, id, func() (T, error) {
+		c, cancel, err := ctx.stepContext(id, so)
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+		defer cancel()
+		return fn(c)
+	}, opts...)
+}
+
+// stepContext derives the context of one step attempt from the run's context
+func (ctx *Context) stepContext(id string, so *stepOptions) (context.Context, context.CancelFunc, error) {
+	if so.timeout <= 0 {
+		c, cancel := context.WithCancel(ctx.cancellationContext())
+		return c, cancel, nil
+	}
+
+	// executeWithTimeout scheduled the step's durable timer before running the body
+	t, err := ctx.engine.storage.GetTimer(stepTimeoutKey(ctx.WorkflowID, id))
+	if err != nil {
+		return nil, nil, err
+	}
+	if t == nil {
+		c, cancel := context.WithCancel(ctx.cancellationContext())
+		return c, cancel, nil
+	}
+	c, cancel := context.WithDeadline(ctx.cancellationContext(), t.FireAt)
+	return c, cancel, nil
+}
+
+// doneErr explains why the run's context is done, or returns nil while it isn't
+func (ctx *Context) doneErr() error {
+	switch ctx.cancellationContext().Err() {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return fmt.Errorf("%w (deadline %s)", ErrWorkflowTimeout, ctx.deadline.Format(time.RFC3339))
+	default:
+		return ErrWorkflowCanceled
+	}
+}
+
+// SetWorkflowDeadline records a workflow's deadline unless it already has one
+func (s *Storage) SetWorkflowDeadline(workflowID string, deadline time.Time) error {
+	return s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			"UPDATE workflows SET deadline_ms = ? WHERE workflow_id = ? AND deadline_ms IS NULL",
+			deadline.UnixMilli(), workflowID,
+		)
+		return err
+	})
+}
+
+// GetWorkflowDeadline returns a workflow's deadline, or the zero time if it has none
+func (s *Storage) GetWorkflowDeadline(workflowID string) (time.Time, error) {
+	var ms sql.NullInt64
+	err := s.db.QueryRow(
+		"SELECT deadline_ms FROM workflows WHERE workflow_id = ?",
+		workflowID,
+	).Scan(&ms)
+	if err != nil && err != sql.ErrNoRows {
+		return time.Time{}, fmt.Errorf("failed to get workflow deadline: %w", err)
+	}
+	if !ms.Valid {
+		return time.Time{}, nil
+	}
+	return time.UnixMilli(ms.Int64), nil
+}
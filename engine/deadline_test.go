@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStepContextCappedByWorkflowDeadline(t *testing.T) {
+	dbPath := "./test_deadline.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	var stepDeadline time.Time
+	err = eng.Execute("deadline-1", func(ctx *Context) error {
+		_, err := StepWithContext(ctx, "export", func(c context.Context) (int, error) {
+			stepDeadline, _ = c.Deadline()
+			<-c.Done()
+			return 0, c.Err()
+		}, WithStepTimeout(time.Hour))
+		return err
+	}, WithWorkflowTimeout(100*time.Millisecond))
+
+	if !errors.Is(err, ErrWorkflowTimeout) {
+		t.Fatalf("expected ErrWorkflowTimeout, got %v", err)
+	}
+	deadline, _ := eng.storage.GetWorkflowDeadline("deadline-1")
+	if deadline.IsZero() || !stepDeadline.Equal(deadline) {
+		t.Errorf("expected step deadline %v to match the workflow's %v", stepDeadline, deadline)
+	}
+	if status, _ := eng.GetWorkflowStatus("deadline-1"); status != "failed" {
+		t.Errorf("expected failed, got %s", status)
+	}
+}
+
+func TestWorkflowDeadlineStopsUncooperativeStep(t *testing.T) {
+	dbPath := "./test_deadline_stuck.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	start := time.Now()
+	err = eng.Execute("deadline-2", func(ctx *Context) error {
+		// Ignores its context entirely
+		_, err := Step(ctx, "stuck", func() (int, error) {
+			time.Sleep(time.Second)
+			return 1, nil
+		})
+		return err
+	}, WithWorkflowTimeout(50*time.Millisecond))
+
+	if !errors.Is(err, ErrWorkflowTimeout) {
+		t.Fatalf("expected ErrWorkflowTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("workflow outlived its deadline by %v", elapsed)
+	}
+}
+
+func TestWorkflowDeadlineIsDurable(t *testing.T) {
+	dbPath := "./test_deadline_durable.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	fail := true
+	workflow := func(ctx *Context) error {
+		if fail {
+			return errors.New("crash")
+		}
+		return nil
+	}
+	eng.Execute("deadline-3", workflow, WithWorkflowTimeout(time.Hour))
+	first, _ := eng.storage.GetWorkflowDeadline("deadline-3")
+
+	// A resume keeps the deadline from the first start
+	fail = false
+	eng.storage.db.Exec("UPDATE workflows SET status = 'running' WHERE workflow_id = 'deadline-3'")
+	if err := eng.Execute("deadline-3", workflow, WithWorkflowTimeout(2*time.Hour)); err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+	if again, _ := eng.storage.GetWorkflowDeadline("deadline-3"); !again.Equal(first) {
+		t.Errorf("expected deadline %v to be kept, got %v", first, again)
+	}
+}
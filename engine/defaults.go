@@ -0,0 +1,61 @@
+package engine
+
+// Step options are resolved from the most general to the most specific, each
+// level overriding the ones before it:
+//
+//  1. the engine's WithDefaultStepOptions
+//  2. the step defaults given to Register for the run's workflow name
+//  3. the run's WithStepDefaults
+//  4. the options passed to the step itself
+//
+// Defaults apply to steps a workflow runs with Step, StepWithContext,
+// StepStream and Map. Sleeps, signal waits and child starts are unaffected,
+// so a default timeout can't cut a signal wait short.
+
+// WithDefaultStepOptions sets options applied to every step the engine runs,
+// typically WithRetry and WithStepTimeout
+func WithDefaultStepOptions(opts ...StepOption) EngineOption {
+	return func(e *Engine) {
+		e.stepDefaults = append(e.stepDefaults, opts...)
+	}
+}
+
+// WithStepDefaults sets options applied to every step of this run,
+// overriding the engine's and the registered workflow's defaults. They are
+// not persisted: pass them again when resuming.
+func WithStepDefaults(opts ...StepOption) WorkflowOption {
+	return func(o *workflowOptions) {
+		o.stepDefaults = append(o.stepDefaults, opts...)
+	}
+}
+
+// resolveStepDefaults returns the step defaults of a run, engine level first
+func (e *Engine) resolveStepDefaults(workflowID string, o *workflowOptions) []StepOption {
+	defaults := append([]StepOption(nil), e.stepDefaults...)
+
+	e.registryMu.RLock()
+	hasRegistered := len(e.registryDefaults) > 0
+	e.registryMu.RUnlock()
+	if hasRegistered {
+		name := o.workflowName
+		if name == "" {
+			if runs, err := e.storage.ListWorkflows(WorkflowFilter{}, workflowID); err == nil && len(runs) == 1 {
+				name = runs[0].Name
+			}
+		}
+		e.registryMu.RLock()
+		defaults = append(defaults, e.registryDefaults[name]...)
+		e.registryMu.RUnlock()
+	}
+
+	return append(defaults, o.stepDefaults...)
+}
+
+// newStepOptions resolves a step's options on top of the run's defaults
+func (ctx *Context) newStepOptions(opts []StepOption) *stepOptions {
+	so := newStepOptions(opts)
+	if so.kind != StepKindStep || len(ctx.stepDefaults) == 0 {
+		return so
+	}
+	return newStepOptions(append(append([]StepOption(nil), ctx.stepDefaults...), opts...))
+}
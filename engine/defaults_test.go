@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStepDefaultsPrecedence(t *testing.T) {
+	dbPath := "./test_defaults.db"
+	defer os.Remove(dbPath)
+
+	retries := func(n int) StepOption {
+		return WithRetry(RetryPolicy{MaxAttempts: n, InitialInterval: time.Millisecond})
+	}
+	engine, err := NewEngine(dbPath, WithDefaultStepOptions(retries(3)))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	// attempts counts calls of a step that always fails
+	attempts := func(ctx *Context, id string, opts ...StepOption) int {
+		n := 0
+		Step(ctx, id, func() (int, error) {
+			n++
+			return 0, errors.New("unavailable")
+		}, opts...)
+		return n
+	}
+
+	var engineLevel, stepLevel, runLevel, registered int
+	engine.Execute("defaults-1", func(ctx *Context) error {
+		engineLevel = attempts(ctx, "engine-default")
+		stepLevel = attempts(ctx, "step-override", retries(1))
+		return nil
+	})
+	engine.Execute("defaults-2", func(ctx *Context) error {
+		runLevel = attempts(ctx, "run-default")
+		return nil
+	}, WithStepDefaults(retries(2)))
+
+	engine.Register("registered", func(ctx *Context) error {
+		registered = attempts(ctx, "registered-default")
+		return nil
+	}, retries(4))
+	if err := engine.Enqueue("defaults-3", "registered", nil); err != nil {
+		t.Fatalf("failed to enqueue: %v", err)
+	}
+	engine.StartWorker(WorkerConfig{PollInterval: 10 * time.Millisecond})
+	waitForStatus(t, engine, "defaults-3", "completed", func() bool { return true })
+
+	if engineLevel != 3 || stepLevel != 1 || runLevel != 2 || registered != 4 {
+		t.Errorf("expected 3, 1, 2 and 4 attempts, got %d, %d, %d and %d",
+			engineLevel, stepLevel, runLevel, registered)
+	}
+
+	// A default timeout must not apply to signal waits
+	done := make(chan error, 1)
+	go func() {
+		done <- engine.Execute("defaults-4", func(ctx *Context) error {
+			_, err := WaitForSignal[string](ctx, "go")
+			return err
+		}, WithStepDefaults(WithStepTimeout(20*time.Millisecond)))
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if err := engine.Signal("defaults-4", "go", "now"); err != nil {
+		t.Fatalf("failed to signal: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("expected the signal wait to outlive the default step timeout, got %v", err)
+	}
+}
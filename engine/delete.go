@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrWorkflowNotTerminal is returned by DeleteWorkflow with OnlyIfTerminal
+// for a run that hasn't completed, failed or been canceled
+var ErrWorkflowNotTerminal = errors.New("workflow is not terminal")
+
+// DeleteOptions configures DeleteWorkflow
+type DeleteOptions struct {
+	// OnlyIfTerminal refuses to delete runs that are still queued or running
+	OnlyIfTerminal bool
+}
+
+// workflowTables are the tables holding a run's rows besides workflows,
+// deleted in this order. Step deletions release their blobs (see blobs.go).
+var workflowTables = []string{
+	"step_attempts",
+	"step_checkpoints",
+	"step_sequences",
+	"auto_steps",
+	"steps",
+	"timers",
+	"signals",
+	"updates",
+	"continuations",
+	"workflow_attributes",
+	"workflow_logs",
+	"workflow_counters",
+	"workflow_events",
+}
+
+// DeleteWorkflow permanently deletes a run with its steps, attempts,
+// checkpoints, timers, signals, updates, logs and events, and the stored
+// outputs no other run shares, in one transaction, e.g. for erasure
+// requests. Child workflows are separate runs and must be deleted on their
+// own. A run deleted while executing is canceled, in another process as
+// with CancelWorkflow.
+func (e *Engine) DeleteWorkflow(workflowID string, opts DeleteOptions) error {
+	if err := e.storage.DeleteWorkflow(workflowID, opts.OnlyIfTerminal); err != nil {
+		return err
+	}
+
+	e.stopRunning(workflowID)
+	fmt.Printf("[DELETE] workflow %s deleted\n", workflowID)
+	return nil
+}
+
+// DeleteWorkflow deletes a run and every row belonging to it. With
+// onlyIfTerminal it fails with ErrWorkflowNotTerminal unless the run is
+// completed, failed or canceled.
+func (s *Storage) DeleteWorkflow(workflowID string, onlyIfTerminal bool) error {
+	return s.retryOnBusy(func() error {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var status string
+		err = tx.QueryRow("SELECT status FROM workflows WHERE workflow_id = ?", workflowID).Scan(&status)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: %s", ErrWorkflowNotFound, workflowID)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get workflow status: %w", err)
+		}
+		if onlyIfTerminal && status != "completed" && status != "failed" && status != "canceled" {
+			return fmt.Errorf("%w: %s is %s", ErrWorkflowNotTerminal, workflowID, status)
+		}
+
+		for _, table := range workflowTables {
+			if _, err := tx.Exec("DELETE FROM "+table+" WHERE workflow_id = ?", workflowID); err != nil {
+				return fmt.Errorf("failed to delete %s: %w", table, err)
+			}
+		}
+		if _, err := tx.Exec("DELETE FROM workflows WHERE workflow_id = ?", workflowID); err != nil {
+			return fmt.Errorf("failed to delete workflow: %w", err)
+		}
+		return tx.Commit()
+	})
+}
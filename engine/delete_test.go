@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDeleteWorkflowCascades(t *testing.T) {
+	dbPath := "./test_delete.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	profile := func(ctx *Context) error {
+		if _, err := Step(ctx, "load-profile", func() (string, error) {
+			return "ada@example.com " + ctx.WorkflowID, nil
+		}); err != nil {
+			return err
+		}
+		if _, err := Step(ctx, "shared", func() (string, error) { return "shared output", nil }); err != nil {
+			return err
+		}
+		if err := ctx.SetSearchAttribute("customer", "ada"); err != nil {
+			return err
+		}
+		if _, err := ctx.Counter("emails").Add(1); err != nil {
+			return err
+		}
+		return ctx.StartTimer("remind", time.Hour)
+	}
+	for _, id := range []string{"user-ada", "user-bob"} {
+		if err := eng.Execute(id, profile); err != nil {
+			t.Fatalf("workflow %s failed: %v", id, err)
+		}
+	}
+	if err := eng.Signal("user-ada", "update", "new address"); err != nil {
+		t.Fatalf("failed to signal: %v", err)
+	}
+
+	eng.Register("profile", profile)
+	if err := eng.Enqueue("user-eve", "profile", nil); err != nil {
+		t.Fatalf("failed to enqueue: %v", err)
+	}
+	if err := eng.DeleteWorkflow("user-eve", DeleteOptions{OnlyIfTerminal: true}); !errors.Is(err, ErrWorkflowNotTerminal) {
+		t.Errorf("expected a queued run not to be deleted, got %v", err)
+	}
+
+	if err := eng.DeleteWorkflow("user-ada", DeleteOptions{OnlyIfTerminal: true}); err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+	for _, table := range append(workflowTables, "workflows") {
+		var n int
+		eng.storage.db.QueryRow("SELECT COUNT(*) FROM " + table + " WHERE workflow_id = 'user-ada'").Scan(&n)
+		if n != 0 {
+			t.Errorf("expected no rows for user-ada in %s, got %d", table, n)
+		}
+	}
+	var personal, shared int
+	eng.storage.db.QueryRow("SELECT COUNT(*) FROM blobs WHERE CAST(data AS TEXT) LIKE '%user-ada%'").Scan(&personal)
+	eng.storage.db.QueryRow("SELECT COUNT(*) FROM blobs WHERE CAST(data AS TEXT) LIKE '%shared output%'").Scan(&shared)
+	if personal != 0 || shared != 1 {
+		t.Errorf("expected only the deleted run's own outputs to be removed, got %d personal and %d shared blobs", personal, shared)
+	}
+	if history, _ := eng.GetHistory("user-bob"); len(history) == 0 {
+		t.Error("expected other runs to be kept")
+	}
+
+	if err := eng.DeleteWorkflow("user-ada", DeleteOptions{}); !errors.Is(err, ErrWorkflowNotFound) {
+		t.Errorf("expected deleting again to report not found, got %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrMissingDependency is returned by Dependency when nothing was provided
+// under the name, or the value has another type
+var ErrMissingDependency = errors.New("missing dependency")
+
+// dependencies holds runtime values provided to workflows by name
+type dependencies struct {
+	mu     sync.RWMutex
+	values map[string]interface{}
+}
+
+// WithDependency provides a runtime dependency, such as a database pool or
+// an API client, to every workflow the engine runs. Dependencies are not
+// persisted: each worker provides its own, so a run resumed elsewhere uses
+// that worker's, and tests can provide fakes.
+func WithDependency(name string, v interface{}) EngineOption {
+	return func(e *Engine) {
+		e.Provide(name, v)
+	}
+}
+
+// Provide adds or replaces a dependency after the engine is created
+func (e *Engine) Provide(name string, v interface{}) {
+	e.deps.mu.Lock()
+	defer e.deps.mu.Unlock()
+	if e.deps.values == nil {
+		e.deps.values = make(map[string]interface{})
+	}
+	e.deps.values[name] = v
+}
+
+// Dependency returns the dependency provided under name, or nil
+func (ctx *Context) Dependency(name string) interface{} {
+	ctx.engine.deps.mu.RLock()
+	defer ctx.engine.deps.mu.RUnlock()
+	return ctx.engine.deps.values[name]
+}
+
+// Dependency returns the dependency provided under name as a T
+func Dependency[T any](ctx *Context, name string) (T, error) {
+	v, ok := ctx.Dependency(name).(T)
+	if !ok {
+		var zero T
+		return zero, fmt.Errorf("%w: %s (%T)", ErrMissingDependency, name, zero)
+	}
+	return v, nil
+}
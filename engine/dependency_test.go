@@ -0,0 +1,53 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+// paymentsAPI is the kind of client a workflow gets from its worker
+type paymentsAPI interface {
+	Charge(cents int) (string, error)
+}
+
+type fakePayments struct{ charged int }
+
+func (f *fakePayments) Charge(cents int) (string, error) {
+	f.charged += cents
+	return "ch_1", nil
+}
+
+func TestDependencies(t *testing.T) {
+	dbPath := "./test_dependency.db"
+	defer os.Remove(dbPath)
+
+	fake := &fakePayments{}
+	engine, err := NewEngine(dbPath, WithDependency("payments", fake))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	var missingErr error
+	err = engine.Execute("deps-1", func(ctx *Context) error {
+		_, missingErr = Dependency[paymentsAPI](ctx, "ledger")
+		_, err := Step(ctx, "charge", func() (string, error) {
+			payments, err := Dependency[paymentsAPI](ctx, "payments")
+			if err != nil {
+				return "", err
+			}
+			return payments.Charge(500)
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("failed to execute: %v", err)
+	}
+	if fake.charged != 500 {
+		t.Errorf("expected the fake to be charged 500, got %d", fake.charged)
+	}
+	if !errors.Is(missingErr, ErrMissingDependency) {
+		t.Errorf("expected ErrMissingDependency, got %v", missingErr)
+	}
+}
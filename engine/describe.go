@@ -0,0 +1,227 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Node kinds in a workflow description
+const (
+	NodeWorkflow = "workflow" // a run; the root, or a child started by a step
+	NodeStep     = "step"
+	NodeMap      = "map"      // the steps of one Map call
+	NodeParallel = "parallel" // steps or maps whose executions overlapped, e.g. started with Go
+	NodeAttempt  = "attempt"  // one execution of a step that ran more than once
+)
+
+// maxDescribeDepth bounds how deep child workflows are expanded
+const maxDescribeDepth = 8
+
+// DescribeNode is one node of a run's step tree
+type DescribeNode struct {
+	Kind        string
+	Name        string // workflow ID, step ID, Map prefix or "attempt N"
+	Status      string
+	Error       string
+	StartedAt   time.Time
+	CompletedAt time.Time // zero while anything below is still running
+	Children    []*DescribeNode
+}
+
+// Duration returns how long the node ran, or zero if it has not finished
+func (n *DescribeNode) Duration() time.Duration {
+	if n.CompletedAt.IsZero() || n.StartedAt.IsZero() {
+		return 0
+	}
+	return n.CompletedAt.Sub(n.StartedAt)
+}
+
+// DescribeWorkflow returns a run's steps as a tree: Map calls group their
+// steps, steps that ran concurrently form parallel groups, steps that ran
+// more than once list their attempts, and child workflows are expanded under
+// the step that started them
+func (e *Engine) DescribeWorkflow(workflowID string) (*DescribeNode, error) {
+	run, err := e.GetWorkflow(workflowID)
+	if err != nil {
+		return nil, err
+	}
+	return e.describeRun(run, 0)
+}
+
+// describeRun builds the subtree of one run
+func (e *Engine) describeRun(run *WorkflowInfo, depth int) (*DescribeNode, error) {
+	node := &DescribeNode{
+		Kind:      NodeWorkflow,
+		Name:      run.WorkflowID,
+		Status:    run.Status,
+		StartedAt: run.CreatedAt,
+	}
+	if run.Status == "completed" || run.Status == "failed" || run.Status == "canceled" {
+		node.CompletedAt = run.UpdatedAt
+	}
+	if depth >= maxDescribeDepth {
+		return node, nil
+	}
+
+	steps, err := e.storage.ListSteps(run.WorkflowID)
+	if err != nil {
+		return nil, err
+	}
+	children, err := e.storage.ListWorkflows(WorkflowFilter{ParentIDs: []string{run.WorkflowID}})
+	if err != nil {
+		return nil, err
+	}
+	childByID := make(map[string]*WorkflowInfo, len(children))
+	for i := range children {
+		childByID[children[i].WorkflowID] = &children[i]
+	}
+
+	// Steps in sequence order, with each Map call folded into one node
+	var items []*DescribeNode
+	maps := make(map[string]*DescribeNode)
+	for _, st := range steps {
+		step := stepNode(&st)
+		if st.Kind == StepKindChildStart {
+			childID := ChildWorkflowID(run.WorkflowID, strings.TrimPrefix(st.StepID, "start-child:"))
+			if child, ok := childByID[childID]; ok {
+				delete(childByID, childID)
+				sub, err := e.describeRun(child, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				step.Children = append(step.Children, sub)
+			}
+		}
+
+		if st.Group == "" {
+			items = append(items, step)
+			continue
+		}
+		group, ok := maps[st.Group]
+		if !ok {
+			group = &DescribeNode{Kind: NodeMap, Name: st.Group}
+			maps[st.Group] = group
+			items = append(items, group)
+		}
+		group.Children = append(group.Children, step)
+	}
+	for _, group := range maps {
+		summarize(group)
+	}
+
+	node.Children = groupOverlapping(items)
+
+	// Runs recorded before timestamps carried milliseconds are precise only to
+	// the second, so derive the run's span from its steps
+	if len(node.Children) > 0 {
+		status := node.Status
+		node.CompletedAt = time.Time{}
+		summarize(node)
+		node.Status = status
+		if status != "completed" && status != "failed" && status != "canceled" {
+			node.CompletedAt = time.Time{}
+		}
+	}
+
+	// Children started other than by StartChildDetached go last
+	for _, child := range children {
+		if _, ok := childByID[child.WorkflowID]; !ok {
+			continue
+		}
+		sub, err := e.describeRun(childByID[child.WorkflowID], depth+1)
+		if err != nil {
+			return nil, err
+		}
+		node.Children = append(node.Children, sub)
+	}
+	return node, nil
+}
+
+// stepNode describes one step, listing its attempts if it ran more than once
+func stepNode(st *StepRecord) *DescribeNode {
+	node := &DescribeNode{
+		Kind:        NodeStep,
+		Name:        st.StepID,
+		Status:      st.Status,
+		Error:       st.Error,
+		StartedAt:   st.StartedAt,
+		CompletedAt: st.CompletedAt,
+	}
+	if len(st.Attempts) > 1 {
+		for _, a := range st.Attempts {
+			node.Children = append(node.Children, &DescribeNode{
+				Kind:        NodeAttempt,
+				Name:        fmt.Sprintf("attempt %d", a.Attempt),
+				Status:      a.Status,
+				Error:       a.Error,
+				StartedAt:   a.StartedAt,
+				CompletedAt: a.CompletedAt,
+			})
+		}
+	}
+	return node
+}
+
+// groupOverlapping wraps runs of consecutive items whose executions
+// overlapped in a parallel node. Go branches aren't recorded, so overlap in
+// time is how concurrent steps are recognised.
+func groupOverlapping(items []*DescribeNode) []*DescribeNode {
+	var out []*DescribeNode
+	var current []*DescribeNode
+	var currentEnd time.Time
+
+	flush := func() {
+		if len(current) == 1 {
+			out = append(out, current[0])
+		} else if len(current) > 1 {
+			group := &DescribeNode{Kind: NodeParallel, Name: fmt.Sprintf("%d concurrent", len(current)), Children: current}
+			summarize(group)
+			out = append(out, group)
+		}
+		current = nil
+	}
+
+	for _, item := range items {
+		end := item.CompletedAt
+		if end.IsZero() {
+			end = time.Now()
+		}
+		if len(current) > 0 && !item.StartedAt.Before(currentEnd) {
+			flush()
+		}
+		if len(current) == 0 || end.After(currentEnd) {
+			currentEnd = end
+		}
+		current = append(current, item)
+	}
+	flush()
+	return out
+}
+
+// summarize derives a group's status and span from its children
+func summarize(group *DescribeNode) {
+	group.Status = "completed"
+	running := false
+	for i, c := range group.Children {
+		if i == 0 || c.StartedAt.Before(group.StartedAt) {
+			group.StartedAt = c.StartedAt
+		}
+		if c.CompletedAt.IsZero() {
+			running = true
+		} else if c.CompletedAt.After(group.CompletedAt) {
+			group.CompletedAt = c.CompletedAt
+		}
+		switch c.Status {
+		case "failed", "canceled":
+			if group.Status != "in_progress" {
+				group.Status = c.Status
+			}
+		case "in_progress":
+			group.Status = "in_progress"
+		}
+	}
+	if running {
+		group.CompletedAt = time.Time{}
+	}
+}
@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDescribeWorkflowTree(t *testing.T) {
+	dbPath := "./test_describe.db"
+	defer os.Remove(dbPath)
+
+	engine, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	flaky := 0
+	engine.Execute("describe-1", func(ctx *Context) error {
+		Step(ctx, "prepare", func() (int, error) { return 1, nil })
+
+		Map(ctx, "resize", []int{1, 2, 3}, func(i int, n int) (int, error) {
+			time.Sleep(5 * time.Millisecond)
+			return n * 2, nil
+		})
+
+		for _, id := range []string{"left", "right"} {
+			ctx.Go(func() error {
+				_, err := Step(ctx, id, func() (int, error) {
+					time.Sleep(30 * time.Millisecond)
+					return 1, nil
+				})
+				return err
+			})
+		}
+		if err := ctx.Wait(); err != nil {
+			return err
+		}
+
+		Step(ctx, "upload", func() (int, error) {
+			flaky++
+			if flaky == 1 {
+				return 0, errors.New("timeout")
+			}
+			return 1, nil
+		}, WithRetry(RetryPolicy{MaxAttempts: 2, InitialInterval: time.Millisecond}))
+
+		_, err := ctx.StartChildDetached("notify", "notify", nil)
+		return err
+	})
+
+	tree, err := engine.DescribeWorkflow("describe-1")
+	if err != nil {
+		t.Fatalf("failed to describe: %v", err)
+	}
+	if tree.Kind != NodeWorkflow || tree.Status != "completed" || tree.Duration() <= 0 {
+		t.Errorf("unexpected root: %+v", tree)
+	}
+
+	kinds := make([]string, len(tree.Children))
+	for i, c := range tree.Children {
+		kinds[i] = c.Kind + ":" + c.Name
+	}
+	want := []string{"step:prepare", "map:resize", "parallel:2 concurrent", "step:upload", "step:start-child:notify"}
+	if len(kinds) != len(want) {
+		t.Fatalf("expected %v, got %v", want, kinds)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, kinds)
+		}
+	}
+
+	if resize := tree.Children[1]; len(resize.Children) != 3 || resize.Status != "completed" || resize.Duration() <= 0 {
+		t.Errorf("unexpected map node: %+v", resize)
+	}
+	if upload := tree.Children[3]; len(upload.Children) != 2 || upload.Children[0].Status != "failed" ||
+		upload.Children[0].Error != "timeout" {
+		t.Errorf("expected upload's attempts, got %+v", upload.Children)
+	}
+	start := tree.Children[4]
+	if len(start.Children) != 1 || start.Children[0].Name != "describe-1/notify" || start.Children[0].Status != "queued" {
+		t.Errorf("expected the child run under its start step, got %+v", start.Children)
+	}
+
+	if _, err := engine.DescribeWorkflow("missing"); !errors.Is(err, ErrWorkflowNotFound) {
+		t.Errorf("expected ErrWorkflowNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// StepChange classifies how a step differs between two runs
+type StepChange string
+
+const (
+	StepUnchanged    StepChange = "same"
+	StepChanged      StepChange = "changed"
+	StepOnlyInFirst  StepChange = "removed"
+	StepOnlyInSecond StepChange = "added"
+)
+
+// StepDiff compares one step ID across two runs
+type StepDiff struct {
+	StepID      string
+	Change      StepChange
+	Reordered   bool        // present in both runs but at a different point in the sequence
+	First       *StepRecord // nil if the step only ran in the second run
+	Second      *StepRecord // nil if the step only ran in the first run
+	Differences []string    // human-readable field changes, e.g. "status: completed -> failed"
+}
+
+// WorkflowDiff is the step-by-step comparison of two workflow runs
+type WorkflowDiff struct {
+	First  string
+	Second string
+	Steps  []StepDiff
+}
+
+// Identical reports whether both runs executed the same steps, in the same
+// order, with the same status, output and error
+func (d *WorkflowDiff) Identical() bool {
+	for _, s := range d.Steps {
+		if s.Change != StepUnchanged || s.Reordered {
+			return false
+		}
+	}
+	return true
+}
+
+// DiffWorkflows compares the step histories of two workflow runs
+func (e *Engine) DiffWorkflows(first, second string) (*WorkflowDiff, error) {
+	a, err := e.GetHistory(first)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load history of %s: %w", first, err)
+	}
+	b, err := e.GetHistory(second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load history of %s: %w", second, err)
+	}
+
+	return &WorkflowDiff{First: first, Second: second, Steps: DiffHistories(a, b)}, nil
+}
+
+// DiffHistories aligns two step sequences by step ID and compares matching steps.
+// Steps on the longest common subsequence keep their order; steps found in both
+// runs but off that subsequence are reported once, as Reordered, at their
+// position in the first run.
+func DiffHistories(first, second []StepRecord) []StepDiff {
+	// lcs[i][j] is the common subsequence length of first[i:] and second[j:]
+	lcs := make([][]int, len(first)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(second)+1)
+	}
+	for i := len(first) - 1; i >= 0; i-- {
+		for j := len(second) - 1; j >= 0; j-- {
+			if first[i].StepID == second[j].StepID {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	inFirst := make(map[string]bool, len(first))
+	for i := range first {
+		inFirst[first[i].StepID] = true
+	}
+	secondByID := make(map[string]*StepRecord, len(second))
+	for j := range second {
+		secondByID[second[j].StepID] = &second[j]
+	}
+
+	var diffs []StepDiff
+	i, j := 0, 0
+	for i < len(first) || j < len(second) {
+		switch {
+		case i < len(first) && j < len(second) && first[i].StepID == second[j].StepID:
+			diffs = append(diffs, compareSteps(&first[i], &second[j], false))
+			i++
+			j++
+		case j == len(second) || (i < len(first) && lcs[i+1][j] >= lcs[i][j+1]):
+			a := &first[i]
+			if b, ok := secondByID[a.StepID]; ok {
+				diffs = append(diffs, compareSteps(a, b, true))
+			} else {
+				diffs = append(diffs, StepDiff{StepID: a.StepID, Change: StepOnlyInFirst, First: a})
+			}
+			i++
+		default:
+			// Steps also in the first run are reported at their position there
+			if !inFirst[second[j].StepID] {
+				diffs = append(diffs, compareSteps(nil, &second[j], false))
+			}
+			j++
+		}
+	}
+
+	return diffs
+}
+
+// compareSteps builds the diff for a step ID; a nil first means it only ran in the second run
+func compareSteps(a, b *StepRecord, reordered bool) StepDiff {
+	if a == nil {
+		return StepDiff{StepID: b.StepID, Change: StepOnlyInSecond, Second: b}
+	}
+
+	d := StepDiff{StepID: a.StepID, Change: StepUnchanged, Reordered: reordered, First: a, Second: b}
+	if a.Status != b.Status {
+		d.Differences = append(d.Differences, fmt.Sprintf("status: %s -> %s", a.Status, b.Status))
+	}
+	if !bytes.Equal(a.Output, b.Output) {
+		d.Differences = append(d.Differences, fmt.Sprintf("output: %s -> %s", displayOutput(a.Output), displayOutput(b.Output)))
+	}
+	if a.Error != b.Error {
+		d.Differences = append(d.Differences, fmt.Sprintf("error: %q -> %q", a.Error, b.Error))
+	}
+	if len(d.Differences) > 0 {
+		d.Change = StepChanged
+	}
+	return d
+}
+
+// displayOutput renders a step's JSON output, marking steps that produced none
+func displayOutput(output []byte) string {
+	if len(output) == 0 {
+		return "(none)"
+	}
+	return string(output)
+}
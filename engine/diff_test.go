@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestDiffWorkflows(t *testing.T) {
+	dbPath := "./test_diff.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	// Same workflow code, but the second run sees a different price and a failing charge
+	run := func(workflowID string, price int, chargeErr error) {
+		eng.Execute(workflowID, func(ctx *Context) error {
+			if _, err := Step(ctx, "load-cart", func() (int, error) { return 3, nil }); err != nil {
+				return err
+			}
+			if _, err := Step(ctx, "quote", func() (int, error) { return price, nil }); err != nil {
+				return err
+			}
+			if price > 100 {
+				if _, err := Step(ctx, "fraud-check", func() (bool, error) { return true, nil }); err != nil {
+					return err
+				}
+			}
+			_, err := Step(ctx, "charge", func() (string, error) { return "ch_1", chargeErr })
+			return err
+		})
+	}
+	run("order-1", 50, nil)
+	run("order-2", 150, errors.New("card declined"))
+
+	diff, err := eng.DiffWorkflows("order-1", "order-2")
+	if err != nil {
+		t.Fatalf("failed to diff: %v", err)
+	}
+	if diff.Identical() {
+		t.Fatal("expected runs to differ")
+	}
+
+	want := []struct {
+		id     string
+		change StepChange
+	}{
+		{"load-cart", StepUnchanged},
+		{"quote", StepChanged},
+		{"fraud-check", StepOnlyInSecond},
+		{"charge", StepChanged},
+	}
+	if len(diff.Steps) != len(want) {
+		t.Fatalf("expected %d steps, got %+v", len(want), diff.Steps)
+	}
+	for i, w := range want {
+		got := diff.Steps[i]
+		if got.StepID != w.id || got.Change != w.change || got.Reordered {
+			t.Errorf("step %d: expected %s %s, got %s %s (reordered=%v)",
+				i, w.id, w.change, got.StepID, got.Change, got.Reordered)
+		}
+	}
+	if d := diff.Steps[3].Differences; len(d) != 3 {
+		t.Errorf("expected status, output and error differences for charge, got %v", d)
+	}
+	if diff.Steps[0].First.CompletedAt.IsZero() || diff.Steps[0].First.Duration() < 0 {
+		t.Errorf("expected timings on history, got %+v", diff.Steps[0].First)
+	}
+
+	self, err := eng.DiffWorkflows("order-1", "order-1")
+	if err != nil {
+		t.Fatalf("failed to diff: %v", err)
+	}
+	if !self.Identical() {
+		t.Errorf("expected a run to be identical to itself, got %+v", self.Steps)
+	}
+
+	if _, err := eng.DiffWorkflows("order-1", "missing"); !errors.Is(err, ErrWorkflowNotFound) {
+		t.Errorf("expected ErrWorkflowNotFound, got %v", err)
+	}
+}
+
+func TestDiffHistoriesReordered(t *testing.T) {
+	steps := func(ids ...string) []StepRecord {
+		var out []StepRecord
+		for i, id := range ids {
+			out = append(out, StepRecord{StepID: id, SequenceNum: int64(i + 1), Status: "completed"})
+		}
+		return out
+	}
+
+	diffs := DiffHistories(steps("a", "b", "c", "d"), steps("a", "c", "d", "b", "e"))
+
+	got := make([]string, len(diffs))
+	for i, d := range diffs {
+		got[i] = d.StepID + ":" + string(d.Change)
+		if d.Reordered {
+			got[i] += "*"
+		}
+	}
+	want := []string{"a:same", "b:same*", "c:same", "d:same", "e:added"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("expected %v, got %v", want, got)
+			break
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrDriverNotRegistered is returned by NewEngine for a DSN whose database
+// driver the program doesn't import
+var ErrDriverNotRegistered = errors.New("database driver not registered")
+
+// LibSQLDriver is the database/sql driver name remote libSQL (Turso) DSNs are
+// opened with. The engine doesn't link a libSQL client itself; import one
+// that registers under this name, such as
+// github.com/tursodatabase/libsql-client-go/libsql.
+const LibSQLDriver = "libsql"
+
+// dataSource is a parsed database location
+type dataSource struct {
+	driver string
+	source string
+	remote bool // served over the network, so file-level PRAGMAs don't apply
+}
+
+// parseDSN accepts what NewEngine does:
+//
+//	./workflows.db                         a file path
+//	file:workflows.db?_pragma=...          a SQLite URI, with modernc.org/sqlite query parameters
+//	sqlite:///var/lib/app/workflows.db     the same, written as a URL
+//	libsql://db-org.turso.io?authToken=... a remote libSQL database (also http(s):// and ws(s)://)
+//
+// A LiteFS mount needs no special form: open the database by its path
+// inside the mount, e.g. /litefs/workflows.db.
+func parseDSN(dsn string) dataSource {
+	scheme, rest, ok := strings.Cut(dsn, "://")
+	if !ok {
+		return dataSource{driver: "sqlite", source: dsn}
+	}
+	switch strings.ToLower(scheme) {
+	case "sqlite", "sqlite3":
+		return dataSource{driver: "sqlite", source: "file:" + rest}
+	case "libsql", "http", "https", "ws", "wss":
+		return dataSource{driver: LibSQLDriver, source: dsn, remote: true}
+	}
+	return dataSource{driver: "sqlite", source: dsn}
+}
+
+// open opens the data source, failing early if its driver isn't linked in
+func (ds dataSource) open() (*sql.DB, error) {
+	if !slices.Contains(sql.Drivers(), ds.driver) {
+		return nil, fmt.Errorf("%w: %q (import a libSQL client that registers it, such as github.com/tursodatabase/libsql-client-go/libsql)",
+			ErrDriverNotRegistered, ds.driver)
+	}
+	return sql.Open(ds.driver, ds.source)
+}
+
+// liteFSPrimary reports whether a local database lives on a LiteFS replica,
+// and which node is primary. LiteFS only accepts writes on the primary and
+// marks replicas with a .primary file in the mount holding its hostname.
+func liteFSPrimary(ds dataSource) (string, bool) {
+	if ds.remote {
+		return "", false
+	}
+	path := strings.TrimPrefix(ds.source, "file:")
+	path, _, _ = strings.Cut(path, "?")
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(path), ".primary"))
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(string(data)), true
+}
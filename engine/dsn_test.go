@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"modernc.org/sqlite"
+)
+
+func TestParseDSN(t *testing.T) {
+	cases := []struct {
+		dsn  string
+		want dataSource
+	}{
+		{"./workflows.db", dataSource{driver: "sqlite", source: "./workflows.db"}},
+		{"file:workflows.db?_pragma=synchronous(NORMAL)", dataSource{driver: "sqlite", source: "file:workflows.db?_pragma=synchronous(NORMAL)"}},
+		{"sqlite:///var/lib/app/workflows.db", dataSource{driver: "sqlite", source: "file:/var/lib/app/workflows.db"}},
+		{"libsql://db-org.turso.io?authToken=t", dataSource{driver: LibSQLDriver, source: "libsql://db-org.turso.io?authToken=t", remote: true}},
+		{"https://db-org.turso.io", dataSource{driver: LibSQLDriver, source: "https://db-org.turso.io", remote: true}},
+	}
+	for _, tc := range cases {
+		if got := parseDSN(tc.dsn); got != tc.want {
+			t.Errorf("parseDSN(%q) = %+v, want %+v", tc.dsn, got, tc.want)
+		}
+	}
+}
+
+func TestEngineWithSQLiteURI(t *testing.T) {
+	dbPath := "./test_dsn.db"
+	defer os.Remove(dbPath)
+
+	engine, err := NewEngine("file:" + dbPath + "?_pragma=synchronous(NORMAL)")
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	if err := engine.Execute("dsn-1", func(ctx *Context) error {
+		_, err := Step(ctx, "one", func() (int, error) { return 1, nil })
+		return err
+	}); err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	var synchronous int
+	engine.storage.db.QueryRow("PRAGMA synchronous").Scan(&synchronous)
+	if synchronous != 1 {
+		t.Errorf("expected the DSN's synchronous=NORMAL pragma to apply, got %d", synchronous)
+	}
+}
+
+// localLibSQL stands in for a libSQL client in tests, serving libsql:// URLs
+// from local files
+type localLibSQL struct{}
+
+func (localLibSQL) Open(name string) (driver.Conn, error) {
+	return (&sqlite.Driver{}).Open(strings.TrimPrefix(name, "libsql://"))
+}
+
+var registerLibSQL sync.Once
+
+func TestEngineWithRemoteLibSQL(t *testing.T) {
+	dbPath := "./test_dsn_remote.db"
+	defer os.Remove(dbPath)
+
+	if !slices.Contains(sql.Drivers(), LibSQLDriver) {
+		if _, err := NewEngine("libsql://" + dbPath); !errors.Is(err, ErrDriverNotRegistered) {
+			t.Fatalf("expected ErrDriverNotRegistered without a libSQL driver, got %v", err)
+		}
+	}
+
+	registerLibSQL.Do(func() { sql.Register(LibSQLDriver, localLibSQL{}) })
+	engine, err := NewEngine("libsql://" + dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	if err := engine.Execute("remote-1", func(ctx *Context) error {
+		_, err := Step(ctx, "one", func() (int, error) { return 1, nil })
+		return err
+	}); err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	if status, _ := engine.GetWorkflowStatus("remote-1"); status != "completed" {
+		t.Errorf("expected completed, got %s", status)
+	}
+
+	// File-level pragmas are left to the remote server
+	var mode string
+	engine.storage.db.QueryRow("PRAGMA journal_mode").Scan(&mode)
+	if mode == "wal" {
+		t.Error("expected the engine not to switch a remote database to WAL")
+	}
+}
+
+func TestLiteFSReplicaDetected(t *testing.T) {
+	dir := t.TempDir()
+	if _, ok := liteFSPrimary(parseDSN(dir + "/workflows.db")); ok {
+		t.Error("expected no replica without a .primary file")
+	}
+	os.WriteFile(dir+"/.primary", []byte("node-1\n"), 0o644)
+	if primary, ok := liteFSPrimary(parseDSN("sqlite://" + dir + "/workflows.db?_pragma=busy_timeout(100)")); !ok || primary != "node-1" {
+		t.Errorf("expected a replica of node-1, got %q, %v", primary, ok)
+	}
+}
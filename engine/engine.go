@@ -1,72 +1,368 @@
 package engine
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
 )
 
 // Engine is the main durable execution engine
 type Engine struct {
-	storage *Storage
+	storage    *Storage
+	reads      *Storage // serves query-heavy APIs; the primary unless WithReadReplica
+	replicaDSN string
+	workerID   string
+	executor   Executor // workerID with this process's host and PID, recorded on steps
+	shardCount int
+
+	// Admission control (see backpressure.go)
+	maxPending       int
+	admissionTimeout time.Duration
+
+	breakers *circuitBreakers
+	timers   timerHandlers
+	schemas  schemaRegistry
+	codec    Codec
+	deps     dependencies
+
+	outputLimit  OutputLimit
+	runLimits    RunLimits
+	maintenance  *MaintenanceConfig
+	interceptors []StepInterceptor
+	hooks        []WorkflowHook
+	pluginSpecs  []pluginSpec
+	plugins      []Plugin
+	bus          pluginBus
+	stepDefaults []StepOption
+	logCapture   *LogCapture
+	usage        *usageAccount
+	failover     *failoverState
+
+	errorMu        sync.Mutex
+	errorReporters []ErrorReporter
+
+	runningMu sync.Mutex
+	running   map[string]*Context
+
+	registryMu       sync.RWMutex
+	registry         map[string]WorkflowFunc
+	registryDefaults map[string][]StepOption
+	templates        map[string]*Template
+
+	// Background goroutines (heartbeats, etc.) exit when stop is closed
+	stop     chan struct{}
+	stopOnce sync.Once
+	bg       sync.WaitGroup
+	workerMu sync.Mutex
+	worker   *WorkerConfig
+	leaders  leaderState
+}
+
+// EngineOption configures an Engine at construction time
+type EngineOption func(*Engine)
+
+// WithWorkerID overrides the default worker identity (hostname-pid)
+func WithWorkerID(id string) EngineOption {
+	return func(e *Engine) {
+		e.workerID = id
+	}
+}
+
+// WithShardCount sets the number of workflow shards; it must match across the fleet
+func WithShardCount(n int) EngineOption {
+	return func(e *Engine) {
+		if n > 0 {
+			e.shardCount = n
+		}
+	}
 }
 
-// NewEngine creates a new durable execution engine
-func NewEngine(dbPath string) (*Engine, error) {
-	storage, err := NewStorage(dbPath)
+// NewEngine creates a new durable execution engine. dsn is a database file
+// path, a SQLite URI such as "file:workflows.db?_pragma=synchronous(NORMAL)",
+// or a remote libSQL (Turso) URL such as "libsql://db-org.turso.io?authToken=..."
+// for which a libSQL driver must be imported (see LibSQLDriver).
+func NewEngine(dsn string, opts ...EngineOption) (*Engine, error) {
+	storage, err := NewStorage(dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create storage: %w", err)
 	}
 
-	return &Engine{
-		storage: storage,
-	}, nil
+	e := &Engine{
+		storage:    storage,
+		workerID:   defaultWorkerID(),
+		shardCount: DefaultShardCount,
+		codec:      NewJSONCodec(JSONCodecOptions{}),
+		stop:       make(chan struct{}),
+		registry:   make(map[string]WorkflowFunc),
+	}
+	e.HandleTimers(TimerKindSignal, e.deliverTimerSignal)
+	for _, opt := range opts {
+		opt(e)
+	}
+	e.executor = Executor{WorkerID: e.workerID, Hostname: localHostname(), PID: os.Getpid()}
+	if err := storage.BackfillShards(e.shardCount); err != nil {
+		storage.Close()
+		return nil, err
+	}
+	e.reads = storage
+	if e.replicaDSN != "" {
+		if e.reads, err = storage.openReadReplica(e.replicaDSN); err != nil {
+			storage.Close()
+			return nil, err
+		}
+	}
+	if e.failover != nil {
+		if err := e.initFailover(); err != nil {
+			e.Close()
+			return nil, err
+		}
+	}
+	if e.maintenance != nil {
+		e.startMaintenance(*e.maintenance)
+	}
+	if e.usage != nil {
+		e.startUsageReports()
+	}
+	if err := e.startPlugins(); err != nil {
+		e.Close()
+		return nil, err
+	}
+
+	return e, nil
 }
 
 // Execute runs or resumes a workflow
 // workflowID: unique identifier for this workflow instance
 // workflowFn: the user's workflow function
-func (e *Engine) Execute(workflowID string, workflowFn func(*Context) error) error {
+// opts: per-run options such as WithRetryBudget
+func (e *Engine) Execute(workflowID string, workflowFn func(*Context) error, opts ...WorkflowOption) error {
+	o := newWorkflowOptions(opts)
+
+	// New workflows must pass validation and admission control; resumes are always admitted
+	if _, err := e.storage.GetWorkflowStatus(workflowID); errors.Is(err, ErrWorkflowNotFound) {
+		if o.input != nil {
+			if err := e.validateInput(o.workflowName, o.input); err != nil {
+				return err
+			}
+		}
+		if err := e.admit(); err != nil {
+			return err
+		}
+	}
+
 	// Create workflow record if it doesn't exist
-	if err := e.storage.CreateWorkflow(workflowID); err != nil {
+	if err := e.storage.CreateWorkflow(workflowID, ShardForWorkflow(workflowID, e.shardCount)); err != nil {
 		return fmt.Errorf("failed to create workflow: %w", err)
 	}
+	if err := e.persistStartOptions(e.storage, workflowID, o); err != nil {
+		return err
+	}
+	if o.concurrencyKey != "" {
+		if err := e.awaitConcurrencyKey(workflowID, o.concurrencyKey); err != nil {
+			return err
+		}
+	}
 
-	// Check if workflow is already completed
-	status, err := e.storage.GetWorkflowStatus(workflowID)
+	return e.runWorkflow(workflowID, workflowFn, o)
+}
+
+// runWorkflow executes a workflow whose record already exists
+func (e *Engine) runWorkflow(workflowID string, workflowFn func(*Context) error, o *workflowOptions) error {
+	// Check if workflow is already completed; the version guards the final status update
+	status, version, err := e.storage.GetWorkflowVersion(workflowID)
 	if err != nil {
 		return fmt.Errorf("failed to get workflow status: %w", err)
 	}
 
 	if status == "completed" {
 		fmt.Println("Workflow already completed")
-		return nil
+		// Continuations recorded by an older version may still be pending
+		started, err := e.startContinuations(e.storage, workflowID)
+		logContinuations(workflowID, started)
+		return err
+	}
+	if status == "canceled" {
+		// Finish cleaning up after a crash during a cancellation scope's cleanup
+		if pending, err := e.storage.HasPendingCleanups(workflowID); err != nil {
+			return err
+		} else if pending {
+			return e.resumeCleanups(workflowID, workflowFn, o)
+		}
+		return ErrWorkflowCanceled
+	}
+	// Only the active region executes runs
+	if err := e.checkFence(); err != nil {
+		return err
+	}
+
+	// Take ownership of the run so the orphan scanner can tell if this process dies
+	version, token, err := e.storage.ClaimWorkflowRun(workflowID, e.workerID, version)
+	if err != nil {
+		return err
 	}
 
 	// Create context for the workflow
-	ctx, err := newContext(workflowID, e.storage)
+	ctx, err := newContext(e, workflowID)
 	if err != nil {
 		return fmt.Errorf("failed to create context: %w", err)
 	}
+	ctx.fencingToken = token
+	ctx.queuedAt = o.queuedAt
+	if ctx.retryBudget, err = e.loadRetryBudget(workflowID); err != nil {
+		return err
+	}
+	ctx.stepDefaults = e.resolveStepDefaults(workflowID, o)
+	if err := ctx.initRunLimits(e.runLimits.merge(o.runLimits)); err != nil {
+		return err
+	}
 
-	// Execute the workflow function
-	if err := workflowFn(ctx); err != nil {
-		// Mark workflow as failed
-		e.storage.UpdateWorkflowStatus(workflowID, "failed")
+	untrack := e.trackRunning(ctx)
+	defer untrack()
+	e.emit(EngineEvent{Type: EventWorkflowStarted, WorkflowID: workflowID})
+	defer ctx.cancelRun()
+	defer ctx.releaseLocks()
+	go e.watchCancellation(ctx)
+
+	// Execute the workflow function, then wait for every step it started to be
+	// persisted so completion can't overtake a step still being written
+	err = callWorkflow(func() error { return workflowFn(ctx) })
+	if waitErr := ctx.awaitSteps(); err == nil {
+		err = waitErr
+	}
+	if ctx.Canceled() {
+		// Status is already 'canceled'; keep it that way
+		e.finishWorkflow(workflowID, "canceled", nil)
+		return ErrWorkflowCanceled
+	}
+	if errors.Is(err, ErrFenced) {
+		// Another region was promoted; it owns the run from here
+		e.releaseFencedRun(workflowID, version)
+		return err
+	}
+	if errors.Is(err, ErrStaleFencingToken) {
+		// Another execution claimed the run; its outcome is the one that counts
+		return err
+	}
+	if labels := ctx.handoffLabels(); len(labels) > 0 {
+		// A step needs a worker with other labels; let one pick the run up
+		handedOff, hoErr := e.handOff(workflowID, version, labels)
+		if hoErr != nil {
+			return hoErr
+		}
+		if handedOff {
+			return nil
+		}
+		if err == nil {
+			err = fmt.Errorf("%w: %s", ErrMissingCapabilities, strings.Join(labels, ", "))
+		}
+	}
+	if err != nil {
+		// Mark workflow as failed, unless another process already settled it
+		if casErr := e.storage.CompareAndSetWorkflowStatus(workflowID, "failed", version); casErr != nil {
+			return fmt.Errorf("workflow execution failed: %w (status not recorded: %v)", err, casErr)
+		}
+		e.finishWorkflow(workflowID, "failed", err)
+		e.reportError(workflowID, err)
 		return fmt.Errorf("workflow execution failed: %w", err)
 	}
 
-	// Mark workflow as completed
-	if err := e.storage.UpdateWorkflowStatus(workflowID, "completed"); err != nil {
-		return fmt.Errorf("failed to mark workflow as completed: %w", err)
+	// Mark workflow as completed, together with its output and continuations
+	var started []string
+	err = e.storage.WithTx(func(tx StorageTx) error {
+		if err := tx.CompleteWorkflow(workflowID, version, ctx.output); err != nil {
+			return fmt.Errorf("failed to mark workflow as completed: %w", err)
+		}
+		started, err = e.startContinuations(tx.Storage, workflowID)
+		return err
+	})
+	if err != nil {
+		return err
 	}
+	e.finishWorkflow(workflowID, "completed", nil)
+	logContinuations(workflowID, started)
+
+	return nil
+}
 
+// persistStartOptions durably records the options that must outlive this
+// process, in s so callers can record them with the row they create
+func (e *Engine) persistStartOptions(s *Storage, workflowID string, o *workflowOptions) error {
+	if o.params != nil {
+		data, err := json.Marshal(o.params)
+		if err != nil {
+			return fmt.Errorf("failed to marshal workflow params: %w", err)
+		}
+		if err := s.SetWorkflowParams(workflowID, data); err != nil {
+			return fmt.Errorf("failed to record workflow params: %w", err)
+		}
+	}
+	if o.headers != nil {
+		data, err := json.Marshal(o.headers)
+		if err != nil {
+			return fmt.Errorf("failed to marshal workflow headers: %w", err)
+		}
+		if err := s.SetWorkflowHeaders(workflowID, data); err != nil {
+			return fmt.Errorf("failed to record workflow headers: %w", err)
+		}
+	}
+	if o.input != nil {
+		if err := s.SetWorkflowStart(workflowID, o.workflowName, o.input); err != nil {
+			return fmt.Errorf("failed to record workflow input: %w", err)
+		}
+	}
+	if o.retryBudget != nil {
+		if err := s.SetWorkflowRetryBudget(workflowID, *o.retryBudget); err != nil {
+			return fmt.Errorf("failed to record retry budget: %w", err)
+		}
+	}
+	if o.timeout > 0 {
+		if err := s.SetWorkflowDeadline(workflowID, time.Now().Add(o.timeout)); err != nil {
+			return fmt.Errorf("failed to record workflow deadline: %w", err)
+		}
+	}
+	if o.parentID != "" {
+		if err := s.SetWorkflowParent(workflowID, o.parentID); err != nil {
+			return fmt.Errorf("failed to record parent workflow: %w", err)
+		}
+	}
+	if len(o.onComplete) > 0 {
+		if err := s.AddContinuations(workflowID, o.onComplete); err != nil {
+			return fmt.Errorf("failed to record continuations: %w", err)
+		}
+	}
 	return nil
 }
 
-// Close closes the engine and releases resources
+// Close stops background goroutines, deregisters the worker and releases resources
 func (e *Engine) Close() error {
+	e.stopOnce.Do(func() {
+		close(e.stop)
+	})
+	e.bg.Wait()
+	e.bus.close()
+
+	e.workerMu.Lock()
+	registered := e.worker != nil
+	e.workerMu.Unlock()
+	if registered {
+		e.storage.MarkWorkerStopped(e.workerID)
+	}
+
+	if e.reads != e.storage {
+		e.reads.Close()
+	}
 	return e.storage.Close()
 }
 
+// WorkerID returns the identity this engine uses when registering as a worker
+func (e *Engine) WorkerID() string {
+	return e.workerID
+}
+
 // GetWorkflowStatus returns the current status of a workflow
 func (e *Engine) GetWorkflowStatus(workflowID string) (string, error) {
 	return e.storage.GetWorkflowStatus(workflowID)
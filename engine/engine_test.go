@@ -367,3 +367,65 @@ func TestLoopSequencing(t *testing.T) {
 		}
 	}
 }
+
+func TestSameStepIDsAcrossWorkflows(t *testing.T) {
+	dbPath := "./test_step_scope.db"
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + "-wal")
+	defer os.Remove(dbPath + "-shm")
+
+	// A database created when step keys were globally unique
+	old, err := NewStorage(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	for _, stmt := range []string{
+		"DROP TABLE steps",
+		`CREATE TABLE steps (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			workflow_id TEXT NOT NULL,
+			step_id TEXT NOT NULL,
+			sequence_num INTEGER NOT NULL,
+			step_key TEXT UNIQUE NOT NULL,
+			status TEXT NOT NULL,
+			output BLOB,
+			error TEXT,
+			started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			completed_at TIMESTAMP,
+			FOREIGN KEY (workflow_id) REFERENCES workflows(workflow_id)
+		)`,
+		`INSERT INTO workflows (workflow_id, status) VALUES ('old-1', 'completed')`,
+		`INSERT INTO steps (workflow_id, step_id, sequence_num, step_key, status, output)
+		 VALUES ('old-1', 'fetch', 1, 'fetch:1', 'completed', '"old"')`,
+	} {
+		if _, err := old.db.Exec(stmt); err != nil {
+			t.Fatalf("failed to set up old schema: %v", err)
+		}
+	}
+	old.Close()
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	for _, id := range []string{"new-1", "new-2"} {
+		if err := eng.Execute(id, func(ctx *Context) error {
+			_, err := Step(ctx, "fetch", func() (string, error) { return id, nil })
+			return err
+		}); err != nil {
+			t.Fatalf("workflow %s failed: %v", id, err)
+		}
+	}
+
+	for _, id := range []string{"old-1", "new-1", "new-2"} {
+		output, found, err := eng.storage.GetStep(id, "fetch:1")
+		if err != nil {
+			t.Fatalf("failed to get step of %s: %v", id, err)
+		}
+		if !found || len(output) == 0 {
+			t.Errorf("expected a completed fetch step for %s, got %q", id, output)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrorMatch is a failed step whose error text matched a search
+type ErrorMatch struct {
+	WorkflowID string
+	StepID     string
+	Error      string
+	FailedAt   time.Time
+}
+
+// SearchErrors finds step errors containing query as a phrase, newest first.
+// Only failures recorded at or after since are returned; a zero since searches
+// all history.
+func (e *Engine) SearchErrors(query string, since time.Time) ([]ErrorMatch, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("search query is empty")
+	}
+	return e.reads.SearchStepErrors(query, since)
+}
+
+// initErrorIndex creates the full-text index over steps.error and the
+// triggers that keep it in sync. Existing errors are indexed on first creation.
+func (s *Storage) initErrorIndex() error {
+	var exists int
+	if err := s.db.QueryRow(
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'step_errors'",
+	).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to inspect error index: %w", err)
+	}
+
+	schema := `
+	CREATE VIRTUAL TABLE IF NOT EXISTS step_errors USING fts5(
+		error, content='steps', content_rowid='id'
+	);
+
+	CREATE TRIGGER IF NOT EXISTS steps_error_update AFTER UPDATE OF error ON steps BEGIN
+		INSERT INTO step_errors(step_errors, rowid, error)
+			SELECT 'delete', old.id, old.error WHERE old.error IS NOT NULL;
+		INSERT INTO step_errors(rowid, error)
+			SELECT new.id, new.error WHERE new.error IS NOT NULL;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS steps_error_delete AFTER DELETE ON steps BEGIN
+		INSERT INTO step_errors(step_errors, rowid, error)
+			SELECT 'delete', old.id, old.error WHERE old.error IS NOT NULL;
+	END;
+	`
+	if _, err := s.db.Exec(schema); err != nil {
+		return fmt.Errorf("failed to create error index: %w", err)
+	}
+
+	if exists == 0 {
+		if _, err := s.db.Exec("INSERT INTO step_errors(step_errors) VALUES ('rebuild')"); err != nil {
+			return fmt.Errorf("failed to build error index: %w", err)
+		}
+	}
+	return nil
+}
+
+// SearchStepErrors runs a phrase query against the error index
+func (s *Storage) SearchStepErrors(query string, since time.Time) ([]ErrorMatch, error) {
+	// Quote the query so punctuation is matched literally rather than parsed as FTS syntax
+	phrase := `"` + strings.ReplaceAll(query, `"`, `""`) + `"`
+
+	rows, err := s.db.Query(
+		`SELECT s.workflow_id, s.step_id, s.error, s.completed_at
+		 FROM step_errors f JOIN steps s ON s.id = f.rowid
+		 WHERE step_errors MATCH ? AND s.completed_at >= ?
+		 ORDER BY s.completed_at DESC, s.id DESC`,
+		phrase, dbTime(since),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search step errors: %w", err)
+	}
+	defer rows.Close()
+
+	var matches []ErrorMatch
+	for rows.Next() {
+		var m ErrorMatch
+		var failedAt sql.NullTime
+		if err := rows.Scan(&m.WorkflowID, &m.StepID, &m.Error, &failedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan error match: %w", err)
+		}
+		m.FailedAt = failedAt.Time
+		matches = append(matches, m)
+	}
+
+	return matches, rows.Err()
+}
@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSearchErrors(t *testing.T) {
+	dbPath := "./test_errsearch.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	failWith := func(workflowID, msg string) {
+		eng.Execute(workflowID, func(ctx *Context) error {
+			_, err := Step(ctx, "call-api-"+workflowID, func() (string, error) {
+				return "", errors.New(msg)
+			})
+			return err
+		})
+	}
+	failWith("sync-1", "read tcp 10.0.0.1:443: connection reset by peer")
+	failWith("sync-2", "context deadline exceeded")
+	failWith("sync-3", "write: Connection reset by peer")
+
+	matches, err := eng.SearchErrors("connection reset by peer", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("failed to search errors: %v", err)
+	}
+	if len(matches) != 2 {
+		t.Fatalf("expected 2 matches, got %+v", matches)
+	}
+	for _, m := range matches {
+		if m.WorkflowID != "sync-1" && m.WorkflowID != "sync-3" {
+			t.Errorf("unexpected match %+v", m)
+		}
+		if m.StepID != "call-api-"+m.WorkflowID || m.FailedAt.IsZero() {
+			t.Errorf("incomplete match %+v", m)
+		}
+	}
+
+	// Punctuation is matched literally
+	matches, err = eng.SearchErrors("10.0.0.1:443", time.Time{})
+	if err != nil {
+		t.Fatalf("failed to search errors: %v", err)
+	}
+	if len(matches) != 1 || matches[0].WorkflowID != "sync-1" {
+		t.Errorf("expected sync-1 only, got %+v", matches)
+	}
+
+	// Failures before since are excluded
+	matches, err = eng.SearchErrors("deadline", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to search errors: %v", err)
+	}
+	if len(matches) != 0 {
+		t.Errorf("expected no matches after since, got %+v", matches)
+	}
+}
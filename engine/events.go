@@ -0,0 +1,272 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// EventsFromNow starts WatchEvents after the latest recorded event
+const EventsFromNow int64 = -1
+
+// eventPollInterval is how often watchers look for new events
+const eventPollInterval = 100 * time.Millisecond
+
+// Statuses of events that aren't a workflow or step status
+const (
+	EventLog           = "log"            // a line recorded with Context.Logf
+	EventAttemptFailed = "attempt_failed" // one attempt of a step failed; Message names the attempt
+)
+
+// WorkflowEvent is one recorded status change of a workflow or one of its
+// steps, or a line logged by the workflow
+type WorkflowEvent struct {
+	ID         int64 // increases with every event; resume watching after it
+	WorkflowID string
+	StepID     string // empty for workflow status changes and logs
+	StepKey    string
+	Status     string // a workflow or step status, EventLog or EventAttemptFailed
+	Error      string
+	Message    string // the logged line, or which attempt failed
+	Time       time.Time
+}
+
+// IsTerminal reports whether the event finishes its workflow
+func (ev *WorkflowEvent) IsTerminal() bool {
+	if ev.StepID != "" {
+		return false
+	}
+	return ev.Status == "completed" || ev.Status == "failed" || ev.Status == "canceled"
+}
+
+// initEvents records workflow and step status changes and failed attempts,
+// whichever process makes them, so watchers can follow them by event ID.
+// Events take the time the change was written with (updated_at,
+// started_at or completed_at, all from dbNow), not SQLite's clock; the
+// triggers are recreated so databases with older ones are upgraded.
+func (s *Storage) initEvents() error {
+	_, err := s.db.Exec(`
+	CREATE TABLE IF NOT EXISTS workflow_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		workflow_id TEXT NOT NULL,
+		step_id TEXT,
+		step_key TEXT,
+		status TEXT NOT NULL,
+		error TEXT,
+		message TEXT,
+		created_at TIMESTAMP NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_workflow_events ON workflow_events(workflow_id, id);
+
+	DROP TRIGGER IF EXISTS workflows_event_insert;
+	DROP TRIGGER IF EXISTS workflows_event_update;
+	DROP TRIGGER IF EXISTS steps_event_insert;
+	DROP TRIGGER IF EXISTS steps_event_update;
+	DROP TRIGGER IF EXISTS step_attempts_event_fail;
+
+	CREATE TRIGGER workflows_event_insert AFTER INSERT ON workflows BEGIN
+		INSERT INTO workflow_events (workflow_id, status, created_at)
+		VALUES (new.workflow_id, new.status, COALESCE(new.updated_at, new.created_at));
+	END;
+
+	CREATE TRIGGER workflows_event_update AFTER UPDATE OF status ON workflows
+	WHEN new.status IS NOT old.status BEGIN
+		INSERT INTO workflow_events (workflow_id, status, created_at)
+		VALUES (new.workflow_id, new.status, COALESCE(new.updated_at, new.created_at));
+	END;
+
+	CREATE TRIGGER steps_event_insert AFTER INSERT ON steps BEGIN
+		INSERT INTO workflow_events (workflow_id, step_id, step_key, status, error, created_at)
+		VALUES (new.workflow_id, new.step_id, new.step_key, new.status, new.error,
+		        CASE new.status WHEN 'in_progress' THEN new.started_at ELSE COALESCE(new.completed_at, new.started_at) END);
+	END;
+
+	CREATE TRIGGER steps_event_update AFTER UPDATE OF status ON steps
+	WHEN new.status IS NOT old.status BEGIN
+		INSERT INTO workflow_events (workflow_id, step_id, step_key, status, error, created_at)
+		VALUES (new.workflow_id, new.step_id, new.step_key, new.status, new.error,
+		        CASE new.status WHEN 'in_progress' THEN new.started_at ELSE COALESCE(new.completed_at, new.started_at) END);
+	END;
+
+	CREATE TRIGGER step_attempts_event_fail AFTER UPDATE OF status ON step_attempts
+	WHEN new.status = 'failed' BEGIN
+		INSERT INTO workflow_events (workflow_id, step_id, step_key, status, error, message, created_at)
+		VALUES (new.workflow_id,
+		        -- the step ID is the step key without its ":<sequence>" suffix
+		        SUBSTR(RTRIM(new.step_key, '0123456789'), 1, LENGTH(RTRIM(new.step_key, '0123456789')) - 1),
+		        new.step_key, 'attempt_failed', new.error, 'attempt ' || new.attempt,
+		        COALESCE(new.completed_at, new.started_at));
+	END;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create event log: %w", err)
+	}
+	if err := s.addColumnIfMissing("workflow_events", "message", "TEXT"); err != nil {
+		return err
+	}
+
+	// A replayed Logf call finds its line already recorded under the same key
+	if _, err := s.db.Exec(
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_workflow_event_logs ON workflow_events(workflow_id, step_key)
+		 WHERE status = 'log'`,
+	); err != nil {
+		return fmt.Errorf("failed to create log index: %w", err)
+	}
+	return nil
+}
+
+// Logf records a line in the workflow's event feed, shown by workflowctl
+// tail and delivered by WatchEvents. Lines are recorded once: a resumed run
+// that logs the same lines in the same order doesn't repeat them. With
+// WithLogCapture the line is also captured with the run's logs.
+func (ctx *Context) Logf(format string, args ...interface{}) {
+	n := atomic.AddInt64(&ctx.logSeq, 1)
+	msg := fmt.Sprintf(format, args...)
+	ctx.captureLog(msg)
+	if err := ctx.storage.InsertLogEvent(ctx.WorkflowID, fmt.Sprintf("log:%d", n), msg); err != nil {
+		fmt.Printf("[EVENTS] failed to record log line of %s: %v\n", ctx.WorkflowID, err)
+	}
+}
+
+// ListEvents returns up to limit events after afterID, oldest first. An
+// empty workflowID lists events of every workflow.
+func (e *Engine) ListEvents(workflowID string, afterID int64, limit int) ([]WorkflowEvent, error) {
+	return e.reads.ListEvents(workflowID, afterID, limit)
+}
+
+// PruneEvents deletes events recorded before the given time
+func (e *Engine) PruneEvents(before time.Time) (int64, error) {
+	return e.storage.PruneEvents(before)
+}
+
+// WatchEvents delivers events after afterID (or EventsFromNow) as they are
+// recorded, until ctx is done or the engine closes. An empty workflowID
+// watches every workflow; otherwise the channel also closes after the
+// workflow completes, fails or is canceled.
+func (e *Engine) WatchEvents(ctx context.Context, workflowID string, afterID int64) (<-chan WorkflowEvent, error) {
+	if afterID == EventsFromNow {
+		latest, err := e.reads.LatestEventID()
+		if err != nil {
+			return nil, err
+		}
+		afterID = latest
+	}
+
+	ch := make(chan WorkflowEvent)
+	e.bg.Add(1)
+	go func() {
+		defer e.bg.Done()
+		defer close(ch)
+
+		ticker := time.NewTicker(eventPollInterval)
+		defer ticker.Stop()
+		for {
+			events, err := e.reads.ListEvents(workflowID, afterID, 100)
+			if err != nil {
+				fmt.Printf("[EVENTS] %v\n", err)
+			}
+			for _, ev := range events {
+				select {
+				case ch <- ev:
+				case <-ctx.Done():
+					return
+				case <-e.stop:
+					return
+				}
+				afterID = ev.ID
+				if workflowID != "" && ev.IsTerminal() {
+					return
+				}
+			}
+			if len(events) == 100 {
+				continue
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			case <-e.stop:
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// ListEvents loads up to limit events after afterID, optionally for one workflow
+func (s *Storage) ListEvents(workflowID string, afterID int64, limit int) ([]WorkflowEvent, error) {
+	query := `SELECT id, workflow_id, COALESCE(step_id, ''), COALESCE(step_key, ''), status,
+	            COALESCE(error, ''), COALESCE(message, ''), created_at
+	          FROM workflow_events WHERE id > ?`
+	args := []interface{}{afterID}
+	if workflowID != "" {
+		query += " AND workflow_id = ?"
+		args = append(args, workflowID)
+	}
+	query += " ORDER BY id"
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []WorkflowEvent
+	for rows.Next() {
+		var ev WorkflowEvent
+		if err := rows.Scan(&ev.ID, &ev.WorkflowID, &ev.StepID, &ev.StepKey, &ev.Status, &ev.Error, &ev.Message, &ev.Time); err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		events = append(events, ev)
+	}
+	return events, rows.Err()
+}
+
+// InsertLogEvent records a logged line under a key unique within the workflow
+func (s *Storage) InsertLogEvent(workflowID, key, message string) error {
+	return s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			`INSERT OR IGNORE INTO workflow_events (workflow_id, step_key, status, message, created_at)
+			 VALUES (?, ?, 'log', ?, ?)`,
+			workflowID, key, message, dbNow(),
+		)
+		return err
+	})
+}
+
+// LatestEventID returns the ID of the newest event, or 0 if there are none
+func (s *Storage) LatestEventID() (int64, error) {
+	var id sql.NullInt64
+	if err := s.db.QueryRow("SELECT MAX(id) FROM workflow_events").Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to read latest event: %w", err)
+	}
+	return id.Int64, nil
+}
+
+// PruneEvents deletes events created before the given time
+func (s *Storage) PruneEvents(before time.Time) (int64, error) {
+	var deleted int64
+	err := s.retryOnBusy(func() error {
+		res, err := s.db.Exec(
+			"DELETE FROM workflow_events WHERE created_at < ?",
+			dbTime(before),
+		)
+		if err != nil {
+			return err
+		}
+		deleted, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune events: %w", err)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,159 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestEventsRecordStatusChanges(t *testing.T) {
+	dbPath := "./test_events.db"
+	defer os.Remove(dbPath)
+
+	engine, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	engine.Execute("events-1", func(ctx *Context) error {
+		Step(ctx, "ok", func() (int, error) { return 1, nil })
+		_, err := Step(ctx, "broken", func() (int, error) { return 0, errors.New("boom") })
+		return err
+	})
+
+	events, err := engine.ListEvents("events-1", 0, 0)
+	if err != nil {
+		t.Fatalf("failed to list events: %v", err)
+	}
+	var got []string
+	for _, ev := range events {
+		got = append(got, ev.StepID+":"+ev.Status)
+	}
+	want := []string{":running", "ok:in_progress", "ok:completed", "broken:in_progress", "broken:attempt_failed", "broken:failed", ":failed"}
+	if len(got) != len(want) {
+		t.Fatalf("expected events %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected events %v, got %v", want, got)
+		}
+	}
+	if events[4].Error != "boom" || events[4].Message != "attempt 1" {
+		t.Errorf("expected failed attempt event with error, got %+v", events[4])
+	}
+	if events[5].Error != "boom" || events[5].Time.IsZero() {
+		t.Errorf("expected failed step event with error and time, got %+v", events[5])
+	}
+	// Events carry the times the engine wrote, not SQLite's clock
+	var completedAt, updatedAt time.Time
+	engine.storage.db.QueryRow("SELECT completed_at FROM steps WHERE workflow_id = 'events-1' AND step_id = 'ok'").Scan(&completedAt)
+	engine.storage.db.QueryRow("SELECT updated_at FROM workflows WHERE workflow_id = 'events-1'").Scan(&updatedAt)
+	if completedAt.IsZero() || !events[2].Time.Equal(completedAt) || !events[6].Time.Equal(updatedAt) {
+		t.Errorf("expected event times %v and %v, got %v and %v", completedAt, updatedAt, events[2].Time, events[6].Time)
+	}
+	if !events[6].IsTerminal() || events[2].IsTerminal() {
+		t.Error("only the workflow's final status should be terminal")
+	}
+
+	pruned, err := engine.PruneEvents(time.Now().Add(time.Minute))
+	if err != nil || pruned != int64(len(events)) {
+		t.Errorf("expected %d events pruned, got %d: %v", len(events), pruned, err)
+	}
+}
+
+func TestWatchEvents(t *testing.T) {
+	dbPath := "./test_events_watch.db"
+	defer os.Remove(dbPath)
+
+	engine, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	engine.Execute("before", func(ctx *Context) error { return nil })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	all, err := engine.WatchEvents(ctx, "", EventsFromNow)
+	if err != nil {
+		t.Fatalf("failed to watch: %v", err)
+	}
+	one, err := engine.WatchEvents(ctx, "watched", 0)
+	if err != nil {
+		t.Fatalf("failed to watch: %v", err)
+	}
+
+	engine.Execute("watched", func(ctx *Context) error {
+		_, err := Step(ctx, "work", func() (int, error) { return 1, nil })
+		return err
+	})
+
+	var statuses []string
+	for ev := range one {
+		statuses = append(statuses, ev.StepID+":"+ev.Status)
+	}
+	if len(statuses) != 4 || statuses[3] != ":completed" {
+		t.Errorf("expected the run's events ending with completion, got %v", statuses)
+	}
+
+	select {
+	case ev := <-all:
+		if ev.WorkflowID != "watched" {
+			t.Errorf("expected only events after the watch started, got %+v", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event delivered")
+	}
+
+	cancel()
+	for range all {
+	}
+}
+
+func TestLogfRecordedOncePerLine(t *testing.T) {
+	dbPath := "./test_events_log.db"
+	defer os.Remove(dbPath)
+
+	engine, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	attempts := 0
+	workflow := func(ctx *Context) error {
+		ctx.Logf("charging %d cents", 500)
+		_, err := Step(ctx, "charge", func() (int, error) {
+			attempts++
+			if attempts == 1 {
+				return 0, errors.New("card declined")
+			}
+			return 500, nil
+		})
+		if err != nil {
+			return err
+		}
+		ctx.Logf("charged")
+		return nil
+	}
+	engine.Execute("log-1", workflow)
+	engine.Execute("log-1", workflow)
+
+	events, err := engine.ListEvents("log-1", 0, 0)
+	if err != nil {
+		t.Fatalf("failed to list events: %v", err)
+	}
+	var lines []string
+	for _, ev := range events {
+		if ev.Status == EventLog {
+			lines = append(lines, ev.Message)
+		}
+	}
+	if len(lines) != 2 || lines[0] != "charging 500 cents" || lines[1] != "charged" {
+		t.Errorf("expected each line logged once, got %q", lines)
+	}
+}
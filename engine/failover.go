@@ -0,0 +1,334 @@
+package engine
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultRegionHeartbeat is how often the active region records that it is alive
+const DefaultRegionHeartbeat = 5 * time.Second
+
+var (
+	// ErrStandby is returned when a standby region is asked to run a workflow
+	ErrStandby = errors.New("engine is a standby region")
+	// ErrFenced is returned when another region was promoted while this one was active
+	ErrFenced = errors.New("region was fenced by a promotion")
+	// ErrReplicationLag is returned by Promote when the standby is too far behind
+	ErrReplicationLag = errors.New("replication lag exceeds the promotion limit")
+)
+
+// FailoverConfig configures an engine for active-passive operation across
+// regions sharing one replicated database
+type FailoverConfig struct {
+	Region  string // this engine's region, e.g. "eu-west-1"
+	Standby bool   // start passive even if no region is active yet
+
+	// MaxLag is the replication lag above which Promote refuses unless
+	// forced; zero disables the check
+	MaxLag time.Duration
+	// ReplicationLag reports how far this region's copy of the database is
+	// behind the active region, e.g. from LiteFS or libSQL replication
+	// positions. Without it the lag is estimated from the age of the active
+	// region's last heartbeat.
+	ReplicationLag func() (time.Duration, error)
+	// HeartbeatInterval is how often the active region records that it is
+	// alive; default DefaultRegionHeartbeat
+	HeartbeatInterval time.Duration
+}
+
+// ActiveRegion is the region currently allowed to execute steps.
+// Epoch increases on every promotion and acts as a fencing token.
+type ActiveRegion struct {
+	Region    string
+	Epoch     int64
+	Heartbeat time.Time
+}
+
+// failoverState tracks whether this engine's region is active and under which epoch
+type failoverState struct {
+	cfg FailoverConfig
+
+	mu     sync.Mutex
+	active bool
+	epoch  int64
+}
+
+// WithFailover makes the engine one region of an active-passive pair. Only
+// the active region claims and executes workflows; a standby waits until
+// Promote is called, after which the old active region is fenced and stops
+// before its next step instead of executing it a second time.
+func WithFailover(cfg FailoverConfig) EngineOption {
+	return func(e *Engine) {
+		if cfg.HeartbeatInterval <= 0 {
+			cfg.HeartbeatInterval = DefaultRegionHeartbeat
+		}
+		e.failover = &failoverState{cfg: cfg}
+	}
+}
+
+// initFailover takes the active role if no region holds it yet, or resumes
+// it after a restart, and starts the region heartbeat
+func (e *Engine) initFailover() error {
+	f := e.failover
+	if f.cfg.Region == "" {
+		return fmt.Errorf("failed to configure failover: region is required")
+	}
+
+	if !f.cfg.Standby {
+		if err := e.storage.InitActiveRegion(f.cfg.Region); err != nil {
+			return err
+		}
+	}
+	current, err := e.storage.GetActiveRegion()
+	if err != nil {
+		return err
+	}
+	if current != nil && current.Region == f.cfg.Region && !f.cfg.Standby {
+		f.set(true, current.Epoch)
+		fmt.Printf("[FAILOVER] %s is active (epoch %d)\n", f.cfg.Region, current.Epoch)
+	} else {
+		fmt.Printf("[FAILOVER] %s is standby\n", f.cfg.Region)
+	}
+
+	e.bg.Add(1)
+	go e.regionHeartbeat()
+	return nil
+}
+
+func (f *failoverState) set(active bool, epoch int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.active, f.epoch = active, epoch
+}
+
+func (f *failoverState) state() (bool, int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.active, f.epoch
+}
+
+// regionHeartbeat refreshes the active region's heartbeat and steps down as
+// soon as another region has been promoted
+func (e *Engine) regionHeartbeat() {
+	defer e.bg.Done()
+
+	ticker := time.NewTicker(e.failover.cfg.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+		}
+
+		active, epoch := e.failover.state()
+		if !active {
+			continue
+		}
+		ok, err := e.storage.HeartbeatRegion(e.failover.cfg.Region, epoch)
+		if err != nil {
+			fmt.Printf("[FAILOVER] heartbeat: %v\n", err)
+			continue
+		}
+		if !ok {
+			e.failover.set(false, epoch)
+			fmt.Printf("[FAILOVER] %s was fenced; now standby\n", e.failover.cfg.Region)
+		}
+	}
+}
+
+// IsActiveRegion reports whether this engine may execute workflows. Engines
+// without WithFailover are always active.
+func (e *Engine) IsActiveRegion() bool {
+	if e.failover == nil {
+		return true
+	}
+	active, _ := e.failover.state()
+	return active
+}
+
+// FencingToken returns the epoch this engine's region was made active under,
+// or 0 if it is a standby or failover is not configured
+func (e *Engine) FencingToken() int64 {
+	if e.failover == nil {
+		return 0
+	}
+	active, epoch := e.failover.state()
+	if !active {
+		return 0
+	}
+	return epoch
+}
+
+// GetActiveRegion returns the region currently allowed to execute steps, if any
+func (e *Engine) GetActiveRegion() (*ActiveRegion, error) {
+	return e.storage.GetActiveRegion()
+}
+
+// ReplicationLag reports how far this region's database is behind the
+// active region, using FailoverConfig.ReplicationLag when set. The default
+// estimate is the age of the active region's last replicated heartbeat,
+// which also grows when the active region is down.
+func (e *Engine) ReplicationLag() (time.Duration, error) {
+	if e.failover == nil {
+		return 0, nil
+	}
+	if e.failover.cfg.ReplicationLag != nil {
+		return e.failover.cfg.ReplicationLag()
+	}
+	if e.IsActiveRegion() {
+		return 0, nil
+	}
+	current, err := e.storage.GetActiveRegion()
+	if err != nil || current == nil {
+		return 0, err
+	}
+	return time.Since(current.Heartbeat), nil
+}
+
+// Promote makes this engine's region the active one and returns its new
+// fencing token. The previous active region is fenced: its in-flight runs
+// stop before their next step and are requeued for this region. Promote
+// refuses when replication lag exceeds MaxLag, since steps that completed
+// in the old region but haven't replicated yet would run again; force
+// overrides the check, e.g. when the old region is unreachable.
+func (e *Engine) Promote(force bool) (int64, error) {
+	if e.failover == nil {
+		return 0, fmt.Errorf("failed to promote: failover is not configured")
+	}
+	f := e.failover
+
+	if !force && f.cfg.MaxLag > 0 {
+		lag, err := e.ReplicationLag()
+		if err != nil {
+			return 0, fmt.Errorf("failed to measure replication lag: %w", err)
+		}
+		if lag > f.cfg.MaxLag {
+			return 0, fmt.Errorf("%w: %v > %v", ErrReplicationLag, lag, f.cfg.MaxLag)
+		}
+	}
+
+	epoch, err := e.storage.PromoteRegion(f.cfg.Region)
+	if err != nil {
+		return 0, err
+	}
+	f.set(true, epoch)
+	fmt.Printf("[FAILOVER] %s promoted (epoch %d)\n", f.cfg.Region, epoch)
+	return epoch, nil
+}
+
+// checkFence fails if this engine's region is not, or is no longer, the
+// active one. It reads the active region from the database so a promotion
+// is seen before the next step runs, not at the next heartbeat.
+func (e *Engine) checkFence() error {
+	if e.failover == nil {
+		return nil
+	}
+	active, epoch := e.failover.state()
+	if !active {
+		return ErrStandby
+	}
+	current, err := e.storage.GetActiveRegion()
+	if err != nil {
+		return err
+	}
+	if current == nil || current.Region != e.failover.cfg.Region || current.Epoch != epoch {
+		e.failover.set(false, epoch)
+		return ErrFenced
+	}
+	return nil
+}
+
+// releaseFencedRun requeues a run interrupted by a promotion so the new
+// active region picks it up; runs without a registered name are left for
+// its orphan scanner
+func (e *Engine) releaseFencedRun(workflowID string, version int64) {
+	name, err := e.storage.GetWorkflowName(workflowID)
+	if err != nil || name == "" {
+		return
+	}
+	if err := e.storage.RequeueWorkflow(workflowID, version); err != nil {
+		fmt.Printf("[FAILOVER] failed to requeue %s: %v\n", workflowID, err)
+		return
+	}
+	fmt.Printf("[FAILOVER] %s requeued for the active region\n", workflowID)
+}
+
+// InitActiveRegion makes region the active one if no region has been yet
+func (s *Storage) InitActiveRegion(region string) error {
+	return s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			`INSERT INTO active_region (id, region, epoch, heartbeat_ms) VALUES (1, ?, 1, ?)
+			 ON CONFLICT(id) DO NOTHING`,
+			region, time.Now().UnixMilli(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to initialize active region: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetActiveRegion loads the active region, returning nil if none was set
+func (s *Storage) GetActiveRegion() (*ActiveRegion, error) {
+	var r ActiveRegion
+	var heartbeatMs int64
+	err := s.db.QueryRow(
+		"SELECT region, epoch, heartbeat_ms FROM active_region WHERE id = 1",
+	).Scan(&r.Region, &r.Epoch, &heartbeatMs)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active region: %w", err)
+	}
+
+	r.Heartbeat = time.UnixMilli(heartbeatMs)
+	return &r, nil
+}
+
+// HeartbeatRegion records that region is alive, reporting false if it is no
+// longer active under epoch
+func (s *Storage) HeartbeatRegion(region string, epoch int64) (bool, error) {
+	var affected int64
+	err := s.retryOnBusy(func() error {
+		res, err := s.db.Exec(
+			"UPDATE active_region SET heartbeat_ms = ? WHERE id = 1 AND region = ? AND epoch = ?",
+			time.Now().UnixMilli(), region, epoch,
+		)
+		if err != nil {
+			return err
+		}
+		affected, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to record region heartbeat: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// PromoteRegion makes region the active one under a new epoch and returns it
+func (s *Storage) PromoteRegion(region string) (int64, error) {
+	var epoch int64
+	err := s.retryOnBusy(func() error {
+		return s.db.QueryRow(
+			`INSERT INTO active_region (id, region, epoch, heartbeat_ms) VALUES (1, ?, 1, ?)
+			 ON CONFLICT(id) DO UPDATE SET
+			   region = excluded.region,
+			   epoch = active_region.epoch + 1,
+			   heartbeat_ms = excluded.heartbeat_ms
+			 RETURNING epoch`,
+			region, time.Now().UnixMilli(),
+		).Scan(&epoch)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to promote region: %w", err)
+	}
+	return epoch, nil
+}
@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFailoverPromoteFencesOldRegion(t *testing.T) {
+	dbPath := "./test_failover.db"
+	defer os.Remove(dbPath)
+
+	east, err := NewEngine(dbPath, WithWorkerID("east-1"), WithFailover(FailoverConfig{Region: "us-east", MaxLag: time.Minute}))
+	if err != nil {
+		t.Fatalf("failed to create active engine: %v", err)
+	}
+	defer east.Close()
+	west, err := NewEngine(dbPath, WithWorkerID("west-1"), WithFailover(FailoverConfig{Region: "us-west", Standby: true, MaxLag: time.Minute}))
+	if err != nil {
+		t.Fatalf("failed to create standby engine: %v", err)
+	}
+	defer west.Close()
+
+	if !east.IsActiveRegion() || east.FencingToken() != 1 {
+		t.Fatalf("expected us-east active under epoch 1, got %v/%d", east.IsActiveRegion(), east.FencingToken())
+	}
+	if err := west.Execute("standby-run", func(ctx *Context) error { return nil }); !errors.Is(err, ErrStandby) {
+		t.Fatalf("expected the standby to refuse runs, got %v", err)
+	}
+
+	charges := 0
+	workflow := func(promote bool) func(*Context) error {
+		return func(ctx *Context) error {
+			if _, err := Step(ctx, "reserve", func() (int, error) { return 1, nil }); err != nil {
+				return err
+			}
+			if promote {
+				// The active region fails over while this run is in flight
+				if _, err := west.Promote(false); err != nil {
+					return err
+				}
+			}
+			_, err := Step(ctx, "charge", func() (int, error) {
+				charges++
+				return 1, nil
+			})
+			return err
+		}
+	}
+
+	if err := east.Execute("failover-1", workflow(true)); !errors.Is(err, ErrFenced) {
+		t.Fatalf("expected the old region to be fenced, got %v", err)
+	}
+	if east.IsActiveRegion() {
+		t.Error("expected us-east to step down once fenced")
+	}
+	if status, _ := east.storage.GetWorkflowStatus("failover-1"); status == "failed" {
+		t.Error("expected a fenced run not to be marked failed")
+	}
+
+	region, err := west.GetActiveRegion()
+	if err != nil || region.Region != "us-west" || region.Epoch != 2 {
+		t.Fatalf("expected us-west active under epoch 2, got %+v: %v", region, err)
+	}
+
+	if err := west.Execute("failover-1", workflow(false)); err != nil {
+		t.Fatalf("expected the promoted region to finish the run: %v", err)
+	}
+	if charges != 1 {
+		t.Errorf("expected the charge step to run once, ran %d times", charges)
+	}
+}
+
+func TestFailoverPromoteRespectsLag(t *testing.T) {
+	dbPath := "./test_failover_lag.db"
+	defer os.Remove(dbPath)
+
+	active, err := NewEngine(dbPath, WithFailover(FailoverConfig{Region: "eu"}))
+	if err != nil {
+		t.Fatalf("failed to create active engine: %v", err)
+	}
+	defer active.Close()
+	standby, err := NewEngine(dbPath, WithFailover(FailoverConfig{
+		Region:         "ap",
+		Standby:        true,
+		MaxLag:         time.Second,
+		ReplicationLag: func() (time.Duration, error) { return time.Minute, nil },
+	}))
+	if err != nil {
+		t.Fatalf("failed to create standby engine: %v", err)
+	}
+	defer standby.Close()
+
+	if _, err := standby.Promote(false); !errors.Is(err, ErrReplicationLag) {
+		t.Fatalf("expected promotion to be refused while lagging, got %v", err)
+	}
+	if !active.IsActiveRegion() {
+		t.Fatal("expected a refused promotion to leave the active region alone")
+	}
+
+	epoch, err := standby.Promote(true)
+	if err != nil || epoch != 2 {
+		t.Fatalf("expected a forced promotion to epoch 2, got %d: %v", epoch, err)
+	}
+	if err := active.checkFence(); !errors.Is(err, ErrFenced) {
+		t.Errorf("expected the old region to be fenced, got %v", err)
+	}
+}
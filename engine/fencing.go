@@ -0,0 +1,34 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrStaleFencingToken is returned when an execution saves a step after
+// another execution claimed the run, e.g. a worker that stalled past its
+// lease and was replaced by the orphan scanner
+var ErrStaleFencingToken = errors.New("run was claimed by another execution")
+
+// FencingToken returns the token this execution claimed the run under
+func (ctx *Context) FencingToken() int64 {
+	return ctx.fencingToken
+}
+
+// checkFencingToken fails if the run has been claimed since token was issued.
+// A zero token is never checked.
+func checkFencingToken(tx *instrumentedTx, workflowID string, token int64) error {
+	if token == 0 {
+		return nil
+	}
+	var current int64
+	if err := tx.QueryRow(
+		"SELECT fencing_token FROM workflows WHERE workflow_id = ?", workflowID,
+	).Scan(&current); err != nil {
+		return fmt.Errorf("failed to check fencing token: %w", err)
+	}
+	if current != token {
+		return fmt.Errorf("%w: %s token %d superseded by %d", ErrStaleFencingToken, workflowID, token, current)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestStaleFencingTokenCannotSaveStep(t *testing.T) {
+	dbPath := "./test_fencing.db"
+	defer os.Remove(dbPath)
+
+	engine, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	var tokens []int64
+	zombie := func(ctx *Context) error {
+		_, err := StepWithInfo(ctx, "charge", func(info StepInfo) (string, error) {
+			tokens = append(tokens, info.FencingToken)
+			// The run is claimed by a new owner while this execution stalls
+			_, version, err := engine.storage.GetWorkflowVersion(info.WorkflowID)
+			if err != nil {
+				return "", err
+			}
+			if _, _, err := engine.storage.ClaimWorkflowRun(info.WorkflowID, "new-owner", version); err != nil {
+				return "", err
+			}
+			return "zombie", nil
+		})
+		return err
+	}
+
+	if err := engine.Execute("fence-1", zombie); !errors.Is(err, ErrStaleFencingToken) {
+		t.Fatalf("expected the zombie's save to be rejected, got %v", err)
+	}
+	if status, _ := engine.storage.GetWorkflowStatus("fence-1"); status != "running" {
+		t.Errorf("expected the new owner's run to be left running, got %s", status)
+	}
+	if steps, _ := engine.storage.LoadCompletedSteps("fence-1"); len(steps) != 0 {
+		t.Errorf("expected no step saved under the stale token, got %d", len(steps))
+	}
+
+	if err := engine.Execute("fence-1", func(ctx *Context) error {
+		_, err := StepWithInfo(ctx, "charge", func(info StepInfo) (string, error) {
+			tokens = append(tokens, info.FencingToken)
+			return "owner", nil
+		})
+		return err
+	}); err != nil {
+		t.Fatalf("expected the current owner to save the step: %v", err)
+	}
+
+	if len(tokens) != 2 || tokens[0] != 1 || tokens[1] != 3 {
+		t.Errorf("expected tokens [1 3], got %v", tokens)
+	}
+}
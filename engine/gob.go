@@ -0,0 +1,45 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+)
+
+// gobCodec encodes values with encoding/gob
+type gobCodec struct{}
+
+// NewGobCodec creates a codec that round-trips Go values exactly: int64 and
+// uint64 keep full precision inside interface values, []byte stays binary and
+// time.Time keeps nanoseconds and its zone offset. Concrete types stored in interface values
+// must be registered with gob.Register.
+//
+// Gob data is not JSON, so step schemas, readable history diffs, error
+// search on outputs and the dsl package are unavailable; use it only when
+// results are consumed from Go.
+func NewGobCodec() Codec {
+	return gobCodec{}
+}
+
+// Marshal encodes v; nil and nil pointers encode to empty data
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes data into v; empty data leaves v unchanged
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
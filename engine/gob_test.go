@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+type gobOrder struct {
+	ID       string
+	Total    int64
+	Payload  []byte
+	PlacedAt time.Time
+	Extra    map[string]interface{}
+}
+
+func init() {
+	gob.Register(int64(0))
+}
+
+func TestGobCodecRoundTripsThroughReplay(t *testing.T) {
+	dbPath := "./test_gob.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath, WithCodec(NewGobCodec()))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	loc := time.FixedZone("IST", 5*3600+1800)
+	want := gobOrder{
+		ID:       "o-1",
+		Total:    1<<62 + 1,
+		Payload:  []byte{0, 1, 2, 255},
+		PlacedAt: time.Date(2024, 5, 1, 12, 0, 0, 123456789, loc),
+		Extra:    map[string]interface{}{"big": int64(9007199254740993)},
+	}
+
+	var calls, runs int
+	workflow := func(got *gobOrder) WorkflowFunc {
+		return func(ctx *Context) error {
+			runs++
+			order, err := Step(ctx, "load", func() (gobOrder, error) {
+				calls++
+				return want, nil
+			})
+			if err != nil {
+				return err
+			}
+			*got = order
+			if runs == 1 {
+				return errors.New("crash after step")
+			}
+			return nil
+		}
+	}
+
+	var first, replayed gobOrder
+	eng.Execute("gob-1", workflow(&first))
+	if err := eng.Execute("gob-1", workflow(&replayed)); err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected step to run once, ran %d times", calls)
+	}
+
+	if replayed.Total != want.Total || !bytes.Equal(replayed.Payload, want.Payload) {
+		t.Errorf("expected %+v, got %+v", want, replayed)
+	}
+	if _, offset := replayed.PlacedAt.Zone(); !replayed.PlacedAt.Equal(want.PlacedAt) || offset != 5*3600+1800 {
+		t.Errorf("expected time %v, got %v", want.PlacedAt, replayed.PlacedAt)
+	}
+	if big, ok := replayed.Extra["big"].(int64); !ok || big != 9007199254740993 {
+		t.Errorf("expected exact int64 in interface map, got %#v", replayed.Extra["big"])
+	}
+}
+
+func TestGobCodecInput(t *testing.T) {
+	dbPath := "./test_gob_input.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath, WithCodec(NewGobCodec()))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	type args struct {
+		Count int64
+		Raw   []byte
+	}
+	orders := NewWorkflow("gob-orders", func(ctx *Context, in args) (args, error) {
+		return in, nil
+	})
+	orders.Register(eng)
+
+	out, err := orders.Execute(eng, "gob-in-1", args{Count: 1 << 60, Raw: []byte("x\x00y")})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if out.Count != 1<<60 || string(out.Raw) != "x\x00y" {
+		t.Errorf("unexpected output %+v", out)
+	}
+}
@@ -0,0 +1,189 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Group is a set of concurrent functions waited for together, independently
+// of the run's Go and Wait and of other groups. Groups nest: a child group
+// created with Group.Group is part of its parent, whose Wait also waits for
+// it. The run doesn't complete until every group has finished.
+type Group struct {
+	ctx      *Context
+	seq      int // position among the run's groups, for stable step IDs
+	runCtx   context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	changed  *sync.Cond // signaled when a function returns
+	started  int
+	running  int
+	first    int  // index of the first function to succeed, or -1
+	won      bool // WaitAny returned; later failures are discarded
+	failures []parallelFailure
+	children []*Group
+}
+
+// newGroup creates a group whose context is derived from parent
+func (ctx *Context) newGroup(parent context.Context) *Group {
+	ctx.mu.Lock()
+	ctx.groupSeq++
+	seq := ctx.groupSeq
+	ctx.mu.Unlock()
+
+	g := &Group{ctx: ctx, seq: seq, first: -1}
+	g.runCtx, g.cancel = context.WithCancel(parent)
+	g.changed = sync.NewCond(&g.mu)
+	return g
+}
+
+// Group starts a new group of concurrent functions, e.g. for one parallel
+// phase of a workflow
+func (ctx *Context) Group() *Group {
+	g := ctx.newGroup(ctx.runCtx)
+	ctx.mu.Lock()
+	ctx.groups = append(ctx.groups, g)
+	ctx.mu.Unlock()
+	return g
+}
+
+// Group starts a child group whose functions g.Wait also waits for
+func (g *Group) Group() *Group {
+	child := g.ctx.newGroup(g.runCtx)
+	g.mu.Lock()
+	g.children = append(g.children, child)
+	g.mu.Unlock()
+	return child
+}
+
+// Context returns a context canceled with the workflow, and once WaitAny has
+// a winner. Pass it to the calls a group's steps make, so the functions that
+// lost stop instead of running to completion.
+func (g *Group) Context() context.Context {
+	return g.runCtx
+}
+
+// Go runs a function concurrently as part of the group
+func (g *Group) Go(fn func() error) {
+	g.mu.Lock()
+	index := g.started
+	g.started++
+	g.running++
+	g.mu.Unlock()
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		err := callWorkflow(fn)
+
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.running--
+		switch {
+		case err == nil && g.first < 0:
+			g.first = index
+		case err != nil && !g.won:
+			g.failures = append(g.failures, parallelFailure{index, err})
+		}
+		g.changed.Broadcast()
+	}()
+}
+
+// Wait waits for the group's functions and child groups, and reports their
+// failures as Context.Wait does. A child's failures are reported as one
+// error, after the group's own, unless the child's Wait already reported them.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+
+	g.mu.Lock()
+	children := g.children
+	g.children = nil
+	g.mu.Unlock()
+
+	var childFailures []parallelFailure
+	for _, child := range children {
+		if err := child.Wait(); err != nil {
+			childFailures = append(childFailures, parallelFailure{err: err})
+		}
+	}
+
+	g.mu.Lock()
+	failures := g.failures
+	g.failures = nil
+	index := g.started
+	won := g.won
+	g.mu.Unlock()
+
+	if won {
+		// Everything but the winner was canceled
+		return nil
+	}
+	for _, f := range childFailures {
+		f.index = index
+		index++
+		failures = append(failures, f)
+	}
+	return collectParallelErrors(failures)
+}
+
+// WaitAny waits until the first of the group's functions succeeds, cancels
+// the group's context and returns that function's index, e.g. to take the
+// first of two hedged requests. The other functions are not waited for, and
+// their failures are discarded; the run still waits for them before it
+// completes. The winner is recorded, so a resumed run picks the same one.
+// If every function fails, WaitAny returns -1 and their errors as Wait does.
+func (g *Group) WaitAny() (int, error) {
+	var waitErr error
+	winner, err := Step(g.ctx, fmt.Sprintf("group:%d:any", g.seq), func() (int, error) {
+		g.mu.Lock()
+		for g.first < 0 && g.running > 0 {
+			g.changed.Wait()
+		}
+		first := g.first
+		g.mu.Unlock()
+
+		if first < 0 {
+			waitErr = g.Wait()
+			if waitErr == nil {
+				return -1, nil
+			}
+			return -1, waitErr
+		}
+		return first, nil
+	}, withStepKind(StepKindGroup))
+	if waitErr != nil {
+		return -1, waitErr
+	}
+	if err != nil {
+		return -1, err
+	}
+
+	if winner >= 0 {
+		g.mu.Lock()
+		g.won = true
+		g.failures = nil
+		g.mu.Unlock()
+		g.cancel()
+	}
+	return winner, nil
+}
+
+// waitGroups waits for every group of the run, returning the first failure
+// no Group.Wait reported
+func (ctx *Context) waitGroups() error {
+	ctx.mu.Lock()
+	groups := ctx.groups
+	ctx.groups = nil
+	ctx.mu.Unlock()
+
+	var first error
+	for _, g := range groups {
+		if err := g.Wait(); err != nil && first == nil {
+			first = err
+		}
+		g.cancel()
+	}
+	return first
+}
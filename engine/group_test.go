@@ -0,0 +1,220 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroupWaitsOnlyForItsOwnWork(t *testing.T) {
+	dbPath := "./test_group.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	var slowDone, phaseDone atomic.Bool
+	err = eng.Execute("phases", func(ctx *Context) error {
+		// Unrelated background work started with ctx.Go
+		ctx.Go(func() error {
+			_, err := Step(ctx, "slow-report", func() (bool, error) {
+				time.Sleep(200 * time.Millisecond)
+				slowDone.Store(true)
+				return true, nil
+			})
+			return err
+		})
+
+		fetch := ctx.Group()
+		for i := 0; i < 3; i++ {
+			fetch.Go(func() error {
+				_, err := Step(ctx, fmt.Sprintf("fetch-%d", i), func() (int, error) { return i, nil })
+				return err
+			})
+		}
+		if err := fetch.Wait(); err != nil {
+			return err
+		}
+		if slowDone.Load() {
+			t.Error("expected the group's Wait not to wait for ctx.Go work")
+		}
+		phaseDone.Store(true)
+		return ctx.Wait()
+	})
+	if err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	if !phaseDone.Load() || !slowDone.Load() {
+		t.Error("expected both phases to finish")
+	}
+}
+
+func TestNestedGroupFailuresReachParent(t *testing.T) {
+	dbPath := "./test_group_nested.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	var waitErr error
+	eng.Execute("nested", func(ctx *Context) error {
+		outer := ctx.Group()
+		outer.Go(func() error {
+			_, err := Step(ctx, "outer", func() (int, error) { return 0, errBadFile })
+			return err
+		})
+		inner := outer.Group()
+		inner.Go(func() error {
+			_, err := Step(ctx, "inner", func() (int, error) { return 0, errBadFile })
+			return err
+		})
+		waitErr = outer.Wait()
+		return waitErr
+	})
+
+	var pe *ParallelError
+	if !errors.As(waitErr, &pe) {
+		t.Fatalf("expected *ParallelError, got %T: %v", waitErr, waitErr)
+	}
+	if len(pe.Errors) != 2 {
+		t.Fatalf("expected outer and inner failures, got %v", pe.Errors)
+	}
+	if steps := pe.FailedSteps(); len(steps) != 2 || steps[0] != "outer" || steps[1] != "inner" {
+		t.Errorf("expected failed steps [outer inner], got %v", steps)
+	}
+}
+
+func TestUnwaitedGroupFailsRun(t *testing.T) {
+	dbPath := "./test_group_unwaited.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	err = eng.Execute("unwaited", func(ctx *Context) error {
+		g := ctx.Group()
+		g.Go(func() error {
+			_, err := Step(ctx, "late", func() (int, error) {
+				time.Sleep(50 * time.Millisecond)
+				return 0, errBadFile
+			})
+			return err
+		})
+		return nil
+	})
+	if !errors.Is(err, errBadFile) {
+		t.Fatalf("expected the group's failure to fail the run, got %v", err)
+	}
+	if status, _ := eng.GetWorkflowStatus("unwaited"); status != "failed" {
+		t.Errorf("expected failed status, got %s", status)
+	}
+}
+
+func TestGroupWaitAnyTakesFirstSuccess(t *testing.T) {
+	dbPath := "./test_group_any.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	var slowCanceled atomic.Bool
+	var winner int
+	workflow := func(ctx *Context) error {
+		quotes := ctx.Group()
+		quotes.Go(func() error {
+			_, err := Step(ctx, "quote-ups", func() (int, error) {
+				select {
+				case <-quotes.Context().Done():
+					slowCanceled.Store(true)
+					return 0, quotes.Context().Err()
+				case <-time.After(2 * time.Second):
+					return 12, nil
+				}
+			})
+			return err
+		})
+		quotes.Go(func() error {
+			_, err := Step(ctx, "quote-fedex", func() (int, error) {
+				time.Sleep(20 * time.Millisecond)
+				return 15, nil
+			})
+			return err
+		})
+		var err error
+		winner, err = quotes.WaitAny()
+		return err
+	}
+
+	start := time.Now()
+	if err := eng.Execute("hedged", workflow); err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	if winner != 1 {
+		t.Errorf("expected fedex (1) to win, got %d", winner)
+	}
+	if !slowCanceled.Load() {
+		t.Error("expected the losing request to be canceled")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the run not to wait for the slow request, took %v", elapsed)
+	}
+
+	// A resumed run takes the recorded winner
+	if err := eng.storage.UpdateWorkflowStatus("hedged", "running"); err != nil {
+		t.Fatalf("failed to reset status: %v", err)
+	}
+	winner = -1
+	if err := eng.Execute("hedged", workflow); err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if winner != 1 {
+		t.Errorf("expected replay to pick fedex (1) again, got %d", winner)
+	}
+}
+
+func TestGroupWaitAnyAllFail(t *testing.T) {
+	dbPath := "./test_group_any_fail.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	winner := 0
+	err = eng.Execute("hedged-fail", func(ctx *Context) error {
+		g := ctx.Group()
+		for i := 0; i < 2; i++ {
+			g.Go(func() error {
+				_, err := Step(ctx, fmt.Sprintf("try-%d", i), func() (int, error) { return 0, errBadFile })
+				return err
+			})
+		}
+		var err error
+		winner, err = g.WaitAny()
+		return err
+	})
+	var pe *ParallelError
+	if !errors.As(err, &pe) || len(pe.Errors) != 2 {
+		t.Fatalf("expected both failures, got %v", err)
+	}
+	if winner != -1 {
+		t.Errorf("expected -1 when none succeeds, got %d", winner)
+	}
+}
@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// WithHeader attaches metadata such as a correlation or trace ID to a run.
+// Headers are persisted with the run, readable with ctx.Header from the
+// workflow and its steps on any worker, and inherited by children it starts.
+func WithHeader(name, value string) WorkflowOption {
+	return func(o *workflowOptions) {
+		if o.headers == nil {
+			o.headers = make(map[string]string)
+		}
+		o.headers[name] = value
+	}
+}
+
+// withHeaders attaches several headers, keeping any already set
+func withHeaders(headers map[string]string) WorkflowOption {
+	return func(o *workflowOptions) {
+		for name, value := range headers {
+			if _, ok := o.headers[name]; !ok {
+				WithHeader(name, value)(o)
+			}
+		}
+	}
+}
+
+// Header returns the value of a header the run was started with, or ""
+func (ctx *Context) Header(name string) string {
+	return ctx.headers[name]
+}
+
+// Headers returns a copy of every header the run was started with
+func (ctx *Context) Headers() map[string]string {
+	headers := make(map[string]string, len(ctx.headers))
+	for name, value := range ctx.headers {
+		headers[name] = value
+	}
+	return headers
+}
+
+// GetWorkflowHeaders returns the headers a run was started with
+func (e *Engine) GetWorkflowHeaders(workflowID string) (map[string]string, error) {
+	if _, err := e.storage.GetWorkflowStatus(workflowID); err != nil {
+		return nil, err
+	}
+	return e.storage.GetWorkflowHeaders(workflowID)
+}
+
+// SetWorkflowHeaders records the headers of a workflow unless already set
+func (s *Storage) SetWorkflowHeaders(workflowID string, headers []byte) error {
+	return s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			"UPDATE workflows SET headers = COALESCE(headers, ?) WHERE workflow_id = ?",
+			headers, workflowID,
+		)
+		return err
+	})
+}
+
+// GetWorkflowHeaders loads the headers of a workflow, or nil if none were given
+func (s *Storage) GetWorkflowHeaders(workflowID string) (map[string]string, error) {
+	var data []byte
+	err := s.db.QueryRow(
+		"SELECT headers FROM workflows WHERE workflow_id = ?",
+		workflowID,
+	).Scan(&data)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get workflow headers: %w", err)
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var headers map[string]string
+	if err := json.Unmarshal(data, &headers); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal workflow headers: %w", err)
+	}
+	return headers, nil
+}
@@ -0,0 +1,55 @@
+package engine
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestHeadersPropagate(t *testing.T) {
+	dbPath := "./test_headers.db"
+	defer os.Remove(dbPath)
+
+	engine, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	childHeaders := make(chan map[string]string, 1)
+	engine.Register("audit", func(ctx *Context) error {
+		childHeaders <- ctx.Headers()
+		return nil
+	})
+
+	var inStep string
+	run := func(ctx *Context) error {
+		inStep, _ = Step(ctx, "call-api", func() (string, error) {
+			return ctx.Header("trace_id"), nil
+		})
+		_, err := ctx.StartChildDetached("audit", "audit", nil, WithHeader("tenant", "acme"))
+		return err
+	}
+	if err := engine.Execute("headers-1", run, WithHeader("trace_id", "abc123")); err != nil {
+		t.Fatalf("failed to execute: %v", err)
+	}
+	if inStep != "abc123" {
+		t.Errorf("expected the step to see trace_id abc123, got %q", inStep)
+	}
+
+	// Headers are persisted with the run, so resumes on any worker see them
+	headers, err := engine.GetWorkflowHeaders("headers-1")
+	if err != nil || headers["trace_id"] != "abc123" {
+		t.Errorf("expected persisted trace_id, got %v: %v", headers, err)
+	}
+
+	engine.StartWorker(WorkerConfig{PollInterval: 10 * time.Millisecond})
+	select {
+	case got := <-childHeaders:
+		if got["trace_id"] != "abc123" || got["tenant"] != "acme" {
+			t.Errorf("expected the child to inherit trace_id and add tenant, got %v", got)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("child workflow never ran")
+	}
+}
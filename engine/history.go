@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// StepRecord is one persisted step of a workflow run
+type StepRecord struct {
+	StepID      string
+	StepKey     string
+	SequenceNum int64
+	Kind        string
+	Group       string   // prefix of the Map call that started the step, if any
+	Tags        []string // labels given WithTags
+	Status      string   // in_progress, completed, failed or canceled
+	Output      []byte   // JSON-encoded result, set once completed
+	OutputType  string   // fingerprint of the result's Go type, e.g. {"id":int}
+	Error       string
+	WorkerID    string
+	Hostname    string // host and process that last executed the step
+	PID         int
+	StartedAt   time.Time
+	CompletedAt time.Time     // zero while in progress
+	Attempts    []StepAttempt // every execution of the step's function, oldest first
+}
+
+// Duration returns how long the step ran, or zero if it has not finished
+func (r *StepRecord) Duration() time.Duration {
+	if r.CompletedAt.IsZero() {
+		return 0
+	}
+	return r.CompletedAt.Sub(r.StartedAt)
+}
+
+// Executor returns the worker, host and process that last executed the step
+func (r *StepRecord) Executor() Executor {
+	return Executor{WorkerID: r.WorkerID, Hostname: r.Hostname, PID: r.PID}
+}
+
+// GetHistory returns every recorded step of a workflow in sequence order
+func (e *Engine) GetHistory(workflowID string) ([]StepRecord, error) {
+	if _, err := e.reads.GetWorkflowStatus(workflowID); err != nil {
+		return nil, err
+	}
+	return e.reads.ListSteps(workflowID)
+}
+
+// ListSteps loads all steps of a workflow ordered by sequence number
+func (s *Storage) ListSteps(workflowID string) ([]StepRecord, error) {
+	steps, err := s.ListStepsForWorkflows([]string{workflowID})
+	if err != nil {
+		return nil, err
+	}
+	return steps[workflowID], nil
+}
+
+// ListStepsForWorkflows loads the steps of several workflows in one query,
+// keyed by workflow ID and ordered by sequence number
+func (s *Storage) ListStepsForWorkflows(workflowIDs []string) (map[string][]StepRecord, error) {
+	if len(workflowIDs) == 0 {
+		return nil, nil
+	}
+	args := make([]interface{}, len(workflowIDs))
+	for i, id := range workflowIDs {
+		args[i] = id
+	}
+
+	rows, err := s.db.Query(
+		fmt.Sprintf(
+			`SELECT s.workflow_id, s.step_id, s.step_key, s.sequence_num, s.kind, COALESCE(s.step_group, ''), s.tags,
+			   s.status, `+stepOutputColumn+`, COALESCE(s.output_type, ''),
+			   s.error, s.worker_id, COALESCE(s.hostname, ''), COALESCE(s.pid, 0), s.started_at, s.completed_at
+			 FROM steps s LEFT JOIN blobs b ON b.id = s.output_blob
+			 WHERE s.workflow_id IN (%s) ORDER BY s.workflow_id, s.sequence_num`,
+			placeholders(len(workflowIDs)),
+		),
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list steps: %w", err)
+	}
+	defer rows.Close()
+
+	steps := make(map[string][]StepRecord, len(workflowIDs))
+	for rows.Next() {
+		var workflowID string
+		var r StepRecord
+		var errMsg, workerID, tags sql.NullString
+		var completedAt sql.NullTime
+		if err := rows.Scan(&workflowID, &r.StepID, &r.StepKey, &r.SequenceNum, &r.Kind, &r.Group, &tags, &r.Status,
+			&r.Output, &r.OutputType, &errMsg, &workerID, &r.Hostname, &r.PID, &r.StartedAt, &completedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan step: %w", err)
+		}
+		r.Tags = decodeTags(tags)
+		r.Error = errMsg.String
+		r.WorkerID = workerID.String
+		r.CompletedAt = completedAt.Time
+		steps[workflowID] = append(steps[workflowID], r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	attempts, err := s.listStepAttempts(workflowIDs)
+	if err != nil {
+		return nil, err
+	}
+	for id, records := range steps {
+		for i := range records {
+			records[i].Attempts = attempts[id][records[i].StepKey]
+		}
+	}
+	return steps, nil
+}
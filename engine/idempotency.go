@@ -0,0 +1,44 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+// IdempotencyScope decides which attempts of a step share an idempotency key
+type IdempotencyScope int
+
+const (
+	// IdempotencyPerStep gives every attempt of a step the same key, so an
+	// external API deduplicates a retry of a call that actually went through
+	IdempotencyPerStep IdempotencyScope = iota
+	// IdempotencyPerAttempt gives each attempt its own key, for APIs that
+	// remember failed requests and would replay their error to a retry
+	IdempotencyPerAttempt
+)
+
+// WithIdempotencyScope sets which attempts share StepInfo.IdempotencyKey;
+// the default is IdempotencyPerStep
+func WithIdempotencyScope(scope IdempotencyScope) StepOption {
+	return func(o *stepOptions) {
+		o.idempotency = scope
+	}
+}
+
+// IdempotencyKey returns a token identifying this step of this run, stable
+// across retries and resumes, to pass to APIs such as Stripe's
+// Idempotency-Key header. It is derived from the workflow ID and step key,
+// plus the attempt number under IdempotencyPerAttempt, so reusing a
+// workflow ID for a new run also reuses its keys.
+func (info StepInfo) IdempotencyKey() string {
+	h := sha256.New()
+	h.Write([]byte(info.WorkflowID))
+	h.Write([]byte{0})
+	h.Write([]byte(info.StepKey))
+	if info.idempotency == IdempotencyPerAttempt {
+		h.Write([]byte{0})
+		h.Write([]byte(strconv.Itoa(info.Attempt)))
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyKeys(t *testing.T) {
+	dbPath := "./test_idempotency.db"
+	defer os.Remove(dbPath)
+
+	engine, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	keys := make(map[string][]string)
+	retry := WithRetry(RetryPolicy{MaxAttempts: 2, InitialInterval: time.Millisecond})
+	flaky := func(step string) func(StepInfo) (int, error) {
+		return func(info StepInfo) (int, error) {
+			keys[step] = append(keys[step], info.IdempotencyKey())
+			if info.Attempt == 1 {
+				return 0, errors.New("connection reset")
+			}
+			return 1, nil
+		}
+	}
+	workflow := func(ctx *Context) error {
+		if _, err := StepWithInfo(ctx, "charge", flaky(ctx.WorkflowID+"/charge"), retry); err != nil {
+			return err
+		}
+		_, err := StepWithInfo(ctx, "refund", flaky(ctx.WorkflowID+"/refund"), retry, WithIdempotencyScope(IdempotencyPerAttempt))
+		return err
+	}
+
+	for _, id := range []string{"pay-1", "pay-2"} {
+		if err := engine.Execute(id, workflow); err != nil {
+			t.Fatalf("workflow %s failed: %v", id, err)
+		}
+	}
+
+	charge := keys["pay-1/charge"]
+	if len(charge) != 2 || charge[0] != charge[1] || len(charge[0]) != 32 {
+		t.Errorf("expected one 32-character key across retries, got %v", charge)
+	}
+	if refund := keys["pay-1/refund"]; len(refund) != 2 || refund[0] == refund[1] {
+		t.Errorf("expected a new key per attempt, got %v", refund)
+	}
+	if charge[0] == keys["pay-2/charge"][0] || charge[0] == keys["pay-1/refund"][0] {
+		t.Error("expected keys to differ between steps and workflows")
+	}
+
+	info := StepInfo{WorkflowID: "pay-1", StepKey: "charge:1", Attempt: 7}
+	if info.IdempotencyKey() != charge[0] {
+		t.Error("expected the key to depend only on the workflow ID and step key")
+	}
+}
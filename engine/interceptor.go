@@ -0,0 +1,115 @@
+package engine
+
+import "time"
+
+// StepInterceptor is notified of every step a worker executes, once its
+// result is recorded or its failure saved. Steps replayed from history are
+// not reported again. Interceptors run on the step's goroutine, so slow ones
+// delay the workflow.
+type StepInterceptor interface {
+	StepFinished(ctx *Context, step *FinishedStep)
+}
+
+// FinishedStep describes one executed step
+type FinishedStep struct {
+	StepID     string
+	StepKey    string
+	Kind       string
+	Output     []byte // encoded result; nil if the step failed or streamed its output
+	OutputSize int64  // size of the encoded or streamed result
+	Err        error
+	Duration   time.Duration // from the step being marked in progress until it finished
+	Attempts   int           // executions of the step's function by this worker; 0 if served from the cache
+}
+
+// WithStepInterceptor adds interceptors, called in the order they were added
+func WithStepInterceptor(interceptors ...StepInterceptor) EngineOption {
+	return func(e *Engine) {
+		e.interceptors = append(e.interceptors, interceptors...)
+	}
+}
+
+// interceptStep reports an executed step to the engine's interceptors
+func (ctx *Context) interceptStep(id, stepKey, kind string, started time.Time, output []byte, err error) {
+	ctx.reportStep(&FinishedStep{
+		StepID:     id,
+		StepKey:    stepKey,
+		Kind:       kind,
+		Output:     output,
+		OutputSize: int64(len(output)),
+		Err:        err,
+		Duration:   time.Since(started),
+	})
+}
+
+// reportStep fills in the step's attempts and reports it to the engine's
+// interceptors and plugins
+func (ctx *Context) reportStep(step *FinishedStep) {
+	ctx.mu.Lock()
+	step.Attempts = ctx.attempts[step.StepKey]
+	delete(ctx.attempts, step.StepKey)
+	ctx.mu.Unlock()
+
+	for _, i := range ctx.engine.interceptors {
+		i.StepFinished(ctx, step)
+	}
+	ev := EngineEvent{
+		Type:       EventStepCompleted,
+		WorkflowID: ctx.WorkflowID,
+		StepID:     step.StepID,
+		StepKey:    step.StepKey,
+		Kind:       step.Kind,
+		Attempt:    step.Attempts,
+		Output:     step.Output,
+		Err:        step.Err,
+		Duration:   step.Duration,
+	}
+	if step.Err != nil {
+		ev.Type = EventStepFailed
+	}
+	ctx.engine.emit(ev)
+}
+
+// WorkflowHook is notified when a run this engine executed completes, fails
+// or is canceled, after its status is recorded. Hooks run before Execute
+// returns, so slow ones delay the caller.
+type WorkflowHook interface {
+	WorkflowFinished(run *FinishedWorkflow)
+}
+
+// FinishedWorkflow describes a run that reached a final status
+type FinishedWorkflow struct {
+	WorkflowID string
+	Name       string // registered name; empty for workflows started with Execute
+	Status     string // completed, failed or canceled
+	Err        error  // why the run failed; nil otherwise
+	CreatedAt  time.Time
+	FinishedAt time.Time
+}
+
+// WithWorkflowHook adds workflow hooks, called in the order they were added
+func WithWorkflowHook(hooks ...WorkflowHook) EngineOption {
+	return func(e *Engine) {
+		e.hooks = append(e.hooks, hooks...)
+	}
+}
+
+// finishWorkflow reports a run's final status to the engine's hooks and plugins
+func (e *Engine) finishWorkflow(workflowID, status string, err error) {
+	if len(e.hooks) == 0 && !e.bus.active() {
+		return
+	}
+	run := &FinishedWorkflow{WorkflowID: workflowID, Status: status, Err: err, FinishedAt: time.Now()}
+	if info, infoErr := e.GetWorkflow(workflowID); infoErr == nil {
+		run.Name = info.Name
+		run.CreatedAt = info.CreatedAt
+	}
+	for _, h := range e.hooks {
+		h.WorkflowFinished(run)
+	}
+	ev := EngineEvent{Type: EngineEventType("workflow." + status), WorkflowID: workflowID, Name: run.Name, Err: err, Time: run.FinishedAt}
+	if !run.CreatedAt.IsZero() {
+		ev.Duration = run.FinishedAt.Sub(run.CreatedAt)
+	}
+	e.emit(ev)
+}
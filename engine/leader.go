@@ -0,0 +1,221 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultLeaseTTL is the lease duration used by RunSingleton when ttl is zero
+const DefaultLeaseTTL = 15 * time.Second
+
+// Lease is a named, time-bounded lock held by a single worker
+// Token increases every time the lease changes hands and acts as a fencing token
+type Lease struct {
+	Name      string
+	Holder    string
+	Token     int64
+	ExpiresAt time.Time
+}
+
+// leaderState tracks which singleton leases this engine currently holds
+type leaderState struct {
+	mu   sync.Mutex
+	held map[string]int64
+}
+
+// ErrLeaseExpired is the context.Cause of a singleton's context that ended
+// because its lease ran out before it could be renewed
+var ErrLeaseExpired = errors.New("singleton lease expired")
+
+// RunSingleton runs fn on exactly one engine sharing this database.
+// Every engine may call it; only the lease holder runs fn, and the context
+// passed to fn is canceled as soon as leadership is lost or the engine closes.
+// It also ends when the lease expires, with cause ErrLeaseExpired, even if
+// the renewal that would notice is delayed; every renewal moves that
+// deadline to the new expiry.
+func (e *Engine) RunSingleton(name string, ttl time.Duration, fn func(ctx context.Context)) {
+	if ttl <= 0 {
+		ttl = DefaultLeaseTTL
+	}
+
+	e.bg.Add(1)
+	go func() {
+		defer e.bg.Done()
+
+		var leading *leadership
+		stepDown := func() {
+			if leading != nil {
+				leading.stop()
+				leading = nil
+			}
+			e.setLeader(name, 0, false)
+		}
+
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+
+		for {
+			lease, acquired, err := e.storage.AcquireLease(name, e.workerID, ttl)
+			if err != nil {
+				fmt.Printf("[LEADER] lease %s: %v\n", name, err)
+			}
+
+			if acquired && leading != nil && !leading.extend(lease.ExpiresAt) {
+				// fn was stopped when the previous lease ran out; start it afresh
+				fmt.Printf("[LEADER] %s let %s expire\n", e.workerID, name)
+				stepDown()
+			}
+			if acquired {
+				e.setLeader(name, lease.Token, true)
+				if leading == nil {
+					leading = startLeading(fn, lease.ExpiresAt)
+					fmt.Printf("[LEADER] %s acquired %s (token %d)\n", e.workerID, name, lease.Token)
+				}
+			} else if leading != nil {
+				fmt.Printf("[LEADER] %s lost %s\n", e.workerID, name)
+				stepDown()
+			}
+
+			select {
+			case <-e.stop:
+				stepDown()
+				e.storage.ReleaseLease(name, e.workerID)
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// leadership is one run of a singleton's fn while this engine holds its lease
+type leadership struct {
+	cancel context.CancelCauseFunc
+	expiry *time.Timer // cancels the context when the lease runs out
+	done   chan struct{}
+}
+
+// startLeading runs fn in its own goroutine under a context that is
+// canceled at expires unless extend moves the deadline first
+func startLeading(fn func(ctx context.Context), expires time.Time) *leadership {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	l := &leadership{cancel: cancel, done: make(chan struct{})}
+	l.expiry = time.AfterFunc(time.Until(expires), func() { cancel(ErrLeaseExpired) })
+	go func() {
+		defer close(l.done)
+		fn(ctx)
+	}()
+	return l
+}
+
+// extend moves the deadline to a renewed lease's expiry. It reports false
+// if the previous deadline already passed and fn's context is canceled.
+func (l *leadership) extend(expires time.Time) bool {
+	if !l.expiry.Stop() {
+		return false
+	}
+	l.expiry.Reset(time.Until(expires))
+	return true
+}
+
+// stop cancels fn's context and waits for fn to return
+func (l *leadership) stop() {
+	l.expiry.Stop()
+	l.cancel(context.Canceled)
+	<-l.done
+}
+
+// IsLeader reports whether this engine currently holds the named singleton lease
+func (e *Engine) IsLeader(name string) bool {
+	e.leaders.mu.Lock()
+	defer e.leaders.mu.Unlock()
+	_, ok := e.leaders.held[name]
+	return ok
+}
+
+// GetLease returns the current holder of a named lease, if any
+func (e *Engine) GetLease(name string) (*Lease, error) {
+	return e.storage.GetLease(name)
+}
+
+func (e *Engine) setLeader(name string, token int64, leader bool) {
+	e.leaders.mu.Lock()
+	defer e.leaders.mu.Unlock()
+	if e.leaders.held == nil {
+		e.leaders.held = make(map[string]int64)
+	}
+	if leader {
+		e.leaders.held[name] = token
+	} else {
+		delete(e.leaders.held, name)
+	}
+}
+
+// AcquireLease takes or renews a lease. It succeeds if the lease is free,
+// expired, or already held by holder; the token is bumped on every handover.
+func (s *Storage) AcquireLease(name, holder string, ttl time.Duration) (*Lease, bool, error) {
+	now := time.Now()
+	expires := now.Add(ttl)
+
+	var affected int64
+	err := s.retryOnBusy(func() error {
+		res, err := s.db.Exec(
+			`INSERT INTO leases (name, holder, token, expires_at_ms) VALUES (?, ?, 1, ?)
+			 ON CONFLICT(name) DO UPDATE SET
+			   token = CASE WHEN leases.holder = excluded.holder THEN leases.token ELSE leases.token + 1 END,
+			   holder = excluded.holder,
+			   expires_at_ms = excluded.expires_at_ms
+			 WHERE leases.holder = excluded.holder OR leases.expires_at_ms < ?`,
+			name, holder, expires.UnixMilli(), now.UnixMilli(),
+		)
+		if err != nil {
+			return err
+		}
+		affected, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to acquire lease: %w", err)
+	}
+
+	lease, err := s.GetLease(name)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return lease, affected > 0 && lease != nil && lease.Holder == holder, nil
+}
+
+// ReleaseLease gives up a lease early so another holder can take over immediately
+func (s *Storage) ReleaseLease(name, holder string) error {
+	return s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			"UPDATE leases SET expires_at_ms = 0 WHERE name = ? AND holder = ?",
+			name, holder,
+		)
+		return err
+	})
+}
+
+// GetLease loads a lease by name, returning nil if it has never been taken
+func (s *Storage) GetLease(name string) (*Lease, error) {
+	var l Lease
+	var expiresMs int64
+	err := s.db.QueryRow(
+		"SELECT name, holder, token, expires_at_ms FROM leases WHERE name = ?",
+		name,
+	).Scan(&l.Name, &l.Holder, &l.Token, &expiresMs)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lease: %w", err)
+	}
+
+	l.ExpiresAt = time.UnixMilli(expiresMs)
+	return &l, nil
+}
@@ -0,0 +1,123 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLeaderElectionFailover(t *testing.T) {
+	dbPath := "./test_leader.db"
+	defer os.Remove(dbPath)
+
+	eng1, err := NewEngine(dbPath, WithWorkerID("node-1"))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	eng2, err := NewEngine(dbPath, WithWorkerID("node-2"))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng2.Close()
+
+	var running int32
+	var maxRunning int32
+	subsystem := func(ctx context.Context) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			old := atomic.LoadInt32(&maxRunning)
+			if n <= old || atomic.CompareAndSwapInt32(&maxRunning, old, n) {
+				break
+			}
+		}
+		<-ctx.Done()
+		atomic.AddInt32(&running, -1)
+	}
+
+	ttl := 90 * time.Millisecond
+	eng1.RunSingleton("scheduler", ttl, subsystem)
+	time.Sleep(20 * time.Millisecond)
+	eng2.RunSingleton("scheduler", ttl, subsystem)
+	time.Sleep(100 * time.Millisecond)
+
+	if !eng1.IsLeader("scheduler") || eng2.IsLeader("scheduler") {
+		t.Fatalf("expected node-1 to lead, got node-1=%v node-2=%v",
+			eng1.IsLeader("scheduler"), eng2.IsLeader("scheduler"))
+	}
+
+	first, err := eng2.GetLease("scheduler")
+	if err != nil || first == nil {
+		t.Fatalf("failed to read lease: %v", err)
+	}
+
+	// Closing the leader releases the lease; node-2 takes over
+	eng1.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	if !eng2.IsLeader("scheduler") {
+		t.Fatalf("expected node-2 to take over leadership")
+	}
+
+	second, err := eng2.GetLease("scheduler")
+	if err != nil || second == nil {
+		t.Fatalf("failed to read lease: %v", err)
+	}
+	if second.Holder != "node-2" || second.Token <= first.Token {
+		t.Errorf("expected fencing token to increase on handover: %+v -> %+v", first, second)
+	}
+	if atomic.LoadInt32(&maxRunning) != 1 {
+		t.Errorf("expected at most one running instance, saw %d", maxRunning)
+	}
+}
+
+func TestSingletonStopsWhenLeaseExpires(t *testing.T) {
+	dbPath := "./test_leader_expiry.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath, WithWorkerID("node-1"))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	var starts int32
+	stopped := make(chan error, 2)
+	eng.RunSingleton("reaper", 90*time.Millisecond, func(ctx context.Context) {
+		atomic.AddInt32(&starts, 1)
+		<-ctx.Done()
+		stopped <- context.Cause(ctx)
+	})
+
+	// Renewals keep moving the deadline past the first lease's expiry
+	time.Sleep(300 * time.Millisecond)
+	if !eng.IsLeader("reaper") || atomic.LoadInt32(&starts) != 1 {
+		t.Fatalf("expected one uninterrupted run, leader=%v starts=%d", eng.IsLeader("reaper"), starts)
+	}
+
+	// Holding the only connection delays the renewal past the lease's expiry
+	tx, err := eng.storage.db.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin: %v", err)
+	}
+	select {
+	case cause := <-stopped:
+		if !errors.Is(cause, ErrLeaseExpired) {
+			t.Errorf("expected ErrLeaseExpired, got %v", cause)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("singleton kept running after its lease expired")
+	}
+	tx.Rollback()
+
+	// Once the lease is renewed again, fn starts afresh
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&starts) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("singleton was not restarted after the lease was renewed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
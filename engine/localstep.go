@@ -0,0 +1,280 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// localStepBatchSize is how many local step results are buffered before
+// they are written without waiting for the next durable step
+const localStepBatchSize = 100
+
+// localStepRecord is a completed local step that isn't persisted yet
+type localStepRecord struct {
+	stepKey    string
+	stepID     string
+	seqNum     int64
+	tags       []string
+	startedAt  time.Time
+	scheduled  time.Time
+	output     []byte
+	outputType string // fingerprint of the result type
+}
+
+// LocalStep runs a short operation, such as a lookup or a calculation, as a
+// step without writing to storage while it runs. Its result is buffered and
+// persisted together with the other buffered results right before the next
+// durable step starts, or when the run ends, saving the round trips a Step
+// makes. If the process dies before then, the buffered steps run again when
+// the workflow resumes, so fn must be safe to repeat.
+//
+// Attempts are retried in memory per WithRetry and the step may be tagged
+// with WithTags; options that need storage, such as WithTimeout, pools and
+// cache keys, are ignored. Results must fit the output size limit, since
+// local steps are never offloaded. Errors are returned as *StepError.
+func LocalStep[T any](ctx *Context, id string, fn func() (T, error), opts ...StepOption) (T, error) {
+	result, err := runLocalStep(ctx, id, fn, opts...)
+	if err != nil {
+		return result, &StepError{StepID: id, Err: err}
+	}
+	return result, nil
+}
+
+// runLocalStep executes or replays a local step
+func runLocalStep[T any](ctx *Context, id string, fn func() (T, error), opts ...StepOption) (T, error) {
+	var zero T
+	so := ctx.newStepOptions(opts)
+	seqNum, err := ctx.stepSequence(id)
+	if err != nil {
+		return zero, err
+	}
+	stepKey := generateStepKey(id, seqNum)
+
+	ctx.mu.Lock()
+	output, found := ctx.completedSteps[stepKey]
+	ctx.mu.Unlock()
+	if !found {
+		var err error
+		if output, found, err = ctx.storedStep(stepKey, seqNum); err != nil {
+			return zero, fmt.Errorf("failed to check step in database: %w", err)
+		}
+		if found {
+			if err := ctx.loadOutputType(stepKey); err != nil {
+				return zero, err
+			}
+		}
+	}
+	if found {
+		if output == nil {
+			var err error
+			if output, err = ctx.loadOffloaded(stepKey); err != nil {
+				return zero, err
+			}
+		}
+		var result T
+		if err := ctx.decodeResult(id, stepKey, output, &result, "cached"); err != nil {
+			return zero, err
+		}
+		ctx.mu.Lock()
+		ctx.completedSteps[stepKey] = output
+		ctx.mu.Unlock()
+		ctx.printf("[SKIPPED] %s (already completed)\n", id)
+		return result, nil
+	}
+
+	if err := ctx.doneErr(); err != nil {
+		return zero, err
+	}
+	if err := ctx.startStepWithinLimits(id, seqNum); err != nil {
+		return zero, err
+	}
+	if ctx.sim != nil {
+		return simulateStep[T](ctx, id, stepKey, seqNum, StepKindLocal)
+	}
+
+	scheduled := ctx.scheduleStep()
+	started := time.Now()
+	result, err := executeLocal(ctx, id, so.retry, fn)
+	if err == nil {
+		output, err = ctx.engine.codec.Marshal(result)
+		if err != nil {
+			return zero, fmt.Errorf("failed to marshal result: %w", err)
+		}
+		err = ctx.engine.validateStepOutput(id, output)
+	}
+	if err == nil {
+		var offload bool
+		if offload, err = ctx.checkOutputSize(id, so, output); offload {
+			err = fmt.Errorf("%w: local step %s returned %d bytes; use Step for large results", ErrOutputTooLarge, id, len(output))
+		}
+	}
+	if err == nil {
+		err = ctx.addHistoryBytes(id, output)
+	}
+	if err != nil {
+		// Failures are rare; record them like any other step's
+		ctx.recordLocalFailure(id, stepKey, seqNum, so, err)
+		ctx.interceptStep(id, stepKey, StepKindLocal, started, nil, err)
+		return zero, err
+	}
+
+	ctx.mu.Lock()
+	ctx.completedSteps[stepKey] = output
+	ctx.localSteps = append(ctx.localSteps, localStepRecord{stepKey, id, seqNum, so.tags, started, scheduled, output, fingerprintOf[T]()})
+	full := len(ctx.localSteps) >= localStepBatchSize
+	ctx.mu.Unlock()
+	ctx.interceptStep(id, stepKey, StepKindLocal, started, output, nil)
+
+	if full {
+		if err := ctx.flushLocalSteps(); err != nil {
+			return zero, err
+		}
+	}
+	return result, nil
+}
+
+// executeLocal runs fn, retrying per policy without recording attempts
+func executeLocal[T any](ctx *Context, id string, policy *RetryPolicy, fn func() (T, error)) (T, error) {
+	var zero T
+	for attempt := 1; ; attempt++ {
+		result, err := callStep(fn)
+		err = ctx.stoppedStepError(err)
+		if err == nil {
+			return result, nil
+		}
+		if errors.Is(err, ErrWorkflowCanceled) || errors.Is(err, ErrWorkflowTimeout) {
+			return zero, err
+		}
+		if policy == nil || attempt >= policy.MaxAttempts {
+			return zero, err
+		}
+
+		delay := policy.backoff(attempt)
+		if budgetErr := ctx.retryBudget.spend(delay); budgetErr != nil {
+			return zero, fmt.Errorf("%w (last error: %v)", budgetErr, err)
+		}
+		ctx.printf("[RETRY] %s attempt %d failed: %v (retrying in %v)\n", id, attempt, err, delay.Round(time.Millisecond))
+		select {
+		case <-ctx.engine.stop:
+			return zero, err
+		case <-ctx.Done():
+			return zero, ctx.doneErr()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// recordLocalFailure persists the buffered steps and the failed one, so the
+// run's history shows where it failed
+func (ctx *Context) recordLocalFailure(id, stepKey string, seqNum int64, so *stepOptions, err error) {
+	if flushErr := ctx.flushLocalSteps(); flushErr != nil {
+		ctx.printf("[LOCAL] %v\n", flushErr)
+		return
+	}
+	if markErr := ctx.storage.MarkStepInProgress(ctx.WorkflowID, stepKey, id, seqNum, StepKindLocal, so.group, so.tags, ctx.engine.executor, ctx.scheduleStep()); markErr != nil {
+		ctx.printf("[LOCAL] failed to record %s: %v\n", id, markErr)
+		return
+	}
+	ctx.failStep(stepKey, err)
+}
+
+// flushLocalSteps persists the buffered local step results when a batch
+// fills up and when the run ends; durable steps persist them with
+// markStepInProgress
+func (ctx *Context) flushLocalSteps() error {
+	ctx.mu.Lock()
+	batch := ctx.localSteps
+	ctx.localSteps = nil
+	ctx.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	if err := ctx.storage.SaveLocalSteps(ctx.WorkflowID, ctx.fencingToken, ctx.engine.executor, batch); err != nil {
+		// Keep them for the next flush
+		ctx.mu.Lock()
+		ctx.localSteps = append(batch, ctx.localSteps...)
+		ctx.mu.Unlock()
+		return fmt.Errorf("failed to save local steps: %w", err)
+	}
+	ctx.printf("[LOCAL] persisted %d local steps\n", len(batch))
+	return nil
+}
+
+// markStepInProgress marks a durable step started (zombie protection) in
+// the same transaction that persists the local steps sequenced before it
+func (ctx *Context) markStepInProgress(stepKey, id string, seqNum int64, kind, group string, tags []string) error {
+	ctx.mu.Lock()
+	batch := ctx.localSteps
+	ctx.localSteps = nil
+	ctx.mu.Unlock()
+
+	scheduled := ctx.scheduleStep()
+	err := ctx.storage.WithTx(func(tx StorageTx) error {
+		// The run may have been canceled by a process that can't reach it
+		if err := ctx.checkCanceled(tx.Storage); err != nil {
+			return err
+		}
+		if len(batch) > 0 {
+			if err := tx.SaveLocalSteps(ctx.WorkflowID, ctx.fencingToken, ctx.engine.executor, batch); err != nil {
+				return fmt.Errorf("failed to save local steps: %w", err)
+			}
+		}
+		if err := tx.MarkStepInProgress(ctx.WorkflowID, stepKey, id, seqNum, kind, group, tags, ctx.engine.executor, scheduled); err != nil {
+			return fmt.Errorf("failed to mark step in progress: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		// Keep them for the next flush
+		ctx.mu.Lock()
+		ctx.localSteps = append(batch, ctx.localSteps...)
+		ctx.mu.Unlock()
+		return err
+	}
+	if len(batch) > 0 {
+		ctx.printf("[LOCAL] persisted %d local steps\n", len(batch))
+	}
+	ctx.engine.emit(EngineEvent{Type: EventStepStarted, WorkflowID: ctx.WorkflowID, StepID: id, StepKey: stepKey, Kind: kind})
+	return nil
+}
+
+// SaveLocalSteps records completed local steps in one transaction. Like
+// SaveStep it fails with ErrStaleFencingToken if the run was claimed again.
+func (s *Storage) SaveLocalSteps(workflowID string, token int64, exec Executor, steps []localStepRecord) error {
+	return s.retryOnBusy(func() error {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if err := checkFencingToken(tx, workflowID, token); err != nil {
+			return err
+		}
+		now := dbNow()
+		for _, step := range steps {
+			tags, err := encodeTags(step.tags)
+			if err != nil {
+				return err
+			}
+			blobID, err := putBlob(tx, step.output)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(
+				`INSERT INTO steps (workflow_id, step_key, step_id, sequence_num, status, kind, tags, worker_id, hostname, pid, output_blob, output_type,
+				   scheduled_at, started_at, completed_at)
+				 VALUES (?, ?, ?, ?, 'completed', ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+				 ON CONFLICT(workflow_id, step_key) DO UPDATE SET
+				   status = 'completed', kind = excluded.kind, tags = excluded.tags, worker_id = excluded.worker_id,
+				   hostname = excluded.hostname, pid = excluded.pid, scheduled_at = excluded.scheduled_at, output = NULL, output_blob = excluded.output_blob, output_type = excluded.output_type, started_at = excluded.started_at, completed_at = excluded.completed_at`,
+				workflowID, step.stepKey, step.stepID, step.seqNum, StepKindLocal, tags, exec.WorkerID, exec.Hostname, exec.PID, blobID, step.outputType, dbTime(step.scheduled), dbTime(step.startedAt), now,
+			); err != nil {
+				return err
+			}
+		}
+		return tx.Commit()
+	})
+}
@@ -0,0 +1,123 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLocalStepsPersistWithNextDurableStep(t *testing.T) {
+	dbPath := "./test_localstep.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	countLocal := func() int {
+		var n int
+		eng.storage.db.QueryRow("SELECT COUNT(*) FROM steps WHERE workflow_id = 'pricing' AND kind = 'local' AND status = 'completed'").Scan(&n)
+		return n
+	}
+
+	runs := 0
+	var beforeDurable, insideDurable, afterBatch int
+	workflow := func(ctx *Context) error {
+		total := 0
+		for i := 0; i < 5; i++ {
+			price, err := LocalStep(ctx, fmt.Sprintf("price-%d", i), func() (int, error) {
+				runs++
+				return i * 10, nil
+			})
+			if err != nil {
+				return err
+			}
+			total += price
+		}
+		beforeDurable = countLocal()
+
+		if _, err := Step(ctx, "charge", func() (int, error) {
+			insideDurable = countLocal()
+			return total, nil
+		}); err != nil {
+			return err
+		}
+
+		// A full buffer is written without waiting for a durable step
+		for i := 0; i < localStepBatchSize; i++ {
+			if _, err := LocalStep(ctx, fmt.Sprintf("line-%d", i), func() (int, error) { return i, nil }); err != nil {
+				return err
+			}
+		}
+		afterBatch = countLocal()
+		_, err := LocalStep(ctx, "summary", func() (string, error) { return "ok", nil }, WithTags("pricing"))
+		return err
+	}
+
+	if err := eng.Execute("pricing", workflow); err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	if beforeDurable != 0 || insideDurable != 5 || afterBatch != 5+localStepBatchSize {
+		t.Errorf("unexpected persisted counts: %d before, %d inside durable step, %d after batch", beforeDurable, insideDurable, afterBatch)
+	}
+	if n := countLocal(); n != 6+localStepBatchSize {
+		t.Errorf("expected every local step persisted when the run ended, got %d", n)
+	}
+
+	// Replays return the recorded results without running again
+	if err := eng.storage.UpdateWorkflowStatus("pricing", "running"); err != nil {
+		t.Fatalf("failed to reset status: %v", err)
+	}
+	if err := eng.Execute("pricing", workflow); err != nil {
+		t.Fatalf("resumed run failed: %v", err)
+	}
+	if runs != 5 {
+		t.Errorf("expected local steps not to run again, ran %d times", runs)
+	}
+}
+
+func TestLocalStepFailureIsRecorded(t *testing.T) {
+	dbPath := "./test_localstep_fail.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	attempts := 0
+	err = eng.Execute("parse", func(ctx *Context) error {
+		if _, err := LocalStep(ctx, "normalize", func() (string, error) { return "x", nil }); err != nil {
+			return err
+		}
+		_, err := LocalStep(ctx, "parse", func() (int, error) {
+			attempts++
+			return 0, errors.New("malformed")
+		}, WithRetry(RetryPolicy{MaxAttempts: 3, InitialInterval: time.Millisecond}))
+		return err
+	})
+	var stepErr *StepError
+	if !errors.As(err, &stepErr) || stepErr.StepID != "parse" {
+		t.Fatalf("expected a StepError for parse, got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("expected 3 in-memory attempts, got %d", attempts)
+	}
+
+	history, err := eng.GetHistory("parse")
+	if err != nil {
+		t.Fatalf("failed to get history: %v", err)
+	}
+	status := map[string]string{}
+	for _, step := range history {
+		status[step.StepID] = step.Status
+	}
+	if status["normalize"] != "completed" || status["parse"] != "failed" {
+		t.Errorf("expected the buffered step and the failure recorded, got %v", status)
+	}
+}
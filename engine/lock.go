@@ -0,0 +1,211 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrLockTimeout is returned when a lock could not be acquired within its wait limit
+var ErrLockTimeout = errors.New("timed out acquiring lock")
+
+// DefaultLockTTL is how long a lock survives without renewal (e.g. after a crash)
+const DefaultLockTTL = 5 * time.Minute
+
+// lockPollInterval is how often a blocked acquirer retries
+const lockPollInterval = 100 * time.Millisecond
+
+// LockOption configures AcquireLock and AcquireSemaphore
+type LockOption func(*lockOptions)
+
+type lockOptions struct {
+	ttl  time.Duration
+	wait time.Duration
+}
+
+// WithLockTTL sets how long the lock outlives a crashed holder
+func WithLockTTL(d time.Duration) LockOption {
+	return func(o *lockOptions) {
+		o.ttl = d
+	}
+}
+
+// WithLockWait bounds how long to wait for the lock (0 waits indefinitely)
+func WithLockWait(d time.Duration) LockOption {
+	return func(o *lockOptions) {
+		o.wait = d
+	}
+}
+
+// Lock is a held cross-workflow lock. It is renewed in the background until
+// released; if the holder's process dies, the lock expires after its TTL.
+// If renewing fails until the lease expires, or another holder took it,
+// Lost is closed and the critical section should stop.
+type Lock struct {
+	Name  string
+	Token int64 // fencing token, increases every time the lock changes hands
+
+	ctx      *Context
+	holder   string
+	lease    string
+	stop     chan struct{}
+	stopOnce sync.Once
+	lost     chan struct{}
+}
+
+// AcquireLock blocks until this workflow holds the named mutex, e.g.
+// "inventory:sku-123". Every call is a separate acquisition: a second call,
+// e.g. from another Go branch, waits for the first to release. A workflow
+// resuming after a crash re-acquires a lock it still holds immediately.
+// Locks are released when the workflow returns.
+func (ctx *Context) AcquireLock(name string, opts ...LockOption) (*Lock, error) {
+	return ctx.AcquireSemaphore(name, 1, opts...)
+}
+
+// AcquireSemaphore blocks until this workflow holds one of permits slots of
+// the named semaphore. Like AcquireLock, every call takes its own slot.
+func (ctx *Context) AcquireSemaphore(name string, permits int, opts ...LockOption) (*Lock, error) {
+	return ctx.acquireSlot(name, ctx.lockHolder(name), permits, opts...)
+}
+
+// lockHolder names the holder of the workflow's next acquisition of name.
+// Acquisitions are numbered per name, so a resumed run making the same
+// calls finds the leases its previous execution held.
+func (ctx *Context) lockHolder(name string) string {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if ctx.lockAcquisitions == nil {
+		ctx.lockAcquisitions = make(map[string]int)
+	}
+	ctx.lockAcquisitions[name]++
+	return fmt.Sprintf("%s#%d", ctx.WorkflowID, ctx.lockAcquisitions[name])
+}
+
+// acquireSlot blocks until holder owns one of permits lease slots of name
+func (ctx *Context) acquireSlot(name, holder string, permits int, opts ...LockOption) (*Lock, error) {
+	o := &lockOptions{ttl: DefaultLockTTL}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if permits < 1 {
+		permits = 1
+	}
+
+	var deadline time.Time
+	if o.wait > 0 {
+		deadline = time.Now().Add(o.wait)
+	}
+
+	for {
+		if ctx.Canceled() {
+			return nil, ErrWorkflowCanceled
+		}
+
+		for slot := 0; slot < permits; slot++ {
+			leaseName := lockLeaseName(name, slot)
+			lease, acquired, err := ctx.storage.AcquireLease(leaseName, holder, o.ttl)
+			if err != nil {
+				return nil, fmt.Errorf("failed to acquire lock %s: %w", name, err)
+			}
+			if acquired {
+				lock := &Lock{Name: name, Token: lease.Token, ctx: ctx, holder: holder, lease: leaseName,
+					stop: make(chan struct{}), lost: make(chan struct{})}
+				ctx.trackLock(lock)
+				go lock.renew(o.ttl, lease.ExpiresAt)
+				return lock, nil
+			}
+		}
+
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w: %s", ErrLockTimeout, name)
+		}
+
+		select {
+		case <-ctx.engine.stop:
+			return nil, fmt.Errorf("engine closed while waiting for lock %s", name)
+		case <-ctx.Done():
+			return nil, ctx.doneErr()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// lockLeaseName maps a semaphore slot onto a lease row
+func lockLeaseName(name string, slot int) string {
+	return fmt.Sprintf("lock:%s#%d", name, slot)
+}
+
+// Lost is closed once the lock can no longer be relied on: another holder
+// took it, or it could not be renewed before its lease expired
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// renew keeps the lease alive until the lock is released. A failed renewal
+// is retried while the lease lasts.
+func (l *Lock) renew(ttl time.Duration, expires time.Time) {
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-l.ctx.engine.stop:
+			return
+		case <-ticker.C:
+			lease, ok, err := l.ctx.storage.AcquireLease(l.lease, l.holder, ttl)
+			if err == nil && ok {
+				expires = lease.ExpiresAt
+				continue
+			}
+			if err != nil && time.Now().Before(expires) {
+				fmt.Printf("[LOCK] %s failed to renew lock %s: %v\n", l.holder, l.Name, err)
+				continue
+			}
+			fmt.Printf("[LOCK] %s lost lock %s\n", l.holder, l.Name)
+			close(l.lost)
+			return
+		}
+	}
+}
+
+// Release gives up the lock so other workflows can take it
+func (l *Lock) Release() error {
+	var err error
+	l.stopOnce.Do(func() {
+		close(l.stop)
+		err = l.ctx.storage.ReleaseLease(l.lease, l.holder)
+		l.ctx.untrackLock(l)
+	})
+	return err
+}
+
+// trackLock remembers a held lock so it is released when the workflow returns
+func (ctx *Context) trackLock(l *Lock) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	ctx.locks = append(ctx.locks, l)
+}
+
+func (ctx *Context) untrackLock(l *Lock) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	for i, held := range ctx.locks {
+		if held == l {
+			ctx.locks = append(ctx.locks[:i], ctx.locks[i+1:]...)
+			return
+		}
+	}
+}
+
+// releaseLocks releases every lock still held by the workflow
+func (ctx *Context) releaseLocks() {
+	ctx.mu.Lock()
+	held := append([]*Lock(nil), ctx.locks...)
+	ctx.mu.Unlock()
+
+	for _, l := range held {
+		l.Release()
+	}
+}
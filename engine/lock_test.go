@@ -0,0 +1,218 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkflowLocksSerializeAccess(t *testing.T) {
+	dbPath := "./test_locks.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	var holders, maxHolders int32
+	fulfil := func(permits int) func(ctx *Context) error {
+		return func(ctx *Context) error {
+			lock, err := ctx.AcquireSemaphore("inventory:sku-123", permits, WithLockWait(5*time.Second))
+			if err != nil {
+				return err
+			}
+			defer lock.Release()
+
+			n := atomic.AddInt32(&holders, 1)
+			for {
+				old := atomic.LoadInt32(&maxHolders)
+				if n <= old || atomic.CompareAndSwapInt32(&maxHolders, old, n) {
+					break
+				}
+			}
+			time.Sleep(30 * time.Millisecond)
+			atomic.AddInt32(&holders, -1)
+			return nil
+		}
+	}
+
+	for _, permits := range []int{1, 2} {
+		atomic.StoreInt32(&maxHolders, 0)
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if err := eng.Execute(fmt.Sprintf("fulfil-%d-%d", permits, i), fulfil(permits)); err != nil {
+					t.Errorf("workflow failed: %v", err)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		if got := atomic.LoadInt32(&maxHolders); got != int32(permits) {
+			t.Errorf("expected at most %d concurrent holders, saw %d", permits, got)
+		}
+	}
+}
+
+func TestLockWaitTimeout(t *testing.T) {
+	dbPath := "./test_lock_timeout.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	held := make(chan struct{})
+	release := make(chan struct{})
+	go eng.Execute("holder", func(ctx *Context) error {
+		if _, err := ctx.AcquireLock("report"); err != nil {
+			return err
+		}
+		close(held)
+		<-release
+		return nil
+	})
+	<-held
+
+	err = eng.Execute("waiter", func(ctx *Context) error {
+		_, err := ctx.AcquireLock("report", WithLockWait(50*time.Millisecond))
+		return err
+	})
+	close(release)
+	if !errors.Is(err, ErrLockTimeout) {
+		t.Fatalf("expected ErrLockTimeout, got %v", err)
+	}
+}
+
+func TestLocksArePerAcquisition(t *testing.T) {
+	dbPath := "./test_lock_acquisitions.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	// A previous execution crashed holding its first acquisition of "ledger"
+	eng.storage.AcquireLease(lockLeaseName("ledger", 0), "books-1#1", time.Minute)
+
+	err = eng.Execute("books-1", func(ctx *Context) error {
+		first, err := ctx.AcquireLock("ledger", WithLockWait(50*time.Millisecond))
+		if err != nil {
+			return fmt.Errorf("expected to re-acquire the held lock: %w", err)
+		}
+
+		// A second acquisition by the same run waits like any other holder
+		if _, err := ctx.AcquireLock("ledger", WithLockWait(50*time.Millisecond)); !errors.Is(err, ErrLockTimeout) {
+			return fmt.Errorf("expected the second acquisition to time out, got %v", err)
+		}
+		first.Release()
+
+		// Semaphore acquisitions take distinct slots
+		a, err := ctx.AcquireSemaphore("printers", 2)
+		if err != nil {
+			return err
+		}
+		b, err := ctx.AcquireSemaphore("printers", 2)
+		if err != nil {
+			return err
+		}
+		if a.lease == b.lease {
+			return fmt.Errorf("expected distinct slots, both got %s", a.lease)
+		}
+		if _, err := ctx.AcquireSemaphore("printers", 2, WithLockWait(50*time.Millisecond)); !errors.Is(err, ErrLockTimeout) {
+			return fmt.Errorf("expected the third permit to time out, got %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockLostWhenTakenOver(t *testing.T) {
+	dbPath := "./test_lock_lost.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	err = eng.Execute("report-1", func(ctx *Context) error {
+		lock, err := ctx.AcquireLock("report", WithLockTTL(60*time.Millisecond))
+		if err != nil {
+			return err
+		}
+		eng.storage.db.Exec("UPDATE leases SET holder = 'intruder' WHERE name = ?", lock.lease)
+		select {
+		case <-lock.Lost():
+			return nil
+		case <-time.After(time.Second):
+			return errors.New("expected Lost to be closed once another holder took the lock")
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResourcePoolLimitsSteps(t *testing.T) {
+	dbPath := "./test_pool.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	smtp, err := eng.NewResourcePool("smtp", 2)
+	if err != nil {
+		t.Fatalf("failed to create pool: %v", err)
+	}
+
+	var inUse, maxInUse int32
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := eng.Execute(fmt.Sprintf("notify-%d", i), func(ctx *Context) error {
+				_, err := Step(ctx, "send-email", func() (bool, error) {
+					n := atomic.AddInt32(&inUse, 1)
+					for {
+						old := atomic.LoadInt32(&maxInUse)
+						if n <= old || atomic.CompareAndSwapInt32(&maxInUse, old, n) {
+							break
+						}
+					}
+					time.Sleep(30 * time.Millisecond)
+					atomic.AddInt32(&inUse, -1)
+					return true, nil
+				}, WithResourcePool(smtp))
+				return err
+			})
+			if err != nil {
+				t.Errorf("workflow failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&maxInUse); got != 2 {
+		t.Errorf("expected pool to cap concurrency at 2, saw %d", got)
+	}
+}
@@ -0,0 +1,170 @@
+package engine
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLogCaptureBytes caps the lines captured per run when LogCapture.MaxBytes is unset
+const DefaultLogCaptureBytes = 64 << 10
+
+// LogCapture configures WithLogCapture
+type LogCapture struct {
+	MaxBytes   int     // per run; later lines are dropped after a truncation notice
+	SampleRate float64 // fraction of lines kept, between 0 and 1; 0 keeps every line
+}
+
+// LogLine is one line a run printed
+type LogLine struct {
+	Seq     int64 // increases with every captured line of the run
+	Message string
+	Time    time.Time
+}
+
+// runLog tracks what a run has captured so far, including earlier executions
+type runLog struct {
+	mu        sync.Mutex
+	loaded    bool
+	seq       int64
+	size      int
+	truncated bool
+}
+
+// WithLogCapture stores the lines a run prints through the engine (retries,
+// skipped steps, checkpoints, ctx.Logf and ctx.Logger) with the run, so they
+// can be read back with GetWorkflowLogs or workflowctl logs after the process
+// that printed them is gone. Lines are still written to stdout.
+func WithLogCapture(cfg LogCapture) EngineOption {
+	return func(e *Engine) {
+		if cfg.MaxBytes <= 0 {
+			cfg.MaxBytes = DefaultLogCaptureBytes
+		}
+		e.logCapture = &cfg
+	}
+}
+
+// GetWorkflowLogs returns the lines captured from a run, oldest first. Lines
+// of every execution are kept, so a resumed run shows what each one printed.
+func (e *Engine) GetWorkflowLogs(workflowID string) ([]LogLine, error) {
+	if _, err := e.storage.GetWorkflowStatus(workflowID); err != nil {
+		return nil, err
+	}
+	return e.storage.ListWorkflowLogs(workflowID)
+}
+
+// Logger returns a logger whose lines go to stdout and, with WithLogCapture,
+// are stored with the run
+func (ctx *Context) Logger() *log.Logger {
+	return log.New(runLogWriter{ctx}, "", log.LstdFlags)
+}
+
+// runLogWriter writes to stdout and captures each line for its run
+type runLogWriter struct {
+	ctx *Context
+}
+
+func (w runLogWriter) Write(p []byte) (int, error) {
+	n, err := os.Stdout.Write(p)
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		w.ctx.captureLog(line)
+	}
+	return n, err
+}
+
+// printf prints a line about the run and captures it
+func (ctx *Context) printf(format string, args ...interface{}) {
+	line := fmt.Sprintf(format, args...)
+	fmt.Print(line)
+	ctx.captureLog(strings.TrimRight(line, "\n"))
+}
+
+// captureLog stores a line with the run if log capture is on and the run
+// hasn't reached its cap
+func (ctx *Context) captureLog(line string) {
+	cfg := ctx.engine.logCapture
+	if cfg == nil || ctx.sim != nil {
+		return
+	}
+	if cfg.SampleRate > 0 && cfg.SampleRate < 1 && rand.Float64() >= cfg.SampleRate {
+		return
+	}
+
+	l := &ctx.logs
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.loaded {
+		seq, size, err := ctx.storage.WorkflowLogSize(ctx.WorkflowID)
+		if err != nil {
+			fmt.Printf("[LOGS] %v\n", err)
+			return
+		}
+		l.seq, l.size, l.loaded = seq, size, true
+		l.truncated = size >= cfg.MaxBytes
+	}
+	if l.truncated {
+		return
+	}
+	if l.size+len(line) > cfg.MaxBytes {
+		line = fmt.Sprintf("[LOGS] output truncated after %d bytes", l.size)
+		l.truncated = true
+	}
+
+	l.seq++
+	l.size += len(line)
+	if err := ctx.storage.AppendWorkflowLog(ctx.WorkflowID, l.seq, line); err != nil {
+		fmt.Printf("[LOGS] failed to capture a line of %s: %v\n", ctx.WorkflowID, err)
+	}
+}
+
+// AppendWorkflowLog stores one captured line of a run
+func (s *Storage) AppendWorkflowLog(workflowID string, seq int64, message string) error {
+	return s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			"INSERT OR IGNORE INTO workflow_logs (workflow_id, seq, message, created_at) VALUES (?, ?, ?, ?)",
+			workflowID, seq, message, dbNow(),
+		)
+		return err
+	})
+}
+
+// WorkflowLogSize returns the last sequence number and total size of the lines captured from a run
+func (s *Storage) WorkflowLogSize(workflowID string) (int64, int, error) {
+	var seq sql.NullInt64
+	var size int
+	err := s.db.QueryRow(
+		"SELECT MAX(seq), COALESCE(SUM(LENGTH(CAST(message AS BLOB))), 0) FROM workflow_logs WHERE workflow_id = ?",
+		workflowID,
+	).Scan(&seq, &size)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read captured log size: %w", err)
+	}
+	return seq.Int64, size, nil
+}
+
+// ListWorkflowLogs loads the lines captured from a run, oldest first
+func (s *Storage) ListWorkflowLogs(workflowID string) ([]LogLine, error) {
+	rows, err := s.db.Query(
+		"SELECT seq, message, created_at FROM workflow_logs WHERE workflow_id = ? ORDER BY seq",
+		workflowID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list captured logs: %w", err)
+	}
+	defer rows.Close()
+
+	var lines []LogLine
+	for rows.Next() {
+		var line LogLine
+		if err := rows.Scan(&line.Seq, &line.Message, &line.Time); err != nil {
+			return nil, fmt.Errorf("failed to scan captured log line: %w", err)
+		}
+		lines = append(lines, line)
+	}
+	return lines, rows.Err()
+}
@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogCapture(t *testing.T) {
+	dbPath := "./test_logs.db"
+	defer os.Remove(dbPath)
+
+	engine, err := NewEngine(dbPath, WithLogCapture(LogCapture{MaxBytes: 400}))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	attempts := 0
+	workflow := func(ctx *Context) error {
+		ctx.Logf("charging %d cents", 500)
+		ctx.Logger().Println("calling the card processor")
+		_, err := Step(ctx, "charge", func() (int, error) {
+			attempts++
+			if attempts == 1 {
+				return 0, errors.New("card declined")
+			}
+			return 500, nil
+		}, WithRetry(RetryPolicy{MaxAttempts: 2, InitialInterval: time.Millisecond}))
+		return err
+	}
+	if err := engine.Execute("logs-1", workflow); err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+
+	lines, err := engine.GetWorkflowLogs("logs-1")
+	if err != nil {
+		t.Fatalf("failed to get logs: %v", err)
+	}
+	var messages []string
+	for _, line := range lines {
+		messages = append(messages, line.Message)
+	}
+	joined := strings.Join(messages, "\n")
+	for _, want := range []string{"charging 500 cents", "calling the card processor", "[RETRY] charge attempt 1 failed: card declined"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("expected the captured log to contain %q, got %q", want, messages)
+		}
+	}
+
+	// Lines beyond the cap are dropped after a truncation notice, across executions
+	noisy := func(ctx *Context) error {
+		for i := 0; i < 20; i++ {
+			ctx.Logf("line %02d of a noisy workflow", i)
+		}
+		return nil
+	}
+	engine.Execute("logs-2", noisy)
+	engine.Execute("logs-2", noisy)
+	lines, err = engine.GetWorkflowLogs("logs-2")
+	if err != nil {
+		t.Fatalf("failed to get logs: %v", err)
+	}
+	last := lines[len(lines)-1].Message
+	if len(lines) >= 20 || !strings.Contains(last, "truncated") {
+		t.Errorf("expected the log to be truncated at 400 bytes, got %d lines ending %q", len(lines), last)
+	}
+	for i := 1; i < len(lines); i++ {
+		if lines[i].Seq != lines[i-1].Seq+1 {
+			t.Errorf("expected consecutive sequence numbers, got %d after %d", lines[i].Seq, lines[i-1].Seq)
+		}
+	}
+
+	if _, err := engine.GetWorkflowLogs("missing"); !errors.Is(err, ErrWorkflowNotFound) {
+		t.Errorf("expected ErrWorkflowNotFound, got %v", err)
+	}
+}
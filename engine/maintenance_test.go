@@ -0,0 +1,51 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMaintainTruncatesWALAndVacuums(t *testing.T) {
+	dbPath := "./test_maintain.db"
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + "-wal")
+	defer os.Remove(dbPath + "-shm")
+
+	eng, err := NewEngine(dbPath, WithMaintenance(MaintenanceConfig{}))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	for i := 0; i < 20; i++ {
+		payload := strings.Repeat(fmt.Sprint(i), 20000)
+		eng.Execute(fmt.Sprintf("bulk-%d", i), func(ctx *Context) error {
+			_, err := Step(ctx, "payload", func() (string, error) { return payload, nil })
+			return err
+		})
+	}
+	if _, err := eng.storage.db.Exec("DELETE FROM steps"); err != nil {
+		t.Fatalf("failed to delete steps: %v", err)
+	}
+
+	stats, err := eng.Maintain()
+	if err != nil {
+		t.Fatalf("maintain failed: %v", err)
+	}
+	if stats.Busy || stats.CheckpointedFrames != stats.WALFrames {
+		t.Errorf("expected a complete checkpoint, got %+v", stats)
+	}
+	if stats.FreedPages == 0 {
+		t.Errorf("expected incremental vacuum to free pages, got %+v", stats)
+	}
+
+	info, err := os.Stat(dbPath + "-wal")
+	if err != nil {
+		t.Fatalf("failed to stat WAL: %v", err)
+	}
+	if info.Size() != 0 {
+		t.Errorf("expected truncated WAL, got %d bytes", info.Size())
+	}
+}
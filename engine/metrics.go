@@ -0,0 +1,325 @@
+package engine
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StorageLatencyBuckets are the upper bounds of the storage latency histogram
+var StorageLatencyBuckets = []time.Duration{
+	100 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// StorageOpStats is the latency histogram of one storage operation, such as
+// "SaveStep". Every SQL statement and commit it issues is counted separately.
+type StorageOpStats struct {
+	Op            string
+	Count         int64
+	Errors        int64
+	Busy          int64 // statements that found the database locked
+	BusyExhausted int64 // operations that gave up retrying a locked database
+	Total         time.Duration
+	Max           time.Duration
+	Buckets       []int64 // counts per StorageLatencyBuckets bound; the last entry counts slower calls
+}
+
+// Mean returns the average latency
+func (s *StorageOpStats) Mean() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Count)
+}
+
+// Quantile returns the bucket bound below which a fraction q of calls fell,
+// or Max for calls beyond the last bound
+func (s *StorageOpStats) Quantile(q float64) time.Duration {
+	rank := int64(math.Ceil(q * float64(s.Count)))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for i, n := range s.Buckets {
+		seen += n
+		if seen >= rank && i < len(StorageLatencyBuckets) {
+			return StorageLatencyBuckets[i]
+		}
+	}
+	return s.Max
+}
+
+// StorageMetrics returns latency histograms for every storage operation
+// issued so far, ordered by name
+func (e *Engine) StorageMetrics() []StorageOpStats {
+	return e.storage.db.metrics.snapshot()
+}
+
+// WithSlowQueryLog logs every storage statement that takes at least threshold,
+// tagged with its operation and, when the statement filters by one, workflow ID
+func WithSlowQueryLog(threshold time.Duration) EngineOption {
+	return func(e *Engine) {
+		e.storage.db.metrics.slowThreshold = threshold
+	}
+}
+
+// storageMetrics aggregates per-operation latencies
+type storageMetrics struct {
+	mu            sync.Mutex
+	ops           map[string]*StorageOpStats
+	slowThreshold time.Duration
+
+	workflowArgs sync.Map // query -> index of its workflow_id argument, or -1
+}
+
+// observe records one statement
+func (m *storageMetrics) observe(query string, args []interface{}, start time.Time, err error) {
+	elapsed := time.Since(start)
+	op := storageOperation()
+
+	bucket := sort.Search(len(StorageLatencyBuckets), func(i int) bool {
+		return elapsed <= StorageLatencyBuckets[i]
+	})
+
+	m.mu.Lock()
+	st := m.opStats(op)
+	st.Count++
+	if err != nil && err != sql.ErrNoRows {
+		st.Errors++
+	}
+	if isSQLiteBusy(err) {
+		st.Busy++
+	}
+	st.Total += elapsed
+	if elapsed > st.Max {
+		st.Max = elapsed
+	}
+	st.Buckets[bucket]++
+	m.mu.Unlock()
+
+	if m.slowThreshold > 0 && elapsed >= m.slowThreshold {
+		workflow := ""
+		if id := m.workflowID(query, args); id != "" {
+			workflow = " workflow=" + id
+		}
+		fmt.Printf("[SLOW QUERY] %s took %v%s: %s\n", op, elapsed.Round(time.Microsecond), workflow, compactQuery(query))
+	}
+}
+
+// observeBusyExhausted counts an operation that stopped retrying a busy database
+func (m *storageMetrics) observeBusyExhausted() {
+	op := storageOperation()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.opStats(op).BusyExhausted++
+}
+
+// opStats returns the histogram of an operation, creating it on first use;
+// m.mu must be held
+func (m *storageMetrics) opStats(op string) *StorageOpStats {
+	st, ok := m.ops[op]
+	if !ok {
+		st = &StorageOpStats{Op: op, Buckets: make([]int64, len(StorageLatencyBuckets)+1)}
+		m.ops[op] = st
+	}
+	return st
+}
+
+// snapshot copies the current histograms
+func (m *storageMetrics) snapshot() []StorageOpStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]StorageOpStats, 0, len(m.ops))
+	for _, st := range m.ops {
+		c := *st
+		c.Buckets = append([]int64(nil), st.Buckets...)
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Op < out[j].Op })
+	return out
+}
+
+var workflowIDParam = regexp.MustCompile(`workflow_id\s*=\s*\?`)
+
+// workflowID returns the workflow a statement filters by, if any
+func (m *storageMetrics) workflowID(query string, args []interface{}) string {
+	idx, ok := m.workflowArgs.Load(query)
+	if !ok {
+		i := -1
+		if loc := workflowIDParam.FindStringIndex(query); loc != nil {
+			i = strings.Count(query[:loc[0]], "?")
+		}
+		m.workflowArgs.Store(query, i)
+		idx = i
+	}
+
+	if i := idx.(int); i >= 0 && i < len(args) {
+		if id, ok := args[i].(string); ok {
+			return id
+		}
+	}
+	return ""
+}
+
+// storageOperation names the Storage method on the call stack, falling back
+// to the nearest caller outside this file
+func storageOperation() string {
+	pcs := make([]uintptr, 16)
+	n := runtime.Callers(4, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	fallback := ""
+	for {
+		frame, more := frames.Next()
+		name := frame.Function
+		if i := strings.Index(name, "(*Storage)."); i >= 0 {
+			method := name[i+len("(*Storage)."):]
+			method, _, _ = strings.Cut(method, ".") // closures passed to retryOnBusy
+			if method != "retryOnBusy" && method != "retryOnBusyContext" {
+				return method
+			}
+		} else if fallback == "" {
+			fallback = name[strings.LastIndex(name, ".")+1:]
+		}
+		if !more {
+			break
+		}
+	}
+	if fallback == "" {
+		fallback = "unknown"
+	}
+	return fallback
+}
+
+// compactQuery collapses whitespace so a query fits on one log line
+func compactQuery(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// instrumentedDB times every statement issued through the database handle.
+// Within Storage.WithTx, statements run in its transaction instead.
+type instrumentedDB struct {
+	*sql.DB
+	metrics *storageMetrics
+	tx      *sql.Tx
+}
+
+// Exec runs a statement
+func (db *instrumentedDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	res, err := db.conn().Exec(query, args...)
+	db.metrics.observe(query, args, start, err)
+	return res, err
+}
+
+// Query runs a query; the time until its first row is available is recorded
+func (db *instrumentedDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := db.conn().Query(query, args...)
+	db.metrics.observe(query, args, start, err)
+	return rows, err
+}
+
+// QueryRow runs a single-row query
+func (db *instrumentedDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := db.conn().QueryRow(query, args...)
+	db.metrics.observe(query, args, start, row.Err())
+	return row
+}
+
+// Begin starts an instrumented transaction, or a savepoint within the
+// enclosing one
+func (db *instrumentedDB) Begin() (*instrumentedTx, error) {
+	if db.tx != nil {
+		return db.beginSavepoint()
+	}
+	start := time.Now()
+	tx, err := db.DB.Begin()
+	db.metrics.observe("BEGIN", nil, start, err)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedTx{Tx: tx, metrics: db.metrics}, nil
+}
+
+// instrumentedTx times every statement of a transaction and its commit
+type instrumentedTx struct {
+	*sql.Tx
+	metrics   *storageMetrics
+	savepoint string // set for a nested transaction
+	done      bool
+}
+
+// Exec runs a statement in the transaction
+func (tx *instrumentedTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	res, err := tx.Tx.Exec(query, args...)
+	tx.metrics.observe(query, args, start, err)
+	return res, err
+}
+
+// Query runs a query in the transaction
+func (tx *instrumentedTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := tx.Tx.Query(query, args...)
+	tx.metrics.observe(query, args, start, err)
+	return rows, err
+}
+
+// QueryRow runs a single-row query in the transaction
+func (tx *instrumentedTx) QueryRow(query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := tx.Tx.QueryRow(query, args...)
+	tx.metrics.observe(query, args, start, row.Err())
+	return row
+}
+
+// Commit commits the transaction, or releases its savepoint
+func (tx *instrumentedTx) Commit() error {
+	if tx.savepoint != "" {
+		tx.done = true
+		return tx.exec("RELEASE " + tx.savepoint)
+	}
+	start := time.Now()
+	err := tx.Tx.Commit()
+	tx.metrics.observe("COMMIT", nil, start, err)
+	return err
+}
+
+// Rollback aborts the transaction, or undoes the writes since its savepoint.
+// It does nothing after Commit.
+func (tx *instrumentedTx) Rollback() error {
+	if tx.savepoint == "" {
+		return tx.Tx.Rollback()
+	}
+	if tx.done {
+		return sql.ErrTxDone
+	}
+	tx.done = true
+	if err := tx.exec("ROLLBACK TO " + tx.savepoint); err != nil {
+		return err
+	}
+	return tx.exec("RELEASE " + tx.savepoint)
+}
+
+// exec runs a statement that returns no result
+func (tx *instrumentedTx) exec(query string) error {
+	_, err := tx.Exec(query)
+	return err
+}
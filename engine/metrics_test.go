@@ -0,0 +1,64 @@
+package engine
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStorageMetricsByOperation(t *testing.T) {
+	dbPath := "./test_metrics.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	err = eng.Execute("metrics-1", func(ctx *Context) error {
+		for _, id := range []string{"a", "b", "c"} {
+			if _, err := Step(ctx, id, func() (string, error) { return id, nil }); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+
+	ops := make(map[string]StorageOpStats)
+	for _, st := range eng.StorageMetrics() {
+		ops[st.Op] = st
+	}
+
+	mark, ok := ops["MarkStepInProgress"]
+	if !ok || mark.Count != 3 {
+		t.Fatalf("expected 3 MarkStepInProgress calls, got %+v", mark)
+	}
+	var bucketed int64
+	for _, n := range mark.Buckets {
+		bucketed += n
+	}
+	if bucketed != mark.Count || mark.Max <= 0 || mark.Quantile(0.99) <= 0 {
+		t.Errorf("inconsistent histogram: %+v", mark)
+	}
+
+	// SaveStep runs a transaction; its statements and commit are attributed to it
+	if save := ops["SaveStep"]; save.Count < 3*3 {
+		t.Errorf("expected SaveStep to record its transaction statements, got %+v", save)
+	}
+}
+
+func TestSlowQueryWorkflowTag(t *testing.T) {
+	m := &storageMetrics{ops: make(map[string]*StorageOpStats), slowThreshold: time.Nanosecond}
+
+	query := "UPDATE steps SET status = ? WHERE workflow_id = ? AND step_key = ?"
+	if got := m.workflowID(query, []interface{}{"failed", "wf-9", "a:1"}); got != "wf-9" {
+		t.Errorf("expected wf-9, got %q", got)
+	}
+	if got := m.workflowID("SELECT COUNT(*) FROM workflows", nil); got != "" {
+		t.Errorf("expected no workflow, got %q", got)
+	}
+}
@@ -0,0 +1,44 @@
+package engine
+
+import (
+	"bytes"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// msgpackCodec encodes values as MessagePack
+type msgpackCodec struct{}
+
+// NewMsgpackCodec creates a MessagePack codec. It honours json struct tags,
+// so types written for the JSON codec encode under the same field names, and
+// produces smaller payloads that are still readable from any language with a
+// msgpack library.
+//
+// Like gob, msgpack data is not JSON: step schemas, readable history diffs
+// and the dsl package need the JSON codec.
+func NewMsgpackCodec() Codec {
+	return msgpackCodec{}
+}
+
+// Marshal encodes v
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	enc.UseCompactFloats(true)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes data into v; empty data leaves v unchanged
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type msgpackReading struct {
+	SensorID string    `json:"sensor_id"`
+	Values   []float64 `json:"values"`
+	Count    int
+}
+
+func TestMsgpackCodecIsSmallerAndHonoursJSONTags(t *testing.T) {
+	v := msgpackReading{SensorID: "s-1", Values: []float64{120, 121.5, 119}, Count: 3}
+
+	data, err := NewMsgpackCodec().Marshal(v)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	jsonData, _ := json.Marshal(v)
+	if len(data) >= len(jsonData) {
+		t.Errorf("expected msgpack (%d bytes) to be smaller than JSON (%d bytes)", len(data), len(jsonData))
+	}
+
+	// Other msgpack readers see the JSON field names
+	var generic map[string]interface{}
+	if err := msgpack.Unmarshal(data, &generic); err != nil {
+		t.Fatalf("failed to decode generically: %v", err)
+	}
+	if generic["sensor_id"] != "s-1" {
+		t.Errorf("expected sensor_id key, got %v", generic)
+	}
+}
+
+func TestMsgpackCodecReplay(t *testing.T) {
+	dbPath := "./test_msgpack.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath, WithCodec(NewMsgpackCodec()))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	want := msgpackReading{SensorID: "s-2", Values: []float64{0.1}, Count: 1}
+	var calls, runs int
+	var got msgpackReading
+	workflow := func(ctx *Context) error {
+		runs++
+		r, err := Step(ctx, "read", func() (msgpackReading, error) {
+			calls++
+			return want, nil
+		})
+		if err != nil {
+			return err
+		}
+		got = r
+		if runs == 1 {
+			return errors.New("crash after step")
+		}
+		return nil
+	}
+
+	eng.Execute("mp-1", workflow)
+	if err := eng.Execute("mp-1", workflow); err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected step to run once, ran %d times", calls)
+	}
+	if got.SensorID != want.SensorID || got.Count != 1 || len(got.Values) != 1 || got.Values[0] != 0.1 {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
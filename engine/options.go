@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"time"
+)
+
+// WorkflowOption configures a single workflow start
+type WorkflowOption func(*workflowOptions)
+
+type workflowOptions struct {
+	queue          string
+	priority       int
+	concurrencyKey string
+	retryBudget    *RetryBudget
+	runLimits      *RunLimits
+	timeout        time.Duration
+	onComplete     []string
+	parentID       string
+	params         map[string]string
+	headers        map[string]string
+
+	stepDefaults []StepOption
+
+	// Set by the dispatcher: when a claimed run entered its queue
+	queuedAt time.Time
+
+	// Set by typed workflows started with Execute
+	workflowName string
+	input        []byte
+}
+
+func newWorkflowOptions(opts []WorkflowOption) *workflowOptions {
+	o := &workflowOptions{queue: DefaultQueue}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithQueue places an enqueued workflow on a named queue
+func WithQueue(queue string) WorkflowOption {
+	return func(o *workflowOptions) {
+		o.queue = queue
+	}
+}
+
+// StepOption configures a single step call
+type StepOption func(*stepOptions)
+
+type stepOptions struct {
+	retry   *RetryPolicy
+	timeout time.Duration
+	pools   []*ResourcePool
+
+	poolLockOpts []LockOption
+
+	kind  string
+	group string   // Map call the step belongs to
+	tags  []string // see WithTags
+
+	cacheKey string
+	cacheTTL time.Duration
+
+	maxOutput int
+
+	requires []string
+
+	idempotency IdempotencyScope
+
+	callHash bool
+}
+
+func newStepOptions(opts []StepOption) *stepOptions {
+	o := &stepOptions{kind: StepKindStep}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// withStepKind marks engine-internal steps (sleeps, signal waits, ...) so
+// monitoring can tell what a pending step is waiting on
+func withStepKind(kind string) StepOption {
+	return func(o *stepOptions) {
+		o.kind = kind
+	}
+}
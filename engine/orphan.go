@@ -0,0 +1,236 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultOrphanStaleAfter is used when OrphanScanConfig.StaleAfter is zero
+const DefaultOrphanStaleAfter = 30 * time.Minute
+
+// OrphanScanConfig configures the orphan scanner
+type OrphanScanConfig struct {
+	Interval time.Duration // time between scans; defaults to a minute
+	// StaleAfter applies to runs owned by processes that never registered as
+	// workers and so have no heartbeat: they are orphaned once they have
+	// recorded no step activity for this long. A run waiting on a durable
+	// timer is quiet by design and counts as active until StaleAfter past the
+	// timer's due time; one holding a lock is active while the lock's lease is
+	// renewed, and one with a step in progress and no step timeout is left to
+	// its owner.
+	StaleAfter time.Duration
+}
+
+// OrphanedWorkflow is a running workflow whose process died, and what the scanner did with it
+type OrphanedWorkflow struct {
+	WorkflowID string
+	Owner      string // worker ID of the process that was running it
+	Action     string // "requeued" or "interrupted"
+}
+
+// StartOrphanScanner periodically recovers running workflows whose process
+// died. It runs on the elected leader only.
+func (e *Engine) StartOrphanScanner(cfg OrphanScanConfig) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Minute
+	}
+
+	e.RunSingleton("orphan-scanner", 0, func(ctx context.Context) {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		for {
+			if _, err := e.ScanOrphans(cfg.StaleAfter); err != nil {
+				fmt.Printf("[ORPHANS] %v\n", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+// ScanOrphans finds running workflows whose owning worker stopped
+// heartbeating, or whose unregistered owner has shown no sign of life for
+// staleAfter (see OrphanScanConfig.StaleAfter).
+// Workflows started by name are requeued so any worker serving the name
+// replays them; others can only be resumed by the code that started them
+// and are marked interrupted.
+func (e *Engine) ScanOrphans(staleAfter time.Duration) ([]OrphanedWorkflow, error) {
+	if staleAfter <= 0 {
+		staleAfter = DefaultOrphanStaleAfter
+	}
+
+	workers, err := e.storage.ListWorkers()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	alive := make(map[string]bool, len(workers))
+	for _, w := range workers {
+		alive[w.WorkerID] = w.Alive(now)
+	}
+
+	running, err := e.storage.ListRunningWorkflows()
+	if err != nil {
+		return nil, err
+	}
+
+	var orphans []OrphanedWorkflow
+	for _, r := range running {
+		if e.isRunningLocally(r.id) {
+			continue
+		}
+		if isAlive, registered := alive[r.owner]; registered {
+			if isAlive {
+				continue
+			}
+		} else if r.stepInProgress || now.Sub(r.lastActivity) < staleAfter || now.Sub(r.busyUntil) < staleAfter {
+			continue
+		}
+
+		o := OrphanedWorkflow{WorkflowID: r.id, Owner: r.owner}
+		if r.name != "" {
+			o.Action = "requeued"
+			err = e.storage.RequeueWorkflow(r.id, r.version)
+		} else {
+			o.Action = "interrupted"
+			err = e.storage.CompareAndSetWorkflowStatus(r.id, "interrupted", r.version)
+		}
+		if errors.Is(err, ErrStatusConflict) {
+			continue // the owner settled it after all
+		}
+		if err != nil {
+			return orphans, fmt.Errorf("failed to recover workflow %s: %w", r.id, err)
+		}
+
+		fmt.Printf("[ORPHANS] %s %s (owner %s)\n", o.Action, r.id, ownerName(r.owner))
+		orphans = append(orphans, o)
+	}
+	return orphans, nil
+}
+
+// isRunningLocally reports whether this engine is executing the workflow
+func (e *Engine) isRunningLocally(workflowID string) bool {
+	e.runningMu.Lock()
+	defer e.runningMu.Unlock()
+	_, ok := e.running[workflowID]
+	return ok
+}
+
+// ownerName describes a workflow owner for log lines
+func ownerName(owner string) string {
+	if owner == "" {
+		return "unknown"
+	}
+	return owner
+}
+
+// runningWorkflow is a running workflow row inspected by the orphan scanner
+type runningWorkflow struct {
+	id           string
+	name         string
+	owner        string
+	version      int64
+	lastActivity time.Time
+	// busyUntil is when the run's latest pending timer is due or its latest
+	// lock lease expires, whichever is later
+	busyUntil time.Time
+	// stepInProgress reports a step in progress without a pending timeout
+	stepInProgress bool
+}
+
+// ListRunningWorkflows returns every running workflow with its owner, the
+// time of its most recent status change, step activity or checkpoint, and
+// its pending timers, lock leases and untimed steps in progress
+func (s *Storage) ListRunningWorkflows() ([]runningWorkflow, error) {
+	rows, err := s.db.Query(
+		`SELECT w.workflow_id, COALESCE(w.workflow_name, ''), COALESCE(w.claimed_by, ''), w.version,
+		   MAX(STRFTIME('%Y-%m-%d %H:%M:%f', w.updated_at),
+		       COALESCE((SELECT STRFTIME('%Y-%m-%d %H:%M:%f', MAX(MAX(s.started_at, COALESCE(s.completed_at, s.started_at))))
+		                 FROM steps s WHERE s.workflow_id = w.workflow_id), ''),
+		       COALESCE((SELECT STRFTIME('%Y-%m-%d %H:%M:%f', MAX(c.updated_at))
+		                 FROM step_checkpoints c WHERE c.workflow_id = w.workflow_id), '')),
+		   MAX(COALESCE((SELECT MAX(t.fire_at_ms) FROM timers t WHERE t.workflow_id = w.workflow_id AND t.status = 'pending'), 0),
+		       COALESCE((SELECT MAX(l.expires_at_ms) FROM leases l
+		                 WHERE SUBSTR(l.holder, 1, LENGTH(w.workflow_id) + 1) IN (w.workflow_id || '#', w.workflow_id || '/')), 0)),
+		   EXISTS (SELECT 1 FROM steps s WHERE s.workflow_id = w.workflow_id AND s.status = 'in_progress'
+		           AND NOT EXISTS (SELECT 1 FROM timers t WHERE t.timer_key = w.workflow_id || '/' || s.step_id || '/timeout'
+		                           AND t.status = 'pending'))
+		 FROM workflows w WHERE w.status = 'running'`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list running workflows: %w", err)
+	}
+	defer rows.Close()
+
+	var running []runningWorkflow
+	for rows.Next() {
+		var r runningWorkflow
+		var activity string
+		var busyUntilMs int64
+		if err := rows.Scan(&r.id, &r.name, &r.owner, &r.version, &activity, &busyUntilMs, &r.stepInProgress); err != nil {
+			return nil, fmt.Errorf("failed to scan running workflow: %w", err)
+		}
+		if r.lastActivity, err = time.Parse(timestampLayout, activity); err != nil {
+			return nil, fmt.Errorf("failed to parse activity time %q: %w", activity, err)
+		}
+		if busyUntilMs > 0 {
+			r.busyUntil = time.UnixMilli(busyUntilMs)
+		}
+		running = append(running, r)
+	}
+	return running, rows.Err()
+}
+
+// ClaimWorkflowRun records workerID as the owner of a run starting now and
+// moves the workflow to running, provided its status is still at version.
+// It returns the version the run must settle from and the run's new fencing
+// token, which step results are saved under (see SaveStep).
+func (s *Storage) ClaimWorkflowRun(workflowID, workerID string, version int64) (int64, int64, error) {
+	var newVersion, token int64
+	err := s.retryOnBusy(func() error {
+		err := s.db.QueryRow(
+			`UPDATE workflows SET claimed_by = ?,
+			   version = CASE WHEN status = 'running' THEN version ELSE version + 1 END,
+			   fencing_token = fencing_token + 1,
+			   status = 'running', updated_at = ?
+			 WHERE workflow_id = ? AND version = ? RETURNING version, fencing_token`,
+			workerID, dbNow(), workflowID, version,
+		).Scan(&newVersion, &token)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: %s changed before the run started", ErrStatusConflict, workflowID)
+		}
+		return err
+	})
+	return newVersion, token, err
+}
+
+// RequeueWorkflow returns a running workflow to its queue, provided its status is still at version
+func (s *Storage) RequeueWorkflow(workflowID string, version int64) error {
+	var affected int64
+	err := s.retryOnBusy(func() error {
+		res, err := s.db.Exec(
+			`UPDATE workflows SET status = 'queued', claimed_by = NULL, version = version + 1,
+			   updated_at = ?
+			 WHERE workflow_id = ? AND status = 'running' AND version = ?`,
+			dbNow(), workflowID, version,
+		)
+		if err != nil {
+			return err
+		}
+		affected, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w: %s", ErrStatusConflict, workflowID)
+	}
+	return nil
+}
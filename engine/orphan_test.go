@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestScanOrphansRecoversDeadOwners(t *testing.T) {
+	dbPath := "./test_orphans.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath, WithWorkerID("scanner-1"))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	var resumed int
+	eng.Register("import", func(ctx *Context) error {
+		_, err := Step(ctx, "load", func() (int, error) {
+			resumed++
+			return 1, nil
+		})
+		return err
+	})
+
+	// A worker claimed a named run, then stopped heartbeating
+	eng.storage.RegisterWorker(WorkerInfo{WorkerID: "dead-1", HeartbeatInterval: time.Second})
+	eng.storage.db.Exec("UPDATE workers SET last_heartbeat = ? WHERE worker_id = 'dead-1'", time.Now().Add(-time.Hour).UTC())
+	eng.Enqueue("import-1", "import", nil)
+	eng.storage.db.Exec("UPDATE workflows SET status = 'running', claimed_by = 'dead-1' WHERE workflow_id = 'import-1'")
+
+	// A live worker's run is left alone
+	eng.storage.RegisterWorker(WorkerInfo{WorkerID: "live-1", HeartbeatInterval: time.Minute})
+	eng.Enqueue("import-2", "import", nil)
+	eng.storage.db.Exec("UPDATE workflows SET status = 'running', claimed_by = 'live-1' WHERE workflow_id = 'import-2'")
+
+	// An anonymous run from a process that never registered went quiet long ago
+	eng.storage.CreateWorkflow("adhoc-1", 0)
+	eng.storage.db.Exec("UPDATE workflows SET claimed_by = 'gone-1', updated_at = ? WHERE workflow_id = 'adhoc-1'",
+		time.Now().Add(-2*time.Hour).UTC().Format("2006-01-02 15:04:05"))
+
+	// Quiet runs of unregistered owners that show other signs of life are left alone
+	old := time.Now().Add(-2 * time.Hour).UTC().Format("2006-01-02 15:04:05")
+	eng.storage.CreateWorkflow("sleeping-1", 0)
+	eng.storage.db.Exec("UPDATE workflows SET claimed_by = 'gone-1', updated_at = ? WHERE workflow_id = 'sleeping-1'", old)
+	eng.ScheduleTimer("sleeping-1", "sleeping-1/nap", TimerKindSleep, time.Now().Add(24*time.Hour), nil)
+	eng.storage.CreateWorkflow("locked-1", 0)
+	eng.storage.db.Exec("UPDATE workflows SET claimed_by = 'gone-1', updated_at = ? WHERE workflow_id = 'locked-1'", old)
+	eng.storage.AcquireLease(lockLeaseName("ledger", 0), "locked-1#1", time.Minute)
+	eng.storage.CreateWorkflow("busy-1", 0)
+	eng.storage.db.Exec("UPDATE workflows SET claimed_by = 'gone-1', updated_at = ? WHERE workflow_id = 'busy-1'", old)
+	eng.storage.db.Exec(`INSERT INTO steps (workflow_id, step_key, step_id, sequence_num, status, started_at)
+		VALUES ('busy-1', 'busy-1/export', 'export', 1, 'in_progress', ?)`, old)
+
+	orphans, err := eng.ScanOrphans(time.Hour)
+	if err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+	actions := make(map[string]string)
+	for _, o := range orphans {
+		actions[o.WorkflowID] = o.Action
+	}
+	if len(actions) != 2 || actions["import-1"] != "requeued" || actions["adhoc-1"] != "interrupted" {
+		t.Fatalf("unexpected scan result: %+v", orphans)
+	}
+	for _, id := range []string{"import-2", "sleeping-1", "locked-1", "busy-1"} {
+		if status, _ := eng.storage.GetWorkflowStatus(id); status != "running" {
+			t.Errorf("expected %s to stay running, got %s", id, status)
+		}
+	}
+
+	// Requeued runs are replayed by any worker serving the name; stop the
+	// live worker first so it doesn't own the requeued run's shard
+	eng.storage.MarkWorkerStopped("live-1")
+	if err := eng.StartWorker(WorkerConfig{Capacity: 1, PollInterval: 10 * time.Millisecond}); err != nil {
+		t.Fatalf("failed to start worker: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if status, _ := eng.storage.GetWorkflowStatus("import-1"); status == "completed" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("requeued workflow never completed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Interrupted runs resume when their code executes them again
+	if err := eng.Execute("adhoc-1", func(ctx *Context) error { return nil }); err != nil {
+		t.Fatalf("failed to resume interrupted workflow: %v", err)
+	}
+	if status, _ := eng.storage.GetWorkflowStatus("adhoc-1"); status != "completed" {
+		t.Errorf("expected completed, got %s", status)
+	}
+}
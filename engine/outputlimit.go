@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrOutputTooLarge is returned when a step's encoded output exceeds its size limit
+var ErrOutputTooLarge = errors.New("step output too large")
+
+// OutputLimit bounds the encoded size of step outputs. Every completed
+// output is loaded into memory when a workflow resumes, so one oversized
+// result slows down every later replay of the run.
+type OutputLimit struct {
+	MaxBytes int  // largest encoded output a step may return; 0 is unlimited
+	Offload  bool // store larger outputs in chunked blob storage instead of failing the step
+}
+
+// WithOutputLimit sets the default size limit for step outputs
+func WithOutputLimit(limit OutputLimit) EngineOption {
+	return func(e *Engine) {
+		e.outputLimit = limit
+	}
+}
+
+// WithMaxOutputSize overrides the engine's output size limit for one step
+func WithMaxOutputSize(maxBytes int) StepOption {
+	return func(o *stepOptions) {
+		o.maxOutput = maxBytes
+	}
+}
+
+// checkOutputSize reports whether an encoded output must be offloaded, or
+// fails if it is over the limit and offloading is disabled
+func (ctx *Context) checkOutputSize(stepID string, so *stepOptions, output []byte) (bool, error) {
+	limit := ctx.engine.outputLimit.MaxBytes
+	if so.maxOutput > 0 {
+		limit = so.maxOutput
+	}
+	if limit <= 0 || len(output) <= limit {
+		return false, nil
+	}
+	if ctx.engine.outputLimit.Offload {
+		return true, nil
+	}
+	return false, fmt.Errorf("%w: %s returned %d bytes, limit is %d", ErrOutputTooLarge, stepID, len(output), limit)
+}
+
+// offloadStep records a completed step whose output is stored in chunks, so
+// it is read on demand instead of with the rest of the run's history
+func (ctx *Context) offloadStep(stepKey string, output []byte) error {
+	w, err := ctx.storage.newStreamWriter(ctx.WorkflowID, stepKey)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(output); err != nil {
+		w.discard()
+		return err
+	}
+	blobID, err := w.finish()
+	if err != nil {
+		return err
+	}
+	if err := ctx.storage.SaveStreamedStep(ctx.WorkflowID, stepKey, blobID); err != nil {
+		return fmt.Errorf("failed to save step: %w", err)
+	}
+
+	ctx.mu.Lock()
+	ctx.completedSteps[stepKey] = nil
+	ctx.mu.Unlock()
+	return nil
+}
+
+// loadOffloaded reads the output of a completed step that was not loaded
+// with the run's history because it is stored in chunks
+func (ctx *Context) loadOffloaded(stepKey string) ([]byte, error) {
+	r, found, err := ctx.storage.OpenStepOutput(ctx.WorkflowID, stepKey)
+	if err != nil || !found {
+		return nil, err
+	}
+	defer r.Close()
+
+	output, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read offloaded output: %w", err)
+	}
+	return output, nil
+}
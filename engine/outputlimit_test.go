@@ -0,0 +1,88 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestOutputLimitFailsOversizedStep(t *testing.T) {
+	dbPath := "./test_output_limit.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath, WithOutputLimit(OutputLimit{MaxBytes: 100}))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	big := strings.Repeat("x", 200)
+	err = eng.Execute("limit-1", func(ctx *Context) error {
+		_, err := Step(ctx, "big", func() (string, error) { return big, nil })
+		return err
+	})
+	if !errors.Is(err, ErrOutputTooLarge) {
+		t.Fatalf("expected ErrOutputTooLarge, got %v", err)
+	}
+
+	// A per-step limit overrides the engine default
+	err = eng.Execute("limit-2", func(ctx *Context) error {
+		_, err := Step(ctx, "big", func() (string, error) { return big, nil }, WithMaxOutputSize(1000))
+		return err
+	})
+	if err != nil {
+		t.Fatalf("expected per-step limit to allow output, got %v", err)
+	}
+}
+
+func TestOutputLimitOffloadsOversizedStep(t *testing.T) {
+	dbPath := "./test_output_offload.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath, WithOutputLimit(OutputLimit{MaxBytes: 100, Offload: true}))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	big := strings.Repeat("y", 5000)
+	var calls, runs int
+	var got string
+	workflow := func(ctx *Context) error {
+		runs++
+		out, err := Step(ctx, "big", func() (string, error) {
+			calls++
+			return big, nil
+		})
+		if err != nil {
+			return err
+		}
+		got = out
+		if runs == 1 {
+			return errors.New("crash after step")
+		}
+		return nil
+	}
+
+	eng.Execute("offload-1", workflow)
+
+	// The output is kept out of the history loaded on resume
+	loaded, err := eng.storage.LoadCompletedSteps("offload-1")
+	if err != nil {
+		t.Fatalf("failed to load steps: %v", err)
+	}
+	for key, output := range loaded {
+		if output != nil {
+			t.Errorf("expected %s to be offloaded, loaded %d bytes", key, len(output))
+		}
+	}
+
+	got = ""
+	if err := eng.Execute("offload-1", workflow); err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+	if calls != 1 || got != big {
+		t.Errorf("expected replayed output after %d call(s), got %d bytes after %d", 1, len(got), calls)
+	}
+}
@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is the error a step or workflow function that panicked fails
+// with, so one bad input fails its run instead of crashing the worker
+type PanicError struct {
+	Value interface{}
+	Stack []byte // stack of the panicking goroutine
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// ErrorReporter receives every workflow failure, e.g. to forward it to
+// Sentry or Rollbar. stack is the panic's stack if the run failed because a
+// step or workflow function panicked, and nil otherwise.
+type ErrorReporter func(workflowID string, err error, stack []byte)
+
+// OnError adds a reporter called whenever a run fails, in the order added
+func (e *Engine) OnError(report ErrorReporter) {
+	e.errorMu.Lock()
+	defer e.errorMu.Unlock()
+	e.errorReporters = append(e.errorReporters, report)
+}
+
+// reportError hands a run's failure to every error reporter
+func (e *Engine) reportError(workflowID string, err error) {
+	e.errorMu.Lock()
+	reporters := e.errorReporters
+	e.errorMu.Unlock()
+
+	var stack []byte
+	var pe *PanicError
+	if errors.As(err, &pe) {
+		stack = pe.Stack
+	}
+	for _, report := range reporters {
+		report(workflowID, err, stack)
+	}
+}
+
+// recoverPanic turns a panic of the calling function into err
+func recoverPanic(err *error) {
+	if r := recover(); r != nil {
+		*err = &PanicError{Value: r, Stack: debug.Stack()}
+	}
+}
+
+// callStep runs a step function, returning a panic as a *PanicError
+func callStep[T any](fn func() (T, error)) (result T, err error) {
+	defer recoverPanic(&err)
+	return fn()
+}
+
+// callWorkflow runs a workflow or parallel function, returning a panic as a *PanicError
+func callWorkflow(fn func() error) (err error) {
+	defer recoverPanic(&err)
+	return fn()
+}
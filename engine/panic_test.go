@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestErrorReporterGetsFailuresAndPanics(t *testing.T) {
+	dbPath := "./test_panic.db"
+	defer os.Remove(dbPath)
+
+	engine, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	type report struct {
+		id    string
+		err   error
+		stack []byte
+	}
+	var mu sync.Mutex
+	var reports []report
+	engine.OnError(func(workflowID string, err error, stack []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		reports = append(reports, report{workflowID, err, stack})
+	})
+
+	// A panicking step fails its run instead of crashing the worker
+	err = engine.Execute("panic-1", func(ctx *Context) error {
+		_, err := Step(ctx, "parse", func() (int, error) {
+			var m map[string]int
+			m["boom"] = 1
+			return 0, nil
+		})
+		return err
+	})
+	var pe *PanicError
+	if !errors.As(err, &pe) {
+		t.Fatalf("expected a PanicError, got %v", err)
+	}
+
+	// So does a panic in a parallel branch
+	err = engine.Execute("panic-2", func(ctx *Context) error {
+		ctx.Go(func() error { panic("branch exploded") })
+		return ctx.Wait()
+	})
+	if !errors.As(err, &pe) || pe.Value != "branch exploded" {
+		t.Fatalf("expected the branch's panic, got %v", err)
+	}
+
+	engine.Execute("failed-1", func(ctx *Context) error { return errors.New("card declined") })
+	engine.Execute("ok-1", func(ctx *Context) error { return nil })
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reports) != 3 {
+		t.Fatalf("expected three failures reported, got %d", len(reports))
+	}
+	if reports[0].id != "panic-1" || !strings.Contains(string(reports[0].stack), "panic") {
+		t.Errorf("expected panic-1 with its stack, got %s and %d stack bytes", reports[0].id, len(reports[0].stack))
+	}
+	if reports[2].id != "failed-1" || reports[2].stack != nil || reports[2].err.Error() != "card declined" {
+		t.Errorf("expected failed-1 without a stack, got %+v", reports[2])
+	}
+	if status, _ := engine.GetWorkflowStatus("panic-1"); status != "failed" {
+		t.Errorf("expected the panicked run to be failed, got %s", status)
+	}
+}
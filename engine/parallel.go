@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// StepError is returned by Step when a step fails. Its message is the
+// underlying error's, so it only adds the ID of the failed step.
+type StepError struct {
+	StepID string
+	Err    error
+}
+
+// Error returns the underlying error's message
+func (e *StepError) Error() string { return e.Err.Error() }
+
+// Unwrap returns the underlying error
+func (e *StepError) Unwrap() error { return e.Err }
+
+// ParallelError is returned by Wait when more than one function started with
+// Go failed. Errors are in the order the functions were started.
+type ParallelError struct {
+	Errors []error
+}
+
+// Error lists every failure, prefixed with its step ID where known
+func (e *ParallelError) Error() string {
+	parts := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		var se *StepError
+		if errors.As(err, &se) {
+			parts[i] = fmt.Sprintf("step %s: %v", se.StepID, err)
+		} else {
+			parts[i] = err.Error()
+		}
+	}
+	return fmt.Sprintf("%d parallel tasks failed: %s", len(e.Errors), strings.Join(parts, "; "))
+}
+
+// Unwrap returns the individual errors, so errors.Is and errors.As match any of them
+func (e *ParallelError) Unwrap() []error { return e.Errors }
+
+// FailedSteps returns the IDs of the steps whose failure caused each error, where known
+func (e *ParallelError) FailedSteps() []string {
+	var ids []string
+	for _, err := range e.Errors {
+		var se *StepError
+		if errors.As(err, &se) {
+			ids = append(ids, se.StepID)
+		}
+	}
+	return ids
+}
+
+// parallelFailure is an error returned by the index-th function passed to Go
+type parallelFailure struct {
+	index int
+	err   error
+}
+
+// collectParallelErrors combines the failures of one Wait, ordered by start
+func collectParallelErrors(failures []parallelFailure) error {
+	switch len(failures) {
+	case 0:
+		return nil
+	case 1:
+		return failures[0].err
+	}
+
+	sort.Slice(failures, func(i, j int) bool { return failures[i].index < failures[j].index })
+	errs := make([]error, len(failures))
+	for i, f := range failures {
+		errs[i] = f.err
+	}
+	return &ParallelError{Errors: errs}
+}
+
+// withStepGroup records the Map call a step belongs to, for describing runs
+func withStepGroup(group string) StepOption {
+	return func(o *stepOptions) {
+		o.group = group
+	}
+}
+
+// Map runs fn as a step for every item in parallel and returns the results
+// in input order, whatever order the steps finish in. Step IDs are
+// prefix-0, prefix-1, and so on. Failures are reported as Wait reports them;
+// the results of failed items are left at their zero value.
+//
+// Map waits only for its own steps, so it may be mixed with Go and Wait.
+func Map[In, Out any](ctx *Context, prefix string, items []In, fn func(i int, item In) (Out, error), opts ...StepOption) ([]Out, error) {
+	results := make([]Out, len(items))
+	errs := make([]error, len(items))
+
+	// Number the steps in input order so history doesn't depend on scheduling
+	ids := make([]string, len(items))
+	for i := range items {
+		ids[i] = fmt.Sprintf("%s-%d", prefix, i)
+	}
+	if _, err := ctx.stepSequences(ids); err != nil {
+		return nil, err
+	}
+
+	// Cap opts so the append can't write into the caller's backing array
+	opts = append(opts[:len(opts):len(opts)], withStepGroup(prefix))
+
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = Step(ctx, ids[i], func() (Out, error) {
+				return fn(i, item)
+			}, opts...)
+		}()
+	}
+	wg.Wait()
+
+	var failures []parallelFailure
+	for i, err := range errs {
+		if err != nil {
+			failures = append(failures, parallelFailure{index: i, err: err})
+		}
+	}
+	return results, collectParallelErrors(failures)
+}
@@ -0,0 +1,182 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errBadFile = errors.New("bad file")
+
+func TestWaitAggregatesParallelFailures(t *testing.T) {
+	dbPath := "./test_parallel.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	var waitErr error
+	eng.Execute("fanout-1", func(ctx *Context) error {
+		for i := 0; i < 6; i++ {
+			id := fmt.Sprintf("file-%d", i)
+			ctx.Go(func() error {
+				_, err := Step(ctx, id, func() (int, error) {
+					// Later files fail first, so order comes from launch, not completion
+					time.Sleep(time.Duration(6-i) * 5 * time.Millisecond)
+					if i%2 == 1 {
+						return 0, fmt.Errorf("%w: %s", errBadFile, id)
+					}
+					return i, nil
+				})
+				return err
+			})
+		}
+		waitErr = ctx.Wait()
+		return waitErr
+	})
+
+	var pe *ParallelError
+	if !errors.As(waitErr, &pe) {
+		t.Fatalf("expected *ParallelError, got %T: %v", waitErr, waitErr)
+	}
+	if want := []string{"file-1", "file-3", "file-5"}; !reflect.DeepEqual(pe.FailedSteps(), want) {
+		t.Errorf("expected failed steps %v, got %v", want, pe.FailedSteps())
+	}
+	if !errors.Is(waitErr, errBadFile) {
+		t.Error("expected errors.Is to match an individual failure")
+	}
+	if !strings.HasPrefix(waitErr.Error(), "3 parallel tasks failed: step file-1: bad file: file-1;") {
+		t.Errorf("unexpected message: %v", waitErr)
+	}
+}
+
+func TestWaitSingleFailureAndTolerance(t *testing.T) {
+	dbPath := "./test_parallel_single.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	// A workflow may inspect and tolerate failures; they aren't reported again at completion
+	err = eng.Execute("fanout-2", func(ctx *Context) error {
+		ctx.Go(func() error {
+			_, err := Step(ctx, "optional", func() (int, error) { return 0, errBadFile })
+			return err
+		})
+		err := ctx.Wait()
+		var se *StepError
+		if !errors.As(err, &se) || se.StepID != "optional" || err.Error() != "bad file" {
+			return fmt.Errorf("expected a StepError for optional, got %T: %v", err, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected tolerated failure to complete the workflow, got %v", err)
+	}
+}
+
+func TestMapOrdersResultsByInput(t *testing.T) {
+	dbPath := "./test_parallel_map.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	items := []int{5, 4, 3, 2, 1, 0}
+	var calls int32
+	run := func() ([]int, error) {
+		var out []int
+		err := eng.Execute("map-1", func(ctx *Context) error {
+			// Later items finish first
+			res, err := Map(ctx, "square", items, func(i, n int) (int, error) {
+				atomic.AddInt32(&calls, 1)
+				time.Sleep(time.Duration(6-i) * 5 * time.Millisecond)
+				return n * n, nil
+			})
+			out = res
+			return err
+		})
+		return out, err
+	}
+
+	got, err := run()
+	if err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	if want := []int{25, 16, 9, 4, 1, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	// Replay returns the same order without rerunning any step
+	eng.storage.db.Exec("UPDATE workflows SET status = 'running' WHERE workflow_id = 'map-1'")
+	replayed, err := run()
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if !reflect.DeepEqual(replayed, got) || calls != 6 {
+		t.Errorf("expected replay %v with 6 calls, got %v with %d", got, replayed, calls)
+	}
+
+	steps, _ := eng.storage.ListSteps("map-1")
+	if len(steps) != 6 || steps[0].StepID != "square-0" {
+		t.Errorf("unexpected steps: %+v", steps)
+	}
+
+	// Options passed with spare capacity are not written past their length
+	opts := make([]StepOption, 0, 1)
+	err = eng.Execute("map-2", func(ctx *Context) error {
+		_, err := Map(ctx, "cube", items, func(i, n int) (int, error) { return n * n * n, nil }, opts...)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	if spare := opts[:1]; spare[0] != nil {
+		t.Error("expected Map to leave the caller's option slice untouched")
+	}
+}
+
+func TestMapReportsEveryFailure(t *testing.T) {
+	dbPath := "./test_parallel_map_fail.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	var results []string
+	err = eng.Execute("map-2", func(ctx *Context) error {
+		res, err := Map(ctx, "check", []string{"a", "", "c", ""}, func(i int, s string) (string, error) {
+			if s == "" {
+				return "", errBadFile
+			}
+			return s + "!", nil
+		})
+		results = res
+		return err
+	})
+
+	var pe *ParallelError
+	if !errors.As(err, &pe) || !reflect.DeepEqual(pe.FailedSteps(), []string{"check-1", "check-3"}) {
+		t.Fatalf("expected failures for check-1 and check-3, got %v", err)
+	}
+	if want := []string{"a!", "", "c!", ""}; !reflect.DeepEqual(results, want) {
+		t.Errorf("expected partial results %v, got %v", want, results)
+	}
+}
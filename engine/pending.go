@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Step kinds recorded with each step
+const (
+	StepKindStep       = "step"
+	StepKindSleep      = "sleep"
+	StepKindSignal     = "signal"
+	StepKindSignalSend = "signal_send"
+	StepKindChildStart = "child_start"
+	StepKindState      = "state"
+	StepKindUpdate     = "update"
+	StepKindGroup      = "group"
+	StepKindLocal      = "local"
+	StepKindCounter    = "counter"
+	StepKindTimer      = "timer"
+	StepKindScope      = "scope"
+)
+
+// PendingStep is a step currently in progress
+type PendingStep struct {
+	WorkflowID string
+	StepID     string
+	StepKey    string
+	Kind       string
+	StartedAt  time.Time
+	Owner      string // worker ID executing the step
+	WaitingOn  string // human-readable description of what the step is blocked on
+}
+
+// GetPendingSteps returns the in-progress steps of a workflow, oldest first,
+// so monitors can tell a step stuck in user code from one waiting on a signal or timer
+func (e *Engine) GetPendingSteps(workflowID string) ([]PendingStep, error) {
+	steps, err := e.storage.ListPendingSteps(workflowID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range steps {
+		p := &steps[i]
+		switch p.Kind {
+		case StepKindSignal:
+			// Step IDs are "signal:<name>:<n>"
+			name := strings.TrimPrefix(p.StepID, "signal:")
+			if i := strings.LastIndex(name, ":"); i >= 0 {
+				name = name[:i]
+			}
+			p.WaitingOn = fmt.Sprintf("signal %q", name)
+		case StepKindUpdate:
+			// Step IDs are "update:<name>:<n>"
+			name := strings.TrimPrefix(p.StepID, "update:")
+			if i := strings.LastIndex(name, ":"); i >= 0 {
+				name = name[:i]
+			}
+			p.WaitingOn = fmt.Sprintf("update %q", name)
+		case StepKindGroup:
+			p.WaitingOn = "first function of group to succeed"
+		case StepKindSleep:
+			t, err := e.storage.GetTimer(fmt.Sprintf("%s/%s", workflowID, p.StepID))
+			if err != nil {
+				return nil, err
+			}
+			if t != nil {
+				p.WaitingOn = fmt.Sprintf("timer until %s", t.FireAt.Format(time.RFC3339))
+			}
+		default:
+			p.WaitingOn = "executing"
+		}
+	}
+
+	return steps, nil
+}
+
+// ListPendingSteps loads in-progress steps of a workflow
+func (s *Storage) ListPendingSteps(workflowID string) ([]PendingStep, error) {
+	rows, err := s.db.Query(
+		`SELECT step_id, step_key, kind, started_at, worker_id FROM steps
+		 WHERE workflow_id = ? AND status = 'in_progress' ORDER BY started_at, sequence_num`,
+		workflowID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending steps: %w", err)
+	}
+	defer rows.Close()
+
+	var steps []PendingStep
+	for rows.Next() {
+		p := PendingStep{WorkflowID: workflowID}
+		var owner sql.NullString
+		if err := rows.Scan(&p.StepID, &p.StepKey, &p.Kind, &p.StartedAt, &owner); err != nil {
+			return nil, fmt.Errorf("failed to scan pending step: %w", err)
+		}
+		p.Owner = owner.String
+		steps = append(steps, p)
+	}
+
+	return steps, rows.Err()
+}
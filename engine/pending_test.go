@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestGetPendingSteps(t *testing.T) {
+	dbPath := "./test_pending.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath, WithWorkerID("worker-7"))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- eng.Execute("onboard-1", func(ctx *Context) error {
+			ctx.Go(func() error {
+				_, err := Step(ctx, "provision-laptop", func() (string, error) {
+					close(started)
+					<-release
+					return "LAPTOP-1", nil
+				})
+				return err
+			})
+			ctx.Go(func() error {
+				_, err := WaitForSignal[bool](ctx, "manager-approval")
+				return err
+			})
+			return ctx.Wait()
+		})
+	}()
+
+	<-started
+	time.Sleep(50 * time.Millisecond)
+
+	pending, err := eng.GetPendingSteps("onboard-1")
+	if err != nil {
+		t.Fatalf("failed to get pending steps: %v", err)
+	}
+	if len(pending) != 2 {
+		t.Fatalf("expected 2 pending steps, got %+v", pending)
+	}
+
+	byID := make(map[string]PendingStep)
+	for _, p := range pending {
+		byID[p.StepID] = p
+	}
+	laptop := byID["provision-laptop"]
+	if laptop.WaitingOn != "executing" || laptop.Owner != "worker-7" || laptop.StartedAt.IsZero() {
+		t.Errorf("unexpected laptop step: %+v", laptop)
+	}
+	if approval := byID["signal:manager-approval:1"]; approval.WaitingOn != `signal "manager-approval"` {
+		t.Errorf("expected approval step to wait on a signal, got %+v", approval)
+	}
+
+	close(release)
+	eng.Signal("onboard-1", "manager-approval", true)
+	if err := <-done; err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// PIIAttribute is the search attribute set to "true" on runs whose steps
+// returned personal data. Each category found is also recorded as
+// "pii.<category>", naming the last step whose output contained it.
+const PIIAttribute = "pii"
+
+// PIIRule flags one category of personal data: string or number values
+// matching Pattern, and fields whose name is one of Fields. Field names are
+// compared case-insensitively, ignoring '_' and '-'.
+type PIIRule struct {
+	Category string
+	Pattern  *regexp.Regexp
+	Fields   []string
+}
+
+// DefaultPIIRules detect email addresses, phone numbers, payment card numbers,
+// US social security numbers and commonly named personal fields
+var DefaultPIIRules = []PIIRule{
+	{Category: "email", Pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), Fields: []string{"email", "emailaddress"}},
+	{Category: "phone", Pattern: regexp.MustCompile(`\+\d{1,3}[ .-]?\(?\d{2,4}\)?[ .-]?\d{3,4}[ .-]?\d{3,4}\b`), Fields: []string{"phone", "phonenumber", "mobile"}},
+	{Category: "card", Pattern: regexp.MustCompile(`\b\d{4}[ -]?\d{4}[ -]?\d{4}[ -]?\d{1,4}\b`), Fields: []string{"cardnumber", "pan", "cvv"}},
+	{Category: "ssn", Pattern: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`), Fields: []string{"ssn", "socialsecuritynumber"}},
+	{Category: "identity", Fields: []string{"dateofbirth", "dob", "passport", "passportnumber", "taxid"}},
+}
+
+// PIIClassifier is a StepInterceptor that scans step outputs against its
+// rules and tags runs that returned personal data with PIIAttribute.
+// Outputs that aren't JSON are matched against the patterns as text.
+type PIIClassifier struct {
+	rules  []PIIRule
+	fields map[string]string // normalized field name -> category
+}
+
+// NewPIIClassifier creates a classifier; with no rules it uses DefaultPIIRules
+func NewPIIClassifier(rules ...PIIRule) *PIIClassifier {
+	if len(rules) == 0 {
+		rules = DefaultPIIRules
+	}
+	c := &PIIClassifier{rules: rules, fields: make(map[string]string)}
+	for _, r := range rules {
+		for _, f := range r.Fields {
+			c.fields[normalizeFieldName(f)] = r.Category
+		}
+	}
+	return c
+}
+
+// Classify returns the sorted categories of personal data found in an
+// encoded value
+func (c *PIIClassifier) Classify(data []byte) []string {
+	found := make(map[string]bool)
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err == nil {
+		c.walk(v, found)
+	} else {
+		c.matchText(string(data), found)
+	}
+
+	categories := make([]string, 0, len(found))
+	for category := range found {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// StepFinished tags the run with the categories found in the step's output
+func (c *PIIClassifier) StepFinished(ctx *Context, step *FinishedStep) {
+	if step.Output == nil {
+		return
+	}
+	categories := c.Classify(step.Output)
+	if len(categories) == 0 {
+		return
+	}
+
+	for _, category := range categories {
+		if err := ctx.SetSearchAttribute(PIIAttribute+"."+category, step.StepID); err != nil {
+			fmt.Printf("[PII] failed to tag %s: %v\n", ctx.WorkflowID, err)
+			return
+		}
+	}
+	if err := ctx.SetSearchAttribute(PIIAttribute, "true"); err != nil {
+		fmt.Printf("[PII] failed to tag %s: %v\n", ctx.WorkflowID, err)
+		return
+	}
+	ctx.printf("[PII] %s returned %s\n", step.StepID, strings.Join(categories, ", "))
+}
+
+// walk classifies a decoded JSON value
+func (c *PIIClassifier) walk(v interface{}, found map[string]bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if category, ok := c.fields[normalizeFieldName(key)]; ok && !isEmptyJSON(value) {
+				found[category] = true
+			}
+			c.walk(value, found)
+		}
+	case []interface{}:
+		for _, value := range v {
+			c.walk(value, found)
+		}
+	case string:
+		c.matchText(v, found)
+	case json.Number:
+		c.matchText(v.String(), found)
+	}
+}
+
+// matchText applies the rules' patterns to text
+func (c *PIIClassifier) matchText(text string, found map[string]bool) {
+	for _, r := range c.rules {
+		if r.Pattern != nil && !found[r.Category] && r.Pattern.MatchString(text) {
+			found[r.Category] = true
+		}
+	}
+}
+
+// normalizeFieldName lowercases a field name and drops '_' and '-'
+func normalizeFieldName(name string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(name))
+}
+
+// isEmptyJSON reports whether a decoded value carries no data
+func isEmptyJSON(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"os"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestPIIClassify(t *testing.T) {
+	c := NewPIIClassifier()
+
+	cases := []struct {
+		data string
+		want []string
+	}{
+		{`{"id": 7, "status": "shipped"}`, []string{}},
+		{`{"contact": "ada@example.com"}`, []string{"email"}},
+		{`{"customer": {"Date_Of_Birth": "1990-01-01", "notes": ["call +44 20 7946 0958"]}}`, []string{"identity", "phone"}},
+		{`{"ssn": ""}`, []string{}},
+		{`[4111111111111111]`, []string{"card"}},
+		{`not json: 123-45-6789`, []string{"ssn"}},
+	}
+	for _, tc := range cases {
+		if got := c.Classify([]byte(tc.data)); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Classify(%s) = %v, want %v", tc.data, got, tc.want)
+		}
+	}
+
+	custom := NewPIIClassifier(PIIRule{Category: "employee", Pattern: regexp.MustCompile(`\bEMP-\d{6}\b`)})
+	if got := custom.Classify([]byte(`"EMP-004211 ada@example.com"`)); !reflect.DeepEqual(got, []string{"employee"}) {
+		t.Errorf("expected only the custom rule to apply, got %v", got)
+	}
+}
+
+func TestPIIClassifierTagsRuns(t *testing.T) {
+	dbPath := "./test_pii.db"
+	defer os.Remove(dbPath)
+
+	engine, err := NewEngine(dbPath, WithStepInterceptor(NewPIIClassifier()))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	type user struct {
+		ID    int    `json:"id"`
+		Email string `json:"email"`
+	}
+	engine.Execute("pii-1", func(ctx *Context) error {
+		_, err := Step(ctx, "load-user", func() (user, error) { return user{ID: 1, Email: "ada@example.com"}, nil })
+		return err
+	})
+	engine.Execute("pii-2", func(ctx *Context) error {
+		_, err := Step(ctx, "count", func() (int, error) { return 3, nil })
+		return err
+	})
+
+	attrs, err := engine.GetSearchAttributes("pii-1")
+	if err != nil {
+		t.Fatalf("failed to get search attributes: %v", err)
+	}
+	if attrs[PIIAttribute] != "true" || attrs["pii.email"] != "load-user" {
+		t.Errorf("expected pii-1 tagged with email from load-user, got %v", attrs)
+	}
+
+	runs, err := engine.ListWorkflows(WorkflowFilter{SearchAttributes: map[string]string{PIIAttribute: "true"}})
+	if err != nil {
+		t.Fatalf("failed to list workflows: %v", err)
+	}
+	if len(runs) != 1 || runs[0].WorkflowID != "pii-1" {
+		t.Errorf("expected only pii-1 to be tagged, got %+v", runs)
+	}
+}
@@ -0,0 +1,295 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrPluginNotRegistered is returned by NewEngine for a WithPlugin name no
+// package registered with RegisterPlugin
+var ErrPluginNotRegistered = errors.New("plugin not registered")
+
+// EngineEventType names a lifecycle event delivered to plugins
+type EngineEventType string
+
+// Lifecycle events delivered to plugins
+const (
+	EventWorkflowStarted   EngineEventType = "workflow.started" // an execution started or resumed a run
+	EventWorkflowCompleted EngineEventType = "workflow.completed"
+	EventWorkflowFailed    EngineEventType = "workflow.failed"
+	EventWorkflowCanceled  EngineEventType = "workflow.canceled"
+	EventStepStarted       EngineEventType = "step.started" // a durable step was marked in progress
+	EventStepCompleted     EngineEventType = "step.completed"
+	EventStepFailed        EngineEventType = "step.failed"
+	EventStepRetrying      EngineEventType = "step.retrying" // an attempt failed and another follows
+	EventTimerScheduled    EngineEventType = "timer.scheduled"
+	EventTimerFired        EngineEventType = "timer.fired"
+	EventTimerCanceled     EngineEventType = "timer.canceled"
+	EventSignalSent        EngineEventType = "signal.sent"
+	EventSignalReceived    EngineEventType = "signal.received"
+)
+
+// EngineEvent is one lifecycle event of a run executed by this engine.
+// Fields that don't apply to an event's type are zero.
+type EngineEvent struct {
+	Type       EngineEventType
+	WorkflowID string
+	Name       string // registered workflow name, on workflow.completed, failed and canceled
+	StepID     string
+	StepKey    string
+	Kind       string        // step kind, or timer kind on timer events
+	Attempt    int           // attempts so far, on step.completed, failed and retrying
+	Output     []byte        // encoded result, on step.completed unless streamed
+	Err        error         // on workflow.failed, step.failed and step.retrying
+	Duration   time.Duration // how long the run or step took; on step.retrying, the wait before the next attempt
+	Timer      string        // timer key
+	FireAt     time.Time     // when the timer is due, on timer.scheduled and fired
+	Signal     string        // signal name
+	Time       time.Time
+}
+
+// Plugin receives every lifecycle event of an engine: workflows starting
+// and finishing, steps starting, finishing and retrying, timers and signals.
+// Each plugin gets the events in the order they happened, one at a time on
+// its own goroutine, so a slow plugin delays neither the workflows nor the
+// other plugins; its backlog is kept in memory until it catches up, and
+// events still queued when the process dies are lost. Steps replayed from
+// history are not reported again. Events are shared by every plugin and
+// must not be modified.
+//
+// A plugin may also implement PluginStarter, to be given the engine, and
+// io.Closer, to flush what it buffered when the engine closes.
+type Plugin interface {
+	Name() string
+	HandleEvent(ev *EngineEvent)
+}
+
+// PluginStarter is implemented by plugins that need the engine, e.g. to
+// read run history; Start runs before NewEngine returns
+type PluginStarter interface {
+	Start(e *Engine) error
+}
+
+// PluginFactory creates a plugin from its configuration, the JSON given to
+// WithPlugin (nil if none was given)
+type PluginFactory func(config json.RawMessage) (Plugin, error)
+
+// pluginRegistry holds the factories registered with RegisterPlugin
+var pluginRegistry = struct {
+	sync.RWMutex
+	factories map[string]PluginFactory
+}{factories: make(map[string]PluginFactory)}
+
+// RegisterPlugin makes a plugin available to WithPlugin by name. Packages
+// providing plugins call it from an init function, so importing them is
+// enough to load them by configuration. It panics if the name is taken.
+func RegisterPlugin(name string, factory PluginFactory) {
+	pluginRegistry.Lock()
+	defer pluginRegistry.Unlock()
+	if factory == nil {
+		panic("engine: RegisterPlugin factory is nil")
+	}
+	if _, dup := pluginRegistry.factories[name]; dup {
+		panic("engine: RegisterPlugin called twice for plugin " + name)
+	}
+	pluginRegistry.factories[name] = factory
+}
+
+// RegisteredPlugins returns the names of the registered plugins, sorted
+func RegisteredPlugins() []string {
+	pluginRegistry.RLock()
+	defer pluginRegistry.RUnlock()
+	names := make([]string, 0, len(pluginRegistry.factories))
+	for name := range pluginRegistry.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// pluginSpec is a registered plugin to load, as given to WithPlugin
+type pluginSpec struct {
+	name   string
+	config json.RawMessage
+}
+
+// WithPlugin loads the plugin registered under name, created from config
+func WithPlugin(name string, config json.RawMessage) EngineOption {
+	return func(e *Engine) {
+		e.pluginSpecs = append(e.pluginSpecs, pluginSpec{name, config})
+	}
+}
+
+// WithPlugins adds plugin instances, started after those loaded by name
+func WithPlugins(plugins ...Plugin) EngineOption {
+	return func(e *Engine) {
+		e.plugins = append(e.plugins, plugins...)
+	}
+}
+
+// startPlugins creates the plugins loaded by name and starts delivering
+// events to every plugin
+func (e *Engine) startPlugins() error {
+	var plugins []Plugin
+	for _, spec := range e.pluginSpecs {
+		pluginRegistry.RLock()
+		factory := pluginRegistry.factories[spec.name]
+		pluginRegistry.RUnlock()
+		if factory == nil {
+			return fmt.Errorf("%w: %s", ErrPluginNotRegistered, spec.name)
+		}
+		p, err := factory(spec.config)
+		if err != nil {
+			return fmt.Errorf("failed to create plugin %s: %w", spec.name, err)
+		}
+		plugins = append(plugins, p)
+	}
+	plugins = append(plugins, e.plugins...)
+
+	for _, p := range plugins {
+		if starter, ok := p.(PluginStarter); ok {
+			if err := starter.Start(e); err != nil {
+				return fmt.Errorf("failed to start plugin %s: %w", p.Name(), err)
+			}
+		}
+		e.bus.subscribe(p)
+		fmt.Printf("[PLUGIN] loaded %s\n", p.Name())
+	}
+	return nil
+}
+
+// emit delivers a lifecycle event to the engine's plugins
+func (e *Engine) emit(ev EngineEvent) {
+	if !e.bus.active() {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	e.bus.publish(&ev)
+}
+
+// pluginBacklogWarning is how far behind a plugin gets before it is reported
+const pluginBacklogWarning = 10000
+
+// pluginBus queues events for each plugin
+type pluginBus struct {
+	mu     sync.RWMutex
+	subs   []*pluginSub
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// pluginSub is one plugin's queue of undelivered events
+type pluginSub struct {
+	plugin Plugin
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []*EngineEvent
+	closed bool
+	warned bool
+}
+
+// active reports whether any plugin receives events
+func (b *pluginBus) active() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs) > 0 && !b.closed
+}
+
+// subscribe starts delivering events to p
+func (b *pluginBus) subscribe(p Plugin) {
+	sub := &pluginSub{plugin: p}
+	sub.cond = sync.NewCond(&sub.mu)
+	b.mu.Lock()
+	b.subs = append(b.subs, sub)
+	b.mu.Unlock()
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		sub.deliver()
+	}()
+}
+
+// publish queues ev for every plugin without waiting for them
+func (b *pluginBus) publish(ev *EngineEvent) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return
+	}
+	for _, sub := range b.subs {
+		sub.mu.Lock()
+		sub.queue = append(sub.queue, ev)
+		if len(sub.queue) >= pluginBacklogWarning && !sub.warned {
+			sub.warned = true
+			fmt.Printf("[PLUGIN] %s is %d events behind\n", sub.plugin.Name(), len(sub.queue))
+		}
+		sub.mu.Unlock()
+		sub.cond.Signal()
+	}
+}
+
+// close delivers the queued events, then closes the plugins that are io.Closers
+func (b *pluginBus) close() {
+	b.mu.Lock()
+	b.closed = true
+	subs := b.subs
+	b.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.mu.Lock()
+		sub.closed = true
+		sub.mu.Unlock()
+		sub.cond.Signal()
+	}
+	b.wg.Wait()
+
+	for _, sub := range subs {
+		if closer, ok := sub.plugin.(interface{ Close() error }); ok {
+			if err := closer.Close(); err != nil {
+				fmt.Printf("[PLUGIN] failed to close %s: %v\n", sub.plugin.Name(), err)
+			}
+		}
+	}
+}
+
+// deliver hands queued events to the plugin until the bus closes and the
+// queue is empty
+func (sub *pluginSub) deliver() {
+	for {
+		sub.mu.Lock()
+		for len(sub.queue) == 0 && !sub.closed {
+			sub.cond.Wait()
+		}
+		batch := sub.queue
+		sub.queue = nil
+		if len(batch) < pluginBacklogWarning/2 {
+			sub.warned = false
+		}
+		closed := sub.closed
+		sub.mu.Unlock()
+
+		for _, ev := range batch {
+			sub.handle(ev)
+		}
+		if closed && len(batch) == 0 {
+			return
+		}
+	}
+}
+
+// handle passes one event to the plugin; a panicking plugin keeps receiving events
+func (sub *pluginSub) handle(ev *EngineEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("[PLUGIN] %s panicked handling %s of %s: %v\n", sub.plugin.Name(), ev.Type, ev.WorkflowID, r)
+		}
+	}()
+	sub.plugin.HandleEvent(ev)
+}
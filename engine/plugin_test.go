@@ -0,0 +1,112 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingPlugin collects the types of the events it receives
+type recordingPlugin struct {
+	prefix string
+	mu     sync.Mutex
+	events []string
+	closed bool
+}
+
+func (p *recordingPlugin) Name() string { return "recorder" }
+
+func (p *recordingPlugin) HandleEvent(ev *EngineEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, p.prefix+string(ev.Type)+" "+ev.StepID+ev.Signal)
+}
+
+func (p *recordingPlugin) Close() error {
+	p.closed = true
+	return nil
+}
+
+var recorders = make(chan *recordingPlugin, 1)
+
+func init() {
+	RegisterPlugin("test-recorder", func(config json.RawMessage) (Plugin, error) {
+		var cfg struct {
+			Prefix string `json:"prefix"`
+		}
+		if err := json.Unmarshal(config, &cfg); err != nil {
+			return nil, err
+		}
+		p := &recordingPlugin{prefix: cfg.Prefix}
+		recorders <- p
+		return p, nil
+	})
+}
+
+func TestPluginsReceiveLifecycleEvents(t *testing.T) {
+	dbPath := "./test_plugin.db"
+	defer os.Remove(dbPath)
+
+	if _, err := NewEngine(dbPath, WithPlugin("missing", nil)); !errors.Is(err, ErrPluginNotRegistered) {
+		t.Fatalf("expected ErrPluginNotRegistered, got %v", err)
+	}
+
+	eng, err := NewEngine(dbPath, WithPlugin("test-recorder", json.RawMessage(`{"prefix":"> "}`)))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	p := <-recorders
+
+	if err := eng.Signal("plugin-1", "approve", true); err != nil {
+		t.Fatalf("failed to signal: %v", err)
+	}
+	calls := 0
+	err = eng.Execute("plugin-1", func(ctx *Context) error {
+		_, err := Step(ctx, "charge", func() (int, error) {
+			if calls++; calls == 1 {
+				return 0, errors.New("card declined")
+			}
+			return 1, nil
+		}, WithRetry(RetryPolicy{MaxAttempts: 2, InitialInterval: time.Millisecond}))
+		if err != nil {
+			return err
+		}
+		if err := ctx.Sleep("wait", time.Millisecond); err != nil {
+			return err
+		}
+		_, err = WaitForSignal[bool](ctx, "approve")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	if err := eng.Close(); err != nil {
+		t.Fatalf("failed to close engine: %v", err)
+	}
+
+	want := []string{
+		"> signal.sent approve",
+		"> workflow.started ",
+		"> step.started charge",
+		"> step.retrying charge",
+		"> step.completed charge",
+		"> step.started wait",
+		"> timer.scheduled ",
+		"> timer.fired ",
+		"> step.completed wait",
+		"> step.started signal:approve:1",
+		"> signal.received signal:approve:1approve",
+		"> step.completed signal:approve:1",
+		"> workflow.completed ",
+	}
+	if got := strings.Join(p.events, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("expected events\n%s\ngot\n%s", strings.Join(want, "\n"), got)
+	}
+	if !p.closed {
+		t.Error("expected the plugin to be closed with the engine")
+	}
+}
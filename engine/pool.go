@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// ResourcePool limits how many steps across all workflows and workers may use
+// a shared external resource at once (e.g. 5 concurrent SMTP connections).
+// The size is stored in the database so every engine enforces the same limit.
+type ResourcePool struct {
+	Name string
+	Size int
+}
+
+// NewResourcePool creates or resizes a named pool
+func (e *Engine) NewResourcePool(name string, size int) (*ResourcePool, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("resource pool %s must have a positive size", name)
+	}
+	if err := e.storage.UpsertResourcePool(name, size); err != nil {
+		return nil, fmt.Errorf("failed to save resource pool: %w", err)
+	}
+	return &ResourcePool{Name: name, Size: size}, nil
+}
+
+// GetResourcePool loads a pool created by any engine sharing this database
+func (e *Engine) GetResourcePool(name string) (*ResourcePool, error) {
+	size, err := e.storage.GetResourcePoolSize(name)
+	if err != nil {
+		return nil, err
+	}
+	return &ResourcePool{Name: name, Size: size}, nil
+}
+
+// WithResourcePool makes the step hold a slot of pool while it executes.
+// Replayed steps do not acquire a slot. A slot held by a crashed worker is
+// reclaimed by the same step on resume, or by others once its lease expires.
+func WithResourcePool(pool *ResourcePool, opts ...LockOption) StepOption {
+	return func(o *stepOptions) {
+		o.pools = append(o.pools, pool)
+		o.poolLockOpts = append(o.poolLockOpts, opts...)
+	}
+}
+
+// acquirePools takes one slot from each of the step's pools
+func (ctx *Context) acquirePools(stepID string, so *stepOptions) (func(), error) {
+	var held []*Lock
+	release := func() {
+		for _, l := range held {
+			l.Release()
+		}
+	}
+
+	for _, pool := range so.pools {
+		// Use the stored size so a resize by any engine applies everywhere
+		size, err := ctx.storage.GetResourcePoolSize(pool.Name)
+		if err != nil {
+			size = pool.Size
+		}
+
+		holder := fmt.Sprintf("%s/%s", ctx.WorkflowID, stepID)
+		lock, err := ctx.acquireSlot("pool:"+pool.Name, holder, size, so.poolLockOpts...)
+		if err != nil {
+			release()
+			return nil, fmt.Errorf("failed to acquire resource pool %s: %w", pool.Name, err)
+		}
+		held = append(held, lock)
+	}
+
+	return release, nil
+}
+
+// UpsertResourcePool stores a pool's size
+func (s *Storage) UpsertResourcePool(name string, size int) error {
+	return s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			`INSERT INTO resource_pools (name, size) VALUES (?, ?)
+			 ON CONFLICT(name) DO UPDATE SET size = excluded.size`,
+			name, size,
+		)
+		return err
+	})
+}
+
+// GetResourcePoolSize loads a pool's size
+func (s *Storage) GetResourcePoolSize(name string) (int, error) {
+	var size int
+	err := s.db.QueryRow("SELECT size FROM resource_pools WHERE name = ?", name).Scan(&size)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("resource pool %s not found", name)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get resource pool: %w", err)
+	}
+	return size, nil
+}
@@ -0,0 +1,30 @@
+package engine
+
+import "time"
+
+// DefaultPriorityAging is how long a queued workflow waits to gain one level
+// of priority, unless WorkerConfig.PriorityAging says otherwise
+const DefaultPriorityAging = time.Minute
+
+// WithPriority sets the priority of an enqueued workflow; workers claim
+// higher priorities first (the default is 0). A queued workflow gains one
+// level for every WorkerConfig.PriorityAging it has waited, so low-priority
+// work still runs under sustained high-priority load.
+func WithPriority(priority int) WorkflowOption {
+	return func(o *workflowOptions) {
+		o.priority = priority
+	}
+}
+
+// claimOrder returns the ORDER BY clause workers claim queued workflows in,
+// and its arguments: highest effective priority first, then oldest
+func claimOrder(aging time.Duration) (string, []interface{}) {
+	if aging == 0 {
+		aging = DefaultPriorityAging
+	}
+	if aging < 0 {
+		return "ORDER BY priority DESC, created_at", nil
+	}
+	return `ORDER BY priority + CAST((julianday(?) - julianday(created_at)) * 86400000 AS INTEGER) / ? DESC, created_at`,
+		[]interface{}{dbNow(), aging.Milliseconds()}
+}
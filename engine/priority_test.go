@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPriorityAging(t *testing.T) {
+	dbPath := "./test_priority.db"
+	defer os.Remove(dbPath)
+
+	engine, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	// A low-priority run queued ten minutes ago, and a high-priority one queued now
+	if err := engine.Enqueue("report", "batch", nil); err != nil {
+		t.Fatalf("failed to enqueue: %v", err)
+	}
+	if _, err := engine.storage.db.Exec(
+		"UPDATE workflows SET created_at = ? WHERE workflow_id = 'report'",
+		dbTime(time.Now().Add(-10*time.Minute)),
+	); err != nil {
+		t.Fatalf("failed to age workflow: %v", err)
+	}
+	if err := engine.Enqueue("checkout", "batch", nil, WithPriority(5)); err != nil {
+		t.Fatalf("failed to enqueue: %v", err)
+	}
+
+	shards := make([]int, DefaultShardCount)
+	for i := range shards {
+		shards[i] = i
+	}
+	// claimFirst claims the next workflow and queues it again
+	claimFirst := func(aging time.Duration) string {
+		claimed, err := engine.storage.ClaimQueuedWorkflows("w1", shards, []string{DefaultQueue}, []string{"batch"}, nil, aging, 1)
+		if err != nil || len(claimed) != 1 {
+			t.Fatalf("expected to claim one workflow, got %v: %v", claimed, err)
+		}
+		engine.storage.db.Exec("UPDATE workflows SET status = 'queued' WHERE workflow_id = ?", claimed[0].id)
+		return claimed[0].id
+	}
+
+	// Without aging priority wins; at a minute per level, ten minutes of
+	// waiting outranks a priority of 5
+	if got := claimFirst(-1); got != "checkout" {
+		t.Errorf("expected checkout first without aging, got %s", got)
+	}
+	if got := claimFirst(0); got != "report" {
+		t.Errorf("expected the aged report first, got %s", got)
+	}
+	if got := claimFirst(time.Hour); got != "checkout" {
+		t.Errorf("expected checkout first at an hour per level, got %s", got)
+	}
+}
@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// QueueLatency summarizes the schedule-to-start latency of the steps of one
+// queue's runs: how long steps waited between becoming runnable and a
+// worker starting them. The first step a worker runs after claiming a run
+// waited for as long as the run sat in its queue (enqueued, handed off or
+// requeued after a crash); later steps start as soon as the workflow calls
+// them. Growing percentiles mean the fleet can't keep up with the queue.
+type QueueLatency struct {
+	Queue string
+	Steps int
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// StepQueueLatency returns step schedule-to-start latency percentiles per
+// queue, over steps started since the given time, ordered by queue
+func (e *Engine) StepQueueLatency(since time.Time) ([]QueueLatency, error) {
+	latencies, err := e.reads.ListStepQueueLatencies(since)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]QueueLatency, 0, len(latencies))
+	for queue, ds := range latencies {
+		sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+		out = append(out, QueueLatency{
+			Queue: queue,
+			Steps: len(ds),
+			P50:   latencyPercentile(ds, 0.50),
+			P90:   latencyPercentile(ds, 0.90),
+			P99:   latencyPercentile(ds, 0.99),
+			Max:   ds[len(ds)-1],
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Queue < out[j].Queue })
+	return out, nil
+}
+
+// latencyPercentile returns the nearest-rank percentile q of sorted latencies
+func latencyPercentile(sorted []time.Duration, q float64) time.Duration {
+	rank := int(math.Ceil(q * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// scheduleStep returns when the step being started became runnable: when
+// the run entered its queue for the first step after a dispatcher claimed
+// it, and now for every other step
+func (ctx *Context) scheduleStep() time.Time {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if queued := ctx.queuedAt; !queued.IsZero() {
+		ctx.queuedAt = time.Time{}
+		return queued
+	}
+	return time.Now()
+}
+
+// ListStepQueueLatencies loads the schedule-to-start latency of every step
+// started since the given time, keyed by the queue of its run
+func (s *Storage) ListStepQueueLatencies(since time.Time) (map[string][]time.Duration, error) {
+	rows, err := s.db.Query(
+		`SELECT w.queue, s.scheduled_at, s.started_at
+		 FROM steps s JOIN workflows w ON w.workflow_id = s.workflow_id
+		 WHERE s.scheduled_at IS NOT NULL AND s.started_at >= ?`,
+		dbTime(since),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list step latencies: %w", err)
+	}
+	defer rows.Close()
+
+	latencies := make(map[string][]time.Duration)
+	for rows.Next() {
+		var queue string
+		var scheduled, started time.Time
+		if err := rows.Scan(&queue, &scheduled, &started); err != nil {
+			return nil, fmt.Errorf("failed to scan step latency: %w", err)
+		}
+		d := started.Sub(scheduled)
+		if d < 0 {
+			d = 0
+		}
+		latencies[queue] = append(latencies[queue], d)
+	}
+	return latencies, rows.Err()
+}
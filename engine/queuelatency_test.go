@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStepQueueLatency(t *testing.T) {
+	dbPath := "./test_queue_latency.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	eng.Register("report", func(ctx *Context) error {
+		for i := 0; i < 3; i++ {
+			if _, err := Step(ctx, fmt.Sprintf("part-%d", i), func() (int, error) { return i, nil }); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err := eng.Enqueue("report-1", "report", nil, WithQueue("reports")); err != nil {
+		t.Fatalf("failed to enqueue: %v", err)
+	}
+	// The run has waited two minutes for a worker
+	if _, err := eng.storage.db.Exec(
+		"UPDATE workflows SET updated_at = ? WHERE workflow_id = 'report-1'",
+		dbTime(time.Now().Add(-2*time.Minute)),
+	); err != nil {
+		t.Fatalf("failed to age workflow: %v", err)
+	}
+
+	if err := eng.StartWorker(WorkerConfig{Queues: []string{"reports"}, PollInterval: 10 * time.Millisecond}); err != nil {
+		t.Fatalf("failed to start worker: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if status, _ := eng.GetWorkflowStatus("report-1"); status == "completed" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the workflow")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stats, err := eng.StepQueueLatency(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("failed to get latencies: %v", err)
+	}
+	if len(stats) != 1 || stats[0].Queue != "reports" || stats[0].Steps != 3 {
+		t.Fatalf("expected the report's 3 steps, got %+v", stats)
+	}
+	if s := stats[0]; s.Max < 2*time.Minute || s.P99 != s.Max || s.P50 > time.Second {
+		t.Errorf("expected only the first step to have waited in the queue, got %+v", s)
+	}
+}
@@ -0,0 +1,294 @@
+package engine
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultQueue is the queue workflows are enqueued on unless WithQueue is given
+const DefaultQueue = "default"
+
+// DefaultPollInterval is how often a worker looks for queued workflows
+const DefaultPollInterval = time.Second
+
+// WorkflowFunc is a workflow body that can be registered by name
+type WorkflowFunc func(*Context) error
+
+// Register makes a workflow function available to workers under a stable
+// name. stepDefaults apply to every step of its runs, overriding the
+// engine's WithDefaultStepOptions.
+func (e *Engine) Register(name string, fn WorkflowFunc, stepDefaults ...StepOption) {
+	e.registryMu.Lock()
+	defer e.registryMu.Unlock()
+	e.registry[name] = fn
+	if len(stepDefaults) > 0 {
+		if e.registryDefaults == nil {
+			e.registryDefaults = make(map[string][]StepOption)
+		}
+		e.registryDefaults[name] = stepDefaults
+	} else {
+		delete(e.registryDefaults, name)
+	}
+}
+
+// lookupWorkflow returns the registered workflow function for name
+func (e *Engine) lookupWorkflow(name string) (WorkflowFunc, bool) {
+	e.registryMu.RLock()
+	defer e.registryMu.RUnlock()
+	fn, ok := e.registry[name]
+	return fn, ok
+}
+
+// registeredNames lists all registered workflow names
+func (e *Engine) registeredNames() []string {
+	e.registryMu.RLock()
+	defer e.registryMu.RUnlock()
+	names := make([]string, 0, len(e.registry))
+	for name := range e.registry {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Enqueue durably records a workflow to be run by any worker that registered
+// workflowName. The input is JSON-encoded and available via ctx.Input.
+// Enqueueing an existing workflow ID is a no-op.
+func (e *Engine) Enqueue(workflowID, workflowName string, input interface{}, opts ...WorkflowOption) error {
+	payload, err := e.codec.Marshal(input)
+	if err != nil {
+		return fmt.Errorf("failed to marshal workflow input: %w", err)
+	}
+	return e.enqueueEncoded(workflowID, workflowName, payload, opts...)
+}
+
+// enqueueEncoded enqueues a workflow whose input is already encoded with the engine's codec
+func (e *Engine) enqueueEncoded(workflowID, workflowName string, payload []byte, opts ...WorkflowOption) error {
+	o := newWorkflowOptions(opts)
+
+	if _, err := e.storage.GetWorkflowStatus(workflowID); err == nil {
+		return nil
+	} else if !errors.Is(err, ErrWorkflowNotFound) {
+		return fmt.Errorf("failed to check workflow: %w", err)
+	}
+
+	if err := e.validateInput(workflowName, payload); err != nil {
+		return err
+	}
+	if err := e.checkParams(workflowName, o.params); err != nil {
+		return err
+	}
+
+	if err := e.admit(); err != nil {
+		return err
+	}
+
+	// The row and its options commit together, so no worker can dequeue the
+	// run before its options are recorded
+	shard := ShardForWorkflow(workflowID, e.shardCount)
+	return e.storage.WithTx(func(tx StorageTx) error {
+		if err := tx.CreateQueuedWorkflow(workflowID, shard, workflowName, o.queue, o.priority, o.concurrencyKey, payload); err != nil {
+			return fmt.Errorf("failed to enqueue workflow: %w", err)
+		}
+		return e.persistStartOptions(tx.Storage, workflowID, o)
+	})
+}
+
+// Input decodes the workflow's start input into v
+func (ctx *Context) Input(v interface{}) error {
+	if len(ctx.input) == 0 {
+		return nil
+	}
+	if err := ctx.engine.codec.Unmarshal(ctx.input, v); err != nil {
+		return fmt.Errorf("failed to unmarshal workflow input: %w", err)
+	}
+	return nil
+}
+
+// dispatch polls for queued workflows in owned shards and runs up to capacity at once
+func (e *Engine) dispatch(cfg WorkerConfig) {
+	defer e.bg.Done()
+
+	capacity := cfg.Capacity
+	if capacity <= 0 {
+		capacity = 1
+	}
+	queues := cfg.Queues
+	if len(queues) == 0 {
+		queues = []string{DefaultQueue}
+	}
+
+	var inflight int64
+	ticker := time.NewTicker(cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+		}
+
+		free := capacity - int(atomic.LoadInt64(&inflight))
+		names := e.registeredNames()
+		if free <= 0 || len(names) == 0 || !e.IsActiveRegion() {
+			continue
+		}
+
+		shards, err := e.OwnedShards()
+		if err != nil {
+			fmt.Printf("[WORKER] failed to compute shards: %v\n", err)
+			continue
+		}
+
+		claimed, err := e.storage.ClaimQueuedWorkflows(e.workerID, shards, queues, names, cfg.Labels, cfg.PriorityAging, free)
+		if err != nil {
+			fmt.Printf("[WORKER] failed to claim workflows: %v\n", err)
+			continue
+		}
+
+		for _, wf := range claimed {
+			fn, ok := e.lookupWorkflow(wf.name)
+			if !ok {
+				continue
+			}
+			atomic.AddInt64(&inflight, 1)
+			e.bg.Add(1)
+			go func(wf queuedWorkflow) {
+				defer e.bg.Done()
+				defer atomic.AddInt64(&inflight, -1)
+				o := newWorkflowOptions(nil)
+				o.queuedAt = wf.queuedAt
+				if err := e.runWorkflow(wf.id, fn, o); err != nil {
+					fmt.Printf("[WORKER] workflow %s: %v\n", wf.id, err)
+				}
+			}(wf)
+		}
+	}
+}
+
+// queuedWorkflow is a claimed workflow row handed to the dispatcher
+type queuedWorkflow struct {
+	id       string
+	name     string
+	queuedAt time.Time
+}
+
+// CreateQueuedWorkflow inserts a workflow in 'queued' status, ignoring duplicates.
+// An empty concurrencyKey leaves the workflow unserialized.
+func (s *Storage) CreateQueuedWorkflow(workflowID string, shard int, workflowName, queue string, priority int, concurrencyKey string, input []byte) error {
+	now := dbNow()
+	key := sql.NullString{String: concurrencyKey, Valid: concurrencyKey != ""}
+	return s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			`INSERT OR IGNORE INTO workflows (workflow_id, status, shard, workflow_name, queue, priority, concurrency_key, input, created_at, updated_at)
+			 VALUES (?, 'queued', ?, ?, ?, ?, ?, ?, ?, ?)`,
+			workflowID, shard, workflowName, queue, priority, key, input, now, now,
+		)
+		return err
+	})
+}
+
+// ClaimQueuedWorkflows atomically moves up to limit queued workflows to running
+// for workerID, highest priority first after aging (see claimOrder). Workflows
+// handed off for required labels are claimed from any shard, provided the
+// worker has every label, and workflows whose concurrency key is held by a
+// running workflow are skipped.
+func (s *Storage) ClaimQueuedWorkflows(workerID string, shards []int, queues, names, labels []string, aging time.Duration, limit int) ([]queuedWorkflow, error) {
+	if len(queues) == 0 || len(names) == 0 {
+		return nil, nil
+	}
+
+	args := make([]interface{}, 0, len(shards)+len(queues)+len(names)+len(labels)+1)
+	for _, shard := range shards {
+		args = append(args, shard)
+	}
+	for _, q := range queues {
+		args = append(args, q)
+	}
+	for _, n := range names {
+		args = append(args, n)
+	}
+	for _, l := range labels {
+		args = append(args, l)
+	}
+	order, orderArgs := claimOrder(aging)
+	args = append(append(args, orderArgs...), limit)
+
+	rows, err := s.db.Query(
+		fmt.Sprintf(
+			`SELECT workflow_id, workflow_name, updated_at FROM workflows
+			 WHERE status = 'queued' AND (shard IN (%s) OR requires IS NOT NULL)
+			   AND queue IN (%s) AND workflow_name IN (%s)
+			   AND NOT EXISTS (SELECT 1 FROM json_each(COALESCE(requires, '[]')) WHERE value NOT IN (%s))
+			   AND %s
+			 %s LIMIT ?`,
+			placeholders(len(shards)), placeholders(len(queues)), placeholders(len(names)), placeholders(len(labels)), concurrencyKeyFree, order,
+		),
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find queued workflows: %w", err)
+	}
+
+	var candidates []queuedWorkflow
+	for rows.Next() {
+		var wf queuedWorkflow
+		if err := rows.Scan(&wf.id, &wf.name, &wf.queuedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan queued workflow: %w", err)
+		}
+		candidates = append(candidates, wf)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Another worker may claim the same row; only rows we flip ourselves are ours
+	var claimed []queuedWorkflow
+	for _, wf := range candidates {
+		var affected int64
+		err := s.retryOnBusy(func() error {
+			res, err := s.db.Exec(
+				`UPDATE workflows SET status = 'running', claimed_by = ?, version = version + 1, updated_at = ?
+				 WHERE workflow_id = ? AND status = 'queued' AND `+concurrencyKeyFree,
+				workerID, dbNow(), wf.id,
+			)
+			if err != nil {
+				return err
+			}
+			affected, err = res.RowsAffected()
+			return err
+		})
+		if err != nil {
+			return claimed, fmt.Errorf("failed to claim workflow: %w", err)
+		}
+		if affected > 0 {
+			claimed = append(claimed, wf)
+		}
+	}
+
+	return claimed, nil
+}
+
+// GetWorkflowInput returns the encoded start input of a workflow, or nil if
+// it was started without one
+func (e *Engine) GetWorkflowInput(workflowID string) ([]byte, error) {
+	return e.reads.GetWorkflowInput(workflowID)
+}
+
+// GetWorkflowInput returns the stored start input of a workflow
+func (s *Storage) GetWorkflowInput(workflowID string) ([]byte, error) {
+	var input []byte
+	err := s.db.QueryRow(
+		"SELECT input FROM workflows WHERE workflow_id = ?",
+		workflowID,
+	).Scan(&input)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get workflow input: %w", err)
+	}
+	return input, nil
+}
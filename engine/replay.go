@@ -0,0 +1,183 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// ErrReplayDiverged is returned by a replayed step the recorded history
+// doesn't have at that point, e.g. because the workflow code changed
+var ErrReplayDiverged = errors.New("replay diverged from recorded history")
+
+// ReplayStep is a step reached during a replay, before its recorded result
+// is handed back to the workflow
+type ReplayStep struct {
+	Index       int // position in the replay, from 1
+	StepID      string
+	SequenceNum int64
+	Kind        string
+	Recorded    *StepRecord // nil if the history has no such step
+}
+
+// ReplayOption configures a single Replay call
+type ReplayOption func(*replayOptions)
+
+type replayOptions struct {
+	breakpoint func(ReplayStep) error
+}
+
+// WithBreakpoint calls fn before each replayed step. Returning an error
+// stops the replay there: the step fails with that error.
+func WithBreakpoint(fn func(step ReplayStep) error) ReplayOption {
+	return func(o *replayOptions) {
+		o.breakpoint = fn
+	}
+}
+
+// ReplayResult is what a replay went through, in call order
+type ReplayResult struct {
+	WorkflowID string
+	Steps      []ReplayStep
+	Err        error // the workflow function's own return value
+}
+
+// replaySession serves recorded results to a replaying workflow
+type replaySession struct {
+	history    map[string]*StepRecord // by step key
+	breakpoint func(ReplayStep) error
+	steps      []ReplayStep
+}
+
+// Replay re-executes workflowFn locally against a run's recorded history,
+// with its recorded input, params and headers. Steps return their recorded result or
+// error instead of running and, as with Simulate, nothing is written to the
+// engine's database, so a production run can be stepped through exactly as
+// it happened. A step the history doesn't have fails with ErrReplayDiverged.
+func (e *Engine) Replay(workflowID string, workflowFn func(*Context) error, opts ...ReplayOption) (*ReplayResult, error) {
+	o := &replayOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if _, err := e.GetWorkflow(workflowID); err != nil {
+		return nil, err
+	}
+	history, err := e.reads.ListSteps(workflowID)
+	if err != nil {
+		return nil, err
+	}
+	input, err := e.reads.GetWorkflowInput(workflowID)
+	if err != nil {
+		return nil, err
+	}
+	params, err := e.reads.GetWorkflowParams(workflowID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load workflow params: %w", err)
+	}
+	headers, err := e.reads.GetWorkflowHeaders(workflowID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load workflow headers: %w", err)
+	}
+
+	scratch, err := NewStorage(":memory:")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create replay storage: %w", err)
+	}
+	defer scratch.Close()
+
+	session := &replaySession{history: make(map[string]*StepRecord, len(history)), breakpoint: o.breakpoint}
+	for i := range history {
+		session.history[history[i].StepKey] = &history[i]
+	}
+	sim := &simulation{replay: session}
+	ctx := &Context{
+		WorkflowID:     workflowID,
+		engine:         e,
+		storage:        scratch,
+		completedSteps: make(map[string][]byte),
+		stepIDToSeq:    make(map[string]int64),
+		input:          input,
+		params:         params,
+		headers:        headers,
+		sim:            sim,
+		eg:             &errgroup.Group{},
+	}
+	ctx.runCtx, ctx.cancelRun = context.WithCancel(context.Background())
+	defer ctx.cancelRun()
+	defer ctx.releaseLocks()
+
+	runErr := callWorkflow(func() error { return workflowFn(ctx) })
+
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	return &ReplayResult{WorkflowID: workflowID, Steps: session.steps, Err: runErr}, nil
+}
+
+// replayStep returns a step's recorded result in place of running it
+func replayStep[T any](ctx *Context, id, stepKey string, seqNum int64, kind string) (T, error) {
+	var zero T
+	output, err := replayStepOutput(ctx, id, stepKey, seqNum, kind)
+	if err != nil {
+		return zero, err
+	}
+
+	var result T
+	if err := ctx.engine.codec.Unmarshal(output, &result); err != nil {
+		return zero, fmt.Errorf("failed to unmarshal recorded result of %s: %w", id, err)
+	}
+	ctx.mu.Lock()
+	ctx.completedSteps[stepKey] = output
+	ctx.mu.Unlock()
+	return result, nil
+}
+
+// replayStepOutput stops at the breakpoint and returns a step's recorded
+// output as stored: encoded, or the raw bytes of a streamed step
+func replayStepOutput(ctx *Context, id, stepKey string, seqNum int64, kind string) ([]byte, error) {
+	session := ctx.sim.replay
+
+	ctx.sim.mu.Lock()
+	rec := session.history[stepKey]
+	step := ReplayStep{Index: len(session.steps) + 1, StepID: id, SequenceNum: seqNum, Kind: kind, Recorded: rec}
+	session.steps = append(session.steps, step)
+	ctx.sim.mu.Unlock()
+
+	if session.breakpoint != nil {
+		if err := session.breakpoint(step); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case rec == nil:
+		return nil, fmt.Errorf("%w: %s (sequence %d) is not in the history", ErrReplayDiverged, id, seqNum)
+	case rec.Kind != kind:
+		return nil, fmt.Errorf("%w: %s was recorded as a %s step, not %s", ErrReplayDiverged, id, rec.Kind, kind)
+	case rec.Status == "failed":
+		return nil, errors.New(rec.Error)
+	case rec.Status != "completed":
+		return nil, fmt.Errorf("%w: %s is %s in the history", ErrReplayDiverged, id, rec.Status)
+	}
+	if rec.Output != nil {
+		return rec.Output, nil
+	}
+
+	// Streamed and offloaded outputs are kept in chunks
+	r, found, err := ctx.engine.reads.OpenStepOutput(ctx.WorkflowID, stepKey)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: output of %s is gone", ErrReplayDiverged, id)
+	}
+	defer r.Close()
+	output, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recorded output of %s: %w", id, err)
+	}
+	return output, nil
+}
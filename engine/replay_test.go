@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestResumeQueriesOnlyNewSteps(t *testing.T) {
+	dbPath := "./test_replay.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	// The first execution fails at step 300 of 400
+	failAt := 300
+	workflow := func(ctx *Context) error {
+		for i := 1; i <= 400; i++ {
+			_, err := Step(ctx, fmt.Sprintf("step-%d", i), func() (int, error) {
+				if i == failAt {
+					return 0, errors.New("unavailable")
+				}
+				return i, nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := eng.Execute("replay-1", workflow); err == nil {
+		t.Fatal("expected the first execution to fail")
+	}
+
+	opCount := func(op string) int64 {
+		for _, st := range eng.StorageMetrics() {
+			if st.Op == op {
+				return st.Count
+			}
+		}
+		return 0
+	}
+	lookups, loads := opCount("GetStep"), opCount("LoadReplayState")
+
+	failAt = 0
+	if err := eng.Execute("replay-1", workflow); err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+
+	// Replayed steps, including the failed one, come from the single load;
+	// only the 100 steps past the recorded history are looked up
+	if got := opCount("GetStep") - lookups; got != 100 {
+		t.Errorf("expected 100 step lookups on resume, got %d", got)
+	}
+	if got := opCount("LoadReplayState") - loads; got != 1 {
+		t.Errorf("expected the history to be loaded once, got %d", got)
+	}
+}
+
+func TestReplayStepsThroughHistory(t *testing.T) {
+	dbPath := "./test_replay_debug.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	calls := 0
+	var totals []int
+	order := func(ctx *Context) error {
+		var items []string
+		if err := ctx.Input(&items); err != nil {
+			return err
+		}
+		total := 0
+		for _, item := range items {
+			price, err := Step(ctx, "price-"+item, func() (int, error) {
+				calls++
+				return len(item) * 10, nil
+			})
+			if err != nil {
+				return err
+			}
+			total += price
+		}
+		totals = append(totals, total)
+		_, err := Step(ctx, "charge", func() (bool, error) {
+			calls++
+			return false, errors.New("card declined")
+		})
+		return err
+	}
+	eng.Register("order", order)
+	if err := eng.Enqueue("order-1", "order", []string{"tea", "scones"}); err != nil {
+		t.Fatalf("failed to enqueue: %v", err)
+	}
+	if err := eng.Execute("order-1", order); err == nil {
+		t.Fatal("expected the charge to fail")
+	}
+	calls = 0
+
+	var paused []string
+	result, err := eng.Replay("order-1", order, WithBreakpoint(func(step ReplayStep) error {
+		paused = append(paused, fmt.Sprintf("%d:%s:%s", step.Index, step.StepID, step.Recorded.Status))
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("failed to replay: %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("expected no step bodies to run, got %d calls", calls)
+	}
+	want := []string{"1:price-tea:completed", "2:price-scones:completed", "3:charge:failed"}
+	if !reflect.DeepEqual(paused, want) {
+		t.Errorf("expected to pause at %v, got %v", want, paused)
+	}
+	if result.Err == nil || result.Err.Error() != "card declined" {
+		t.Errorf("expected the recorded failure, got %v", result.Err)
+	}
+	if totals[len(totals)-1] != 90 {
+		t.Errorf("expected the recorded prices to be replayed, got %v", totals)
+	}
+
+	// Changed code diverges from the history
+	result, err = eng.Replay("order-1", func(ctx *Context) error {
+		_, err := Step(ctx, "reserve", func() (bool, error) { return true, nil })
+		return err
+	})
+	if err != nil {
+		t.Fatalf("failed to replay: %v", err)
+	}
+	if !errors.Is(result.Err, ErrReplayDiverged) || result.Steps[0].Recorded != nil {
+		t.Errorf("expected the replay to diverge, got %v", result.Err)
+	}
+
+	// A breakpoint can stop the replay
+	stop := errors.New("stopped")
+	result, _ = eng.Replay("order-1", order, WithBreakpoint(func(step ReplayStep) error { return stop }))
+	if !errors.Is(result.Err, stop) || len(result.Steps) != 1 {
+		t.Errorf("expected the replay to stop at the first step, got %v after %d steps", result.Err, len(result.Steps))
+	}
+}
@@ -0,0 +1,38 @@
+package engine
+
+import "fmt"
+
+// WithReadReplica serves query-heavy APIs (ListWorkflows, ListWorkflowNames,
+// GetHistory, GetHistories, events, stats, error search and everything the
+// dashboard and workflowctl build on them) from a second database, keeping
+// the primary free for step writes. dsn takes the same forms as NewEngine's:
+// a LiteFS replica's path, a libSQL replica URL, or the primary's own file,
+// which gives reads a connection of their own instead of queueing behind
+// writes. Replicas may lag; workflow execution always reads the primary.
+func WithReadReplica(dsn string) EngineOption {
+	return func(e *Engine) {
+		e.replicaDSN = dsn
+	}
+}
+
+// openReadReplica opens the database reads are routed to
+func (s *Storage) openReadReplica(dsn string) (*Storage, error) {
+	ds := parseDSN(dsn)
+	db, err := ds.open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open read replica: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+	if !ds.remote {
+		if _, err := db.Exec("PRAGMA busy_timeout=5000"); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to set busy timeout on read replica: %w", err)
+		}
+		if _, err := db.Exec("PRAGMA query_only=1"); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to make read replica read-only: %w", err)
+		}
+	}
+	// Reads share the primary's metrics, so StorageMetrics covers both
+	return &Storage{db: &instrumentedDB{DB: db, metrics: s.db.metrics}, busyRetry: s.busyRetry}, nil
+}
@@ -0,0 +1,64 @@
+package engine
+
+import (
+	"os"
+	"testing"
+)
+
+func TestReadReplicaRouting(t *testing.T) {
+	dbPath, replicaPath := "./test_replica_primary.db", "./test_replica.db"
+	defer os.Remove(dbPath)
+	defer os.Remove(replicaPath)
+
+	// A replica that has not caught up: same schema, no runs yet
+	stale, err := NewEngine(replicaPath)
+	if err != nil {
+		t.Fatalf("failed to create replica: %v", err)
+	}
+	stale.Close()
+
+	engine, err := NewEngine(dbPath, WithReadReplica(replicaPath))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	if err := engine.Execute("replica-1", func(ctx *Context) error {
+		_, err := Step(ctx, "one", func() (int, error) { return 1, nil })
+		return err
+	}); err != nil {
+		t.Fatalf("workflow failed on the primary: %v", err)
+	}
+
+	// Listing and history come from the replica; point lookups from the primary
+	if runs, err := engine.ListWorkflows(WorkflowFilter{}); err != nil || len(runs) != 0 {
+		t.Errorf("expected the stale replica to list no runs, got %d: %v", len(runs), err)
+	}
+	if _, err := engine.GetHistory("replica-1"); err == nil {
+		t.Error("expected history to be read from the replica")
+	}
+	if _, err := engine.GetWorkflow("replica-1"); err != nil {
+		t.Errorf("expected GetWorkflow to read the primary, got %v", err)
+	}
+
+	// The replica connection never writes
+	if err := engine.reads.CreateWorkflow("sneaky", 0); err == nil {
+		t.Error("expected writes through the read replica to fail")
+	}
+}
+
+func TestReadReplicaOnPrimaryFile(t *testing.T) {
+	dbPath := "./test_replica_self.db"
+	defer os.Remove(dbPath)
+
+	engine, err := NewEngine(dbPath, WithReadReplica(dbPath))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	engine.Execute("self-1", func(ctx *Context) error { return nil })
+	if runs, err := engine.ListWorkflows(WorkflowFilter{}); err != nil || len(runs) != 1 {
+		t.Errorf("expected the run through the read connection, got %d: %v", len(runs), err)
+	}
+}
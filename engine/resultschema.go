@@ -0,0 +1,361 @@
+package engine
+
+import (
+	"database/sql"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrResultSchemaChanged is returned when a recorded step result can't be
+// replayed into the step's current result type (see ResultSchemaError)
+var ErrResultSchemaChanged = errors.New("step result schema changed")
+
+// ResultSchemaError reports a step whose result was recorded for a type
+// incompatible with the one the workflow now expects, e.g. after a field
+// changed from a string to a number. Both types are given as fingerprints:
+// the shape of the encoded value, like {"id":int,"tags":[string]}.
+type ResultSchemaError struct {
+	StepID   string
+	Recorded string // fingerprint of the type the result was recorded for
+	Current  string // fingerprint of the step's result type now
+	Err      error  // the decoding error, if decoding was attempted
+}
+
+// Error describes both fingerprints
+func (e *ResultSchemaError) Error() string {
+	msg := fmt.Sprintf("%v: step %s recorded a result of type %s, which can't be replayed as %s",
+		ErrResultSchemaChanged, e.StepID, e.Recorded, e.Current)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap lets errors.Is match ErrResultSchemaChanged
+func (e *ResultSchemaError) Unwrap() error {
+	return ErrResultSchemaChanged
+}
+
+// decodeResult decodes a step's recorded result into result, a pointer to
+// the step's result type. A result recorded for an incompatible type fails
+// with a *ResultSchemaError rather than a decoding error, or rather than
+// decoding into zero values.
+func (ctx *Context) decodeResult(id, stepKey string, output []byte, result interface{}, source string) error {
+	current := typeFingerprint(reflect.TypeOf(result).Elem())
+	ctx.mu.Lock()
+	recorded := ctx.outputTypes[stepKey]
+	ctx.mu.Unlock()
+
+	if recorded != "" && !fingerprintsCompatible(recorded, current) {
+		return &ResultSchemaError{StepID: id, Recorded: recorded, Current: current}
+	}
+	if err := ctx.engine.codec.Unmarshal(output, result); err != nil {
+		if recorded != "" && recorded != current {
+			return &ResultSchemaError{StepID: id, Recorded: recorded, Current: current, Err: err}
+		}
+		return fmt.Errorf("failed to unmarshal %s result: %w", source, err)
+	}
+	return nil
+}
+
+// loadOutputType loads the recorded result type of a step completed by
+// another execution since the run's history was loaded
+func (ctx *Context) loadOutputType(stepKey string) error {
+	fingerprint, err := ctx.storage.GetStepOutputType(ctx.WorkflowID, stepKey)
+	if err != nil {
+		return err
+	}
+	ctx.setOutputType(stepKey, fingerprint)
+	return nil
+}
+
+// saveOutputType returns a write recording a step's result type with its result
+func (ctx *Context) saveOutputType(stepKey, fingerprint string) func(tx StorageTx) error {
+	return func(tx StorageTx) error {
+		if err := tx.SetStepOutputType(ctx.WorkflowID, stepKey, fingerprint); err != nil {
+			return err
+		}
+		ctx.setOutputType(stepKey, fingerprint)
+		return nil
+	}
+}
+
+// setOutputType remembers a step's result type
+func (ctx *Context) setOutputType(stepKey, fingerprint string) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if ctx.outputTypes == nil {
+		ctx.outputTypes = make(map[string]string)
+	}
+	ctx.outputTypes[stepKey] = fingerprint
+}
+
+// fingerprintOf returns the fingerprint of T
+func fingerprintOf[T any]() string {
+	return typeFingerprint(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+// fingerprints caches typeFingerprint by type
+var fingerprints sync.Map
+
+// typeFingerprint describes the shape a value of type t encodes to: its
+// scalar kinds (bool, int, uint, float, string, bytes), lists [elem], maps
+// map[key]elem and objects {"field":type,...} named as encoded. Types with
+// their own encoding, interfaces and recursive types are "any".
+func typeFingerprint(t reflect.Type) string {
+	if cached, ok := fingerprints.Load(t); ok {
+		return cached.(string)
+	}
+	var b strings.Builder
+	writeFingerprint(&b, t, make(map[reflect.Type]bool))
+	fingerprints.Store(t, b.String())
+	return b.String()
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// writeFingerprint appends the fingerprint of t; seen holds the types being described
+func writeFingerprint(b *strings.Builder, t reflect.Type, seen map[reflect.Type]bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if seen[t] || t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) ||
+		reflect.PointerTo(t).Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType) {
+		b.WriteString("any")
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		b.WriteString("bool")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		b.WriteString("int")
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		b.WriteString("uint")
+	case reflect.Float32, reflect.Float64:
+		b.WriteString("float")
+	case reflect.String:
+		b.WriteString("string")
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			b.WriteString("bytes")
+			return
+		}
+		b.WriteByte('[')
+		writeFingerprint(b, t.Elem(), seen)
+		b.WriteByte(']')
+	case reflect.Map:
+		b.WriteString("map[")
+		writeFingerprint(b, t.Key(), seen)
+		b.WriteByte(']')
+		writeFingerprint(b, t.Elem(), seen)
+	case reflect.Struct:
+		seen[t] = true
+		b.WriteByte('{')
+		writeFields(b, t, seen, true)
+		b.WriteByte('}')
+		delete(seen, t)
+	default:
+		b.WriteString("any")
+	}
+}
+
+// writeFields appends the encoded fields of a struct, including those
+// promoted from embedded structs; it reports whether none was written yet
+func writeFields(b *strings.Builder, t reflect.Type, seen map[reflect.Type]bool, first bool) bool {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			first = writeFields(b, ft, seen, first)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if !first {
+			b.WriteByte(',')
+		}
+		first = false
+		b.WriteString(strconv.Quote(name))
+		b.WriteByte(':')
+		writeFingerprint(b, f.Type, seen)
+	}
+	return first
+}
+
+// shape is a parsed fingerprint
+type shape struct {
+	kind   string // a scalar kind, "any", "list", "map" or "object"
+	key    *shape
+	elem   *shape
+	fields map[string]*shape // by lowercased name, as decoding matches them
+}
+
+// compatibility caches fingerprintsCompatible by fingerprint pair
+var compatibility sync.Map
+
+// fingerprintsCompatible reports whether a value encoded from a type with
+// the recorded fingerprint decodes into one with the current fingerprint
+// without losing its meaning: fields may be added or removed, integers may
+// widen to floats, but shared fields must keep compatible types and objects
+// must keep at least one field. Unparseable fingerprints are compatible.
+func fingerprintsCompatible(recorded, current string) bool {
+	if recorded == current {
+		return true
+	}
+	pair := [2]string{recorded, current}
+	if cached, ok := compatibility.Load(pair); ok {
+		return cached.(bool)
+	}
+	r, rerr := parseFingerprint(recorded)
+	c, cerr := parseFingerprint(current)
+	ok := rerr != nil || cerr != nil || shapesCompatible(r, c)
+	compatibility.Store(pair, ok)
+	return ok
+}
+
+// shapesCompatible reports whether a value of shape r decodes into shape c
+func shapesCompatible(r, c *shape) bool {
+	if r.kind == "any" || c.kind == "any" {
+		return true
+	}
+	switch {
+	case r.kind == c.kind:
+	case (r.kind == "int" || r.kind == "uint") && (c.kind == "int" || c.kind == "uint" || c.kind == "float"):
+		return true
+	case r.kind == "object" && c.kind == "map":
+		return c.key.kind == "string" || c.key.kind == "any"
+	case r.kind == "map" && c.kind == "object":
+		return r.key.kind == "string" || r.key.kind == "any"
+	default:
+		return false
+	}
+
+	switch r.kind {
+	case "list":
+		return shapesCompatible(r.elem, c.elem)
+	case "map":
+		return shapesCompatible(r.key, c.key) && shapesCompatible(r.elem, c.elem)
+	case "object":
+		shared := 0
+		for name, cf := range c.fields {
+			if rf, ok := r.fields[name]; ok {
+				shared++
+				if !shapesCompatible(rf, cf) {
+					return false
+				}
+			}
+		}
+		return shared > 0 || len(r.fields) == 0 || len(c.fields) == 0
+	}
+	return true
+}
+
+// parseFingerprint parses a fingerprint written by typeFingerprint
+func parseFingerprint(s string) (*shape, error) {
+	sh, rest, err := parseShape(s)
+	if err == nil && rest != "" {
+		err = fmt.Errorf("unexpected %q", rest)
+	}
+	return sh, err
+}
+
+// parseShape parses the shape at the start of s and returns the rest
+func parseShape(s string) (*shape, string, error) {
+	switch {
+	case strings.HasPrefix(s, "["):
+		elem, rest, err := parseShape(s[1:])
+		if err != nil {
+			return nil, "", err
+		}
+		if !strings.HasPrefix(rest, "]") {
+			return nil, "", fmt.Errorf("unterminated list at %q", rest)
+		}
+		return &shape{kind: "list", elem: elem}, rest[1:], nil
+
+	case strings.HasPrefix(s, "map["):
+		key, rest, err := parseShape(s[4:])
+		if err != nil {
+			return nil, "", err
+		}
+		if !strings.HasPrefix(rest, "]") {
+			return nil, "", fmt.Errorf("unterminated map key at %q", rest)
+		}
+		elem, rest, err := parseShape(rest[1:])
+		if err != nil {
+			return nil, "", err
+		}
+		return &shape{kind: "map", key: key, elem: elem}, rest, nil
+
+	case strings.HasPrefix(s, "{"):
+		sh := &shape{kind: "object", fields: make(map[string]*shape)}
+		rest := s[1:]
+		for !strings.HasPrefix(rest, "}") {
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				return nil, "", fmt.Errorf("bad field name at %q", rest)
+			}
+			name, _ := strconv.Unquote(quoted)
+			rest = strings.TrimPrefix(rest[len(quoted):], ":")
+			field, after, err := parseShape(rest)
+			if err != nil {
+				return nil, "", err
+			}
+			sh.fields[strings.ToLower(name)] = field
+			rest = strings.TrimPrefix(after, ",")
+		}
+		return sh, rest[1:], nil
+	}
+
+	for _, kind := range []string{"bool", "int", "uint", "float", "string", "bytes", "any"} {
+		if strings.HasPrefix(s, kind) {
+			return &shape{kind: kind}, s[len(kind):], nil
+		}
+	}
+	return nil, "", fmt.Errorf("unknown type at %q", s)
+}
+
+// SetStepOutputType records the fingerprint of a step's result type
+func (s *Storage) SetStepOutputType(workflowID, stepKey, fingerprint string) error {
+	return s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			"UPDATE steps SET output_type = ? WHERE workflow_id = ? AND step_key = ?",
+			fingerprint, workflowID, stepKey,
+		)
+		return err
+	})
+}
+
+// GetStepOutputType loads the fingerprint of a step's result type, or ""
+// if none was recorded
+func (s *Storage) GetStepOutputType(workflowID, stepKey string) (string, error) {
+	var fingerprint sql.NullString
+	err := s.db.QueryRow(
+		"SELECT output_type FROM steps WHERE workflow_id = ? AND step_key = ?",
+		workflowID, stepKey,
+	).Scan(&fingerprint)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to get step output type: %w", err)
+	}
+	return fingerprint.String, nil
+}
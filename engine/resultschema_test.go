@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestResultSchemaDrift(t *testing.T) {
+	dbPath := "./test_resultschema.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	type order struct {
+		ID    string `json:"id"`
+		Total int    `json:"total"`
+	}
+	err = eng.Execute("schema-1", func(ctx *Context) error {
+		if _, err := Step(ctx, "load", func() (order, error) { return order{ID: "A-1", Total: 5}, nil }); err != nil {
+			return err
+		}
+		_, err := LocalStep(ctx, "count", func() (int, error) { return 3, nil })
+		return err
+	})
+	if err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+
+	steps, err := eng.storage.ListSteps("schema-1")
+	if err != nil || len(steps) != 2 {
+		t.Fatalf("expected two steps, got %+v, %v", steps, err)
+	}
+	if steps[0].OutputType != `{"id":string,"total":int}` || steps[1].OutputType != "int" {
+		t.Errorf("expected the result types recorded, got %q and %q", steps[0].OutputType, steps[1].OutputType)
+	}
+
+	// Adding a field and widening a number replay the recorded result
+	type orderV2 struct {
+		ID       string  `json:"id"`
+		Total    float64 `json:"total"`
+		Currency string  `json:"currency"`
+	}
+	ctx, err := newContext(eng, "schema-1")
+	if err != nil {
+		t.Fatalf("failed to create context: %v", err)
+	}
+	got, err := Step(ctx, "load", func() (orderV2, error) { return orderV2{}, errors.New("step ran again") })
+	if err != nil || got.ID != "A-1" || got.Total != 5 {
+		t.Errorf("expected the recorded order, got %+v, %v", got, err)
+	}
+
+	// Changing a field's type fails with both fingerprints
+	type orderV3 struct {
+		ID int `json:"id"`
+	}
+	ctx, err = newContext(eng, "schema-1")
+	if err != nil {
+		t.Fatalf("failed to create context: %v", err)
+	}
+	_, err = Step(ctx, "load", func() (orderV3, error) { return orderV3{}, nil })
+	var schemaErr *ResultSchemaError
+	if !errors.Is(err, ErrResultSchemaChanged) || !errors.As(err, &schemaErr) {
+		t.Fatalf("expected ErrResultSchemaChanged, got %v", err)
+	}
+	if schemaErr.StepID != "load" || schemaErr.Recorded != `{"id":string,"total":int}` || schemaErr.Current != `{"id":int}` {
+		t.Errorf("expected both fingerprints, got %+v", schemaErr)
+	}
+	if !strings.Contains(err.Error(), `{"id":int}`) {
+		t.Errorf("expected the error to show the current type, got %v", err)
+	}
+
+	_, err = LocalStep(ctx, "count", func() (string, error) { return "", nil })
+	if !errors.Is(err, ErrResultSchemaChanged) {
+		t.Errorf("expected ErrResultSchemaChanged for the local step, got %v", err)
+	}
+}
+
+func TestFingerprintsCompatible(t *testing.T) {
+	for _, tc := range []struct {
+		recorded, current string
+		want              bool
+	}{
+		{`{"id":string}`, `{"ID":string,"name":string}`, true},
+		{`{"id":string}`, `{"name":string}`, false},
+		{`{}`, `{"name":string}`, true},
+		{`[int]`, `[float]`, true},
+		{`[float]`, `[int]`, false},
+		{`map[string]int`, `{"a":int}`, true},
+		{`map[int]string`, `{"a":string}`, false},
+		{`{"at":any}`, `{"at":string}`, true},
+		{`string`, `bytes`, false},
+		{`not a fingerprint`, `int`, true},
+	} {
+		if got := fingerprintsCompatible(tc.recorded, tc.current); got != tc.want {
+			t.Errorf("fingerprintsCompatible(%s, %s) = %v, want %v", tc.recorded, tc.current, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrRetryBudgetExhausted is returned when a workflow run has used up its retry budget
+var ErrRetryBudgetExhausted = errors.New("workflow retry budget exhausted")
+
+// RetryPolicy controls how a failing step is retried within a run.
+// Delays grow from InitialInterval by Multiplier up to MaxInterval, and each
+// delay is randomized by +/- Jitter (a fraction in [0,1]) so that many
+// workflows retrying the same dependency don't stampede in lockstep.
+type RetryPolicy struct {
+	MaxAttempts     int
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Multiplier      float64
+	Jitter          float64
+}
+
+// RetryBudget caps retries across all steps of one workflow run.
+// Zero fields are unlimited.
+type RetryBudget struct {
+	MaxRetries   int
+	MaxRetryTime time.Duration
+}
+
+// WithRetry retries the step according to policy before giving up
+func WithRetry(policy RetryPolicy) StepOption {
+	return func(o *stepOptions) {
+		o.retry = &policy
+	}
+}
+
+// WithRetryBudget caps the total retries (count and wall time) a workflow run
+// may spend. The budget is stored with the run, and the retries recorded in
+// its step attempts count against it, so a run resumed by another process,
+// whether by Execute, a worker or the orphan scanner, keeps spending the same
+// budget. Retries of local steps are not recorded and only count within one
+// execution.
+func WithRetryBudget(budget RetryBudget) WorkflowOption {
+	return func(o *workflowOptions) {
+		o.retryBudget = &budget
+	}
+}
+
+// backoff returns the jittered delay to wait after the given failed attempt (1-based)
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	initial := p.InitialInterval
+	if initial <= 0 {
+		initial = 100 * time.Millisecond
+	}
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+
+	delay := float64(initial) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxInterval > 0 && delay > float64(p.MaxInterval) {
+		delay = float64(p.MaxInterval)
+	}
+
+	if p.Jitter > 0 {
+		jitter := math.Min(p.Jitter, 1)
+		delay *= 1 + jitter*(2*rand.Float64()-1)
+	}
+
+	return time.Duration(delay)
+}
+
+// retryBudgetState tracks budget consumption for a single run
+type retryBudgetState struct {
+	budget    RetryBudget
+	mu        sync.Mutex
+	retries   int
+	firstUsed time.Time
+}
+
+// loadRetryBudget returns the run's stored retry budget with the retries its
+// executions so far have spent, or nil if the run has no budget
+func (e *Engine) loadRetryBudget(workflowID string) (*retryBudgetState, error) {
+	budget, err := e.storage.GetWorkflowRetryBudget(workflowID)
+	if err != nil || budget == nil {
+		return nil, err
+	}
+	retries, firstUsed, err := e.storage.CountRetries(workflowID)
+	if err != nil {
+		return nil, err
+	}
+	return &retryBudgetState{budget: *budget, retries: retries, firstUsed: firstUsed}, nil
+}
+
+// storedRetryBudget is the JSON form of a RetryBudget in the workflows table
+type storedRetryBudget struct {
+	MaxRetries     int   `json:"max_retries,omitempty"`
+	MaxRetryTimeMs int64 `json:"max_retry_time_ms,omitempty"`
+}
+
+// SetWorkflowRetryBudget stores the retry budget of a workflow unless it already has one
+func (s *Storage) SetWorkflowRetryBudget(workflowID string, budget RetryBudget) error {
+	data, err := json.Marshal(storedRetryBudget{MaxRetries: budget.MaxRetries, MaxRetryTimeMs: budget.MaxRetryTime.Milliseconds()})
+	if err != nil {
+		return err
+	}
+	return s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			"UPDATE workflows SET retry_budget = COALESCE(retry_budget, ?) WHERE workflow_id = ?",
+			string(data), workflowID,
+		)
+		return err
+	})
+}
+
+// GetWorkflowRetryBudget loads the retry budget of a workflow, or nil if it has none
+func (s *Storage) GetWorkflowRetryBudget(workflowID string) (*RetryBudget, error) {
+	var data sql.NullString
+	err := s.db.QueryRow(
+		"SELECT retry_budget FROM workflows WHERE workflow_id = ?",
+		workflowID,
+	).Scan(&data)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get retry budget: %w", err)
+	}
+	if !data.Valid {
+		return nil, nil
+	}
+
+	var stored storedRetryBudget
+	if err := json.Unmarshal([]byte(data.String), &stored); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal retry budget: %w", err)
+	}
+	return &RetryBudget{MaxRetries: stored.MaxRetries, MaxRetryTime: time.Duration(stored.MaxRetryTimeMs) * time.Millisecond}, nil
+}
+
+// CountRetries returns how many step attempts of a workflow followed an
+// earlier attempt of the same step, and when the first of those retries was
+// decided: the end of the attempt it followed
+func (s *Storage) CountRetries(workflowID string) (int, time.Time, error) {
+	var retries int
+	var first sql.NullString
+	err := s.db.QueryRow(
+		`SELECT COUNT(*), MIN(STRFTIME('%Y-%m-%d %H:%M:%f', COALESCE(p.completed_at, a.started_at)))
+		 FROM step_attempts a JOIN step_attempts p
+		   ON p.workflow_id = a.workflow_id AND p.step_key = a.step_key AND p.attempt = a.attempt - 1
+		 WHERE a.workflow_id = ?`,
+		workflowID,
+	).Scan(&retries, &first)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to count retries: %w", err)
+	}
+	if !first.Valid {
+		return retries, time.Time{}, nil
+	}
+	firstUsed, err := time.Parse(timestampLayout, first.String)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to parse retry time %q: %w", first.String, err)
+	}
+	return retries, firstUsed, nil
+}
+
+// spend reserves one retry with the given delay, failing if the budget would be exceeded
+func (b *retryBudgetState) spend(delay time.Duration) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.budget.MaxRetries > 0 && b.retries >= b.budget.MaxRetries {
+		return fmt.Errorf("%w: %d retries used", ErrRetryBudgetExhausted, b.retries)
+	}
+
+	now := time.Now()
+	if b.firstUsed.IsZero() {
+		b.firstUsed = now
+	}
+	if b.budget.MaxRetryTime > 0 && now.Add(delay).Sub(b.firstUsed) > b.budget.MaxRetryTime {
+		return fmt.Errorf("%w: %v spent retrying", ErrRetryBudgetExhausted, now.Sub(b.firstUsed).Round(time.Millisecond))
+	}
+
+	b.retries++
+	return nil
+}
+
+// left returns the retries and retry time the budget still allows, or -1
+// for limits it doesn't set
+func (b *retryBudgetState) left() RetryBudgetLeft {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	left := RetryBudgetLeft{Retries: -1, Time: -1}
+	if b.budget.MaxRetries > 0 {
+		left.Retries = max(b.budget.MaxRetries-b.retries, 0)
+	}
+	if b.budget.MaxRetryTime > 0 {
+		left.Time = b.budget.MaxRetryTime
+		if !b.firstUsed.IsZero() {
+			left.Time = max(b.budget.MaxRetryTime-time.Since(b.firstUsed), 0)
+		}
+	}
+	return left
+}
+
+// stepStop ends a step's retry loop early, when the step times out. Once
+// stop returns, the loop starts no further attempt.
+type stepStop struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	mu     sync.Mutex // held by the retry loop while it starts an attempt
+}
+
+// newStepStop returns a stepStop that also stops the loop when parent is done
+func newStepStop(parent context.Context) *stepStop {
+	c, cancel := context.WithCancelCause(parent)
+	return &stepStop{ctx: c, cancel: cancel}
+}
+
+// stop cancels the loop with cause and waits for an attempt being started
+func (s *stepStop) stop(cause error) {
+	s.cancel(cause)
+	s.mu.Lock()
+	s.mu.Unlock()
+}
+
+// begin is called before each attempt; it reports false once the loop is
+// stopped, and otherwise holds off stop until end
+func (s *stepStop) begin() bool {
+	if s == nil {
+		return true
+	}
+	s.mu.Lock()
+	if s.ctx.Err() != nil {
+		s.mu.Unlock()
+		return false
+	}
+	return true
+}
+
+// end is called once an attempt has started
+func (s *stepStop) end() {
+	if s != nil {
+		s.mu.Unlock()
+	}
+}
+
+// done is closed when the loop is stopped; nil for a loop that can't be
+func (s *stepStop) done() <-chan struct{} {
+	if s == nil {
+		return nil
+	}
+	return s.ctx.Done()
+}
+
+// stopped reports why the loop was stopped, or nil while it wasn't
+func (s *stepStop) stopped() error {
+	if s == nil || s.ctx.Err() == nil {
+		return nil
+	}
+	return context.Cause(s.ctx)
+}
+
+// executeWithRetry runs fn, retrying per policy while the run's budget allows.
+// Every attempt is recorded in the step's attempt history. Canceling the
+// workflow, or stopping stop (which may be nil), abandons any remaining retries.
+func executeWithRetry[T any](ctx *Context, stop *stepStop, id, stepKey string, policy *RetryPolicy, fn func() (T, error)) (T, error) {
+	var zero T
+
+	for attempt := 1; ; attempt++ {
+		if err := ctx.doneErr(); err != nil {
+			return zero, err
+		}
+		if err := ctx.engine.awaitCircuit(ctx, stop, id); err != nil {
+			return zero, err
+		}
+
+		if !stop.begin() {
+			if err := ctx.doneErr(); err != nil {
+				return zero, err
+			}
+			return zero, stop.stopped()
+		}
+		recorded, err := ctx.storage.StartStepAttempt(ctx.WorkflowID, stepKey, ctx.engine.executor)
+		if err != nil {
+			stop.end()
+			return zero, err
+		}
+		ctx.mu.Lock()
+		if ctx.attempts == nil {
+			ctx.attempts = make(map[string]int)
+		}
+		ctx.attempts[stepKey]++
+		if ctx.attemptNums == nil {
+			ctx.attemptNums = make(map[string]int)
+		}
+		ctx.attemptNums[stepKey] = recorded
+		ctx.mu.Unlock()
+		stop.end()
+		result, err := callStep(fn)
+		err = ctx.stoppedStepError(err)
+		if stopErr := stop.stopped(); err != nil && stopErr != nil && !errors.Is(err, ErrWorkflowCanceled) && !errors.Is(err, ErrWorkflowTimeout) {
+			err = fmt.Errorf("%w (step stopped with: %v)", stopErr, err)
+		}
+		ctx.storage.FinishStepAttempt(ctx.WorkflowID, stepKey, recorded, err)
+		// A step timeout stops the loop, so stop.stopped covers ErrStepTimeout
+		abandoned := err != nil && (errors.Is(err, ErrWorkflowCanceled) || errors.Is(err, ErrWorkflowTimeout) || stop.stopped() != nil)
+		ctx.engine.recordCircuit(id, err, abandoned)
+		if err == nil {
+			return result, nil
+		}
+		if abandoned {
+			return zero, err
+		}
+
+		if policy == nil || attempt >= policy.MaxAttempts {
+			return zero, err
+		}
+
+		delay := policy.backoff(attempt)
+		if budgetErr := ctx.retryBudget.spend(delay); budgetErr != nil {
+			return zero, fmt.Errorf("%w (last error: %v)", budgetErr, err)
+		}
+
+		ctx.printf("[RETRY] %s attempt %d failed: %v (retrying in %v)\n", id, attempt, err, delay.Round(time.Millisecond))
+		ctx.engine.emit(EngineEvent{Type: EventStepRetrying, WorkflowID: ctx.WorkflowID, StepID: id, StepKey: stepKey, Attempt: recorded, Err: err, Duration: delay})
+		select {
+		case <-ctx.engine.stop:
+			return zero, err
+		case <-ctx.Done():
+			return zero, ctx.doneErr()
+		case <-stop.done():
+			return zero, fmt.Errorf("%w (last error: %v)", stop.stopped(), err)
+		case <-time.After(delay):
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStepRetryAndWorkflowBudget(t *testing.T) {
+	dbPath := "./test_retry.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	policy := RetryPolicy{MaxAttempts: 5, InitialInterval: time.Millisecond, Jitter: 0.5}
+
+	// Flaky step succeeds on its third attempt
+	attempts := 0
+	err = eng.Execute("retry-ok", func(ctx *Context) error {
+		_, err := Step(ctx, "flaky", func() (string, error) {
+			attempts++
+			if attempts < 3 {
+				return "", errors.New("transient")
+			}
+			return "ok", nil
+		}, WithRetry(policy))
+		return err
+	})
+	if err != nil || attempts != 3 {
+		t.Fatalf("expected success on attempt 3, got attempts=%d err=%v", attempts, err)
+	}
+
+	// Budget of 2 retries is shared across both steps of the run
+	calls := 0
+	err = eng.Execute("retry-budget", func(ctx *Context) error {
+		for _, id := range []string{"a", "b"} {
+			if _, err := Step(ctx, id, func() (int, error) {
+				calls++
+				return 0, errors.New("down")
+			}, WithRetry(policy)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, WithRetryBudget(RetryBudget{MaxRetries: 2}))
+
+	if !errors.Is(err, ErrRetryBudgetExhausted) {
+		t.Fatalf("expected ErrRetryBudgetExhausted, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 1 attempt + 2 budgeted retries, got %d calls", calls)
+	}
+}
+
+func TestRetryBudgetSurvivesResume(t *testing.T) {
+	dbPath := "./test_retry_budget_resume.db"
+	defer os.Remove(dbPath)
+
+	policy := RetryPolicy{MaxAttempts: 5, InitialInterval: time.Millisecond}
+	var flakyCalls, downCalls int
+	workflow := func(resumed bool) func(ctx *Context) error {
+		return func(ctx *Context) error {
+			if _, err := Step(ctx, "flaky", func() (int, error) {
+				if flakyCalls++; flakyCalls < 3 {
+					return 0, errors.New("transient")
+				}
+				return 1, nil
+			}, WithRetry(policy)); err != nil {
+				return err
+			}
+			if !resumed {
+				return ctx.Sleep("wait", time.Hour)
+			}
+			_, err := Step(ctx, "down", func() (int, error) {
+				downCalls++
+				return 0, errors.New("down")
+			}, WithRetry(policy))
+			return err
+		}
+	}
+
+	// Two of three retries are spent before the process "crashes"
+	eng1, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- eng1.Execute("budget-resume", workflow(false), WithRetryBudget(RetryBudget{MaxRetries: 3}))
+	}()
+	time.Sleep(100 * time.Millisecond)
+	eng1.Close()
+	<-done
+
+	// The resume isn't given the budget, and may retry once more
+	eng2, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to reopen engine: %v", err)
+	}
+	defer eng2.Close()
+
+	err = eng2.Execute("budget-resume", workflow(true))
+	if !errors.Is(err, ErrRetryBudgetExhausted) {
+		t.Fatalf("expected ErrRetryBudgetExhausted, got %v", err)
+	}
+	if flakyCalls != 3 || downCalls != 2 {
+		t.Errorf("expected 3 flaky calls and 1 attempt + 1 retry of down, got %d and %d", flakyCalls, downCalls)
+	}
+}
+
+func TestRetryBackoffJitterBounds(t *testing.T) {
+	p := RetryPolicy{InitialInterval: 100 * time.Millisecond, Multiplier: 2, MaxInterval: time.Second, Jitter: 0.2}
+	for i := 0; i < 100; i++ {
+		d := p.backoff(3) // 400ms +/- 20%
+		if d < 320*time.Millisecond || d > 480*time.Millisecond {
+			t.Fatalf("backoff %v outside jitter bounds", d)
+		}
+	}
+	if d := (RetryPolicy{InitialInterval: time.Second, MaxInterval: 2 * time.Second}).backoff(10); d != 2*time.Second {
+		t.Errorf("expected backoff capped at MaxInterval, got %v", d)
+	}
+}
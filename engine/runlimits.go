@@ -0,0 +1,151 @@
+package engine
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrRunLimitExceeded is returned when a run goes over one of its RunLimits
+var ErrRunLimitExceeded = errors.New("run limit exceeded")
+
+// continueAsNewHint is appended to run limit errors
+const continueAsNewHint = "long-lived workflows should continue as new: finish this run and start a fresh one " +
+	"with the state it needs, e.g. WithOnComplete or Enqueue from a final step"
+
+// RunLimits bound the history a single run may build up, so a runaway loop
+// fails its run instead of growing the database without bound. Zero fields
+// are unlimited.
+type RunLimits struct {
+	MaxSteps        int           // steps a run may start, counted across resumes
+	MaxHistoryBytes int64         // total encoded output of the run's steps
+	MaxDuration     time.Duration // time since the workflow was created
+}
+
+// WithRunLimits sets the default limits of every run
+func WithRunLimits(limits RunLimits) EngineOption {
+	return func(e *Engine) {
+		e.runLimits = limits
+	}
+}
+
+// WithWorkflowRunLimits overrides the engine's run limits for one workflow;
+// only its non-zero fields replace the engine's
+func WithWorkflowRunLimits(limits RunLimits) WorkflowOption {
+	return func(o *workflowOptions) {
+		o.runLimits = &limits
+	}
+}
+
+// merge returns l with the non-zero fields of override applied
+func (l RunLimits) merge(override *RunLimits) RunLimits {
+	if override == nil {
+		return l
+	}
+	if override.MaxSteps > 0 {
+		l.MaxSteps = override.MaxSteps
+	}
+	if override.MaxHistoryBytes > 0 {
+		l.MaxHistoryBytes = override.MaxHistoryBytes
+	}
+	if override.MaxDuration > 0 {
+		l.MaxDuration = override.MaxDuration
+	}
+	return l
+}
+
+// runLimitState tracks a run's usage against its limits
+type runLimitState struct {
+	limits  RunLimits
+	mu      sync.Mutex
+	steps   int
+	bytes   int64
+	created time.Time
+}
+
+// initRunLimits loads the usage earlier executions of the run left behind,
+// if the run has any limits
+func (ctx *Context) initRunLimits(limits RunLimits) error {
+	if limits == (RunLimits{}) {
+		return nil
+	}
+	steps, bytes, created, err := ctx.storage.GetRunUsage(ctx.WorkflowID)
+	if err != nil {
+		return err
+	}
+	ctx.limits = &runLimitState{limits: limits, steps: steps, bytes: bytes, created: created}
+	return nil
+}
+
+// startStepWithinLimits counts a step about to run, failing if the run is
+// already at one of its limits. Steps an earlier execution started are
+// already counted.
+func (ctx *Context) startStepWithinLimits(stepID string, seqNum int64) error {
+	l := ctx.limits
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	isNew := seqNum > ctx.prefetchedSeq
+	switch {
+	case isNew && l.limits.MaxSteps > 0 && l.steps >= l.limits.MaxSteps:
+		return ctx.runLimitErr("%s would be step %d, limit is %d", stepID, l.steps+1, l.limits.MaxSteps)
+	case l.limits.MaxHistoryBytes > 0 && l.bytes >= l.limits.MaxHistoryBytes:
+		return ctx.runLimitErr("history is %d bytes, limit is %d", l.bytes, l.limits.MaxHistoryBytes)
+	case l.limits.MaxDuration > 0 && !l.created.IsZero() && time.Since(l.created) > l.limits.MaxDuration:
+		return ctx.runLimitErr("running for %v, limit is %v", time.Since(l.created).Round(time.Second), l.limits.MaxDuration)
+	}
+	if isNew {
+		l.steps++
+	}
+	return nil
+}
+
+// addHistoryBytes counts a step's output toward the run's history size,
+// failing instead if it would take the run over its limit
+func (ctx *Context) addHistoryBytes(stepID string, output []byte) error {
+	l := ctx.limits
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	total := l.bytes + int64(len(output))
+	if l.limits.MaxHistoryBytes > 0 && total > l.limits.MaxHistoryBytes {
+		return ctx.runLimitErr("output of %s takes history to %d bytes, limit is %d", stepID, total, l.limits.MaxHistoryBytes)
+	}
+	l.bytes = total
+	return nil
+}
+
+// runLimitErr builds an ErrRunLimitExceeded error with the continue-as-new hint
+func (ctx *Context) runLimitErr(format string, args ...interface{}) error {
+	fmt.Printf("[LIMIT] %s: %s\n", ctx.WorkflowID, fmt.Sprintf(format, args...))
+	return fmt.Errorf("%w: %s; %s", ErrRunLimitExceeded, fmt.Sprintf(format, args...), continueAsNewHint)
+}
+
+// GetRunUsage returns how many steps a workflow has started, the total size
+// of their outputs, including offloaded ones, and when it was created
+func (s *Storage) GetRunUsage(workflowID string) (int, int64, time.Time, error) {
+	var steps int
+	var bytes int64
+	var created sql.NullTime
+	err := s.db.QueryRow(
+		`SELECT COUNT(s.id),
+		        COALESCE(SUM(COALESCE(LENGTH(b.data), LENGTH(s.output),
+		          (SELECT SUM(LENGTH(c.data)) FROM blob_chunks c WHERE c.blob_id = b.id), 0)), 0),
+		        (SELECT created_at FROM workflows WHERE workflow_id = ?)
+		 FROM steps s LEFT JOIN blobs b ON b.id = s.output_blob
+		 WHERE s.workflow_id = ?`,
+		workflowID, workflowID,
+	).Scan(&steps, &bytes, &created)
+	if err != nil {
+		return 0, 0, time.Time{}, fmt.Errorf("failed to get run usage: %w", err)
+	}
+	return steps, bytes, created.Time, nil
+}
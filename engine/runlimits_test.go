@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunLimitsMaxStepsStopsRunawayLoop(t *testing.T) {
+	dbPath := "./test_run_limits_steps.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath, WithRunLimits(RunLimits{MaxSteps: 5}))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	executed := 0
+	err = eng.Execute("runaway", func(ctx *Context) error {
+		for i := 0; ; i++ {
+			if _, err := Step(ctx, fmt.Sprintf("poll-%d", i), func() (int, error) {
+				executed++
+				return i, nil
+			}); err != nil {
+				return err
+			}
+		}
+	})
+	if !errors.Is(err, ErrRunLimitExceeded) {
+		t.Fatalf("expected ErrRunLimitExceeded, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "continue as new") {
+		t.Errorf("expected a continue-as-new hint, got %v", err)
+	}
+	if executed != 5 {
+		t.Errorf("expected 5 steps to run, ran %d", executed)
+	}
+	if status, _ := eng.GetWorkflowStatus("runaway"); status != "failed" {
+		t.Errorf("expected run to be failed, got %s", status)
+	}
+}
+
+func TestRunLimitsCountHistoryAcrossResumes(t *testing.T) {
+	dbPath := "./test_run_limits_history.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	payload := strings.Repeat("x", 100)
+	crash := true
+	workflow := func(ctx *Context) error {
+		for i := 0; i < 3; i++ {
+			if _, err := Step(ctx, fmt.Sprintf("fetch-%d", i), func() (string, error) {
+				return payload, nil
+			}); err != nil {
+				return err
+			}
+			if crash {
+				return errors.New("worker crashed")
+			}
+		}
+		return nil
+	}
+
+	// The first execution stores one output and fails without limits
+	if err := eng.Execute("history", workflow); err == nil {
+		t.Fatal("expected first execution to fail")
+	}
+	crash = false
+	if err := eng.storage.UpdateWorkflowStatus("history", "running"); err != nil {
+		t.Fatalf("failed to reset status: %v", err)
+	}
+
+	// The resumed run starts with the stored output already counted
+	err = eng.Execute("history", workflow, WithWorkflowRunLimits(RunLimits{MaxHistoryBytes: 250}))
+	if !errors.Is(err, ErrRunLimitExceeded) {
+		t.Fatalf("expected ErrRunLimitExceeded, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "fetch-2") {
+		t.Errorf("expected the third output to exceed the limit, got %v", err)
+	}
+}
+
+func TestRunLimitsMaxDuration(t *testing.T) {
+	dbPath := "./test_run_limits_duration.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath, WithRunLimits(RunLimits{MaxDuration: 50 * time.Millisecond}))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	err = eng.Execute("slow", func(ctx *Context) error {
+		if _, err := Step(ctx, "wait", func() (bool, error) {
+			time.Sleep(1100 * time.Millisecond)
+			return true, nil
+		}); err != nil {
+			return err
+		}
+		_, err := Step(ctx, "next", func() (bool, error) { return true, nil })
+		return err
+	})
+	if !errors.Is(err, ErrRunLimitExceeded) {
+		t.Fatalf("expected ErrRunLimitExceeded, got %v", err)
+	}
+}
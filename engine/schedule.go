@@ -0,0 +1,478 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// OverlapPolicy decides what happens when a schedule fires while its previous run is still active
+type OverlapPolicy string
+
+const (
+	// OverlapSkip drops the new fire
+	OverlapSkip OverlapPolicy = "skip"
+	// OverlapBuffer remembers one pending fire and starts it when the previous run finishes
+	OverlapBuffer OverlapPolicy = "buffer"
+	// OverlapCancelPrevious cancels the active run and starts the new one
+	OverlapCancelPrevious OverlapPolicy = "cancel_previous"
+	// OverlapAllowAll starts the new run alongside the active one
+	OverlapAllowAll OverlapPolicy = "allow_all"
+)
+
+// CatchUpPolicy decides which missed fires run after scheduler downtime
+type CatchUpPolicy string
+
+const (
+	// CatchUpSkip only fires occurrences that are late by less than CatchUpWindow
+	CatchUpSkip CatchUpPolicy = "skip"
+	// CatchUpLatest fires only the most recent missed occurrence
+	CatchUpLatest CatchUpPolicy = "latest"
+	// CatchUpAll fires every missed occurrence, capped at the MaxCatchUp most recent
+	CatchUpAll CatchUpPolicy = "all"
+)
+
+// Default schedule settings
+const (
+	DefaultCatchUpWindow = time.Minute
+	DefaultMaxCatchUp    = 10
+)
+
+// Schedule starts a registered workflow on a cron or calendar expression.
+// Fire times are computed in Timezone (an IANA name, UTC when empty).
+type Schedule struct {
+	ID           string
+	Cron         string
+	Calendar     string
+	Timezone     string
+	WorkflowName string
+	Input        interface{}
+	Queue        string
+
+	Overlap       OverlapPolicy
+	CatchUp       CatchUpPolicy
+	CatchUpWindow time.Duration
+	MaxCatchUp    int
+	Paused        bool
+
+	// Maintained by the scheduler
+	NextFire       time.Time
+	LastWorkflowID string
+	BufferedFire   time.Time
+}
+
+// scheduleRecord is a schedule as stored, with the input still encoded
+type scheduleRecord struct {
+	Schedule
+	input []byte
+}
+
+// ScheduledWorkflowID returns the deterministic workflow ID for a schedule occurrence
+func ScheduledWorkflowID(scheduleID string, fireTime time.Time) string {
+	return fmt.Sprintf("%s-%s", scheduleID, fireTime.UTC().Format("20060102T150405Z"))
+}
+
+// spec parses the schedule's fire-time expression
+func (s Schedule) spec() (ScheduleSpec, error) {
+	return ParseScheduleSpec(s.Cron, s.Calendar, s.Timezone)
+}
+
+// BackfillWorkflowID returns the deterministic workflow ID of a backfilled occurrence.
+// It differs from ScheduledWorkflowID so backfills never collide with (or are
+// skipped because of) the original, possibly failed, run of the same occurrence.
+func BackfillWorkflowID(scheduleID string, fireTime time.Time) string {
+	return fmt.Sprintf("%s-backfill-%s", scheduleID, fireTime.UTC().Format("20060102T150405Z"))
+}
+
+// MaxBackfillRuns bounds how many runs a single Backfill call may start
+const MaxBackfillRuns = 10000
+
+// CreateSchedule creates or replaces a schedule; the first fire is the next match
+func (e *Engine) CreateSchedule(s Schedule) error {
+	spec, err := s.spec()
+	if err != nil {
+		return err
+	}
+	if s.ID == "" || s.WorkflowName == "" {
+		return fmt.Errorf("schedule requires an ID and a workflow name")
+	}
+	if s.Queue == "" {
+		s.Queue = DefaultQueue
+	}
+	if s.Overlap == "" {
+		s.Overlap = OverlapSkip
+	}
+	if s.CatchUp == "" {
+		s.CatchUp = CatchUpSkip
+	}
+	if s.CatchUpWindow <= 0 {
+		s.CatchUpWindow = DefaultCatchUpWindow
+	}
+	if s.MaxCatchUp <= 0 {
+		s.MaxCatchUp = DefaultMaxCatchUp
+	}
+
+	input, err := e.codec.Marshal(s.Input)
+	if err != nil {
+		return fmt.Errorf("failed to marshal schedule input: %w", err)
+	}
+
+	s.NextFire = spec.Next(time.Now())
+	return e.storage.UpsertSchedule(&scheduleRecord{Schedule: s, input: input})
+}
+
+// ListSchedules returns all schedules
+func (e *Engine) ListSchedules() ([]Schedule, error) {
+	records, err := e.storage.ListSchedules()
+	if err != nil {
+		return nil, err
+	}
+	schedules := make([]Schedule, len(records))
+	for i, r := range records {
+		schedules[i] = r.Schedule
+		schedules[i].Input = json.RawMessage(r.input)
+	}
+	return schedules, nil
+}
+
+// Backfill enqueues one run for every occurrence of the schedule in [from, to].
+// Workflow IDs are deterministic, so repeating a backfill does not start
+// duplicates. It returns the workflow IDs of the window's runs.
+func (e *Engine) Backfill(scheduleID string, from, to time.Time) ([]string, error) {
+	r, err := e.storage.GetSchedule(scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := r.spec()
+	if err != nil {
+		return nil, err
+	}
+
+	var fireTimes []time.Time
+	for t := spec.Next(from.Add(-time.Nanosecond)); !t.IsZero() && !t.After(to); t = spec.Next(t) {
+		fireTimes = append(fireTimes, t)
+		if len(fireTimes) > MaxBackfillRuns {
+			return nil, fmt.Errorf("backfill window for %s exceeds %d runs", scheduleID, MaxBackfillRuns)
+		}
+	}
+
+	ids := make([]string, 0, len(fireTimes))
+	for _, t := range fireTimes {
+		id := BackfillWorkflowID(scheduleID, t)
+		if err := e.enqueueEncoded(id, r.WorkflowName, r.input, WithQueue(r.Queue)); err != nil {
+			return ids, fmt.Errorf("failed to backfill %s: %w", id, err)
+		}
+		ids = append(ids, id)
+	}
+
+	fmt.Printf("[SCHEDULER] %s backfilled %d runs from %s to %s\n", scheduleID, len(ids),
+		from.Format(time.RFC3339), to.Format(time.RFC3339))
+	return ids, nil
+}
+
+// DeleteSchedule removes a schedule; runs it already started are unaffected
+func (e *Engine) DeleteSchedule(scheduleID string) error {
+	return e.storage.DeleteSchedule(scheduleID)
+}
+
+// StartScheduler fires due schedules on the elected leader
+func (e *Engine) StartScheduler(pollInterval time.Duration) {
+	if pollInterval <= 0 {
+		pollInterval = time.Second
+	}
+
+	e.RunSingleton("scheduler", 0, func(ctx context.Context) {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			if err := e.runSchedules(time.Now()); err != nil {
+				fmt.Printf("[SCHEDULER] %v\n", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+// runSchedules processes every schedule once
+func (e *Engine) runSchedules(now time.Time) error {
+	records, err := e.storage.ListSchedules()
+	if err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		if r.Paused {
+			continue
+		}
+		if err := e.tickSchedule(r, now); err != nil {
+			fmt.Printf("[SCHEDULER] schedule %s: %v\n", r.ID, err)
+		}
+	}
+	return nil
+}
+
+// tickSchedule fires the due occurrences of one schedule according to its policies
+func (e *Engine) tickSchedule(r *scheduleRecord, now time.Time) error {
+	spec, err := r.spec()
+	if err != nil {
+		return err
+	}
+
+	// Start a buffered fire once the previous run has finished
+	if !r.BufferedFire.IsZero() {
+		active, err := e.scheduleRunActive(r)
+		if err != nil {
+			return err
+		}
+		if !active {
+			if err := e.fireSchedule(r, r.BufferedFire); err != nil {
+				return err
+			}
+			r.BufferedFire = time.Time{}
+		}
+	}
+
+	// Collect occurrences that came due since the last tick
+	var due []time.Time
+	next := r.NextFire
+	for !next.IsZero() && !next.After(now) {
+		due = append(due, next)
+		next = spec.Next(next)
+	}
+	due = applyCatchUp(r.Schedule, due, now)
+
+	for _, fireTime := range due {
+		active, err := e.scheduleRunActive(r)
+		if err != nil {
+			return err
+		}
+
+		if active {
+			switch r.Overlap {
+			case OverlapSkip:
+				fmt.Printf("[SCHEDULER] %s: skipping %s, %s still active\n", r.ID, fireTime.Format(time.RFC3339), r.LastWorkflowID)
+				continue
+			case OverlapBuffer:
+				r.BufferedFire = fireTime
+				continue
+			case OverlapCancelPrevious:
+				if err := e.CancelWorkflow(r.LastWorkflowID); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := e.fireSchedule(r, fireTime); err != nil {
+			return err
+		}
+	}
+
+	// Only the firing state is written back, so an edit made while this tick
+	// ran keeps its definition, and a deleted schedule stays deleted
+	r.NextFire = next
+	found, err := e.storage.SaveScheduleTick(r)
+	if err != nil {
+		return err
+	}
+	if !found {
+		fmt.Printf("[SCHEDULER] %s was deleted while it fired\n", r.ID)
+	}
+	return nil
+}
+
+// applyCatchUp filters due occurrences according to the schedule's catch-up policy
+func applyCatchUp(s Schedule, due []time.Time, now time.Time) []time.Time {
+	if len(due) == 0 {
+		return due
+	}
+
+	switch s.CatchUp {
+	case CatchUpLatest:
+		return due[len(due)-1:]
+	case CatchUpAll:
+		if len(due) > s.MaxCatchUp {
+			fmt.Printf("[SCHEDULER] %s: dropping %d missed runs beyond catch-up cap %d\n", s.ID, len(due)-s.MaxCatchUp, s.MaxCatchUp)
+			return due[len(due)-s.MaxCatchUp:]
+		}
+		return due
+	default:
+		var recent []time.Time
+		for _, t := range due {
+			if now.Sub(t) <= s.CatchUpWindow {
+				recent = append(recent, t)
+			}
+		}
+		if skipped := len(due) - len(recent); skipped > 0 {
+			fmt.Printf("[SCHEDULER] %s: skipping %d missed runs\n", s.ID, skipped)
+		}
+		return recent
+	}
+}
+
+// scheduleRunActive reports whether the schedule's most recent run is still queued or running
+func (e *Engine) scheduleRunActive(r *scheduleRecord) (bool, error) {
+	if r.LastWorkflowID == "" {
+		return false, nil
+	}
+	status, err := e.storage.GetWorkflowStatus(r.LastWorkflowID)
+	if err == ErrWorkflowNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return status == "queued" || status == "running", nil
+}
+
+// fireSchedule enqueues the run for one occurrence
+func (e *Engine) fireSchedule(r *scheduleRecord, fireTime time.Time) error {
+	workflowID := ScheduledWorkflowID(r.ID, fireTime)
+	if err := e.enqueueEncoded(workflowID, r.WorkflowName, r.input, WithQueue(r.Queue)); err != nil {
+		return fmt.Errorf("failed to start %s: %w", workflowID, err)
+	}
+	r.LastWorkflowID = workflowID
+	fmt.Printf("[SCHEDULER] %s fired %s\n", r.ID, workflowID)
+	return nil
+}
+
+// UpsertSchedule inserts or fully replaces a schedule row
+func (s *Storage) UpsertSchedule(r *scheduleRecord) error {
+	var buffered int64
+	if !r.BufferedFire.IsZero() {
+		buffered = r.BufferedFire.UnixMilli()
+	}
+	var nextFire int64
+	if !r.NextFire.IsZero() {
+		nextFire = r.NextFire.UnixMilli()
+	}
+
+	return s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			`INSERT INTO schedules (schedule_id, cron, calendar, timezone, workflow_name, input, queue,
+			                        overlap, catch_up, catch_up_window_ms, max_catch_up, paused,
+			                        next_fire_ms, last_workflow_id, buffered_fire_ms, created_at)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			 ON CONFLICT(schedule_id) DO UPDATE SET
+			   cron = excluded.cron, calendar = excluded.calendar, timezone = excluded.timezone,
+			   workflow_name = excluded.workflow_name, input = excluded.input,
+			   queue = excluded.queue, overlap = excluded.overlap, catch_up = excluded.catch_up,
+			   catch_up_window_ms = excluded.catch_up_window_ms, max_catch_up = excluded.max_catch_up,
+			   paused = excluded.paused, next_fire_ms = excluded.next_fire_ms,
+			   last_workflow_id = excluded.last_workflow_id, buffered_fire_ms = excluded.buffered_fire_ms`,
+			r.ID, r.Cron, r.Calendar, r.Timezone, r.WorkflowName, r.input, r.Queue, string(r.Overlap), string(r.CatchUp),
+			r.CatchUpWindow.Milliseconds(), r.MaxCatchUp, r.Paused, nextFire,
+			r.LastWorkflowID, buffered, dbNow(),
+		)
+		return err
+	})
+}
+
+// SaveScheduleTick records a schedule's next fire, last run and buffered
+// fire, reporting false if the schedule no longer exists
+func (s *Storage) SaveScheduleTick(r *scheduleRecord) (bool, error) {
+	var buffered int64
+	if !r.BufferedFire.IsZero() {
+		buffered = r.BufferedFire.UnixMilli()
+	}
+	var nextFire int64
+	if !r.NextFire.IsZero() {
+		nextFire = r.NextFire.UnixMilli()
+	}
+
+	var affected int64
+	err := s.retryOnBusy(func() error {
+		res, err := s.db.Exec(
+			`UPDATE schedules SET next_fire_ms = ?, last_workflow_id = ?, buffered_fire_ms = ?
+			 WHERE schedule_id = ?`,
+			nextFire, r.LastWorkflowID, buffered, r.ID,
+		)
+		if err != nil {
+			return err
+		}
+		affected, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to save schedule %s: %w", r.ID, err)
+	}
+	return affected > 0, nil
+}
+
+// scheduleColumns is the column list read by scanSchedule
+const scheduleColumns = `schedule_id, cron, calendar, timezone, workflow_name, input, queue,
+	overlap, catch_up, catch_up_window_ms, max_catch_up, paused, next_fire_ms,
+	last_workflow_id, buffered_fire_ms`
+
+// scanSchedule reads one schedule row selected with scheduleColumns
+func scanSchedule(row interface{ Scan(...interface{}) error }) (*scheduleRecord, error) {
+	r := &scheduleRecord{}
+	var overlap, catchUp string
+	var windowMs, nextFireMs, bufferedMs int64
+	var lastWorkflowID sql.NullString
+	if err := row.Scan(&r.ID, &r.Cron, &r.Calendar, &r.Timezone, &r.WorkflowName, &r.input, &r.Queue,
+		&overlap, &catchUp, &windowMs, &r.MaxCatchUp, &r.Paused, &nextFireMs,
+		&lastWorkflowID, &bufferedMs); err != nil {
+		return nil, err
+	}
+
+	r.Overlap = OverlapPolicy(overlap)
+	r.CatchUp = CatchUpPolicy(catchUp)
+	r.CatchUpWindow = time.Duration(windowMs) * time.Millisecond
+	if nextFireMs > 0 {
+		r.NextFire = time.UnixMilli(nextFireMs)
+	}
+	if bufferedMs > 0 {
+		r.BufferedFire = time.UnixMilli(bufferedMs)
+	}
+	r.LastWorkflowID = lastWorkflowID.String
+	return r, nil
+}
+
+// ListSchedules loads every schedule ordered by ID
+func (s *Storage) ListSchedules() ([]*scheduleRecord, error) {
+	rows, err := s.db.Query("SELECT " + scheduleColumns + " FROM schedules ORDER BY schedule_id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schedules: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*scheduleRecord
+	for rows.Next() {
+		r, err := scanSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan schedule: %w", err)
+		}
+		records = append(records, r)
+	}
+
+	return records, rows.Err()
+}
+
+// GetSchedule loads a single schedule
+func (s *Storage) GetSchedule(scheduleID string) (*scheduleRecord, error) {
+	r, err := scanSchedule(s.db.QueryRow(
+		"SELECT "+scheduleColumns+" FROM schedules WHERE schedule_id = ?",
+		scheduleID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("schedule %s not found", scheduleID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schedule: %w", err)
+	}
+	return r, nil
+}
+
+// DeleteSchedule removes a schedule row
+func (s *Storage) DeleteSchedule(scheduleID string) error {
+	return s.retryOnBusy(func() error {
+		_, err := s.db.Exec("DELETE FROM schedules WHERE schedule_id = ?", scheduleID)
+		return err
+	})
+}
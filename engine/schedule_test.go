@@ -0,0 +1,260 @@
+package engine
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	spec, err := ParseCron("*/15 9-17 * * 1-5")
+	if err != nil {
+		t.Fatalf("failed to parse cron: %v", err)
+	}
+
+	// Friday 17:50 -> Monday 09:00
+	from := time.Date(2024, 5, 3, 17, 50, 0, 0, time.UTC)
+	want := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+	if got := spec.Next(from); !got.Equal(want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	if _, err := ParseCron("61 * * * *"); err == nil {
+		t.Error("expected out-of-range minute to be rejected")
+	}
+}
+
+// newTestSchedule stores a schedule whose next fire lies `missed` hourly runs in the past
+func newTestSchedule(t *testing.T, eng *Engine, s Schedule, missed int) *scheduleRecord {
+	t.Helper()
+	if err := eng.CreateSchedule(s); err != nil {
+		t.Fatalf("failed to create schedule: %v", err)
+	}
+	records, err := eng.storage.ListSchedules()
+	if err != nil || len(records) == 0 {
+		t.Fatalf("failed to load schedule: %v", err)
+	}
+	for _, r := range records {
+		if r.ID == s.ID {
+			r.NextFire = time.Now().Truncate(time.Hour).Add(-time.Duration(missed-1) * time.Hour)
+			return r
+		}
+	}
+	t.Fatalf("schedule %s not stored", s.ID)
+	return nil
+}
+
+func TestScheduleCatchUpPolicies(t *testing.T) {
+	dbPath := "./test_schedule_catchup.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	cases := []struct {
+		policy CatchUpPolicy
+		want   int
+	}{
+		{CatchUpSkip, 0},
+		{CatchUpLatest, 1},
+		{CatchUpAll, 5},
+	}
+	for _, tc := range cases {
+		r := newTestSchedule(t, eng, Schedule{
+			ID: "report-" + string(tc.policy), Cron: "@hourly", WorkflowName: "report",
+			CatchUp: tc.policy, CatchUpWindow: time.Nanosecond, MaxCatchUp: 5,
+			Overlap: OverlapAllowAll,
+		}, 48)
+
+		if err := eng.tickSchedule(r, time.Now().Add(time.Minute)); err != nil {
+			t.Fatalf("tick failed: %v", err)
+		}
+
+		queued, err := eng.storage.CountWorkflowsByStatus("queued")
+		if err != nil {
+			t.Fatalf("failed to count: %v", err)
+		}
+		if queued != tc.want {
+			t.Errorf("%s: expected %d runs fired after a 2-day outage, got %d", tc.policy, tc.want, queued)
+		}
+		// Reset the queue for the next policy
+		eng.storage.db.Exec("DELETE FROM workflows")
+	}
+}
+
+func TestScheduleOverlapPolicies(t *testing.T) {
+	dbPath := "./test_schedule_overlap.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	for _, policy := range []OverlapPolicy{OverlapSkip, OverlapBuffer, OverlapCancelPrevious} {
+		r := newTestSchedule(t, eng, Schedule{
+			ID: "sync-" + string(policy), Cron: "@hourly", WorkflowName: "sync",
+			CatchUp: CatchUpAll, Overlap: policy,
+		}, 2)
+
+		// Two occurrences are due; the first run is still queued when the second fires
+		if err := eng.tickSchedule(r, time.Now().Add(time.Minute)); err != nil {
+			t.Fatalf("tick failed: %v", err)
+		}
+
+		first := ScheduledWorkflowID(r.ID, time.Now().Truncate(time.Hour).Add(-time.Hour))
+		firstStatus, _ := eng.GetWorkflowStatus(first)
+
+		switch policy {
+		case OverlapSkip:
+			if r.LastWorkflowID != first || !r.BufferedFire.IsZero() {
+				t.Errorf("skip: expected only the first run, last=%s", r.LastWorkflowID)
+			}
+		case OverlapBuffer:
+			if r.LastWorkflowID != first || r.BufferedFire.IsZero() {
+				t.Errorf("buffer: expected second fire to be buffered, last=%s", r.LastWorkflowID)
+			}
+			// Once the first run finishes the buffered fire starts
+			eng.storage.UpdateWorkflowStatus(first, "completed")
+			if err := eng.tickSchedule(r, time.Now().Add(time.Minute)); err != nil {
+				t.Fatalf("tick failed: %v", err)
+			}
+			if r.LastWorkflowID == first || !r.BufferedFire.IsZero() {
+				t.Errorf("buffer: expected buffered run to start, last=%s", r.LastWorkflowID)
+			}
+		case OverlapCancelPrevious:
+			if firstStatus != "canceled" || r.LastWorkflowID == first {
+				t.Errorf("cancel_previous: expected first run canceled (got %s) and replaced", firstStatus)
+			}
+		}
+	}
+}
+
+func TestCronAcrossDSTTransitions(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+	spec, err := ParseScheduleSpec("30 1 * * *", "", "America/New_York")
+	if err != nil {
+		t.Fatalf("failed to parse spec: %v", err)
+	}
+
+	// Fall back: 01:30 happens twice on 2024-11-03 but must fire once
+	first := spec.Next(time.Date(2024, 11, 3, 0, 0, 0, 0, ny))
+	second := spec.Next(first)
+	if second.Sub(first) < 24*time.Hour {
+		t.Errorf("expected one fire per day across fall-back, got %v then %v", first, second)
+	}
+
+	// Spring forward: 02:30 does not exist on 2024-03-10; fire once after the gap
+	spring, _ := ParseScheduleSpec("30 2 * * *", "", "America/New_York")
+	fire := spring.Next(time.Date(2024, 3, 10, 0, 0, 0, 0, ny))
+	if fire.Day() != 10 || fire.Hour() != 3 {
+		t.Errorf("expected skipped wall time to fire at 03:30 on Mar 10, got %v", fire)
+	}
+	if next := spring.Next(fire); next.Day() != 11 {
+		t.Errorf("expected next fire on Mar 11, got %v", next)
+	}
+}
+
+func TestCalendarLastBusinessDay(t *testing.T) {
+	spec, err := ParseCalendar("last business day of month at 18:00")
+	if err != nil {
+		t.Fatalf("failed to parse calendar: %v", err)
+	}
+
+	// August 31st 2024 is a Saturday
+	got := spec.Next(time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC))
+	want := time.Date(2024, 8, 30, 18, 0, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if next := spec.Next(got); !next.Equal(time.Date(2024, 9, 30, 18, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected Sep 30, got %v", next)
+	}
+
+	if _, err := ParseCalendar("sometimes on tuesdays"); err == nil {
+		t.Error("expected malformed calendar expression to be rejected")
+	}
+}
+
+func TestScheduleBackfill(t *testing.T) {
+	dbPath := "./test_schedule_backfill.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	if err := eng.CreateSchedule(Schedule{ID: "nightly-report", Cron: "0 2 * * *", WorkflowName: "report"}); err != nil {
+		t.Fatalf("failed to create schedule: %v", err)
+	}
+
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 5, 7, 23, 59, 0, 0, time.UTC)
+	ids, err := eng.Backfill("nightly-report", from, to)
+	if err != nil {
+		t.Fatalf("backfill failed: %v", err)
+	}
+	if len(ids) != 7 || ids[0] != "nightly-report-backfill-20240501T020000Z" {
+		t.Fatalf("expected 7 deterministic runs, got %v", ids)
+	}
+
+	// Repeating the backfill does not create duplicates
+	if _, err := eng.Backfill("nightly-report", from, to); err != nil {
+		t.Fatalf("second backfill failed: %v", err)
+	}
+	queued, _ := eng.storage.CountWorkflowsByStatus("queued")
+	if queued != 7 {
+		t.Errorf("expected 7 queued runs after repeated backfill, got %d", queued)
+	}
+}
+
+func TestScheduleTickKeepsConcurrentEdits(t *testing.T) {
+	dbPath := "./test_schedule_tick.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	// The schedule is edited after the scheduler loaded it
+	r := newTestSchedule(t, eng, Schedule{ID: "sync", Cron: "0 * * * *", WorkflowName: "sync", CatchUp: CatchUpLatest}, 1)
+	if err := eng.CreateSchedule(Schedule{ID: "sync", Cron: "30 * * * *", WorkflowName: "sync", Paused: true}); err != nil {
+		t.Fatalf("failed to edit schedule: %v", err)
+	}
+	if err := eng.tickSchedule(r, time.Now()); err != nil {
+		t.Fatalf("tick failed: %v", err)
+	}
+	stored, err := eng.storage.GetSchedule("sync")
+	if err != nil {
+		t.Fatalf("failed to load schedule: %v", err)
+	}
+	if stored.Cron != "30 * * * *" || !stored.Paused {
+		t.Errorf("tick overwrote the edit: %+v", stored.Schedule)
+	}
+	if stored.LastWorkflowID != r.LastWorkflowID || r.LastWorkflowID == "" {
+		t.Errorf("expected last run %q recorded, got %q", r.LastWorkflowID, stored.LastWorkflowID)
+	}
+
+	// A schedule deleted while it fired isn't recreated
+	r = newTestSchedule(t, eng, Schedule{ID: "report", Cron: "0 * * * *", WorkflowName: "report"}, 1)
+	if err := eng.DeleteSchedule("report"); err != nil {
+		t.Fatalf("failed to delete schedule: %v", err)
+	}
+	if err := eng.tickSchedule(r, time.Now()); err != nil {
+		t.Fatalf("tick failed: %v", err)
+	}
+	if _, err := eng.storage.GetSchedule("report"); err == nil {
+		t.Error("deleted schedule was recreated by the tick")
+	}
+}
@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// ErrSchemaViolation is returned when a workflow input or step output does not match its JSON Schema
+var ErrSchemaViolation = errors.New("schema violation")
+
+// schemaRegistry holds compiled JSON Schemas for workflow inputs and step outputs
+type schemaRegistry struct {
+	mu     sync.RWMutex
+	inputs map[string]*jsonschema.Schema // by workflow name
+	steps  map[string]*jsonschema.Schema // by step ID
+}
+
+// SetInputSchema validates the input of every new run of workflowName against
+// a JSON Schema. Enqueue and typed Execute reject non-matching input with
+// ErrSchemaViolation before any state is written.
+func (e *Engine) SetInputSchema(workflowName, schema string) error {
+	compiled, err := compileSchema("workflow/"+workflowName, schema)
+	if err != nil {
+		return err
+	}
+
+	e.schemas.mu.Lock()
+	defer e.schemas.mu.Unlock()
+	if e.schemas.inputs == nil {
+		e.schemas.inputs = make(map[string]*jsonschema.Schema)
+	}
+	e.schemas.inputs[workflowName] = compiled
+	return nil
+}
+
+// SetStepSchema validates the output of every step with the given ID against a
+// JSON Schema before it is persisted. A non-matching output fails the step
+// with ErrSchemaViolation, so it is never replayed into downstream steps.
+func (e *Engine) SetStepSchema(stepID, schema string) error {
+	compiled, err := compileSchema("step/"+stepID, schema)
+	if err != nil {
+		return err
+	}
+
+	e.schemas.mu.Lock()
+	defer e.schemas.mu.Unlock()
+	if e.schemas.steps == nil {
+		e.schemas.steps = make(map[string]*jsonschema.Schema)
+	}
+	e.schemas.steps[stepID] = compiled
+	return nil
+}
+
+// validateInput checks a JSON-encoded workflow input against its schema, if any
+func (e *Engine) validateInput(workflowName string, input []byte) error {
+	e.schemas.mu.RLock()
+	schema := e.schemas.inputs[workflowName]
+	e.schemas.mu.RUnlock()
+	if schema == nil {
+		return nil
+	}
+
+	if err := validateJSON(schema, input); err != nil {
+		return fmt.Errorf("%w: input of workflow %s: %v", ErrSchemaViolation, workflowName, err)
+	}
+	return nil
+}
+
+// validateStepOutput checks a JSON-encoded step output against its schema, if any
+func (e *Engine) validateStepOutput(stepID string, output []byte) error {
+	e.schemas.mu.RLock()
+	schema := e.schemas.steps[stepID]
+	e.schemas.mu.RUnlock()
+	if schema == nil {
+		return nil
+	}
+
+	if err := validateJSON(schema, output); err != nil {
+		return fmt.Errorf("%w: output of step %s: %v", ErrSchemaViolation, stepID, err)
+	}
+	return nil
+}
+
+// compileSchema parses and compiles a JSON Schema document
+func compileSchema(name, schema string) (*jsonschema.Schema, error) {
+	doc, err := jsonschema.UnmarshalJSON(strings.NewReader(schema))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema for %s: %w", name, err)
+	}
+
+	url := "mem:///" + name + ".json"
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource(url, doc); err != nil {
+		return nil, fmt.Errorf("failed to load schema for %s: %w", name, err)
+	}
+	compiled, err := c.Compile(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile schema for %s: %w", name, err)
+	}
+	return compiled, nil
+}
+
+// validateJSON checks an encoded JSON document against a compiled schema
+func validateJSON(schema *jsonschema.Schema, data []byte) error {
+	if len(data) == 0 {
+		data = []byte("null")
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	return schema.Validate(doc)
+}
@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestSchemaValidation(t *testing.T) {
+	dbPath := "./test_schema.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	if err := eng.SetInputSchema("signup", `{
+		"type": "object",
+		"required": ["email"],
+		"properties": {"email": {"type": "string", "pattern": "@"}}
+	}`); err != nil {
+		t.Fatalf("failed to set input schema: %v", err)
+	}
+	if err := eng.SetStepSchema("lookup-user", `{
+		"type": "object",
+		"required": ["id"],
+		"properties": {"id": {"type": "integer", "minimum": 1}}
+	}`); err != nil {
+		t.Fatalf("failed to set step schema: %v", err)
+	}
+	if err := eng.SetStepSchema("broken", `{"type": 12}`); err == nil {
+		t.Error("expected an invalid schema to be rejected")
+	}
+
+	// Inputs are checked before anything is stored
+	err = eng.Enqueue("signup-1", "signup", map[string]string{"email": "nope"})
+	if !errors.Is(err, ErrSchemaViolation) {
+		t.Errorf("expected ErrSchemaViolation, got %v", err)
+	}
+	if _, err := eng.GetWorkflowStatus("signup-1"); !errors.Is(err, ErrWorkflowNotFound) {
+		t.Errorf("expected rejected workflow not to be stored, got %v", err)
+	}
+	if err := eng.Enqueue("signup-2", "signup", map[string]string{"email": "a@example.com"}); err != nil {
+		t.Errorf("expected valid input to be accepted, got %v", err)
+	}
+
+	signup := NewWorkflow("signup", func(ctx *Context, in map[string]string) (string, error) {
+		return in["email"], nil
+	})
+	if _, err := signup.Execute(eng, "signup-3", map[string]string{}); !errors.Is(err, ErrSchemaViolation) {
+		t.Errorf("expected typed Execute to validate input, got %v", err)
+	}
+
+	// Step outputs are checked before they are persisted
+	type user struct {
+		ID int `json:"id"`
+	}
+	downstream := false
+	err = eng.Execute("lookup-1", func(ctx *Context) error {
+		if _, err := Step(ctx, "lookup-user", func() (user, error) { return user{ID: 0}, nil }); err != nil {
+			return err
+		}
+		downstream = true
+		return nil
+	})
+	if !errors.Is(err, ErrSchemaViolation) {
+		t.Errorf("expected ErrSchemaViolation from step, got %v", err)
+	}
+	if downstream {
+		t.Error("expected the workflow to stop at the invalid step")
+	}
+	history, err := eng.GetHistory("lookup-1")
+	if err != nil || len(history) != 1 || history[0].Status != "failed" || history[0].Output != nil {
+		t.Errorf("expected the invalid output not to be persisted, got %+v (%v)", history, err)
+	}
+
+	err = eng.Execute("lookup-2", func(ctx *Context) error {
+		_, err := Step(ctx, "lookup-user", func() (user, error) { return user{ID: 7}, nil })
+		return err
+	})
+	if err != nil {
+		t.Errorf("expected valid output to be accepted, got %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// CancellationScope is a part of a workflow whose effects are undone by
+// cleanup steps if the workflow is canceled while it runs
+type CancellationScope struct {
+	ctx      *Context
+	seq      int
+	mu       sync.Mutex
+	cleanups []scopeCleanup
+}
+
+// scopeCleanup is a cleanup step registered with a scope
+type scopeCleanup struct {
+	id   string
+	fn   func() error
+	opts []StepOption
+}
+
+// WithCancellationScope runs fn in a new scope. If the workflow is canceled
+// or passes its deadline before fn returns successfully, the cleanup steps
+// registered with the scope run in reverse order, in a section that isn't
+// canceled: their steps start and retry normally and ctx.Context() stays
+// live, also for functions started with Go that are still running. The
+// scope then returns fn's error joined with any cleanup failures.
+//
+// Cleanup survives crashes: if the process dies before the cleanup steps
+// finished, executing the canceled workflow again replays it and runs the
+// rest of them.
+func (ctx *Context) WithCancellationScope(fn func(scope *CancellationScope) error) error {
+	ctx.mu.Lock()
+	ctx.scopeSeq++
+	scope := &CancellationScope{ctx: ctx, seq: ctx.scopeSeq}
+	ctx.mu.Unlock()
+
+	err := fn(scope)
+	if err == nil || ctx.doneErr() == nil {
+		return err
+	}
+	if cleanupErr := scope.runCleanups(); cleanupErr != nil {
+		return errors.Join(err, cleanupErr)
+	}
+	return err
+}
+
+// Cleanup registers a step that undoes work done in the scope, such as
+// releasing reserved inventory. Register it right after the step it undoes,
+// so a replay registers the same cleanups. It may be called concurrently.
+func (s *CancellationScope) Cleanup(id string, fn func() error, opts ...StepOption) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cleanups = append(s.cleanups, scopeCleanup{id: id, fn: fn, opts: opts})
+}
+
+// runCleanups runs the registered cleanup steps, last registered first. A
+// marker step of kind StepKindScope is in progress while they run and
+// completed once they all succeeded.
+func (s *CancellationScope) runCleanups() error {
+	ctx := s.ctx
+	id := fmt.Sprintf("scope:%d:cleanup", s.seq)
+	seqNum, err := ctx.stepSequence(id)
+	if err != nil {
+		return err
+	}
+	stepKey := generateStepKey(id, seqNum)
+
+	ctx.mu.Lock()
+	_, done := ctx.completedSteps[stepKey]
+	ctx.mu.Unlock()
+	if !done {
+		var err error
+		if _, done, err = ctx.storedStep(stepKey, seqNum); err != nil {
+			return fmt.Errorf("failed to check step in database: %w", err)
+		}
+	}
+	if done {
+		return nil
+	}
+
+	atomic.AddInt32(&ctx.nonCancellable, 1)
+	defer atomic.AddInt32(&ctx.nonCancellable, -1)
+
+	if err := ctx.markStepInProgress(stepKey, id, seqNum, StepKindScope, "", nil); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	cleanups := append([]scopeCleanup(nil), s.cleanups...)
+	s.mu.Unlock()
+	ctx.printf("[SCOPE] running %d cleanup steps\n", len(cleanups))
+
+	var errs []error
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		c := cleanups[i]
+		_, err := Step(ctx, c.id, func() (bool, error) {
+			return true, c.fn()
+		}, c.opts...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		ctx.storage.SaveStepError(ctx.WorkflowID, stepKey, err.Error())
+		return err
+	}
+
+	output, err := ctx.engine.codec.Marshal(len(cleanups))
+	if err != nil {
+		return fmt.Errorf("failed to marshal result: %w", err)
+	}
+	return ctx.recordStep(stepKey, output)
+}
+
+// cancellationContext is the context of step bodies: the run's, unless
+// cleanup steps are running
+func (ctx *Context) cancellationContext() context.Context {
+	if atomic.LoadInt32(&ctx.nonCancellable) > 0 {
+		return context.WithoutCancel(ctx.runCtx)
+	}
+	return ctx.runCtx
+}
+
+// resumeCleanups re-executes a canceled workflow whose cleanup steps didn't
+// all complete, so its scopes run the rest of them
+func (e *Engine) resumeCleanups(workflowID string, workflowFn func(*Context) error, o *workflowOptions) error {
+	ctx, err := newContext(e, workflowID)
+	if err != nil {
+		return fmt.Errorf("failed to create context: %w", err)
+	}
+	ctx.stepDefaults = e.resolveStepDefaults(workflowID, o)
+	atomic.StoreInt32(&ctx.canceled, 1)
+	ctx.cancelRun()
+
+	untrack := e.trackRunning(ctx)
+	defer untrack()
+	defer ctx.releaseLocks()
+
+	fmt.Printf("[SCOPE] resuming cleanup of canceled workflow %s\n", workflowID)
+	err = callWorkflow(func() error { return workflowFn(ctx) })
+	if waitErr := ctx.awaitSteps(); err == nil {
+		err = waitErr
+	}
+	e.finishWorkflow(workflowID, "canceled", nil)
+	if pending, pendingErr := e.storage.HasPendingCleanups(workflowID); pendingErr == nil && pending {
+		return fmt.Errorf("%w (cleanup incomplete: %v)", ErrWorkflowCanceled, err)
+	}
+	return ErrWorkflowCanceled
+}
+
+// HasPendingCleanups reports whether a cancellation scope of the workflow
+// started cleaning up and didn't finish
+func (s *Storage) HasPendingCleanups(workflowID string) (bool, error) {
+	var pending bool
+	err := s.db.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM steps WHERE workflow_id = ? AND kind = ? AND status != 'completed')",
+		workflowID, StepKindScope,
+	).Scan(&pending)
+	if err != nil {
+		return false, fmt.Errorf("failed to check pending cleanups: %w", err)
+	}
+	return pending, nil
+}
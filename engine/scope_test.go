@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestCancellationScopeRunsCleanups(t *testing.T) {
+	dbPath := "./test_scope.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	var released []int
+	failRelease := true
+	var cleanupCtxErr error
+	order := func(ctx *Context) error {
+		return ctx.WithCancellationScope(func(scope *CancellationScope) error {
+			for i := 0; i < 3; i++ {
+				if _, err := Step(ctx, fmt.Sprintf("reserve-%d", i), func() (bool, error) {
+					if i == 2 {
+						// The order is canceled mid-fan-out
+						eng.CancelWorkflow(ctx.WorkflowID)
+						return false, ctx.Context().Err()
+					}
+					return true, nil
+				}); err != nil {
+					return err
+				}
+				scope.Cleanup(fmt.Sprintf("release-%d", i), func() error {
+					cleanupCtxErr = ctx.Context().Err()
+					if i == 0 && failRelease {
+						failRelease = false
+						return errors.New("inventory service unavailable")
+					}
+					released = append(released, i)
+					return nil
+				})
+			}
+			return nil
+		})
+	}
+
+	err = eng.Execute("order-1", order)
+	if !errors.Is(err, ErrWorkflowCanceled) {
+		t.Fatalf("expected the workflow to be canceled, got %v", err)
+	}
+	if !reflect.DeepEqual(released, []int{1}) || cleanupCtxErr != nil {
+		t.Errorf("expected release-1 to run with a live context, got %v (%v)", released, cleanupCtxErr)
+	}
+	if pending, _ := eng.storage.HasPendingCleanups("order-1"); !pending {
+		t.Fatal("expected the failed cleanup to be pending")
+	}
+
+	// Executing the canceled run again finishes its cleanup
+	if err := eng.Execute("order-1", order); !errors.Is(err, ErrWorkflowCanceled) {
+		t.Fatalf("expected the resumed run to stay canceled, got %v", err)
+	}
+	if !reflect.DeepEqual(released, []int{1, 0}) {
+		t.Errorf("expected release-0 to be retried once, got %v", released)
+	}
+	if pending, _ := eng.storage.HasPendingCleanups("order-1"); pending {
+		t.Error("expected no pending cleanup")
+	}
+	if status, _ := eng.storage.GetWorkflowStatus("order-1"); status != "canceled" {
+		t.Errorf("expected the workflow to stay canceled, got %s", status)
+	}
+	if err := eng.Execute("order-1", order); !errors.Is(err, ErrWorkflowCanceled) || len(released) != 2 {
+		t.Errorf("expected nothing to run again, got %v and %v", err, released)
+	}
+}
+
+func TestCancellationScopeSkipsCleanupOnSuccess(t *testing.T) {
+	dbPath := "./test_scope_success.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	cleanups := 0
+	err = eng.Execute("order-2", func(ctx *Context) error {
+		return ctx.WithCancellationScope(func(scope *CancellationScope) error {
+			if _, err := Step(ctx, "reserve", func() (bool, error) { return true, nil }); err != nil {
+				return err
+			}
+			scope.Cleanup("release", func() error { cleanups++; return nil })
+			_, err := Step(ctx, "charge", func() (bool, error) { return false, errors.New("card declined") })
+			return err
+		})
+	})
+	if err == nil || cleanups != 0 {
+		t.Errorf("expected a failure without cancellation to skip cleanup, got %v after %d cleanups", err, cleanups)
+	}
+}
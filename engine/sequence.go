@@ -2,8 +2,7 @@ package engine
 
 import (
 	"fmt"
-	"path/filepath"
-	"runtime"
+	"sync/atomic"
 )
 
 // generateStepKey creates a unique key combining step ID and sequence number
@@ -12,12 +11,94 @@ func generateStepKey(stepID string, sequenceNum int64) string {
 	return fmt.Sprintf("%s:%d", stepID, sequenceNum)
 }
 
-// getCallerLocation returns the file and line number of the caller
-// This is used for automatic step ID generation
-func getCallerLocation(skip int) string {
-	_, file, line, ok := runtime.Caller(skip)
-	if !ok {
-		return "unknown:0"
+// stepSequence returns the sequence number of a step ID, assigning the next
+// one the first time the ID is seen
+func (ctx *Context) stepSequence(id string) (int64, error) {
+	seqs, err := ctx.stepSequences([]string{id})
+	if err != nil {
+		return 0, err
 	}
-	return fmt.Sprintf("%s:%d", filepath.Base(file), line)
+	return seqs[0], nil
+}
+
+// stepSequences returns the sequence numbers of step IDs, assigning the next
+// ones in order to IDs seen for the first time. Storage assigns them, so
+// processes resuming the same run concurrently agree on every step key;
+// dry runs number their steps in memory.
+func (ctx *Context) stepSequences(ids []string) ([]int64, error) {
+	seqs := make([]int64, len(ids))
+	var missing []string
+	ctx.mu.Lock()
+	for i, id := range ids {
+		seqNum, exists := ctx.stepIDToSeq[id]
+		if !exists && ctx.sim != nil {
+			seqNum = atomic.AddInt64(&ctx.sequenceNum, 1)
+			ctx.stepIDToSeq[id] = seqNum
+			exists = true
+		}
+		if !exists {
+			missing = append(missing, id)
+		}
+		seqs[i] = seqNum
+	}
+	ctx.mu.Unlock()
+	if len(missing) == 0 {
+		return seqs, nil
+	}
+
+	allocated, err := ctx.storage.AllocateStepSequences(ctx.WorkflowID, missing)
+	if err != nil {
+		return nil, err
+	}
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	for i, id := range missing {
+		ctx.stepIDToSeq[id] = allocated[i]
+	}
+	for i, id := range ids {
+		seqs[i] = ctx.stepIDToSeq[id]
+	}
+	return seqs, nil
+}
+
+// AllocateStepSequences returns the sequence numbers of a run's step IDs,
+// assigning the next free ones, in order, to IDs allocated for the first
+// time. Every process gets the same number for an ID and no two IDs share
+// one: the first allocation of an ID wins, and one that loses a race for a
+// number retries with the next.
+func (s *Storage) AllocateStepSequences(workflowID string, stepIDs []string) ([]int64, error) {
+	seqs := make([]int64, len(stepIDs))
+	err := s.WithTx(func(tx StorageTx) error {
+		for i, id := range stepIDs {
+			seq, err := tx.allocateStepSequence(workflowID, id)
+			if err != nil {
+				return err
+			}
+			seqs[i] = seq
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to allocate step sequence: %w", err)
+	}
+	return seqs, nil
+}
+
+// allocateStepSequence assigns a step ID its sequence number, counting the
+// step rows of runs recorded before allocation moved into storage
+func (s *Storage) allocateStepSequence(workflowID, stepID string) (int64, error) {
+	// The MAX and the insert are one statement, which SQLite runs under the
+	// write lock, so concurrent allocations can't pick the same number
+	var seq int64
+	err := s.db.QueryRow(
+		`INSERT INTO step_sequences (workflow_id, step_id, sequence_num)
+		 SELECT ?, ?, COALESCE(MAX(sequence_num), 0) + 1 FROM (
+		   SELECT sequence_num FROM step_sequences WHERE workflow_id = ?
+		   UNION ALL SELECT sequence_num FROM steps WHERE workflow_id = ?
+		 ) WHERE true
+		 ON CONFLICT(workflow_id, step_id) DO UPDATE SET step_id = excluded.step_id
+		 RETURNING sequence_num`,
+		workflowID, stepID, workflowID, workflowID,
+	).Scan(&seq)
+	return seq, err
 }
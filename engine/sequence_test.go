@@ -0,0 +1,59 @@
+package engine
+
+import (
+	"os"
+	"testing"
+)
+
+func TestConcurrentResumesAgreeOnStepKeys(t *testing.T) {
+	dbPath := "./test_sequence.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	err = eng.Execute("resume-1", func(ctx *Context) error {
+		_, err := Step(ctx, "load", func() (int, error) { return 1, nil })
+		return err
+	})
+	if err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+
+	// Two processes resume the run from the same history and reach new steps in different orders
+	a, err := newContext(eng, "resume-1")
+	if err != nil {
+		t.Fatalf("failed to create context: %v", err)
+	}
+	b, err := newContext(eng, "resume-1")
+	if err != nil {
+		t.Fatalf("failed to create context: %v", err)
+	}
+
+	load, _ := a.stepSequence("load")
+	charge, _ := a.stepSequence("charge")
+	ship, _ := b.stepSequence("ship")
+	bCharge, err := b.stepSequence("charge")
+	if err != nil {
+		t.Fatalf("failed to allocate: %v", err)
+	}
+	if load != 1 || charge != 2 || ship != 3 {
+		t.Errorf("expected recorded and new steps to be numbered in order, got load=%d charge=%d ship=%d", load, charge, ship)
+	}
+	if bCharge != charge {
+		t.Errorf("expected both processes to key charge as %d, got %d", charge, bCharge)
+	}
+
+	// A restarted process finds the same numbers
+	c, err := newContext(eng, "resume-1")
+	if err != nil {
+		t.Fatalf("failed to create context: %v", err)
+	}
+	seqs, err := c.stepSequences([]string{"ship", "charge", "refund"})
+	if err != nil || seqs[0] != 3 || seqs[1] != 2 || seqs[2] != 4 {
+		t.Errorf("expected [3 2 4], got %v, %v", seqs, err)
+	}
+}
@@ -0,0 +1,159 @@
+package engine
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"time"
+)
+
+// DefaultShardCount is the number of shards workflow IDs are hashed into
+const DefaultShardCount = 64
+
+// ShardForWorkflow maps a workflow ID onto a shard in [0, shardCount)
+func ShardForWorkflow(workflowID string, shardCount int) int {
+	h := fnv.New32a()
+	h.Write([]byte(workflowID))
+	return int(h.Sum32() % uint32(shardCount))
+}
+
+// BackfillShards assigns workflows created before the shard column existed
+// to their shard; the shard count is only known to the engine, so NewEngine
+// runs it once the database is migrated
+func (s *Storage) BackfillShards(shardCount int) error {
+	rows, err := s.db.Query("SELECT workflow_id FROM workflows WHERE shard < 0")
+	if err != nil {
+		return fmt.Errorf("failed to list unassigned workflows: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan unassigned workflow: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		err := s.retryOnBusy(func() error {
+			_, err := s.db.Exec("UPDATE workflows SET shard = ? WHERE workflow_id = ? AND shard < 0",
+				ShardForWorkflow(id, shardCount), id)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to assign shard of %s: %w", id, err)
+		}
+	}
+	if len(ids) > 0 {
+		fmt.Printf("[SHARDS] assigned %d existing workflows to shards\n", len(ids))
+	}
+	return nil
+}
+
+// AssignShards distributes shards over workers using rendezvous hashing.
+// When a worker joins or leaves only the shards it owned (or wins) move.
+func AssignShards(shardCount int, workerIDs []string) map[string][]int {
+	assignment := make(map[string][]int, len(workerIDs))
+	if len(workerIDs) == 0 {
+		return assignment
+	}
+
+	for shard := 0; shard < shardCount; shard++ {
+		var owner string
+		var best uint64
+		for _, id := range workerIDs {
+			h := fnv.New64a()
+			fmt.Fprintf(h, "%s/%d", id, shard)
+			score := h.Sum64()
+			if owner == "" || score > best || (score == best && id < owner) {
+				owner, best = id, score
+			}
+		}
+		assignment[owner] = append(assignment[owner], shard)
+	}
+
+	return assignment
+}
+
+// OwnedShards returns the shards this engine is responsible for.
+// Engines not started as workers run standalone and own every shard;
+// workers split shards among all currently alive workers.
+func (e *Engine) OwnedShards() ([]int, error) {
+	e.workerMu.Lock()
+	registered := e.worker != nil
+	e.workerMu.Unlock()
+
+	if !registered {
+		all := make([]int, e.shardCount)
+		for i := range all {
+			all[i] = i
+		}
+		return all, nil
+	}
+
+	workers, err := e.storage.ListWorkers()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	live := []string{e.workerID}
+	for _, w := range workers {
+		if w.WorkerID != e.workerID && w.Alive(now) {
+			live = append(live, w.WorkerID)
+		}
+	}
+	sort.Strings(live)
+
+	return AssignShards(e.shardCount, live)[e.workerID], nil
+}
+
+// ListRunnableWorkflows returns running workflows in the shards this engine owns
+func (e *Engine) ListRunnableWorkflows() ([]string, error) {
+	shards, err := e.OwnedShards()
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute owned shards: %w", err)
+	}
+	return e.storage.ListWorkflowsInShards("running", shards)
+}
+
+// ListWorkflowsInShards returns workflow IDs with the given status in the listed shards
+func (s *Storage) ListWorkflowsInShards(status string, shards []int) ([]string, error) {
+	if len(shards) == 0 {
+		return nil, nil
+	}
+
+	args := make([]interface{}, 0, len(shards)+1)
+	args = append(args, status)
+	for _, shard := range shards {
+		args = append(args, shard)
+	}
+
+	rows, err := s.db.Query(
+		fmt.Sprintf(
+			"SELECT workflow_id FROM workflows WHERE status = ? AND shard IN (%s) ORDER BY created_at",
+			placeholders(len(shards)),
+		),
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workflows: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan workflow: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
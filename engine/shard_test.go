@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"os"
+	"testing"
+)
+
+func TestShardRebalanceMovesOnlyDepartedShards(t *testing.T) {
+	before := AssignShards(DefaultShardCount, []string{"w1", "w2", "w3"})
+	after := AssignShards(DefaultShardCount, []string{"w1", "w3"})
+
+	total := 0
+	for _, shards := range after {
+		total += len(shards)
+	}
+	if total != DefaultShardCount {
+		t.Fatalf("expected all %d shards assigned, got %d", DefaultShardCount, total)
+	}
+
+	// Shards owned by surviving workers must not move
+	owner := make(map[int]string)
+	for id, shards := range after {
+		for _, shard := range shards {
+			owner[shard] = id
+		}
+	}
+	for _, id := range []string{"w1", "w3"} {
+		for _, shard := range before[id] {
+			if owner[shard] != id {
+				t.Errorf("shard %d moved from %s to %s", shard, id, owner[shard])
+			}
+		}
+	}
+}
+
+func TestShardForWorkflowIsStable(t *testing.T) {
+	a := ShardForWorkflow("order-42", DefaultShardCount)
+	b := ShardForWorkflow("order-42", DefaultShardCount)
+	if a != b || a < 0 || a >= DefaultShardCount {
+		t.Errorf("expected a stable shard in range, got %d and %d", a, b)
+	}
+}
+
+func TestShardColumnBackfilled(t *testing.T) {
+	dbPath := "./test_shard_backfill.db"
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + "-wal")
+	defer os.Remove(dbPath + "-shm")
+
+	// A database created before workflows were sharded
+	old, err := NewStorage(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	for _, stmt := range []string{
+		"DROP TABLE workflows",
+		`CREATE TABLE workflows (
+			workflow_id TEXT PRIMARY KEY,
+			status TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		"INSERT INTO workflows (workflow_id, status) VALUES ('old-1', 'running'), ('old-2', 'running'), ('old-3', 'completed')",
+	} {
+		if _, err := old.db.Exec(stmt); err != nil {
+			t.Fatalf("failed to set up old schema: %v", err)
+		}
+	}
+	old.Close()
+
+	eng, err := NewEngine(dbPath, WithShardCount(8))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	for _, id := range []string{"old-1", "old-2", "old-3"} {
+		var shard int
+		if err := eng.storage.db.QueryRow("SELECT shard FROM workflows WHERE workflow_id = ?", id).Scan(&shard); err != nil {
+			t.Fatalf("failed to read shard of %s: %v", id, err)
+		}
+		if want := ShardForWorkflow(id, 8); shard != want {
+			t.Errorf("expected %s in shard %d, got %d", id, want, shard)
+		}
+	}
+}
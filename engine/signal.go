@@ -0,0 +1,489 @@
+package engine
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// signalPollInterval is how often WaitForSignal checks for new signals
+const signalPollInterval = 100 * time.Millisecond
+
+// nextSignalSeq selects the values of a new signal row, numbering it after
+// the last signal of the same name sent to the workflow. Its arguments are
+// workflow_id, name, payload, sender, dedup_key and created_at, then the
+// workflow_id and name again.
+const nextSignalSeq = `SELECT ?, ?, COALESCE(MAX(seq), 0) + 1, ?, ?, ?, ?
+	 FROM signals WHERE workflow_id = ? AND name = ?`
+
+// Signal is a durable message addressed to a workflow
+type Signal struct {
+	ID         int64
+	WorkflowID string
+	Name       string
+	Seq        int64 // 1-based position among the workflow's signals with this name
+	Payload    []byte
+	Sender     string
+}
+
+// Signal durably delivers a named signal to a workflow from outside any workflow
+func (e *Engine) Signal(workflowID, name string, payload interface{}) error {
+	data, err := e.codec.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal signal payload: %w", err)
+	}
+	if _, err := e.storage.InsertSignal(workflowID, name, data, "", ""); err != nil {
+		return fmt.Errorf("failed to send signal: %w", err)
+	}
+	e.emit(EngineEvent{Type: EventSignalSent, WorkflowID: workflowID, Signal: name})
+	return nil
+}
+
+// SignalRequest is one signal delivered by SignalBatch
+type SignalRequest struct {
+	WorkflowID string
+	Name       string
+	Payload    interface{}
+	// DedupKey, if set, makes redelivery a no-op, e.g. a queue message ID
+	// for consumers that may see a message twice
+	DedupKey string
+}
+
+// SignalBatch durably delivers many signals in one transaction: either all
+// of them are recorded or none are. It is much faster than calling Signal
+// in a loop, e.g. for a queue consumer draining a backlog.
+func (e *Engine) SignalBatch(signals []SignalRequest) error {
+	batch := make([]Signal, len(signals))
+	dedupKeys := make([]string, len(signals))
+	for i, req := range signals {
+		data, err := e.codec.Marshal(req.Payload)
+		if err != nil {
+			return fmt.Errorf("failed to marshal payload of signal %d: %w", i, err)
+		}
+		batch[i] = Signal{WorkflowID: req.WorkflowID, Name: req.Name, Payload: data}
+		dedupKeys[i] = req.DedupKey
+	}
+	if err := e.storage.InsertSignals(batch, dedupKeys); err != nil {
+		return fmt.Errorf("failed to send signals: %w", err)
+	}
+	for _, sig := range batch {
+		e.emit(EngineEvent{Type: EventSignalSent, WorkflowID: sig.WorkflowID, Signal: sig.Name})
+	}
+	return nil
+}
+
+// SignalWithStart delivers a named signal to a workflow, enqueueing it as
+// workflowName with input first if it doesn't exist yet. Creating the run,
+// recording its options and recording the signal happen in one transaction,
+// so concurrent callers start the run once and every signal reaches it.
+// Options only apply to a run this call starts.
+func (e *Engine) SignalWithStart(workflowID, name string, payload interface{}, workflowName string, input interface{}, opts ...WorkflowOption) error {
+	data, err := e.codec.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal signal payload: %w", err)
+	}
+	sig := Signal{WorkflowID: workflowID, Name: name, Payload: data}
+
+	if _, err := e.storage.GetWorkflowStatus(workflowID); err == nil {
+		if _, err := e.storage.InsertSignal(workflowID, name, data, "", ""); err != nil {
+			return fmt.Errorf("failed to send signal: %w", err)
+		}
+		e.emit(EngineEvent{Type: EventSignalSent, WorkflowID: workflowID, Signal: name})
+		return nil
+	} else if !errors.Is(err, ErrWorkflowNotFound) {
+		return fmt.Errorf("failed to check workflow: %w", err)
+	}
+
+	o := newWorkflowOptions(opts)
+	start, err := e.codec.Marshal(input)
+	if err != nil {
+		return fmt.Errorf("failed to marshal workflow input: %w", err)
+	}
+	if err := e.validateInput(workflowName, start); err != nil {
+		return err
+	}
+	if err := e.checkParams(workflowName, o.params); err != nil {
+		return err
+	}
+	if err := e.admit(); err != nil {
+		return err
+	}
+
+	// The options of a run this call starts commit with its row and the signal
+	shard := ShardForWorkflow(workflowID, e.shardCount)
+	err = e.storage.WithTx(func(tx StorageTx) error {
+		started, err := tx.StartWithSignal(workflowID, shard, workflowName, o.queue, o.priority, o.concurrencyKey, start, sig)
+		if err != nil {
+			return fmt.Errorf("failed to signal with start: %w", err)
+		}
+		if !started {
+			return nil
+		}
+		return e.persistStartOptions(tx.Storage, workflowID, o)
+	})
+	if err != nil {
+		return err
+	}
+	e.emit(EngineEvent{Type: EventSignalSent, WorkflowID: workflowID, Signal: name})
+	return nil
+}
+
+// SignalWorkflow durably sends a named signal to another workflow. Each send
+// is recorded as a step numbered per target and signal name, so sending the
+// same signal again delivers it again, while a replaying sender never
+// delivers any send twice.
+func (ctx *Context) SignalWorkflow(targetID, name string, payload interface{}) error {
+	data, err := ctx.engine.codec.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal signal payload: %w", err)
+	}
+
+	// The first send keeps the unnumbered ID earlier versions recorded, so
+	// runs started before sends were numbered don't send it again on replay
+	stepID := fmt.Sprintf("signal-send:%s:%s", targetID, name)
+	if n := ctx.nextSignalSend(targetID, name); n > 1 {
+		stepID = fmt.Sprintf("%s#%d", stepID, n)
+	}
+	_, err = Step(ctx, stepID, func() (int64, error) {
+		dedupKey := fmt.Sprintf("%s/%s", ctx.WorkflowID, stepID)
+		id, err := ctx.storage.InsertSignal(targetID, name, data, ctx.WorkflowID, dedupKey)
+		if err == nil {
+			ctx.engine.emit(EngineEvent{Type: EventSignalSent, WorkflowID: targetID, Signal: name})
+		}
+		return id, err
+	}, withStepKind(StepKindSignalSend))
+	return err
+}
+
+// WaitForSignal blocks until a signal with the given name arrives and returns
+// its decoded payload. Signals are consumed oldest first, each exactly once;
+// consumption is recorded as a step so a replay returns the same signal.
+func WaitForSignal[T any](ctx *Context, name string) (T, error) {
+	stepID := fmt.Sprintf("signal:%s:%d", name, ctx.nextSignalWait(name))
+	return Step(ctx, stepID, func() (T, error) {
+		var payload T
+		consumedKey := fmt.Sprintf("%s/%s", ctx.WorkflowID, stepID)
+
+		err := ctx.awaitSignals("signal "+name, func() (bool, error) {
+			sig, err := ctx.storage.ConsumeSignal(ctx.WorkflowID, name, consumedKey)
+			if err != nil || sig == nil {
+				return false, err
+			}
+			if len(sig.Payload) > 0 {
+				if err := ctx.engine.codec.Unmarshal(sig.Payload, &payload); err != nil {
+					return false, fmt.Errorf("failed to unmarshal signal %s: %w", name, err)
+				}
+			}
+			ctx.engine.emit(EngineEvent{Type: EventSignalReceived, WorkflowID: ctx.WorkflowID, StepID: stepID, Signal: name})
+			return true, nil
+		})
+		return payload, err
+	}, withStepKind(StepKindSignal))
+}
+
+// SignalMessage is a signal received by ReceiveSignals
+type SignalMessage[T any] struct {
+	Seq     int64 // 1-based position among the workflow's signals with this name
+	Sender  string
+	Payload T
+}
+
+// ReceiveSignals blocks until at least one signal with the given name is
+// buffered, then consumes up to max of them in the order they were sent.
+// Signals sent before the workflow asks for them wait durably until it does.
+// Like WaitForSignal, what was consumed is recorded as a step, so a replay
+// returns the same batch.
+func ReceiveSignals[T any](ctx *Context, name string, max int) ([]SignalMessage[T], error) {
+	if max <= 0 {
+		max = 1
+	}
+	stepID := fmt.Sprintf("signals:%s:%d", name, ctx.nextSignalWait(name))
+	return Step(ctx, stepID, func() ([]SignalMessage[T], error) {
+		var batch []SignalMessage[T]
+		consumedKey := fmt.Sprintf("%s/%s", ctx.WorkflowID, stepID)
+
+		err := ctx.awaitSignals("signal "+name, func() (bool, error) {
+			signals, err := ctx.storage.ConsumeSignals(ctx.WorkflowID, name, consumedKey, max)
+			if err != nil || len(signals) == 0 {
+				return false, err
+			}
+			for _, sig := range signals {
+				msg := SignalMessage[T]{Seq: sig.Seq, Sender: sig.Sender}
+				if len(sig.Payload) > 0 {
+					if err := ctx.engine.codec.Unmarshal(sig.Payload, &msg.Payload); err != nil {
+						return false, fmt.Errorf("failed to unmarshal signal %s #%d: %w", name, sig.Seq, err)
+					}
+				}
+				batch = append(batch, msg)
+				ctx.engine.emit(EngineEvent{Type: EventSignalReceived, WorkflowID: ctx.WorkflowID, StepID: stepID, Signal: name})
+			}
+			return true, nil
+		})
+		return batch, err
+	}, withStepKind(StepKindSignal))
+}
+
+// nextSignalWait counts a WaitForSignal or ReceiveSignals call for a signal
+// name, numbering its step
+func (ctx *Context) nextSignalWait(name string) int {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if ctx.signalWaits == nil {
+		ctx.signalWaits = make(map[string]int)
+	}
+	ctx.signalWaits[name]++
+	return ctx.signalWaits[name]
+}
+
+// nextSignalSend numbers SignalWorkflow calls per target and signal name
+func (ctx *Context) nextSignalSend(targetID, name string) int {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if ctx.signalSends == nil {
+		ctx.signalSends = make(map[string]int)
+	}
+	key := targetID + "/" + name
+	ctx.signalSends[key]++
+	return ctx.signalSends[key]
+}
+
+// awaitSignals polls consume until it reports what it waits for was consumed,
+// the run ends or the engine closes
+func (ctx *Context) awaitSignals(what string, consume func() (bool, error)) error {
+	for {
+		// Timers started with StartTimer arrive as signals once due
+		if err := ctx.engine.deliverDueTimers(ctx.WorkflowID); err != nil {
+			return err
+		}
+		done, err := consume()
+		if err != nil || done {
+			return err
+		}
+
+		if ctx.Canceled() {
+			return ErrWorkflowCanceled
+		}
+		select {
+		case <-ctx.engine.stop:
+			return fmt.Errorf("engine closed while waiting for %s", what)
+		case <-ctx.Done():
+			return ctx.doneErr()
+		case <-time.After(signalPollInterval):
+		}
+	}
+}
+
+// InsertSignal records a signal; a non-empty dedupKey makes the insert idempotent
+func (s *Storage) InsertSignal(workflowID, name string, payload []byte, sender, dedupKey string) (int64, error) {
+	var dedup interface{}
+	if dedupKey != "" {
+		dedup = dedupKey
+	}
+
+	var id int64
+	err := s.retryOnBusy(func() error {
+		res, err := s.db.Exec(
+			`INSERT OR IGNORE INTO signals (workflow_id, name, seq, payload, sender, dedup_key, created_at)
+			 `+nextSignalSeq,
+			workflowID, name, payload, sender, dedup, dbNow(), workflowID, name,
+		)
+		if err != nil {
+			return err
+		}
+		id, err = res.LastInsertId()
+		return err
+	})
+	return id, err
+}
+
+// InsertSignals records signals in one transaction; dedupKeys[i], if not
+// empty, deduplicates signals[i] like InsertSignal's dedupKey
+func (s *Storage) InsertSignals(signals []Signal, dedupKeys []string) error {
+	if len(signals) == 0 {
+		return nil
+	}
+	return s.retryOnBusy(func() error {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		now := dbNow()
+		for i, sig := range signals {
+			var dedup interface{}
+			if dedupKeys[i] != "" {
+				dedup = dedupKeys[i]
+			}
+			if _, err := tx.Exec(
+				`INSERT OR IGNORE INTO signals (workflow_id, name, seq, payload, sender, dedup_key, created_at)
+				 `+nextSignalSeq,
+				sig.WorkflowID, sig.Name, sig.Payload, sig.Sender, dedup, now, sig.WorkflowID, sig.Name,
+			); err != nil {
+				return err
+			}
+		}
+		return tx.Commit()
+	})
+}
+
+// StartWithSignal enqueues a workflow like CreateQueuedWorkflow unless it
+// exists, and records sig for it in the same transaction. It reports
+// whether the workflow was created.
+func (s *Storage) StartWithSignal(workflowID string, shard int, workflowName, queue string, priority int, concurrencyKey string, input []byte, sig Signal) (bool, error) {
+	key := sql.NullString{String: concurrencyKey, Valid: concurrencyKey != ""}
+	var started bool
+	err := s.retryOnBusy(func() error {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		now := dbNow()
+		res, err := tx.Exec(
+			`INSERT OR IGNORE INTO workflows (workflow_id, status, shard, workflow_name, queue, priority, concurrency_key, input, created_at, updated_at)
+			 VALUES (?, 'queued', ?, ?, ?, ?, ?, ?, ?, ?)`,
+			workflowID, shard, workflowName, queue, priority, key, input, now, now,
+		)
+		if err != nil {
+			return err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO signals (workflow_id, name, seq, payload, sender, dedup_key, created_at)
+			 `+nextSignalSeq,
+			workflowID, sig.Name, sig.Payload, sig.Sender, nil, now, workflowID, sig.Name,
+		); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		started = affected > 0
+		return nil
+	})
+	return started, err
+}
+
+// ConsumeSignal claims the oldest unconsumed signal for consumedKey. If
+// consumedKey already claimed one (a replay after a crash), that signal is
+// returned again. It returns nil when no signal is available.
+func (s *Storage) ConsumeSignal(workflowID, name, consumedKey string) (*Signal, error) {
+	err := s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			`UPDATE signals SET consumed_key = ?
+			 WHERE id = (SELECT id FROM signals
+			             WHERE workflow_id = ? AND name = ? AND consumed_key IS NULL
+			             ORDER BY id LIMIT 1)
+			   AND NOT EXISTS (SELECT 1 FROM signals WHERE consumed_key = ?)`,
+			consumedKey, workflowID, name, consumedKey,
+		)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume signal: %w", err)
+	}
+
+	var sig Signal
+	var sender sql.NullString
+	err = s.db.QueryRow(
+		"SELECT id, workflow_id, name, seq, payload, sender FROM signals WHERE consumed_key = ?",
+		consumedKey,
+	).Scan(&sig.ID, &sig.WorkflowID, &sig.Name, &sig.Seq, &sig.Payload, &sender)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load consumed signal: %w", err)
+	}
+
+	sig.Sender = sender.String
+	return &sig, nil
+}
+
+// ConsumeSignals claims up to max of the oldest unconsumed signals for
+// consumedKey, in the order they were sent. If consumedKey already claimed a
+// batch (a replay after a crash), that batch is returned again.
+func (s *Storage) ConsumeSignals(workflowID, name, consumedKey string, max int) ([]Signal, error) {
+	keys := make([]interface{}, max)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%s#%d", consumedKey, i+1)
+	}
+	inKeys := placeholders(max)
+
+	err := s.retryOnBusy(func() error {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var claimed int
+		if err := tx.QueryRow(
+			"SELECT COUNT(*) FROM signals WHERE consumed_key IN ("+inKeys+")", keys...,
+		).Scan(&claimed); err != nil {
+			return err
+		}
+		if claimed > 0 {
+			return nil
+		}
+
+		rows, err := tx.Query(
+			`SELECT id FROM signals WHERE workflow_id = ? AND name = ? AND consumed_key IS NULL
+			 ORDER BY id LIMIT ?`,
+			workflowID, name, max,
+		)
+		if err != nil {
+			return err
+		}
+		var ids []int64
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for i, id := range ids {
+			if _, err := tx.Exec("UPDATE signals SET consumed_key = ? WHERE id = ?", keys[i], id); err != nil {
+				return err
+			}
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume signals: %w", err)
+	}
+
+	rows, err := s.db.Query(
+		"SELECT id, workflow_id, name, seq, payload, sender FROM signals WHERE consumed_key IN ("+inKeys+") ORDER BY id",
+		keys...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load consumed signals: %w", err)
+	}
+	defer rows.Close()
+
+	var signals []Signal
+	for rows.Next() {
+		var sig Signal
+		var sender sql.NullString
+		if err := rows.Scan(&sig.ID, &sig.WorkflowID, &sig.Name, &sig.Seq, &sig.Payload, &sender); err != nil {
+			return nil, fmt.Errorf("failed to scan signal: %w", err)
+		}
+		sig.Sender = sender.String
+		signals = append(signals, sig)
+	}
+	return signals, rows.Err()
+}
@@ -0,0 +1,328 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInterWorkflowSignal(t *testing.T) {
+	dbPath := "./test_signal.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	type Shipment struct {
+		Tracking string
+	}
+
+	received := make(chan Shipment, 2)
+	orderDone := make(chan error, 1)
+	go func() {
+		orderDone <- eng.Execute("order-1", func(ctx *Context) error {
+			s, err := WaitForSignal[Shipment](ctx, "shipped")
+			if err != nil {
+				return err
+			}
+			received <- s
+			return nil
+		})
+	}()
+
+	// The sender crashes after signaling and replays; the signal is sent once
+	sends := 0
+	shipment := func(ctx *Context) error {
+		sends++
+		if err := ctx.SignalWorkflow("order-1", "shipped", Shipment{Tracking: "1Z999"}); err != nil {
+			return err
+		}
+		if sends == 1 {
+			return errors.New("crash after signal")
+		}
+		return nil
+	}
+	eng.Execute("shipment-1", shipment)
+	if err := eng.Execute("shipment-1", shipment); err != nil {
+		t.Fatalf("shipment workflow failed: %v", err)
+	}
+
+	select {
+	case s := <-received:
+		if s.Tracking != "1Z999" {
+			t.Errorf("unexpected payload %+v", s)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("order workflow never received the signal")
+	}
+	if err := <-orderDone; err != nil {
+		t.Fatalf("order workflow failed: %v", err)
+	}
+
+	var count int
+	eng.storage.db.QueryRow("SELECT COUNT(*) FROM signals WHERE workflow_id = 'order-1'").Scan(&count)
+	if count != 1 {
+		t.Errorf("expected exactly one signal row, got %d", count)
+	}
+}
+
+func TestSignalWorkflowRepeatedSends(t *testing.T) {
+	dbPath := "./test_signal_repeat.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	// Every send is delivered, and replaying the sender delivers none twice
+	runs := 0
+	sender := func(ctx *Context) error {
+		runs++
+		for i := 1; i <= 3; i++ {
+			if err := ctx.SignalWorkflow("meter-1", "tick", i); err != nil {
+				return err
+			}
+		}
+		if runs == 1 {
+			return errors.New("crash after sending")
+		}
+		return nil
+	}
+	eng.Execute("ticker-1", sender)
+	if err := eng.Execute("ticker-1", sender); err != nil {
+		t.Fatalf("sender failed: %v", err)
+	}
+
+	var got []int
+	err = eng.Execute("meter-1", func(ctx *Context) error {
+		msgs, err := ReceiveSignals[int](ctx, "tick", 10)
+		for _, m := range msgs {
+			got = append(got, m.Payload)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("receiver failed: %v", err)
+	}
+	if fmt.Sprint(got) != "[1 2 3]" {
+		t.Errorf("expected ticks [1 2 3], got %v", got)
+	}
+}
+
+func TestSignalBatch(t *testing.T) {
+	dbPath := "./test_signal_batch.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	var batch []SignalRequest
+	for i := 0; i < 1000; i++ {
+		batch = append(batch, SignalRequest{
+			WorkflowID: fmt.Sprintf("account-%d", i%2),
+			Name:       "deposit",
+			Payload:    i,
+			DedupKey:   fmt.Sprintf("msg-%d", i),
+		})
+	}
+	if err := eng.SignalBatch(batch); err != nil {
+		t.Fatalf("failed to send batch: %v", err)
+	}
+	// A redelivered backlog is deduplicated by message ID
+	if err := eng.SignalBatch(batch[:10]); err != nil {
+		t.Fatalf("failed to resend batch: %v", err)
+	}
+
+	var count int
+	eng.storage.db.QueryRow("SELECT COUNT(*) FROM signals WHERE workflow_id = 'account-0'").Scan(&count)
+	if count != 500 {
+		t.Errorf("expected 500 signals for account-0, got %d", count)
+	}
+
+	if err := eng.Execute("account-0", func(ctx *Context) error {
+		first, err := WaitForSignal[int](ctx, "deposit")
+		if err != nil {
+			return err
+		}
+		if first != 0 {
+			return fmt.Errorf("expected the first deposit in batch order, got %d", first)
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+
+	// One payload that can't be encoded sends none of the batch
+	err = eng.SignalBatch([]SignalRequest{
+		{WorkflowID: "account-9", Name: "deposit", Payload: 1},
+		{WorkflowID: "account-9", Name: "deposit", Payload: make(chan int)},
+	})
+	if err == nil {
+		t.Fatal("expected an unencodable payload to fail the batch")
+	}
+	eng.storage.db.QueryRow("SELECT COUNT(*) FROM signals WHERE workflow_id = 'account-9'").Scan(&count)
+	if count != 0 {
+		t.Errorf("expected no signals from a failed batch, got %d", count)
+	}
+}
+
+func TestSignalWithStart(t *testing.T) {
+	dbPath := "./test_signal_with_start.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	items := make(chan string, 10)
+	eng.Register("cart", func(ctx *Context) error {
+		var owner string
+		if err := ctx.Input(&owner); err != nil {
+			return err
+		}
+		for i := 0; i < 2; i++ {
+			item, err := WaitForSignal[string](ctx, "add-item")
+			if err != nil {
+				return err
+			}
+			items <- owner + ":" + item
+		}
+		return nil
+	})
+
+	// Concurrent callers create the run once and all deliver their signal
+	var wg sync.WaitGroup
+	for _, item := range []string{"apple", "pear"} {
+		wg.Add(1)
+		go func(item string) {
+			defer wg.Done()
+			if err := eng.SignalWithStart("cart-alice", "add-item", item, "cart", "alice"); err != nil {
+				t.Errorf("failed to signal with start: %v", err)
+			}
+		}(item)
+	}
+	wg.Wait()
+
+	var runs, signals int
+	eng.storage.db.QueryRow("SELECT COUNT(*) FROM workflows WHERE workflow_id = 'cart-alice'").Scan(&runs)
+	eng.storage.db.QueryRow("SELECT COUNT(*) FROM signals WHERE workflow_id = 'cart-alice'").Scan(&signals)
+	if runs != 1 || signals != 2 {
+		t.Fatalf("expected one run with two signals, got %d runs and %d signals", runs, signals)
+	}
+
+	if err := eng.StartWorker(WorkerConfig{PollInterval: 10 * time.Millisecond}); err != nil {
+		t.Fatalf("failed to start worker: %v", err)
+	}
+	got := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case item := <-items:
+			got[item] = true
+		case <-time.After(2 * time.Second):
+			t.Fatal("cart never received its items")
+		}
+	}
+	if !got["alice:apple"] || !got["alice:pear"] {
+		t.Errorf("expected both items for alice, got %v", got)
+	}
+}
+
+func TestReceiveSignalsBuffersEarlySignals(t *testing.T) {
+	dbPath := "./test_signal_buffer.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	// Every event arrives before the workflow starts listening
+	for i := 1; i <= 5; i++ {
+		if err := eng.Signal("ledger-1", "event", i*10); err != nil {
+			t.Fatalf("failed to signal: %v", err)
+		}
+	}
+	eng.Signal("ledger-1", "other", "ignored")
+
+	var batches [][]SignalMessage[int]
+	var last int
+	ledger := func(ctx *Context) error {
+		batches = nil
+		for i := 0; i < 2; i++ {
+			batch, err := ReceiveSignals[int](ctx, "event", 2)
+			if err != nil {
+				return err
+			}
+			batches = append(batches, batch)
+		}
+		var err error
+		last, err = WaitForSignal[int](ctx, "event")
+		return err
+	}
+	if err := eng.Execute("ledger-1", ledger); err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+
+	if len(batches) != 2 || len(batches[0]) != 2 || len(batches[1]) != 2 {
+		t.Fatalf("expected two batches of two, got %v", batches)
+	}
+	for i, msg := range append(batches[0], batches[1]...) {
+		if msg.Seq != int64(i+1) || msg.Payload != (i+1)*10 {
+			t.Errorf("message %d: expected #%d = %d, got #%d = %d", i, i+1, (i+1)*10, msg.Seq, msg.Payload)
+		}
+	}
+	if last != 50 {
+		t.Errorf("expected the fifth event last, got %d", last)
+	}
+
+	// Running the finished ledger again consumes nothing new
+	eng.Signal("ledger-1", "event", 60)
+	if err := eng.Execute("ledger-1", ledger); err != nil {
+		t.Fatalf("rerun failed: %v", err)
+	}
+	var pending int
+	eng.storage.db.QueryRow("SELECT COUNT(*) FROM signals WHERE name = 'event' AND consumed_key IS NULL").Scan(&pending)
+	if pending != 1 {
+		t.Errorf("expected the late event to stay buffered, got %d pending", pending)
+	}
+}
+
+func TestSignalWithStartRecordsOptionsWithTheRow(t *testing.T) {
+	dbPath := "./test_signal_with_start_atomic.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	// Recording the continuation fails, so neither the run nor the signal may be recorded
+	eng.storage.db.Exec("DROP TABLE continuations")
+	err = eng.SignalWithStart("cart-1", "add-item", "sku-1", "checkout", nil, WithOnComplete("ship-order"))
+	if err == nil {
+		t.Fatal("expected signal with start to fail")
+	}
+	if _, err := eng.storage.GetWorkflowStatus("cart-1"); !errors.Is(err, ErrWorkflowNotFound) {
+		t.Errorf("expected no workflow row, got %v", err)
+	}
+	var signals int
+	eng.storage.db.QueryRow("SELECT COUNT(*) FROM signals WHERE workflow_id = 'cart-1'").Scan(&signals)
+	if signals != 0 {
+		t.Errorf("expected no signal recorded, got %d", signals)
+	}
+}
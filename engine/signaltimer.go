@@ -0,0 +1,210 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// TimerKindSignal is the kind of timers started with ctx.StartTimer
+const TimerKindSignal = "signal"
+
+// timerSignal is the payload of a TimerKindSignal timer
+type timerSignal struct {
+	Signal  string `json:"signal"`
+	Payload []byte `json:"payload,omitempty"`
+}
+
+// StartTimer starts a durable timer that sends the signal name to this
+// workflow after d, for reminders and escalations. Wait for it like any
+// signal; CancelTimer stops it. Starting a timer with the name of one that is
+// still pending starts a second one.
+func (ctx *Context) StartTimer(name string, d time.Duration) error {
+	return ctx.StartTimerSignal(name, name, d, nil)
+}
+
+// StartTimerSignal is StartTimer delivering payload as the signal named
+// signal, so a workflow can wait for a response or the timer with one
+// WaitForSignal:
+//
+//	ctx.StartTimerSignal("escalate", "decision", 48*time.Hour, Decision{Escalated: true})
+//	decision, err := engine.WaitForSignal[Decision](ctx, "decision")
+//	ctx.CancelTimer("escalate")
+func (ctx *Context) StartTimerSignal(timer, signal string, d time.Duration, payload interface{}) error {
+	data, err := ctx.engine.codec.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal timer payload: %w", err)
+	}
+	encoded, err := json.Marshal(timerSignal{Signal: signal, Payload: data})
+	if err != nil {
+		return fmt.Errorf("failed to encode timer %s: %w", timer, err)
+	}
+
+	ctx.mu.Lock()
+	if ctx.timerStarts == nil {
+		ctx.timerStarts = make(map[string]int)
+	}
+	ctx.timerStarts[timer]++
+	n := ctx.timerStarts[timer]
+	ctx.mu.Unlock()
+
+	stepID := fmt.Sprintf("timer:%s:%d", timer, n)
+	_, err = Step(ctx, stepID, func() (time.Time, error) {
+		t, err := ctx.engine.ScheduleTimer(ctx.WorkflowID, ctx.timerKey(stepID), TimerKindSignal, time.Now().Add(d), encoded)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return t.FireAt, nil
+	}, withStepKind(StepKindTimer))
+	return err
+}
+
+// CancelTimer stops the timer last started under name and reports whether
+// it was stopped before the workflow received its signal. A signal that was
+// sent but not yet received is withdrawn.
+func (ctx *Context) CancelTimer(name string) (bool, error) {
+	ctx.mu.Lock()
+	n := ctx.timerStarts[name]
+	if ctx.timerCancels == nil {
+		ctx.timerCancels = make(map[string]int)
+	}
+	ctx.timerCancels[name]++
+	cancels := ctx.timerCancels[name]
+	ctx.mu.Unlock()
+	if n == 0 {
+		return false, fmt.Errorf("timer %s was not started", name)
+	}
+
+	key := ctx.timerKey(fmt.Sprintf("timer:%s:%d", name, n))
+	return Step(ctx, fmt.Sprintf("timer-cancel:%s:%d", name, cancels), func() (bool, error) {
+		canceled, err := ctx.storage.CancelSignalTimer(key)
+		if canceled {
+			ctx.engine.emit(EngineEvent{Type: EventTimerCanceled, WorkflowID: ctx.WorkflowID, Kind: TimerKindSignal, Timer: key})
+		}
+		return canceled, err
+	}, withStepKind(StepKindTimer))
+}
+
+// timerKey is the key of the timer started by a step
+func (ctx *Context) timerKey(stepID string) string {
+	return fmt.Sprintf("%s/%s", ctx.WorkflowID, stepID)
+}
+
+// deliverTimerSignal sends the signal of a fired TimerKindSignal timer. The
+// timer key deduplicates it, so a timer delivered both by the timer service
+// and by its waiting workflow sends one signal.
+func (e *Engine) deliverTimerSignal(t Timer) error {
+	var ts timerSignal
+	if err := json.Unmarshal(t.Payload, &ts); err != nil {
+		return fmt.Errorf("failed to decode timer %s: %w", t.Key, err)
+	}
+	return e.storage.InsertTimerSignal(t, ts.Signal, ts.Payload)
+}
+
+// deliverDueTimers sends the signals of a workflow's due timers, so they
+// arrive even when no timer service is running
+func (e *Engine) deliverDueTimers(workflowID string) error {
+	due, err := e.storage.ListDueWorkflowTimers(workflowID, TimerKindSignal, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, t := range due {
+		if _, err := e.fireTimer(t); err != nil {
+			return err
+		}
+		if err := e.deliverTimerSignal(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListDueWorkflowTimers lists a workflow's pending timers of a kind that are due
+func (s *Storage) ListDueWorkflowTimers(workflowID, kind string, now time.Time) ([]Timer, error) {
+	rows, err := s.db.Query(
+		`SELECT id, timer_key, workflow_id, kind, fire_at_ms, status, payload
+		 FROM timers WHERE workflow_id = ? AND kind = ? AND status = 'pending' AND fire_at_ms <= ?`,
+		workflowID, kind, now.UnixMilli(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due timers: %w", err)
+	}
+	defer rows.Close()
+
+	var timers []Timer
+	for rows.Next() {
+		var t Timer
+		var fireAtMs int64
+		if err := rows.Scan(&t.ID, &t.Key, &t.WorkflowID, &t.Kind, &fireAtMs, &t.Status, &t.Payload); err != nil {
+			return nil, fmt.Errorf("failed to scan timer: %w", err)
+		}
+		t.FireAt = time.UnixMilli(fireAtMs)
+		timers = append(timers, t)
+	}
+	return timers, rows.Err()
+}
+
+// InsertTimerSignal records the signal of a fired timer, unless the timer
+// was canceled meanwhile or its signal was already recorded
+func (s *Storage) InsertTimerSignal(t Timer, name string, payload []byte) error {
+	return s.retryOnBusy(func() error {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var status string
+		if err := tx.QueryRow("SELECT status FROM timers WHERE timer_key = ?", t.Key).Scan(&status); err != nil {
+			return err
+		}
+		if status != "fired" {
+			return nil
+		}
+		if _, err := tx.Exec(
+			`INSERT OR IGNORE INTO signals (workflow_id, name, seq, payload, sender, dedup_key, created_at)
+			 `+nextSignalSeq,
+			t.WorkflowID, name, payload, "timer", t.Key, dbNow(), t.WorkflowID, name,
+		); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+// CancelSignalTimer cancels a TimerKindSignal timer whose signal wasn't
+// consumed, withdrawing the signal if it was sent, and reports whether it did
+func (s *Storage) CancelSignalTimer(key string) (bool, error) {
+	var canceled bool
+	err := s.retryOnBusy(func() error {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		// A timer whose signal was received already did its job
+		res, err := tx.Exec(
+			`UPDATE timers SET status = 'canceled'
+			 WHERE timer_key = ? AND status IN ('pending', 'fired')
+			   AND NOT EXISTS (SELECT 1 FROM signals WHERE dedup_key = ? AND consumed_key IS NOT NULL)`,
+			key, key,
+		)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM signals WHERE dedup_key = ? AND consumed_key IS NULL", key); err != nil {
+			return err
+		}
+		canceled = n > 0
+		return tx.Commit()
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel timer: %w", err)
+	}
+	return canceled, nil
+}
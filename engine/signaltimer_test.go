@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+type approvalDecision struct {
+	Approved  bool
+	Escalated bool
+}
+
+func TestStartTimerEscalatesWithoutResponse(t *testing.T) {
+	dbPath := "./test_signaltimer.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	var decision approvalDecision
+	var canceled bool
+	err = eng.Execute("approval-1", func(ctx *Context) error {
+		if err := ctx.StartTimerSignal("escalate", "decision", 50*time.Millisecond, approvalDecision{Escalated: true}); err != nil {
+			return err
+		}
+		var err error
+		if decision, err = WaitForSignal[approvalDecision](ctx, "decision"); err != nil {
+			return err
+		}
+		canceled, err = ctx.CancelTimer("escalate")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	if !decision.Escalated || canceled {
+		t.Errorf("expected the timer to escalate, got %+v (canceled %v)", decision, canceled)
+	}
+}
+
+func TestCancelTimerAfterResponse(t *testing.T) {
+	dbPath := "./test_signaltimer_cancel.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	if err := eng.Signal("approval-2", "decision", approvalDecision{Approved: true}); err != nil {
+		t.Fatalf("failed to signal: %v", err)
+	}
+	var decision approvalDecision
+	var canceled bool
+	err = eng.Execute("approval-2", func(ctx *Context) error {
+		if err := ctx.StartTimerSignal("escalate", "decision", time.Hour, approvalDecision{Escalated: true}); err != nil {
+			return err
+		}
+		if err := ctx.StartTimer("remind", time.Minute); err != nil {
+			return err
+		}
+		var err error
+		if decision, err = WaitForSignal[approvalDecision](ctx, "decision"); err != nil {
+			return err
+		}
+		canceled, err = ctx.CancelTimer("escalate")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	if !decision.Approved || !canceled {
+		t.Errorf("expected the response to win and the timer to be canceled, got %+v (canceled %v)", decision, canceled)
+	}
+
+	// The timer service delivers pending timers but not canceled ones
+	if err := eng.fireDueTimers(time.Now().Add(2 * time.Hour)); err != nil {
+		t.Fatalf("failed to fire timers: %v", err)
+	}
+	counts := map[string]int{}
+	rows, err := eng.storage.db.Query("SELECT name FROM signals WHERE workflow_id = 'approval-2'")
+	if err != nil {
+		t.Fatalf("failed to list signals: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		rows.Scan(&name)
+		counts[name]++
+	}
+	if counts["decision"] != 1 || counts["remind"] != 1 {
+		t.Errorf("expected only the approval and the reminder signal, got %v", counts)
+	}
+}
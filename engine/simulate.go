@@ -0,0 +1,174 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// StubFunc stands in for a step body during simulation
+type StubFunc func() (interface{}, error)
+
+// SimulateOption configures a single Simulate call
+type SimulateOption func(*simulateOptions)
+
+type simulateOptions struct {
+	stubs    map[string]StubFunc
+	input    interface{}
+	hasInput bool
+	params   map[string]string
+}
+
+// WithStub replaces the body of the step with the given ID. The stub's result
+// is round-tripped through JSON into the step's result type, so a stub that
+// could never have been persisted fails the simulated step. Steps without a
+// stub return their zero value.
+func WithStub(stepID string, stub StubFunc) SimulateOption {
+	return func(o *simulateOptions) {
+		o.stubs[stepID] = stub
+	}
+}
+
+// WithSimulatedInput sets the start input returned by ctx.Input
+func WithSimulatedInput(input interface{}) SimulateOption {
+	return func(o *simulateOptions) {
+		o.input, o.hasInput = input, true
+	}
+}
+
+// WithSimulatedParams sets the template parameters returned by ctx.Params
+func WithSimulatedParams(params map[string]string) SimulateOption {
+	return func(o *simulateOptions) {
+		o.params = params
+	}
+}
+
+// SimulatedStep is one step the workflow would have executed
+type SimulatedStep struct {
+	StepID      string
+	SequenceNum int64
+	Kind        string
+	Stubbed     bool   // false if the step had no stub and returned its zero value
+	Output      []byte // JSON-encoded result handed back to the workflow
+	Error       string
+}
+
+// Simulation is the would-be step graph of a dry run, in call order
+type Simulation struct {
+	WorkflowID string
+	Steps      []SimulatedStep
+	Err        error // the workflow function's own return value
+	Duration   time.Duration
+}
+
+// simulation collects steps while a simulated workflow runs
+type simulation struct {
+	stubs  map[string]StubFunc
+	replay *replaySession // non-nil during Engine.Replay
+
+	mu    sync.Mutex
+	steps []SimulatedStep
+}
+
+// Simulate dry-runs a workflow: every step body, including sleeps, signal
+// waits and child starts, is replaced by a stub, and all state lives in a
+// scratch in-memory database. Nothing is read from or written to the engine's
+// database, so new workflow code can be validated against production config.
+func (e *Engine) Simulate(workflowID string, workflowFn func(*Context) error, opts ...SimulateOption) (*Simulation, error) {
+	o := &simulateOptions{stubs: make(map[string]StubFunc)}
+	for _, opt := range opts {
+		opt(o)
+	}
+	var input []byte
+	if o.hasInput {
+		data, err := e.codec.Marshal(o.input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal simulated input: %w", err)
+		}
+		input = data
+	}
+
+	scratch, err := NewStorage(":memory:")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create simulation storage: %w", err)
+	}
+	defer scratch.Close()
+
+	sim := &simulation{stubs: o.stubs}
+	ctx := &Context{
+		WorkflowID:     workflowID,
+		engine:         e,
+		storage:        scratch,
+		completedSteps: make(map[string][]byte),
+		stepIDToSeq:    make(map[string]int64),
+		input:          input,
+		params:         o.params,
+		sim:            sim,
+		eg:             &errgroup.Group{},
+	}
+	ctx.runCtx, ctx.cancelRun = context.WithCancel(context.Background())
+	defer ctx.cancelRun()
+	defer ctx.releaseLocks()
+
+	start := time.Now()
+	runErr := workflowFn(ctx)
+
+	return &Simulation{
+		WorkflowID: workflowID,
+		Steps:      sim.steps,
+		Err:        runErr,
+		Duration:   time.Since(start),
+	}, nil
+}
+
+// simulateStep runs a step's stub in place of its body and records it
+func simulateStep[T any](ctx *Context, id, stepKey string, seqNum int64, kind string) (T, error) {
+	if ctx.sim.replay != nil {
+		return replayStep[T](ctx, id, stepKey, seqNum, kind)
+	}
+
+	var zero T
+	step := SimulatedStep{StepID: id, SequenceNum: seqNum, Kind: kind}
+
+	var value interface{} = zero
+	var err error
+	if stub, ok := ctx.sim.stubs[id]; ok {
+		step.Stubbed = true
+		value, err = stub()
+	}
+
+	var result T
+	if err == nil {
+		step.Output, err = ctx.engine.codec.Marshal(value)
+		if err == nil {
+			err = ctx.engine.validateStepOutput(id, step.Output)
+		}
+		if err == nil {
+			if uerr := ctx.engine.codec.Unmarshal(step.Output, &result); uerr != nil {
+				err = fmt.Errorf("stub for %s returned %T, not usable as the step result: %w", id, value, uerr)
+			}
+		}
+	}
+	if err != nil {
+		step.Output = nil
+		step.Error = err.Error()
+	}
+
+	ctx.sim.mu.Lock()
+	ctx.sim.steps = append(ctx.sim.steps, step)
+	ctx.sim.mu.Unlock()
+
+	if err != nil {
+		return zero, err
+	}
+
+	// Repeated calls with the same ID replay the first result, as in a real run
+	ctx.mu.Lock()
+	ctx.completedSteps[stepKey] = step.Output
+	ctx.mu.Unlock()
+
+	return result, nil
+}
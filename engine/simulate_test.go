@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSimulate(t *testing.T) {
+	dbPath := "./test_simulate.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	type order struct {
+		Amount int `json:"amount"`
+	}
+	charged := false
+
+	sim, err := eng.Simulate("order-sim", func(ctx *Context) error {
+		var o order
+		if err := ctx.Input(&o); err != nil {
+			return err
+		}
+		quote, err := Step(ctx, "quote", func() (int, error) { return 0, errors.New("real body ran") })
+		if err != nil {
+			return err
+		}
+		if quote != o.Amount*2 {
+			return errors.New("unexpected quote")
+		}
+		if err := ctx.Sleep("cool-off", 24*time.Hour); err != nil {
+			return err
+		}
+		approved, err := WaitForSignal[bool](ctx, "approval")
+		if err != nil {
+			return err
+		}
+		if approved {
+			_, err = Step(ctx, "charge", func() (string, error) {
+				charged = true
+				return "ch_1", nil
+			})
+		}
+		return err
+	},
+		WithSimulatedInput(order{Amount: 21}),
+		WithStub("quote", func() (interface{}, error) { return 42, nil }),
+		WithStub("signal:approval:1", func() (interface{}, error) { return true, nil }),
+	)
+	if err != nil {
+		t.Fatalf("failed to simulate: %v", err)
+	}
+	if sim.Err != nil {
+		t.Fatalf("simulated workflow failed: %v", sim.Err)
+	}
+	if charged {
+		t.Error("expected step bodies not to run")
+	}
+
+	want := []struct {
+		id      string
+		kind    string
+		stubbed bool
+	}{
+		{"quote", StepKindStep, true},
+		{"cool-off", StepKindSleep, false},
+		{"signal:approval:1", StepKindSignal, true},
+		{"charge", StepKindStep, false},
+	}
+	if len(sim.Steps) != len(want) {
+		t.Fatalf("expected %d steps, got %+v", len(want), sim.Steps)
+	}
+	for i, w := range want {
+		got := sim.Steps[i]
+		if got.StepID != w.id || got.Kind != w.kind || got.Stubbed != w.stubbed || got.SequenceNum != int64(i+1) {
+			t.Errorf("step %d: expected %+v, got %+v", i, w, got)
+		}
+	}
+
+	// Nothing reached the real database
+	if _, err := eng.GetWorkflowStatus("order-sim"); !errors.Is(err, ErrWorkflowNotFound) {
+		t.Errorf("expected no workflow record, got %v", err)
+	}
+	if timers, err := eng.storage.ListDueTimers(time.Now().Add(48 * time.Hour)); err != nil || len(timers) != 0 {
+		t.Errorf("expected no timers, got %v (%v)", timers, err)
+	}
+}
+
+func TestSimulateStubErrors(t *testing.T) {
+	dbPath := "./test_simulate_err.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	sim, err := eng.Simulate("sim-err", func(ctx *Context) error {
+		if _, err := Step(ctx, "count", func() (int, error) { return 1, nil }); err != nil {
+			return err
+		}
+		_, err := Step(ctx, "never", func() (int, error) { return 1, nil })
+		return err
+	}, WithStub("count", func() (interface{}, error) { return "not a number", nil }))
+	if err != nil {
+		t.Fatalf("failed to simulate: %v", err)
+	}
+	if sim.Err == nil {
+		t.Fatal("expected a stub type mismatch to fail the workflow")
+	}
+	if len(sim.Steps) != 1 || sim.Steps[0].Error == "" {
+		t.Errorf("expected one failed step, got %+v", sim.Steps)
+	}
+}
@@ -0,0 +1,122 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Set stores a value under key for the rest of the run, for small mutable
+// state that isn't naturally a step result, such as pagination cursors or
+// counters. Each call is recorded like a step, so a resumed run sees the value
+// the original execution stored at the same point, even if the code computing
+// it isn't deterministic. Calls from concurrent Go functions must not race on
+// the same key.
+func (ctx *Context) Set(key string, v interface{}) error {
+	data, err := ctx.engine.codec.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal state %s: %w", key, err)
+	}
+
+	ctx.mu.Lock()
+	if ctx.stateSets == nil {
+		ctx.stateSets = make(map[string]int)
+	}
+	ctx.stateSets[key]++
+	n := ctx.stateSets[key]
+	ctx.mu.Unlock()
+
+	recorded, err := ctx.recordValue(fmt.Sprintf("state:%s:%d", key, n), StepKindState, data)
+	if err != nil {
+		return &StepError{StepID: "state:" + key, Err: err}
+	}
+
+	ctx.mu.Lock()
+	if ctx.state == nil {
+		ctx.state = make(map[string][]byte)
+	}
+	ctx.state[key] = recorded
+	ctx.mu.Unlock()
+	return nil
+}
+
+// Get decodes the value last stored under key into out and reports whether
+// one was set
+func (ctx *Context) Get(key string, out interface{}) (bool, error) {
+	ctx.mu.Lock()
+	data, ok := ctx.state[key]
+	ctx.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+	if err := ctx.engine.codec.Unmarshal(data, out); err != nil {
+		return true, fmt.Errorf("failed to unmarshal state %s: %w", key, err)
+	}
+	return true, nil
+}
+
+// recordValue durably records already-encoded data as a completed step, or
+// returns the data recorded by an earlier execution of the same step
+func (ctx *Context) recordValue(id, kind string, data []byte) ([]byte, error) {
+	seqNum, err := ctx.stepSequence(id)
+	if err != nil {
+		return nil, err
+	}
+	stepKey := generateStepKey(id, seqNum)
+
+	ctx.mu.Lock()
+	cached, ok := ctx.completedSteps[stepKey]
+	ctx.mu.Unlock()
+	if ok && cached != nil {
+		return cached, nil
+	}
+
+	output, found, err := ctx.storedStep(stepKey, seqNum)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check step in database: %w", err)
+	}
+	if found && output != nil {
+		ctx.mu.Lock()
+		ctx.completedSteps[stepKey] = output
+		ctx.mu.Unlock()
+		return output, nil
+	}
+
+	if err := ctx.doneErr(); err != nil {
+		return nil, err
+	}
+	// Dry runs keep state in memory only
+	if ctx.sim != nil {
+		return data, nil
+	}
+
+	if err := ctx.markStepInProgress(stepKey, id, seqNum, kind, "", nil); err != nil {
+		return nil, err
+	}
+	if err := ctx.recordStep(stepKey, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// GetWorkflowState returns the latest value stored under each key of a run
+// with ctx.Set, encoded with the engine's codec
+func (e *Engine) GetWorkflowState(workflowID string) (map[string][]byte, error) {
+	steps, err := e.GetHistory(workflowID)
+	if err != nil {
+		return nil, err
+	}
+
+	state := make(map[string][]byte)
+	for _, st := range steps {
+		if st.Kind != StepKindState || st.Status != "completed" {
+			continue
+		}
+		// Step IDs are "state:<key>:<n>"
+		key := strings.TrimPrefix(st.StepID, "state:")
+		if i := strings.LastIndex(key, ":"); i >= 0 {
+			key = key[:i]
+		}
+		state[key] = st.Output
+	}
+	return state, nil
+}
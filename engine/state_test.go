@@ -0,0 +1,56 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestWorkflowStateSurvivesResume(t *testing.T) {
+	dbPath := "./test_state.db"
+	defer os.Remove(dbPath)
+
+	engine, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	// source stands in for something outside the workflow's control
+	source, crash := 1, true
+	var seen []int
+	workflow := func(ctx *Context) error {
+		var cursor int
+		if ok, err := ctx.Get("cursor", &cursor); err != nil || ok {
+			t.Errorf("expected no cursor at the start of the run, got %v, %v", ok, err)
+		}
+		if err := ctx.Set("cursor", source); err != nil {
+			return err
+		}
+		ctx.Get("cursor", &cursor)
+		seen = append(seen, cursor)
+
+		if crash {
+			return errors.New("crash")
+		}
+		return ctx.Set("cursor", cursor+1)
+	}
+
+	engine.Execute("state-1", workflow)
+	source, crash = 99, false
+	if err := engine.Execute("state-1", workflow); err != nil {
+		t.Fatalf("failed to resume: %v", err)
+	}
+
+	if len(seen) != 2 || seen[0] != 1 || seen[1] != 1 {
+		t.Errorf("expected the resumed run to read the recorded cursor, saw %v", seen)
+	}
+
+	state, err := engine.GetWorkflowState("state-1")
+	if err != nil {
+		t.Fatalf("failed to get state: %v", err)
+	}
+	if string(state["cursor"]) != "2" {
+		t.Errorf("expected cursor 2, got %q", state["cursor"])
+	}
+}
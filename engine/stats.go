@@ -0,0 +1,180 @@
+package engine
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Stats is a cheap summary of engine state for health dashboards
+type Stats struct {
+	ByStatus           map[string]int // workflow counts keyed by status
+	OldestRunningID    string
+	OldestRunningSince time.Time
+	DBSizeBytes        int64
+}
+
+// Stats returns workflow counts by status, the oldest running workflow and
+// the database size. It runs two indexed queries and is safe to poll often.
+func (e *Engine) Stats() (*Stats, error) {
+	return e.reads.Stats()
+}
+
+// Stats computes the engine summary
+func (s *Storage) Stats() (*Stats, error) {
+	stats := &Stats{ByStatus: make(map[string]int)}
+
+	rows, err := s.db.Query("SELECT status, COUNT(*) FROM workflows GROUP BY status")
+	if err != nil {
+		return nil, fmt.Errorf("failed to count workflows: %w", err)
+	}
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan workflow count: %w", err)
+		}
+		stats.ByStatus[status] = count
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var oldestID sql.NullString
+	var oldestSince sql.NullTime
+	err = s.db.QueryRow(
+		"SELECT workflow_id, created_at FROM workflows WHERE status = 'running' ORDER BY created_at LIMIT 1",
+	).Scan(&oldestID, &oldestSince)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to find oldest running workflow: %w", err)
+	}
+	stats.OldestRunningID = oldestID.String
+	stats.OldestRunningSince = oldestSince.Time
+
+	var pageCount, pageSize int64
+	if err := s.db.QueryRow("PRAGMA page_count").Scan(&pageCount); err != nil {
+		return nil, fmt.Errorf("failed to read page count: %w", err)
+	}
+	if err := s.db.QueryRow("PRAGMA page_size").Scan(&pageSize); err != nil {
+		return nil, fmt.Errorf("failed to read page size: %w", err)
+	}
+	stats.DBSizeBytes = pageCount * pageSize
+
+	return stats, nil
+}
+
+// QueueStats is the backlog of one queue
+type QueueStats struct {
+	Queue          string
+	Queued         int
+	Running        int
+	OldestQueuedAt time.Time // zero when nothing is queued
+}
+
+// QueueStats returns the queued and running workflows of every queue with any
+func (e *Engine) QueueStats() ([]QueueStats, error) {
+	return e.reads.QueueStats()
+}
+
+// StepActivity counts steps that finished after an event ID
+type StepActivity struct {
+	Completed   int
+	Failed      int
+	LastEventID int64 // pass to the next call to count only newer steps
+}
+
+// StepActivity counts steps completed or failed after event afterID; with
+// EventsFromNow it only returns the current LastEventID. Polling it with the
+// previous LastEventID gives step throughput and failure rate.
+func (e *Engine) StepActivity(afterID int64) (*StepActivity, error) {
+	return e.reads.StepActivity(afterID)
+}
+
+// QueueStats groups pending workflows by queue
+func (s *Storage) QueueStats() ([]QueueStats, error) {
+	rows, err := s.db.Query(
+		`SELECT queue, status, COUNT(*), MIN(created_at) FROM workflows
+		 WHERE status IN ('queued', 'running')
+		 GROUP BY queue, status ORDER BY queue`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count queue backlog: %w", err)
+	}
+	defer rows.Close()
+
+	var queues []QueueStats
+	for rows.Next() {
+		var queue, status string
+		var count int
+		var oldest interface{}
+		if err := rows.Scan(&queue, &status, &count, &oldest); err != nil {
+			return nil, fmt.Errorf("failed to scan queue backlog: %w", err)
+		}
+		if len(queues) == 0 || queues[len(queues)-1].Queue != queue {
+			queues = append(queues, QueueStats{Queue: queue})
+		}
+		q := &queues[len(queues)-1]
+		if status == "running" {
+			q.Running = count
+			continue
+		}
+		q.Queued = count
+		q.OldestQueuedAt = aggregateTime(oldest)
+	}
+	return queues, rows.Err()
+}
+
+// StepActivity counts step completions and failures recorded after afterID
+func (s *Storage) StepActivity(afterID int64) (*StepActivity, error) {
+	latest, err := s.LatestEventID()
+	if err != nil {
+		return nil, err
+	}
+	activity := &StepActivity{LastEventID: latest}
+	if afterID == EventsFromNow {
+		return activity, nil
+	}
+
+	rows, err := s.db.Query(
+		`SELECT status, COUNT(*) FROM workflow_events
+		 WHERE id > ? AND id <= ? AND step_id IS NOT NULL AND status IN ('completed', 'failed')
+		 GROUP BY status`,
+		afterID, latest,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count step activity: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan step activity: %w", err)
+		}
+		if status == "completed" {
+			activity.Completed = count
+		} else {
+			activity.Failed = count
+		}
+	}
+	return activity, rows.Err()
+}
+
+// aggregateTime converts a MIN or MAX over a TIMESTAMP column, which the
+// driver returns untyped, to a time
+func aggregateTime(v interface{}) time.Time {
+	switch t := v.(type) {
+	case time.Time:
+		return t
+	case string:
+		for _, layout := range []string{"2006-01-02 15:04:05.999999999", time.RFC3339Nano} {
+			if parsed, err := time.Parse(layout, t); err == nil {
+				return parsed
+			}
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestEngineStats(t *testing.T) {
+	dbPath := "./test_stats.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	eng.Execute("ok-1", func(ctx *Context) error { return nil })
+	eng.Execute("bad-1", func(ctx *Context) error { return errors.New("boom") })
+	eng.Enqueue("later-1", "report", nil)
+	eng.storage.CreateWorkflow("stuck-1", 0)
+
+	stats, err := eng.Stats()
+	if err != nil {
+		t.Fatalf("failed to get stats: %v", err)
+	}
+
+	want := map[string]int{"completed": 1, "failed": 1, "queued": 1, "running": 1}
+	for status, n := range want {
+		if stats.ByStatus[status] != n {
+			t.Errorf("expected %d %s workflows, got %d", n, status, stats.ByStatus[status])
+		}
+	}
+	if stats.OldestRunningID != "stuck-1" || stats.OldestRunningSince.IsZero() {
+		t.Errorf("expected stuck-1 as oldest running, got %q since %v", stats.OldestRunningID, stats.OldestRunningSince)
+	}
+	if stats.DBSizeBytes <= 0 {
+		t.Errorf("expected a positive database size, got %d", stats.DBSizeBytes)
+	}
+}
+
+func TestQueueStatsAndStepActivity(t *testing.T) {
+	dbPath := "./test_stats_queues.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	eng.Enqueue("report-1", "report", nil)
+	eng.Enqueue("report-2", "report", nil)
+	eng.Enqueue("mail-1", "mail", nil, WithQueue("email"))
+	eng.storage.CreateWorkflow("running-1", 0)
+
+	queues, err := eng.QueueStats()
+	if err != nil {
+		t.Fatalf("failed to get queue stats: %v", err)
+	}
+	if len(queues) != 2 || queues[0].Queue != "default" || queues[1].Queue != "email" {
+		t.Fatalf("unexpected queues: %+v", queues)
+	}
+	if queues[0].Queued != 2 || queues[0].Running != 1 || queues[0].OldestQueuedAt.IsZero() {
+		t.Errorf("unexpected default queue: %+v", queues[0])
+	}
+	if queues[1].Queued != 1 || queues[1].Running != 0 {
+		t.Errorf("unexpected email queue: %+v", queues[1])
+	}
+
+	start, err := eng.StepActivity(EventsFromNow)
+	if err != nil {
+		t.Fatalf("failed to get step activity: %v", err)
+	}
+	eng.Execute("busy-1", func(ctx *Context) error {
+		Step(ctx, "a", func() (int, error) { return 1, nil })
+		Step(ctx, "b", func() (int, error) { return 2, nil })
+		_, err := Step(ctx, "c", func() (int, error) { return 0, errors.New("boom") })
+		return err
+	})
+
+	activity, err := eng.StepActivity(start.LastEventID)
+	if err != nil {
+		t.Fatalf("failed to get step activity: %v", err)
+	}
+	if activity.Completed != 2 || activity.Failed != 1 || activity.LastEventID <= start.LastEventID {
+		t.Errorf("unexpected activity: %+v", activity)
+	}
+}
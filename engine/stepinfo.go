@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// StepInfo describes the execution of a step to its function
+type StepInfo struct {
+	WorkflowID   string
+	WorkflowName string // registered name the run was started under; "" if anonymous
+	Headers      map[string]string
+	Params       Params
+
+	StepID  string
+	StepKey string
+	// Attempt is the 1-based number of this attempt, counted across resumes
+	// of the run, so a step retried after a crash sees 2, not 1
+	Attempt int
+	// FirstScheduled is when the step's first attempt started
+	FirstScheduled time.Time
+	// RetriesLeft is how many more attempts the step's retry policy allows
+	// in this execution, capped by the run's retry budget
+	RetriesLeft int
+	// RetryBudget is what is left of the run's retry budget; nil unless the
+	// run was started WithRetryBudget
+	RetryBudget *RetryBudgetLeft
+
+	// FencingToken increases every time the run is claimed. Pass it to
+	// external systems that support fencing so they can reject writes from
+	// an execution that has since been replaced.
+	FencingToken int64
+
+	// TraceID and SpanID identify this attempt in the run's trace (see
+	// TraceParent); an interceptor can record a span under them
+	TraceID string
+	SpanID  string
+
+	traceFlags  string
+	idempotency IdempotencyScope
+}
+
+// RetryBudgetLeft is the remainder of a run's RetryBudget; limits the budget
+// doesn't set are -1
+type RetryBudgetLeft struct {
+	Retries int
+	Time    time.Duration
+}
+
+// StepWithInfo is Step for functions that need to know about their
+// execution, e.g. to derive idempotency keys or to degrade on late attempts.
+// Each attempt gets fresh info.
+func StepWithInfo[T any](ctx *Context, id string, fn func(info StepInfo) (T, error), opts ...StepOption) (T, error) {
+	so := ctx.newStepOptions(opts)
+	return Step(ctx, id, func() (T, error) {
+		info, err := ctx.stepInfo(id, so)
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+		return fn(info)
+	}, opts...)
+}
+
+// stepInfo describes the running attempt of a step
+func (ctx *Context) stepInfo(id string, so *stepOptions) (StepInfo, error) {
+	seqNum, err := ctx.stepSequence(id)
+	if err != nil {
+		return StepInfo{}, err
+	}
+	stepKey := generateStepKey(id, seqNum)
+
+	name, err := ctx.storage.GetWorkflowName(ctx.WorkflowID)
+	if err != nil {
+		return StepInfo{}, err
+	}
+	first, err := ctx.storage.GetStepScheduledAt(ctx.WorkflowID, stepKey)
+	if err != nil {
+		return StepInfo{}, err
+	}
+
+	ctx.mu.Lock()
+	attempt, executed := ctx.attemptNums[stepKey], ctx.attempts[stepKey]
+	ctx.mu.Unlock()
+
+	info := StepInfo{
+		WorkflowID:     ctx.WorkflowID,
+		WorkflowName:   name,
+		Headers:        ctx.Headers(),
+		Params:         ctx.Params(),
+		StepID:         id,
+		StepKey:        stepKey,
+		Attempt:        attempt,
+		FirstScheduled: first,
+		FencingToken:   ctx.fencingToken,
+		idempotency:    so.idempotency,
+	}
+	info.TraceID, info.SpanID, info.traceFlags = stepTrace(ctx.WorkflowID, ctx.Header(TraceParentHeader), stepKey, attempt)
+	if so.retry != nil {
+		info.RetriesLeft = max(so.retry.MaxAttempts-executed, 0)
+	}
+	if ctx.retryBudget != nil {
+		left := ctx.retryBudget.left()
+		info.RetryBudget = &left
+		if left.Retries >= 0 {
+			info.RetriesLeft = min(info.RetriesLeft, left.Retries)
+		}
+	}
+	return info, nil
+}
+
+// GetStepScheduledAt returns when a step's first attempt started, or the zero
+// time if it has not been attempted
+func (s *Storage) GetStepScheduledAt(workflowID, stepKey string) (time.Time, error) {
+	var first time.Time
+	err := s.db.QueryRow(
+		"SELECT started_at FROM step_attempts WHERE workflow_id = ? AND step_key = ? AND attempt = 1",
+		workflowID, stepKey,
+	).Scan(&first)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get step schedule time: %w", err)
+	}
+	return first, nil
+}
@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStepWithInfo(t *testing.T) {
+	dbPath := "./test_stepinfo.db"
+	defer os.Remove(dbPath)
+
+	engine, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	var infos []StepInfo
+	workflow := func(failures int) func(*Context) error {
+		return func(ctx *Context) error {
+			_, err := StepWithInfo(ctx, "charge", func(info StepInfo) (string, error) {
+				infos = append(infos, info)
+				if len(infos) <= failures {
+					return "", errors.New("gateway timeout")
+				}
+				return "ok", nil
+			}, WithRetry(RetryPolicy{MaxAttempts: 3, InitialInterval: time.Millisecond}))
+			return err
+		}
+	}
+
+	// Three failed attempts fail the run; resuming it continues the numbering
+	if err := engine.Execute("info-1", workflow(3), WithHeader("tenant", "acme"),
+		WithRetryBudget(RetryBudget{MaxRetries: 1})); err == nil {
+		t.Fatal("expected the run to fail once the retry budget is spent")
+	}
+	if err := engine.Execute("info-1", workflow(2)); err != nil {
+		t.Fatalf("expected the resumed run to succeed: %v", err)
+	}
+
+	if len(infos) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(infos))
+	}
+	first := infos[0]
+	if first.WorkflowID != "info-1" || first.StepID != "charge" || first.StepKey != "charge:1" {
+		t.Errorf("unexpected step identity: %+v", first)
+	}
+	if first.Headers["tenant"] != "acme" {
+		t.Errorf("expected run headers in step info, got %v", first.Headers)
+	}
+	if first.FirstScheduled.IsZero() {
+		t.Error("expected the first attempt's start time")
+	}
+
+	// The budget allows one retry; it is stored with the run, so the resume has none left
+	for i, want := range []struct{ attempt, retriesLeft int }{{1, 1}, {2, 0}, {3, 0}} {
+		if infos[i].Attempt != want.attempt || infos[i].RetriesLeft != want.retriesLeft {
+			t.Errorf("attempt %d: expected attempt %d with %d retries left, got %d with %d",
+				i, want.attempt, want.retriesLeft, infos[i].Attempt, infos[i].RetriesLeft)
+		}
+		if !infos[i].FirstScheduled.Equal(first.FirstScheduled) {
+			t.Errorf("attempt %d: expected first scheduled %v, got %v", i, first.FirstScheduled, infos[i].FirstScheduled)
+		}
+	}
+	if first.RetryBudget == nil || first.RetryBudget.Retries != 1 || first.RetryBudget.Time != -1 {
+		t.Errorf("expected 1 budgeted retry and no time limit, got %+v", first.RetryBudget)
+	}
+	if infos[2].RetryBudget == nil || infos[2].RetryBudget.Retries != 0 {
+		t.Errorf("expected the resumed run's budget to be spent, got %+v", infos[2].RetryBudget)
+	}
+}
@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -10,29 +11,53 @@ import (
 	_ "modernc.org/sqlite"
 )
 
+// ErrWorkflowNotFound is returned when a workflow ID has no stored record
+var ErrWorkflowNotFound = errors.New("workflow not found")
+
+// ErrStatusConflict is returned when a workflow's status changed since it was read,
+// e.g. another process completed or canceled it while this one was running it
+var ErrStatusConflict = errors.New("workflow status changed concurrently")
+
 type Storage struct {
-	db *sql.DB
+	db        *instrumentedDB
+	busyRetry BusyRetryPolicy
+	ctx       func() context.Context // bounds busy retries; nil for none (see WithContext)
 }
 
-// NewStorage creates a new storage instance with SQLite database
-func NewStorage(dbPath string) (*Storage, error) {
-	db, err := sql.Open("sqlite", dbPath)
+// NewStorage creates a new storage instance from a file path or DSN (see parseDSN)
+func NewStorage(dsn string) (*Storage, error) {
+	ds := parseDSN(dsn)
+	db, err := ds.open()
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	// Configure SQLite for better concurrency
-	if _, err := db.Exec("PRAGMA journal_mode=WAL"); err != nil {
-		return nil, fmt.Errorf("failed to set WAL mode: %w", err)
+	// Remote databases manage their own journal and storage
+	if !ds.remote {
+		// Let Maintain reclaim free pages; only takes effect on a new database file
+		if _, err := db.Exec("PRAGMA auto_vacuum=INCREMENTAL"); err != nil {
+			return nil, fmt.Errorf("failed to set auto vacuum: %w", err)
+		}
+
+		// Configure SQLite for better concurrency
+		if _, err := db.Exec("PRAGMA journal_mode=WAL"); err != nil {
+			return nil, fmt.Errorf("failed to set WAL mode: %w", err)
+		}
+		if _, err := db.Exec("PRAGMA busy_timeout=5000"); err != nil {
+			return nil, fmt.Errorf("failed to set busy timeout: %w", err)
+		}
 	}
-	if _, err := db.Exec("PRAGMA busy_timeout=5000"); err != nil {
-		return nil, fmt.Errorf("failed to set busy timeout: %w", err)
+	if primary, ok := liteFSPrimary(ds); ok {
+		fmt.Printf("[STORAGE] %s is a LiteFS replica; writes fail until it is promoted (primary: %s)\n", dsn, primary)
 	}
 
 	// SQLite single-writer limitation
 	db.SetMaxOpenConns(1)
 
-	s := &Storage{db: db}
+	s := &Storage{
+		db:        &instrumentedDB{DB: db, metrics: &storageMetrics{ops: make(map[string]*StorageOpStats)}},
+		busyRetry: DefaultBusyRetryPolicy,
+	}
 
 	// Initialize schema
 	if err := s.initSchema(); err != nil {
@@ -44,6 +69,10 @@ func NewStorage(dbPath string) (*Storage, error) {
 
 // initSchema creates the database tables if they don't exist
 func (s *Storage) initSchema() error {
+	if err := s.scopeStepKeysToWorkflow(); err != nil {
+		return err
+	}
+
 	schema := `
 	CREATE TABLE IF NOT EXISTS workflows (
 		workflow_id TEXT PRIMARY KEY,
@@ -57,7 +86,7 @@ func (s *Storage) initSchema() error {
 		workflow_id TEXT NOT NULL,
 		step_id TEXT NOT NULL,
 		sequence_num INTEGER NOT NULL,
-		step_key TEXT UNIQUE NOT NULL,
+		step_key TEXT NOT NULL,
 		status TEXT NOT NULL,
 		output BLOB,
 		error TEXT,
@@ -67,45 +96,458 @@ func (s *Storage) initSchema() error {
 	);
 
 	CREATE INDEX IF NOT EXISTS idx_workflow_steps ON steps(workflow_id, sequence_num);
-	CREATE UNIQUE INDEX IF NOT EXISTS idx_step_key ON steps(step_key);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_workflow_step_key ON steps(workflow_id, step_key);
+
+	CREATE TABLE IF NOT EXISTS step_attempts (
+		workflow_id TEXT NOT NULL,
+		step_key TEXT NOT NULL,
+		attempt INTEGER NOT NULL,
+		worker_id TEXT,
+		status TEXT NOT NULL,
+		error TEXT,
+		started_at TIMESTAMP NOT NULL,
+		completed_at TIMESTAMP,
+		PRIMARY KEY (workflow_id, step_key, attempt)
+	);
+
+	CREATE TABLE IF NOT EXISTS step_checkpoints (
+		workflow_id TEXT NOT NULL,
+		step_key TEXT NOT NULL,
+		state BLOB,
+		updated_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (workflow_id, step_key)
+	);
+
+	CREATE TABLE IF NOT EXISTS step_sequences (
+		workflow_id TEXT NOT NULL,
+		step_id TEXT NOT NULL,
+		sequence_num INTEGER NOT NULL,
+		PRIMARY KEY (workflow_id, step_id)
+	);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_step_sequence ON step_sequences(workflow_id, sequence_num);
+
+	CREATE TABLE IF NOT EXISTS auto_steps (
+		workflow_id TEXT NOT NULL,
+		step_id TEXT NOT NULL,
+		package TEXT NOT NULL,
+		file TEXT NOT NULL,
+		line INTEGER NOT NULL,
+		function TEXT NOT NULL,
+		call_hash TEXT,
+		PRIMARY KEY (workflow_id, step_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS workers (
+		worker_id TEXT PRIMARY KEY,
+		hostname TEXT NOT NULL,
+		pid INTEGER NOT NULL,
+		version TEXT,
+		queues TEXT,
+		capacity INTEGER NOT NULL DEFAULT 0,
+		status TEXT NOT NULL,
+		heartbeat_interval_ms INTEGER NOT NULL,
+		started_at TIMESTAMP NOT NULL,
+		last_heartbeat TIMESTAMP NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS leases (
+		name TEXT PRIMARY KEY,
+		holder TEXT NOT NULL,
+		token INTEGER NOT NULL,
+		expires_at_ms INTEGER NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS active_region (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		region TEXT NOT NULL,
+		epoch INTEGER NOT NULL,
+		heartbeat_ms INTEGER NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS timers (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		timer_key TEXT UNIQUE NOT NULL,
+		workflow_id TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		fire_at_ms INTEGER NOT NULL,
+		status TEXT NOT NULL,
+		payload BLOB,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_timers_due ON timers(status, fire_at_ms);
+
+	CREATE TABLE IF NOT EXISTS schedules (
+		schedule_id TEXT PRIMARY KEY,
+		cron TEXT NOT NULL,
+		workflow_name TEXT NOT NULL,
+		input BLOB,
+		queue TEXT NOT NULL,
+		overlap TEXT NOT NULL,
+		catch_up TEXT NOT NULL,
+		catch_up_window_ms INTEGER NOT NULL,
+		max_catch_up INTEGER NOT NULL,
+		paused INTEGER NOT NULL DEFAULT 0,
+		next_fire_ms INTEGER NOT NULL,
+		last_workflow_id TEXT,
+		buffered_fire_ms INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS continuations (
+		workflow_id TEXT NOT NULL,
+		position INTEGER NOT NULL,
+		next_workflow_name TEXT NOT NULL,
+		started_workflow_id TEXT,
+		PRIMARY KEY (workflow_id, position)
+	);
+
+	CREATE TABLE IF NOT EXISTS signals (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		workflow_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		payload BLOB,
+		sender TEXT,
+		dedup_key TEXT UNIQUE,
+		consumed_key TEXT UNIQUE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_signals_pending ON signals(workflow_id, name, consumed_key);
+
+	CREATE TABLE IF NOT EXISTS updates (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		workflow_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		args BLOB,
+		status TEXT NOT NULL,
+		result BLOB,
+		error TEXT,
+		consumed_key TEXT UNIQUE,
+		created_at TIMESTAMP,
+		completed_at TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_updates_pending ON updates(workflow_id, name, consumed_key);
+
+	CREATE TABLE IF NOT EXISTS resource_pools (
+		name TEXT PRIMARY KEY,
+		size INTEGER NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS blobs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		hash BLOB UNIQUE NOT NULL,
+		data BLOB,
+		refcount INTEGER NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS blob_chunks (
+		blob_id INTEGER NOT NULL,
+		seq INTEGER NOT NULL,
+		data BLOB NOT NULL,
+		PRIMARY KEY (blob_id, seq)
+	);
+
+	CREATE TABLE IF NOT EXISTS step_cache (
+		cache_key TEXT PRIMARY KEY,
+		output BLOB,
+		created_at_ms INTEGER NOT NULL,
+		expires_at_ms INTEGER NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS workflow_attributes (
+		workflow_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		value TEXT NOT NULL,
+		PRIMARY KEY (workflow_id, name)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_workflow_attributes ON workflow_attributes(name, value);
+
+	CREATE TABLE IF NOT EXISTS workflow_logs (
+		workflow_id TEXT NOT NULL,
+		seq INTEGER NOT NULL,
+		message TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (workflow_id, seq)
+	);
+
+	CREATE TABLE IF NOT EXISTS workflow_counters (
+		workflow_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		value INTEGER NOT NULL,
+		updated_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (workflow_id, name)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_workflow_counters_name ON workflow_counters(name);
 	`
 
 	if _, err := s.db.Exec(schema); err != nil {
 		return fmt.Errorf("failed to create schema: %w", err)
 	}
 
+	// Columns added after the original schema; older databases are upgraded in place
+	migrations := []struct{ table, column, definition string }{
+		{"workflows", "shard", "INTEGER NOT NULL DEFAULT -1"}, // unassigned until BackfillShards
+		{"workflows", "workflow_name", "TEXT"},
+		{"workflows", "input", "BLOB"},
+		{"workflows", "queue", "TEXT NOT NULL DEFAULT 'default'"},
+		{"workflows", "claimed_by", "TEXT"},
+		{"workflows", "parent_id", "TEXT"},
+		{"workflows", "output", "BLOB"},
+		{"workflows", "params", "TEXT"},
+		{"workflows", "headers", "TEXT"},
+		{"workflows", "version", "INTEGER NOT NULL DEFAULT 0"},
+		{"workflows", "requires", "TEXT"},
+		{"workflows", "deadline_ms", "INTEGER"},
+		{"workflows", "priority", "INTEGER NOT NULL DEFAULT 0"},
+		{"workflows", "fencing_token", "INTEGER NOT NULL DEFAULT 0"},
+		{"workflows", "concurrency_key", "TEXT"},
+		{"signals", "seq", "INTEGER NOT NULL DEFAULT 0"},
+		{"workers", "labels", "TEXT NOT NULL DEFAULT ''"},
+		{"steps", "kind", "TEXT NOT NULL DEFAULT 'step'"},
+		{"steps", "worker_id", "TEXT"},
+		{"steps", "hostname", "TEXT"},
+		{"steps", "pid", "INTEGER"},
+		{"steps", "scheduled_at", "TIMESTAMP"},
+		{"step_attempts", "hostname", "TEXT"},
+		{"step_attempts", "pid", "INTEGER"},
+		{"steps", "output_blob", "INTEGER"},
+		{"steps", "step_group", "TEXT"},
+		{"steps", "tags", "TEXT"},
+		{"blobs", "size", "INTEGER"},
+		{"schedules", "calendar", "TEXT NOT NULL DEFAULT ''"},
+		{"schedules", "timezone", "TEXT NOT NULL DEFAULT ''"},
+		{"steps", "output_type", "TEXT"},
+		{"workflows", "retry_budget", "TEXT"},
+	}
+	for _, m := range migrations {
+		if err := s.addColumnIfMissing(m.table, m.column, m.definition); err != nil {
+			return err
+		}
+	}
+
+	if _, err := s.db.Exec(
+		"CREATE INDEX IF NOT EXISTS idx_workflow_status_shard ON workflows(status, shard)",
+	); err != nil {
+		return fmt.Errorf("failed to create shard index: %w", err)
+	}
+	if _, err := s.db.Exec(
+		"CREATE INDEX IF NOT EXISTS idx_workflow_shard_unassigned ON workflows(workflow_id) WHERE shard < 0",
+	); err != nil {
+		return fmt.Errorf("failed to create unassigned shard index: %w", err)
+	}
+	if _, err := s.db.Exec(
+		"CREATE INDEX IF NOT EXISTS idx_workflow_concurrency_key ON workflows(concurrency_key, status)",
+	); err != nil {
+		return fmt.Errorf("failed to create concurrency key index: %w", err)
+	}
+
+	if err := s.initBlobStore(); err != nil {
+		return err
+	}
+	if err := s.initCheckpoints(); err != nil {
+		return err
+	}
+	if err := s.initEvents(); err != nil {
+		return err
+	}
+	return s.initErrorIndex()
+}
+
+// addColumnIfMissing adds a column to an existing table unless it is already present
+func (s *Storage) addColumnIfMissing(table, column, definition string) error {
+	rows, err := s.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+			return fmt.Errorf("failed to scan column info: %w", err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	if _, err := s.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
 	return nil
 }
 
-// CreateWorkflow creates a new workflow record
-func (s *Storage) CreateWorkflow(workflowID string) error {
+// scopeStepKeysToWorkflow rebuilds a steps table created when step_key was
+// globally unique, so two workflows running the same step IDs no longer collide.
+// Row IDs are preserved; indexes and triggers are recreated by initSchema.
+func (s *Storage) scopeStepKeysToWorkflow() error {
+	var createSQL string
+	err := s.db.QueryRow(
+		`SELECT t.sql FROM sqlite_master t JOIN sqlite_master i ON i.tbl_name = t.name
+		 WHERE t.type = 'table' AND t.name = 'steps' AND i.name = 'sqlite_autoindex_steps_1'`,
+	).Scan(&createSQL)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to inspect steps table: %w", err)
+	}
+
+	createSQL = strings.Replace(createSQL, "step_key TEXT UNIQUE NOT NULL", "step_key TEXT NOT NULL", 1)
+	createSQL = strings.Replace(createSQL, "CREATE TABLE steps", "CREATE TABLE steps_rebuild", 1)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin steps migration: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		createSQL,
+		"INSERT INTO steps_rebuild SELECT * FROM steps",
+		"DROP TABLE steps",
+		"ALTER TABLE steps_rebuild RENAME TO steps",
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to migrate steps table: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// CreateWorkflow creates a new workflow record in the given shard
+func (s *Storage) CreateWorkflow(workflowID string, shard int) error {
+	now := dbNow()
 	return s.retryOnBusy(func() error {
 		_, err := s.db.Exec(
-			"INSERT OR IGNORE INTO workflows (workflow_id, status) VALUES (?, ?)",
-			workflowID, "running",
+			`INSERT OR IGNORE INTO workflows (workflow_id, status, shard, created_at, updated_at)
+			 VALUES (?, ?, ?, ?, ?)`,
+			workflowID, "running", shard, now, now,
 		)
 		return err
 	})
 }
 
-// UpdateWorkflowStatus updates the status of a workflow
+// UpdateWorkflowStatus unconditionally updates the status of a workflow
 func (s *Storage) UpdateWorkflowStatus(workflowID, status string) error {
 	return s.retryOnBusy(func() error {
 		_, err := s.db.Exec(
-			"UPDATE workflows SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE workflow_id = ?",
-			status, workflowID,
+			`UPDATE workflows SET status = ?, version = version + 1, updated_at = ?
+			 WHERE workflow_id = ?`,
+			status, dbNow(), workflowID,
 		)
 		return err
 	})
 }
 
+// GetWorkflowVersion returns a workflow's status and the version of that status.
+// Every status change increments the version.
+func (s *Storage) GetWorkflowVersion(workflowID string) (string, int64, error) {
+	var status string
+	var version int64
+	err := s.db.QueryRow(
+		"SELECT status, version FROM workflows WHERE workflow_id = ?",
+		workflowID,
+	).Scan(&status, &version)
+	if err == sql.ErrNoRows {
+		return "", 0, ErrWorkflowNotFound
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to get workflow version: %w", err)
+	}
+	return status, version, nil
+}
+
+// CompareAndSetWorkflowStatus updates a workflow's status only if its version
+// is still version, returning ErrStatusConflict otherwise
+func (s *Storage) CompareAndSetWorkflowStatus(workflowID, status string, version int64) error {
+	var affected int64
+	err := s.retryOnBusy(func() error {
+		res, err := s.db.Exec(
+			`UPDATE workflows SET status = ?, version = version + 1, updated_at = ?
+			 WHERE workflow_id = ? AND version = ?`,
+			status, dbNow(), workflowID, version,
+		)
+		if err != nil {
+			return err
+		}
+		affected, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		current, _, err := s.GetWorkflowVersion(workflowID)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %s is now %s", ErrStatusConflict, workflowID, current)
+	}
+	return nil
+}
+
+// CompleteWorkflow marks a workflow completed and stores its output (if any)
+// in one transaction, provided its status is still at version
+func (s *Storage) CompleteWorkflow(workflowID string, version int64, output []byte) error {
+	var affected int64
+	err := s.retryOnBusy(func() error {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		res, err := tx.Exec(
+			`UPDATE workflows SET status = 'completed', version = version + 1, updated_at = ?
+			 WHERE workflow_id = ? AND version = ?`,
+			dbNow(), workflowID, version,
+		)
+		if err != nil {
+			return err
+		}
+		if affected, err = res.RowsAffected(); err != nil || affected == 0 {
+			return err
+		}
+		if output != nil {
+			if _, err := tx.Exec("UPDATE workflows SET output = ? WHERE workflow_id = ?", output, workflowID); err != nil {
+				return err
+			}
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		current, _, err := s.GetWorkflowVersion(workflowID)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %s is now %s", ErrStatusConflict, workflowID, current)
+	}
+	return nil
+}
+
 // GetStep retrieves a completed step's result
 func (s *Storage) GetStep(workflowID, stepKey string) ([]byte, bool, error) {
 	var output []byte
 	var status string
 
 	err := s.db.QueryRow(
-		"SELECT output, status FROM steps WHERE workflow_id = ? AND step_key = ?",
+		"SELECT "+stepOutputColumn+", s.status FROM steps s LEFT JOIN blobs b ON b.id = s.output_blob"+
+			" WHERE s.workflow_id = ? AND s.step_key = ?",
 		workflowID, stepKey,
 	).Scan(&output, &status)
 
@@ -125,28 +567,56 @@ func (s *Storage) GetStep(workflowID, stepKey string) ([]byte, bool, error) {
 }
 
 // MarkStepInProgress marks a step as started (for zombie detection)
-func (s *Storage) MarkStepInProgress(workflowID, stepKey, stepID string, sequenceNum int64) error {
+// kind classifies the step (see StepKind*), group names the Map call that
+// started it, if any, and workerID records who runs it
+func (s *Storage) MarkStepInProgress(workflowID, stepKey, stepID string, sequenceNum int64, kind, group string, tags []string, exec Executor, scheduledAt time.Time) error {
+	encodedTags, err := encodeTags(tags)
+	if err != nil {
+		return err
+	}
 	return s.retryOnBusy(func() error {
 		_, err := s.db.Exec(
-			`INSERT INTO steps (workflow_id, step_key, step_id, sequence_num, status)
-			 VALUES (?, ?, ?, ?, ?)
-			 ON CONFLICT(step_key) DO UPDATE SET status = 'in_progress'`,
-			workflowID, stepKey, stepID, sequenceNum, "in_progress",
+			`INSERT INTO steps (workflow_id, step_key, step_id, sequence_num, status, kind, step_group, tags, worker_id, hostname, pid, scheduled_at, started_at)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			 ON CONFLICT(workflow_id, step_key) DO UPDATE SET
+			   status = 'in_progress', kind = excluded.kind, step_group = excluded.step_group, tags = excluded.tags,
+			   worker_id = excluded.worker_id, hostname = excluded.hostname, pid = excluded.pid,
+			   scheduled_at = excluded.scheduled_at, started_at = excluded.started_at`,
+			workflowID, stepKey, stepID, sequenceNum, "in_progress", kind, group, encodedTags, exec.WorkerID, exec.Hostname, exec.PID,
+			dbTime(scheduledAt), dbNow(),
 		)
 		return err
 	})
 }
 
-// SaveStep persists a step's result
-func (s *Storage) SaveStep(workflowID, stepKey string, output []byte) error {
+// SaveStep persists a step's result, sharing storage with identical outputs (see blobs.go).
+// It fails with ErrStaleFencingToken if the run was claimed again since token
+// was issued, so a zombie owner can't overwrite the new owner's results.
+func (s *Storage) SaveStep(workflowID, stepKey string, token int64, output []byte) error {
 	return s.retryOnBusy(func() error {
-		_, err := s.db.Exec(
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if err := checkFencingToken(tx, workflowID, token); err != nil {
+			return err
+		}
+		blobID, err := putBlob(tx, output)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(
 			`UPDATE steps
-			 SET status = 'completed', output = ?, completed_at = CURRENT_TIMESTAMP
+			 SET status = 'completed', output = NULL, output_blob = ?,
+			   completed_at = ?
 			 WHERE workflow_id = ? AND step_key = ?`,
-			output, workflowID, stepKey,
-		)
-		return err
+			blobID, dbNow(), workflowID, stepKey,
+		); err != nil {
+			return err
+		}
+		return tx.Commit()
 	})
 }
 
@@ -155,37 +625,19 @@ func (s *Storage) SaveStepError(workflowID, stepKey string, errMsg string) error
 	return s.retryOnBusy(func() error {
 		_, err := s.db.Exec(
 			`UPDATE steps
-			 SET status = 'failed', error = ?, completed_at = CURRENT_TIMESTAMP
+			 SET status = 'failed', error = ?, completed_at = ?
 			 WHERE workflow_id = ? AND step_key = ?`,
-			errMsg, workflowID, stepKey,
+			errMsg, dbNow(), workflowID, stepKey,
 		)
 		return err
 	})
 }
 
-// GetMaxSequenceNum returns the maximum sequence number for a workflow
-func (s *Storage) GetMaxSequenceNum(workflowID string) (int64, error) {
-	var maxSeq sql.NullInt64
-	err := s.db.QueryRow(
-		"SELECT MAX(sequence_num) FROM steps WHERE workflow_id = ?",
-		workflowID,
-	).Scan(&maxSeq)
-
-	if err != nil {
-		return 0, fmt.Errorf("failed to get max sequence: %w", err)
-	}
-
-	if !maxSeq.Valid {
-		return 0, nil
-	}
-
-	return maxSeq.Int64, nil
-}
-
 // LoadCompletedSteps loads all completed steps for a workflow
 func (s *Storage) LoadCompletedSteps(workflowID string) (map[string][]byte, error) {
 	rows, err := s.db.Query(
-		"SELECT step_key, output FROM steps WHERE workflow_id = ? AND status = 'completed'",
+		"SELECT s.step_key, "+stepOutputColumn+" FROM steps s LEFT JOIN blobs b ON b.id = s.output_blob"+
+			" WHERE s.workflow_id = ? AND s.status = 'completed'",
 		workflowID,
 	)
 	if err != nil {
@@ -206,28 +658,52 @@ func (s *Storage) LoadCompletedSteps(workflowID string) (map[string][]byte, erro
 	return steps, rows.Err()
 }
 
-// LoadStepIDMapping loads the mapping of step IDs to sequence numbers
-func (s *Storage) LoadStepIDMapping(workflowID string) (map[string]int64, error) {
+// replayState is every step row of a run, loaded in one pass when it resumes
+type replayState struct {
+	completed   map[string][]byte // outputs of completed steps by step key; nil if stored in chunks
+	outputTypes map[string]string // result type fingerprints of completed steps by step key
+	stepIDToSeq map[string]int64
+	maxSeq      int64 // highest sequence number recorded; every row up to it was loaded
+}
+
+// LoadReplayState loads the completed outputs and their result types, the
+// step ID mapping and highest sequence number of a run with a single scan of
+// its step rows
+func (s *Storage) LoadReplayState(workflowID string) (*replayState, error) {
 	rows, err := s.db.Query(
-		"SELECT step_id, sequence_num FROM steps WHERE workflow_id = ?",
+		"SELECT s.step_key, s.step_id, s.sequence_num, s.status, s.output_type, "+stepOutputColumn+
+			" FROM steps s LEFT JOIN blobs b ON b.id = s.output_blob"+
+			" WHERE s.workflow_id = ? ORDER BY s.sequence_num",
 		workflowID,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load step ID mapping: %w", err)
+		return nil, fmt.Errorf("failed to load steps: %w", err)
 	}
 	defer rows.Close()
 
-	mapping := make(map[string]int64)
+	state := &replayState{
+		completed:   make(map[string][]byte),
+		outputTypes: make(map[string]string),
+		stepIDToSeq: make(map[string]int64),
+	}
 	for rows.Next() {
-		var stepID string
+		var stepKey, stepID, status string
 		var seqNum int64
-		if err := rows.Scan(&stepID, &seqNum); err != nil {
-			return nil, fmt.Errorf("failed to scan step mapping: %w", err)
+		var outputType sql.NullString
+		var output []byte
+		if err := rows.Scan(&stepKey, &stepID, &seqNum, &status, &outputType, &output); err != nil {
+			return nil, fmt.Errorf("failed to scan step: %w", err)
+		}
+		if status == "completed" {
+			state.completed[stepKey] = output
+			if outputType.String != "" {
+				state.outputTypes[stepKey] = outputType.String
+			}
 		}
-		mapping[stepID] = seqNum
+		state.stepIDToSeq[stepID] = seqNum
+		state.maxSeq = max(state.maxSeq, seqNum)
 	}
-
-	return mapping, rows.Err()
+	return state, rows.Err()
 }
 
 // Close closes the database connection
@@ -235,29 +711,6 @@ func (s *Storage) Close() error {
 	return s.db.Close()
 }
 
-// retryOnBusy retries a database operation if SQLite is busy
-func (s *Storage) retryOnBusy(fn func() error) error {
-	maxRetries := 5
-	var err error
-
-	for i := 0; i < maxRetries; i++ {
-		err = fn()
-		if err == nil {
-			return nil
-		}
-
-		// Check if it's a busy error
-		if !isSQLiteBusy(err) {
-			return err
-		}
-
-		// Exponential backoff
-		time.Sleep(time.Millisecond * time.Duration(10*(i+1)))
-	}
-
-	return fmt.Errorf("max retries exceeded: %w", err)
-}
-
 // isSQLiteBusy checks if an error is a SQLite busy error
 func isSQLiteBusy(err error) bool {
 	if err == nil {
@@ -268,6 +721,11 @@ func isSQLiteBusy(err error) bool {
 		strings.Contains(errStr, "database is locked")
 }
 
+// placeholders returns n comma-separated SQL bind placeholders ("?,?,?")
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
 // GetWorkflowStatus returns the current status of a workflow
 func (s *Storage) GetWorkflowStatus(workflowID string) (string, error) {
 	var status string
@@ -277,7 +735,7 @@ func (s *Storage) GetWorkflowStatus(workflowID string) (string, error) {
 	).Scan(&status)
 
 	if err == sql.ErrNoRows {
-		return "", errors.New("workflow not found")
+		return "", ErrWorkflowNotFound
 	}
 	if err != nil {
 		return "", fmt.Errorf("failed to get workflow status: %w", err)
@@ -0,0 +1,394 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+)
+
+// Streamed step outputs are written to blob_chunks in fixed-size pieces
+// instead of blobs.data, so a step can produce gigabytes without holding them
+// in memory. While a step streams, its blob is staged under a placeholder
+// hash derived from the step key; a retry or a resume after a crash discards
+// the staged chunks. On completion the placeholder is replaced by the content
+// hash, deduplicating against earlier streamed outputs.
+//
+// Streamed and regular outputs are hashed in separate domains so a regular
+// step never references a chunked blob, whose data column is NULL.
+
+// streamChunkSize is the size of each stored chunk of a streamed output
+const streamChunkSize = 1 << 20
+
+// StepStream executes a step whose output is written to w rather than
+// returned, and returns a reader over the stored output. On replay fn is
+// skipped and the reader serves the output recorded by the first run.
+// The reader must be closed. Errors are returned as *StepError.
+func StepStream(ctx *Context, id string, fn func(w io.Writer) error, opts ...StepOption) (io.ReadCloser, error) {
+	r, err := runStepStream(ctx, id, fn, opts...)
+	if err != nil {
+		return nil, &StepError{StepID: id, Err: err}
+	}
+	return r, nil
+}
+
+// runStepStream executes or replays a streamed step
+func runStepStream(ctx *Context, id string, fn func(w io.Writer) error, opts ...StepOption) (io.ReadCloser, error) {
+	so := ctx.newStepOptions(opts)
+
+	seqNum, err := ctx.stepSequence(id)
+	if err != nil {
+		return nil, err
+	}
+	stepKey := generateStepKey(id, seqNum)
+
+	if ctx.sim != nil {
+		// Stubs for streamed steps return the output bytes; replays the recorded ones
+		var data []byte
+		var err error
+		if ctx.sim.replay != nil {
+			data, err = replayStepOutput(ctx, id, stepKey, seqNum, so.kind)
+		} else {
+			data, err = simulateStep[[]byte](ctx, id, stepKey, seqNum, so.kind)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	r, found, err := ctx.storage.OpenStepOutput(ctx.WorkflowID, stepKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check step in database: %w", err)
+	}
+	if found {
+		ctx.printf("[SKIPPED] %s (already completed)\n", id)
+		return r, nil
+	}
+
+	if err := ctx.doneErr(); err != nil {
+		return nil, err
+	}
+	if err := ctx.checkAffinity(id, so.requires); err != nil {
+		return nil, err
+	}
+	// A promoted region fences this one before the step can run twice
+	if err := ctx.engine.checkFence(); err != nil {
+		return nil, err
+	}
+
+	if err := ctx.markStepInProgress(stepKey, id, seqNum, so.kind, so.group, so.tags); err != nil {
+		return nil, err
+	}
+	defer ctx.beginStep()()
+	started := time.Now()
+
+	if len(so.pools) > 0 {
+		release, err := ctx.acquirePools(id, so)
+		if err != nil {
+			ctx.failStep(stepKey, err)
+			return nil, err
+		}
+		defer release()
+	}
+
+	// Each attempt stages a fresh blob, discarding output from a failed one
+	var size int64
+	attempt := func() (int64, error) {
+		w, err := ctx.storage.newStreamWriter(ctx.WorkflowID, stepKey)
+		if err != nil {
+			return 0, err
+		}
+		if err := fn(w); err != nil {
+			w.discard()
+			return 0, err
+		}
+		size = w.size
+		return w.finish()
+	}
+
+	var blobID int64
+	if so.timeout > 0 || !ctx.deadline.IsZero() {
+		blobID, err = executeWithTimeout(ctx, id, so.timeout, func(stop *stepStop) (int64, error) {
+			return executeWithRetry(ctx, stop, id, stepKey, so.retry, attempt)
+		})
+	} else {
+		blobID, err = executeWithRetry(ctx, nil, id, stepKey, so.retry, attempt)
+	}
+	if err != nil {
+		ctx.failStep(stepKey, err)
+		ctx.interceptStep(id, stepKey, so.kind, started, nil, err)
+		return nil, err
+	}
+
+	if err := ctx.storage.SaveStreamedStep(ctx.WorkflowID, stepKey, blobID); err != nil {
+		return nil, fmt.Errorf("failed to save step: %w", err)
+	}
+	ctx.mu.Lock()
+	ctx.completedSteps[stepKey] = nil
+	ctx.mu.Unlock()
+	ctx.reportStep(&FinishedStep{
+		StepID:     id,
+		StepKey:    stepKey,
+		Kind:       so.kind,
+		OutputSize: size,
+		Duration:   time.Since(started),
+	})
+
+	return ctx.storage.openBlob(blobID), nil
+}
+
+// OpenStepOutput returns a reader over the output of a completed step,
+// whether it was streamed or returned by Step. The reader must be closed.
+func (e *Engine) OpenStepOutput(workflowID, stepID string) (io.ReadCloser, error) {
+	var stepKey string
+	err := e.storage.db.QueryRow(
+		"SELECT step_key FROM steps WHERE workflow_id = ? AND step_id = ? ORDER BY sequence_num DESC LIMIT 1",
+		workflowID, stepID,
+	).Scan(&stepKey)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("step %s not found in workflow %s", stepID, workflowID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up step: %w", err)
+	}
+
+	r, found, err := e.storage.OpenStepOutput(workflowID, stepKey)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("step %s of workflow %s has not completed", stepID, workflowID)
+	}
+	return r, nil
+}
+
+// OpenStepOutput returns a reader over a completed step's output
+func (s *Storage) OpenStepOutput(workflowID, stepKey string) (io.ReadCloser, bool, error) {
+	var blobID sql.NullInt64
+	var output []byte
+	var status string
+	err := s.db.QueryRow(
+		"SELECT output_blob, output, status FROM steps WHERE workflow_id = ? AND step_key = ?",
+		workflowID, stepKey,
+	).Scan(&blobID, &output, &status)
+	if err == sql.ErrNoRows || (err == nil && status != "completed") {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get step: %w", err)
+	}
+
+	if !blobID.Valid {
+		return io.NopCloser(bytes.NewReader(output)), true, nil
+	}
+	return s.openBlob(blobID.Int64), true, nil
+}
+
+// SaveStreamedStep marks a step completed with a streamed blob as its output
+func (s *Storage) SaveStreamedStep(workflowID, stepKey string, blobID int64) error {
+	return s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			`UPDATE steps
+			 SET status = 'completed', output = NULL, output_blob = ?,
+			   completed_at = ?
+			 WHERE workflow_id = ? AND step_key = ?`,
+			blobID, dbNow(), workflowID, stepKey,
+		)
+		return err
+	})
+}
+
+// stagingHash is the placeholder hash of a blob a step is still streaming
+func stagingHash(workflowID, stepKey string) []byte {
+	return []byte("staging:" + workflowID + "/" + stepKey)
+}
+
+// streamWriter writes a step's output to blob_chunks
+type streamWriter struct {
+	s       *Storage
+	blobID  int64
+	staging []byte
+	buf     []byte
+	seq     int
+	size    int64
+	hash    hash.Hash
+}
+
+// newStreamWriter stages a new blob for the step, discarding any left by an earlier attempt
+func (s *Storage) newStreamWriter(workflowID, stepKey string) (*streamWriter, error) {
+	w := &streamWriter{s: s, staging: stagingHash(workflowID, stepKey), hash: sha256.New()}
+	w.hash.Write([]byte("stream\x00"))
+
+	err := s.retryOnBusy(func() error {
+		if _, err := s.db.Exec("DELETE FROM blobs WHERE hash = ?", w.staging); err != nil {
+			return err
+		}
+		return s.db.QueryRow(
+			"INSERT INTO blobs (hash, data, refcount, size) VALUES (?, NULL, 0, 0) RETURNING id",
+			w.staging,
+		).Scan(&w.blobID)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to stage streamed output: %w", err)
+	}
+	return w, nil
+}
+
+// Write buffers p and stores every full chunk
+func (w *streamWriter) Write(p []byte) (int, error) {
+	w.hash.Write(p)
+	w.size += int64(len(p))
+
+	n := len(p)
+	for len(p) > 0 {
+		take := min(streamChunkSize-len(w.buf), len(p))
+		w.buf = append(w.buf, p[:take]...)
+		p = p[take:]
+		if len(w.buf) == streamChunkSize {
+			if err := w.flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// flush stores the buffered chunk
+func (w *streamWriter) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	err := w.s.retryOnBusy(func() error {
+		_, err := w.s.db.Exec(
+			"INSERT INTO blob_chunks (blob_id, seq, data) VALUES (?, ?, ?)",
+			w.blobID, w.seq, w.buf,
+		)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store output chunk: %w", err)
+	}
+	w.seq++
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// finish stores the last chunk and publishes the blob under its content hash,
+// returning the ID of the blob the step should reference
+func (w *streamWriter) finish() (int64, error) {
+	if err := w.flush(); err != nil {
+		w.discard()
+		return 0, err
+	}
+	sum := w.hash.Sum(nil)
+
+	var id int64
+	err := w.s.retryOnBusy(func() error {
+		tx, err := w.s.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		err = tx.QueryRow("SELECT id FROM blobs WHERE hash = ?", sum).Scan(&id)
+		switch {
+		case err == nil:
+			// Identical output already stored; drop the staged copy
+			if _, err := tx.Exec("DELETE FROM blobs WHERE id = ?", w.blobID); err != nil {
+				return err
+			}
+		case errors.Is(err, sql.ErrNoRows):
+			id = w.blobID
+			if _, err := tx.Exec(
+				"UPDATE blobs SET hash = ?, size = ? WHERE id = ?",
+				sum, w.size, w.blobID,
+			); err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to publish streamed output: %w", err)
+	}
+	return id, nil
+}
+
+// discard drops the staged blob and its chunks
+func (w *streamWriter) discard() {
+	w.s.retryOnBusy(func() error {
+		_, err := w.s.db.Exec("DELETE FROM blobs WHERE id = ?", w.blobID)
+		return err
+	})
+}
+
+// openBlob returns a reader over a stored blob. Chunks are fetched one query
+// at a time so a slow reader never holds the database connection.
+func (s *Storage) openBlob(blobID int64) io.ReadCloser {
+	return &blobReader{s: s, blobID: blobID}
+}
+
+// blobReader reads a blob stored inline or in chunks
+type blobReader struct {
+	s      *Storage
+	blobID int64
+	loaded bool
+	inline bool
+	seq    int
+	buf    []byte
+	closed bool
+}
+
+// Read implements io.Reader
+func (r *blobReader) Read(p []byte) (int, error) {
+	if r.closed {
+		return 0, errors.New("read from closed step output")
+	}
+
+	if !r.loaded {
+		var data []byte
+		err := r.s.db.QueryRow("SELECT data FROM blobs WHERE id = ?", r.blobID).Scan(&data)
+		if err != nil {
+			return 0, fmt.Errorf("failed to open step output: %w", err)
+		}
+		r.loaded = true
+		if data != nil {
+			r.inline, r.buf = true, data
+		}
+	}
+
+	for len(r.buf) == 0 {
+		if r.inline {
+			return 0, io.EOF
+		}
+		err := r.s.db.QueryRow(
+			"SELECT data FROM blob_chunks WHERE blob_id = ? AND seq = ?",
+			r.blobID, r.seq,
+		).Scan(&r.buf)
+		if err == sql.ErrNoRows {
+			return 0, io.EOF
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to read output chunk: %w", err)
+		}
+		r.seq++
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// Close implements io.Closer
+func (r *blobReader) Close() error {
+	r.closed = true
+	r.buf = nil
+	return nil
+}
@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestStepStreamReplaysChunkedOutput(t *testing.T) {
+	dbPath := "./test_stream.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	// Spans several chunks with a partial last one
+	want := bytes.Repeat([]byte("0123456789abcdef"), (streamChunkSize*5/2)/16)
+
+	var calls, attempts, runs int
+	var got []byte
+	workflow := func(ctx *Context) error {
+		runs++
+		r, err := StepStream(ctx, "export", func(w io.Writer) error {
+			attempts++
+			if attempts == 1 {
+				w.Write(want[:streamChunkSize+10])
+				return errors.New("connection reset")
+			}
+			calls++
+			for i := 0; i < len(want); i += 4096 {
+				if _, err := w.Write(want[i:min(i+4096, len(want))]); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, WithRetry(RetryPolicy{MaxAttempts: 2}))
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		if got, err = io.ReadAll(r); err != nil {
+			return err
+		}
+		if runs == 1 {
+			return errors.New("crash after step")
+		}
+		return nil
+	}
+
+	eng.Execute("stream-1", workflow)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("first run read %d bytes, expected %d", len(got), len(want))
+	}
+
+	got = nil
+	if err := eng.Execute("stream-1", workflow); err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected one successful attempt, got %d", calls)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("replay read %d bytes, expected %d", len(got), len(want))
+	}
+
+	// The failed attempt's chunks were discarded
+	var chunks int
+	eng.storage.db.QueryRow("SELECT COUNT(*) FROM blob_chunks").Scan(&chunks)
+	if chunks != 3 {
+		t.Errorf("expected 3 stored chunks, got %d", chunks)
+	}
+
+	r, err := eng.OpenStepOutput("stream-1", "export")
+	if err != nil {
+		t.Fatalf("failed to open step output: %v", err)
+	}
+	defer r.Close()
+	n, _ := io.Copy(io.Discard, r)
+	if n != int64(len(want)) {
+		t.Errorf("expected %d bytes from OpenStepOutput, got %d", len(want), n)
+	}
+}
+
+func TestStepStreamDeduplicates(t *testing.T) {
+	dbPath := "./test_stream_dedup.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	report := bytes.Repeat([]byte("row\n"), 1000)
+	for _, id := range []string{"report-1", "report-2"} {
+		err := eng.Execute(id, func(ctx *Context) error {
+			r, err := StepStream(ctx, "render", func(w io.Writer) error {
+				_, err := w.Write(report)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			return r.Close()
+		})
+		if err != nil {
+			t.Fatalf("workflow %s failed: %v", id, err)
+		}
+	}
+
+	stats, err := eng.BlobStats()
+	if err != nil {
+		t.Fatalf("failed to get blob stats: %v", err)
+	}
+	if stats.Blobs != 1 || stats.References != 2 || stats.SavedBytes != int64(len(report)) {
+		t.Errorf("expected one shared blob, got %+v", stats)
+	}
+
+	// Regular step outputs are readable through the same API
+	eng.Execute("plain-1", func(ctx *Context) error {
+		_, err := Step(ctx, "greet", func() (string, error) { return "hi", nil })
+		return err
+	})
+	r, err := eng.OpenStepOutput("plain-1", "greet")
+	if err != nil {
+		t.Fatalf("failed to open step output: %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != `"hi"` {
+		t.Errorf("expected encoded step output, got %s", data)
+	}
+}
@@ -0,0 +1,217 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SubjectMatcher finds a data subject in a decoded input or output and
+// returns the paths of the values that identify it, like "$.customer.email"
+type SubjectMatcher interface {
+	Match(v interface{}) []string
+}
+
+// FieldMatcher matches values equal to Value in fields named one of Fields,
+// or anywhere when Fields is empty. Field names are compared like PIIRule
+// fields; a value in a list is in the field holding the list.
+type FieldMatcher struct {
+	Value      string
+	Fields     []string
+	IgnoreCase bool
+}
+
+// Match returns the paths of the fields holding the subject's identifier
+func (m FieldMatcher) Match(v interface{}) []string {
+	fields := make(map[string]bool, len(m.Fields))
+	for _, f := range m.Fields {
+		fields[normalizeFieldName(f)] = true
+	}
+	var paths []string
+	m.walk(v, "$", "", fields, &paths)
+	return paths
+}
+
+// walk collects the paths of matching values below v; field is the name of
+// the nearest enclosing field
+func (m FieldMatcher) walk(v interface{}, path, field string, fields map[string]bool, paths *[]string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(v) {
+			m.walk(v[key], path+"."+key, key, fields, paths)
+		}
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			converted[fmt.Sprint(key)] = value
+		}
+		m.walk(converted, path, field, fields, paths)
+	case []interface{}:
+		for i, value := range v {
+			m.walk(value, fmt.Sprintf("%s[%d]", path, i), field, fields, paths)
+		}
+	default:
+		text, ok := scalarText(v)
+		if !ok || (len(fields) > 0 && !fields[normalizeFieldName(field)]) {
+			return
+		}
+		if text == m.Value || (m.IgnoreCase && strings.EqualFold(text, m.Value)) {
+			*paths = append(*paths, path)
+		}
+	}
+}
+
+// scalarText formats a decoded string or number for comparison
+func scalarText(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, fmt.Stringer:
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
+
+// sortedKeys returns a map's keys in order, so reports are stable
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SubjectReport lists the runs holding a data subject's data
+type SubjectReport struct {
+	GeneratedAt time.Time
+	Runs        []SubjectRun
+}
+
+// SubjectRun is one run holding the subject's data
+type SubjectRun struct {
+	WorkflowID string
+	Name       string
+	Status     string
+	CreatedAt  time.Time
+	Matches    []SubjectMatch
+}
+
+// SubjectMatch is one recorded value containing the subject's data
+type SubjectMatch struct {
+	Source string      // "input", "output" or "step"
+	StepID string      // set for step outputs
+	Paths  []string    // where the subject was found; "$" for undecodable values
+	Data   interface{} // the decoded value, or its text if it couldn't be decoded
+}
+
+// RecordedValue is one recorded value of a run, as stored
+type RecordedValue struct {
+	WorkflowID string
+	Source     string
+	StepID     string
+	Data       []byte
+}
+
+// ExportSubjectData scans every run's input, output and step outputs for a
+// data subject and reports the runs holding their data, e.g. to answer a
+// data-access request. Values are decoded with the engine's codec; values
+// it can't decode generically, such as gob, are searched as text for a
+// FieldMatcher's Value. Streamed step outputs aren't scanned.
+func (e *Engine) ExportSubjectData(m SubjectMatcher) (*SubjectReport, error) {
+	report := &SubjectReport{GeneratedAt: time.Now()}
+	runs := make(map[string]*SubjectRun)
+	var ids []string
+
+	err := e.reads.ScanRecordedValues(func(rv RecordedValue) {
+		match, ok := e.matchSubject(m, rv)
+		if !ok {
+			return
+		}
+		run := runs[rv.WorkflowID]
+		if run == nil {
+			run = &SubjectRun{WorkflowID: rv.WorkflowID}
+			runs[rv.WorkflowID] = run
+			ids = append(ids, rv.WorkflowID)
+		}
+		run.Matches = append(run.Matches, match)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return report, nil
+	}
+
+	infos, err := e.reads.ListWorkflows(WorkflowFilter{}, ids...)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		run := runs[info.WorkflowID]
+		run.Name, run.Status, run.CreatedAt = info.Name, info.Status, info.CreatedAt
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		report.Runs = append(report.Runs, *runs[id])
+	}
+	fmt.Printf("[SUBJECT] found subject data in %d runs\n", len(report.Runs))
+	return report, nil
+}
+
+// matchSubject decodes a recorded value and matches it against m
+func (e *Engine) matchSubject(m SubjectMatcher, rv RecordedValue) (SubjectMatch, bool) {
+	match := SubjectMatch{Source: rv.Source, StepID: rv.StepID}
+
+	var v interface{}
+	if err := e.codec.Unmarshal(rv.Data, &v); err == nil {
+		match.Paths, match.Data = m.Match(v), v
+		return match, len(match.Paths) > 0
+	}
+
+	fm, ok := m.(FieldMatcher)
+	if !ok || fm.Value == "" {
+		return match, false
+	}
+	data, value := rv.Data, []byte(fm.Value)
+	if fm.IgnoreCase {
+		data, value = bytes.ToLower(data), bytes.ToLower(value)
+	}
+	if !bytes.Contains(data, value) {
+		return match, false
+	}
+	match.Paths, match.Data = []string{"$"}, string(rv.Data)
+	return match, true
+}
+
+// ScanRecordedValues calls fn with the input and output of every run and
+// the outputs of their completed steps
+func (s *Storage) ScanRecordedValues(fn func(rv RecordedValue)) error {
+	rows, err := s.db.Query(
+		`SELECT workflow_id, 'input', '', input FROM workflows WHERE input IS NOT NULL
+		 UNION ALL
+		 SELECT workflow_id, 'output', '', output FROM workflows WHERE output IS NOT NULL
+		 UNION ALL
+		 SELECT s.workflow_id, 'step', s.step_id, ` + stepOutputColumn + `
+		 FROM steps s LEFT JOIN blobs b ON b.id = s.output_blob
+		 WHERE s.status = 'completed' AND ` + stepOutputColumn + ` IS NOT NULL`,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to scan recorded values: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rv RecordedValue
+		if err := rows.Scan(&rv.WorkflowID, &rv.Source, &rv.StepID, &rv.Data); err != nil {
+			return fmt.Errorf("failed to scan recorded value: %w", err)
+		}
+		fn(rv)
+	}
+	return rows.Err()
+}
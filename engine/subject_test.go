@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestExportSubjectData(t *testing.T) {
+	dbPath := "./test_subject.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	type customer struct {
+		Email    string   `json:"email"`
+		Contacts []string `json:"contacts"`
+	}
+	err = eng.Execute("signup-ada", func(ctx *Context) error {
+		_, err := Step(ctx, "load", func() (customer, error) {
+			return customer{Email: "Ada@Example.com", Contacts: []string{"bob@example.com"}}, nil
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	err = eng.Execute("signup-bob", func(ctx *Context) error {
+		_, err := Step(ctx, "note", func() (string, error) { return "ada@example.com", nil })
+		return err
+	})
+	if err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	eng.Register("invoice", func(ctx *Context) error { return nil })
+	if err := eng.Enqueue("invoice-1", "invoice", map[string]string{"billing_email": "ada@example.com"}); err != nil {
+		t.Fatalf("failed to enqueue: %v", err)
+	}
+
+	report, err := eng.ExportSubjectData(FieldMatcher{Value: "ada@example.com", Fields: []string{"email", "billingEmail"}, IgnoreCase: true})
+	if err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	var runs []string
+	for _, run := range report.Runs {
+		runs = append(runs, run.WorkflowID)
+	}
+	if !reflect.DeepEqual(runs, []string{"invoice-1", "signup-ada"}) {
+		t.Fatalf("expected the runs with ada's email fields, got %v", runs)
+	}
+	if m := report.Runs[0].Matches[0]; m.Source != "input" || !reflect.DeepEqual(m.Paths, []string{"$.billing_email"}) {
+		t.Errorf("expected the invoice input to match, got %+v", m)
+	}
+	if m := report.Runs[1].Matches[0]; m.Source != "step" || m.StepID != "load" || !reflect.DeepEqual(m.Paths, []string{"$.email"}) {
+		t.Errorf("expected the loaded customer to match, got %+v", m)
+	}
+	if report.Runs[1].Status != "completed" {
+		t.Errorf("expected the run's status in the report, got %q", report.Runs[1].Status)
+	}
+
+	// Without fields, values anywhere match, including in lists
+	report, err = eng.ExportSubjectData(FieldMatcher{Value: "bob@example.com"})
+	if err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	if len(report.Runs) != 1 || !reflect.DeepEqual(report.Runs[0].Matches[0].Paths, []string{"$.contacts[0]"}) {
+		t.Errorf("expected bob to be found in ada's contacts, got %+v", report.Runs)
+	}
+}
@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// WithTags labels a step, e.g. "external-api" or "payments", so its outcomes
+// are aggregated with every other step carrying the tag by TagStats. Tags
+// given as step defaults are added to the step's own.
+func WithTags(tags ...string) StepOption {
+	return func(o *stepOptions) {
+		o.tags = append(o.tags, tags...)
+	}
+}
+
+// encodeTags returns the JSON stored in steps.tags, without duplicates, or
+// nil for untagged steps
+func encodeTags(tags []string) (interface{}, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	seen := make(map[string]bool, len(tags))
+	unique := make([]string, 0, len(tags))
+	for _, t := range tags {
+		if t != "" && !seen[t] {
+			seen[t] = true
+			unique = append(unique, t)
+		}
+	}
+	if len(unique) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(unique)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode step tags: %w", err)
+	}
+	return string(data), nil
+}
+
+// decodeTags parses steps.tags
+func decodeTags(data sql.NullString) []string {
+	if !data.Valid {
+		return nil
+	}
+	var tags []string
+	json.Unmarshal([]byte(data.String), &tags)
+	return tags
+}
+
+// TagStats aggregates the steps of every workflow carrying one tag
+type TagStats struct {
+	Tag           string
+	Completed     int
+	Failed        int
+	Canceled      int
+	InProgress    int
+	TotalDuration time.Duration // summed over finished steps
+}
+
+// FailureRate returns the fraction of finished steps that failed
+func (t *TagStats) FailureRate() float64 {
+	finished := t.Completed + t.Failed
+	if finished == 0 {
+		return 0
+	}
+	return float64(t.Failed) / float64(finished)
+}
+
+// MeanDuration returns the average duration of finished steps
+func (t *TagStats) MeanDuration() time.Duration {
+	finished := t.Completed + t.Failed + t.Canceled
+	if finished == 0 {
+		return 0
+	}
+	return t.TotalDuration / time.Duration(finished)
+}
+
+// TagStats returns step outcomes per tag for steps started since the given
+// time (every step if zero), ordered by tag
+func (e *Engine) TagStats(since time.Time) ([]TagStats, error) {
+	return e.reads.TagStats(since)
+}
+
+// TagStats groups tagged steps by tag and status
+func (s *Storage) TagStats(since time.Time) ([]TagStats, error) {
+	rows, err := s.db.Query(
+		`SELECT t.value, s.status, COUNT(*),
+		        COALESCE(SUM((julianday(s.completed_at) - julianday(s.started_at)) * 86400000.0), 0)
+		 FROM steps s, json_each(s.tags) t
+		 WHERE s.tags IS NOT NULL AND s.started_at >= ?
+		 GROUP BY t.value, s.status ORDER BY t.value`,
+		dbTime(since),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate step tags: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []TagStats
+	for rows.Next() {
+		var tag, status string
+		var count int
+		var ms float64
+		if err := rows.Scan(&tag, &status, &count, &ms); err != nil {
+			return nil, fmt.Errorf("failed to scan tag stats: %w", err)
+		}
+		if len(stats) == 0 || stats[len(stats)-1].Tag != tag {
+			stats = append(stats, TagStats{Tag: tag})
+		}
+		st := &stats[len(stats)-1]
+		switch status {
+		case "completed":
+			st.Completed = count
+		case "failed":
+			st.Failed = count
+		case "canceled":
+			st.Canceled = count
+		default:
+			st.InProgress += count
+		}
+		st.TotalDuration += time.Duration(math.Round(ms)) * time.Millisecond
+	}
+	return stats, rows.Err()
+}
@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTagStatsAggregateAcrossWorkflows(t *testing.T) {
+	dbPath := "./test_tags.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	charge := func(ctx *Context, fail bool) error {
+		_, err := Step(ctx, "charge", func() (bool, error) {
+			if fail {
+				return false, errors.New("card declined")
+			}
+			return true, nil
+		}, WithTags("payments"))
+		return err
+	}
+
+	if err := eng.Execute("checkout-1", func(ctx *Context) error { return charge(ctx, false) },
+		WithStepDefaults(WithTags("external-api"))); err != nil {
+		t.Fatalf("checkout-1 failed: %v", err)
+	}
+	eng.Execute("checkout-2", func(ctx *Context) error { return charge(ctx, true) })
+	eng.Execute("refund-1", func(ctx *Context) error {
+		if err := charge(ctx, false); err != nil {
+			return err
+		}
+		_, err := Step(ctx, "audit", func() (bool, error) { return true, nil })
+		return err
+	})
+
+	history, err := eng.GetHistory("checkout-1")
+	if err != nil {
+		t.Fatalf("failed to get history: %v", err)
+	}
+	if want := []string{"external-api", "payments"}; !reflect.DeepEqual(history[0].Tags, want) {
+		t.Errorf("expected tags %v, got %v", want, history[0].Tags)
+	}
+
+	stats, err := eng.TagStats(time.Time{})
+	if err != nil {
+		t.Fatalf("failed to get tag stats: %v", err)
+	}
+	if len(stats) != 2 || stats[0].Tag != "external-api" || stats[1].Tag != "payments" {
+		t.Fatalf("expected external-api and payments stats, got %+v", stats)
+	}
+	payments := stats[1]
+	if payments.Completed != 2 || payments.Failed != 1 {
+		t.Errorf("expected 2 completed and 1 failed payment, got %+v", payments)
+	}
+	if rate := payments.FailureRate(); rate < 0.33 || rate > 0.34 {
+		t.Errorf("expected a failure rate of 1/3, got %v", rate)
+	}
+
+	later, err := eng.TagStats(time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("failed to get tag stats: %v", err)
+	}
+	if len(later) != 0 {
+		t.Errorf("expected no stats for steps started later, got %+v", later)
+	}
+}
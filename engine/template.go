@@ -0,0 +1,160 @@
+package engine
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// ErrMissingParam is returned when a templated workflow is started without a required parameter
+var ErrMissingParam = errors.New("missing template parameter")
+
+// Params are the start-time parameters of a workflow run. They are stored
+// with the run, so every replay sees the values it was started with.
+type Params map[string]string
+
+// Get returns a parameter, or "" if it is not set
+func (p Params) Get(name string) string {
+	return p[name]
+}
+
+// Int parses a parameter as an integer
+func (p Params) Int(name string) (int, error) {
+	n, err := strconv.Atoi(p[name])
+	if err != nil {
+		return 0, fmt.Errorf("parameter %s is not an integer: %w", name, err)
+	}
+	return n, nil
+}
+
+// Duration parses a parameter as a time.Duration such as "30s"
+func (p Params) Duration(name string) (time.Duration, error) {
+	d, err := time.ParseDuration(p[name])
+	if err != nil {
+		return 0, fmt.Errorf("parameter %s is not a duration: %w", name, err)
+	}
+	return d, nil
+}
+
+// paramPattern matches ${name} placeholders
+var paramPattern = regexp.MustCompile(`\$\{([A-Za-z0-9_.-]+)\}`)
+
+// Expand substitutes ${name} placeholders in s, e.g. a step ID "page-${team}".
+// Placeholders for unset parameters are left as they are.
+func (p Params) Expand(s string) string {
+	return paramPattern.ReplaceAllStringFunc(s, func(m string) string {
+		if v, ok := p[m[2:len(m)-1]]; ok {
+			return v
+		}
+		return m
+	})
+}
+
+// Template is a workflow whose step configuration is filled in from
+// start-time parameters, so one registered body serves many near-identical
+// workflows. Defaults apply to parameters not given at start.
+type Template struct {
+	Defaults map[string]string
+	Required []string
+	Fn       func(ctx *Context, p Params) error
+}
+
+// WithParams sets the parameters of a workflow run
+func WithParams(params map[string]string) WorkflowOption {
+	return func(o *workflowOptions) {
+		o.params = params
+	}
+}
+
+// RegisterTemplate registers a parameterized workflow under name. Start it with
+// Enqueue(workflowID, name, input, WithParams(...)).
+func (e *Engine) RegisterTemplate(name string, t *Template) {
+	e.registryMu.Lock()
+	if e.templates == nil {
+		e.templates = make(map[string]*Template)
+	}
+	e.templates[name] = t
+	e.registryMu.Unlock()
+
+	e.Register(name, func(ctx *Context) error {
+		params, err := t.resolve(ctx.params)
+		if err != nil {
+			return err
+		}
+		return t.Fn(ctx, params)
+	})
+}
+
+// checkParams rejects a start of a locally registered template that lacks required parameters
+func (e *Engine) checkParams(workflowName string, params map[string]string) error {
+	e.registryMu.RLock()
+	t := e.templates[workflowName]
+	e.registryMu.RUnlock()
+	if t == nil {
+		return nil
+	}
+	_, err := t.resolve(params)
+	return err
+}
+
+// resolve merges defaults into the given parameters and checks required ones
+func (t *Template) resolve(given map[string]string) (Params, error) {
+	params := make(Params, len(t.Defaults)+len(given))
+	for k, v := range t.Defaults {
+		params[k] = v
+	}
+	for k, v := range given {
+		params[k] = v
+	}
+	for _, name := range t.Required {
+		if _, ok := params[name]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrMissingParam, name)
+		}
+	}
+	return params, nil
+}
+
+// Params returns the parameters the workflow run was started with
+func (ctx *Context) Params() Params {
+	params := make(Params, len(ctx.params))
+	for k, v := range ctx.params {
+		params[k] = v
+	}
+	return params
+}
+
+// SetWorkflowParams stores the parameters of a workflow unless they are already set
+func (s *Storage) SetWorkflowParams(workflowID string, params []byte) error {
+	return s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			"UPDATE workflows SET params = COALESCE(params, ?) WHERE workflow_id = ?",
+			params, workflowID,
+		)
+		return err
+	})
+}
+
+// GetWorkflowParams loads the parameters of a workflow, or nil if none were given
+func (s *Storage) GetWorkflowParams(workflowID string) (map[string]string, error) {
+	var data []byte
+	err := s.db.QueryRow(
+		"SELECT params FROM workflows WHERE workflow_id = ?",
+		workflowID,
+	).Scan(&data)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get workflow params: %w", err)
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var params map[string]string
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal workflow params: %w", err)
+	}
+	return params, nil
+}
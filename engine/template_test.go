@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWorkflowTemplate(t *testing.T) {
+	dbPath := "./test_template.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	var mu sync.Mutex
+	sent := make(map[string]string)
+	eng.RegisterTemplate("notify-team", &Template{
+		Defaults: map[string]string{"channel": "#alerts", "attempts": "2"},
+		Required: []string{"team"},
+		Fn: func(ctx *Context, p Params) error {
+			attempts, err := p.Int("attempts")
+			if err != nil {
+				return err
+			}
+			_, err = Step(ctx, p.Expand("page-${team}"), func() (string, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				sent[p.Get("team")] = p.Expand("${channel}: ${team} on call")
+				return "ok", nil
+			}, WithRetry(RetryPolicy{MaxAttempts: attempts}))
+			return err
+		},
+	})
+
+	if err := eng.Enqueue("notify-1", "notify-team", nil, WithParams(map[string]string{"team": "payments"})); err != nil {
+		t.Fatalf("failed to enqueue: %v", err)
+	}
+	if err := eng.Enqueue("notify-2", "notify-team", nil,
+		WithParams(map[string]string{"team": "search", "channel": "#search-oncall"})); err != nil {
+		t.Fatalf("failed to enqueue: %v", err)
+	}
+	if err := eng.Enqueue("notify-3", "notify-team", nil); !errors.Is(err, ErrMissingParam) {
+		t.Errorf("expected ErrMissingParam, got %v", err)
+	}
+
+	if err := eng.StartWorker(WorkerConfig{Capacity: 2, PollInterval: 10 * time.Millisecond}); err != nil {
+		t.Fatalf("failed to start worker: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for _, id := range []string{"notify-1", "notify-2"} {
+		for {
+			status, _ := eng.GetWorkflowStatus(id)
+			if status == "completed" {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%s did not complete: %s", id, status)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if sent["payments"] != "#alerts: payments on call" {
+		t.Errorf("expected defaults to apply, got %q", sent["payments"])
+	}
+	if sent["search"] != "#search-oncall: search on call" {
+		t.Errorf("expected given params to override defaults, got %q", sent["search"])
+	}
+
+	history, err := eng.GetHistory("notify-2")
+	if err != nil || len(history) != 1 || history[0].StepID != "page-search" {
+		t.Errorf("expected parameterized step ID, got %+v (%v)", history, err)
+	}
+
+	// Params are stored with the run
+	params, err := eng.storage.GetWorkflowParams("notify-2")
+	if err != nil || params["channel"] != "#search-oncall" {
+		t.Errorf("expected stored params, got %v (%v)", params, err)
+	}
+}
@@ -0,0 +1,374 @@
+package engine
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// temporalFailureSource marks failures exported from this engine
+const temporalFailureSource = "DurableExecutionEngine"
+
+// TemporalHistory is a run's history in the JSON layout of Temporal's
+// History message, as printed by `temporal workflow show --output json` and
+// accepted by Temporal's history viewers. Workflow task events are not
+// emitted, so exported histories are for inspection, not replay.
+type TemporalHistory struct {
+	Events []*TemporalEvent `json:"events"`
+}
+
+// TemporalEvent is one history event. Attributes are written under the
+// event type's attribute field, e.g. activityTaskScheduledEventAttributes.
+type TemporalEvent struct {
+	EventID    int64
+	EventTime  time.Time
+	EventType  string // e.g. EVENT_TYPE_ACTIVITY_TASK_SCHEDULED
+	Attributes map[string]interface{}
+
+	refs map[string]*TemporalEvent // attributes naming another event's ID, set once numbered
+}
+
+// MarshalJSON writes the event in Temporal's protobuf JSON form
+func (ev *TemporalEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"eventId":                           strconv.FormatInt(ev.EventID, 10),
+		"eventTime":                         ev.EventTime.UTC().Format(time.RFC3339Nano),
+		"eventType":                         ev.EventType,
+		temporalAttributesKey(ev.EventType): ev.Attributes,
+	})
+}
+
+// temporalAttributesKey maps EVENT_TYPE_TIMER_FIRED to timerFiredEventAttributes
+func temporalAttributesKey(eventType string) string {
+	words := strings.Split(strings.ToLower(strings.TrimPrefix(eventType, "EVENT_TYPE_")), "_")
+	for i := 1; i < len(words); i++ {
+		if words[i] != "" {
+			words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+		}
+	}
+	return strings.Join(words, "") + "EventAttributes"
+}
+
+// ExportTemporalHistory converts a run's history to Temporal's event history
+// format: steps become activities (one started event per step, carrying the
+// final attempt number and the previous attempt's failure, as Temporal
+// records retries), sleeps become timers, received and sent signals become
+// signal events, started children become child workflow events and ctx.Set
+// calls become markers.
+func (e *Engine) ExportTemporalHistory(workflowID string) (*TemporalHistory, error) {
+	run, err := e.GetWorkflow(workflowID)
+	if err != nil {
+		return nil, err
+	}
+	steps, err := e.storage.ListSteps(workflowID)
+	if err != nil {
+		return nil, err
+	}
+	input, err := e.storage.GetWorkflowInput(workflowID)
+	if err != nil {
+		return nil, err
+	}
+	children, err := e.storage.ListWorkflows(WorkflowFilter{ParentIDs: []string{workflowID}})
+	if err != nil {
+		return nil, err
+	}
+	childByID := make(map[string]*WorkflowInfo, len(children))
+	for i := range children {
+		childByID[children[i].WorkflowID] = &children[i]
+	}
+
+	b := &temporalBuilder{}
+	started := map[string]interface{}{
+		"workflowType":           map[string]string{"name": temporalWorkflowType(run)},
+		"taskQueue":              map[string]string{"name": run.Queue},
+		"attempt":                1,
+		"firstExecutionRunId":    run.WorkflowID,
+		"originalExecutionRunId": run.WorkflowID,
+	}
+	if len(input) > 0 {
+		started["input"] = temporalPayloads(input)
+	}
+	if run.ParentID != "" {
+		started["parentWorkflowExecution"] = temporalExecution(run.ParentID)
+	}
+	b.add(run.CreatedAt, "EVENT_TYPE_WORKFLOW_EXECUTION_STARTED", started)
+
+	lastError := ""
+	for i := range steps {
+		st := &steps[i]
+		if st.Status == "failed" {
+			lastError = st.Error
+		}
+		switch st.Kind {
+		case StepKindSleep:
+			e.exportTimer(b, workflowID, st)
+		case StepKindSignal:
+			exportSignalReceived(b, st)
+		case StepKindSignalSend:
+			exportSignalSent(b, st)
+		case StepKindChildStart:
+			childID := ChildWorkflowID(workflowID, strings.TrimPrefix(st.StepID, "start-child:"))
+			exportChildStart(b, st, childID, childByID[childID])
+		case StepKindState:
+			attrs := map[string]interface{}{"markerName": st.StepID}
+			if len(st.Output) > 0 {
+				attrs["details"] = map[string]interface{}{"value": temporalPayloads(st.Output)}
+			}
+			b.add(st.CompletedAt, "EVENT_TYPE_MARKER_RECORDED", attrs)
+		default:
+			exportActivity(b, st, run.Queue)
+		}
+	}
+
+	switch run.Status {
+	case "completed":
+		attrs := map[string]interface{}{}
+		if len(run.Output) > 0 {
+			attrs["result"] = temporalPayloads(run.Output)
+		}
+		b.addLast(run.UpdatedAt, "EVENT_TYPE_WORKFLOW_EXECUTION_COMPLETED", attrs)
+	case "failed":
+		b.addLast(run.UpdatedAt, "EVENT_TYPE_WORKFLOW_EXECUTION_FAILED", map[string]interface{}{
+			"failure": temporalFailure(lastError),
+		})
+	case "canceled":
+		b.addLast(run.UpdatedAt, "EVENT_TYPE_WORKFLOW_EXECUTION_CANCELED", map[string]interface{}{})
+	}
+
+	return b.history(), nil
+}
+
+// exportActivity adds the scheduled, started and closing events of a step
+func exportActivity(b *temporalBuilder, st *StepRecord, queue string) {
+	scheduled := b.add(st.StartedAt, "EVENT_TYPE_ACTIVITY_TASK_SCHEDULED", map[string]interface{}{
+		"activityId":   st.StepKey,
+		"activityType": map[string]string{"name": st.StepID},
+		"taskQueue":    map[string]string{"name": queue},
+	})
+
+	startedAt, attempt := st.StartedAt, 1
+	startedAttrs := map[string]interface{}{}
+	if n := len(st.Attempts); n > 0 {
+		last := st.Attempts[n-1]
+		startedAt, attempt = last.StartedAt, last.Attempt
+		if last.WorkerID != "" {
+			startedAttrs["identity"] = last.WorkerID
+		}
+		if n > 1 && st.Attempts[n-2].Error != "" {
+			startedAttrs["lastFailure"] = temporalFailure(st.Attempts[n-2].Error)
+		}
+	} else if st.WorkerID != "" {
+		startedAttrs["identity"] = st.WorkerID
+	}
+	startedAttrs["attempt"] = attempt
+	started := b.add(startedAt, "EVENT_TYPE_ACTIVITY_TASK_STARTED", startedAttrs, "scheduledEventId", scheduled)
+
+	switch st.Status {
+	case "completed":
+		attrs := map[string]interface{}{}
+		if len(st.Output) > 0 {
+			attrs["result"] = temporalPayloads(st.Output)
+		}
+		b.add(st.CompletedAt, "EVENT_TYPE_ACTIVITY_TASK_COMPLETED", attrs,
+			"scheduledEventId", scheduled, "startedEventId", started)
+	case "failed":
+		b.add(st.CompletedAt, "EVENT_TYPE_ACTIVITY_TASK_FAILED", map[string]interface{}{
+			"failure": temporalFailure(st.Error),
+		}, "scheduledEventId", scheduled, "startedEventId", started)
+	case "canceled":
+		b.add(st.CompletedAt, "EVENT_TYPE_ACTIVITY_TASK_CANCELED", map[string]interface{}{},
+			"scheduledEventId", scheduled, "startedEventId", started)
+	}
+}
+
+// exportTimer adds the started and closing events of a sleep
+func (e *Engine) exportTimer(b *temporalBuilder, workflowID string, st *StepRecord) {
+	attrs := map[string]interface{}{"timerId": st.StepID}
+	if t, err := e.storage.GetTimer(fmt.Sprintf("%s/%s", workflowID, st.StepID)); err == nil && t != nil {
+		attrs["startToFireTimeout"] = temporalDuration(t.FireAt.Sub(st.StartedAt))
+	}
+	started := b.add(st.StartedAt, "EVENT_TYPE_TIMER_STARTED", attrs)
+
+	switch st.Status {
+	case "completed":
+		b.add(st.CompletedAt, "EVENT_TYPE_TIMER_FIRED", map[string]interface{}{"timerId": st.StepID},
+			"startedEventId", started)
+	case "failed", "canceled":
+		b.add(st.CompletedAt, "EVENT_TYPE_TIMER_CANCELED", map[string]interface{}{"timerId": st.StepID},
+			"startedEventId", started)
+	}
+}
+
+// exportSignalReceived adds the signal a WaitForSignal step consumed
+func exportSignalReceived(b *temporalBuilder, st *StepRecord) {
+	if st.Status != "completed" {
+		return
+	}
+	// Step IDs are "signal:<name>:<n>"
+	name := strings.TrimPrefix(st.StepID, "signal:")
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		name = name[:i]
+	}
+	attrs := map[string]interface{}{"signalName": name}
+	if len(st.Output) > 0 {
+		attrs["input"] = temporalPayloads(st.Output)
+	}
+	b.add(st.CompletedAt, "EVENT_TYPE_WORKFLOW_EXECUTION_SIGNALED", attrs)
+}
+
+// exportSignalSent adds the events of a SignalWorkflow step
+func exportSignalSent(b *temporalBuilder, st *StepRecord) {
+	// Step IDs are "signal-send:<target>:<name>", then "#2", "#3" for
+	// repeated sends
+	target := strings.TrimPrefix(st.StepID, "signal-send:")
+	if i := strings.LastIndex(target, "#"); i >= 0 {
+		if _, err := strconv.Atoi(target[i+1:]); err == nil {
+			target = target[:i]
+		}
+	}
+	name := ""
+	if i := strings.LastIndex(target, ":"); i >= 0 {
+		target, name = target[:i], target[i+1:]
+	}
+	initiated := b.add(st.StartedAt, "EVENT_TYPE_SIGNAL_EXTERNAL_WORKFLOW_EXECUTION_INITIATED", map[string]interface{}{
+		"workflowExecution": temporalExecution(target),
+		"signalName":        name,
+	})
+
+	switch st.Status {
+	case "completed":
+		b.add(st.CompletedAt, "EVENT_TYPE_EXTERNAL_WORKFLOW_EXECUTION_SIGNALED", map[string]interface{}{
+			"workflowExecution": temporalExecution(target),
+		}, "initiatedEventId", initiated)
+	case "failed":
+		b.add(st.CompletedAt, "EVENT_TYPE_SIGNAL_EXTERNAL_WORKFLOW_EXECUTION_FAILED", map[string]interface{}{
+			"workflowExecution": temporalExecution(target),
+		}, "initiatedEventId", initiated)
+	}
+}
+
+// exportChildStart adds the events of a StartChildDetached step
+func exportChildStart(b *temporalBuilder, st *StepRecord, childID string, child *WorkflowInfo) {
+	attrs := map[string]interface{}{"workflowId": childID, "parentClosePolicy": "PARENT_CLOSE_POLICY_ABANDON"}
+	if child != nil {
+		attrs["workflowType"] = map[string]string{"name": temporalWorkflowType(child)}
+		attrs["taskQueue"] = map[string]string{"name": child.Queue}
+	}
+	initiated := b.add(st.StartedAt, "EVENT_TYPE_START_CHILD_WORKFLOW_EXECUTION_INITIATED", attrs)
+
+	switch st.Status {
+	case "completed":
+		startedAttrs := map[string]interface{}{"workflowExecution": temporalExecution(childID)}
+		if child != nil {
+			startedAttrs["workflowType"] = map[string]string{"name": temporalWorkflowType(child)}
+		}
+		b.add(st.CompletedAt, "EVENT_TYPE_CHILD_WORKFLOW_EXECUTION_STARTED", startedAttrs,
+			"initiatedEventId", initiated)
+	case "failed":
+		b.add(st.CompletedAt, "EVENT_TYPE_START_CHILD_WORKFLOW_EXECUTION_FAILED", map[string]interface{}{
+			"workflowId": childID,
+		}, "initiatedEventId", initiated)
+	}
+}
+
+// temporalBuilder collects events and numbers them in time order
+type temporalBuilder struct {
+	events []*TemporalEvent
+	last   *TemporalEvent
+}
+
+// add records an event; refs are attribute name and event pairs
+func (b *temporalBuilder) add(at time.Time, eventType string, attrs map[string]interface{}, refs ...interface{}) *TemporalEvent {
+	ev := &TemporalEvent{EventTime: at, EventType: eventType, Attributes: attrs}
+	for i := 0; i+1 < len(refs); i += 2 {
+		if ev.refs == nil {
+			ev.refs = make(map[string]*TemporalEvent)
+		}
+		ev.refs[refs[i].(string)] = refs[i+1].(*TemporalEvent)
+	}
+	b.events = append(b.events, ev)
+	return ev
+}
+
+// addLast records the closing event of the run
+func (b *temporalBuilder) addLast(at time.Time, eventType string, attrs map[string]interface{}) {
+	b.last = &TemporalEvent{EventTime: at, EventType: eventType, Attributes: attrs}
+}
+
+// history orders and numbers the events. Runs recorded before timestamps
+// carried milliseconds are precise only to the second, so the start and
+// closing events are pinned to the ends rather than sorted.
+func (b *temporalBuilder) history() *TemporalHistory {
+	first, rest := b.events[0], b.events[1:]
+	sort.SliceStable(rest, func(i, j int) bool { return rest[i].EventTime.Before(rest[j].EventTime) })
+
+	events := append([]*TemporalEvent{first}, rest...)
+	if len(rest) > 0 && rest[0].EventTime.Before(first.EventTime) {
+		first.EventTime = rest[0].EventTime
+	}
+	if b.last != nil {
+		if end := events[len(events)-1].EventTime; b.last.EventTime.Before(end) {
+			b.last.EventTime = end
+		}
+		events = append(events, b.last)
+	}
+
+	for i, ev := range events {
+		ev.EventID = int64(i + 1)
+	}
+	for _, ev := range events {
+		for name, ref := range ev.refs {
+			ev.Attributes[name] = strconv.FormatInt(ref.EventID, 10)
+		}
+	}
+	return &TemporalHistory{Events: events}
+}
+
+// temporalWorkflowType names a run's workflow type
+func temporalWorkflowType(run *WorkflowInfo) string {
+	if run.Name != "" {
+		return run.Name
+	}
+	return run.WorkflowID
+}
+
+// temporalExecution identifies a run; workflow IDs double as run IDs
+func temporalExecution(workflowID string) map[string]string {
+	return map[string]string{"workflowId": workflowID, "runId": workflowID}
+}
+
+// temporalPayloads wraps encoded data as Temporal payloads. Data written by
+// the JSON codec is labelled json/plain, anything else binary/plain.
+func temporalPayloads(data []byte) map[string]interface{} {
+	encoding := "binary/plain"
+	if json.Valid(data) {
+		encoding = "json/plain"
+	}
+	return map[string]interface{}{
+		"payloads": []map[string]interface{}{{
+			"metadata": map[string]string{"encoding": base64.StdEncoding.EncodeToString([]byte(encoding))},
+			"data":     base64.StdEncoding.EncodeToString(data),
+		}},
+	}
+}
+
+// temporalFailure describes an error as a Temporal application failure
+func temporalFailure(message string) map[string]interface{} {
+	return map[string]interface{}{
+		"message":                message,
+		"source":                 temporalFailureSource,
+		"applicationFailureInfo": map[string]interface{}{},
+	}
+}
+
+// temporalDuration formats a duration as protobuf JSON does, e.g. "1.5s"
+func temporalDuration(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+}
@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportTemporalHistory(t *testing.T) {
+	dbPath := "./test_temporal.db"
+	defer os.Remove(dbPath)
+
+	engine, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	flaky := 0
+	engine.Execute("export-1", func(ctx *Context) error {
+		if _, err := Step(ctx, "charge", func() (string, error) {
+			flaky++
+			if flaky == 1 {
+				return "", errors.New("card declined")
+			}
+			return "ok", nil
+		}, WithRetry(RetryPolicy{MaxAttempts: 2, InitialInterval: time.Millisecond})); err != nil {
+			return err
+		}
+		if err := ctx.Sleep("cool-down", 10*time.Millisecond); err != nil {
+			return err
+		}
+		_, err := Step(ctx, "ship", func() (int, error) { return 0, errors.New("no courier") })
+		return err
+	})
+
+	history, err := engine.ExportTemporalHistory("export-1")
+	if err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+
+	types := make([]string, len(history.Events))
+	for i, ev := range history.Events {
+		types[i] = strings.TrimPrefix(ev.EventType, "EVENT_TYPE_")
+		if ev.EventID != int64(i+1) {
+			t.Errorf("event %d has ID %d", i, ev.EventID)
+		}
+		if i > 0 && ev.EventTime.Before(history.Events[i-1].EventTime) {
+			t.Errorf("event %d is older than the one before it", ev.EventID)
+		}
+	}
+	want := []string{
+		"WORKFLOW_EXECUTION_STARTED",
+		"ACTIVITY_TASK_SCHEDULED", "ACTIVITY_TASK_STARTED", "ACTIVITY_TASK_COMPLETED",
+		"TIMER_STARTED", "TIMER_FIRED",
+		"ACTIVITY_TASK_SCHEDULED", "ACTIVITY_TASK_STARTED", "ACTIVITY_TASK_FAILED",
+		"WORKFLOW_EXECUTION_FAILED",
+	}
+	if strings.Join(types, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected %v, got %v", want, types)
+	}
+
+	started := history.Events[2].Attributes
+	if started["attempt"] != 2 || started["scheduledEventId"] != "2" {
+		t.Errorf("unexpected activity started attributes: %v", started)
+	}
+	if failure, _ := started["lastFailure"].(map[string]interface{}); failure["message"] != "card declined" {
+		t.Errorf("expected the first attempt's failure, got %v", started["lastFailure"])
+	}
+	if fired := history.Events[5].Attributes; fired["timerId"] != "cool-down" || fired["startedEventId"] != "5" {
+		t.Errorf("unexpected timer fired attributes: %v", fired)
+	}
+
+	// The JSON form nests attributes under the event type's field, as Temporal does
+	data, err := json.Marshal(history)
+	if err != nil {
+		t.Fatalf("failed to marshal history: %v", err)
+	}
+	var decoded struct {
+		Events []map[string]json.RawMessage `json:"events"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("failed to decode history: %v", err)
+	}
+	if string(decoded.Events[0]["eventId"]) != `"1"` {
+		t.Errorf("expected a string event ID, got %s", decoded.Events[0]["eventId"])
+	}
+	if _, ok := decoded.Events[3]["activityTaskCompletedEventAttributes"]; !ok {
+		t.Errorf("expected activityTaskCompletedEventAttributes, got %v", decoded.Events[3])
+	}
+	if !strings.Contains(string(decoded.Events[3]["activityTaskCompletedEventAttributes"]), `"encoding":"anNvbi9wbGFpbg=="`) {
+		t.Errorf("expected a json/plain payload, got %s", decoded.Events[3]["activityTaskCompletedEventAttributes"])
+	}
+
+	if _, err := engine.ExportTemporalHistory("missing"); !errors.Is(err, ErrWorkflowNotFound) {
+		t.Errorf("expected ErrWorkflowNotFound, got %v", err)
+	}
+}
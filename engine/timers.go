@@ -0,0 +1,399 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrStepTimeout is returned when a step exceeds its durable timeout
+var ErrStepTimeout = errors.New("step timed out")
+
+// Timer kinds used by the engine itself
+const (
+	TimerKindSleep       = "sleep"
+	TimerKindStepTimeout = "step_timeout"
+)
+
+// timerRecheckInterval bounds how long a waiter sleeps before re-reading its timer row
+const timerRecheckInterval = time.Second
+
+// Timer is a durable timer row. Timers survive crashes: whoever is waiting
+// on one after a restart recomputes the remaining time from FireAt.
+type Timer struct {
+	ID         int64
+	Key        string
+	WorkflowID string
+	Kind       string
+	FireAt     time.Time
+	Status     string // 'pending', 'fired' or 'canceled'
+	Payload    []byte
+}
+
+// TimerHandler is invoked by the timer service when a timer of its kind fires
+type TimerHandler func(t Timer) error
+
+// timerHandlers maps timer kinds to handlers registered with HandleTimers
+type timerHandlers struct {
+	mu       sync.RWMutex
+	handlers map[string]TimerHandler
+}
+
+// HandleTimers registers the handler run by the timer service for a timer kind
+func (e *Engine) HandleTimers(kind string, fn TimerHandler) {
+	e.timers.mu.Lock()
+	defer e.timers.mu.Unlock()
+	if e.timers.handlers == nil {
+		e.timers.handlers = make(map[string]TimerHandler)
+	}
+	e.timers.handlers[kind] = fn
+}
+
+// ScheduleTimer durably creates a timer. Scheduling an existing key is a no-op
+// and returns the original timer, so callers can safely re-run after a crash.
+func (e *Engine) ScheduleTimer(workflowID, key, kind string, fireAt time.Time, payload []byte) (*Timer, error) {
+	t, err := e.storage.CreateTimer(workflowID, key, kind, fireAt, payload)
+	if err != nil {
+		return nil, err
+	}
+	e.emit(EngineEvent{Type: EventTimerScheduled, WorkflowID: workflowID, Kind: kind, Timer: key, FireAt: t.FireAt})
+	return t, nil
+}
+
+// scheduleStepTimeout schedules a step's timeout timer. A pending one is kept,
+// so a step resumed after a crash keeps its deadline; one left fired or
+// canceled by an earlier execution of the step is restarted at fireAt.
+func (e *Engine) scheduleStepTimeout(workflowID, key string, fireAt time.Time) (*Timer, error) {
+	t, err := e.storage.RestartTimer(workflowID, key, TimerKindStepTimeout, fireAt)
+	if err != nil {
+		return nil, err
+	}
+	e.emit(EngineEvent{Type: EventTimerScheduled, WorkflowID: workflowID, Kind: TimerKindStepTimeout, Timer: key, FireAt: t.FireAt})
+	return t, nil
+}
+
+// CancelTimer cancels a pending timer; it is a no-op if the timer already fired
+func (e *Engine) CancelTimer(key string) error {
+	canceled, err := e.storage.UpdateTimerStatus(key, "pending", "canceled")
+	if err != nil {
+		return err
+	}
+	if canceled && e.bus.active() {
+		ev := EngineEvent{Type: EventTimerCanceled, Timer: key}
+		if t, err := e.storage.GetTimer(key); err == nil && t != nil {
+			ev.WorkflowID, ev.Kind = t.WorkflowID, t.Kind
+		}
+		e.emit(ev)
+	}
+	return nil
+}
+
+// fireTimer marks a due timer as fired and reports whether this call fired it
+func (e *Engine) fireTimer(t Timer) (bool, error) {
+	fired, err := e.storage.FireTimer(t.Key)
+	if err != nil || !fired {
+		return false, err
+	}
+	e.emit(EngineEvent{Type: EventTimerFired, WorkflowID: t.WorkflowID, Kind: t.Kind, Timer: t.Key, FireAt: t.FireAt})
+	return true, nil
+}
+
+// StartTimerService fires due timers and runs their handlers. It runs on the
+// elected leader only, so each timer's handler runs once across the fleet.
+func (e *Engine) StartTimerService(pollInterval time.Duration) {
+	if pollInterval <= 0 {
+		pollInterval = time.Second
+	}
+
+	e.RunSingleton("timer-service", 0, func(ctx context.Context) {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			if err := e.fireDueTimers(time.Now()); err != nil {
+				fmt.Printf("[TIMERS] %v\n", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+// fireDueTimers marks every due pending timer as fired and runs its handler
+func (e *Engine) fireDueTimers(now time.Time) error {
+	due, err := e.storage.ListDueTimers(now)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range due {
+		fired, err := e.fireTimer(t)
+		if err != nil {
+			return err
+		}
+		if !fired {
+			continue
+		}
+
+		e.timers.mu.RLock()
+		handler := e.timers.handlers[t.Kind]
+		e.timers.mu.RUnlock()
+		if handler == nil {
+			continue
+		}
+		if err := handler(t); err != nil {
+			fmt.Printf("[TIMERS] handler for %s (%s) failed: %v\n", t.Key, t.Kind, err)
+		}
+	}
+
+	return nil
+}
+
+// waitForTimer blocks until the timer fires (returns true) or is canceled (returns false).
+// A waiter whose timer comes due fires it itself, so sleeps never depend on the
+// timer service being enabled.
+func (e *Engine) waitForTimer(key string) (bool, error) {
+	for {
+		t, err := e.storage.GetTimer(key)
+		if err != nil {
+			return false, err
+		}
+		if t == nil {
+			return false, fmt.Errorf("timer %s not found", key)
+		}
+
+		switch t.Status {
+		case "fired":
+			return true, nil
+		case "canceled":
+			return false, nil
+		}
+
+		remaining := time.Until(t.FireAt)
+		if remaining <= 0 {
+			if _, err := e.fireTimer(*t); err != nil {
+				return false, err
+			}
+			continue
+		}
+		if remaining > timerRecheckInterval {
+			remaining = timerRecheckInterval
+		}
+
+		select {
+		case <-e.stop:
+			return false, errors.New("engine closed while waiting for timer")
+		case <-time.After(remaining):
+		}
+	}
+}
+
+// Sleep durably pauses the workflow for d. The wake-up time is persisted on
+// first call, so a workflow resumed after a crash only sleeps the remainder.
+func (ctx *Context) Sleep(id string, d time.Duration) error {
+	_, err := Step(ctx, id, func() (bool, error) {
+		key := fmt.Sprintf("%s/%s", ctx.WorkflowID, id)
+		if _, err := ctx.engine.ScheduleTimer(ctx.WorkflowID, key, TimerKindSleep, time.Now().Add(d), nil); err != nil {
+			return false, fmt.Errorf("failed to schedule sleep: %w", err)
+		}
+		fired, err := ctx.engine.waitForTimer(key)
+		if err != nil {
+			return false, err
+		}
+		if !fired {
+			return false, fmt.Errorf("sleep %s was canceled", id)
+		}
+		return true, nil
+	}, withStepKind(StepKindSleep))
+	return err
+}
+
+// WithStepTimeout fails the step with ErrStepTimeout if it has not completed
+// within d of its first attempt. The deadline is durable across restarts.
+func WithStepTimeout(d time.Duration) StepOption {
+	return func(o *stepOptions) {
+		o.timeout = d
+	}
+}
+
+// stepTimeoutKey is the key of a step's durable timeout timer; the orphan
+// scanner's query builds it too
+func stepTimeoutKey(workflowID, stepID string) string {
+	return fmt.Sprintf("%s/%s/timeout", workflowID, stepID)
+}
+
+// executeWithTimeout races fn against the step's durable timeout timer, if it
+// has a timeout, and against the run's cancellation and deadline. fn is given
+// a stepStop that is stopped when either wins, so its retry loop starts no
+// further attempt; the attempt in flight is abandoned.
+func executeWithTimeout[T any](ctx *Context, id string, timeout time.Duration, fn func(stop *stepStop) (T, error)) (T, error) {
+	var zero T
+
+	key := stepTimeoutKey(ctx.WorkflowID, id)
+	var expired <-chan time.Time
+	if timeout > 0 {
+		t, err := ctx.engine.scheduleStepTimeout(ctx.WorkflowID, key, time.Now().Add(timeout))
+		if err != nil {
+			return zero, fmt.Errorf("failed to schedule step timeout: %w", err)
+		}
+		expired = time.After(time.Until(t.FireAt))
+	}
+
+	stop := newStepStop(ctx.cancellationContext())
+	defer stop.cancel(nil)
+
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		v, err := fn(stop)
+		done <- result{v, err}
+	}()
+
+	select {
+	case r := <-done:
+		if timeout > 0 {
+			ctx.engine.CancelTimer(key)
+		}
+		return r.value, r.err
+	case <-ctx.Done():
+		// The body may not watch ctx.Done(); stop waiting for it regardless
+		stop.stop(nil)
+		if timeout > 0 {
+			ctx.engine.CancelTimer(key)
+		}
+		return zero, ctx.doneErr()
+	case <-expired:
+		err := fmt.Errorf("%w: %s exceeded %v", ErrStepTimeout, id, timeout)
+		stop.stop(err)
+		ctx.engine.fireTimer(Timer{Key: key, WorkflowID: ctx.WorkflowID, Kind: TimerKindStepTimeout})
+		return zero, err
+	}
+}
+
+// CreateTimer inserts a pending timer unless one with the same key exists
+func (s *Storage) CreateTimer(workflowID, key, kind string, fireAt time.Time, payload []byte) (*Timer, error) {
+	err := s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			`INSERT OR IGNORE INTO timers (timer_key, workflow_id, kind, fire_at_ms, status, payload, created_at)
+			 VALUES (?, ?, ?, ?, 'pending', ?, ?)`,
+			key, workflowID, kind, fireAt.UnixMilli(), payload, dbNow(),
+		)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create timer: %w", err)
+	}
+	return s.GetTimer(key)
+}
+
+// RestartTimer inserts a pending timer, or makes an existing one that
+// already fired or was canceled pending again at fireAt
+func (s *Storage) RestartTimer(workflowID, key, kind string, fireAt time.Time) (*Timer, error) {
+	err := s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			`INSERT INTO timers (timer_key, workflow_id, kind, fire_at_ms, status, created_at)
+			 VALUES (?, ?, ?, ?, 'pending', ?)
+			 ON CONFLICT(timer_key) DO UPDATE SET fire_at_ms = excluded.fire_at_ms, status = 'pending'
+			 WHERE timers.status != 'pending'`,
+			key, workflowID, kind, fireAt.UnixMilli(), dbNow(),
+		)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to restart timer: %w", err)
+	}
+	return s.GetTimer(key)
+}
+
+// GetTimer loads a timer by key, returning nil if it does not exist
+func (s *Storage) GetTimer(key string) (*Timer, error) {
+	var t Timer
+	var fireAtMs int64
+	err := s.db.QueryRow(
+		`SELECT id, timer_key, workflow_id, kind, fire_at_ms, status, payload
+		 FROM timers WHERE timer_key = ?`,
+		key,
+	).Scan(&t.ID, &t.Key, &t.WorkflowID, &t.Kind, &fireAtMs, &t.Status, &t.Payload)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get timer: %w", err)
+	}
+
+	t.FireAt = time.UnixMilli(fireAtMs)
+	return &t, nil
+}
+
+// ListDueTimers returns pending timers whose fire time has passed
+func (s *Storage) ListDueTimers(now time.Time) ([]Timer, error) {
+	rows, err := s.db.Query(
+		`SELECT id, timer_key, workflow_id, kind, fire_at_ms, status, payload
+		 FROM timers WHERE status = 'pending' AND fire_at_ms <= ? ORDER BY fire_at_ms`,
+		now.UnixMilli(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due timers: %w", err)
+	}
+	defer rows.Close()
+
+	var timers []Timer
+	for rows.Next() {
+		var t Timer
+		var fireAtMs int64
+		if err := rows.Scan(&t.ID, &t.Key, &t.WorkflowID, &t.Kind, &fireAtMs, &t.Status, &t.Payload); err != nil {
+			return nil, fmt.Errorf("failed to scan timer: %w", err)
+		}
+		t.FireAt = time.UnixMilli(fireAtMs)
+		timers = append(timers, t)
+	}
+
+	return timers, rows.Err()
+}
+
+// FireTimer flips a pending timer to fired; it reports false if someone else already did
+func (s *Storage) FireTimer(key string) (bool, error) {
+	var affected int64
+	err := s.retryOnBusy(func() error {
+		res, err := s.db.Exec(
+			"UPDATE timers SET status = 'fired' WHERE timer_key = ? AND status = 'pending'",
+			key,
+		)
+		if err != nil {
+			return err
+		}
+		affected, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to fire timer: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// UpdateTimerStatus moves a timer between statuses if it is currently in
+// from, and reports whether it was
+func (s *Storage) UpdateTimerStatus(key, from, to string) (bool, error) {
+	var affected int64
+	err := s.retryOnBusy(func() error {
+		res, err := s.db.Exec(
+			"UPDATE timers SET status = ? WHERE timer_key = ? AND status = ?",
+			to, key, from,
+		)
+		if err != nil {
+			return err
+		}
+		affected, err = res.RowsAffected()
+		return err
+	})
+	return affected > 0, err
+}
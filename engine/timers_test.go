@@ -0,0 +1,166 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDurableSleepSurvivesRestart(t *testing.T) {
+	dbPath := "./test_sleep.db"
+	defer os.Remove(dbPath)
+
+	workflow := func(ctx *Context) error {
+		return ctx.Sleep("cool-down", 300*time.Millisecond)
+	}
+
+	eng1, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- eng1.Execute("sleepy", workflow) }()
+
+	// "Crash" the first process partway through the sleep
+	time.Sleep(150 * time.Millisecond)
+	eng1.Close()
+	<-done
+
+	eng2, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to reopen engine: %v", err)
+	}
+	defer eng2.Close()
+
+	start := time.Now()
+	if err := eng2.Execute("sleepy", workflow); err != nil {
+		t.Fatalf("resumed workflow failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Errorf("expected resume to sleep only the remainder, slept %v", elapsed)
+	}
+}
+
+func TestTimerServiceRunsHandlers(t *testing.T) {
+	dbPath := "./test_timer_service.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	fired := make(chan Timer, 1)
+	eng.HandleTimers("approval_timeout", func(tm Timer) error {
+		fired <- tm
+		return nil
+	})
+
+	if _, err := eng.ScheduleTimer("wf-1", "wf-1/approval", "approval_timeout", time.Now().Add(20*time.Millisecond), []byte("escalate")); err != nil {
+		t.Fatalf("failed to schedule timer: %v", err)
+	}
+	eng.StartTimerService(10 * time.Millisecond)
+
+	select {
+	case tm := <-fired:
+		if string(tm.Payload) != "escalate" {
+			t.Errorf("unexpected payload %q", tm.Payload)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timer handler never ran")
+	}
+}
+
+func TestStepTimeout(t *testing.T) {
+	dbPath := "./test_step_timeout.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	err = eng.Execute("slow", func(ctx *Context) error {
+		_, err := Step(ctx, "slow-call", func() (string, error) {
+			time.Sleep(200 * time.Millisecond)
+			return "late", nil
+		}, WithStepTimeout(30*time.Millisecond))
+		return err
+	})
+	if !errors.Is(err, ErrStepTimeout) {
+		t.Fatalf("expected ErrStepTimeout, got %v", err)
+	}
+}
+
+func TestStepTimeoutStopsRetries(t *testing.T) {
+	dbPath := "./test_step_timeout_retry.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	var calls, after int32
+	err = eng.Execute("flaky", func(ctx *Context) error {
+		_, err := Step(ctx, "flaky-call", func() (string, error) {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(10 * time.Millisecond)
+			return "", errors.New("connection refused")
+		}, WithStepTimeout(50*time.Millisecond), WithRetry(RetryPolicy{MaxAttempts: 6, InitialInterval: 20 * time.Millisecond, Multiplier: 1}))
+
+		// No attempt starts once the step has timed out, while the run goes on
+		after = atomic.LoadInt32(&calls)
+		time.Sleep(200 * time.Millisecond)
+		return err
+	})
+	if !errors.Is(err, ErrStepTimeout) {
+		t.Fatalf("expected ErrStepTimeout, got %v", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != after || got >= 6 {
+		t.Errorf("expected retries to stop at the timeout, got %d calls then %d", after, got)
+	}
+	history, err := eng.GetHistory("flaky")
+	if err != nil || len(history) != 1 || len(history[0].Attempts) != int(after) {
+		t.Errorf("expected %d recorded attempts, got %+v, %v", after, history, err)
+	}
+}
+
+func TestStepTimeoutRestartsOnRerun(t *testing.T) {
+	dbPath := "./test_step_timeout_rerun.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	run := func(work time.Duration) error {
+		return eng.Execute("rerun", func(ctx *Context) error {
+			_, err := Step(ctx, "call", func() (string, error) {
+				time.Sleep(work)
+				return "done", nil
+			}, WithStepTimeout(100*time.Millisecond))
+			return err
+		})
+	}
+	if err := run(300 * time.Millisecond); !errors.Is(err, ErrStepTimeout) {
+		t.Fatalf("expected ErrStepTimeout, got %v", err)
+	}
+
+	// Re-executing the failed run gives the step a fresh deadline, not the fired one
+	time.Sleep(50 * time.Millisecond)
+	if err := run(10 * time.Millisecond); err != nil {
+		t.Fatalf("expected the rerun to finish within its own timeout, got %v", err)
+	}
+	timer, err := eng.storage.GetTimer(stepTimeoutKey("rerun", "call"))
+	if err != nil || timer == nil || timer.Status != "canceled" {
+		t.Errorf("expected the rerun's timer canceled on completion, got %+v, %v", timer, err)
+	}
+}
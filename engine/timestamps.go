@@ -0,0 +1,35 @@
+package engine
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// timestampLayout is how timestamps are stored: UTC with milliseconds, so
+// they order correctly as text and support step latency analysis
+const timestampLayout = "2006-01-02 15:04:05.000"
+
+// lastTimestamp is the most recent timestamp dbNow handed out, in Unix milliseconds
+var lastTimestamp atomic.Int64
+
+// dbNow returns the current time in the stored layout. Timestamps are
+// generated here rather than with SQLite's CURRENT_TIMESTAMP, which only has
+// second precision, and never go backwards within a process, so a step
+// can't complete before it started if the wall clock is stepped back.
+func dbNow() string {
+	now := time.Now().UnixMilli()
+	for {
+		last := lastTimestamp.Load()
+		if now < last {
+			now = last
+		}
+		if lastTimestamp.CompareAndSwap(last, now) {
+			return dbTime(time.UnixMilli(now))
+		}
+	}
+}
+
+// dbTime formats t in the stored timestamp layout
+func dbTime(t time.Time) string {
+	return t.UTC().Format(timestampLayout)
+}
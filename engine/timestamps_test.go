@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTimestampsHaveMilliseconds(t *testing.T) {
+	dbPath := "./test_timestamps.db"
+	defer os.Remove(dbPath)
+
+	engine, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	before := time.Now().Add(-time.Millisecond)
+	err = engine.Execute("timestamps-1", func(ctx *Context) error {
+		_, err := Step(ctx, "short", func() (int, error) {
+			time.Sleep(30 * time.Millisecond)
+			return 1, nil
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+
+	steps, err := engine.GetHistory("timestamps-1")
+	if err != nil || len(steps) != 1 {
+		t.Fatalf("expected one step, got %d (%v)", len(steps), err)
+	}
+	if d := steps[0].Duration(); d < 30*time.Millisecond || d >= time.Second {
+		t.Errorf("expected a duration of about 30ms, got %v", d)
+	}
+
+	run, err := engine.GetWorkflow("timestamps-1")
+	if err != nil {
+		t.Fatalf("failed to get workflow: %v", err)
+	}
+	if run.CreatedAt.Before(before) || run.UpdatedAt.Before(steps[0].CompletedAt) {
+		t.Errorf("expected run timestamps to bracket its step with milliseconds, got %v and %v",
+			run.CreatedAt, run.UpdatedAt)
+	}
+}
+
+func TestDBNowIsMonotonic(t *testing.T) {
+	lastTimestamp.Store(time.Now().Add(time.Hour).UnixMilli())
+	defer lastTimestamp.Store(0)
+
+	a, b := dbNow(), dbNow()
+	if b < a {
+		t.Errorf("expected timestamps not to go backwards, got %s then %s", a, b)
+	}
+	if parsed, err := time.Parse(timestampLayout, a); err != nil || parsed.Before(time.Now()) {
+		t.Errorf("expected the later stored timestamp to be kept, got %s (%v)", a, err)
+	}
+}
@@ -0,0 +1,78 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// W3C Trace Context headers, read by OpenTelemetry's default propagators.
+// A run started WithHeader(TraceParentHeader, ...) continues that trace, and
+// its BaggageHeader entries are passed on to every step's outbound calls.
+const (
+	TraceParentHeader = "traceparent"
+	BaggageHeader     = "baggage"
+)
+
+// stepTrace derives the trace and span IDs of a step attempt. The trace is
+// the one the run was started under, or one derived from the workflow ID so
+// every step of a run shares a trace; the span ID is derived from the step
+// key and attempt, so it is the same on every worker that computes it.
+func stepTrace(workflowID, runTraceParent, stepKey string, attempt int) (traceID, spanID, flags string) {
+	traceID, flags = parseTraceParent(runTraceParent)
+	if traceID == "" {
+		sum := sha256.Sum256([]byte("trace\x00" + workflowID))
+		traceID, flags = hex.EncodeToString(sum[:16]), "01"
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("span\x00%s\x00%s\x00%d", workflowID, stepKey, attempt)))
+	return traceID, hex.EncodeToString(sum[:8]), flags
+}
+
+// parseTraceParent returns the trace ID and flags of a version 00
+// traceparent value, or "" if it isn't one
+func parseTraceParent(value string) (traceID, flags string) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return "", ""
+	}
+	if _, err := hex.DecodeString(parts[1] + parts[2] + parts[3]); err != nil || parts[1] == strings.Repeat("0", 32) {
+		return "", ""
+	}
+	return parts[1], parts[3]
+}
+
+// TraceParent returns the W3C traceparent of this step attempt, so services
+// it calls join the run's trace as children of the step
+func (info StepInfo) TraceParent() string {
+	return fmt.Sprintf("00-%s-%s-%s", info.TraceID, info.SpanID, info.traceFlags)
+}
+
+// Baggage returns a W3C baggage value naming the run and step, after any
+// baggage the run was started with
+func (info StepInfo) Baggage() string {
+	var entries []string
+	if run := strings.TrimSpace(info.Headers[BaggageHeader]); run != "" {
+		entries = append(entries, run)
+	}
+	entries = append(entries, "workflow.id="+url.PathEscape(info.WorkflowID))
+	if info.WorkflowName != "" {
+		entries = append(entries, "workflow.name="+url.PathEscape(info.WorkflowName))
+	}
+	entries = append(entries,
+		"step.id="+url.PathEscape(info.StepID),
+		"step.key="+url.PathEscape(info.StepKey),
+		"step.attempt="+strconv.Itoa(info.Attempt),
+	)
+	return strings.Join(entries, ",")
+}
+
+// InjectTraceHeaders sets the traceparent and baggage headers of an outbound
+// request made by the step
+func (info StepInfo) InjectTraceHeaders(h http.Header) {
+	h.Set(TraceParentHeader, info.TraceParent())
+	h.Set(BaggageHeader, info.Baggage())
+}
@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStepTraceHeaders(t *testing.T) {
+	dbPath := "./test_tracing.db"
+	defer os.Remove(dbPath)
+
+	engine, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	var sent []http.Header
+	call := func(info StepInfo) (bool, error) {
+		h := http.Header{}
+		info.InjectTraceHeaders(h)
+		sent = append(sent, h)
+		if info.Attempt == 1 {
+			return false, errors.New("503")
+		}
+		return true, nil
+	}
+
+	const caller = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	err = engine.Execute("trace-1", func(ctx *Context) error {
+		_, err := StepWithInfo(ctx, "call-vendor", call, WithRetry(RetryPolicy{MaxAttempts: 2, InitialInterval: time.Millisecond}))
+		return err
+	}, WithHeader(TraceParentHeader, caller), WithHeader(BaggageHeader, "tenant=acme"))
+	if err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+
+	if len(sent) != 2 {
+		t.Fatalf("expected two attempts, got %d", len(sent))
+	}
+	first, second := sent[0].Get(TraceParentHeader), sent[1].Get(TraceParentHeader)
+	if !strings.HasPrefix(first, "00-4bf92f3577b34da6a3ce929d0e0e4736-") || !strings.HasSuffix(first, "-01") {
+		t.Errorf("expected the step to continue the caller's trace, got %s", first)
+	}
+	if first == second || first == caller {
+		t.Errorf("expected a span per attempt, got %s and %s", first, second)
+	}
+	if got := sent[1].Get(BaggageHeader); got != "tenant=acme,workflow.id=trace-1,step.id=call-vendor,step.key=call-vendor:1,step.attempt=2" {
+		t.Errorf("unexpected baggage %q", got)
+	}
+
+	// Runs without a traceparent get a stable trace of their own
+	a, _, _ := stepTrace("trace-2", "", "s:1", 1)
+	b, _, _ := stepTrace("trace-2", "not-a-traceparent", "s:2", 1)
+	if a != b || len(a) != 32 {
+		t.Errorf("expected one derived trace per run, got %s and %s", a, b)
+	}
+}
@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+)
+
+// StorageTx is a view of the storage whose methods all run in one
+// transaction: their writes commit together or not at all
+type StorageTx struct {
+	*Storage
+}
+
+// WithTx runs fn in a transaction and commits it if fn returns nil. Every
+// Storage method called on tx joins the transaction, including ones that
+// open their own, which become savepoints; a WithTx on tx nests the same
+// way. fn is run again from the start if the database is busy, so it must
+// not have side effects outside tx. Storage other than tx must not be used
+// inside fn: the transaction holds the database's only connection.
+func (s *Storage) WithTx(fn func(tx StorageTx) error) error {
+	return s.retryOnBusy(func() error {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		view := *s
+		view.db = &instrumentedDB{DB: s.db.DB, metrics: s.db.metrics, tx: tx.Tx}
+		if err := fn(StorageTx{&view}); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+// savepointSeq names the savepoints of nested transactions
+var savepointSeq int64
+
+// beginSavepoint starts a nested transaction within db's transaction
+func (db *instrumentedDB) beginSavepoint() (*instrumentedTx, error) {
+	name := fmt.Sprintf("sp_%d", atomic.AddInt64(&savepointSeq, 1))
+	if _, err := db.Exec("SAVEPOINT " + name); err != nil {
+		return nil, err
+	}
+	return &instrumentedTx{Tx: db.tx, metrics: db.metrics, savepoint: name}, nil
+}
+
+// sqlConn runs statements on a database or in a transaction
+type sqlConn interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// conn returns the handle statements run on: the transaction, if any
+func (db *instrumentedDB) conn() sqlConn {
+	if db.tx != nil {
+		return db.tx
+	}
+	return db.DB
+}
@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWithTxCommitsOrRollsBackTogether(t *testing.T) {
+	dbPath := "./test_tx.db"
+	defer os.Remove(dbPath)
+
+	s, err := NewStorage(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer s.Close()
+
+	// A failure rolls back every write, including those of methods with their own transaction
+	boom := errors.New("boom")
+	err = s.WithTx(func(tx StorageTx) error {
+		if err := tx.CreateWorkflow("tx-1", 0); err != nil {
+			return err
+		}
+		if err := tx.MarkStepInProgress("tx-1", "a:1", "a", 1, StepKindStep, "", nil, Executor{}, time.Now()); err != nil {
+			return err
+		}
+		if err := tx.SaveStep("tx-1", "a:1", 0, []byte(`"done"`)); err != nil {
+			return err
+		}
+		return boom
+	})
+	if err != boom {
+		t.Fatalf("expected fn's error, got %v", err)
+	}
+	if _, err := s.GetWorkflowStatus("tx-1"); !errors.Is(err, ErrWorkflowNotFound) {
+		t.Errorf("expected the workflow to be rolled back, got %v", err)
+	}
+
+	// A failed nested transaction undoes only its own writes
+	err = s.WithTx(func(tx StorageTx) error {
+		if err := tx.CreateWorkflow("tx-2", 0); err != nil {
+			return err
+		}
+		nested := tx.WithTx(func(tx StorageTx) error {
+			if err := tx.CreateWorkflow("tx-3", 0); err != nil {
+				return err
+			}
+			return boom
+		})
+		if nested != boom {
+			t.Errorf("expected the nested error, got %v", nested)
+		}
+		return tx.MarkStepInProgress("tx-2", "a:1", "a", 1, StepKindStep, "", nil, Executor{}, time.Now())
+	})
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	if status, err := s.GetWorkflowStatus("tx-2"); err != nil || status != "running" {
+		t.Errorf("expected tx-2 to be committed, got %q, %v", status, err)
+	}
+	if _, err := s.GetWorkflowStatus("tx-3"); !errors.Is(err, ErrWorkflowNotFound) {
+		t.Errorf("expected tx-3 to be rolled back, got %v", err)
+	}
+	if steps, err := s.ListSteps("tx-2"); err != nil || len(steps) != 1 {
+		t.Errorf("expected tx-2's step to be committed, got %d, %v", len(steps), err)
+	}
+}
+
+func TestCompletionStartsContinuationsAtomically(t *testing.T) {
+	dbPath := "./test_tx_chain.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	eng.Register("notify", func(ctx *Context) error { return nil })
+	err = eng.Execute("order-1", func(ctx *Context) error {
+		// Another process settles the run before it completes
+		_, version, err := eng.storage.GetWorkflowVersion(ctx.WorkflowID)
+		if err != nil {
+			return err
+		}
+		return eng.storage.CompareAndSetWorkflowStatus(ctx.WorkflowID, "failed", version)
+	}, WithOnComplete("notify"))
+	if !errors.Is(err, ErrStatusConflict) {
+		t.Fatalf("expected a status conflict, got %v", err)
+	}
+	if _, err := eng.storage.GetWorkflowStatus(ContinuationWorkflowID("order-1", "notify")); !errors.Is(err, ErrWorkflowNotFound) {
+		t.Errorf("expected no continuation for a run that didn't complete, got %v", err)
+	}
+
+	if err := eng.Execute("order-2", func(ctx *Context) error { return nil }, WithOnComplete("notify")); err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	if status, err := eng.storage.GetWorkflowStatus(ContinuationWorkflowID("order-2", "notify")); err != nil || status == "" {
+		t.Errorf("expected the continuation to be queued with the completion, got %q, %v", status, err)
+	}
+}
@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// TxStepTable is the table TxStep keeps its commit markers in, in the
+// application's database. Rows may be deleted once their workflow completed.
+const TxStepTable = "durable_tx_steps"
+
+// txTablesCreated remembers the databases TxStepTable exists in
+var txTablesCreated sync.Map
+
+// TxStep runs fn in a transaction on the application's database db and
+// completes the step only if the transaction commits. A marker row with the
+// step's encoded result is written in the same transaction, so if the
+// process dies after the commit but before the step is recorded, the retry
+// finds the marker and returns its result instead of running fn again: the
+// writes happen effectively once. fn must do all its writes through tx and
+// must not commit or roll it back.
+func TxStep[T any](ctx *Context, id string, db *sql.DB, fn func(tx *sql.Tx) (T, error), opts ...StepOption) (T, error) {
+	return StepWithInfo(ctx, id, func(info StepInfo) (T, error) {
+		var zero T
+		if err := ensureTxStepTable(db); err != nil {
+			return zero, err
+		}
+		// Every attempt and resume must find the same marker
+		info.idempotency = IdempotencyPerStep
+		key := info.IdempotencyKey()
+
+		tx, err := db.BeginTx(ctx.Context(), nil)
+		if err != nil {
+			return zero, fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		var stored string
+		err = tx.QueryRow(rebindTx(db, "SELECT output FROM "+TxStepTable+" WHERE step_key = ?"), key).Scan(&stored)
+		if err == nil {
+			// An earlier attempt committed; its result is the step's
+			var result T
+			data, err := base64.StdEncoding.DecodeString(stored)
+			if err == nil {
+				err = ctx.engine.codec.Unmarshal(data, &result)
+			}
+			if err != nil {
+				return zero, fmt.Errorf("failed to decode committed result: %w", err)
+			}
+			ctx.printf("[TX] %s already committed\n", id)
+			return result, nil
+		}
+		if err != sql.ErrNoRows {
+			return zero, fmt.Errorf("failed to check commit marker: %w", err)
+		}
+
+		result, err := fn(tx)
+		if err != nil {
+			return zero, err
+		}
+		data, err := ctx.engine.codec.Marshal(result)
+		if err != nil {
+			return zero, fmt.Errorf("failed to marshal result: %w", err)
+		}
+		if _, err := tx.Exec(
+			rebindTx(db, "INSERT INTO "+TxStepTable+" (step_key, workflow_id, output, created_at) VALUES (?, ?, ?, ?)"),
+			key, ctx.WorkflowID, base64.StdEncoding.EncodeToString(data), dbNow(),
+		); err != nil {
+			return zero, fmt.Errorf("failed to write commit marker: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return zero, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return result, nil
+	}, opts...)
+}
+
+// ensureTxStepTable creates TxStepTable in db the first time it is used
+func ensureTxStepTable(db *sql.DB) error {
+	if _, ok := txTablesCreated.Load(db); ok {
+		return nil
+	}
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS ` + TxStepTable + ` (
+		step_key VARCHAR(64) PRIMARY KEY,
+		workflow_id TEXT NOT NULL,
+		output TEXT NOT NULL,
+		created_at VARCHAR(32) NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("failed to create %s: %w", TxStepTable, err)
+	}
+	txTablesCreated.Store(db, true)
+	return nil
+}
+
+// rebindTx rewrites ? placeholders to $1, $2, ... for PostgreSQL drivers
+func rebindTx(db *sql.DB, query string) string {
+	driver := fmt.Sprintf("%T", db.Driver())
+	if !strings.Contains(driver, "pq.") && !strings.Contains(driver, "pgx") && !strings.Contains(driver, "stdlib.") {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+)
+
+// openAppDB opens an application database with an accounts table
+func openAppDB(t *testing.T, path string) *sql.DB {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("failed to open app db: %v", err)
+	}
+	if _, err := db.Exec("CREATE TABLE accounts (id TEXT PRIMARY KEY, balance INTEGER NOT NULL)"); err != nil {
+		t.Fatalf("failed to create accounts: %v", err)
+	}
+	return db
+}
+
+func TestTxStepRollsBackFailedAttempts(t *testing.T) {
+	dbPath := "./test_txstep.db"
+	appPath := "./test_txstep_app.db"
+	defer os.Remove(dbPath)
+	defer os.Remove(appPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+	app := openAppDB(t, appPath)
+	defer app.Close()
+
+	attempts := 0
+	err = eng.Execute("open-account", func(ctx *Context) error {
+		_, err := TxStep(ctx, "insert", app, func(tx *sql.Tx) (int, error) {
+			attempts++
+			if _, err := tx.Exec("INSERT INTO accounts (id, balance) VALUES ('acct-1', 100)"); err != nil {
+				return 0, err
+			}
+			if attempts == 1 {
+				return 0, errors.New("validation failed")
+			}
+			return 100, nil
+		}, WithRetry(RetryPolicy{MaxAttempts: 2}))
+		return err
+	})
+	if err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+
+	var rows int
+	app.QueryRow("SELECT COUNT(*) FROM accounts").Scan(&rows)
+	if attempts != 2 || rows != 1 {
+		t.Errorf("expected the failed attempt to roll back, got %d attempts and %d rows", attempts, rows)
+	}
+}
+
+func TestTxStepCommittedButUnrecorded(t *testing.T) {
+	dbPath := "./test_txstep_marker.db"
+	appPath := "./test_txstep_marker_app.db"
+	defer os.Remove(dbPath)
+	defer os.Remove(appPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+	app := openAppDB(t, appPath)
+	defer app.Close()
+
+	runs := 0
+	workflow := func(ctx *Context) error {
+		balance, err := TxStep(ctx, "credit", app, func(tx *sql.Tx) (int, error) {
+			runs++
+			if _, err := tx.Exec("INSERT INTO accounts (id, balance) VALUES ('acct-2', 50)"); err != nil {
+				return 0, err
+			}
+			return 50, nil
+		})
+		if err != nil {
+			return err
+		}
+		if balance != 50 {
+			return errors.New("wrong balance")
+		}
+		return nil
+	}
+	if err := eng.Execute("credit-account", workflow); err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+
+	// Simulate a crash between the application commit and recording the step
+	if _, err := eng.storage.db.Exec("DELETE FROM steps WHERE workflow_id = 'credit-account'"); err != nil {
+		t.Fatalf("failed to drop step: %v", err)
+	}
+	if err := eng.storage.UpdateWorkflowStatus("credit-account", "running"); err != nil {
+		t.Fatalf("failed to reset status: %v", err)
+	}
+	if err := eng.Execute("credit-account", workflow); err != nil {
+		t.Fatalf("resumed run failed: %v", err)
+	}
+	if runs != 1 {
+		t.Errorf("expected the committed transaction not to run again, ran %d times", runs)
+	}
+}
@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrWorkflowNotCompleted is returned when asking for the result of a workflow that has not completed
+var ErrWorkflowNotCompleted = errors.New("workflow not completed")
+
+// Workflow is a named workflow definition with a typed input and output.
+// Both are encoded with the engine's codec: the input is stored when the
+// workflow starts and replayed on resume, and the output is stored on completion.
+type Workflow[In, Out any] struct {
+	Name string
+	Fn   func(ctx *Context, in In) (Out, error)
+}
+
+// NewWorkflow defines a typed workflow under a stable name
+func NewWorkflow[In, Out any](name string, fn func(ctx *Context, in In) (Out, error)) *Workflow[In, Out] {
+	return &Workflow[In, Out]{Name: name, Fn: fn}
+}
+
+// Register makes the workflow available to workers on e
+func (w *Workflow[In, Out]) Register(e *Engine) {
+	e.Register(w.Name, w.run)
+}
+
+// Enqueue durably records a run of the workflow for any worker that registered it
+func (w *Workflow[In, Out]) Enqueue(e *Engine, workflowID string, in In, opts ...WorkflowOption) error {
+	return e.Enqueue(workflowID, w.Name, in, opts...)
+}
+
+// Execute runs or resumes the workflow in this process and returns its output.
+// The input given on first start is stored; resumes replay the stored input.
+func (w *Workflow[In, Out]) Execute(e *Engine, workflowID string, in In, opts ...WorkflowOption) (Out, error) {
+	var zero Out
+
+	data, err := e.codec.Marshal(in)
+	if err != nil {
+		return zero, fmt.Errorf("failed to marshal workflow input: %w", err)
+	}
+	// Cap opts so the append can't write into the caller's backing array
+	opts = append(opts[:len(opts):len(opts)], withInput(w.Name, data))
+
+	if err := e.Execute(workflowID, w.run, opts...); err != nil {
+		return zero, err
+	}
+	return w.Result(e, workflowID)
+}
+
+// Result returns the output of a completed run of the workflow
+func (w *Workflow[In, Out]) Result(e *Engine, workflowID string) (Out, error) {
+	var out Out
+
+	status, err := e.storage.GetWorkflowStatus(workflowID)
+	if err != nil {
+		return out, err
+	}
+	if status != "completed" {
+		return out, fmt.Errorf("%w: %s is %s", ErrWorkflowNotCompleted, workflowID, status)
+	}
+
+	data, err := e.storage.GetWorkflowOutput(workflowID)
+	if err != nil {
+		return out, err
+	}
+	if len(data) > 0 {
+		if err := e.codec.Unmarshal(data, &out); err != nil {
+			return out, fmt.Errorf("failed to unmarshal workflow output: %w", err)
+		}
+	}
+	return out, nil
+}
+
+// run adapts the typed function to a WorkflowFunc
+func (w *Workflow[In, Out]) run(ctx *Context) error {
+	var in In
+	if err := ctx.Input(&in); err != nil {
+		return err
+	}
+
+	out, err := w.Fn(ctx, in)
+	if err != nil {
+		return err
+	}
+
+	// Stored atomically with the completed status
+	data, err := ctx.engine.codec.Marshal(out)
+	if err != nil {
+		return fmt.Errorf("failed to marshal workflow output: %w", err)
+	}
+	ctx.output = data
+	return nil
+}
+
+// withInput records the name and start input of a workflow run started with Execute
+func withInput(workflowName string, input []byte) WorkflowOption {
+	return func(o *workflowOptions) {
+		o.workflowName = workflowName
+		o.input = input
+	}
+}
+
+// SetWorkflowStart records the name and input of a workflow unless they are already set
+func (s *Storage) SetWorkflowStart(workflowID, workflowName string, input []byte) error {
+	return s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			`UPDATE workflows SET workflow_name = COALESCE(workflow_name, ?), input = COALESCE(input, ?)
+			 WHERE workflow_id = ?`,
+			workflowName, input, workflowID,
+		)
+		return err
+	})
+}
+
+// SetWorkflowOutput stores the JSON-encoded result of a workflow
+func (s *Storage) SetWorkflowOutput(workflowID string, output []byte) error {
+	return s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			"UPDATE workflows SET output = ? WHERE workflow_id = ?",
+			output, workflowID,
+		)
+		return err
+	})
+}
+
+// GetWorkflowOutput returns the stored result of a workflow, or nil if none was recorded
+func (s *Storage) GetWorkflowOutput(workflowID string) ([]byte, error) {
+	var output []byte
+	err := s.db.QueryRow(
+		"SELECT output FROM workflows WHERE workflow_id = ?",
+		workflowID,
+	).Scan(&output)
+	if err == sql.ErrNoRows {
+		return nil, ErrWorkflowNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workflow output: %w", err)
+	}
+	return output, nil
+}
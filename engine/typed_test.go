@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+type quoteRequest struct {
+	SKU      string `json:"sku"`
+	Quantity int    `json:"quantity"`
+}
+
+type quote struct {
+	Total int `json:"total"`
+}
+
+var quoteWorkflow = NewWorkflow("quote", func(ctx *Context, req quoteRequest) (quote, error) {
+	price, err := Step(ctx, "price", func() (int, error) { return 25, nil })
+	if err != nil {
+		return quote{}, err
+	}
+	return quote{Total: price * req.Quantity}, nil
+})
+
+func TestTypedWorkflowExecute(t *testing.T) {
+	dbPath := "./test_typed.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	out, err := quoteWorkflow.Execute(eng, "quote-1", quoteRequest{SKU: "A1", Quantity: 4})
+	if err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	if out.Total != 100 {
+		t.Errorf("expected total 100, got %d", out.Total)
+	}
+
+	// Re-executing replays the stored input rather than the new one
+	out, err = quoteWorkflow.Execute(eng, "quote-1", quoteRequest{SKU: "A1", Quantity: 9})
+	if err != nil {
+		t.Fatalf("re-execute failed: %v", err)
+	}
+	if out.Total != 100 {
+		t.Errorf("expected stored total 100, got %d", out.Total)
+	}
+
+	if _, err := quoteWorkflow.Result(eng, "missing"); !errors.Is(err, ErrWorkflowNotFound) {
+		t.Errorf("expected ErrWorkflowNotFound, got %v", err)
+	}
+}
+
+func TestTypedWorkflowEnqueue(t *testing.T) {
+	dbPath := "./test_typed_enqueue.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	if err := quoteWorkflow.Enqueue(eng, "quote-2", quoteRequest{SKU: "B2", Quantity: 2}); err != nil {
+		t.Fatalf("failed to enqueue: %v", err)
+	}
+	if _, err := quoteWorkflow.Result(eng, "quote-2"); !errors.Is(err, ErrWorkflowNotCompleted) {
+		t.Errorf("expected ErrWorkflowNotCompleted before running, got %v", err)
+	}
+
+	quoteWorkflow.Register(eng)
+	if err := eng.StartWorker(WorkerConfig{PollInterval: 10 * time.Millisecond}); err != nil {
+		t.Fatalf("failed to start worker: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		out, err := quoteWorkflow.Result(eng, "quote-2")
+		if err == nil {
+			if out.Total != 50 {
+				t.Errorf("expected total 50, got %d", out.Total)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("workflow did not complete: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTypedWorkflowLeavesCallerOptions(t *testing.T) {
+	dbPath := "./test_typed_opts.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	// Spare capacity the input option must not be written into
+	opts := make([]WorkflowOption, 1, 4)
+	opts[0] = WithPriority(5)
+	if _, err := quoteWorkflow.Execute(eng, "quote-opts", quoteRequest{SKU: "A1", Quantity: 1}, opts...); err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	if spare := opts[:cap(opts)]; spare[1] != nil {
+		t.Error("Execute appended into the caller's options")
+	}
+}
@@ -0,0 +1,219 @@
+package engine
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrUpdateRejected wraps the error an update handler returned
+	ErrUpdateRejected = errors.New("update rejected")
+	// ErrUpdateNotHandled is returned when a workflow finishes without handling an update
+	ErrUpdateNotHandled = errors.New("workflow finished without handling update")
+)
+
+// receivedUpdate is an update consumed by HandleUpdate, recorded as its step output
+type receivedUpdate struct {
+	ID   int64
+	Args []byte
+}
+
+// Update sends a named update to a workflow and waits until the workflow has
+// handled it with HandleUpdate and durably recorded the outcome. The
+// handler's result is decoded into result, which may be nil; an error the
+// handler returned comes back wrapped in ErrUpdateRejected.
+func (e *Engine) Update(workflowID, name string, args interface{}, result interface{}) error {
+	if _, err := e.storage.GetWorkflowStatus(workflowID); err != nil {
+		return err
+	}
+	data, err := e.codec.Marshal(args)
+	if err != nil {
+		return fmt.Errorf("failed to marshal update args: %w", err)
+	}
+	id, err := e.storage.InsertUpdate(workflowID, name, data)
+	if err != nil {
+		return err
+	}
+
+	for {
+		u, err := e.storage.GetUpdate(id)
+		if err != nil {
+			return err
+		}
+		switch u.status {
+		case "completed":
+			if result == nil || len(u.result) == 0 {
+				return nil
+			}
+			if err := e.codec.Unmarshal(u.result, result); err != nil {
+				return fmt.Errorf("failed to unmarshal update result: %w", err)
+			}
+			return nil
+		case "failed":
+			return fmt.Errorf("%w: %s", ErrUpdateRejected, u.err)
+		}
+
+		// Runs that settled can't consume the update any more
+		if status, err := e.storage.GetWorkflowStatus(workflowID); err != nil {
+			return err
+		} else if status == "completed" || status == "failed" || status == "canceled" {
+			return fmt.Errorf("%w: %s is %s", ErrUpdateNotHandled, workflowID, status)
+		}
+
+		select {
+		case <-e.stop:
+			return fmt.Errorf("engine closed while waiting for update %s", name)
+		case <-time.After(signalPollInterval):
+		}
+	}
+}
+
+// HandleUpdate blocks until an update with the given name arrives, runs
+// handler on it and records its result for the caller of Engine.Update.
+// Updates are handled oldest first, one per call. The handler runs as
+// workflow code, not as a step: on replay it runs again with the recorded
+// arguments, so it may change workflow state but should do its side effects
+// in steps. Its error is returned to the caller, not to the workflow.
+func HandleUpdate[A, R any](ctx *Context, name string, handler func(args A) (R, error)) error {
+	ctx.mu.Lock()
+	if ctx.updateWaits == nil {
+		ctx.updateWaits = make(map[string]int)
+	}
+	ctx.updateWaits[name]++
+	n := ctx.updateWaits[name]
+	ctx.mu.Unlock()
+
+	stepID := fmt.Sprintf("update:%s:%d", name, n)
+	received, err := Step(ctx, stepID, func() (receivedUpdate, error) {
+		var received receivedUpdate
+		consumedKey := fmt.Sprintf("%s/%s", ctx.WorkflowID, stepID)
+		err := ctx.awaitSignals("update "+name, func() (bool, error) {
+			u, err := ctx.storage.ConsumeUpdate(ctx.WorkflowID, name, consumedKey)
+			if err != nil || u == nil {
+				return false, err
+			}
+			received = *u
+			return true, nil
+		})
+		return received, err
+	}, withStepKind(StepKindUpdate))
+	if err != nil {
+		return err
+	}
+
+	var args A
+	var result R
+	var handlerErr error
+	if err := ctx.engine.codec.Unmarshal(received.Args, &args); err != nil {
+		handlerErr = fmt.Errorf("failed to unmarshal update args: %w", err)
+	} else {
+		result, handlerErr = handler(args)
+	}
+
+	_, err = Step(ctx, fmt.Sprintf("update-result:%s:%d", name, n), func() (bool, error) {
+		if handlerErr != nil {
+			return true, ctx.storage.FinishUpdate(received.ID, nil, handlerErr.Error())
+		}
+		data, err := ctx.engine.codec.Marshal(result)
+		if err != nil {
+			return false, fmt.Errorf("failed to marshal update result: %w", err)
+		}
+		return true, ctx.storage.FinishUpdate(received.ID, data, "")
+	})
+	return err
+}
+
+// storedUpdate is an update row as seen by its caller
+type storedUpdate struct {
+	status string
+	result []byte
+	err    string
+}
+
+// InsertUpdate records a pending update for a workflow and returns its ID
+func (s *Storage) InsertUpdate(workflowID, name string, args []byte) (int64, error) {
+	var id int64
+	err := s.retryOnBusy(func() error {
+		res, err := s.db.Exec(
+			`INSERT INTO updates (workflow_id, name, args, status, created_at) VALUES (?, ?, ?, 'pending', ?)`,
+			workflowID, name, args, dbNow(),
+		)
+		if err != nil {
+			return err
+		}
+		id, err = res.LastInsertId()
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to send update: %w", err)
+	}
+	return id, nil
+}
+
+// ConsumeUpdate claims the oldest unconsumed update for consumedKey like
+// ConsumeSignal, returning the same update again on replay and nil when none
+// is pending
+func (s *Storage) ConsumeUpdate(workflowID, name, consumedKey string) (*receivedUpdate, error) {
+	err := s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			`UPDATE updates SET consumed_key = ?
+			 WHERE id = (SELECT id FROM updates
+			             WHERE workflow_id = ? AND name = ? AND consumed_key IS NULL
+			             ORDER BY id LIMIT 1)
+			   AND NOT EXISTS (SELECT 1 FROM updates WHERE consumed_key = ?)`,
+			consumedKey, workflowID, name, consumedKey,
+		)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume update: %w", err)
+	}
+
+	var u receivedUpdate
+	err = s.db.QueryRow(
+		"SELECT id, args FROM updates WHERE consumed_key = ?", consumedKey,
+	).Scan(&u.ID, &u.Args)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load consumed update: %w", err)
+	}
+	return &u, nil
+}
+
+// FinishUpdate records the outcome of a handled update; a non-empty errMsg
+// means the handler rejected it
+func (s *Storage) FinishUpdate(id int64, result []byte, errMsg string) error {
+	status, msg := "completed", sql.NullString{}
+	if errMsg != "" {
+		status, msg = "failed", sql.NullString{String: errMsg, Valid: true}
+	}
+	err := s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			"UPDATE updates SET status = ?, result = ?, error = ?, completed_at = ? WHERE id = ?",
+			status, result, msg, dbNow(), id,
+		)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record update result: %w", err)
+	}
+	return nil
+}
+
+// GetUpdate loads an update's outcome by ID
+func (s *Storage) GetUpdate(id int64) (*storedUpdate, error) {
+	var u storedUpdate
+	var msg sql.NullString
+	err := s.db.QueryRow(
+		"SELECT status, result, error FROM updates WHERE id = ?", id,
+	).Scan(&u.status, &u.result, &msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get update: %w", err)
+	}
+	u.err = msg.String
+	return &u, nil
+}
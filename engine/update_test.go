@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestUpdateWithResult(t *testing.T) {
+	dbPath := "./test_update.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	var items []string
+	order := func(ctx *Context) error {
+		items = nil
+		for i := 0; i < 3; i++ {
+			err := HandleUpdate(ctx, "add-item", func(item string) (int, error) {
+				if item == "" {
+					return 0, errors.New("item is required")
+				}
+				items = append(items, item)
+				return len(items), nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- eng.Execute("order-1", order) }()
+	deadline := time.Now().Add(2 * time.Second)
+	for _, err := eng.GetWorkflowStatus("order-1"); err != nil; _, err = eng.GetWorkflowStatus("order-1") {
+		if time.Now().After(deadline) {
+			t.Fatal("workflow never started")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	var count int
+	if err := eng.Update("order-1", "add-item", "book", &count); err != nil || count != 1 {
+		t.Fatalf("expected 1 item after the first update, got %d: %v", count, err)
+	}
+	if err := eng.Update("order-1", "add-item", "", &count); !errors.Is(err, ErrUpdateRejected) {
+		t.Fatalf("expected the handler's rejection, got %v", err)
+	}
+	if err := eng.Update("order-1", "add-item", "pen", &count); err != nil || count != 2 {
+		t.Fatalf("expected 2 items after the third update, got %d: %v", count, err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+
+	if err := eng.Update("order-1", "add-item", "late", nil); !errors.Is(err, ErrUpdateNotHandled) {
+		t.Errorf("expected an update to a finished run to fail, got %v", err)
+	}
+	if err := eng.Update("missing", "add-item", "x", nil); !errors.Is(err, ErrWorkflowNotFound) {
+		t.Errorf("expected an unknown workflow to be rejected, got %v", err)
+	}
+}
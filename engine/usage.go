@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// UsageTagHeader is the run header usage is tagged with unless
+// UsageConfig.Tag is set, e.g. WithHeader(UsageTagHeader, "payments")
+const UsageTagHeader = "namespace"
+
+// Usage is the resource use of the steps of every run sharing a tag over a
+// reporting period
+type Usage struct {
+	Tag         string
+	From, To    time.Time
+	Steps       int64 // steps executed, including failed ones
+	FailedSteps int64
+	Attempts    int64 // executions of step functions; Attempts - Steps are retries
+	Duration    time.Duration
+	OutputBytes int64
+}
+
+// Retries returns the number of attempts beyond each step's first
+func (u *Usage) Retries() int64 {
+	if u.Attempts < u.Steps {
+		return 0
+	}
+	return u.Attempts - u.Steps
+}
+
+// UsageReporter receives the usage of executed steps aggregated per tag, for
+// example to charge workflow usage back to the teams that own it
+type UsageReporter interface {
+	ReportUsage(usage []Usage)
+}
+
+// UsageConfig configures WithUsageReporter
+type UsageConfig struct {
+	Interval time.Duration             // how often usage is reported; default 1 minute
+	Tag      func(ctx *Context) string // a run's tag; default its UsageTagHeader header
+}
+
+// usageAccount aggregates step usage per tag between reports
+type usageAccount struct {
+	reporter UsageReporter
+	cfg      UsageConfig
+
+	mu    sync.Mutex
+	from  time.Time
+	byTag map[string]*Usage
+}
+
+// WithUsageReporter aggregates the duration, output size and attempts of
+// every step the engine executes per tag, and hands them to reporter every
+// interval and when the engine closes. Steps replayed from history are not
+// counted again.
+func WithUsageReporter(reporter UsageReporter, cfg UsageConfig) EngineOption {
+	return func(e *Engine) {
+		if cfg.Interval <= 0 {
+			cfg.Interval = time.Minute
+		}
+		if cfg.Tag == nil {
+			cfg.Tag = func(ctx *Context) string { return ctx.Header(UsageTagHeader) }
+		}
+		e.usage = &usageAccount{reporter: reporter, cfg: cfg, from: time.Now(), byTag: make(map[string]*Usage)}
+		e.interceptors = append(e.interceptors, e.usage)
+	}
+}
+
+// StepFinished adds a step to its run's tag
+func (a *usageAccount) StepFinished(ctx *Context, step *FinishedStep) {
+	tag := a.cfg.Tag(ctx)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	u, ok := a.byTag[tag]
+	if !ok {
+		u = &Usage{Tag: tag}
+		a.byTag[tag] = u
+	}
+	u.Steps++
+	if step.Err != nil {
+		u.FailedSteps++
+	}
+	u.Attempts += int64(step.Attempts)
+	u.Duration += step.Duration
+	u.OutputBytes += step.OutputSize
+}
+
+// flush reports the usage aggregated since the last report, if any
+func (a *usageAccount) flush() {
+	a.mu.Lock()
+	now := time.Now()
+	usage := make([]Usage, 0, len(a.byTag))
+	for _, u := range a.byTag {
+		u.From, u.To = a.from, now
+		usage = append(usage, *u)
+	}
+	a.from = now
+	a.byTag = make(map[string]*Usage)
+	a.mu.Unlock()
+
+	if len(usage) == 0 {
+		return
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Tag < usage[j].Tag })
+	a.reporter.ReportUsage(usage)
+}
+
+// startUsageReports reports usage every interval until the engine closes,
+// then reports what is left
+func (e *Engine) startUsageReports() {
+	e.bg.Add(1)
+	go func() {
+		defer e.bg.Done()
+		defer e.usage.flush()
+		ticker := time.NewTicker(e.usage.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-e.stop:
+				return
+			case <-ticker.C:
+				e.usage.flush()
+			}
+		}
+	}()
+}
@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// usageLog collects reported usage
+type usageLog struct {
+	mu    sync.Mutex
+	usage []Usage
+}
+
+func (l *usageLog) ReportUsage(usage []Usage) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.usage = append(l.usage, usage...)
+}
+
+func TestUsageReporter(t *testing.T) {
+	dbPath := "./test_usage.db"
+	defer os.Remove(dbPath)
+
+	reported := &usageLog{}
+	engine, err := NewEngine(dbPath, WithUsageReporter(reported, UsageConfig{Interval: time.Hour}))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+
+	calls := 0
+	workflow := func(ctx *Context) error {
+		Step(ctx, "flaky", func() (string, error) {
+			calls++
+			if calls < 3 {
+				return "", errors.New("unavailable")
+			}
+			return "done", nil
+		}, WithRetry(RetryPolicy{MaxAttempts: 3, InitialInterval: time.Millisecond}))
+		_, err := StepStream(ctx, "export", func(w io.Writer) error {
+			_, err := io.Copy(w, strings.NewReader(strings.Repeat("x", 1000)))
+			return err
+		})
+		return err
+	}
+	engine.Execute("usage-1", workflow, WithHeader(UsageTagHeader, "payments"))
+	engine.Execute("usage-1", workflow, WithHeader(UsageTagHeader, "payments")) // replayed, not counted
+	engine.Execute("usage-2", func(ctx *Context) error {
+		_, err := Step(ctx, "fail", func() (int, error) { return 0, errors.New("boom") })
+		return err
+	})
+	engine.Close()
+
+	if len(reported.usage) != 2 {
+		t.Fatalf("expected usage for two tags on close, got %+v", reported.usage)
+	}
+	untagged, payments := reported.usage[0], reported.usage[1]
+	if untagged.Tag != "" || untagged.Steps != 1 || untagged.FailedSteps != 1 || untagged.Attempts != 1 {
+		t.Errorf("unexpected untagged usage %+v", untagged)
+	}
+	if payments.Tag != "payments" || payments.Steps != 2 || payments.FailedSteps != 0 ||
+		payments.Attempts != 4 || payments.Retries() != 2 {
+		t.Errorf("unexpected payments usage %+v", payments)
+	}
+	if payments.OutputBytes < 1000 || payments.Duration <= 0 || !payments.To.After(payments.From) {
+		t.Errorf("expected output size, duration and period, got %+v", payments)
+	}
+}
@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestConcurrentStatusChangeIsNotOverwritten(t *testing.T) {
+	dbPath := "./test_version.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	// Another process settles the workflow as failed while this one is still running it
+	err = eng.Execute("occ-1", func(ctx *Context) error {
+		if _, err := Step(ctx, "work", func() (int, error) { return 1, nil }); err != nil {
+			return err
+		}
+		return eng.storage.UpdateWorkflowStatus("occ-1", "failed")
+	})
+	if !errors.Is(err, ErrStatusConflict) {
+		t.Fatalf("expected ErrStatusConflict, got %v", err)
+	}
+
+	status, version, err := eng.storage.GetWorkflowVersion("occ-1")
+	if err != nil {
+		t.Fatalf("failed to get version: %v", err)
+	}
+	if status != "failed" || version != 1 {
+		t.Errorf("expected failed at version 1, got %s at version %d", status, version)
+	}
+
+	// A later run moves it back to running (version 2) and may settle it
+	if err := eng.Execute("occ-1", func(ctx *Context) error { return nil }); err != nil {
+		t.Fatalf("expected rerun to complete, got %v", err)
+	}
+	status, version, _ = eng.storage.GetWorkflowVersion("occ-1")
+	if status != "completed" || version != 3 {
+		t.Errorf("expected completed at version 3, got %s at version %d", status, version)
+	}
+}
+
+func TestCompareAndSetWorkflowStatus(t *testing.T) {
+	dbPath := "./test_version_cas.db"
+	defer os.Remove(dbPath)
+
+	s, err := NewStorage(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer s.Close()
+
+	s.CreateWorkflow("cas-1", 0)
+	if err := s.CompareAndSetWorkflowStatus("cas-1", "completed", 0); err != nil {
+		t.Fatalf("first update failed: %v", err)
+	}
+	if err := s.CompareAndSetWorkflowStatus("cas-1", "failed", 0); !errors.Is(err, ErrStatusConflict) {
+		t.Errorf("expected stale update to conflict, got %v", err)
+	}
+	if status, _ := s.GetWorkflowStatus("cas-1"); status != "completed" {
+		t.Errorf("expected completed to win, got %s", status)
+	}
+}
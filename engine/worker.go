@@ -0,0 +1,214 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultHeartbeatInterval is used when WorkerConfig.HeartbeatInterval is zero
+const DefaultHeartbeatInterval = 5 * time.Second
+
+// missedHeartbeats is how many intervals may pass before a worker is considered dead
+const missedHeartbeats = 3
+
+// WorkerConfig describes the worker process registering with the engine
+type WorkerConfig struct {
+	Version           string
+	Queues            []string
+	Labels            []string // capabilities such as "has-gpu" or "region=eu", matched by WithRequires
+	Capacity          int
+	HeartbeatInterval time.Duration
+	PollInterval      time.Duration
+	PriorityAging     time.Duration // queued time per level of priority gained; default DefaultPriorityAging, negative disables aging
+}
+
+// WorkerInfo is a snapshot of a registered worker as seen in storage
+type WorkerInfo struct {
+	WorkerID          string
+	Hostname          string
+	PID               int
+	Version           string
+	Queues            []string
+	Labels            []string
+	Capacity          int
+	Status            string // 'active' or 'stopped'
+	HeartbeatInterval time.Duration
+	StartedAt         time.Time
+	LastHeartbeat     time.Time
+}
+
+// Alive reports whether the worker is active and has heartbeated recently
+func (w WorkerInfo) Alive(now time.Time) bool {
+	if w.Status != "active" {
+		return false
+	}
+	return now.Sub(w.LastHeartbeat) <= missedHeartbeats*w.HeartbeatInterval
+}
+
+// Executor identifies the process that executed a step: the engine's worker
+// ID and the host and process it ran in, recorded with each step and attempt
+type Executor struct {
+	WorkerID string
+	Hostname string
+	PID      int
+}
+
+// String formats the executor as "worker (host, pid N)"
+func (x Executor) String() string {
+	if x.Hostname == "" {
+		return x.WorkerID
+	}
+	return fmt.Sprintf("%s (%s, pid %d)", x.WorkerID, x.Hostname, x.PID)
+}
+
+// localHostname returns this machine's hostname, or "unknown"
+func localHostname() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return hostname
+}
+
+// defaultWorkerID builds a worker identity from the hostname and process ID
+func defaultWorkerID() string {
+	return fmt.Sprintf("%s-%d", localHostname(), os.Getpid())
+}
+
+// StartWorker registers this engine as a worker, heartbeats, and runs
+// queued workflows for registered names until Close
+func (e *Engine) StartWorker(cfg WorkerConfig) error {
+	if cfg.HeartbeatInterval <= 0 {
+		cfg.HeartbeatInterval = DefaultHeartbeatInterval
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = DefaultPollInterval
+	}
+
+	e.workerMu.Lock()
+	defer e.workerMu.Unlock()
+	if e.worker != nil {
+		return fmt.Errorf("worker %s already started", e.workerID)
+	}
+
+	info := WorkerInfo{
+		WorkerID:          e.workerID,
+		Hostname:          e.executor.Hostname,
+		PID:               e.executor.PID,
+		Version:           cfg.Version,
+		Queues:            cfg.Queues,
+		Labels:            cfg.Labels,
+		Capacity:          cfg.Capacity,
+		HeartbeatInterval: cfg.HeartbeatInterval,
+	}
+	if err := e.storage.RegisterWorker(info); err != nil {
+		return fmt.Errorf("failed to register worker: %w", err)
+	}
+	e.worker = &cfg
+
+	e.bg.Add(1)
+	go func() {
+		defer e.bg.Done()
+		ticker := time.NewTicker(cfg.HeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-e.stop:
+				return
+			case <-ticker.C:
+				if err := e.storage.HeartbeatWorker(e.workerID); err != nil {
+					fmt.Printf("[WORKER] heartbeat failed for %s: %v\n", e.workerID, err)
+				}
+			}
+		}
+	}()
+
+	// Pick up queued workflows for registered names
+	e.bg.Add(1)
+	go e.dispatch(cfg)
+
+	return nil
+}
+
+// ListWorkers returns every worker that has registered with this database
+func (e *Engine) ListWorkers() ([]WorkerInfo, error) {
+	return e.storage.ListWorkers()
+}
+
+// RegisterWorker inserts or refreshes a worker registration
+func (s *Storage) RegisterWorker(w WorkerInfo) error {
+	now := time.Now().UTC()
+	return s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			`INSERT INTO workers (worker_id, hostname, pid, version, queues, labels, capacity, status,
+			                      heartbeat_interval_ms, started_at, last_heartbeat)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, 'active', ?, ?, ?)
+			 ON CONFLICT(worker_id) DO UPDATE SET
+			   hostname = excluded.hostname, pid = excluded.pid, version = excluded.version,
+			   queues = excluded.queues, labels = excluded.labels, capacity = excluded.capacity, status = 'active',
+			   heartbeat_interval_ms = excluded.heartbeat_interval_ms,
+			   started_at = excluded.started_at, last_heartbeat = excluded.last_heartbeat`,
+			w.WorkerID, w.Hostname, w.PID, w.Version, strings.Join(w.Queues, ","), strings.Join(w.Labels, ","), w.Capacity,
+			w.HeartbeatInterval.Milliseconds(), now, now,
+		)
+		return err
+	})
+}
+
+// HeartbeatWorker records that a worker is still alive
+func (s *Storage) HeartbeatWorker(workerID string) error {
+	return s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			"UPDATE workers SET last_heartbeat = ?, status = 'active' WHERE worker_id = ?",
+			time.Now().UTC(), workerID,
+		)
+		return err
+	})
+}
+
+// MarkWorkerStopped records a clean worker shutdown
+func (s *Storage) MarkWorkerStopped(workerID string) error {
+	return s.retryOnBusy(func() error {
+		_, err := s.db.Exec(
+			"UPDATE workers SET status = 'stopped' WHERE worker_id = ?",
+			workerID,
+		)
+		return err
+	})
+}
+
+// ListWorkers loads all worker registrations ordered by worker ID
+func (s *Storage) ListWorkers() ([]WorkerInfo, error) {
+	rows, err := s.db.Query(
+		`SELECT worker_id, hostname, pid, version, queues, labels, capacity, status,
+		        heartbeat_interval_ms, started_at, last_heartbeat
+		 FROM workers ORDER BY worker_id`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workers: %w", err)
+	}
+	defer rows.Close()
+
+	var workers []WorkerInfo
+	for rows.Next() {
+		var w WorkerInfo
+		var queues, labels string
+		var intervalMs int64
+		if err := rows.Scan(&w.WorkerID, &w.Hostname, &w.PID, &w.Version, &queues, &labels, &w.Capacity,
+			&w.Status, &intervalMs, &w.StartedAt, &w.LastHeartbeat); err != nil {
+			return nil, fmt.Errorf("failed to scan worker: %w", err)
+		}
+		if queues != "" {
+			w.Queues = strings.Split(queues, ",")
+		}
+		if labels != "" {
+			w.Labels = strings.Split(labels, ",")
+		}
+		w.HeartbeatInterval = time.Duration(intervalMs) * time.Millisecond
+		workers = append(workers, w)
+	}
+
+	return workers, rows.Err()
+}
@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWorkerRegistrationAndHeartbeat(t *testing.T) {
+	dbPath := "./test_workers.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath, WithWorkerID("worker-a"))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+
+	err = eng.StartWorker(WorkerConfig{
+		Version:           "v1.2.3",
+		Queues:            []string{"default", "payments"},
+		Capacity:          8,
+		HeartbeatInterval: 20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("failed to start worker: %v", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	workers, err := eng.ListWorkers()
+	if err != nil {
+		t.Fatalf("failed to list workers: %v", err)
+	}
+	if len(workers) != 1 {
+		t.Fatalf("expected 1 worker, got %d", len(workers))
+	}
+
+	w := workers[0]
+	if w.WorkerID != "worker-a" || w.Version != "v1.2.3" || w.Capacity != 8 {
+		t.Errorf("unexpected worker info: %+v", w)
+	}
+	if len(w.Queues) != 2 || w.Queues[1] != "payments" {
+		t.Errorf("expected queues [default payments], got %v", w.Queues)
+	}
+	if !w.Alive(time.Now()) {
+		t.Errorf("expected worker to be alive, last heartbeat %v", w.LastHeartbeat)
+	}
+	if w.Alive(time.Now().Add(time.Second)) {
+		t.Errorf("expected worker to be considered dead after missed heartbeats")
+	}
+
+	if err := eng.Close(); err != nil {
+		t.Fatalf("failed to close engine: %v", err)
+	}
+
+	// A fresh engine on the same database sees the stopped worker
+	eng2, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to reopen engine: %v", err)
+	}
+	defer eng2.Close()
+
+	workers, err = eng2.ListWorkers()
+	if err != nil {
+		t.Fatalf("failed to list workers: %v", err)
+	}
+	if len(workers) != 1 || workers[0].Status != "stopped" || workers[0].Alive(time.Now()) {
+		t.Errorf("expected a single stopped worker, got %+v", workers)
+	}
+}
+
+func TestStepsRecordExecutor(t *testing.T) {
+	dbPath := "./test_executor.db"
+	defer os.Remove(dbPath)
+
+	eng, err := NewEngine(dbPath, WithWorkerID("worker-b"))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	err = eng.Execute("wf-executor", func(ctx *Context) error {
+		if _, err := Step(ctx, "remote", func() (int, error) { return 1, nil }); err != nil {
+			return err
+		}
+		_, err := LocalStep(ctx, "local", func() (int, error) { return 2, nil })
+		return err
+	})
+	if err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+
+	hostname, _ := os.Hostname()
+	want := Executor{WorkerID: "worker-b", Hostname: hostname, PID: os.Getpid()}
+	history, err := eng.GetHistory("wf-executor")
+	if err != nil || len(history) != 2 {
+		t.Fatalf("expected 2 steps, got %d (%v)", len(history), err)
+	}
+	for _, step := range history {
+		if step.Executor() != want {
+			t.Errorf("expected %s to record %v, got %v", step.StepID, want, step.Executor())
+		}
+	}
+	if attempts := history[0].Attempts; len(attempts) != 1 || attempts[0].Executor() != want {
+		t.Errorf("expected the attempt to record %v, got %+v", want, attempts)
+	}
+}
@@ -0,0 +1,151 @@
+package engine
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WorkflowInfo describes one workflow run
+type WorkflowInfo struct {
+	WorkflowID string
+	Name       string // registered name; empty for workflows started with Execute
+	Status     string
+	Queue      string
+	ParentID   string
+	Output     []byte // encoded result, set once completed
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// WorkflowFilter selects runs for ListWorkflows; empty fields match every run
+type WorkflowFilter struct {
+	Names     []string
+	ParentIDs []string
+	Status    string
+	Limit     int // newest runs first; applies per name when Names is set
+
+	SearchAttributes map[string]string // runs carrying every one of these attributes
+}
+
+// GetWorkflow returns a workflow run, or ErrWorkflowNotFound
+func (e *Engine) GetWorkflow(workflowID string) (*WorkflowInfo, error) {
+	runs, err := e.storage.ListWorkflows(WorkflowFilter{}, workflowID)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrWorkflowNotFound, workflowID)
+	}
+	return &runs[0], nil
+}
+
+// ListWorkflows returns the runs matching filter, newest first
+func (e *Engine) ListWorkflows(filter WorkflowFilter) ([]WorkflowInfo, error) {
+	return e.reads.ListWorkflows(filter)
+}
+
+// ListWorkflowNames returns every registered name workflows have been started under
+func (e *Engine) ListWorkflowNames() ([]string, error) {
+	return e.reads.ListWorkflowNames()
+}
+
+// GetHistories returns the steps of several workflows, loaded together
+func (e *Engine) GetHistories(workflowIDs []string) (map[string][]StepRecord, error) {
+	return e.reads.ListStepsForWorkflows(workflowIDs)
+}
+
+// ListWorkflows loads runs matching filter, or the listed runs if any IDs are given
+func (s *Storage) ListWorkflows(filter WorkflowFilter, workflowIDs ...string) ([]WorkflowInfo, error) {
+	var where []string
+	var args []interface{}
+	in := func(column string, values []string) {
+		where = append(where, fmt.Sprintf("%s IN (%s)", column, placeholders(len(values))))
+		for _, v := range values {
+			args = append(args, v)
+		}
+	}
+	if len(workflowIDs) > 0 {
+		in("workflow_id", workflowIDs)
+	}
+	if len(filter.Names) > 0 {
+		in("workflow_name", filter.Names)
+	}
+	if len(filter.ParentIDs) > 0 {
+		in("parent_id", filter.ParentIDs)
+	}
+	if filter.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if len(filter.SearchAttributes) > 0 {
+		conds, condArgs := searchAttributeConditions(filter.SearchAttributes)
+		where = append(where, conds...)
+		args = append(args, condArgs...)
+	}
+	cond := ""
+	if len(where) > 0 {
+		cond = "WHERE " + strings.Join(where, " AND ")
+	}
+
+	// Number runs per name so a limit can apply to each name in one query
+	partition := "NULL"
+	if len(filter.Names) > 0 {
+		partition = "workflow_name"
+	}
+	query := fmt.Sprintf(
+		`SELECT workflow_id, COALESCE(workflow_name, ''), status, queue, COALESCE(parent_id, ''), output,
+		   created_at, updated_at,
+		   ROW_NUMBER() OVER (PARTITION BY %s ORDER BY created_at DESC, workflow_id DESC) AS n
+		 FROM workflows %s`,
+		partition, cond,
+	)
+	if filter.Limit > 0 {
+		query = "SELECT * FROM (" + query + ") WHERE n <= ?"
+		args = append(args, filter.Limit)
+	}
+	query += " ORDER BY created_at DESC, workflow_id DESC"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workflows: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []WorkflowInfo
+	for rows.Next() {
+		var w WorkflowInfo
+		var createdAt, updatedAt sql.NullTime
+		var n int
+		if err := rows.Scan(&w.WorkflowID, &w.Name, &w.Status, &w.Queue, &w.ParentID, &w.Output,
+			&createdAt, &updatedAt, &n); err != nil {
+			return nil, fmt.Errorf("failed to scan workflow: %w", err)
+		}
+		w.CreatedAt = createdAt.Time
+		w.UpdatedAt = updatedAt.Time
+		runs = append(runs, w)
+	}
+	return runs, rows.Err()
+}
+
+// ListWorkflowNames returns the distinct registered names of stored workflows
+func (s *Storage) ListWorkflowNames() ([]string, error) {
+	rows, err := s.db.Query(
+		"SELECT DISTINCT workflow_name FROM workflows WHERE workflow_name IS NOT NULL ORDER BY workflow_name",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workflow names: %w", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan workflow name: %w", err)
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
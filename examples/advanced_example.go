@@ -33,34 +33,17 @@ func DataProcessingPipeline(ctx *engine.Context, dataFiles []string) error {
 		Status   string
 	}
 
-	results := make([]FileResult, 0, len(dataFiles))
-
-	for i, file := range dataFiles {
-		// Capture loop variables
-		index := i
-		filename := file
-
-		ctx.Go(func() error {
-			// Each file gets a unique step ID
-			result, err := engine.Step(ctx, fmt.Sprintf("process-file-%d", index), func() (FileResult, error) {
-				fmt.Printf("Processing file: %s...\n", filename)
-				time.Sleep(1 * time.Second) // Simulate processing
-				return FileResult{
-					Filename: filename,
-					Records:  100 + index*10,
-					Status:   "completed",
-				}, nil
-			})
-			if err != nil {
-				return err
-			}
-			results = append(results, result)
-			return nil
-		})
-	}
-
-	// Wait for all files to be processed
-	if err := ctx.Wait(); err != nil {
+	// Results come back in file order, however the steps finish
+	results, err := engine.Map(ctx, "process-file", dataFiles, func(index int, filename string) (FileResult, error) {
+		fmt.Printf("Processing file: %s...\n", filename)
+		time.Sleep(1 * time.Second) // Simulate processing
+		return FileResult{
+			Filename: filename,
+			Records:  100 + index*10,
+			Status:   "completed",
+		}, nil
+	})
+	if err != nil {
 		return fmt.Errorf("file processing failed: %w", err)
 	}
 
@@ -0,0 +1,102 @@
+package grpcapi
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/yourusername/durable-execution-engine/client"
+)
+
+// Client calls the WorkflowEvents service over a gRPC connection. It is
+// safe for concurrent use.
+type Client struct {
+	cc    grpc.ClientConnInterface
+	token string
+}
+
+// ClientOption configures a Client
+type ClientOption func(*Client)
+
+// WithToken sends token with every call, for servers using WithBearerToken
+func WithToken(token string) ClientOption {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// NewClient returns a client using cc, e.g. from grpc.NewClient
+func NewClient(cc grpc.ClientConnInterface, opts ...ClientOption) *Client {
+	c := &Client{cc: cc}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// EventStream receives events pushed by the server
+type EventStream struct {
+	stream grpc.ClientStream
+	cancel context.CancelFunc
+}
+
+// Recv returns the next event. It returns io.EOF once a watched workflow has
+// finished, or the server's status error.
+func (s *EventStream) Recv() (*client.Event, error) {
+	m := dynamicpb.NewMessage(eventDesc)
+	if err := s.stream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return decodeEvent(m), nil
+}
+
+// Close ends the stream
+func (s *EventStream) Close() error {
+	s.cancel()
+	return nil
+}
+
+// WatchWorkflow streams status changes of a workflow and its steps after
+// event ID after (0 for its whole history, or client.EventsFromNow). The
+// stream ends after the workflow completes, fails or is canceled.
+func (c *Client) WatchWorkflow(ctx context.Context, workflowID string, after int64) (*EventStream, error) {
+	req := dynamicpb.NewMessage(watchWorkflowRequestDesc)
+	set(req, "workflow_id", protoreflect.ValueOfString(workflowID))
+	set(req, "after_id", protoreflect.ValueOfInt64(after))
+	return c.watch(ctx, 0, req)
+}
+
+// WatchAll streams status changes of every workflow after event ID after
+// (or client.EventsFromNow) until ctx is done. Resume a broken stream by
+// passing the ID of the last event received.
+func (c *Client) WatchAll(ctx context.Context, after int64) (*EventStream, error) {
+	req := dynamicpb.NewMessage(watchAllRequestDesc)
+	set(req, "after_id", protoreflect.ValueOfInt64(after))
+	return c.watch(ctx, 1, req)
+}
+
+// watch opens the server stream of serviceDesc.Streams[i] and sends req
+func (c *Client) watch(ctx context.Context, i int, req *dynamicpb.Message) (*EventStream, error) {
+	if c.token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+c.token)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	desc := &serviceDesc.Streams[i]
+	stream, err := c.cc.NewStream(ctx, desc, "/"+ServiceName+"/"+desc.StreamName)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err := stream.SendMsg(req); err != nil {
+		cancel()
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		cancel()
+		return nil, err
+	}
+	return &EventStream{stream: stream, cancel: cancel}, nil
+}
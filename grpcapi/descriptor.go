@@ -0,0 +1,146 @@
+package grpcapi
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/yourusername/durable-execution-engine/client"
+)
+
+// ServiceName is the full name of the WorkflowEvents service in events.proto
+const ServiceName = "durable.v1.WorkflowEvents"
+
+// Message descriptors of events.proto. The file is described here rather
+// than compiled with protoc, and messages are encoded with dynamicpb; the
+// wire format is the same, so clients generated from events.proto interoperate.
+var (
+	watchWorkflowRequestDesc protoreflect.MessageDescriptor
+	watchAllRequestDesc      protoreflect.MessageDescriptor
+	eventDesc                protoreflect.MessageDescriptor
+)
+
+func init() {
+	scalar := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:   typ.Enum(),
+		}
+	}
+	str := descriptorpb.FieldDescriptorProto_TYPE_STRING
+	i64 := descriptorpb.FieldDescriptorProto_TYPE_INT64
+
+	timeField := scalar("time", 8, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE)
+	timeField.TypeName = proto.String(".google.protobuf.Timestamp")
+
+	stream := func(name, input string) *descriptorpb.MethodDescriptorProto {
+		return &descriptorpb.MethodDescriptorProto{
+			Name:            proto.String(name),
+			InputType:       proto.String(".durable.v1." + input),
+			OutputType:      proto.String(".durable.v1.Event"),
+			ServerStreaming: proto.Bool(true),
+		}
+	}
+
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("grpcapi/events.proto"),
+		Package:    proto.String("durable.v1"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{timestamppb.File_google_protobuf_timestamp_proto.Path()},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name:  proto.String("WatchWorkflowRequest"),
+				Field: []*descriptorpb.FieldDescriptorProto{scalar("workflow_id", 1, str), scalar("after_id", 2, i64)},
+			},
+			{
+				Name:  proto.String("WatchAllRequest"),
+				Field: []*descriptorpb.FieldDescriptorProto{scalar("after_id", 1, i64)},
+			},
+			{
+				Name: proto.String("Event"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					scalar("id", 1, i64),
+					scalar("workflow_id", 2, str),
+					scalar("step_id", 3, str),
+					scalar("step_key", 4, str),
+					scalar("status", 5, str),
+					scalar("error", 6, str),
+					scalar("message", 7, str),
+					timeField,
+				},
+			},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("WorkflowEvents"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				stream("WatchWorkflow", "WatchWorkflowRequest"),
+				stream("WatchAll", "WatchAllRequest"),
+			},
+		}},
+	}, protoregistry.GlobalFiles)
+	if err != nil {
+		panic(fmt.Sprintf("grpcapi: invalid events.proto descriptor: %v", err))
+	}
+
+	messages := fd.Messages()
+	watchWorkflowRequestDesc = messages.ByName("WatchWorkflowRequest")
+	watchAllRequestDesc = messages.ByName("WatchAllRequest")
+	eventDesc = messages.ByName("Event")
+}
+
+// getString reads a string field of a dynamic message
+func getString(m *dynamicpb.Message, name protoreflect.Name) string {
+	return m.Get(m.Descriptor().Fields().ByName(name)).String()
+}
+
+// getInt reads an int64 field of a dynamic message
+func getInt(m *dynamicpb.Message, name protoreflect.Name) int64 {
+	return m.Get(m.Descriptor().Fields().ByName(name)).Int()
+}
+
+// set writes a field of a dynamic message
+func set(m *dynamicpb.Message, name protoreflect.Name, v protoreflect.Value) {
+	m.Set(m.Descriptor().Fields().ByName(name), v)
+}
+
+// encodeEvent converts an event to an Event message
+func encodeEvent(ev client.Event) *dynamicpb.Message {
+	m := dynamicpb.NewMessage(eventDesc)
+	set(m, "id", protoreflect.ValueOfInt64(ev.ID))
+	set(m, "workflow_id", protoreflect.ValueOfString(ev.WorkflowID))
+	set(m, "step_id", protoreflect.ValueOfString(ev.StepID))
+	set(m, "step_key", protoreflect.ValueOfString(ev.StepKey))
+	set(m, "status", protoreflect.ValueOfString(ev.Status))
+	set(m, "error", protoreflect.ValueOfString(ev.Error))
+	set(m, "message", protoreflect.ValueOfString(ev.Message))
+	set(m, "time", protoreflect.ValueOfMessage(timestamppb.New(ev.Time).ProtoReflect()))
+	return m
+}
+
+// decodeEvent converts an Event message back to an event
+func decodeEvent(m *dynamicpb.Message) *client.Event {
+	ev := &client.Event{
+		ID:         getInt(m, "id"),
+		WorkflowID: getString(m, "workflow_id"),
+		StepID:     getString(m, "step_id"),
+		StepKey:    getString(m, "step_key"),
+		Status:     getString(m, "status"),
+		Error:      getString(m, "error"),
+		Message:    getString(m, "message"),
+	}
+	fd := eventDesc.Fields().ByName("time")
+	if m.Has(fd) {
+		ts := &timestamppb.Timestamp{}
+		proto.Merge(ts, m.Get(fd).Message().Interface())
+		ev.Time = ts.AsTime()
+	}
+	return ev
+}
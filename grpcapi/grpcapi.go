@@ -0,0 +1,161 @@
+// Package grpcapi serves the engine's workflow event feed as the gRPC
+// service in events.proto: WatchWorkflow and WatchAll push step and status
+// events to subscribers as they are recorded, for reactive UIs and log
+// pipelines that shouldn't poll. The same feed is served over HTTP by the
+// server package.
+package grpcapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/yourusername/durable-execution-engine/client"
+	"github.com/yourusername/durable-execution-engine/engine"
+)
+
+// Option configures NewServer
+type Option func(*service)
+
+// WithBearerToken makes every call carry "authorization: Bearer <token>"
+// metadata; others fail with Unauthenticated
+func WithBearerToken(token string) Option {
+	return func(s *service) {
+		s.token = token
+	}
+}
+
+// WithServerOptions passes options such as TLS credentials to grpc.NewServer
+func WithServerOptions(opts ...grpc.ServerOption) Option {
+	return func(s *service) {
+		s.serverOpts = append(s.serverOpts, opts...)
+	}
+}
+
+// NewServer returns a gRPC server with the WorkflowEvents service backed by
+// eng registered. Anyone who can reach it may watch every workflow unless
+// WithBearerToken is given.
+func NewServer(eng *engine.Engine, opts ...Option) *grpc.Server {
+	s := &service{eng: eng}
+	for _, opt := range opts {
+		opt(s)
+	}
+	serverOpts := s.serverOpts
+	if s.token != "" {
+		serverOpts = append(serverOpts[:len(serverOpts):len(serverOpts)], grpc.ChainStreamInterceptor(s.authenticate))
+	}
+	srv := grpc.NewServer(serverOpts...)
+	srv.RegisterService(&serviceDesc, s)
+	return srv
+}
+
+// Register adds the WorkflowEvents service to an existing gRPC server, which
+// is responsible for authenticating its calls
+func Register(srv *grpc.Server, eng *engine.Engine) {
+	srv.RegisterService(&serviceDesc, &service{eng: eng})
+}
+
+// eventsServer is implemented by service; grpc checks handlers against it
+type eventsServer interface {
+	watch(ctx context.Context, workflowID string, after int64, stream grpc.ServerStream) error
+}
+
+type service struct {
+	eng        *engine.Engine
+	token      string
+	serverOpts []grpc.ServerOption
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*eventsServer)(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchWorkflow",
+			ServerStreams: true,
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				req := dynamicpb.NewMessage(watchWorkflowRequestDesc)
+				if err := stream.RecvMsg(req); err != nil {
+					return err
+				}
+				workflowID := getString(req, "workflow_id")
+				if workflowID == "" {
+					return status.Error(codes.InvalidArgument, "workflow_id is required")
+				}
+				return srv.(eventsServer).watch(stream.Context(), workflowID, getInt(req, "after_id"), stream)
+			},
+		},
+		{
+			StreamName:    "WatchAll",
+			ServerStreams: true,
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				req := dynamicpb.NewMessage(watchAllRequestDesc)
+				if err := stream.RecvMsg(req); err != nil {
+					return err
+				}
+				return srv.(eventsServer).watch(stream.Context(), "", getInt(req, "after_id"), stream)
+			},
+		},
+	},
+	Metadata: "grpcapi/events.proto",
+}
+
+// watch sends events until the client goes away, the engine closes or, for
+// one workflow, the workflow finishes
+func (s *service) watch(ctx context.Context, workflowID string, after int64, stream grpc.ServerStream) error {
+	if workflowID != "" {
+		if _, err := s.eng.GetWorkflowStatus(workflowID); err != nil {
+			return statusError(err)
+		}
+	}
+	if after < engine.EventsFromNow {
+		return status.Errorf(codes.InvalidArgument, "bad after_id %d", after)
+	}
+
+	events, err := s.eng.WatchEvents(ctx, workflowID, after)
+	if err != nil {
+		return statusError(err)
+	}
+	for ev := range events {
+		if err := stream.SendMsg(encodeEvent(client.Event{
+			ID:         ev.ID,
+			WorkflowID: ev.WorkflowID,
+			StepID:     ev.StepID,
+			StepKey:    ev.StepKey,
+			Status:     ev.Status,
+			Error:      ev.Error,
+			Message:    ev.Message,
+			Time:       ev.Time,
+		})); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// authenticate rejects calls without the configured bearer token
+func (s *service) authenticate(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	for _, v := range md.Get("authorization") {
+		got, ok := strings.CutPrefix(v, "Bearer ")
+		if ok && subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) == 1 {
+			return handler(srv, stream)
+		}
+	}
+	return status.Error(codes.Unauthenticated, "unauthorized")
+}
+
+// statusError maps engine errors to gRPC status codes
+func statusError(err error) error {
+	if errors.Is(err, engine.ErrWorkflowNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,157 @@
+package grpcapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/yourusername/durable-execution-engine/client"
+	"github.com/yourusername/durable-execution-engine/engine"
+)
+
+// serve starts srv on a local port and returns a connection to it
+func serve(t *testing.T, srv *grpc.Server) *grpc.ClientConn {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestWatchOverGRPC(t *testing.T) {
+	dbPath := "./test_grpc_watch.db"
+	defer os.Remove(dbPath)
+
+	eng, err := engine.NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	eng.Register("ship", func(ctx *engine.Context) error {
+		if _, err := engine.WaitForSignal[string](ctx, "go"); err != nil {
+			return err
+		}
+		_, err := engine.Step(ctx, "label", func() (string, error) { return "ok", nil })
+		return err
+	})
+
+	c := NewClient(serve(t, NewServer(eng)))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The database is empty, so the whole history is what happens from here
+	all, err := c.WatchAll(ctx, 0)
+	if err != nil {
+		t.Fatalf("failed to watch all: %v", err)
+	}
+	defer all.Close()
+
+	if err := eng.Enqueue("ship-1", "ship", nil); err != nil {
+		t.Fatalf("failed to enqueue: %v", err)
+	}
+	first, err := all.Recv()
+	if err != nil || first.WorkflowID != "ship-1" || first.Status != "queued" || first.Time.IsZero() {
+		t.Fatalf("expected queued event, got %+v: %v", first, err)
+	}
+
+	missing, err := c.WatchWorkflow(ctx, "missing", 0)
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+	if _, err := missing.Recv(); status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound, got %v", err)
+	}
+
+	stream, err := c.WatchWorkflow(ctx, "ship-1", 0)
+	if err != nil {
+		t.Fatalf("failed to watch workflow: %v", err)
+	}
+	defer stream.Close()
+
+	if err := eng.StartWorker(engine.WorkerConfig{PollInterval: 10 * time.Millisecond}); err != nil {
+		t.Fatalf("failed to start worker: %v", err)
+	}
+	eng.Signal("ship-1", "go", "now")
+
+	var got []string
+	for {
+		ev, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("stream failed: %v", err)
+		}
+		if ev.StepID == "label" || ev.StepID == "" {
+			got = append(got, ev.StepID+":"+ev.Status)
+		}
+	}
+	want := []string{":queued", ":running", "label:in_progress", "label:completed", ":completed"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestGRPCBearerToken(t *testing.T) {
+	dbPath := "./test_grpc_auth.db"
+	defer os.Remove(dbPath)
+
+	eng, err := engine.NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+	if err := eng.Execute("audit-1", func(ctx *engine.Context) error { return nil }); err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+
+	conn := serve(t, NewServer(eng, WithBearerToken("s3cret")))
+	ctx := context.Background()
+
+	stream, err := NewClient(conn).WatchWorkflow(ctx, "audit-1", 0)
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected Unauthenticated without a token, got %v", err)
+	}
+
+	stream, err = NewClient(conn, WithToken("s3cret")).WatchWorkflow(ctx, "audit-1", 0)
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+	defer stream.Close()
+	var last *client.Event
+	for {
+		ev, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("stream failed: %v", err)
+		}
+		last = ev
+	}
+	if last == nil || last.Status != "completed" {
+		t.Errorf("expected the history to end with completed, got %+v", last)
+	}
+}
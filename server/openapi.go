@@ -0,0 +1,255 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/durable-execution-engine/client"
+)
+
+// APIVersion is the version of the /v1 API reported in the OpenAPI document
+const APIVersion = "1.0.0"
+
+// route is one operation of the /v1 API, served by NewHandler and described
+// in the OpenAPI document
+type route struct {
+	method, path string
+	handle       func(s *server, w http.ResponseWriter, r *http.Request)
+	id, summary  string
+	params       []param
+	body         interface{} // request body, if any
+	status       int         // success status
+	response     interface{} // success body; nil for none
+	stream       bool        // the response is newline-delimited JSON of response
+}
+
+// param is a path or query parameter of a route
+type param struct {
+	name, in, typ, description string
+	array                      bool // a query parameter that may repeat
+}
+
+// routes is the /v1 API
+var routes = []route{
+	{method: "GET", path: "/v1/health", handle: (*server).health,
+		id: "health", summary: "Report that the server is up and which worker serves it",
+		status: http.StatusOK, response: map[string]string{}},
+	{method: "POST", path: "/v1/workflows", handle: (*server).enqueue,
+		id: "enqueueWorkflow", summary: "Enqueue a registered workflow for the workers to run",
+		body: client.StartRequest{}, status: http.StatusAccepted},
+	{method: "GET", path: "/v1/workflows", handle: (*server).list,
+		id: "listWorkflows", summary: "List workflow runs, newest first",
+		params: []param{
+			{name: "name", in: "query", typ: "string", array: true, description: "registered names to match"},
+			{name: "parent_id", in: "query", typ: "string", array: true, description: "parent runs to match"},
+			{name: "status", in: "query", typ: "string", description: "status to match"},
+			{name: "limit", in: "query", typ: "integer", description: "maximum runs, per name when name is given"},
+		},
+		status: http.StatusOK, response: []client.WorkflowInfo{}},
+	{method: "GET", path: "/v1/workflows/{id}", handle: (*server).get,
+		id: "getWorkflow", summary: "Get a workflow run, with its output once completed",
+		params: []param{idParam}, status: http.StatusOK, response: client.WorkflowInfo{}},
+	{method: "GET", path: "/v1/workflows/{id}/history", handle: (*server).history,
+		id: "getWorkflowHistory", summary: "List the steps of a run and their attempts",
+		params: []param{idParam}, status: http.StatusOK, response: []client.StepRecord{}},
+	{method: "GET", path: "/v1/workflows/{id}/logs", handle: (*server).logs,
+		id: "getWorkflowLogs", summary: "List the lines a run logged, if the engine captures logs",
+		params: []param{idParam}, status: http.StatusOK, response: []client.LogLine{}},
+	{method: "POST", path: "/v1/workflows/{id}/cancel", handle: (*server).cancel,
+		id: "cancelWorkflow", summary: "Cancel a run",
+		params: []param{idParam}, status: http.StatusNoContent},
+	{method: "POST", path: "/v1/workflows/{id}/signals/{name}", handle: (*server).signal,
+		id: "signalWorkflow", summary: "Send a signal to a run; the body is its JSON payload",
+		params: []param{idParam, {name: "name", in: "path", typ: "string", description: "signal name"}},
+		body:   json.RawMessage{}, status: http.StatusAccepted},
+	{method: "GET", path: "/v1/workflows/{id}/events", handle: (*server).watchWorkflow,
+		id: "watchWorkflow", summary: "Stream a run's events until it finishes",
+		params: []param{idParam, afterParam}, status: http.StatusOK, response: client.Event{}, stream: true},
+	{method: "GET", path: "/v1/events", handle: (*server).watchAll,
+		id: "watchEvents", summary: "Stream the events of every run",
+		params: []param{afterParam}, status: http.StatusOK, response: client.Event{}, stream: true},
+}
+
+// openAPIRoute serves the OpenAPI document; NewHandler registers it apart
+// from routes, which the document is built from
+var openAPIRoute = route{method: "GET", path: "/v1/openapi.json",
+	id: "getOpenAPI", summary: "Get this OpenAPI document",
+	status: http.StatusOK, response: map[string]interface{}{}}
+
+var (
+	idParam    = param{name: "id", in: "path", typ: "string", description: "workflow ID"}
+	afterParam = param{name: "after", in: "query", typ: "integer", description: "event ID to resume after; default: events from now on"}
+)
+
+func (s *server) openapi(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, OpenAPI())
+}
+
+// OpenAPI returns the OpenAPI 3.0 document of the /v1 API, from which client
+// SDKs in other languages can be generated. It is also served at
+// /v1/openapi.json.
+func OpenAPI() map[string]interface{} {
+	schemas := make(map[string]interface{})
+	paths := make(map[string]interface{})
+	for _, rt := range append(routes, openAPIRoute) {
+		op := map[string]interface{}{
+			"operationId": rt.id,
+			"summary":     rt.summary,
+			"responses":   openAPIResponses(rt, schemas),
+		}
+		if len(rt.params) > 0 {
+			params := make([]interface{}, len(rt.params))
+			for i, p := range rt.params {
+				params[i] = openAPIParam(p)
+			}
+			op["parameters"] = params
+		}
+		if rt.body != nil {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": schemaOf(reflect.TypeOf(rt.body), schemas)},
+				},
+			}
+		}
+
+		item, _ := paths[rt.path].(map[string]interface{})
+		if item == nil {
+			item = make(map[string]interface{})
+			paths[rt.path] = item
+		}
+		item[strings.ToLower(rt.method)] = op
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Durable Execution Engine workflow API",
+			"version":     APIVersion,
+			"description": "Start, signal, cancel and inspect workflow runs. Inputs, signal payloads and outputs are JSON.",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer"},
+			},
+		},
+		// Servers started with WithAuth require the token; others accept anything
+		"security": []interface{}{map[string]interface{}{}, map[string]interface{}{"bearerAuth": []string{}}},
+	}
+}
+
+// openAPIResponses describes a route's success response and its errors
+func openAPIResponses(rt route, schemas map[string]interface{}) map[string]interface{} {
+	success := map[string]interface{}{"description": http.StatusText(rt.status)}
+	if rt.response != nil {
+		mediaType := "application/json"
+		if rt.stream {
+			mediaType = "application/x-ndjson"
+			success["description"] = "one JSON object per line"
+		}
+		success["content"] = map[string]interface{}{
+			mediaType: map[string]interface{}{"schema": schemaOf(reflect.TypeOf(rt.response), schemas)},
+		}
+	}
+	errorBody := map[string]interface{}{
+		"description": "error; code is one of " + strings.Join([]string{
+			client.CodeNotFound, client.CodeNotCompleted, client.CodeInvalid, client.CodeUnavailable, client.CodeUnauthorized, client.CodeInternal,
+		}, ", "),
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": schemaOf(reflect.TypeOf(client.ErrorResponse{}), schemas)},
+		},
+	}
+	return map[string]interface{}{
+		strconv.Itoa(rt.status): success,
+		"default":               errorBody,
+	}
+}
+
+// openAPIParam describes a path or query parameter
+func openAPIParam(p param) map[string]interface{} {
+	schema := map[string]interface{}{"type": p.typ}
+	if p.typ == "integer" {
+		schema["format"] = "int64"
+	}
+	if p.array {
+		schema = map[string]interface{}{"type": "array", "items": schema}
+	}
+	return map[string]interface{}{
+		"name":        p.name,
+		"in":          p.in,
+		"required":    p.in == "path",
+		"description": p.description,
+		"schema":      schema,
+	}
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaOf describes the JSON encoding of t. Structs become named schemas
+// in schemas, referenced by name, so generated SDKs get one type per
+// client type.
+func schemaOf(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == rawType:
+		return map[string]interface{}{"description": "any JSON value"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaOf(t.Elem(), schemas)
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem(), schemas)}
+	case reflect.Struct:
+		ref := map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+		if _, done := schemas[t.Name()]; done {
+			return ref
+		}
+		schemas[t.Name()] = nil // guards recursive types
+		properties := make(map[string]interface{})
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if !f.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			properties[name] = schemaOf(f.Type, schemas)
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		schemas[t.Name()] = schema
+		return ref
+	}
+	return map[string]interface{}{}
+}
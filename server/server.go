@@ -0,0 +1,416 @@
+// Package server exposes the engine's workflow API over HTTP, described by an
+// OpenAPI document. Inputs, signal payloads and outputs travel as JSON, so the
+// engine must use a JSON codec.
+package server
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/durable-execution-engine/client"
+	"github.com/yourusername/durable-execution-engine/engine"
+)
+
+// DefaultMaxBodyBytes bounds request bodies unless WithMaxBodyBytes is given
+const DefaultMaxBodyBytes = 1 << 20
+
+// AuthFunc decides whether a request may use the API; a non-nil error
+// rejects it with 401 and the error's message
+type AuthFunc func(r *http.Request) error
+
+// Option configures NewHandler
+type Option func(*server)
+
+// WithMaxBodyBytes bounds the size of request bodies; larger ones are
+// rejected with 413
+func WithMaxBodyBytes(n int64) Option {
+	return func(s *server) {
+		if n > 0 {
+			s.maxBodyBytes = n
+		}
+	}
+}
+
+// WithAuth makes every request pass auth, e.g. BearerToken
+func WithAuth(auth AuthFunc) Option {
+	return func(s *server) {
+		s.auth = auth
+	}
+}
+
+// BearerToken accepts requests carrying "Authorization: Bearer <token>"
+func BearerToken(token string) AuthFunc {
+	return func(r *http.Request) error {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			return errUnauthorized
+		}
+		return nil
+	}
+}
+
+// Authenticate wraps h so only requests passing auth reach it, for serving
+// other handlers (e.g. the dashboard) next to the API under the same check
+func Authenticate(h http.Handler, auth AuthFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := auth(r); err != nil {
+			if !errors.Is(err, errUnauthorized) {
+				err = fmt.Errorf("%w: %v", errUnauthorized, err)
+			}
+			writeError(w, err)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// NewHandler returns an http.Handler serving the /v1 workflow API backed by
+// eng, described by the OpenAPI document at /v1/openapi.json. Request
+// bodies are limited to DefaultMaxBodyBytes and anyone who can reach the
+// handler may use it unless WithAuth is given.
+func NewHandler(eng *engine.Engine, opts ...Option) http.Handler {
+	s := &server{eng: eng, maxBodyBytes: DefaultMaxBodyBytes}
+	for _, opt := range opts {
+		opt(s)
+	}
+	mux := http.NewServeMux()
+	for _, rt := range routes {
+		handle := rt.handle
+		mux.HandleFunc(rt.method+" "+rt.path, func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+			handle(s, w, r)
+		})
+	}
+	mux.HandleFunc(openAPIRoute.method+" "+openAPIRoute.path, s.openapi)
+	if s.auth != nil {
+		return Authenticate(mux, s.auth)
+	}
+	return mux
+}
+
+type server struct {
+	eng          *engine.Engine
+	maxBodyBytes int64
+	auth         AuthFunc
+}
+
+func (s *server) health(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok", "worker_id": s.eng.WorkerID()})
+}
+
+func (s *server) enqueue(w http.ResponseWriter, r *http.Request) {
+	var req client.StartRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if req.WorkflowID == "" || req.Name == "" {
+		writeError(w, fmt.Errorf("%w: workflow_id and name are required", errBadRequest))
+		return
+	}
+	input, err := decodeValue(req.Input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var opts []engine.WorkflowOption
+	if req.Queue != "" {
+		opts = append(opts, engine.WithQueue(req.Queue))
+	}
+	if req.Priority != 0 {
+		opts = append(opts, engine.WithPriority(req.Priority))
+	}
+	if req.ConcurrencyKey != "" {
+		opts = append(opts, engine.WithConcurrencyKey(req.ConcurrencyKey))
+	}
+	if req.TimeoutMs > 0 {
+		opts = append(opts, engine.WithWorkflowTimeout(time.Duration(req.TimeoutMs)*time.Millisecond))
+	}
+	for _, name := range req.OnComplete {
+		opts = append(opts, engine.WithOnComplete(name))
+	}
+	if req.Params != nil {
+		opts = append(opts, engine.WithParams(req.Params))
+	}
+	for name, value := range req.Headers {
+		opts = append(opts, engine.WithHeader(name, value))
+	}
+
+	if err := s.eng.Enqueue(req.WorkflowID, req.Name, input, opts...); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *server) list(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := engine.WorkflowFilter{
+		Names:     q["name"],
+		ParentIDs: q["parent_id"],
+		Status:    q.Get("status"),
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			writeError(w, fmt.Errorf("%w: bad limit %q", errBadRequest, v))
+			return
+		}
+		filter.Limit = limit
+	}
+
+	runs, err := s.eng.ListWorkflows(filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	out := make([]client.WorkflowInfo, len(runs))
+	for i := range runs {
+		out[i] = workflowInfo(&runs[i])
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *server) get(w http.ResponseWriter, r *http.Request) {
+	run, err := s.eng.GetWorkflow(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, workflowInfo(run))
+}
+
+func (s *server) history(w http.ResponseWriter, r *http.Request) {
+	steps, err := s.eng.GetHistory(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	out := make([]client.StepRecord, len(steps))
+	for i, st := range steps {
+		out[i] = client.StepRecord{
+			StepID:      st.StepID,
+			StepKey:     st.StepKey,
+			SequenceNum: st.SequenceNum,
+			Kind:        st.Kind,
+			Status:      st.Status,
+			Output:      rawJSON(st.Output),
+			Error:       st.Error,
+			WorkerID:    st.WorkerID,
+			Hostname:    st.Hostname,
+			PID:         st.PID,
+			StartedAt:   st.StartedAt,
+			CompletedAt: st.CompletedAt,
+		}
+		for _, a := range st.Attempts {
+			out[i].Attempts = append(out[i].Attempts, client.StepAttempt{
+				Attempt:     a.Attempt,
+				Status:      a.Status,
+				Error:       a.Error,
+				WorkerID:    a.WorkerID,
+				Hostname:    a.Hostname,
+				PID:         a.PID,
+				StartedAt:   a.StartedAt,
+				CompletedAt: a.CompletedAt,
+			})
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *server) logs(w http.ResponseWriter, r *http.Request) {
+	lines, err := s.eng.GetWorkflowLogs(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	out := make([]client.LogLine, len(lines))
+	for i, line := range lines {
+		out[i] = client.LogLine{Seq: line.Seq, Message: line.Message, Time: line.Time}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *server) cancel(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := s.eng.GetWorkflowStatus(id); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.eng.CancelWorkflow(id); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) signal(w http.ResponseWriter, r *http.Request) {
+	var raw json.RawMessage
+	if err := decodeBody(r, &raw); err != nil {
+		writeError(w, err)
+		return
+	}
+	payload, err := decodeValue(raw)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.eng.Signal(r.PathValue("id"), r.PathValue("name"), payload); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *server) watchWorkflow(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := s.eng.GetWorkflowStatus(id); err != nil {
+		writeError(w, err)
+		return
+	}
+	s.watch(w, r, id)
+}
+
+func (s *server) watchAll(w http.ResponseWriter, r *http.Request) {
+	s.watch(w, r, "")
+}
+
+// watch streams events as newline-delimited JSON until the client goes
+// away, the engine closes or, for one workflow, the workflow finishes. The
+// grpcapi package serves the same feed as a gRPC service.
+func (s *server) watch(w http.ResponseWriter, r *http.Request, workflowID string) {
+	after := engine.EventsFromNow
+	if v := r.URL.Query().Get("after"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeError(w, fmt.Errorf("%w: bad after %q", errBadRequest, v))
+			return
+		}
+		after = n
+	}
+
+	events, err := s.eng.WatchEvents(r.Context(), workflowID, after)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+	enc := json.NewEncoder(w)
+	for ev := range events {
+		if err := enc.Encode(client.Event{
+			ID:         ev.ID,
+			WorkflowID: ev.WorkflowID,
+			StepID:     ev.StepID,
+			StepKey:    ev.StepKey,
+			Status:     ev.Status,
+			Error:      ev.Error,
+			Message:    ev.Message,
+			Time:       ev.Time,
+		}); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// Errors marking requests that are rejected before reaching the engine
+var (
+	errBadRequest   = errors.New("bad request")
+	errTooLarge     = errors.New("request body too large")
+	errUnauthorized = errors.New("unauthorized")
+)
+
+// decodeBody decodes the JSON request body into v
+func decodeBody(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return fmt.Errorf("%w: limit is %d bytes", errTooLarge, tooLarge.Limit)
+		}
+		return fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+	return nil
+}
+
+// decodeValue turns a JSON document into a value the engine's codec can
+// re-encode; numbers are kept exact
+func decodeValue(raw json.RawMessage) (interface{}, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+	return v, nil
+}
+
+// workflowInfo converts a run to its wire form
+func workflowInfo(run *engine.WorkflowInfo) client.WorkflowInfo {
+	return client.WorkflowInfo{
+		WorkflowID: run.WorkflowID,
+		Name:       run.Name,
+		Status:     run.Status,
+		Queue:      run.Queue,
+		ParentID:   run.ParentID,
+		Output:     rawJSON(run.Output),
+		CreatedAt:  run.CreatedAt,
+		UpdatedAt:  run.UpdatedAt,
+	}
+}
+
+// rawJSON passes stored data through if it is JSON and drops it otherwise
+func rawJSON(data []byte) json.RawMessage {
+	if len(data) == 0 || !json.Valid(data) {
+		return nil
+	}
+	return data
+}
+
+// writeJSON writes v with the given status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		fmt.Printf("[API] failed to write response: %v\n", err)
+	}
+}
+
+// writeError maps engine errors to a status and an error code the client understands
+func writeError(w http.ResponseWriter, err error) {
+	status, code := http.StatusInternalServerError, client.CodeInternal
+	switch {
+	case errors.Is(err, engine.ErrWorkflowNotFound):
+		status, code = http.StatusNotFound, client.CodeNotFound
+	case errors.Is(err, engine.ErrWorkflowNotCompleted):
+		status, code = http.StatusConflict, client.CodeNotCompleted
+	case errors.Is(err, errBadRequest), errors.Is(err, engine.ErrSchemaViolation), errors.Is(err, engine.ErrMissingParam):
+		status, code = http.StatusBadRequest, client.CodeInvalid
+	case errors.Is(err, errTooLarge):
+		status, code = http.StatusRequestEntityTooLarge, client.CodeInvalid
+	case errors.Is(err, errUnauthorized):
+		status, code = http.StatusUnauthorized, client.CodeUnauthorized
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	case errors.Is(err, engine.ErrBackpressure):
+		status, code = http.StatusServiceUnavailable, client.CodeUnavailable
+	}
+	writeJSON(w, status, client.ErrorResponse{Code: code, Message: err.Error()})
+}
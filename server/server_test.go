@@ -0,0 +1,296 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/durable-execution-engine/client"
+	"github.com/yourusername/durable-execution-engine/engine"
+)
+
+type order struct {
+	ID    string `json:"id"`
+	Total int64  `json:"total"`
+}
+
+func TestClientRoundTrip(t *testing.T) {
+	dbPath := "./test_server.db"
+	defer os.Remove(dbPath)
+
+	eng, err := engine.NewEngine(dbPath, engine.WithLogCapture(engine.LogCapture{}))
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	checkout := engine.NewWorkflow("checkout", func(ctx *engine.Context, in order) (order, error) {
+		approval, err := engine.WaitForSignal[string](ctx, "approve")
+		if err != nil {
+			return order{}, err
+		}
+		ctx.Logf("approved: %s", approval)
+		in.ID += "-" + approval
+		return in, nil
+	})
+	checkout.Register(eng)
+
+	srv := httptest.NewServer(NewHandler(eng))
+	defer srv.Close()
+
+	c, err := client.Dial(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer c.Close()
+
+	if err := c.Enqueue("checkout-1", "checkout", order{ID: "o1", Total: 9007199254740993},
+		client.WithHeader("trace_id", "t-1")); err != nil {
+		t.Fatalf("failed to enqueue: %v", err)
+	}
+	if headers, err := eng.GetWorkflowHeaders("checkout-1"); err != nil || headers["trace_id"] != "t-1" {
+		t.Errorf("expected the trace_id header, got %v: %v", headers, err)
+	}
+	if err := c.Result("checkout-1", nil); !errors.Is(err, client.ErrWorkflowNotCompleted) {
+		t.Fatalf("expected ErrWorkflowNotCompleted, got %v", err)
+	}
+	if err := c.Signal("checkout-1", "approve", "ok"); err != nil {
+		t.Fatalf("failed to signal: %v", err)
+	}
+
+	if err := eng.StartWorker(engine.WorkerConfig{PollInterval: 10 * time.Millisecond}); err != nil {
+		t.Fatalf("failed to start worker: %v", err)
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		status, err := c.GetWorkflowStatus("checkout-1")
+		if err != nil {
+			t.Fatalf("failed to get status: %v", err)
+		}
+		if status == "completed" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("workflow never completed, status %s", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var out order
+	if err := c.Result("checkout-1", &out); err != nil {
+		t.Fatalf("failed to get result: %v", err)
+	}
+	if out.ID != "o1-ok" || out.Total != 9007199254740993 {
+		t.Errorf("unexpected result %+v", out)
+	}
+
+	runs, err := c.ListWorkflows(client.WorkflowFilter{Names: []string{"checkout"}})
+	if err != nil || len(runs) != 1 || runs[0].WorkflowID != "checkout-1" {
+		t.Errorf("unexpected runs %+v: %v", runs, err)
+	}
+	steps, err := c.GetHistory("checkout-1")
+	if err != nil || len(steps) == 0 {
+		t.Errorf("expected history, got %+v: %v", steps, err)
+	}
+	lines, err := c.GetLogs("checkout-1")
+	if err != nil || len(lines) != 1 || lines[0].Message != "approved: ok" {
+		t.Errorf("expected the captured log line, got %+v: %v", lines, err)
+	}
+
+	if _, err := c.GetWorkflow("missing"); !errors.Is(err, client.ErrWorkflowNotFound) {
+		t.Errorf("expected ErrWorkflowNotFound, got %v", err)
+	}
+	if err := c.CancelWorkflow("missing"); !errors.Is(err, client.ErrWorkflowNotFound) {
+		t.Errorf("expected ErrWorkflowNotFound on cancel, got %v", err)
+	}
+}
+
+func TestClientDialFailure(t *testing.T) {
+	srv := httptest.NewServer(nil)
+	addr := srv.URL
+	srv.Close()
+
+	if _, err := client.Dial(addr); !errors.Is(err, client.ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable, got %v", err)
+	}
+}
+
+func TestClientWatchWorkflow(t *testing.T) {
+	dbPath := "./test_server_watch.db"
+	defer os.Remove(dbPath)
+
+	eng, err := engine.NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	eng.Register("ship", func(ctx *engine.Context) error {
+		if _, err := engine.WaitForSignal[string](ctx, "go"); err != nil {
+			return err
+		}
+		_, err := engine.Step(ctx, "label", func() (string, error) { return "ok", nil })
+		return err
+	})
+
+	srv := httptest.NewServer(NewHandler(eng))
+	defer srv.Close()
+	c, err := client.Dial(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	all, err := c.WatchAll(ctx, client.EventsFromNow)
+	if err != nil {
+		t.Fatalf("failed to watch all: %v", err)
+	}
+	defer all.Close()
+
+	if err := c.Enqueue("ship-1", "ship", nil); err != nil {
+		t.Fatalf("failed to enqueue: %v", err)
+	}
+	first, err := all.Recv()
+	if err != nil || first.WorkflowID != "ship-1" || first.Status != "queued" {
+		t.Fatalf("expected queued event, got %+v: %v", first, err)
+	}
+
+	if _, err := c.WatchWorkflow(ctx, "missing", 0); !errors.Is(err, client.ErrWorkflowNotFound) {
+		t.Errorf("expected ErrWorkflowNotFound, got %v", err)
+	}
+	stream, err := c.WatchWorkflow(ctx, "ship-1", 0)
+	if err != nil {
+		t.Fatalf("failed to watch workflow: %v", err)
+	}
+	defer stream.Close()
+
+	if err := eng.StartWorker(engine.WorkerConfig{PollInterval: 10 * time.Millisecond}); err != nil {
+		t.Fatalf("failed to start worker: %v", err)
+	}
+	c.Signal("ship-1", "go", "now")
+
+	var got []string
+	for {
+		ev, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("stream failed: %v", err)
+		}
+		if ev.StepID == "label" || ev.StepID == "" {
+			got = append(got, ev.StepID+":"+ev.Status)
+		}
+	}
+	want := []string{":queued", ":running", "label:in_progress", "label:completed", ":completed"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	dbPath := "./test_openapi.db"
+	defer os.Remove(dbPath)
+
+	eng, err := engine.NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+
+	srv := httptest.NewServer(NewHandler(eng))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/v1/openapi.json")
+	if err != nil {
+		t.Fatalf("failed to get document: %v", err)
+	}
+	defer resp.Body.Close()
+	var doc struct {
+		OpenAPI    string                                       `json:"openapi"`
+		Paths      map[string]map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]interface{} `json:"properties"`
+				Required   []string               `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatalf("failed to decode document: %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("expected OpenAPI 3.0.3, got %q", doc.OpenAPI)
+	}
+
+	// Every served route is described, and every referenced schema defined
+	for _, rt := range append(routes, openAPIRoute) {
+		if _, ok := doc.Paths[rt.path][strings.ToLower(rt.method)]; !ok {
+			t.Errorf("expected %s %s in the document", rt.method, rt.path)
+		}
+	}
+	body, _ := json.Marshal(doc.Paths)
+	for _, ref := range regexp.MustCompile(`#/components/schemas/(\w+)`).FindAllStringSubmatch(string(body), -1) {
+		if _, ok := doc.Components.Schemas[ref[1]]; !ok {
+			t.Errorf("expected schema %s to be defined", ref[1])
+		}
+	}
+
+	info := doc.Components.Schemas["WorkflowInfo"]
+	if _, ok := info.Properties["created_at"]; !ok || !slices.Contains(info.Required, "workflow_id") || slices.Contains(info.Required, "output") {
+		t.Errorf("expected WorkflowInfo to follow its JSON tags, got %+v", info)
+	}
+	if _, ok := doc.Components.Schemas["StepAttempt"]; !ok {
+		t.Error("expected nested types to get their own schema")
+	}
+}
+
+func TestAuthAndBodyLimit(t *testing.T) {
+	dbPath := "./test_server_auth.db"
+	defer os.Remove(dbPath)
+
+	eng, err := engine.NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	defer eng.Close()
+	eng.Register("noop", func(ctx *engine.Context) error { return nil })
+
+	srv := httptest.NewServer(NewHandler(eng, WithAuth(BearerToken("s3cret")), WithMaxBodyBytes(64)))
+	defer srv.Close()
+
+	if _, err := client.Dial(srv.URL); !errors.Is(err, client.ErrUnauthorized) {
+		t.Fatalf("expected ErrUnauthorized without a token, got %v", err)
+	}
+	if _, err := client.Dial(srv.URL, client.WithBearerToken("wrong")); !errors.Is(err, client.ErrUnauthorized) {
+		t.Fatalf("expected ErrUnauthorized with the wrong token, got %v", err)
+	}
+	c, err := client.Dial(srv.URL, client.WithBearerToken("s3cret"))
+	if err != nil {
+		t.Fatalf("failed to dial with the token: %v", err)
+	}
+	defer c.Close()
+
+	if err := c.Enqueue("noop-1", "noop", nil); err != nil {
+		t.Fatalf("failed to enqueue: %v", err)
+	}
+	err = c.Enqueue("noop-2", "noop", strings.Repeat("x", 100))
+	if !errors.Is(err, client.ErrInvalidRequest) || !strings.Contains(err.Error(), "too large") {
+		t.Fatalf("expected an oversized body to be rejected, got %v", err)
+	}
+	if _, err := eng.GetWorkflowStatus("noop-2"); !errors.Is(err, engine.ErrWorkflowNotFound) {
+		t.Errorf("expected the oversized request not to enqueue, got %v", err)
+	}
+}